@@ -10,6 +10,7 @@ import (
 	"strings"
 	"syscall"
 	"time"
+	_ "time/tzdata" // часовые пояса регионов не зависят от tzdata в образе
 
 	"delivery-system/internal/config"
 	"delivery-system/internal/database"
@@ -17,19 +18,24 @@ import (
 	"delivery-system/internal/kafka"
 	"delivery-system/internal/logger"
 	"delivery-system/internal/models"
+	"delivery-system/internal/money"
+	"delivery-system/internal/payments"
 	"delivery-system/internal/redis"
 	"delivery-system/internal/services"
+	"delivery-system/internal/storage"
 )
 
 // Фабричные функции для подключения внешних сервисов (подменяемые в тестах).
 var (
-	dbConnect        = database.Connect
-	redisConnect     = redis.Connect
-	newKafkaProducer = kafka.NewProducer
-	newKafkaConsumer = kafka.NewConsumer
-	kafkaHealthCheck = handlers.CheckKafkaHealth
-	loadConfig       = config.Load
-	newLogger        = logger.New
+	dbConnect          = database.Connect
+	redisConnect       = redis.Connect
+	newKafkaProducer   = kafka.NewProducer
+	newKafkaConsumer   = kafka.NewConsumer
+	newBlobStorage     = storage.New
+	newPaymentProvider = payments.New
+	kafkaHealthCheck   = handlers.CheckKafkaHealth
+	loadConfig         = config.Load
+	newLogger          = logger.New
 )
 
 // application агрегирует собранные зависимости.
@@ -42,6 +48,10 @@ type application struct {
 	consumer *kafka.Consumer
 	mux      *http.ServeMux
 	server   *http.Server
+	// reconciler периодически освобождает курьеров, оставшихся busy без заказов
+	reconciler *services.CourierReconciler
+	// payments повторяет операции у платежного провайдера, не выполненные после коммита
+	payments *services.PaymentService
 }
 
 func main() {
@@ -59,6 +69,10 @@ func main() {
 		}
 	}()
 
+	background, stopBackground := context.WithCancel(context.Background())
+	go app.reconciler.Run(background)
+	go app.payments.Run(background)
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
@@ -66,6 +80,7 @@ func main() {
 
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
+	stopBackground()
 	_ = app.consumer.Stop()
 	if err := app.server.Shutdown(ctx); err != nil {
 		app.log.WithError(err).Error("Server forced to shutdown")
@@ -80,6 +95,19 @@ func main() {
 func buildApplication() (*application, error) {
 	cfg := loadConfig()
 	log := newLogger(&cfg.Logger)
+	if cfg.Pricing.Currency != "" {
+		money.DefaultCurrency = cfg.Pricing.Currency
+	}
+
+	pricingService, err := newPricingService(&cfg.Pricing)
+	if err != nil {
+		return nil, fmt.Errorf("pricing regions: %w", err)
+	}
+
+	orderFlow, err := newOrderFlow(&cfg.OrderFlow)
+	if err != nil {
+		return nil, fmt.Errorf("order flow: %w", err)
+	}
 
 	db, err := dbConnect(&cfg.Database, log)
 	if err != nil {
@@ -107,24 +135,72 @@ func buildApplication() (*application, error) {
 		return nil, fmt.Errorf("kafka consumer: %w", err)
 	}
 
-	pricingService := services.NewPricingService(cfg.Pricing.BaseFare, cfg.Pricing.PerKm, cfg.Pricing.MinFare)
-	promoService := services.NewPromoService(db, log)
+	blobStorage, err := newBlobStorage(&cfg.Storage)
+	if err != nil {
+		_ = consumer.Stop()
+		_ = producer.Close()
+		_ = redisClient.Close()
+		_ = db.Close()
+		return nil, fmt.Errorf("blob storage: %w", err)
+	}
 
-	orderService := services.NewOrderService(db, log, pricingService, promoService)
+	paymentProvider, err := newPaymentProvider(&cfg.Payments)
+	if err != nil {
+		_ = consumer.Stop()
+		_ = producer.Close()
+		_ = redisClient.Close()
+		_ = db.Close()
+		return nil, fmt.Errorf("payments provider: %w", err)
+	}
+
+	promoService := services.NewPromoService(db, log, pricingService, &cfg.Promo)
+	campaignService := services.NewCampaignService(db, log)
+	payoutRules := services.NewPayoutRules(cfg.Payout.PerDelivery, cfg.Payout.PerKm, cfg.Payout.MinPayout, pricingService.Currency())
+	earningsService := services.NewEarningsService(db, log, payoutRules, pricingService)
+	loyaltyService := services.NewLoyaltyService(db, log, &cfg.Loyalty)
+	paymentService := services.NewPaymentService(db, paymentProvider, log, &cfg.Payments, loyaltyService)
+	receiptService := services.NewReceiptService(db, log, pricingService)
+	walletService := services.NewWalletService(db, log)
+	referralService := services.NewReferralService(db, log, walletService, &cfg.Referral)
+
+	orderService := services.NewOrderService(db, log, pricingService, promoService, earningsService, paymentService, receiptService, referralService, walletService, loyaltyService, orderFlow)
 	courierService := services.NewCourierService(db, log)
 	assignmentService := services.NewCourierAssignmentService(db, courierService, orderService, log)
 	geocodingService := services.NewGeocodingService(redisClient, log, &cfg.Geocoding)
-	analyticsService := services.NewAnalyticsService(db, redisClient, log, &cfg.Analytics)
+	analyticsService := services.NewAnalyticsService(db, redisClient, log, &cfg.Analytics, pricingService)
 	rateLimiter := services.NewRateLimiter(redisClient, log, &cfg.RateLimit)
-
-	orderHandler := handlers.NewOrderHandler(orderService, assignmentService, geocodingService, producer, redisClient, log)
+	// Отдельный лимит на проверку промокодов, чтобы их нельзя было перебирать
+	promoValidateLimiter := services.NewRateLimiter(redisClient, log, &config.RateLimitConfig{
+		Enabled:       cfg.Promo.ValidateRequests > 0,
+		Requests:      cfg.Promo.ValidateRequests,
+		WindowSeconds: cfg.Promo.ValidateWindowSeconds,
+		KeyPrefix:     "ratelimit:promo-validate",
+	})
+	proofService := services.NewProofService(db, blobStorage, log)
+	onboardingService := services.NewCourierOnboardingService(db, blobStorage, orderFlow, log)
+	performanceService := services.NewCourierPerformanceService(db, log)
+	reviewService := services.NewReviewService(db, log, &cfg.Reviews)
+	courierReconciler := services.NewCourierReconciler(db, log, orderFlow, producer,
+		time.Duration(cfg.Couriers.ReconcileIntervalSeconds)*time.Second, time.Duration(cfg.Couriers.ReconcileGraceSeconds)*time.Second)
+
+	orderHandler := handlers.NewOrderHandler(orderService, assignmentService, geocodingService, receiptService, producer, redisClient, log)
 	courierHandler := handlers.NewCourierHandler(courierService, orderService, producer, redisClient, log)
+	onboardingHandler := handlers.NewCourierOnboardingHandler(onboardingService, producer, redisClient, log, &cfg.Storage)
+	performanceHandler := handlers.NewCourierPerformanceHandler(performanceService, log)
+	reviewHandler := handlers.NewReviewHandler(reviewService, redisClient, log)
 	promoHandler := handlers.NewPromoHandler(promoService, log)
+	campaignHandler := handlers.NewCampaignHandler(campaignService, log)
+	referralHandler := handlers.NewReferralHandler(referralService, walletService, log)
+	loyaltyHandler := handlers.NewLoyaltyHandler(loyaltyService, log)
 	analyticsHandler := handlers.NewAnalyticsHandler(analyticsService, log, &cfg.Analytics)
 	healthHandler := handlers.NewHealthHandler(db, redisClient, cfg.Kafka.Brokers, kafkaHealthCheck)
 	rateLimitHandler := handlers.NewRateLimitHandler(rateLimiter, log, &cfg.RateLimit)
+	proofHandler := handlers.NewProofHandler(proofService, log, &cfg.Storage)
+	earningsHandler := handlers.NewEarningsHandler(earningsService, log)
+	paymentHandler := handlers.NewPaymentHandler(paymentService, log, &cfg.Payments)
+	receiptHandler := handlers.NewReceiptHandler(receiptService, log)
 
-	registerEventHandlers(consumer, log)
+	registerEventHandlers(consumer, performanceService, log)
 	if err := consumer.Start(); err != nil {
 		_ = consumer.Stop()
 		_ = producer.Close()
@@ -133,7 +209,7 @@ func buildApplication() (*application, error) {
 		return nil, fmt.Errorf("kafka consumer start: %w", err)
 	}
 
-	mux := setupRoutes(orderHandler, courierHandler, healthHandler, promoHandler, analyticsHandler, rateLimitHandler, rateLimiter, log)
+	mux := setupRoutes(orderHandler, proofHandler, earningsHandler, paymentHandler, receiptHandler, courierHandler, onboardingHandler, performanceHandler, reviewHandler, healthHandler, promoHandler, campaignHandler, referralHandler, loyaltyHandler, analyticsHandler, rateLimitHandler, rateLimiter, promoValidateLimiter, log)
 	server := &http.Server{
 		Addr:         fmt.Sprintf("%s:%s", cfg.Server.Host, cfg.Server.Port),
 		Handler:      mux,
@@ -142,23 +218,25 @@ func buildApplication() (*application, error) {
 	}
 
 	return &application{
-		cfg:      cfg,
-		log:      log,
-		db:       db,
-		redis:    redisClient,
-		producer: producer,
-		consumer: consumer,
-		mux:      mux,
-		server:   server,
+		cfg:        cfg,
+		log:        log,
+		db:         db,
+		redis:      redisClient,
+		producer:   producer,
+		consumer:   consumer,
+		mux:        mux,
+		server:     server,
+		reconciler: courierReconciler,
+		payments:   paymentService,
 	}, nil
 }
 
 // setupRoutes настраивает маршруты HTTP сервера
-func setupRoutes(orderHandler *handlers.OrderHandler, courierHandler *handlers.CourierHandler, healthHandler *handlers.HealthHandler, promoHandler *handlers.PromoHandler, analyticsHandler *handlers.AnalyticsHandler, rateLimitHandler *handlers.RateLimitHandler, rateLimiter *services.RateLimiter, log *logger.Logger) *http.ServeMux {
+func setupRoutes(orderHandler *handlers.OrderHandler, proofHandler *handlers.ProofHandler, earningsHandler *handlers.EarningsHandler, paymentHandler *handlers.PaymentHandler, receiptHandler *handlers.ReceiptHandler, courierHandler *handlers.CourierHandler, onboardingHandler *handlers.CourierOnboardingHandler, performanceHandler *handlers.CourierPerformanceHandler, reviewHandler *handlers.ReviewHandler, healthHandler *handlers.HealthHandler, promoHandler *handlers.PromoHandler, campaignHandler *handlers.CampaignHandler, referralHandler *handlers.ReferralHandler, loyaltyHandler *handlers.LoyaltyHandler, analyticsHandler *handlers.AnalyticsHandler, rateLimitHandler *handlers.RateLimitHandler, rateLimiter, promoValidateLimiter *services.RateLimiter, log *logger.Logger) *http.ServeMux {
 	mux := http.NewServeMux()
 
 	applyAPI := func(h http.HandlerFunc) http.HandlerFunc {
-		return corsMiddleware(handlers.RateLimitMiddleware(rateLimiter, log, h))
+		return corsMiddleware(handlers.RateLimitMiddleware(rateLimiter, log, handlers.ActorMiddleware(h)))
 	}
 
 	// Health check endpoints
@@ -168,20 +246,46 @@ func setupRoutes(orderHandler *handlers.OrderHandler, courierHandler *handlers.C
 
 	// Order endpoints
 	mux.HandleFunc("/api/orders", applyAPI(handleOrdersRoute(orderHandler)))
-	mux.HandleFunc("/api/orders/", applyAPI(handleOrderRoute(orderHandler)))
+	mux.HandleFunc("/api/orders/search", applyAPI(orderHandler.SearchOrders))
+	mux.HandleFunc("/api/orders/", applyAPI(handleOrderRoute(orderHandler, proofHandler, earningsHandler, paymentHandler, receiptHandler, reviewHandler)))
 
 	// Courier endpoints
 	mux.HandleFunc("/api/couriers", applyAPI(handleCouriersRoute(courierHandler)))
-	mux.HandleFunc("/api/couriers/", applyAPI(handleCourierRoute(courierHandler)))
+	mux.HandleFunc("/api/couriers/", applyAPI(handleCourierRoute(courierHandler, onboardingHandler, performanceHandler, reviewHandler, earningsHandler)))
 	mux.HandleFunc("/api/couriers/available", applyAPI(courierHandler.GetAvailableCouriers))
+	mux.HandleFunc("/api/courier-documents/expiring", applyAPI(onboardingHandler.GetExpiringDocuments))
+
+	// Review moderation endpoints
+	mux.HandleFunc("/api/reviews", applyAPI(reviewHandler.ListReviews))
+	mux.HandleFunc("/api/reviews/", applyAPI(handleReviewRoute(reviewHandler)))
 
 	// Promo codes endpoints
 	mux.HandleFunc("/api/promo-codes", applyAPI(handlePromoCodesRoute(promoHandler)))
-	mux.HandleFunc("/api/promo-codes/", applyAPI(handlePromoCodeRoute(promoHandler)))
+	mux.HandleFunc("/api/promo-codes/", applyAPI(handlePromoCodeRoute(promoHandler, promoValidateLimiter, log)))
+
+	// Campaign endpoints
+	mux.HandleFunc("/api/campaigns", applyAPI(handleCampaignsRoute(campaignHandler)))
+	mux.HandleFunc("/api/campaigns/", applyAPI(handleCampaignRoute(campaignHandler)))
+
+	// Referral program endpoints
+	mux.HandleFunc("/api/referral-codes", applyAPI(referralHandler.CreateReferralCode))
+	mux.HandleFunc("/api/referral-codes/", applyAPI(handleReferralCodeRoute(referralHandler)))
+	mux.HandleFunc("/api/wallets/", applyAPI(referralHandler.GetWallet))
+
+	// Loyalty points endpoints
+	mux.HandleFunc("/api/loyalty/", applyAPI(handleLoyaltyRoute(loyaltyHandler)))
+
+	// Payment provider webhooks
+	mux.HandleFunc("/api/payments/webhook", corsMiddleware(paymentHandler.Webhook))
+
+	// Courier payouts
+	mux.HandleFunc("/api/payouts", applyAPI(earningsHandler.GetPayouts))
 
 	// Analytics endpoints
 	mux.HandleFunc("/api/analytics/kpi", applyAPI(analyticsHandler.GetKPIs))
 	mux.HandleFunc("/api/analytics/couriers", applyAPI(analyticsHandler.GetCourierAnalytics))
+	mux.HandleFunc("/api/analytics/merchants", applyAPI(analyticsHandler.GetMerchantAnalytics))
+	mux.HandleFunc("/api/analytics/stages", applyAPI(analyticsHandler.GetStageTimings))
 
 	// Rate limit status
 	mux.HandleFunc("/api/rate-limit/status", applyAPI(rateLimitHandler.Status))
@@ -204,19 +308,98 @@ func handleOrdersRoute(handler *handlers.OrderHandler) http.HandlerFunc {
 }
 
 // handleOrderRoute обрабатывает маршруты для отдельного заказа
-func handleOrderRoute(handler *handlers.OrderHandler) http.HandlerFunc {
+func handleOrderRoute(handler *handlers.OrderHandler, proofHandler *handlers.ProofHandler, earningsHandler *handlers.EarningsHandler, paymentHandler *handlers.PaymentHandler, receiptHandler *handlers.ReceiptHandler, reviewHandler *handlers.ReviewHandler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if strings.HasSuffix(r.URL.Path, "/status") {
+		if strings.HasSuffix(r.URL.Path, "/payment/refund") {
+			// Возврат оплаты по заказу
+			if r.Method == http.MethodPost {
+				paymentHandler.RefundPayment(w, r)
+			} else {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if strings.HasSuffix(r.URL.Path, "/payment") {
+			// Платеж по заказу
+			if r.Method == http.MethodGet {
+				paymentHandler.GetPayment(w, r)
+			} else {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if strings.HasSuffix(r.URL.Path, "/receipt") {
+			// Чек по доставленному заказу
+			if r.Method == http.MethodGet {
+				receiptHandler.GetReceipt(w, r)
+			} else {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if strings.Contains(r.URL.Path, "/proof/") {
+			// Получение содержимого подтверждения доставки
+			if r.Method == http.MethodGet {
+				proofHandler.GetProofContent(w, r)
+			} else {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if strings.HasSuffix(r.URL.Path, "/proof") {
+			// Загрузка и список подтверждений доставки
+			switch r.Method {
+			case http.MethodGet:
+				proofHandler.ListProofs(w, r)
+			case http.MethodPost:
+				proofHandler.UploadProof(w, r)
+			default:
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if strings.HasSuffix(r.URL.Path, "/status") {
 			// Обновление статуса заказа
 			if r.Method == http.MethodPut {
 				handler.UpdateOrderStatus(w, r)
 			} else {
 				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
 			}
+		} else if strings.HasSuffix(r.URL.Path, "/history") {
+			// Хронология статусов заказа
+			if r.Method == http.MethodGet {
+				handler.GetOrderHistory(w, r)
+			} else {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if strings.HasSuffix(r.URL.Path, "/contact-attempts") {
+			// Попытки связаться с клиентом при неудачной доставке
+			switch r.Method {
+			case http.MethodGet:
+				handler.GetDeliveryAttempts(w, r)
+			case http.MethodPost:
+				handler.LogContactAttempt(w, r)
+			default:
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if strings.HasSuffix(r.URL.Path, "/tip") {
+			// Чаевые курьеру по заказу
+			if r.Method == http.MethodPost {
+				earningsHandler.AddTip(w, r)
+			} else {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
 		} else if strings.HasSuffix(r.URL.Path, "/review") {
-			// Создание отзыва по заказу
+			// Создание и правка отзыва по заказу
+			switch r.Method {
+			case http.MethodPost:
+				reviewHandler.CreateReview(w, r)
+			case http.MethodPut:
+				reviewHandler.UpdateReview(w, r)
+			default:
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if strings.HasSuffix(r.URL.Path, "/unassign") {
+			// Снятие курьера с заказа диспетчером
 			if r.Method == http.MethodPost {
-				handler.CreateReview(w, r)
+				handler.UnassignCourier(w, r)
+			} else {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if strings.HasSuffix(r.URL.Path, "/reassign") {
+			// Передача заказа другому курьеру диспетчером
+			if r.Method == http.MethodPost {
+				handler.ReassignCourier(w, r)
 			} else {
 				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
 			}
@@ -228,10 +411,13 @@ func handleOrderRoute(handler *handlers.OrderHandler) http.HandlerFunc {
 				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
 			}
 		} else {
-			// Получение заказа по ID
-			if r.Method == http.MethodGet {
+			// Получение и изменение заказа по ID
+			switch r.Method {
+			case http.MethodGet:
 				handler.GetOrder(w, r)
-			} else {
+			case http.MethodPatch:
+				handler.UpdateOrder(w, r)
+			default:
 				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
 			}
 		}
@@ -253,9 +439,40 @@ func handleCouriersRoute(handler *handlers.CourierHandler) http.HandlerFunc {
 }
 
 // handleCourierRoute обрабатывает маршруты для отдельного курьера
-func handleCourierRoute(handler *handlers.CourierHandler) http.HandlerFunc {
+func handleCourierRoute(handler *handlers.CourierHandler, onboardingHandler *handlers.CourierOnboardingHandler, performanceHandler *handlers.CourierPerformanceHandler, reviewHandler *handlers.ReviewHandler, earningsHandler *handlers.EarningsHandler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if strings.HasSuffix(r.URL.Path, "/status") {
+		if strings.Contains(r.URL.Path, "/documents/") {
+			// Содержимое документа курьера
+			if r.Method == http.MethodGet {
+				onboardingHandler.GetDocumentContent(w, r)
+			} else {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if strings.HasSuffix(r.URL.Path, "/documents") {
+			// Документы курьера: список и загрузка
+			switch r.Method {
+			case http.MethodGet:
+				onboardingHandler.ListDocuments(w, r)
+			case http.MethodPost:
+				onboardingHandler.UploadDocument(w, r)
+			default:
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if strings.HasSuffix(r.URL.Path, "/onboarding") {
+			// Смена этапа подключения курьера
+			if r.Method == http.MethodPut {
+				onboardingHandler.UpdateOnboarding(w, r)
+			} else {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if strings.HasSuffix(r.URL.Path, "/performance") {
+			// Оценка работы курьера
+			if r.Method == http.MethodGet {
+				performanceHandler.GetPerformance(w, r)
+			} else {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if strings.HasSuffix(r.URL.Path, "/status") {
 			// Обновление статуса курьера
 			if r.Method == http.MethodPut {
 				handler.UpdateCourierStatus(w, r)
@@ -269,10 +486,31 @@ func handleCourierRoute(handler *handlers.CourierHandler) http.HandlerFunc {
 			} else {
 				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
 			}
+		} else if strings.HasSuffix(r.URL.Path, "/balance") {
+			// Баланс начислений курьера
+			if r.Method == http.MethodGet {
+				earningsHandler.GetBalance(w, r)
+			} else {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if strings.HasSuffix(r.URL.Path, "/statement") {
+			// Выписка по начислениям курьера за период
+			if r.Method == http.MethodGet {
+				earningsHandler.GetStatement(w, r)
+			} else {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if strings.HasSuffix(r.URL.Path, "/adjustments") {
+			// Бонусы и корректировки баланса курьера
+			if r.Method == http.MethodPost {
+				earningsHandler.CreateAdjustment(w, r)
+			} else {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
 		} else if strings.HasSuffix(r.URL.Path, "/reviews") {
-			// Получение отзывов курьера
+			// Получение опубликованных отзывов курьера
 			if r.Method == http.MethodGet {
-				handler.GetCourierReviews(w, r)
+				reviewHandler.GetCourierReviews(w, r)
 			} else {
 				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
 			}
@@ -302,8 +540,17 @@ func handlePromoCodesRoute(handler *handlers.PromoHandler) http.HandlerFunc {
 }
 
 // handlePromoCodeRoute обрабатывает отдельный промокод
-func handlePromoCodeRoute(handler *handlers.PromoHandler) http.HandlerFunc {
+func handlePromoCodeRoute(handler *handlers.PromoHandler, validateLimiter *services.RateLimiter, log *logger.Logger) http.HandlerFunc {
+	validate := handlers.RateLimitMiddleware(validateLimiter, log, handler.ValidatePromoCode)
 	return func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/validate") {
+			validate(w, r)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/redemptions") {
+			handler.ListRedemptions(w, r)
+			return
+		}
 		if r.Method == http.MethodGet {
 			handler.GetPromoCode(w, r)
 			return
@@ -320,8 +567,136 @@ func handlePromoCodeRoute(handler *handlers.PromoHandler) http.HandlerFunc {
 	}
 }
 
+// handleCampaignsRoute обрабатывает коллекцию кампаний
+func handleCampaignsRoute(handler *handlers.CampaignHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			handler.ListCampaigns(w, r)
+		case http.MethodPost:
+			handler.CreateCampaign(w, r)
+		default:
+			writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	}
+}
+
+// handleCampaignRoute обрабатывает отдельную кампанию и ее коды
+func handleCampaignRoute(handler *handlers.CampaignHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/codes") {
+			// Выпуск и выгрузка кодов кампании
+			switch r.Method {
+			case http.MethodGet:
+				handler.ListCodes(w, r)
+			case http.MethodPost:
+				handler.GenerateCodes(w, r)
+			default:
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if strings.HasSuffix(r.URL.Path, "/deactivate") {
+			// Отключение кампании вместе с кодами
+			if r.Method == http.MethodPost {
+				handler.DeactivateCampaign(w, r)
+			} else {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if strings.HasSuffix(r.URL.Path, "/stats") {
+			// Статистика использования кодов
+			if r.Method == http.MethodGet {
+				handler.GetStats(w, r)
+			} else {
+				writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+			}
+		} else if r.Method == http.MethodGet {
+			handler.GetCampaign(w, r)
+		} else {
+			writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		}
+	}
+}
+
+// handleReviewRoute обрабатывает ответ курьера и модерацию отдельного отзыва
+func handleReviewRoute(handler *handlers.ReviewHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/reply") {
+			handler.ReplyToReview(w, r)
+		} else if strings.HasSuffix(r.URL.Path, "/moderation") {
+			handler.ModerateReview(w, r)
+		} else {
+			writeErrorResponse(w, http.StatusNotFound, "Not found")
+		}
+	}
+}
+
+// handleReferralCodeRoute обрабатывает реферальный код и его приглашения
+func handleReferralCodeRoute(handler *handlers.ReferralHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/referrals") {
+			handler.ListReferrals(w, r)
+		} else {
+			handler.GetReferralCode(w, r)
+		}
+	}
+}
+
+// handleLoyaltyRoute обрабатывает баланс и историю баллов клиента
+func handleLoyaltyRoute(handler *handlers.LoyaltyHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/history") {
+			handler.GetHistory(w, r)
+		} else {
+			handler.GetAccount(w, r)
+		}
+	}
+}
+
+// newPricingService собирает регион по умолчанию из PRICING_* и дополнительные регионы из файла.
+func newPricingService(cfg *config.PricingConfig) (*services.PricingService, error) {
+	defaultRegion := models.Region{
+		Code:           cfg.Region,
+		Currency:       cfg.Currency,
+		Timezone:       cfg.Timezone,
+		TaxRate:        cfg.TaxRate,
+		ReducedTaxRate: cfg.ReducedRate,
+		BaseFare:       cfg.BaseFare,
+		PerKm:          cfg.PerKm,
+		MinFare:        cfg.MinFare,
+	}
+	if len(cfg.VehicleMultipliers) > 0 {
+		defaultRegion.VehicleMultipliers = make(map[models.VehicleType]float64, len(cfg.VehicleMultipliers))
+		for vehicle, multiplier := range cfg.VehicleMultipliers {
+			defaultRegion.VehicleMultipliers[models.VehicleType(vehicle)] = multiplier
+		}
+	}
+
+	var regions []models.Region
+	if cfg.RegionsFile != "" {
+		loaded, err := services.LoadRegions(cfg.RegionsFile)
+		if err != nil {
+			return nil, err
+		}
+		regions = loaded
+	}
+
+	return services.NewRegionalPricingService(defaultRegion, regions)
+}
+
+// newOrderFlow собирает машину состояний заказа из ORDER_FLOW_FILE или из переходов по умолчанию.
+func newOrderFlow(cfg *config.OrderFlowConfig) (*services.OrderFlow, error) {
+	transitions := services.DefaultOrderTransitions()
+	if cfg.File != "" {
+		loaded, err := services.LoadOrderTransitions(cfg.File)
+		if err != nil {
+			return nil, err
+		}
+		transitions = loaded
+	}
+	return services.NewOrderFlow(transitions, cfg.MaxDeliveryAttempts)
+}
+
 // registerEventHandlers регистрирует обработчики событий Kafka
-func registerEventHandlers(consumer *kafka.Consumer, log *logger.Logger) {
+func registerEventHandlers(consumer *kafka.Consumer, performanceService *services.CourierPerformanceService, log *logger.Logger) {
 	// Пример обработчика событий - можно расширить по необходимости
 	consumer.RegisterHandler("order.created", func(ctx context.Context, event *models.Event) error {
 		log.WithField("event_id", event.ID).Info("Processing order created event")
@@ -331,17 +706,21 @@ func registerEventHandlers(consumer *kafka.Consumer, log *logger.Logger) {
 
 	consumer.RegisterHandler("order.status_changed", func(ctx context.Context, event *models.Event) error {
 		log.WithField("event_id", event.ID).Info("Processing order status changed event")
-		// Здесь можно добавить логику уведомлений, обновления статистики и т.д.
-		return nil
+		// Доставки и отмены учитываются в оценке работы курьера
+		return performanceService.HandleEvent(ctx, event)
 	})
+
+	// Назначения и снятия курьеров с заказов учитываются в доле принятых заказов
+	consumer.RegisterHandler(models.EventTypeCourierAssigned, performanceService.HandleEvent)
+	consumer.RegisterHandler(models.EventTypeCourierUnassigned, performanceService.HandleEvent)
 }
 
 // corsMiddleware и другие helper функции
 func corsMiddleware(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Device-ID, X-Actor")
 
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusOK)
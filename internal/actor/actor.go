@@ -0,0 +1,80 @@
+// Package actor описывает исполнителя действия (пользователь, курьер или компонент системы)
+// и передает его через context до транзакции, где его записывает аудит.
+package actor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Type — вид исполнителя.
+type Type string
+
+const (
+	TypeUser    Type = "user"
+	TypeCourier Type = "courier"
+	TypeSystem  Type = "system"
+)
+
+// maxIDLength ограничивает идентификатор так, чтобы "type:id" помещался в order_status_history.changed_by.
+const maxIDLength = 200
+
+// Actor — исполнитель действия. В журнале хранится строкой "type:id", например courier:<uuid> или system:auto_assign.
+type Actor struct {
+	Type Type   `json:"type"`
+	ID   string `json:"id"`
+}
+
+// System возвращает исполнителя-компонент системы.
+func System(component string) Actor {
+	return Actor{Type: TypeSystem, ID: component}
+}
+
+// Anonymous — исполнитель запроса API без заголовка X-Actor.
+var Anonymous = Actor{Type: TypeUser, ID: "anonymous"}
+
+func (a Actor) String() string {
+	return string(a.Type) + ":" + a.ID
+}
+
+// Parse разбирает строку "type:id". Для курьера id должен быть UUID.
+func Parse(value string) (Actor, error) {
+	kind, id, ok := strings.Cut(strings.TrimSpace(value), ":")
+	id = strings.TrimSpace(id)
+	if !ok || id == "" {
+		return Actor{}, fmt.Errorf("actor must be in form type:id")
+	}
+	if len(id) > maxIDLength {
+		return Actor{}, fmt.Errorf("actor id is too long")
+	}
+
+	a := Actor{Type: Type(strings.ToLower(kind)), ID: id}
+	switch a.Type {
+	case TypeUser, TypeSystem:
+	case TypeCourier:
+		if _, err := uuid.Parse(id); err != nil {
+			return Actor{}, fmt.Errorf("courier actor id must be a UUID")
+		}
+	default:
+		return Actor{}, fmt.Errorf("actor type must be user, courier or system")
+	}
+	return a, nil
+}
+
+type contextKey struct{}
+
+// WithContext возвращает контекст с исполнителем.
+func WithContext(ctx context.Context, a Actor) context.Context {
+	return context.WithValue(ctx, contextKey{}, a)
+}
+
+// FromContext возвращает исполнителя из контекста; без него действие считается выполненным системой.
+func FromContext(ctx context.Context) Actor {
+	if a, ok := ctx.Value(contextKey{}).(Actor); ok {
+		return a
+	}
+	return System("unknown")
+}
@@ -0,0 +1,30 @@
+package actor
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	a, err := Parse(" User:ops-42 ")
+	if err != nil || a.Type != TypeUser || a.ID != "ops-42" || a.String() != "user:ops-42" {
+		t.Fatalf("unexpected actor %v (%v)", a, err)
+	}
+
+	for _, value := range []string{"", "user", "user:", "robot:1", "courier:42", "user:" + strings.Repeat("x", maxIDLength+1)} {
+		if _, err := Parse(value); err == nil {
+			t.Errorf("expected error for %q", value)
+		}
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	if got := FromContext(context.Background()); got != System("unknown") {
+		t.Fatalf("expected system:unknown by default, got %v", got)
+	}
+	ctx := WithContext(context.Background(), System("auto_assign"))
+	if got := FromContext(ctx).String(); got != "system:auto_assign" {
+		t.Fatalf("unexpected actor %q", got)
+	}
+}
@@ -9,10 +9,11 @@ const (
 	KindNotFound   Kind = "not_found"
 	KindValidation Kind = "validation"
 	KindConflict   Kind = "conflict"
+	KindForbidden  Kind = "forbidden"
 )
 
 // Error is a typed error with a stable Kind and a human-readable message.
-// Msg should be safe to return to clients for Validation/NotFound/Conflict.
+// Msg should be safe to return to clients for Validation/NotFound/Conflict/Forbidden.
 type Error struct {
 	Kind Kind
 	Msg  string
@@ -46,6 +47,7 @@ func New(kind Kind, msg string, err error) error {
 func NotFound(msg string, err error) error   { return New(KindNotFound, msg, err) }
 func Validation(msg string, err error) error { return New(KindValidation, msg, err) }
 func Conflict(msg string, err error) error   { return New(KindConflict, msg, err) }
+func Forbidden(msg string, err error) error  { return New(KindForbidden, msg, err) }
 
 func Is(err error, kind Kind) bool {
 	var e *Error
@@ -4,6 +4,8 @@ import (
 	"os"
 	"strconv"
 	"strings"
+
+	"delivery-system/internal/money"
 )
 
 // Config представляет конфигурацию приложения
@@ -15,8 +17,17 @@ type Config struct {
 	Logger    LoggerConfig    `json:"logger"`
 	Geocoding GeocodingConfig `json:"geocoding"`
 	Pricing   PricingConfig   `json:"pricing"`
+	Payout    PayoutConfig    `json:"payout"`
 	Analytics AnalyticsConfig `json:"analytics"`
 	RateLimit RateLimitConfig `json:"rate_limit"`
+	Storage   StorageConfig   `json:"storage"`
+	Payments  PaymentsConfig  `json:"payments"`
+	Promo     PromoConfig     `json:"promo"`
+	Referral  ReferralConfig  `json:"referral"`
+	Loyalty   LoyaltyConfig   `json:"loyalty"`
+	OrderFlow OrderFlowConfig `json:"order_flow"`
+	Couriers  CourierConfig   `json:"couriers"`
+	Reviews   ReviewConfig    `json:"reviews"`
 }
 
 // ServerConfig представляет конфигурацию HTTP сервера
@@ -74,21 +85,38 @@ type GeocodingConfig struct {
 	TimeoutSeconds int    `json:"timeout_seconds"` // таймаут http-запроса
 }
 
-// PricingConfig хранит тарифы для доставки
+// PricingConfig хранит тариф региона по умолчанию и путь к файлу дополнительных регионов
 type PricingConfig struct {
-	BaseFare float64 `json:"base_fare"`
-	PerKm    float64 `json:"per_km"`
-	MinFare  float64 `json:"min_fare"`
+	BaseFare    float64 `json:"base_fare"`
+	PerKm       float64 `json:"per_km"`
+	MinFare     float64 `json:"min_fare"`
+	Currency    string  `json:"currency"`     // ISO 4217, валюта по умолчанию для всех сумм
+	Region      string  `json:"region"`       // код региона по умолчанию
+	Timezone    string  `json:"timezone"`     // IANA часовой пояс региона по умолчанию
+	TaxRate     float64 `json:"tax_rate"`     // ставка налога региона по умолчанию, доля от 0 до 1
+	ReducedRate float64 `json:"reduced_rate"` // пониженная ставка для товаров категории reduced
+	RegionsFile string  `json:"regions_file"` // JSON со списком регионов, пустой — только регион по умолчанию
+	// VehicleMultipliers — надбавка к тарифу региона по умолчанию для транспорта, например car=1.5
+	VehicleMultipliers map[string]float64 `json:"vehicle_multipliers"`
+}
+
+// PayoutConfig хранит правила расчета выплат курьерам
+type PayoutConfig struct {
+	PerDelivery float64 `json:"per_delivery"`
+	PerKm       float64 `json:"per_km"`
+	MinPayout   float64 `json:"min_payout"`
 }
 
 // AnalyticsConfig хранит настройки аналитики
 type AnalyticsConfig struct {
-	CacheTTLMinutes       int    `json:"cache_ttl_minutes"`
-	MaxRangeDays          int    `json:"max_range_days"`
-	DefaultGroupBy        string `json:"default_group_by"`
-	DefaultTopLimit       int    `json:"default_top_limit"`
-	DefaultCourierLimit   int    `json:"default_courier_limit"`
-	RequestTimeoutSeconds int    `json:"request_timeout_seconds"`
+	CacheTTLMinutes       int                `json:"cache_ttl_minutes"`
+	MaxRangeDays          int                `json:"max_range_days"`
+	DefaultGroupBy        string             `json:"default_group_by"`
+	DefaultTopLimit       int                `json:"default_top_limit"`
+	DefaultCourierLimit   int                `json:"default_courier_limit"`
+	RequestTimeoutSeconds int                `json:"request_timeout_seconds"`
+	ReportingCurrency     string             `json:"reporting_currency"` // валюта сводной выручки при пересчете
+	ConversionRates       map[string]float64 `json:"conversion_rates"`   // единиц ReportingCurrency за единицу валюты
 }
 
 // RateLimitConfig описывает настройки rate limiting
@@ -99,6 +127,64 @@ type RateLimitConfig struct {
 	KeyPrefix     string `json:"key_prefix"`
 }
 
+// StorageConfig описывает хранилище бинарных объектов (фото, подписи, документы)
+type StorageConfig struct {
+	Provider    string `json:"provider"`      // local
+	LocalDir    string `json:"local_dir"`     // корневой каталог для local
+	MaxUploadMB int    `json:"max_upload_mb"` // максимальный размер загружаемого файла
+}
+
+// PaymentsConfig описывает интеграцию с платежным провайдером
+type PaymentsConfig struct {
+	Provider                string `json:"provider"`                  // none | fake
+	WebhookSecret           string `json:"-"`                         // секрет для подписи вебхуков
+	WebhookToleranceSeconds int    `json:"webhook_tolerance_seconds"` // допустимое расхождение времени подписи
+	GateTransitions         bool   `json:"gate_transitions"`          // блокировать смену статуса без оплаты
+	RetryIntervalSeconds    int    `json:"retry_interval_seconds"`    // период повтора операций у провайдера, 0 — без повтора
+}
+
+// PromoConfig описывает правила сочетания промокодов в одном заказе
+type PromoConfig struct {
+	StackingOrder         string `json:"stacking_order"`          // fixed_first | percent_first
+	MaxCodesPerOrder      int    `json:"max_codes_per_order"`     // сколько кодов можно указать в заказе
+	ValidateRequests      int    `json:"validate_requests"`       // проверок промокода с одного IP за окно, 0 — без ограничения
+	ValidateWindowSeconds int    `json:"validate_window_seconds"` // окно ограничения проверок
+}
+
+// ReferralConfig описывает бонусы реферальной программы
+type ReferralConfig struct {
+	ReferrerReward float64 `json:"referrer_reward"` // бонус пригласившему, в валюте заказа
+	RefereeReward  float64 `json:"referee_reward"`  // бонус приглашенному, в валюте заказа
+	CodePrefix     string  `json:"code_prefix"`     // префикс персональных кодов
+}
+
+// LoyaltyConfig описывает начисление и списание баллов лояльности
+type LoyaltyConfig struct {
+	EarnRate    float64            `json:"earn_rate"`    // баллов за единицу оплаченной суммы, 0 — не начислять
+	EarnRates   map[string]float64 `json:"earn_rates"`   // ставки начисления по валютам
+	PointValue  float64            `json:"point_value"`  // стоимость балла в валюте заказа
+	PointValues map[string]float64 `json:"point_values"` // стоимость балла по валютам
+	TTLDays     int                `json:"ttl_days"`     // срок действия начисленных баллов
+}
+
+// OrderFlowConfig описывает машину состояний заказа
+type OrderFlowConfig struct {
+	File                string `json:"file"`                  // JSON со списком переходов, пустой — переходы по умолчанию
+	MaxDeliveryAttempts int    `json:"max_delivery_attempts"` // неудачных попыток вручения до обязательного возврата
+}
+
+// CourierConfig описывает сверку статусов курьеров
+type CourierConfig struct {
+	ReconcileIntervalSeconds int `json:"reconcile_interval_seconds"` // период сверки busy-курьеров без заказов, 0 — выключена
+	ReconcileGraceSeconds    int `json:"reconcile_grace_seconds"`    // сколько курьер должен пробыть busy без изменений
+}
+
+// ReviewConfig описывает правку и фильтрацию отзывов
+type ReviewConfig struct {
+	EditWindowHours int      `json:"edit_window_hours"` // сколько часов после создания клиент может править отзыв
+	BlockedWords    []string `json:"blocked_words"`     // дополнительные запрещенные слова к встроенному списку
+}
+
 // Load загружает конфигурацию из переменных окружения
 func Load() *Config {
 	return &Config{
@@ -143,9 +229,21 @@ func Load() *Config {
 			TimeoutSeconds: getEnvAsInt("GEOCODER_TIMEOUT_SECONDS", 5),
 		},
 		Pricing: PricingConfig{
-			BaseFare: getEnvAsFloat("PRICING_BASE_FARE", 100.0),
-			PerKm:    getEnvAsFloat("PRICING_PER_KM", 20.0),
-			MinFare:  getEnvAsFloat("PRICING_MIN_FARE", 150.0),
+			BaseFare:           getEnvAsFloat("PRICING_BASE_FARE", 100.0),
+			PerKm:              getEnvAsFloat("PRICING_PER_KM", 20.0),
+			MinFare:            getEnvAsFloat("PRICING_MIN_FARE", 150.0),
+			Currency:           strings.ToUpper(getEnv("PRICING_CURRENCY", "RUB")),
+			Region:             getEnv("PRICING_REGION", "default"),
+			Timezone:           getEnv("PRICING_TIMEZONE", "UTC"),
+			TaxRate:            getEnvAsFloat("PRICING_TAX_RATE", 0),
+			ReducedRate:        getEnvAsFloat("PRICING_REDUCED_TAX_RATE", 0),
+			RegionsFile:        getEnv("PRICING_REGIONS_FILE", ""),
+			VehicleMultipliers: getEnvAsMultipliers("PRICING_VEHICLE_MULTIPLIERS"),
+		},
+		Payout: PayoutConfig{
+			PerDelivery: getEnvAsFloat("PAYOUT_PER_DELIVERY", 60.0),
+			PerKm:       getEnvAsFloat("PAYOUT_PER_KM", 12.0),
+			MinPayout:   getEnvAsFloat("PAYOUT_MIN", 90.0),
 		},
 		Analytics: AnalyticsConfig{
 			CacheTTLMinutes:       getEnvAsInt("ANALYTICS_CACHE_TTL_MINUTES", 10),
@@ -154,6 +252,8 @@ func Load() *Config {
 			DefaultTopLimit:       getEnvAsInt("ANALYTICS_DEFAULT_TOP_LIMIT", 5),
 			DefaultCourierLimit:   getEnvAsInt("ANALYTICS_DEFAULT_COURIER_LIMIT", 50),
 			RequestTimeoutSeconds: getEnvAsInt("ANALYTICS_REQUEST_TIMEOUT_SECONDS", 5),
+			ReportingCurrency:     strings.ToUpper(getEnv("ANALYTICS_REPORTING_CURRENCY", "")),
+			ConversionRates:       getEnvAsRates("ANALYTICS_CONVERSION_RATES"),
 		},
 		RateLimit: RateLimitConfig{
 			Enabled:       getEnvAsBool("RATE_LIMIT_ENABLED", false),
@@ -161,6 +261,48 @@ func Load() *Config {
 			WindowSeconds: getEnvAsInt("RATE_LIMIT_WINDOW_SECONDS", 60),
 			KeyPrefix:     getEnv("RATE_LIMIT_KEY_PREFIX", "ratelimit"),
 		},
+		Storage: StorageConfig{
+			Provider:    getEnv("STORAGE_PROVIDER", "local"),
+			LocalDir:    getEnv("STORAGE_LOCAL_DIR", "./data/blobs"),
+			MaxUploadMB: getEnvAsInt("STORAGE_MAX_UPLOAD_MB", 10),
+		},
+		Payments: PaymentsConfig{
+			Provider:                getEnv("PAYMENTS_PROVIDER", "none"),
+			WebhookSecret:           getEnv("PAYMENTS_WEBHOOK_SECRET", ""),
+			WebhookToleranceSeconds: getEnvAsInt("PAYMENTS_WEBHOOK_TOLERANCE_SECONDS", 300),
+			GateTransitions:         getEnvAsBool("PAYMENTS_GATE_TRANSITIONS", false),
+			RetryIntervalSeconds:    getEnvAsInt("PAYMENTS_RETRY_INTERVAL_SECONDS", 60),
+		},
+		Promo: PromoConfig{
+			StackingOrder:         getEnv("PROMO_STACKING_ORDER", "fixed_first"),
+			MaxCodesPerOrder:      getEnvAsInt("PROMO_MAX_CODES_PER_ORDER", 3),
+			ValidateRequests:      getEnvAsInt("PROMO_VALIDATE_REQUESTS", 10),
+			ValidateWindowSeconds: getEnvAsInt("PROMO_VALIDATE_WINDOW_SECONDS", 60),
+		},
+		Referral: ReferralConfig{
+			ReferrerReward: getEnvAsFloat("REFERRAL_REFERRER_REWARD", 300.0),
+			RefereeReward:  getEnvAsFloat("REFERRAL_REFEREE_REWARD", 200.0),
+			CodePrefix:     getEnv("REFERRAL_CODE_PREFIX", "REF-"),
+		},
+		Loyalty: LoyaltyConfig{
+			EarnRate:    getEnvAsFloat("LOYALTY_EARN_RATE", 0.05),
+			EarnRates:   getEnvAsRates("LOYALTY_EARN_RATES"),
+			PointValue:  getEnvAsFloat("LOYALTY_POINT_VALUE", 1.0),
+			PointValues: getEnvAsRates("LOYALTY_POINT_VALUES"),
+			TTLDays:     getEnvAsInt("LOYALTY_POINTS_TTL_DAYS", 365),
+		},
+		OrderFlow: OrderFlowConfig{
+			File:                getEnv("ORDER_FLOW_FILE", ""),
+			MaxDeliveryAttempts: getEnvAsInt("ORDER_MAX_DELIVERY_ATTEMPTS", 2),
+		},
+		Couriers: CourierConfig{
+			ReconcileIntervalSeconds: getEnvAsInt("COURIER_RECONCILE_INTERVAL_SECONDS", 60),
+			ReconcileGraceSeconds:    getEnvAsInt("COURIER_RECONCILE_GRACE_SECONDS", 300),
+		},
+		Reviews: ReviewConfig{
+			EditWindowHours: getEnvAsInt("REVIEW_EDIT_WINDOW_HOURS", 48),
+			BlockedWords:    getEnvAsList("REVIEW_BLOCKED_WORDS"),
+		},
 	}
 }
 
@@ -201,3 +343,59 @@ func getEnvAsBool(key string, defaultValue bool) bool {
 	}
 	return defaultValue
 }
+
+// getEnvAsList разбирает список через запятую; пустые элементы пропускаются
+func getEnvAsList(key string) []string {
+	var items []string
+	for _, item := range strings.Split(getEnv(key, ""), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// getEnvAsRates разбирает курсы вида "EUR=98.5,KZT=0.19"; некорректные пары пропускаются
+func getEnvAsRates(key string) map[string]float64 {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return nil
+	}
+
+	rates := make(map[string]float64)
+	for _, pair := range strings.Split(valueStr, ",") {
+		code, rateStr, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+		code = strings.ToUpper(strings.TrimSpace(code))
+		rate, err := strconv.ParseFloat(strings.TrimSpace(rateStr), 64)
+		if err != nil || rate <= 0 || !money.IsCurrencyCode(code) {
+			continue
+		}
+		rates[code] = rate
+	}
+	return rates
+}
+
+// getEnvAsMultipliers разбирает коэффициенты вида "scooter=1.2,car=1.5"; некорректные пары пропускаются
+func getEnvAsMultipliers(key string) map[string]float64 {
+	valueStr := getEnv(key, "")
+	if valueStr == "" {
+		return nil
+	}
+
+	multipliers := make(map[string]float64)
+	for _, pair := range strings.Split(valueStr, ",") {
+		name, valueStr, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok {
+			continue
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(valueStr), 64)
+		if err != nil || value <= 0 {
+			continue
+		}
+		multipliers[strings.ToLower(strings.TrimSpace(name))] = value
+	}
+	return multipliers
+}
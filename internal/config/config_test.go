@@ -40,3 +40,39 @@ func TestLoadDefaults(t *testing.T) {
 		t.Fatalf("expected analytics defaults set")
 	}
 }
+
+func TestGetEnvAsRates(t *testing.T) {
+	os.Setenv("TEST_RATES", "eur=98.5, KZT=0.19,bad,USD=-1,XX=2")
+	defer os.Unsetenv("TEST_RATES")
+
+	rates := getEnvAsRates("TEST_RATES")
+	if len(rates) != 2 || rates["EUR"] != 98.5 || rates["KZT"] != 0.19 {
+		t.Fatalf("unexpected rates: %v", rates)
+	}
+	if getEnvAsRates("TEST_RATES_MISSING") != nil {
+		t.Fatalf("expected nil rates when variable is not set")
+	}
+}
+
+func TestGetEnvAsMultipliers(t *testing.T) {
+	os.Setenv("TEST_MULTIPLIERS", "Scooter=1.2, car=1.5,bad,bike=0")
+	defer os.Unsetenv("TEST_MULTIPLIERS")
+
+	multipliers := getEnvAsMultipliers("TEST_MULTIPLIERS")
+	if len(multipliers) != 2 || multipliers["scooter"] != 1.2 || multipliers["car"] != 1.5 {
+		t.Fatalf("unexpected multipliers: %v", multipliers)
+	}
+}
+
+func TestGetEnvAsList(t *testing.T) {
+	os.Setenv("TEST_LIST", " spam, ,scam ,")
+	defer os.Unsetenv("TEST_LIST")
+
+	items := getEnvAsList("TEST_LIST")
+	if len(items) != 2 || items[0] != "spam" || items[1] != "scam" {
+		t.Fatalf("unexpected items: %v", items)
+	}
+	if getEnvAsList("TEST_LIST_MISSING") != nil {
+		t.Fatalf("expected nil list when variable is not set")
+	}
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"delivery-system/internal/actor"
+)
+
+// ActorHeader — заголовок с исполнителем запроса в формате type:id (user:<id>, courier:<uuid>, system:<компонент>).
+const ActorHeader = "X-Actor"
+
+// ActorMiddleware кладет исполнителя из заголовка X-Actor в контекст запроса.
+// Без заголовка исполнителем считается anonymous-пользователь. Заголовок не аутентифицируется:
+// его должен подставлять шлюз перед сервисом, поэтому роли в машине состояний — рекомендательные.
+func ActorMiddleware(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		a := actor.Anonymous
+		if value := strings.TrimSpace(r.Header.Get(ActorHeader)); value != "" {
+			parsed, err := actor.Parse(value)
+			if err != nil {
+				writeErrorResponse(w, http.StatusBadRequest, "Invalid X-Actor header: "+err.Error())
+				return
+			}
+			a = parsed
+		}
+
+		next(w, r.WithContext(actor.WithContext(r.Context(), a)))
+	}
+}
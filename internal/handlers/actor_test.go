@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"delivery-system/internal/actor"
+)
+
+func TestActorMiddleware(t *testing.T) {
+	var got actor.Actor
+	handler := ActorMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		got = actor.FromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodPatch, "/api/orders/x/status", nil)
+	rr := httptest.NewRecorder()
+	handler(rr, req)
+	if rr.Code != http.StatusOK || got != actor.Anonymous {
+		t.Fatalf("expected anonymous actor, got %d %v", rr.Code, got)
+	}
+
+	req = httptest.NewRequest(http.MethodPatch, "/api/orders/x/status", nil)
+	req.Header.Set(ActorHeader, "courier:7b0c4c3e-4a55-4a3b-9d6a-1c1f3a3b2a10")
+	rr = httptest.NewRecorder()
+	handler(rr, req)
+	if rr.Code != http.StatusOK || got.String() != "courier:7b0c4c3e-4a55-4a3b-9d6a-1c1f3a3b2a10" {
+		t.Fatalf("expected courier actor, got %d %v", rr.Code, got)
+	}
+
+	req = httptest.NewRequest(http.MethodPatch, "/api/orders/x/status", nil)
+	req.Header.Set(ActorHeader, "courier:not-a-uuid")
+	rr = httptest.NewRecorder()
+	handler(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid actor, got %d", rr.Code)
+	}
+}
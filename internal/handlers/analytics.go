@@ -12,6 +12,7 @@ import (
 	"delivery-system/internal/config"
 	"delivery-system/internal/logger"
 	"delivery-system/internal/models"
+	"delivery-system/internal/money"
 )
 
 const (
@@ -53,8 +54,7 @@ func (h *AnalyticsHandler) GetKPIs(w http.ResponseWriter, r *http.Request) {
 
 	metrics, err := h.service.GetKPIs(ctx, filter)
 	if err != nil {
-		h.log.WithError(err).Error("Failed to load KPI metrics")
-		writeErrorResponse(w, http.StatusInternalServerError, "Failed to load analytics")
+		writeServiceError(w, h.log, err, "Failed to load analytics")
 		return
 	}
 
@@ -86,8 +86,7 @@ func (h *AnalyticsHandler) GetCourierAnalytics(w http.ResponseWriter, r *http.Re
 
 	metrics, err := h.service.GetCourierAnalytics(ctx, filter)
 	if err != nil {
-		h.log.WithError(err).Error("Failed to load courier analytics")
-		writeErrorResponse(w, http.StatusInternalServerError, "Failed to load analytics")
+		writeServiceError(w, h.log, err, "Failed to load analytics")
 		return
 	}
 
@@ -101,6 +100,70 @@ func (h *AnalyticsHandler) GetCourierAnalytics(w http.ResponseWriter, r *http.Re
 	writeJSONResponse(w, http.StatusOK, metrics)
 }
 
+// GetMerchantAnalytics возвращает отзывы по заведениям (оценка и теги) с опциональным CSV.
+func (h *AnalyticsHandler) GetMerchantAnalytics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	filter, format, err := parseAnalyticsFilter(r, h.cfg)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), analyticsTimeout(h.cfg))
+	defer cancel()
+
+	metrics, err := h.service.GetMerchantAnalytics(ctx, filter)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to load analytics")
+		return
+	}
+
+	if format == "csv" {
+		if err := writeMerchantCSV(w, metrics); err != nil {
+			h.log.WithError(err).Warn("Failed to stream merchant CSV")
+		}
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, metrics)
+}
+
+// GetStageTimings возвращает время по этапам жизненного цикла заказа с опциональным CSV.
+func (h *AnalyticsHandler) GetStageTimings(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	filter, format, err := parseAnalyticsFilter(r, h.cfg)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), analyticsTimeout(h.cfg))
+	defer cancel()
+
+	timings, err := h.service.GetStageTimings(ctx, filter)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to load analytics")
+		return
+	}
+
+	if format == "csv" {
+		if err := writeStageCSV(w, timings); err != nil {
+			h.log.WithError(err).Warn("Failed to stream stage timings CSV")
+		}
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, timings)
+}
+
 func parseAnalyticsFilter(r *http.Request, cfg *config.AnalyticsConfig) (*models.AnalyticsFilter, string, error) {
 	query := r.URL.Query()
 	now := time.Now().UTC()
@@ -175,12 +238,19 @@ func parseAnalyticsFilter(r *http.Request, cfg *config.AnalyticsConfig) (*models
 		return nil, "", fmt.Errorf("format must be json or csv")
 	}
 
+	currency := strings.ToUpper(strings.TrimSpace(query.Get("currency")))
+	if currency != "" && !money.IsCurrencyCode(currency) {
+		return nil, "", fmt.Errorf("currency must be a 3-letter ISO 4217 code")
+	}
+
 	filter := &models.AnalyticsFilter{
 		From:          from,
 		To:            to,
 		GroupBy:       groupBy,
 		TopItemsLimit: topLimit,
 		CourierLimit:  courierLimit,
+		Region:        strings.TrimSpace(query.Get("region")),
+		Currency:      currency,
 	}
 
 	return filter, format, nil
@@ -205,18 +275,18 @@ func writeKPICSV(w http.ResponseWriter, metrics *models.KPIMetrics) error {
 	w.WriteHeader(http.StatusOK)
 
 	writer := csv.NewWriter(w)
-	_ = writer.Write([]string{"section", "period", "revenue", "orders_count", "avg_delivery_time_minutes"})
+	_ = writer.Write([]string{"section", "period", "revenue", "currency", "orders_count", "avg_delivery_time_minutes"})
 	rangeLabel := fmt.Sprintf("%s..%s", metrics.From.Format("2006-01-02"), metrics.To.Format("2006-01-02"))
-	_ = writer.Write([]string{"summary", rangeLabel, fmt.Sprintf("%.2f", metrics.Revenue), strconv.Itoa(metrics.OrdersCount), fmt.Sprintf("%.2f", metrics.AvgDeliveryTimeMinutes)})
+	_ = writer.Write([]string{"summary", rangeLabel, metrics.Revenue.String(), metrics.Currency, strconv.Itoa(metrics.OrdersCount), fmt.Sprintf("%.2f", metrics.AvgDeliveryTimeMinutes)})
 
 	for _, period := range metrics.Periods {
-		_ = writer.Write([]string{"period", period.Period, fmt.Sprintf("%.2f", period.Revenue), strconv.Itoa(period.OrdersCount), fmt.Sprintf("%.2f", period.AvgDeliveryTimeMinutes)})
+		_ = writer.Write([]string{"period", period.Period, period.Revenue.String(), metrics.Currency, strconv.Itoa(period.OrdersCount), fmt.Sprintf("%.2f", period.AvgDeliveryTimeMinutes)})
 	}
 
 	_ = writer.Write([]string{})
-	_ = writer.Write([]string{"section", "item_name", "quantity", "revenue"})
+	_ = writer.Write([]string{"section", "item_name", "quantity", "revenue", "currency"})
 	for _, item := range metrics.TopItems {
-		_ = writer.Write([]string{"top_item", item.Name, strconv.Itoa(item.Quantity), fmt.Sprintf("%.2f", item.Revenue)})
+		_ = writer.Write([]string{"top_item", item.Name, strconv.Itoa(item.Quantity), item.Revenue.String(), metrics.Currency})
 	}
 
 	writer.Flush()
@@ -229,16 +299,82 @@ func writeCourierCSV(w http.ResponseWriter, metrics []*models.CourierAnalytics)
 	w.WriteHeader(http.StatusOK)
 
 	writer := csv.NewWriter(w)
-	_ = writer.Write([]string{"courier_id", "courier_name", "deliveries", "revenue", "rating", "avg_delivery_time_minutes"})
+	header := []string{"courier_id", "courier_name", "deliveries", "revenue", "currency", "rating", "avg_delivery_time_minutes"}
+	_ = writer.Write(append(header, reviewTagColumns()...))
 
 	for _, row := range metrics {
-		_ = writer.Write([]string{
+		record := []string{
 			row.CourierID.String(),
 			row.CourierName,
 			strconv.Itoa(row.Deliveries),
-			fmt.Sprintf("%.2f", row.Revenue),
+			row.Revenue.String(),
+			row.Currency,
 			fmt.Sprintf("%.2f", row.Rating),
 			fmt.Sprintf("%.2f", row.AvgDeliveryTimeMinutes),
+		}
+		_ = writer.Write(append(record, reviewTagValues(row.Tags)...))
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+func writeMerchantCSV(w http.ResponseWriter, metrics []*models.MerchantAnalytics) error {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", "attachment; filename=merchants.csv")
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	header := []string{"merchant_name", "reviews", "rated_reviews", "rating"}
+	_ = writer.Write(append(header, reviewTagColumns()...))
+
+	for _, row := range metrics {
+		record := []string{
+			row.MerchantName,
+			strconv.Itoa(row.Reviews),
+			strconv.Itoa(row.RatedReviews),
+			fmt.Sprintf("%.2f", row.Rating),
+		}
+		_ = writer.Write(append(record, reviewTagValues(row.Tags)...))
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// reviewTagColumns — колонки частот тегов: по одной на каждый тег, в порядке models.ReviewTags.
+func reviewTagColumns() []string {
+	columns := make([]string, 0, len(models.ReviewTags))
+	for _, tag := range models.ReviewTags {
+		columns = append(columns, "tag_"+string(tag))
+	}
+	return columns
+}
+
+func reviewTagValues(tags map[models.ReviewTag]int) []string {
+	values := make([]string, 0, len(models.ReviewTags))
+	for _, tag := range models.ReviewTags {
+		values = append(values, strconv.Itoa(tags[tag]))
+	}
+	return values
+}
+
+func writeStageCSV(w http.ResponseWriter, timings *models.StageTimings) error {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", "attachment; filename=stages.csv")
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	_ = writer.Write([]string{"status", "transitions", "avg_minutes", "p50_minutes", "p90_minutes", "max_minutes"})
+
+	for _, stage := range timings.Stages {
+		_ = writer.Write([]string{
+			string(stage.Status),
+			strconv.Itoa(stage.Transitions),
+			fmt.Sprintf("%.2f", stage.AvgMinutes),
+			fmt.Sprintf("%.2f", stage.P50Minutes),
+			fmt.Sprintf("%.2f", stage.P90Minutes),
+			fmt.Sprintf("%.2f", stage.MaxMinutes),
 		})
 	}
 
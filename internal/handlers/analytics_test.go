@@ -10,17 +10,21 @@ import (
 	"testing"
 	"time"
 
+	"delivery-system/internal/apperror"
 	"delivery-system/internal/config"
 	"delivery-system/internal/logger"
 	"delivery-system/internal/models"
+	"delivery-system/internal/money"
 
 	"github.com/google/uuid"
 )
 
 type stubAnalyticsService struct {
-	kpi      *models.KPIMetrics
-	couriers []*models.CourierAnalytics
-	err      error
+	kpi       *models.KPIMetrics
+	couriers  []*models.CourierAnalytics
+	merchants []*models.MerchantAnalytics
+	stages    *models.StageTimings
+	err       error
 }
 
 func (s *stubAnalyticsService) GetKPIs(ctx context.Context, filter *models.AnalyticsFilter) (*models.KPIMetrics, error) {
@@ -31,6 +35,14 @@ func (s *stubAnalyticsService) GetCourierAnalytics(ctx context.Context, filter *
 	return s.couriers, s.err
 }
 
+func (s *stubAnalyticsService) GetMerchantAnalytics(ctx context.Context, filter *models.AnalyticsFilter) ([]*models.MerchantAnalytics, error) {
+	return s.merchants, s.err
+}
+
+func (s *stubAnalyticsService) GetStageTimings(ctx context.Context, filter *models.AnalyticsFilter) (*models.StageTimings, error) {
+	return s.stages, s.err
+}
+
 func TestAnalyticsHandler_GetKPIs_JSON(t *testing.T) {
 	cfg := &config.AnalyticsConfig{
 		MaxRangeDays: 30,
@@ -39,10 +51,10 @@ func TestAnalyticsHandler_GetKPIs_JSON(t *testing.T) {
 	kpi := &models.KPIMetrics{
 		From:        time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
 		To:          time.Date(2024, 1, 2, 23, 59, 59, 0, time.UTC),
-		Revenue:     1000,
+		Revenue:     money.New(100000, "RUB"),
 		OrdersCount: 5,
 		TopItems: []models.TopItem{
-			{Name: "Pizza", Quantity: 3, Revenue: 600},
+			{Name: "Pizza", Quantity: 3, Revenue: money.New(60000, "RUB")},
 		},
 	}
 	h := NewAnalyticsHandler(&stubAnalyticsService{kpi: kpi}, log, cfg)
@@ -61,7 +73,7 @@ func TestAnalyticsHandler_GetKPIs_JSON(t *testing.T) {
 		t.Fatalf("failed to decode response: %v", err)
 	}
 
-	if resp.OrdersCount != kpi.OrdersCount || resp.Revenue != kpi.Revenue {
+	if resp.OrdersCount != kpi.OrdersCount || resp.Revenue.Amount != kpi.Revenue.Amount {
 		t.Fatalf("unexpected KPI response: %+v", resp)
 	}
 }
@@ -76,9 +88,10 @@ func TestAnalyticsHandler_GetCourierAnalytics_CSV(t *testing.T) {
 			CourierID:              testUUID("11111111-1111-1111-1111-111111111111"),
 			CourierName:            "John",
 			Deliveries:             10,
-			Revenue:                500,
+			Revenue:                money.New(50000, "RUB"),
 			Rating:                 4.7,
 			AvgDeliveryTimeMinutes: 35.5,
+			Tags:                   map[models.ReviewTag]int{models.ReviewTagLate: 2},
 		},
 	}
 	h := NewAnalyticsHandler(&stubAnalyticsService{couriers: couriers}, log, cfg)
@@ -99,6 +112,34 @@ func TestAnalyticsHandler_GetCourierAnalytics_CSV(t *testing.T) {
 	if body := rr.Body.String(); !strings.Contains(body, "John") || !strings.Contains(body, "courier_name") {
 		t.Fatalf("unexpected CSV body: %s", body)
 	}
+
+	if body := rr.Body.String(); !strings.Contains(body, "tag_late,tag_cold_food,tag_rude,tag_great_service") || !strings.Contains(body, "35.50,2,0,0,0") {
+		t.Fatalf("expected tag columns in CSV body: %s", body)
+	}
+}
+
+func TestAnalyticsHandler_GetMerchantAnalytics(t *testing.T) {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	merchants := []*models.MerchantAnalytics{
+		{MerchantName: "Pizzeria", Reviews: 4, RatedReviews: 3, Rating: 3.67, Tags: map[models.ReviewTag]int{models.ReviewTagColdFood: 2}},
+	}
+	h := NewAnalyticsHandler(&stubAnalyticsService{merchants: merchants}, log, &config.AnalyticsConfig{MaxRangeDays: 30})
+
+	rr := httptest.NewRecorder()
+	h.GetMerchantAnalytics(rr, httptest.NewRequest(http.MethodGet, "/api/analytics/merchants?from=2024-01-01&to=2024-01-02", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rr.Code)
+	}
+	var got []models.MerchantAnalytics
+	if err := json.NewDecoder(rr.Body).Decode(&got); err != nil || len(got) != 1 || got[0].Tags[models.ReviewTagColdFood] != 2 {
+		t.Fatalf("unexpected JSON body: %+v (%v)", got, err)
+	}
+
+	rr = httptest.NewRecorder()
+	h.GetMerchantAnalytics(rr, httptest.NewRequest(http.MethodGet, "/api/analytics/merchants?from=2024-01-01&to=2024-01-02&format=csv", nil))
+	if body := rr.Body.String(); !strings.Contains(body, "merchant_name,reviews,rated_reviews,rating,tag_late") || !strings.Contains(body, "Pizzeria,4,3,3.67,0,2,0,0") {
+		t.Fatalf("unexpected CSV body: %s", body)
+	}
 }
 
 func TestAnalyticsHandler_MaxRange_TooWide(t *testing.T) {
@@ -208,6 +249,34 @@ func TestAnalyticsHandler_ServiceError(t *testing.T) {
 	}
 }
 
+func TestAnalyticsHandler_MixedCurrenciesIsBadRequest(t *testing.T) {
+	err := apperror.Validation("orders span several currencies (EUR, RUB) and no conversion rate to RUB is configured for EUR; filter by currency or region", nil)
+	h := NewAnalyticsHandler(&stubAnalyticsService{err: err}, logger.New(&config.LoggerConfig{Level: "error", Format: "json"}), &config.AnalyticsConfig{MaxRangeDays: 30})
+	req := httptest.NewRequest(http.MethodGet, "/api/analytics/kpi?from=2024-01-01&to=2024-01-02", nil)
+	rr := httptest.NewRecorder()
+
+	h.GetKPIs(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+}
+
+func TestParseAnalyticsFilter_RegionAndCurrency(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/api/analytics/kpi?from=2024-01-01&to=2024-01-02&region=de-berlin&currency=eur", nil)
+	filter, _, err := parseAnalyticsFilter(req, &config.AnalyticsConfig{MaxRangeDays: 30})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if filter.Region != "de-berlin" || filter.Currency != "EUR" {
+		t.Fatalf("unexpected filter: %+v", filter)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/analytics/kpi?currency=euro", nil)
+	if _, _, err := parseAnalyticsFilter(req, &config.AnalyticsConfig{MaxRangeDays: 30}); err == nil {
+		t.Fatalf("expected error for invalid currency")
+	}
+}
+
 func TestAnalyticsHandler_CourierServiceError(t *testing.T) {
 	h := NewAnalyticsHandler(&stubAnalyticsService{err: fmt.Errorf("service error")}, logger.New(&config.LoggerConfig{Level: "error", Format: "json"}), &config.AnalyticsConfig{MaxRangeDays: 30})
 	req := httptest.NewRequest(http.MethodGet, "/api/analytics/couriers?from=2024-01-01&to=2024-01-02", nil)
@@ -224,12 +293,12 @@ func TestAnalyticsHandler_GetKPIs_CSV(t *testing.T) {
 	kpi := &models.KPIMetrics{
 		From:        time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
 		To:          time.Date(2024, 1, 2, 23, 0, 0, 0, time.UTC),
-		Revenue:     100,
+		Revenue:     money.New(10000, "RUB"),
 		OrdersCount: 2,
 		Periods: []models.KPIPeriod{
-			{Period: "2024-01-01", Revenue: 50, OrdersCount: 1, AvgDeliveryTimeMinutes: 10},
+			{Period: "2024-01-01", Revenue: money.New(5000, "RUB"), OrdersCount: 1, AvgDeliveryTimeMinutes: 10},
 		},
-		TopItems: []models.TopItem{{Name: "Item", Quantity: 1, Revenue: 50}},
+		TopItems: []models.TopItem{{Name: "Item", Quantity: 1, Revenue: money.New(5000, "RUB")}},
 	}
 	h := NewAnalyticsHandler(&stubAnalyticsService{kpi: kpi}, log, &config.AnalyticsConfig{MaxRangeDays: 30})
 	req := httptest.NewRequest(http.MethodGet, "/api/analytics/kpi?from=2024-01-01&to=2024-01-02&format=csv", nil)
@@ -270,3 +339,26 @@ func testUUID(val string) uuid.UUID {
 	id, _ := uuid.Parse(val)
 	return id
 }
+
+func TestAnalyticsHandler_GetStageTimings(t *testing.T) {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	stages := &models.StageTimings{Stages: []models.StageTiming{
+		{Status: models.OrderStatusCreated, Transitions: 10, AvgMinutes: 2.5, P50Minutes: 1.5, P90Minutes: 6, MaxMinutes: 9},
+		{Status: models.OrderStatusInDelivery, Transitions: 8, AvgMinutes: 24.5, P50Minutes: 22, P90Minutes: 35, MaxMinutes: 41},
+	}}
+	h := NewAnalyticsHandler(&stubAnalyticsService{stages: stages}, log, &config.AnalyticsConfig{MaxRangeDays: 30})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/analytics/stages?from=2024-01-01&to=2024-01-02", nil)
+	rr := httptest.NewRecorder()
+	h.GetStageTimings(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"p90_minutes":35`) {
+		t.Fatalf("unexpected response %d: %s", rr.Code, rr.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/analytics/stages?from=2024-01-01&to=2024-01-02&format=csv", nil)
+	rr = httptest.NewRecorder()
+	h.GetStageTimings(rr, req)
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "in_delivery,8,24.50,22.00,35.00,41.00") {
+		t.Fatalf("unexpected CSV %d: %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// CampaignHandler обрабатывает кампании и массовый выпуск промокодов.
+type CampaignHandler struct {
+	campaignService CampaignService
+	log             *logger.Logger
+}
+
+// NewCampaignHandler создает обработчик кампаний.
+func NewCampaignHandler(campaignService CampaignService, log *logger.Logger) *CampaignHandler {
+	return &CampaignHandler{
+		campaignService: campaignService,
+		log:             log,
+	}
+}
+
+// CreateCampaign создает кампанию с шаблоном скидки.
+func (h *CampaignHandler) CreateCampaign(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.CreateCampaignRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	campaign, err := h.campaignService.CreateCampaign(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to create campaign")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusCreated, campaign)
+}
+
+// ListCampaigns возвращает список кампаний.
+func (h *CampaignHandler) ListCampaigns(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	page, err := parsePageRequest(w, r, 200)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	campaigns, err := h.campaignService.ListCampaigns(r.Context(), page)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to list campaigns")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, campaigns)
+}
+
+// GetCampaign возвращает кампанию.
+func (h *CampaignHandler) GetCampaign(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	campaignID, err := extractUUIDFromPath(r.URL.Path, "/api/campaigns/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid campaign ID")
+		return
+	}
+
+	campaign, err := h.campaignService.GetCampaign(r.Context(), campaignID)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to get campaign")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, campaign)
+}
+
+// GenerateCodes выпускает пачку случайных кодов кампании.
+func (h *CampaignHandler) GenerateCodes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	campaignID, err := extractUUIDFromPath(r.URL.Path, "/api/campaigns/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid campaign ID")
+		return
+	}
+
+	var req models.GenerateCodesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	result, err := h.campaignService.GenerateCodes(r.Context(), campaignID, &req)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to generate promo codes")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusCreated, result)
+}
+
+// ListCodes возвращает коды кампании в JSON или CSV (?format=csv).
+func (h *CampaignHandler) ListCodes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	campaignID, err := extractUUIDFromPath(r.URL.Path, "/api/campaigns/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid campaign ID")
+		return
+	}
+
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format != "" && format != "json" && format != "csv" {
+		writeErrorResponse(w, http.StatusBadRequest, "format must be json or csv")
+		return
+	}
+
+	codes, err := h.campaignService.ListCampaignCodes(r.Context(), campaignID)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to list campaign codes")
+		return
+	}
+
+	if format == "csv" {
+		if err := writeCampaignCodesCSV(w, campaignID, codes); err != nil {
+			h.log.WithError(err).Warn("Failed to stream campaign codes CSV")
+		}
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, codes)
+}
+
+// DeactivateCampaign отключает кампанию и все ее коды.
+func (h *CampaignHandler) DeactivateCampaign(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	campaignID, err := extractUUIDFromPath(r.URL.Path, "/api/campaigns/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid campaign ID")
+		return
+	}
+
+	campaign, err := h.campaignService.DeactivateCampaign(r.Context(), campaignID)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to deactivate campaign")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, campaign)
+}
+
+// GetStats возвращает статистику использования кодов кампании.
+func (h *CampaignHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	campaignID, err := extractUUIDFromPath(r.URL.Path, "/api/campaigns/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid campaign ID")
+		return
+	}
+
+	stats, err := h.campaignService.GetCampaignStats(r.Context(), campaignID)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to get campaign stats")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, stats)
+}
+
+func writeCampaignCodesCSV(w http.ResponseWriter, campaignID uuid.UUID, codes []*models.PromoCode) error {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=campaign_%s_codes.csv", campaignID))
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	_ = writer.Write([]string{"code", "active", "max_uses", "used_count", "expires_at"})
+
+	for _, code := range codes {
+		expiresAt := ""
+		if code.ExpiresAt != nil {
+			expiresAt = code.ExpiresAt.UTC().Format(time.RFC3339)
+		}
+		_ = writer.Write([]string{
+			code.Code,
+			strconv.FormatBool(code.Active),
+			strconv.Itoa(code.MaxUses),
+			strconv.Itoa(code.UsedCount),
+			expiresAt,
+		})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
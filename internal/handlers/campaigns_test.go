@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/config"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+	"delivery-system/internal/pagination"
+
+	"github.com/google/uuid"
+)
+
+type stubCampaignService struct {
+	codes  []*models.PromoCode
+	err    error
+	gotReq *models.GenerateCodesRequest
+}
+
+func (s *stubCampaignService) CreateCampaign(ctx context.Context, req *models.CreateCampaignRequest) (*models.Campaign, error) {
+	return &models.Campaign{ID: uuid.New(), Name: req.Name, Active: true}, s.err
+}
+func (s *stubCampaignService) GetCampaign(ctx context.Context, campaignID uuid.UUID) (*models.Campaign, error) {
+	return nil, apperror.NotFound("campaign not found", nil)
+}
+func (s *stubCampaignService) ListCampaigns(ctx context.Context, page pagination.Request) (*pagination.Page[*models.Campaign], error) {
+	return &pagination.Page[*models.Campaign]{Items: []*models.Campaign{}}, nil
+}
+func (s *stubCampaignService) GenerateCodes(ctx context.Context, campaignID uuid.UUID, req *models.GenerateCodesRequest) (*models.GenerateCodesResult, error) {
+	s.gotReq = req
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &models.GenerateCodesResult{CampaignID: campaignID, Generated: req.Count, CodesCount: req.Count}, nil
+}
+func (s *stubCampaignService) ListCampaignCodes(ctx context.Context, campaignID uuid.UUID) ([]*models.PromoCode, error) {
+	return s.codes, s.err
+}
+func (s *stubCampaignService) DeactivateCampaign(ctx context.Context, campaignID uuid.UUID) (*models.Campaign, error) {
+	return &models.Campaign{ID: campaignID}, s.err
+}
+func (s *stubCampaignService) GetCampaignStats(ctx context.Context, campaignID uuid.UUID) (*models.CampaignStats, error) {
+	return &models.CampaignStats{CampaignID: campaignID}, s.err
+}
+
+func TestCampaignHandler_GenerateCodes(t *testing.T) {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	svc := &stubCampaignService{}
+	handler := NewCampaignHandler(svc, log)
+	path := "/api/campaigns/" + uuid.New().String() + "/codes"
+
+	rr := httptest.NewRecorder()
+	handler.GenerateCodes(rr, httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"count":10000,"prefix":"BLOG-"}`)))
+	if rr.Code != http.StatusCreated || svc.gotReq.Count != 10000 || svc.gotReq.Prefix != "BLOG-" {
+		t.Fatalf("expected 201, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	svc.err = apperror.Validation("count must be between 1 and 100000", nil)
+	rr = httptest.NewRecorder()
+	handler.GenerateCodes(rr, httptest.NewRequest(http.MethodPost, path, strings.NewReader(`{"count":0}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.GenerateCodes(rr, httptest.NewRequest(http.MethodPost, "/api/campaigns/bad/codes", strings.NewReader(`{}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for bad id, got %d", rr.Code)
+	}
+}
+
+func TestCampaignHandler_ListCodesCSV(t *testing.T) {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	svc := &stubCampaignService{codes: []*models.PromoCode{
+		{Code: "BLOG-AAAA1111", Active: true, MaxUses: 1},
+		{Code: "BLOG-BBBB2222", Active: false, MaxUses: 1, UsedCount: 1},
+	}}
+	handler := NewCampaignHandler(svc, log)
+	path := "/api/campaigns/" + uuid.New().String() + "/codes"
+
+	rr := httptest.NewRecorder()
+	handler.ListCodes(rr, httptest.NewRequest(http.MethodGet, path+"?format=csv", nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "text/csv" {
+		t.Fatalf("expected csv, got %d %s", rr.Code, rr.Header().Get("Content-Type"))
+	}
+	lines := strings.Split(strings.TrimSpace(rr.Body.String()), "\n")
+	if len(lines) != 3 || lines[0] != "code,active,max_uses,used_count,expires_at" || lines[2] != "BLOG-BBBB2222,false,1,1," {
+		t.Fatalf("unexpected csv: %q", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ListCodes(rr, httptest.NewRequest(http.MethodGet, path+"?format=xml", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+}
+
+func TestCampaignHandler_GetCampaign_NotFound(t *testing.T) {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	handler := NewCampaignHandler(&stubCampaignService{}, log)
+
+	rr := httptest.NewRecorder()
+	handler.GetCampaign(rr, httptest.NewRequest(http.MethodGet, "/api/campaigns/"+uuid.New().String(), nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,231 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"delivery-system/internal/config"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+	"delivery-system/internal/redis"
+
+	"github.com/google/uuid"
+)
+
+// defaultExpiringWithinDays — горизонт выборки истекающих документов по умолчанию.
+const defaultExpiringWithinDays = 30
+
+// CourierOnboardingHandler обрабатывает документы курьеров и этапы их проверки.
+type CourierOnboardingHandler struct {
+	onboardingService CourierOnboardingService
+	producer          EventProducer
+	redisClient       RedisClient
+	log               *logger.Logger
+	maxUploadBytes    int64
+}
+
+// NewCourierOnboardingHandler создает обработчик подключения курьеров.
+func NewCourierOnboardingHandler(onboardingService CourierOnboardingService, producer EventProducer, redisClient RedisClient, log *logger.Logger, cfg *config.StorageConfig) *CourierOnboardingHandler {
+	maxMB := defaultMaxUploadMB
+	if cfg != nil && cfg.MaxUploadMB > 0 {
+		maxMB = cfg.MaxUploadMB
+	}
+	return &CourierOnboardingHandler{
+		onboardingService: onboardingService,
+		producer:          producer,
+		redisClient:       redisClient,
+		log:               log,
+		maxUploadBytes:    int64(maxMB) << 20,
+	}
+}
+
+// UploadDocument принимает документ курьера (multipart/form-data: type, file, expires_at в формате YYYY-MM-DD).
+// Файл не PDF, JPEG, PNG или WebP сохраняется с типом application/octet-stream.
+func (h *CourierOnboardingHandler) UploadDocument(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	courierID, err := extractUUIDFromPath(r.URL.Path, "/api/couriers/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid courier ID")
+		return
+	}
+
+	file, ok := parseUpload(w, r, h.maxUploadBytes, documentContentTypes)
+	if !ok {
+		return
+	}
+	defer file.Close()
+	defer func() { _ = r.MultipartForm.RemoveAll() }()
+
+	docType := models.DocumentType(r.FormValue("type"))
+	if !docType.IsValid() {
+		writeErrorResponse(w, http.StatusBadRequest, "type must be one of identity, driver_license, vehicle_registration, insurance")
+		return
+	}
+
+	var expiresAt *time.Time
+	if value := r.FormValue("expires_at"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			writeErrorResponse(w, http.StatusBadRequest, "Invalid expires_at, expected YYYY-MM-DD")
+			return
+		}
+		expiresAt = &parsed
+	}
+
+	doc, err := h.onboardingService.UploadDocument(r.Context(), courierID, docType, file.ContentType, expiresAt, file)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to upload courier document")
+		return
+	}
+
+	// Загрузка могла перевести курьера в documents_submitted
+	h.invalidateCourier(r, courierID)
+
+	writeJSONResponse(w, http.StatusCreated, doc)
+}
+
+// ListDocuments возвращает документы курьера.
+func (h *CourierOnboardingHandler) ListDocuments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	courierID, err := extractUUIDFromPath(r.URL.Path, "/api/couriers/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid courier ID")
+		return
+	}
+
+	docs, err := h.onboardingService.ListDocuments(r.Context(), courierID)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to list courier documents")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, docs)
+}
+
+// GetDocumentContent отдает содержимое документа курьера.
+func (h *CourierOnboardingHandler) GetDocumentContent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	courierID, err := extractUUIDFromPath(r.URL.Path, "/api/couriers/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid courier ID")
+		return
+	}
+
+	documentID, err := extractDocumentIDFromPath(r.URL.Path)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid document ID")
+		return
+	}
+
+	doc, content, err := h.onboardingService.OpenDocument(r.Context(), courierID, documentID)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to get courier document")
+		return
+	}
+	defer content.Close()
+
+	if err := serveStoredFile(w, content, doc.ContentType, doc.SizeBytes, "document-"+documentID.String(), documentContentTypes); err != nil {
+		h.log.WithError(err).WithField("document_id", documentID).Warn("Failed to stream courier document")
+	}
+}
+
+// GetExpiringDocuments возвращает документы, срок которых истекает в ближайшие within_days дней (по умолчанию 30),
+// включая уже истекшие.
+func (h *CourierOnboardingHandler) GetExpiringDocuments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	days := defaultExpiringWithinDays
+	if value := r.URL.Query().Get("within_days"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 || parsed > 365 {
+			writeErrorResponse(w, http.StatusBadRequest, "within_days must be between 0 and 365")
+			return
+		}
+		days = parsed
+	}
+
+	docs, err := h.onboardingService.ListExpiringDocuments(r.Context(), time.Now().AddDate(0, 0, days))
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to list expiring courier documents")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, docs)
+}
+
+// UpdateOnboarding переводит курьера на другой этап подключения.
+func (h *CourierOnboardingHandler) UpdateOnboarding(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	courierID, err := extractUUIDFromPath(r.URL.Path, "/api/couriers/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid courier ID")
+		return
+	}
+
+	var req models.UpdateOnboardingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if !req.Status.IsValid() {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid onboarding status")
+		return
+	}
+
+	change, err := h.onboardingService.UpdateOnboarding(r.Context(), courierID, &req)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to update courier onboarding")
+		return
+	}
+
+	// Приостановленный или отключенный курьер уходит в offline
+	if change.OldCourierStatus != change.CourierStatus {
+		if err := h.producer.PublishCourierStatusChanged(courierID, change.OldCourierStatus, change.CourierStatus); err != nil {
+			h.log.WithError(err).Error("Failed to publish courier status changed event")
+		}
+	}
+
+	h.invalidateCourier(r, courierID)
+
+	writeJSONResponse(w, http.StatusOK, change)
+}
+
+// invalidateCourier удаляет курьера из кеша после смены этапа подключения.
+func (h *CourierOnboardingHandler) invalidateCourier(r *http.Request, courierID uuid.UUID) {
+	cacheKey := redis.GenerateKey(redis.KeyPrefixCourier, courierID.String())
+	if err := h.redisClient.Delete(r.Context(), cacheKey); err != nil {
+		h.log.WithError(err).Error("Failed to invalidate courier cache")
+	}
+}
+
+// extractDocumentIDFromPath извлекает ID документа из пути /api/couriers/{id}/documents/{document_id}
+func extractDocumentIDFromPath(path string) (uuid.UUID, error) {
+	idx := strings.Index(path, "/documents/")
+	if idx < 0 {
+		return uuid.Nil, fmt.Errorf("invalid path format")
+	}
+	return uuid.Parse(strings.Trim(path[idx+len("/documents/"):], "/"))
+}
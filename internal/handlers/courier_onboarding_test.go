@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/config"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type stubOnboardingService struct {
+	doc     *models.CourierDocument
+	list    []*models.CourierDocument
+	change  *models.OnboardingChange
+	content string
+	err     error
+
+	gotType    models.DocumentType
+	gotExpires *time.Time
+	gotBody    string
+	gotCT      string
+	gotBefore  time.Time
+	gotReq     *models.UpdateOnboardingRequest
+}
+
+func (s *stubOnboardingService) UploadDocument(ctx context.Context, courierID uuid.UUID, docType models.DocumentType, contentType string, expiresAt *time.Time, content io.Reader) (*models.CourierDocument, error) {
+	data, _ := io.ReadAll(content)
+	s.gotType = docType
+	s.gotExpires = expiresAt
+	s.gotBody = string(data)
+	s.gotCT = contentType
+	return s.doc, s.err
+}
+func (s *stubOnboardingService) ListDocuments(ctx context.Context, courierID uuid.UUID) ([]*models.CourierDocument, error) {
+	return s.list, s.err
+}
+func (s *stubOnboardingService) OpenDocument(ctx context.Context, courierID, documentID uuid.UUID) (*models.CourierDocument, io.ReadCloser, error) {
+	if s.err != nil {
+		return nil, nil, s.err
+	}
+	return s.doc, io.NopCloser(strings.NewReader(s.content)), nil
+}
+func (s *stubOnboardingService) ListExpiringDocuments(ctx context.Context, before time.Time) ([]*models.CourierDocument, error) {
+	s.gotBefore = before
+	return s.list, s.err
+}
+func (s *stubOnboardingService) UpdateOnboarding(ctx context.Context, courierID uuid.UUID, req *models.UpdateOnboardingRequest) (*models.OnboardingChange, error) {
+	s.gotReq = req
+	return s.change, s.err
+}
+
+func newOnboardingHandler(svc *stubOnboardingService, producer EventProducer) *CourierOnboardingHandler {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	return NewCourierOnboardingHandler(svc, producer, &stubRedisMiss{}, log, &config.StorageConfig{MaxUploadMB: 1})
+}
+
+func newDocumentUploadRequest(t *testing.T, path string, fields map[string]string, content string) *http.Request {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	for k, v := range fields {
+		_ = mw.WriteField(k, v)
+	}
+	if content != "" {
+		fw, err := mw.CreateFormFile("file", "licence.pdf")
+		if err != nil {
+			t.Fatalf("failed to create form file: %v", err)
+		}
+		_, _ = fw.Write([]byte(content))
+	}
+	_ = mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, path, body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestCourierOnboardingHandler_UploadDocument(t *testing.T) {
+	courierID := uuid.New()
+	path := "/api/couriers/" + courierID.String() + "/documents"
+	svc := &stubOnboardingService{doc: &models.CourierDocument{ID: uuid.New(), CourierID: courierID, Type: models.DocumentTypeDriverLicense}}
+	handler := newOnboardingHandler(svc, &stubProducerCourier{})
+
+	rr := httptest.NewRecorder()
+	handler.UploadDocument(rr, newDocumentUploadRequest(t, path, map[string]string{"type": "driver_license", "expires_at": "2030-01-31"}, "scan"))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	// CreateFormFile отправляет application/octet-stream — тип вне белого списка так и сохраняется
+	if svc.gotCT != "application/octet-stream" {
+		t.Fatalf("unexpected stored content type %q", svc.gotCT)
+	}
+	if svc.gotType != models.DocumentTypeDriverLicense || svc.gotBody != "scan" || svc.gotExpires == nil || svc.gotExpires.Format("2006-01-02") != "2030-01-31" {
+		t.Fatalf("unexpected service call: %+v", svc)
+	}
+
+	cases := []struct {
+		name   string
+		svc    *stubOnboardingService
+		req    *http.Request
+		status int
+	}{
+		{"invalid type", &stubOnboardingService{}, newDocumentUploadRequest(t, path, map[string]string{"type": "selfie"}, "x"), http.StatusBadRequest},
+		{"invalid expiry", &stubOnboardingService{}, newDocumentUploadRequest(t, path, map[string]string{"type": "insurance", "expires_at": "31.01.2030"}, "x"), http.StatusBadRequest},
+		{"missing file", &stubOnboardingService{}, newDocumentUploadRequest(t, path, map[string]string{"type": "identity"}, ""), http.StatusBadRequest},
+		{"forbidden", &stubOnboardingService{err: apperror.Forbidden("couriers can only upload their own documents", nil)}, newDocumentUploadRequest(t, path, map[string]string{"type": "identity"}, "x"), http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rr := httptest.NewRecorder()
+			newOnboardingHandler(tc.svc, &stubProducerCourier{}).UploadDocument(rr, tc.req)
+			if rr.Code != tc.status {
+				t.Fatalf("expected %d, got %d", tc.status, rr.Code)
+			}
+		})
+	}
+}
+
+func TestCourierOnboardingHandler_GetDocumentContent(t *testing.T) {
+	courierID := uuid.New()
+	documentID := uuid.New()
+	ct := "application/pdf"
+	svc := &stubOnboardingService{doc: &models.CourierDocument{ID: documentID, CourierID: courierID, ContentType: &ct, SizeBytes: 3}, content: "pdf"}
+	handler := newOnboardingHandler(svc, &stubProducerCourier{})
+
+	rr := httptest.NewRecorder()
+	handler.GetDocumentContent(rr, httptest.NewRequest(http.MethodGet, "/api/couriers/"+courierID.String()+"/documents/"+documentID.String(), nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != ct || rr.Body.String() != "pdf" {
+		t.Fatalf("unexpected response: %d %q %q", rr.Code, rr.Header().Get("Content-Type"), rr.Body.String())
+	}
+	if rr.Header().Get("X-Content-Type-Options") != "nosniff" || rr.Header().Get("Content-Disposition") != `attachment; filename="document-`+documentID.String()+`.pdf"` {
+		t.Fatalf("expected safe download headers, got %v", rr.Header())
+	}
+
+	html := "text/html; charset=utf-8"
+	svc.doc.ContentType = &html
+	rr = httptest.NewRecorder()
+	handler.GetDocumentContent(rr, httptest.NewRequest(http.MethodGet, "/api/couriers/"+courierID.String()+"/documents/"+documentID.String(), nil))
+	if rr.Header().Get("Content-Type") != "application/octet-stream" {
+		t.Fatalf("expected octet-stream for html document, got %q", rr.Header().Get("Content-Type"))
+	}
+
+	rr = httptest.NewRecorder()
+	handler.GetDocumentContent(rr, httptest.NewRequest(http.MethodGet, "/api/couriers/"+courierID.String()+"/documents/bad", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid document id, got %d", rr.Code)
+	}
+}
+
+func TestCourierOnboardingHandler_GetExpiringDocuments(t *testing.T) {
+	svc := &stubOnboardingService{list: []*models.CourierDocument{}}
+	handler := newOnboardingHandler(svc, &stubProducerCourier{})
+
+	rr := httptest.NewRecorder()
+	handler.GetExpiringDocuments(rr, httptest.NewRequest(http.MethodGet, "/api/courier-documents/expiring?within_days=7", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if d := time.Until(svc.gotBefore); d < 6*24*time.Hour || d > 7*24*time.Hour {
+		t.Fatalf("unexpected horizon: %v", svc.gotBefore)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.GetExpiringDocuments(rr, httptest.NewRequest(http.MethodGet, "/api/courier-documents/expiring?within_days=-1", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+}
+
+func TestCourierOnboardingHandler_UpdateOnboarding(t *testing.T) {
+	courierID := uuid.New()
+	path := "/api/couriers/" + courierID.String() + "/onboarding"
+	svc := &stubOnboardingService{change: &models.OnboardingChange{
+		CourierID:        courierID,
+		OldStatus:        models.OnboardingStatusVerified,
+		Status:           models.OnboardingStatusSuspended,
+		OldCourierStatus: models.CourierStatusAvailable,
+		CourierStatus:    models.CourierStatusOffline,
+	}}
+	producer := &recordingProducerCourier{}
+	handler := newOnboardingHandler(svc, producer)
+
+	rr := httptest.NewRecorder()
+	handler.UpdateOnboarding(rr, httptest.NewRequest(http.MethodPut, path, strings.NewReader(`{"status":"suspended","reason":"insurance expired"}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if svc.gotReq.Reason != "insurance expired" || producer.statusChangedCalls != 1 {
+		t.Fatalf("unexpected call: %+v, events %d", svc.gotReq, producer.statusChangedCalls)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.UpdateOnboarding(rr, httptest.NewRequest(http.MethodPut, path, strings.NewReader(`{"status":"approved"}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown status, got %d", rr.Code)
+	}
+
+	svc.err = apperror.Conflict("missing or expired documents: insurance", nil)
+	rr = httptest.NewRecorder()
+	handler.UpdateOnboarding(rr, httptest.NewRequest(http.MethodPut, path, strings.NewReader(`{"status":"verified"}`)))
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"delivery-system/internal/logger"
+)
+
+// defaultPerformanceHistoryLimit — сколько записей истории оценки возвращается по умолчанию.
+const defaultPerformanceHistoryLimit = 20
+
+// CourierPerformanceHandler отдает оценку работы курьеров.
+type CourierPerformanceHandler struct {
+	performanceService CourierPerformanceService
+	log                *logger.Logger
+}
+
+// NewCourierPerformanceHandler создает обработчик оценки работы курьеров.
+func NewCourierPerformanceHandler(performanceService CourierPerformanceService, log *logger.Logger) *CourierPerformanceHandler {
+	return &CourierPerformanceHandler{
+		performanceService: performanceService,
+		log:                log,
+	}
+}
+
+// GetPerformance возвращает показатели курьера, итоговую оценку и последние history_limit
+// записей истории (по умолчанию 20, 0 — без истории).
+func (h *CourierPerformanceHandler) GetPerformance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	courierID, err := extractUUIDFromPath(r.URL.Path, "/api/couriers/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid courier ID")
+		return
+	}
+
+	limit := defaultPerformanceHistoryLimit
+	if value := r.URL.Query().Get("history_limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 || parsed > 200 {
+			writeErrorResponse(w, http.StatusBadRequest, "history_limit must be between 0 and 200")
+			return
+		}
+		limit = parsed
+	}
+
+	report, err := h.performanceService.GetPerformance(r.Context(), courierID, limit)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to get courier performance")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, report)
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/config"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type stubPerformanceService struct {
+	report   *models.CourierPerformanceReport
+	err      error
+	gotLimit int
+}
+
+func (s *stubPerformanceService) GetPerformance(ctx context.Context, courierID uuid.UUID, historyLimit int) (*models.CourierPerformanceReport, error) {
+	s.gotLimit = historyLimit
+	return s.report, s.err
+}
+
+func TestCourierPerformanceHandler_GetPerformance(t *testing.T) {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	courierID := uuid.New()
+	path := "/api/couriers/" + courierID.String() + "/performance"
+
+	report := &models.CourierPerformanceReport{History: []*models.PerformanceSnapshot{}}
+	report.CourierID = courierID
+	report.Score = 0.87
+	svc := &stubPerformanceService{report: report}
+	handler := NewCourierPerformanceHandler(svc, log)
+
+	rr := httptest.NewRecorder()
+	handler.GetPerformance(rr, httptest.NewRequest(http.MethodGet, path+"?history_limit=5", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid json: %v", err)
+	}
+	if body["score"] != 0.87 || svc.gotLimit != 5 {
+		t.Fatalf("unexpected response %v, limit %d", body, svc.gotLimit)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.GetPerformance(rr, httptest.NewRequest(http.MethodGet, path, nil))
+	if svc.gotLimit != defaultPerformanceHistoryLimit {
+		t.Fatalf("expected default limit, got %d", svc.gotLimit)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.GetPerformance(rr, httptest.NewRequest(http.MethodGet, path+"?history_limit=-1", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+
+	svc.err = apperror.NotFound("courier not found", nil)
+	rr = httptest.NewRecorder()
+	handler.GetPerformance(rr, httptest.NewRequest(http.MethodGet, path, nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+}
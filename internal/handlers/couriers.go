@@ -180,6 +180,16 @@ func (h *CourierHandler) GetCouriers(w http.ResponseWriter, r *http.Request) {
 		status = &s
 	}
 
+	var onboarding *models.OnboardingStatus
+	if onboardingStr := query.Get("onboarding_status"); onboardingStr != "" {
+		o := models.OnboardingStatus(onboardingStr)
+		if !o.IsValid() {
+			writeErrorResponse(w, http.StatusBadRequest, "Invalid onboarding_status")
+			return
+		}
+		onboarding = &o
+	}
+
 	var minRating *float64
 	if ratingStr := query.Get("min_rating"); ratingStr != "" {
 		if val, err := strconv.ParseFloat(ratingStr, 64); err == nil && val >= 0 && val <= 5 {
@@ -202,24 +212,15 @@ func (h *CourierHandler) GetCouriers(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	limit := 50 // По умолчанию
-	if limitStr := query.Get("limit"); limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
-			limit = l
-		}
-	}
-
-	offset := 0
-	if offsetStr := query.Get("offset"); offsetStr != "" {
-		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
-			offset = o
-		}
+	page, err := parsePageRequest(w, r, 100)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	couriers, err := h.courierService.GetCouriers(r.Context(), status, minRating, limit, offset, orderBy)
+	couriers, err := h.courierService.GetCouriers(r.Context(), status, onboarding, minRating, orderBy, page)
 	if err != nil {
-		h.log.WithError(err).Error("Failed to get couriers")
-		writeErrorResponse(w, http.StatusInternalServerError, "Failed to get couriers")
+		writeServiceError(w, h.log, err, "Failed to get couriers")
 		return
 	}
 
@@ -243,43 +244,6 @@ func (h *CourierHandler) GetAvailableCouriers(w http.ResponseWriter, r *http.Req
 	writeJSONResponse(w, http.StatusOK, couriers)
 }
 
-// GetCourierReviews возвращает отзывы по курьеру
-func (h *CourierHandler) GetCourierReviews(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodGet {
-		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
-	courierID, err := extractUUIDFromPath(r.URL.Path, "/api/couriers/")
-	if err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, "Invalid courier ID")
-		return
-	}
-
-	query := r.URL.Query()
-	limit := 50
-	if limitStr := query.Get("limit"); limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
-			limit = l
-		}
-	}
-
-	offset := 0
-	if offsetStr := query.Get("offset"); offsetStr != "" {
-		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
-			offset = o
-		}
-	}
-
-	reviews, err := h.orderService.GetCourierReviews(r.Context(), courierID, limit, offset)
-	if err != nil {
-		writeServiceError(w, h.log, err, "Failed to get courier reviews")
-		return
-	}
-
-	writeJSONResponse(w, http.StatusOK, reviews)
-}
-
 // AssignOrderToCourier назначает заказ курьеру
 func (h *CourierHandler) AssignOrderToCourier(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -340,5 +304,8 @@ func (h *CourierHandler) validateCreateCourierRequest(req *models.CreateCourierR
 	if req.Phone == "" {
 		return fmt.Errorf("courier phone is required")
 	}
+	if req.VehicleType != "" && !req.VehicleType.IsValid() {
+		return fmt.Errorf("vehicle_type must be one of bike, scooter, car")
+	}
 	return nil
 }
@@ -13,6 +13,7 @@ import (
 	"delivery-system/internal/config"
 	"delivery-system/internal/logger"
 	"delivery-system/internal/models"
+	"delivery-system/internal/pagination"
 
 	"github.com/google/uuid"
 )
@@ -40,8 +41,11 @@ func (s *stubCourierService) UpdateCourierStatus(ctx context.Context, courierID
 	}
 	return s.err
 }
-func (s *stubCourierService) GetCouriers(ctx context.Context, status *models.CourierStatus, minRating *float64, limit, offset int, orderBy string) ([]*models.Courier, error) {
-	return s.list, s.err
+func (s *stubCourierService) GetCouriers(ctx context.Context, status *models.CourierStatus, onboarding *models.OnboardingStatus, minRating *float64, orderBy string, page pagination.Request) (*pagination.Page[*models.Courier], error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &pagination.Page[*models.Courier]{Items: s.list}, nil
 }
 func (s *stubCourierService) GetAvailableCouriers(ctx context.Context) ([]*models.Courier, error) {
 	return s.list, s.err
@@ -51,9 +55,8 @@ func (s *stubCourierService) AssignOrderToCourier(ctx context.Context, orderID,
 }
 
 type stubOrderSvc struct {
-	order   *models.Order
-	reviews []*models.Review
-	err     error
+	order *models.Order
+	err   error
 }
 
 func (s *stubOrderSvc) CreateOrder(ctx context.Context, req *models.CreateOrderRequest) (*models.Order, error) {
@@ -62,20 +65,32 @@ func (s *stubOrderSvc) CreateOrder(ctx context.Context, req *models.CreateOrderR
 func (s *stubOrderSvc) GetOrder(ctx context.Context, orderID uuid.UUID) (*models.Order, error) {
 	return s.order, s.err
 }
-func (s *stubOrderSvc) UpdateOrderStatus(ctx context.Context, orderID uuid.UUID, req *models.UpdateOrderStatusRequest) error {
-	return s.err
+func (s *stubOrderSvc) UpdateOrder(ctx context.Context, orderID uuid.UUID, req *models.UpdateOrderRequest) (*models.OrderUpdate, error) {
+	return nil, s.err
 }
-func (s *stubOrderSvc) GetOrders(ctx context.Context, status *models.OrderStatus, courierID *uuid.UUID, limit, offset int) ([]*models.Order, error) {
-	return []*models.Order{s.order}, s.err
+func (s *stubOrderSvc) UpdateOrderStatus(ctx context.Context, orderID uuid.UUID, req *models.UpdateOrderStatusRequest) (*models.OrderStatusUpdate, error) {
+	return nil, s.err
 }
-func (s *stubOrderSvc) CreateReview(ctx context.Context, orderID uuid.UUID, req *models.CreateReviewRequest) (*models.Review, error) {
+func (s *stubOrderSvc) GetOrderHistory(ctx context.Context, orderID uuid.UUID) (*models.OrderHistory, error) {
 	return nil, s.err
 }
-func (s *stubOrderSvc) GetCourierReviews(ctx context.Context, courierID uuid.UUID, limit, offset int) ([]*models.Review, error) {
-	if s.reviews != nil {
-		return s.reviews, s.err
-	}
-	return []*models.Review{}, s.err
+func (s *stubOrderSvc) LogContactAttempt(ctx context.Context, orderID uuid.UUID, req *models.LogContactAttemptRequest) (*models.ContactAttempt, error) {
+	return nil, s.err
+}
+func (s *stubOrderSvc) GetDeliveryAttempts(ctx context.Context, orderID uuid.UUID) (*models.DeliveryAttempts, error) {
+	return nil, s.err
+}
+func (s *stubOrderSvc) UnassignCourier(ctx context.Context, orderID uuid.UUID, reason string) (*models.CourierReassignment, error) {
+	return nil, s.err
+}
+func (s *stubOrderSvc) ReassignCourier(ctx context.Context, orderID, courierID uuid.UUID, reason string) (*models.CourierReassignment, error) {
+	return nil, s.err
+}
+func (s *stubOrderSvc) GetOrders(ctx context.Context, status *models.OrderStatus, courierID *uuid.UUID, page pagination.Request) (*pagination.Page[*models.Order], error) {
+	return &pagination.Page[*models.Order]{Items: []*models.Order{s.order}}, s.err
+}
+func (s *stubOrderSvc) SearchOrders(ctx context.Context, q *models.OrderSearchQuery) (*models.OrderSearchResult, error) {
+	return &models.OrderSearchResult{}, s.err
 }
 
 type stubProducerCourier struct{}
@@ -84,6 +99,9 @@ func (s *stubProducerCourier) PublishOrderCreated(order *models.Order) error { r
 func (s *stubProducerCourier) PublishOrderStatusChanged(orderID uuid.UUID, oldStatus, newStatus models.OrderStatus, courierID *uuid.UUID) error {
 	return nil
 }
+func (s *stubProducerCourier) PublishOrderUpdated(orderID uuid.UUID, changes []models.FieldChange) error {
+	return nil
+}
 func (s *stubProducerCourier) PublishCourierStatusChanged(courierID uuid.UUID, oldStatus, newStatus models.CourierStatus) error {
 	return nil
 }
@@ -91,6 +109,10 @@ func (s *stubProducerCourier) PublishLocationUpdated(courierID uuid.UUID, lat, l
 	return nil
 }
 func (s *stubProducerCourier) PublishCourierAssigned(orderID, courierID uuid.UUID) error { return nil }
+func (s *stubProducerCourier) PublishCourierUnassigned(orderID, courierID uuid.UUID, newCourierID *uuid.UUID, reason string) error {
+	return nil
+}
+func (s *stubProducerCourier) PublishReceiptIssued(receipt *models.Receipt) error { return nil }
 
 type recordingProducerCourier struct {
 	statusChangedCalls int
@@ -104,6 +126,9 @@ func (p *recordingProducerCourier) PublishOrderCreated(order *models.Order) erro
 func (p *recordingProducerCourier) PublishOrderStatusChanged(orderID uuid.UUID, oldStatus, newStatus models.OrderStatus, courierID *uuid.UUID) error {
 	return nil
 }
+func (p *recordingProducerCourier) PublishOrderUpdated(orderID uuid.UUID, changes []models.FieldChange) error {
+	return nil
+}
 func (p *recordingProducerCourier) PublishCourierStatusChanged(courierID uuid.UUID, oldStatus, newStatus models.CourierStatus) error {
 	p.statusChangedCalls++
 	return p.statusChangedErr
@@ -115,6 +140,12 @@ func (p *recordingProducerCourier) PublishLocationUpdated(courierID uuid.UUID, l
 func (p *recordingProducerCourier) PublishCourierAssigned(orderID, courierID uuid.UUID) error {
 	return nil
 }
+func (p *recordingProducerCourier) PublishCourierUnassigned(orderID, courierID uuid.UUID, newCourierID *uuid.UUID, reason string) error {
+	return nil
+}
+func (p *recordingProducerCourier) PublishReceiptIssued(receipt *models.Receipt) error {
+	return nil
+}
 
 type stubRedisMiss struct{}
 
@@ -131,7 +162,7 @@ func newCourierHandler() *CourierHandler {
 	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
 	courier := &models.Courier{ID: uuid.New(), Name: "C"}
 	order := &models.Order{ID: uuid.New()}
-	return NewCourierHandler(&stubCourierService{courier: courier, list: []*models.Courier{courier}}, &stubOrderSvc{order: order, reviews: []*models.Review{{ID: uuid.New(), Rating: 5}}}, &stubProducerCourier{}, &stubRedis{}, log)
+	return NewCourierHandler(&stubCourierService{courier: courier, list: []*models.Courier{courier}}, &stubOrderSvc{order: order}, &stubProducerCourier{}, &stubRedis{}, log)
 }
 
 func TestCourierHandler_CreateCourier(t *testing.T) {
@@ -354,17 +385,6 @@ func TestCourierHandler_GetCouriers(t *testing.T) {
 	}
 }
 
-func TestCourierHandler_GetCourierReviews(t *testing.T) {
-	h := newCourierHandler()
-	id := uuid.New()
-	req := httptest.NewRequest(http.MethodGet, "/api/couriers/"+id.String()+"/reviews?limit=5&offset=0", nil)
-	rr := httptest.NewRecorder()
-	h.GetCourierReviews(rr, req)
-	if rr.Code != http.StatusOK {
-		t.Fatalf("expected 200, got %d", rr.Code)
-	}
-}
-
 func TestCourierHandler_UpdateStatus_BadBody(t *testing.T) {
 	h := newCourierHandler()
 	req := httptest.NewRequest(http.MethodPut, "/api/couriers/"+uuid.New().String()+"/status", bytes.NewBufferString("bad"))
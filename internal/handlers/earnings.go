@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+)
+
+// EarningsHandler обрабатывает начисления и выплаты курьерам.
+type EarningsHandler struct {
+	earningsService EarningsService
+	log             *logger.Logger
+}
+
+// NewEarningsHandler создает обработчик начислений курьерам.
+func NewEarningsHandler(earningsService EarningsService, log *logger.Logger) *EarningsHandler {
+	return &EarningsHandler{
+		earningsService: earningsService,
+		log:             log,
+	}
+}
+
+// AddTip начисляет чаевые курьеру по доставленному заказу.
+func (h *EarningsHandler) AddTip(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	orderID, err := extractUUIDFromPath(r.URL.Path, "/api/orders/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	var req models.CreateTipRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	entry, err := h.earningsService.AddTip(r.Context(), orderID, &req)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to add tip")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusCreated, entry)
+}
+
+// CreateAdjustment проводит бонус или корректировку по курьеру.
+func (h *EarningsHandler) CreateAdjustment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	courierID, err := extractUUIDFromPath(r.URL.Path, "/api/couriers/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid courier ID")
+		return
+	}
+
+	var req models.CreateAdjustmentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	entry, err := h.earningsService.CreateAdjustment(r.Context(), courierID, &req)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to create adjustment")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusCreated, entry)
+}
+
+// GetBalance возвращает текущие балансы курьера, по одному на валюту начислений.
+func (h *EarningsHandler) GetBalance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	courierID, err := extractUUIDFromPath(r.URL.Path, "/api/couriers/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid courier ID")
+		return
+	}
+
+	balance, err := h.earningsService.GetBalance(r.Context(), courierID)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to get courier balance")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, balance)
+}
+
+// GetStatement возвращает выписку курьера за период (?from=YYYY-MM-DD&to=YYYY-MM-DD&currency=EUR).
+func (h *EarningsHandler) GetStatement(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	courierID, err := extractUUIDFromPath(r.URL.Path, "/api/couriers/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid courier ID")
+		return
+	}
+
+	from, to, err := parsePayoutPeriod(r)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	statement, err := h.earningsService.GetStatement(r.Context(), courierID, r.URL.Query().Get("currency"), from, to)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to get courier statement")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, statement)
+}
+
+// GetPayouts возвращает реестр выплат за период в JSON или CSV (?format=csv).
+func (h *EarningsHandler) GetPayouts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	from, to, err := parsePayoutPeriod(r)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	format := strings.ToLower(r.URL.Query().Get("format"))
+	if format != "" && format != "json" && format != "csv" {
+		writeErrorResponse(w, http.StatusBadRequest, "format must be json or csv")
+		return
+	}
+
+	lines, err := h.earningsService.GetPayouts(r.Context(), from, to)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to get payouts")
+		return
+	}
+
+	if format == "csv" {
+		if err := writePayoutCSV(w, from, to, lines); err != nil {
+			h.log.WithError(err).Warn("Failed to stream payout CSV")
+		}
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, lines)
+}
+
+// parsePayoutPeriod разбирает период выписки; по умолчанию — с начала текущего месяца по сегодня.
+func parsePayoutPeriod(r *http.Request) (time.Time, time.Time, error) {
+	query := r.URL.Query()
+	now := time.Now().UTC()
+
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	to := endOfDay(now)
+
+	if fromParam := query.Get("from"); fromParam != "" {
+		parsed, err := time.Parse("2006-01-02", fromParam)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid 'from' date, expected YYYY-MM-DD")
+		}
+		from = startOfDay(parsed)
+	}
+
+	if toParam := query.Get("to"); toParam != "" {
+		parsed, err := time.Parse("2006-01-02", toParam)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid 'to' date, expected YYYY-MM-DD")
+		}
+		to = endOfDay(parsed)
+	}
+
+	if from.After(to) {
+		return time.Time{}, time.Time{}, fmt.Errorf("'from' date must be before 'to' date")
+	}
+
+	return from, to, nil
+}
+
+func writePayoutCSV(w http.ResponseWriter, from, to time.Time, lines []*models.PayoutLine) error {
+	filename := fmt.Sprintf("payouts_%s_%s.csv", from.Format("2006-01-02"), to.Format("2006-01-02"))
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", "attachment; filename="+filename)
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	_ = writer.Write([]string{"courier_id", "courier_name", "currency", "deliveries", "earnings", "tips", "bonuses", "adjustments", "total"})
+
+	for _, line := range lines {
+		_ = writer.Write([]string{
+			line.CourierID.String(),
+			line.CourierName,
+			line.Currency,
+			strconv.Itoa(line.Deliveries),
+			line.Earnings.String(),
+			line.Tips.String(),
+			line.Bonuses.String(),
+			line.Adjustments.String(),
+			line.Total.String(),
+		})
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/config"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+	"delivery-system/internal/money"
+
+	"github.com/google/uuid"
+)
+
+type stubEarningsService struct {
+	entry     *models.LedgerTransaction
+	balances  []*models.CourierBalance
+	statement *models.CourierStatement
+	payouts   []*models.PayoutLine
+	err       error
+
+	gotFrom     time.Time
+	gotTo       time.Time
+	gotCurrency string
+}
+
+func (s *stubEarningsService) AddTip(ctx context.Context, orderID uuid.UUID, req *models.CreateTipRequest) (*models.LedgerTransaction, error) {
+	return s.entry, s.err
+}
+func (s *stubEarningsService) CreateAdjustment(ctx context.Context, courierID uuid.UUID, req *models.CreateAdjustmentRequest) (*models.LedgerTransaction, error) {
+	return s.entry, s.err
+}
+func (s *stubEarningsService) GetBalance(ctx context.Context, courierID uuid.UUID) ([]*models.CourierBalance, error) {
+	return s.balances, s.err
+}
+func (s *stubEarningsService) GetStatement(ctx context.Context, courierID uuid.UUID, currency string, from, to time.Time) (*models.CourierStatement, error) {
+	s.gotFrom, s.gotTo, s.gotCurrency = from, to, currency
+	return s.statement, s.err
+}
+func (s *stubEarningsService) GetPayouts(ctx context.Context, from, to time.Time) ([]*models.PayoutLine, error) {
+	s.gotFrom, s.gotTo = from, to
+	return s.payouts, s.err
+}
+
+func TestEarningsHandler_AddTip(t *testing.T) {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	orderID := uuid.New()
+	handler := NewEarningsHandler(&stubEarningsService{entry: &models.LedgerTransaction{ID: uuid.New(), Kind: models.LedgerKindTip, Amount: money.New(5000, "RUB")}}, log)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/orders/"+orderID.String()+"/tip", bytes.NewBufferString(`{"amount":50}`))
+	rr := httptest.NewRecorder()
+	handler.AddTip(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rr.Code)
+	}
+
+	handler = NewEarningsHandler(&stubEarningsService{err: apperror.Conflict("tips can only be added to delivered orders", nil)}, log)
+	req = httptest.NewRequest(http.MethodPost, "/api/orders/"+orderID.String()+"/tip", bytes.NewBufferString(`{"amount":50}`))
+	rr = httptest.NewRecorder()
+	handler.AddTip(rr, req)
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/orders/"+orderID.String()+"/tip", bytes.NewBufferString(`{bad`))
+	rr = httptest.NewRecorder()
+	handler.AddTip(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+}
+
+func TestEarningsHandler_CreateAdjustment(t *testing.T) {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	courierID := uuid.New()
+	handler := NewEarningsHandler(&stubEarningsService{entry: &models.LedgerTransaction{ID: uuid.New(), Kind: models.LedgerKindBonus, Amount: money.New(10000, "RUB")}}, log)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/couriers/"+courierID.String()+"/adjustments", bytes.NewBufferString(`{"kind":"bonus","amount":100}`))
+	rr := httptest.NewRecorder()
+	handler.CreateAdjustment(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/couriers/bad/adjustments", bytes.NewBufferString(`{}`))
+	rr = httptest.NewRecorder()
+	handler.CreateAdjustment(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+}
+
+func TestEarningsHandler_GetBalance(t *testing.T) {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	courierID := uuid.New()
+	handler := NewEarningsHandler(&stubEarningsService{balances: []*models.CourierBalance{
+		{CourierID: courierID, Currency: "EUR", Balance: money.New(4000, "EUR")},
+		{CourierID: courierID, Currency: "RUB", Balance: money.New(12000, "RUB")},
+	}}, log)
+
+	rr := httptest.NewRecorder()
+	handler.GetBalance(rr, httptest.NewRequest(http.MethodGet, "/api/couriers/"+courierID.String()+"/balance", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), `"currency":"EUR","balance":40`) || !strings.Contains(rr.Body.String(), `"currency":"RUB","balance":120`) {
+		t.Fatalf("expected a balance per currency, got %s", rr.Body.String())
+	}
+
+	handler = NewEarningsHandler(&stubEarningsService{err: apperror.NotFound("courier not found", nil)}, log)
+	rr = httptest.NewRecorder()
+	handler.GetBalance(rr, httptest.NewRequest(http.MethodGet, "/api/couriers/"+courierID.String()+"/balance", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+}
+
+func TestEarningsHandler_GetStatement(t *testing.T) {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	courierID := uuid.New()
+	svc := &stubEarningsService{statement: &models.CourierStatement{CourierID: courierID}}
+	handler := NewEarningsHandler(svc, log)
+
+	rr := httptest.NewRecorder()
+	handler.GetStatement(rr, httptest.NewRequest(http.MethodGet, "/api/couriers/"+courierID.String()+"/statement?from=2024-05-01&to=2024-05-31&currency=EUR", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if svc.gotFrom.Format("2006-01-02") != "2024-05-01" || svc.gotTo.Format("2006-01-02 15:04") != "2024-05-31 23:59" || svc.gotCurrency != "EUR" {
+		t.Fatalf("unexpected period: %v - %v %s", svc.gotFrom, svc.gotTo, svc.gotCurrency)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.GetStatement(rr, httptest.NewRequest(http.MethodGet, "/api/couriers/"+courierID.String()+"/statement?from=2024-06-01&to=2024-05-01", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for inverted range, got %d", rr.Code)
+	}
+}
+
+func TestEarningsHandler_GetPayoutsCSV(t *testing.T) {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	svc := &stubEarningsService{payouts: []*models.PayoutLine{{
+		CourierID:   uuid.New(),
+		CourierName: "Иван",
+		Currency:    "RUB",
+		Deliveries:  2,
+		Earnings:    money.New(20000, "RUB"),
+		Tips:        money.New(3000, "RUB"),
+		Total:       money.New(23000, "RUB"),
+	}}}
+	handler := NewEarningsHandler(svc, log)
+
+	rr := httptest.NewRecorder()
+	handler.GetPayouts(rr, httptest.NewRequest(http.MethodGet, "/api/payouts?from=2024-05-01&to=2024-05-31&format=csv", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "text/csv" {
+		t.Fatalf("expected text/csv, got %s", ct)
+	}
+	if !strings.Contains(rr.Body.String(), "Иван,RUB,2,200.00,30.00,0.00,0.00,230.00") {
+		t.Fatalf("unexpected csv: %s", rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	handler.GetPayouts(rr, httptest.NewRequest(http.MethodGet, "/api/payouts?format=xml", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid format, got %d", rr.Code)
+	}
+}
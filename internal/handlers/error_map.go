@@ -15,6 +15,8 @@ func writeServiceError(w http.ResponseWriter, log *logger.Logger, err error, int
 		writeErrorResponse(w, http.StatusBadRequest, err.Error())
 	case apperror.Is(err, apperror.KindConflict):
 		writeErrorResponse(w, http.StatusConflict, err.Error())
+	case apperror.Is(err, apperror.KindForbidden):
+		writeErrorResponse(w, http.StatusForbidden, err.Error())
 	default:
 		if log != nil {
 			log.WithError(err).Error(internalMessage)
@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"context"
+	"io"
 	"time"
 
 	"delivery-system/internal/models"
+	"delivery-system/internal/pagination"
 
 	"github.com/google/uuid"
 )
@@ -14,10 +16,15 @@ import (
 type OrderService interface {
 	CreateOrder(ctx context.Context, req *models.CreateOrderRequest) (*models.Order, error)
 	GetOrder(ctx context.Context, orderID uuid.UUID) (*models.Order, error)
-	UpdateOrderStatus(ctx context.Context, orderID uuid.UUID, req *models.UpdateOrderStatusRequest) error
-	GetOrders(ctx context.Context, status *models.OrderStatus, courierID *uuid.UUID, limit, offset int) ([]*models.Order, error)
-	CreateReview(ctx context.Context, orderID uuid.UUID, req *models.CreateReviewRequest) (*models.Review, error)
-	GetCourierReviews(ctx context.Context, courierID uuid.UUID, limit, offset int) ([]*models.Review, error)
+	UpdateOrder(ctx context.Context, orderID uuid.UUID, req *models.UpdateOrderRequest) (*models.OrderUpdate, error)
+	UpdateOrderStatus(ctx context.Context, orderID uuid.UUID, req *models.UpdateOrderStatusRequest) (*models.OrderStatusUpdate, error)
+	GetOrderHistory(ctx context.Context, orderID uuid.UUID) (*models.OrderHistory, error)
+	LogContactAttempt(ctx context.Context, orderID uuid.UUID, req *models.LogContactAttemptRequest) (*models.ContactAttempt, error)
+	GetDeliveryAttempts(ctx context.Context, orderID uuid.UUID) (*models.DeliveryAttempts, error)
+	UnassignCourier(ctx context.Context, orderID uuid.UUID, reason string) (*models.CourierReassignment, error)
+	ReassignCourier(ctx context.Context, orderID, courierID uuid.UUID, reason string) (*models.CourierReassignment, error)
+	GetOrders(ctx context.Context, status *models.OrderStatus, courierID *uuid.UUID, page pagination.Request) (*pagination.Page[*models.Order], error)
+	SearchOrders(ctx context.Context, q *models.OrderSearchQuery) (*models.OrderSearchResult, error)
 }
 
 type AssignmentService interface {
@@ -31,9 +38,12 @@ type GeocodingService interface {
 type EventProducer interface {
 	PublishOrderCreated(order *models.Order) error
 	PublishOrderStatusChanged(orderID uuid.UUID, oldStatus, newStatus models.OrderStatus, courierID *uuid.UUID) error
+	PublishOrderUpdated(orderID uuid.UUID, changes []models.FieldChange) error
 	PublishCourierStatusChanged(courierID uuid.UUID, oldStatus, newStatus models.CourierStatus) error
 	PublishLocationUpdated(courierID uuid.UUID, lat, lon float64) error
 	PublishCourierAssigned(orderID, courierID uuid.UUID) error
+	PublishCourierUnassigned(orderID, courierID uuid.UUID, newCourierID *uuid.UUID, reason string) error
+	PublishReceiptIssued(receipt *models.Receipt) error
 }
 
 type RedisClient interface {
@@ -43,17 +53,73 @@ type RedisClient interface {
 	DeleteByPrefix(ctx context.Context, prefix string) error
 }
 
+// ----- Receipts -----
+
+type ReceiptService interface {
+	GetReceipt(ctx context.Context, orderID uuid.UUID) (*models.Receipt, error)
+	GetReceiptDocument(ctx context.Context, orderID uuid.UUID, format models.ReceiptFormat) ([]byte, error)
+}
+
+// ----- Delivery proofs -----
+
+type ProofService interface {
+	AttachProof(ctx context.Context, orderID uuid.UUID, proofType models.ProofType, contentType string, content io.Reader) (*models.DeliveryProof, error)
+	ListProofs(ctx context.Context, orderID uuid.UUID) ([]*models.DeliveryProof, error)
+	OpenProof(ctx context.Context, orderID, proofID uuid.UUID) (*models.DeliveryProof, io.ReadCloser, error)
+}
+
+// ----- Payments -----
+
+type PaymentService interface {
+	GetPayment(ctx context.Context, orderID uuid.UUID) (*models.Payment, error)
+	Refund(ctx context.Context, orderID uuid.UUID, req *models.RefundPaymentRequest) (*models.Payment, error)
+	HandleWebhook(ctx context.Context, event *models.PaymentWebhookEvent) error
+}
+
 // ----- Couriers -----
 
 type CourierService interface {
 	CreateCourier(ctx context.Context, req *models.CreateCourierRequest) (*models.Courier, error)
 	GetCourier(ctx context.Context, courierID uuid.UUID) (*models.Courier, error)
 	UpdateCourierStatus(ctx context.Context, courierID uuid.UUID, req *models.UpdateCourierStatusRequest) error
-	GetCouriers(ctx context.Context, status *models.CourierStatus, minRating *float64, limit, offset int, orderBy string) ([]*models.Courier, error)
+	GetCouriers(ctx context.Context, status *models.CourierStatus, onboarding *models.OnboardingStatus, minRating *float64, orderBy string, page pagination.Request) (*pagination.Page[*models.Courier], error)
 	GetAvailableCouriers(ctx context.Context) ([]*models.Courier, error)
 	AssignOrderToCourier(ctx context.Context, orderID, courierID uuid.UUID) error
 }
 
+type CourierOnboardingService interface {
+	UploadDocument(ctx context.Context, courierID uuid.UUID, docType models.DocumentType, contentType string, expiresAt *time.Time, content io.Reader) (*models.CourierDocument, error)
+	ListDocuments(ctx context.Context, courierID uuid.UUID) ([]*models.CourierDocument, error)
+	OpenDocument(ctx context.Context, courierID, documentID uuid.UUID) (*models.CourierDocument, io.ReadCloser, error)
+	ListExpiringDocuments(ctx context.Context, before time.Time) ([]*models.CourierDocument, error)
+	UpdateOnboarding(ctx context.Context, courierID uuid.UUID, req *models.UpdateOnboardingRequest) (*models.OnboardingChange, error)
+}
+
+type CourierPerformanceService interface {
+	GetPerformance(ctx context.Context, courierID uuid.UUID, historyLimit int) (*models.CourierPerformanceReport, error)
+}
+
+// ----- Reviews -----
+
+type ReviewService interface {
+	CreateReview(ctx context.Context, orderID uuid.UUID, req *models.CreateReviewRequest) (*models.Review, error)
+	UpdateReview(ctx context.Context, orderID uuid.UUID, req *models.UpdateReviewRequest) (*models.Review, error)
+	ReplyToReview(ctx context.Context, reviewID uuid.UUID, req *models.ReviewReplyRequest) (*models.Review, error)
+	ModerateReview(ctx context.Context, reviewID uuid.UUID, req *models.ModerateReviewRequest) (*models.Review, error)
+	GetCourierReviews(ctx context.Context, courierID uuid.UUID, page pagination.Request) (*pagination.Page[*models.Review], error)
+	ListReviews(ctx context.Context, status models.ReviewStatus, page pagination.Request) (*pagination.Page[*models.Review], error)
+}
+
+// ----- Courier earnings -----
+
+type EarningsService interface {
+	AddTip(ctx context.Context, orderID uuid.UUID, req *models.CreateTipRequest) (*models.LedgerTransaction, error)
+	CreateAdjustment(ctx context.Context, courierID uuid.UUID, req *models.CreateAdjustmentRequest) (*models.LedgerTransaction, error)
+	GetBalance(ctx context.Context, courierID uuid.UUID) ([]*models.CourierBalance, error)
+	GetStatement(ctx context.Context, courierID uuid.UUID, currency string, from, to time.Time) (*models.CourierStatement, error)
+	GetPayouts(ctx context.Context, from, to time.Time) ([]*models.PayoutLine, error)
+}
+
 // ----- Promo -----
 
 type PromoService interface {
@@ -61,7 +127,40 @@ type PromoService interface {
 	GetPromoCode(ctx context.Context, code string) (*models.PromoCode, error)
 	UpdatePromoCode(ctx context.Context, code string, req *models.UpdatePromoCodeRequest) (*models.PromoCode, error)
 	DeletePromoCode(ctx context.Context, code string) error
-	ListPromoCodes(ctx context.Context, limit, offset int) ([]*models.PromoCode, error)
+	ListPromoCodes(ctx context.Context, page pagination.Request) (*pagination.Page[*models.PromoCode], error)
+	ListRedemptions(ctx context.Context, code string, page pagination.Request) (*pagination.Page[*models.PromoRedemption], error)
+	PreviewPromo(ctx context.Context, code string, req *models.ValidatePromoRequest) (*models.PromoValidation, error)
+}
+
+// ----- Campaigns -----
+
+type CampaignService interface {
+	CreateCampaign(ctx context.Context, req *models.CreateCampaignRequest) (*models.Campaign, error)
+	GetCampaign(ctx context.Context, campaignID uuid.UUID) (*models.Campaign, error)
+	ListCampaigns(ctx context.Context, page pagination.Request) (*pagination.Page[*models.Campaign], error)
+	GenerateCodes(ctx context.Context, campaignID uuid.UUID, req *models.GenerateCodesRequest) (*models.GenerateCodesResult, error)
+	ListCampaignCodes(ctx context.Context, campaignID uuid.UUID) ([]*models.PromoCode, error)
+	DeactivateCampaign(ctx context.Context, campaignID uuid.UUID) (*models.Campaign, error)
+	GetCampaignStats(ctx context.Context, campaignID uuid.UUID) (*models.CampaignStats, error)
+}
+
+// ----- Referrals -----
+
+type ReferralService interface {
+	GetOrCreateCode(ctx context.Context, req *models.CreateReferralCodeRequest) (*models.ReferralCode, bool, error)
+	GetReferralCode(ctx context.Context, code string) (*models.ReferralCode, error)
+	ListReferrals(ctx context.Context, code string, page pagination.Request) (*pagination.Page[*models.Referral], error)
+}
+
+type WalletService interface {
+	GetWallet(ctx context.Context, customerPhone string, page pagination.Request) (*models.Wallet, error)
+}
+
+// ----- Loyalty -----
+
+type LoyaltyService interface {
+	GetAccount(ctx context.Context, customerPhone string) (*models.LoyaltyAccount, error)
+	ListHistory(ctx context.Context, customerPhone string, page pagination.Request) (*pagination.Page[*models.LoyaltyTransaction], error)
 }
 
 // ----- Analytics -----
@@ -69,6 +168,8 @@ type PromoService interface {
 type AnalyticsProvider interface {
 	GetKPIs(ctx context.Context, filter *models.AnalyticsFilter) (*models.KPIMetrics, error)
 	GetCourierAnalytics(ctx context.Context, filter *models.AnalyticsFilter) ([]*models.CourierAnalytics, error)
+	GetMerchantAnalytics(ctx context.Context, filter *models.AnalyticsFilter) ([]*models.MerchantAnalytics, error)
+	GetStageTimings(ctx context.Context, filter *models.AnalyticsFilter) (*models.StageTimings, error)
 }
 
 // ----- Health -----
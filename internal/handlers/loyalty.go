@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"net/http"
+
+	"delivery-system/internal/logger"
+)
+
+// LoyaltyHandler обрабатывает баланс и историю баллов лояльности.
+type LoyaltyHandler struct {
+	loyaltyService LoyaltyService
+	log            *logger.Logger
+}
+
+// NewLoyaltyHandler создает обработчик баллов лояльности.
+func NewLoyaltyHandler(loyaltyService LoyaltyService, log *logger.Logger) *LoyaltyHandler {
+	return &LoyaltyHandler{
+		loyaltyService: loyaltyService,
+		log:            log,
+	}
+}
+
+// GetAccount возвращает баланс баллов клиента и ближайшее сгорание.
+func (h *LoyaltyHandler) GetAccount(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	phone, err := extractPathSegment(r.URL.Path, "/api/loyalty/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	account, err := h.loyaltyService.GetAccount(r.Context(), phone)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to get loyalty account")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, account)
+}
+
+// GetHistory возвращает операции по баллам клиента: начисления, списания, сгорания и возвраты.
+func (h *LoyaltyHandler) GetHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	phone, err := extractPathSegment(r.URL.Path, "/api/loyalty/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := parsePageRequest(w, r, 200)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	history, err := h.loyaltyService.ListHistory(r.Context(), phone, page)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to get loyalty history")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, history)
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/config"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+	"delivery-system/internal/pagination"
+)
+
+type stubLoyaltyService struct {
+	gotPhone string
+	limit    int
+}
+
+func (s *stubLoyaltyService) GetAccount(ctx context.Context, customerPhone string) (*models.LoyaltyAccount, error) {
+	s.gotPhone = customerPhone
+	return &models.LoyaltyAccount{CustomerPhone: customerPhone, Balance: 120}, nil
+}
+func (s *stubLoyaltyService) ListHistory(ctx context.Context, customerPhone string, page pagination.Request) (*pagination.Page[*models.LoyaltyTransaction], error) {
+	s.gotPhone = customerPhone
+	s.limit = page.Limit
+	if customerPhone == "bad" {
+		return nil, apperror.Validation("customer phone is required", nil)
+	}
+	return &pagination.Page[*models.LoyaltyTransaction]{Items: []*models.LoyaltyTransaction{}}, nil
+}
+
+func TestLoyaltyHandler_GetAccount(t *testing.T) {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	svc := &stubLoyaltyService{}
+	handler := NewLoyaltyHandler(svc, log)
+
+	rr := httptest.NewRecorder()
+	handler.GetAccount(rr, httptest.NewRequest(http.MethodGet, "/api/loyalty/79990000002", nil))
+	if rr.Code != http.StatusOK || svc.gotPhone != "79990000002" || !strings.Contains(rr.Body.String(), `"balance":120`) {
+		t.Fatalf("expected 200 with balance, got %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestLoyaltyHandler_GetHistory(t *testing.T) {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	svc := &stubLoyaltyService{}
+	handler := NewLoyaltyHandler(svc, log)
+
+	rr := httptest.NewRecorder()
+	handler.GetHistory(rr, httptest.NewRequest(http.MethodGet, "/api/loyalty/79990000002/history?limit=20", nil))
+	if rr.Code != http.StatusOK || svc.gotPhone != "79990000002" || svc.limit != 20 {
+		t.Fatalf("expected 200, got %d (%s, %d)", rr.Code, svc.gotPhone, svc.limit)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.GetHistory(rr, httptest.NewRequest(http.MethodGet, "/api/loyalty/bad/history", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+}
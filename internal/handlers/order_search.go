@@ -0,0 +1,293 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"delivery-system/internal/models"
+	"delivery-system/internal/money"
+
+	"github.com/google/uuid"
+)
+
+// SearchOrders ищет заказы по запросу q (см. parseOrderSearchQuery) и возвращает страницу
+// результатов ({items, next_cursor}) с количеством заказов по статусам.
+func (h *OrderHandler) SearchOrders(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	search, err := parseOrderSearchQuery(r.URL.Query().Get("q"))
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if search.Page, err = parsePageRequest(w, r, 200); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := h.orderService.SearchOrders(r.Context(), search)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to search orders")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, result)
+}
+
+// parseOrderSearchQuery разбирает поисковый запрос. Запрос состоит из слов, разделенных пробелами;
+// значения с пробелами берутся в двойные кавычки. Слова вида field:value задают фильтры:
+//
+//	phone:7999          фрагмент номера (учитываются только цифры)
+//	name:иван           фрагмент имени клиента
+//	address:"ленина 5"  фрагмент адреса доставки
+//	merchant:пиццерия   фрагмент названия продавца
+//	promo:SALE10        примененный промокод
+//	status:ready,in_delivery
+//	courier:<uuid>
+//	currency:EUR
+//	created:2024-01-01..2024-01-31  даты включительно; любую границу можно опустить
+//	amount:100..500                 сумма заказа включительно; одно значение — точное совпадение
+//	sort:-created | created | -amount | amount | relevance
+//
+// Остальные слова ищутся полнотекстово по имени, адресам, продавцу и промокоду.
+func parseOrderSearchQuery(raw string) (*models.OrderSearchQuery, error) {
+	tokens, err := tokenizeSearchQuery(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &models.OrderSearchQuery{}
+	var text []string
+	sortSet := false
+
+	for _, token := range tokens {
+		field, value, ok := strings.Cut(token.value, ":")
+		if !ok || token.quoted {
+			text = append(text, token.value)
+			continue
+		}
+		if value == "" {
+			return nil, fmt.Errorf("empty value for search field %q", field)
+		}
+
+		switch strings.ToLower(field) {
+		case "phone":
+			q.Phone = normalizeDigits(value)
+			if q.Phone == "" {
+				return nil, fmt.Errorf("phone must contain digits")
+			}
+		case "name":
+			q.Name = value
+		case "address":
+			q.Address = value
+		case "merchant":
+			q.Merchant = value
+		case "promo":
+			q.PromoCode = value
+		case "status":
+			for _, s := range strings.Split(value, ",") {
+				status := models.OrderStatus(strings.TrimSpace(s))
+				if !status.IsValid() {
+					return nil, fmt.Errorf("invalid order status %q", s)
+				}
+				q.Statuses = append(q.Statuses, status)
+			}
+		case "courier":
+			id, err := uuid.Parse(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid courier ID")
+			}
+			q.CourierID = &id
+		case "currency":
+			currency := strings.ToUpper(value)
+			if !money.IsCurrencyCode(currency) {
+				return nil, fmt.Errorf("invalid currency %q", value)
+			}
+			q.Currency = currency
+		case "created":
+			if q.CreatedFrom, q.CreatedTo, err = parseDateRange(value); err != nil {
+				return nil, err
+			}
+		case "amount":
+			if q.AmountMin, q.AmountMax, err = parseAmountRange(value); err != nil {
+				return nil, err
+			}
+		case "sort":
+			if err := applySearchSort(q, value); err != nil {
+				return nil, err
+			}
+			sortSet = true
+		default:
+			return nil, fmt.Errorf("unknown search field %q", field)
+		}
+	}
+
+	q.Text = strings.Join(text, " ")
+	if !sortSet {
+		q.SortBy, q.SortDesc = models.OrderSearchSortCreatedAt, true
+		if q.Text != "" {
+			q.SortBy = models.OrderSearchSortRelevance
+		}
+	}
+	if q.SortBy == models.OrderSearchSortRelevance && q.Text == "" {
+		return nil, fmt.Errorf("sort by relevance requires search text")
+	}
+
+	return q, nil
+}
+
+// searchToken — слово запроса; quoted означает, что слово целиком было в кавычках.
+type searchToken struct {
+	value  string
+	quoted bool
+}
+
+// tokenizeSearchQuery делит запрос на слова с учетом двойных кавычек: `name:"анна мария" центр`.
+func tokenizeSearchQuery(raw string) ([]searchToken, error) {
+	var (
+		tokens  []searchToken
+		current strings.Builder
+		inQuote bool
+		quoted  bool
+		started bool
+	)
+	flush := func() {
+		if started {
+			tokens = append(tokens, searchToken{value: current.String(), quoted: quoted})
+		}
+		current.Reset()
+		quoted, started = false, false
+	}
+
+	for _, r := range raw {
+		switch {
+		case r == '"':
+			// Кавычка в начале слова делает его свободным текстом, внутри field:"..." — только группирует
+			if !started {
+				quoted = true
+			}
+			inQuote = !inQuote
+			started = true
+		case !inQuote && (r == ' ' || r == '\t' || r == '\n'):
+			flush()
+		default:
+			current.WriteRune(r)
+			started = true
+		}
+	}
+	if inQuote {
+		return nil, fmt.Errorf("unterminated quote in search query")
+	}
+	flush()
+	return tokens, nil
+}
+
+// parseDateRange разбирает "from..to", "from..", "..to" или одну дату. Даты — YYYY-MM-DD или RFC 3339;
+// верхняя граница включительно, поэтому для дат без времени сдвигается на начало следующего дня.
+func parseDateRange(value string) (*time.Time, *time.Time, error) {
+	fromStr, toStr, isRange := strings.Cut(value, "..")
+	if !isRange {
+		toStr = fromStr
+	}
+
+	var from, to *time.Time
+	if fromStr != "" {
+		t, _, err := parseSearchDate(fromStr)
+		if err != nil {
+			return nil, nil, err
+		}
+		from = &t
+	}
+	if toStr != "" {
+		t, dateOnly, err := parseSearchDate(toStr)
+		if err != nil {
+			return nil, nil, err
+		}
+		if dateOnly {
+			t = t.AddDate(0, 0, 1)
+		} else {
+			t = t.Add(time.Microsecond)
+		}
+		to = &t
+	}
+	if from == nil && to == nil {
+		return nil, nil, fmt.Errorf("created range is empty")
+	}
+	if from != nil && to != nil && !from.Before(*to) {
+		return nil, nil, fmt.Errorf("created range start must be before its end")
+	}
+	return from, to, nil
+}
+
+func parseSearchDate(value string) (time.Time, bool, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, true, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, false, nil
+	}
+	return time.Time{}, false, fmt.Errorf("invalid date %q, expected YYYY-MM-DD or RFC 3339", value)
+}
+
+// parseAmountRange разбирает "min..max", "min..", "..max" или одно значение суммы.
+func parseAmountRange(value string) (*money.Money, *money.Money, error) {
+	minStr, maxStr, isRange := strings.Cut(value, "..")
+	if !isRange {
+		maxStr = minStr
+	}
+
+	var minAmount, maxAmount *money.Money
+	if minStr != "" {
+		m, err := money.Parse(minStr, money.DefaultCurrency)
+		if err != nil || m.IsNegative() {
+			return nil, nil, fmt.Errorf("invalid amount %q", minStr)
+		}
+		minAmount = &m
+	}
+	if maxStr != "" {
+		m, err := money.Parse(maxStr, money.DefaultCurrency)
+		if err != nil || m.IsNegative() {
+			return nil, nil, fmt.Errorf("invalid amount %q", maxStr)
+		}
+		maxAmount = &m
+	}
+	if minAmount == nil && maxAmount == nil {
+		return nil, nil, fmt.Errorf("amount range is empty")
+	}
+	if minAmount != nil && maxAmount != nil && minAmount.Cmp(*maxAmount) > 0 {
+		return nil, nil, fmt.Errorf("amount range minimum exceeds maximum")
+	}
+	return minAmount, maxAmount, nil
+}
+
+func applySearchSort(q *models.OrderSearchQuery, value string) error {
+	desc := strings.HasPrefix(value, "-")
+	switch strings.TrimPrefix(value, "-") {
+	case "created":
+		q.SortBy = models.OrderSearchSortCreatedAt
+	case "amount":
+		q.SortBy = models.OrderSearchSortTotalAmount
+	case "relevance":
+		q.SortBy = models.OrderSearchSortRelevance
+	default:
+		return fmt.Errorf("invalid sort %q", value)
+	}
+	q.SortDesc = desc
+	return nil
+}
+
+// normalizeDigits оставляет в строке только цифры.
+func normalizeDigits(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"delivery-system/internal/config"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestParseOrderSearchQuery(t *testing.T) {
+	q, err := parseOrderSearchQuery(`ленина phone:"+7 (999)" name:"Анна Мария" status:ready,in_delivery promo:SALE10 merchant:пицца created:2024-01-01..2024-01-31 amount:100..500.50 currency:eur`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if q.Text != "ленина" || q.Phone != "7999" || q.Name != "Анна Мария" || q.PromoCode != "SALE10" || q.Merchant != "пицца" || q.Currency != "EUR" {
+		t.Fatalf("unexpected fields: %+v", q)
+	}
+	if len(q.Statuses) != 2 || q.Statuses[1] != models.OrderStatusInDelivery {
+		t.Fatalf("unexpected statuses: %v", q.Statuses)
+	}
+	if !q.CreatedFrom.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) || !q.CreatedTo.Equal(time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected created range: %v..%v", q.CreatedFrom, q.CreatedTo)
+	}
+	if q.AmountMin.Amount != 10000 || q.AmountMax.Amount != 50050 {
+		t.Fatalf("unexpected amount range: %v..%v", q.AmountMin, q.AmountMax)
+	}
+	if q.SortBy != models.OrderSearchSortRelevance {
+		t.Fatalf("expected relevance sort with free text, got %s", q.SortBy)
+	}
+}
+
+func TestParseOrderSearchQuery_Defaults(t *testing.T) {
+	q, err := parseOrderSearchQuery("")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if q.SortBy != models.OrderSearchSortCreatedAt || !q.SortDesc {
+		t.Fatalf("expected newest first, got %s desc=%v", q.SortBy, q.SortDesc)
+	}
+
+	q, err = parseOrderSearchQuery(`amount:..300 sort:-amount "red square"`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if q.AmountMin != nil || q.AmountMax == nil || q.SortBy != models.OrderSearchSortTotalAmount || !q.SortDesc || q.Text != "red square" {
+		t.Fatalf("unexpected query: %+v", q)
+	}
+}
+
+func TestParseOrderSearchQuery_Errors(t *testing.T) {
+	cases := []string{
+		"unknown:1",
+		"status:lost",
+		"phone:abc",
+		"created:2024-13-01",
+		"created:2024-02-01..2024-01-01",
+		"amount:500..100",
+		"amount:-1",
+		"courier:nope",
+		"currency:EURO",
+		"sort:relevance",
+		"sort:name",
+		`name:"unterminated`,
+		"name:",
+	}
+	for _, raw := range cases {
+		if _, err := parseOrderSearchQuery(raw); err == nil {
+			t.Errorf("expected error for %q", raw)
+		}
+	}
+}
+
+func TestOrderHandler_SearchOrders(t *testing.T) {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	svc := &stubOrderService{orders: []*models.Order{{ID: uuid.New()}}}
+	h := NewOrderHandler(svc, &stubAssignmentService{}, &stubGeocodingService{}, &stubReceiptService{}, &stubProducer{}, &stubRedis{}, log)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/orders/search?limit=10&q="+url.QueryEscape("phone:999 status:delivered"), nil)
+	rr := httptest.NewRecorder()
+	h.SearchOrders(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if svc.search == nil || svc.search.Phone != "999" || svc.search.Page.Limit != 10 {
+		t.Fatalf("unexpected search passed to service: %+v", svc.search)
+	}
+	var body map[string]interface{}
+	if err := json.Unmarshal(rr.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if _, ok := body["items"]; !ok || body["total"] != 1.0 {
+		t.Fatalf("expected items envelope with total, got %s", rr.Body.String())
+	}
+
+	// Устаревший offset без курсора передается сервису с заголовком Deprecation
+	req = httptest.NewRequest(http.MethodGet, "/api/orders/search?offset=20&q=name:anna", nil)
+	rr = httptest.NewRecorder()
+	h.SearchOrders(rr, req)
+	if rr.Code != http.StatusOK || svc.search.Page.Offset != 20 || rr.Header().Get("Deprecation") != "true" {
+		t.Fatalf("expected deprecated offset to pass through, got %d %+v", rr.Code, svc.search.Page)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/api/orders/search?cursor=bad&q=name:anna", nil)
+	rr = httptest.NewRecorder()
+	h.SearchOrders(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid cursor, got %d", rr.Code)
+	}
+}
+
+func TestOrderHandler_SearchOrders_BadQuery(t *testing.T) {
+	h := newTestOrderHandler(&models.Order{ID: uuid.New()})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/orders/search?q=status:lost", nil)
+	rr := httptest.NewRecorder()
+	h.SearchOrders(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/orders/search", nil)
+	rr = httptest.NewRecorder()
+	h.SearchOrders(rr, req)
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rr.Code)
+	}
+}
+
+func TestOrderHandler_SearchOrders_Error(t *testing.T) {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	h := NewOrderHandler(&stubOrderService{err: fmt.Errorf("fail")}, &stubAssignmentService{}, &stubGeocodingService{}, &stubReceiptService{}, &stubProducer{}, &stubRedisMissOrder{}, log)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/orders/search?q=anna", nil)
+	rr := httptest.NewRecorder()
+	h.SearchOrders(rr, req)
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", rr.Code)
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"strconv"
 
 	"delivery-system/internal/logger"
 	"delivery-system/internal/models"
@@ -20,17 +19,19 @@ type OrderHandler struct {
 	orderService      OrderService
 	assignmentService AssignmentService
 	geocodingService  GeocodingService
+	receiptService    ReceiptService
 	producer          EventProducer
 	redisClient       RedisClient
 	log               *logger.Logger
 }
 
 // NewOrderHandler создает новый обработчик заказов
-func NewOrderHandler(orderService OrderService, assignmentService AssignmentService, geocodingService GeocodingService, producer EventProducer, redisClient RedisClient, log *logger.Logger) *OrderHandler {
+func NewOrderHandler(orderService OrderService, assignmentService AssignmentService, geocodingService GeocodingService, receiptService ReceiptService, producer EventProducer, redisClient RedisClient, log *logger.Logger) *OrderHandler {
 	return &OrderHandler{
 		orderService:      orderService,
 		assignmentService: assignmentService,
 		geocodingService:  geocodingService,
+		receiptService:    receiptService,
 		producer:          producer,
 		redisClient:       redisClient,
 		log:               log,
@@ -50,6 +51,10 @@ func (h *OrderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if req.DeviceID == "" {
+		req.DeviceID = r.Header.Get("X-Device-ID")
+	}
+
 	// Валидация запроса
 	if err := h.validateCreateOrderRequest(&req); err != nil {
 		writeErrorResponse(w, http.StatusBadRequest, err.Error())
@@ -90,9 +95,11 @@ func (h *OrderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 		// Не возвращаем ошибку клиенту, так как заказ уже создан
 	}
 
-	// Кеширование заказа в Redis
+	// Кеширование заказа в Redis (без PIN: он показывается только клиенту при создании)
 	cacheKey := redis.GenerateKey(redis.KeyPrefixOrder, order.ID.String())
-	if err := h.redisClient.Set(r.Context(), cacheKey, order, defaultCacheTTL); err != nil {
+	cachedOrder := *order
+	cachedOrder.HandoffPIN = nil
+	if err := h.redisClient.Set(r.Context(), cacheKey, &cachedOrder, defaultCacheTTL); err != nil {
 		h.log.WithError(err).Error("Failed to cache order")
 		// Не возвращаем ошибку клиенту
 	}
@@ -109,6 +116,7 @@ func (h *OrderHandler) CreateOrder(w http.ResponseWriter, r *http.Request) {
 			// Обновляем заказ из БД, чтобы вернуть актуальный статус/курьера
 			updatedOrder, getErr := h.orderService.GetOrder(r.Context(), order.ID)
 			if getErr == nil {
+				updatedOrder.HandoffPIN = order.HandoffPIN
 				order = updatedOrder
 			} else {
 				h.log.WithError(getErr).WithField("order_id", order.ID).Warn("Failed to reload order after auto-assign")
@@ -184,6 +192,135 @@ func (h *OrderHandler) GetOrder(w http.ResponseWriter, r *http.Request) {
 	writeJSONResponse(w, http.StatusOK, orderPtr)
 }
 
+// GetOrderHistory возвращает хронологию статусов заказа с длительностями этапов
+func (h *OrderHandler) GetOrderHistory(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	orderID, err := extractUUIDFromPath(r.URL.Path, "/api/orders/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	history, err := h.orderService.GetOrderHistory(r.Context(), orderID)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to get order history")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, history)
+}
+
+// LogContactAttempt записывает попытку курьера связаться с клиентом во время доставки
+func (h *OrderHandler) LogContactAttempt(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	orderID, err := extractUUIDFromPath(r.URL.Path, "/api/orders/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	var req models.LogContactAttemptRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	contact, err := h.orderService.LogContactAttempt(r.Context(), orderID, &req)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to log contact attempt")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusCreated, contact)
+}
+
+// GetDeliveryAttempts возвращает неудачные попытки вручения и журнал связи с клиентом
+func (h *OrderHandler) GetDeliveryAttempts(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	orderID, err := extractUUIDFromPath(r.URL.Path, "/api/orders/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	attempts, err := h.orderService.GetDeliveryAttempts(r.Context(), orderID)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to get delivery attempts")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, attempts)
+}
+
+// UpdateOrder изменяет получателя, адрес доставки или состав заказа до начала сборки
+func (h *OrderHandler) UpdateOrder(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	orderID, err := extractUUIDFromPath(r.URL.Path, "/api/orders/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	var req models.UpdateOrderRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if err := h.validateUpdateOrderRequest(&req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	// Новый адрес без координат геокодируется так же, как при создании заказа
+	if req.DeliveryAddress != nil && req.DeliveryLat == nil {
+		lat, lon, err := h.geocodingService.Geocode(r.Context(), *req.DeliveryAddress)
+		if err != nil {
+			writeErrorResponse(w, http.StatusBadRequest, "Failed to geocode delivery address")
+			return
+		}
+		req.DeliveryLat = &lat
+		req.DeliveryLon = &lon
+	}
+
+	update, err := h.orderService.UpdateOrder(r.Context(), orderID, &req)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to update order")
+		return
+	}
+
+	// Публикация изменений по полям (best effort)
+	if len(update.Changes) > 0 {
+		if err := h.producer.PublishOrderUpdated(orderID, update.Changes); err != nil {
+			h.log.WithError(err).Error("Failed to publish order updated event")
+		}
+	}
+
+	// Инвалидация кеша
+	cacheKey := redis.GenerateKey(redis.KeyPrefixOrder, orderID.String())
+	if err := h.redisClient.Delete(r.Context(), cacheKey); err != nil {
+		h.log.WithError(err).Error("Failed to invalidate order cache")
+	}
+
+	h.log.WithField("order_id", orderID).WithField("changes", len(update.Changes)).Info("Order updated")
+	writeJSONResponse(w, http.StatusOK, update)
+}
+
 // UpdateOrderStatus обновляет статус заказа
 func (h *OrderHandler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPut {
@@ -203,24 +340,32 @@ func (h *OrderHandler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	// Получение текущего заказа для определения старого статуса
-	currentOrder, err := h.orderService.GetOrder(r.Context(), orderID)
+	// Обновление статуса; прежний статус сервис читает под блокировкой строки заказа
+	update, err := h.orderService.UpdateOrderStatus(r.Context(), orderID, &req)
 	if err != nil {
-		writeServiceError(w, h.log, err, "Failed to get order")
+		writeServiceError(w, h.log, err, "Failed to update order status")
 		return
 	}
+	oldStatus := update.OldStatus
 
-	oldStatus := currentOrder.Status
+	// Публикация события изменения статуса: курьер — тот, что закреплен за заказом после перехода,
+	// а не необязательное поле запроса, иначе подписчики не узнают, кто доставил или отменил заказ
+	if err := h.producer.PublishOrderStatusChanged(orderID, oldStatus, req.Status, update.CourierID); err != nil {
+		h.log.WithError(err).Error("Failed to publish order status changed event")
+	}
 
-	// Обновление статуса
-	if err := h.orderService.UpdateOrderStatus(r.Context(), orderID, &req); err != nil {
-		writeServiceError(w, h.log, err, "Failed to update order status")
-		return
+	// Курьер без других активных заказов освобожден в той же транзакции
+	if update.ReleasedCourierID != nil {
+		if err := h.producer.PublishCourierStatusChanged(*update.ReleasedCourierID, models.CourierStatusBusy, models.CourierStatusAvailable); err != nil {
+			h.log.WithError(err).Error("Failed to publish courier status changed event")
+		}
+		courierCacheKey := redis.GenerateKey(redis.KeyPrefixCourier, update.ReleasedCourierID.String())
+		_ = h.redisClient.Delete(r.Context(), courierCacheKey)
 	}
 
-	// Публикация события изменения статуса
-	if err := h.producer.PublishOrderStatusChanged(orderID, oldStatus, req.Status, req.CourierID); err != nil {
-		h.log.WithError(err).Error("Failed to publish order status changed event")
+	// Чек формируется сервисом при переводе в delivered, здесь только публикуется событие
+	if req.Status == models.OrderStatusDelivered && oldStatus != models.OrderStatusDelivered {
+		h.publishReceiptIssued(r.Context(), orderID)
 	}
 
 	// Инвалидация кеша
@@ -264,69 +409,19 @@ func (h *OrderHandler) GetOrders(w http.ResponseWriter, r *http.Request) {
 		courierID = &id
 	}
 
-	limit := 50 // По умолчанию
-	if limitStr := query.Get("limit"); limitStr != "" {
-		if l, err := strconv.Atoi(limitStr); err == nil && l > 0 && l <= 100 {
-			limit = l
-		}
-	}
-
-	offset := 0
-	if offsetStr := query.Get("offset"); offsetStr != "" {
-		if o, err := strconv.Atoi(offsetStr); err == nil && o >= 0 {
-			offset = o
-		}
-	}
-
-	orders, err := h.orderService.GetOrders(r.Context(), status, courierID, limit, offset)
-	if err != nil {
-		h.log.WithError(err).Error("Failed to get orders")
-		writeErrorResponse(w, http.StatusInternalServerError, "Failed to get orders")
-		return
-	}
-
-	writeJSONResponse(w, http.StatusOK, orders)
-}
-
-// CreateReview создает отзыв по доставленному заказу
-func (h *OrderHandler) CreateReview(w http.ResponseWriter, r *http.Request) {
-	if r.Method != http.MethodPost {
-		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
-		return
-	}
-
-	orderID, err := extractUUIDFromPath(r.URL.Path, "/api/orders/")
+	page, err := parsePageRequest(w, r, 100)
 	if err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, "Invalid order ID")
-		return
-	}
-
-	var req models.CreateReviewRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	review, err := h.orderService.CreateReview(r.Context(), orderID, &req)
+	orders, err := h.orderService.GetOrders(r.Context(), status, courierID, page)
 	if err != nil {
-		writeServiceError(w, h.log, err, "Failed to create review")
+		writeServiceError(w, h.log, err, "Failed to get orders")
 		return
 	}
 
-	// Инвалидация кеша заказа
-	cacheKey := redis.GenerateKey(redis.KeyPrefixOrder, orderID.String())
-	_ = h.redisClient.Delete(r.Context(), cacheKey)
-
-	// Инвалидация кеша курьера
-	courierCacheKey := redis.GenerateKey(redis.KeyPrefixCourier, review.CourierID.String())
-	_ = h.redisClient.Delete(r.Context(), courierCacheKey)
-
-	// Инвалидация кеша аналитики (best effort) — влияет на рейтинг/метрики курьера
-	if err := h.invalidateStatsCache(r.Context()); err != nil {
-		h.log.WithError(err).Warn("Failed to invalidate analytics cache")
-	}
-
-	writeJSONResponse(w, http.StatusCreated, review)
+	writeJSONResponse(w, http.StatusOK, orders)
 }
 
 // invalidateStatsCache очищает кеш аналитики (best effort)
@@ -338,6 +433,21 @@ func (h *OrderHandler) invalidateStatsCache(ctx context.Context) error {
 	return h.redisClient.DeleteByPrefix(ctx, prefix)
 }
 
+// publishReceiptIssued публикует событие выдачи чека (best effort)
+func (h *OrderHandler) publishReceiptIssued(ctx context.Context, orderID uuid.UUID) {
+	if h.receiptService == nil {
+		return
+	}
+	receipt, err := h.receiptService.GetReceipt(ctx, orderID)
+	if err != nil {
+		h.log.WithError(err).WithField("order_id", orderID).Error("Failed to load issued receipt")
+		return
+	}
+	if err := h.producer.PublishReceiptIssued(receipt); err != nil {
+		h.log.WithError(err).Error("Failed to publish receipt issued event")
+	}
+}
+
 // validateCreateOrderRequest валидирует запрос на создание заказа
 func (h *OrderHandler) validateCreateOrderRequest(req *models.CreateOrderRequest) error {
 	if req.CustomerName == "" {
@@ -358,18 +468,27 @@ func (h *OrderHandler) validateCreateOrderRequest(req *models.CreateOrderRequest
 	if req.PromoCode != nil && len(*req.PromoCode) > 64 {
 		return fmt.Errorf("promo code is too long")
 	}
-
-	for i, item := range req.Items {
-		if item.Name == "" {
-			return fmt.Errorf("item %d: name is required", i+1)
-		}
-		if item.Quantity <= 0 {
-			return fmt.Errorf("item %d: quantity must be positive", i+1)
-		}
-		if item.Price < 0 {
-			return fmt.Errorf("item %d: price cannot be negative", i+1)
+	for _, code := range req.PromoCodes {
+		if len(code) > 64 {
+			return fmt.Errorf("promo code is too long")
 		}
 	}
+	if req.ReferralCode != nil && len(*req.ReferralCode) > 64 {
+		return fmt.Errorf("referral code is too long")
+	}
+	if len(req.MerchantName) > 255 {
+		return fmt.Errorf("merchant name is too long")
+	}
+	if len(req.DeviceID) > 128 {
+		return fmt.Errorf("device id is too long")
+	}
+	if req.RedeemPoints < 0 {
+		return fmt.Errorf("redeem_points must not be negative")
+	}
+
+	if err := validateOrderItems(req.Items); err != nil {
+		return err
+	}
 
 	// Координаты: если указаны, валидируем; если нет — будут геокодированы позже
 	if req.PickupLat != nil {
@@ -397,6 +516,54 @@ func (h *OrderHandler) validateCreateOrderRequest(req *models.CreateOrderRequest
 	return nil
 }
 
+// validateUpdateOrderRequest проверяет изменение заказа; пустые поля означают «не менять»
+func (h *OrderHandler) validateUpdateOrderRequest(req *models.UpdateOrderRequest) error {
+	if req.CustomerName != nil && *req.CustomerName == "" {
+		return fmt.Errorf("customer name must not be empty")
+	}
+	if req.DeliveryAddress != nil && *req.DeliveryAddress == "" {
+		return fmt.Errorf("delivery address must not be empty")
+	}
+	if req.Items != nil && len(req.Items) == 0 {
+		return fmt.Errorf("order items must not be empty")
+	}
+	if err := validateOrderItems(req.Items); err != nil {
+		return err
+	}
+	if (req.DeliveryLat == nil) != (req.DeliveryLon == nil) {
+		return fmt.Errorf("delivery_lat and delivery_lon must be set together")
+	}
+	if req.DeliveryLat != nil && (*req.DeliveryLat < -90 || *req.DeliveryLat > 90) {
+		return fmt.Errorf("delivery_lat must be between -90 and 90")
+	}
+	if req.DeliveryLon != nil && (*req.DeliveryLon < -180 || *req.DeliveryLon > 180) {
+		return fmt.Errorf("delivery_lon must be between -180 and 180")
+	}
+	return nil
+}
+
+// validateOrderItems проверяет товары заказа
+func validateOrderItems(items []models.CreateOrderItemRequest) error {
+	for i, item := range items {
+		if item.Name == "" {
+			return fmt.Errorf("item %d: name is required", i+1)
+		}
+		if item.Quantity <= 0 {
+			return fmt.Errorf("item %d: quantity must be positive", i+1)
+		}
+		if item.Price.IsNegative() {
+			return fmt.Errorf("item %d: price cannot be negative", i+1)
+		}
+		if item.TaxCategory != "" && !item.TaxCategory.IsValid() {
+			return fmt.Errorf("item %d: invalid tax category", i+1)
+		}
+		if item.WeightKg < 0 || item.VolumeL < 0 {
+			return fmt.Errorf("item %d: weight and volume cannot be negative", i+1)
+		}
+	}
+	return nil
+}
+
 // AutoAssignCourierRequest представляет запрос на автоназначение курьера
 type AutoAssignCourierRequest struct {
 	DeliveryLat *float64 `json:"delivery_lat,omitempty"`
@@ -489,4 +656,105 @@ func (h *OrderHandler) AutoAssignCourier(w http.ResponseWriter, r *http.Request)
 	writeJSONResponse(w, http.StatusOK, courier)
 }
 
+// UnassignCourier снимает курьера с заказа по решению диспетчера; заказ возвращается в created
+func (h *OrderHandler) UnassignCourier(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	orderID, err := extractUUIDFromPath(r.URL.Path, "/api/orders/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	var req models.UnassignCourierRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	reassignment, err := h.orderService.UnassignCourier(r.Context(), orderID, req.Reason)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to unassign courier")
+		return
+	}
+
+	h.publishReassignment(r.Context(), reassignment)
+	writeJSONResponse(w, http.StatusOK, reassignment)
+}
+
+// ReassignCourier передает заказ другому курьеру по решению диспетчера
+func (h *OrderHandler) ReassignCourier(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	orderID, err := extractUUIDFromPath(r.URL.Path, "/api/orders/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	var req models.ReassignCourierRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.CourierID == uuid.Nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Courier ID is required")
+		return
+	}
+
+	reassignment, err := h.orderService.ReassignCourier(r.Context(), orderID, req.CourierID, req.Reason)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to reassign courier")
+		return
+	}
+
+	h.publishReassignment(r.Context(), reassignment)
+	writeJSONResponse(w, http.StatusOK, reassignment)
+}
+
+// publishReassignment публикует события снятия/назначения курьера (best effort) и сбрасывает кеш
+func (h *OrderHandler) publishReassignment(ctx context.Context, ra *models.CourierReassignment) {
+	if err := h.producer.PublishCourierUnassigned(ra.OrderID, ra.PreviousCourierID, ra.CourierID, ra.Reason); err != nil {
+		h.log.WithError(err).Error("Failed to publish courier unassigned event")
+	}
+	if ra.PreviousCourierReleased {
+		if err := h.producer.PublishCourierStatusChanged(ra.PreviousCourierID, models.CourierStatusBusy, models.CourierStatusAvailable); err != nil {
+			h.log.WithError(err).Error("Failed to publish courier status changed event")
+		}
+	}
+	if ra.CourierID != nil {
+		if err := h.producer.PublishCourierAssigned(ra.OrderID, *ra.CourierID); err != nil {
+			h.log.WithError(err).Error("Failed to publish courier assigned event")
+		}
+		if err := h.producer.PublishCourierStatusChanged(*ra.CourierID, models.CourierStatusAvailable, models.CourierStatusBusy); err != nil {
+			h.log.WithError(err).Error("Failed to publish courier status changed event")
+		}
+	}
+	if ra.OldStatus != ra.Status {
+		if err := h.producer.PublishOrderStatusChanged(ra.OrderID, ra.OldStatus, ra.Status, ra.CourierID); err != nil {
+			h.log.WithError(err).Error("Failed to publish order status changed event")
+		}
+	}
+
+	// Инвалидация кеша заказа и обоих курьеров
+	_ = h.redisClient.Delete(ctx, redis.GenerateKey(redis.KeyPrefixOrder, ra.OrderID.String()))
+	_ = h.redisClient.Delete(ctx, redis.GenerateKey(redis.KeyPrefixCourier, ra.PreviousCourierID.String()))
+	if ra.CourierID != nil {
+		_ = h.redisClient.Delete(ctx, redis.GenerateKey(redis.KeyPrefixCourier, ra.CourierID.String()))
+	}
+
+	h.log.WithFields(map[string]interface{}{
+		"order_id":            ra.OrderID,
+		"previous_courier_id": ra.PreviousCourierID,
+		"courier_id":          ra.CourierID,
+		"reason":              ra.Reason,
+	}).Info("Order courier reassigned")
+}
+
 // Интерфейсы вынесены в interfaces.go
@@ -3,6 +3,7 @@ package handlers
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -13,6 +14,7 @@ import (
 	"delivery-system/internal/config"
 	"delivery-system/internal/logger"
 	"delivery-system/internal/models"
+	"delivery-system/internal/pagination"
 
 	"github.com/google/uuid"
 )
@@ -20,9 +22,18 @@ import (
 type stubOrderService struct {
 	order        *models.Order
 	orders       []*models.Order
-	review       *models.Review
 	err          error
 	statusCalled bool
+	search       *models.OrderSearchQuery
+	history      *models.OrderHistory
+	contact      *models.LogContactAttemptRequest
+	attempts     *models.DeliveryAttempts
+	update       *models.OrderUpdate
+	updateReq    *models.UpdateOrderRequest
+	reassignment *models.CourierReassignment
+	reason       string
+	released     *uuid.UUID
+	courierID    *uuid.UUID
 }
 
 func (s *stubOrderService) CreateOrder(ctx context.Context, req *models.CreateOrderRequest) (*models.Order, error) {
@@ -31,18 +42,52 @@ func (s *stubOrderService) CreateOrder(ctx context.Context, req *models.CreateOr
 func (s *stubOrderService) GetOrder(ctx context.Context, orderID uuid.UUID) (*models.Order, error) {
 	return s.order, s.err
 }
-func (s *stubOrderService) UpdateOrderStatus(ctx context.Context, orderID uuid.UUID, req *models.UpdateOrderStatusRequest) error {
+func (s *stubOrderService) UpdateOrder(ctx context.Context, orderID uuid.UUID, req *models.UpdateOrderRequest) (*models.OrderUpdate, error) {
+	s.updateReq = req
+	return s.update, s.err
+}
+func (s *stubOrderService) UpdateOrderStatus(ctx context.Context, orderID uuid.UUID, req *models.UpdateOrderStatusRequest) (*models.OrderStatusUpdate, error) {
 	s.statusCalled = true
-	return s.err
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &models.OrderStatusUpdate{OrderID: orderID, OldStatus: models.OrderStatusInDelivery, Status: req.Status, CourierID: s.courierID, ReleasedCourierID: s.released}, nil
+}
+func (s *stubOrderService) GetOrderHistory(ctx context.Context, orderID uuid.UUID) (*models.OrderHistory, error) {
+	return s.history, s.err
+}
+func (s *stubOrderService) LogContactAttempt(ctx context.Context, orderID uuid.UUID, req *models.LogContactAttemptRequest) (*models.ContactAttempt, error) {
+	s.contact = req
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &models.ContactAttempt{ID: uuid.New(), OrderID: orderID, Attempt: 1, Channel: req.Channel, Result: req.Result}, nil
+}
+func (s *stubOrderService) GetDeliveryAttempts(ctx context.Context, orderID uuid.UUID) (*models.DeliveryAttempts, error) {
+	return s.attempts, s.err
 }
-func (s *stubOrderService) GetOrders(ctx context.Context, status *models.OrderStatus, courierID *uuid.UUID, limit, offset int) ([]*models.Order, error) {
-	return s.orders, s.err
+func (s *stubOrderService) UnassignCourier(ctx context.Context, orderID uuid.UUID, reason string) (*models.CourierReassignment, error) {
+	s.reason = reason
+	return s.reassignment, s.err
 }
-func (s *stubOrderService) CreateReview(ctx context.Context, orderID uuid.UUID, req *models.CreateReviewRequest) (*models.Review, error) {
-	return s.review, s.err
+func (s *stubOrderService) ReassignCourier(ctx context.Context, orderID, courierID uuid.UUID, reason string) (*models.CourierReassignment, error) {
+	s.reason = reason
+	return s.reassignment, s.err
 }
-func (s *stubOrderService) GetCourierReviews(ctx context.Context, courierID uuid.UUID, limit, offset int) ([]*models.Review, error) {
-	return []*models.Review{}, s.err
+func (s *stubOrderService) GetOrders(ctx context.Context, status *models.OrderStatus, courierID *uuid.UUID, page pagination.Request) (*pagination.Page[*models.Order], error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return pagination.NewPage(s.orders, page.Limit, func(o *models.Order) pagination.Cursor {
+		return pagination.Cursor{Sort: "created_at", CreatedAt: o.CreatedAt, ID: o.ID.String()}
+	}), nil
+}
+func (s *stubOrderService) SearchOrders(ctx context.Context, q *models.OrderSearchQuery) (*models.OrderSearchResult, error) {
+	s.search = q
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &models.OrderSearchResult{Page: pagination.Page[*models.Order]{Items: s.orders}, Total: len(s.orders), Facets: map[models.OrderStatus]int{}}, nil
 }
 
 type stubAssignmentService struct {
@@ -65,6 +110,13 @@ func (s *stubGeocodingService) Geocode(ctx context.Context, address string) (flo
 type stubProducer struct {
 	created bool
 	status  bool
+	receipt bool
+	changes []models.FieldChange
+
+	assigned       int
+	unassigned     int
+	courierChanges int
+	statusCourier  *uuid.UUID
 }
 
 func (p *stubProducer) PublishOrderCreated(order *models.Order) error {
@@ -73,15 +125,30 @@ func (p *stubProducer) PublishOrderCreated(order *models.Order) error {
 }
 func (p *stubProducer) PublishOrderStatusChanged(orderID uuid.UUID, oldStatus, newStatus models.OrderStatus, courierID *uuid.UUID) error {
 	p.status = true
+	p.statusCourier = courierID
+	return nil
+}
+func (p *stubProducer) PublishOrderUpdated(orderID uuid.UUID, changes []models.FieldChange) error {
+	p.changes = changes
 	return nil
 }
 func (p *stubProducer) PublishCourierStatusChanged(courierID uuid.UUID, oldStatus, newStatus models.CourierStatus) error {
+	p.courierChanges++
 	return nil
 }
 func (p *stubProducer) PublishLocationUpdated(courierID uuid.UUID, lat, lon float64) error {
 	return nil
 }
 func (p *stubProducer) PublishCourierAssigned(orderID, courierID uuid.UUID) error {
+	p.assigned++
+	return nil
+}
+func (p *stubProducer) PublishCourierUnassigned(orderID, courierID uuid.UUID, newCourierID *uuid.UUID, reason string) error {
+	p.unassigned++
+	return nil
+}
+func (p *stubProducer) PublishReceiptIssued(receipt *models.Receipt) error {
+	p.receipt = true
 	return nil
 }
 
@@ -120,6 +187,7 @@ func newTestOrderHandler(order *models.Order) *OrderHandler {
 		&stubOrderService{order: order, orders: []*models.Order{order}},
 		&stubAssignmentService{courier: &models.Courier{ID: uuid.New(), Name: "c"}},
 		&stubGeocodingService{},
+		&stubReceiptService{},
 		&stubProducer{},
 		&stubRedis{},
 		log,
@@ -171,7 +239,7 @@ func TestOrderHandler_CreateOrder_MethodNotAllowed(t *testing.T) {
 
 func TestOrderHandler_CreateOrder_ServiceError(t *testing.T) {
 	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
-	h := NewOrderHandler(&stubOrderService{err: fmt.Errorf("fail")}, &stubAssignmentService{}, &stubGeocodingService{}, &stubProducer{}, &stubRedisMissOrder{}, log)
+	h := NewOrderHandler(&stubOrderService{err: fmt.Errorf("fail")}, &stubAssignmentService{}, &stubGeocodingService{}, &stubReceiptService{}, &stubProducer{}, &stubRedisMissOrder{}, log)
 	body := `{"customer_name":"Test","customer_phone":"+7999","delivery_address":"addr","pickup_address":"p","pickup_lat":1,"pickup_lon":1,"delivery_lat":2,"delivery_lon":2,"items":[{"name":"x","quantity":1,"price":1}]}`
 	req := httptest.NewRequest(http.MethodPost, "/api/orders", bytes.NewBufferString(body))
 	rr := httptest.NewRecorder()
@@ -206,7 +274,7 @@ func TestOrderHandler_GetOrder_InvalidID(t *testing.T) {
 
 func TestOrderHandler_GetOrder_Error(t *testing.T) {
 	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
-	h := NewOrderHandler(&stubOrderService{err: fmt.Errorf("fail")}, &stubAssignmentService{}, &stubGeocodingService{}, &stubProducer{}, &stubRedisMissOrder{}, log)
+	h := NewOrderHandler(&stubOrderService{err: fmt.Errorf("fail")}, &stubAssignmentService{}, &stubGeocodingService{}, &stubReceiptService{}, &stubProducer{}, &stubRedisMissOrder{}, log)
 	req := httptest.NewRequest(http.MethodGet, "/api/orders/"+uuid.New().String(), nil)
 	rr := httptest.NewRecorder()
 	h.GetOrder(rr, req)
@@ -215,44 +283,179 @@ func TestOrderHandler_GetOrder_Error(t *testing.T) {
 	}
 }
 
-func TestOrderHandler_CreateReview(t *testing.T) {
+func TestOrderHandler_GetOrderHistory(t *testing.T) {
 	orderID := uuid.New()
-	order := &models.Order{ID: orderID}
 	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
-	orderService := &stubOrderService{order: order, review: &models.Review{ID: uuid.New(), Rating: 5}}
-	h := NewOrderHandler(orderService, &stubAssignmentService{}, &stubGeocodingService{}, &stubProducer{}, &stubRedis{}, log)
-
-	body := bytes.NewBufferString(`{"rating":5,"comment":"ok"}`)
-	req := httptest.NewRequest(http.MethodPost, "/api/orders/"+orderID.String()+"/review", body)
+	seconds := 90.0
+	orderService := &stubOrderService{history: &models.OrderHistory{
+		OrderID: orderID,
+		Status:  models.OrderStatusAccepted,
+		Timeline: []models.OrderStatusChange{
+			{NewStatus: models.OrderStatusCreated, ChangedBy: "user:anonymous", DurationSeconds: &seconds},
+			{NewStatus: models.OrderStatusAccepted, ChangedBy: "system:auto_assign"},
+		},
+		TotalSeconds: seconds,
+	}}
+	h := NewOrderHandler(orderService, &stubAssignmentService{}, &stubGeocodingService{}, &stubReceiptService{}, &stubProducer{}, &stubRedis{}, log)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/orders/"+orderID.String()+"/history", nil)
 	rr := httptest.NewRecorder()
+	h.GetOrderHistory(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
 
-	h.CreateReview(rr, req)
-	if rr.Code != http.StatusCreated {
-		t.Fatalf("expected 201, got %d", rr.Code)
+	var resp models.OrderHistory
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resp.Timeline) != 2 || resp.Timeline[1].ChangedBy != "system:auto_assign" || resp.Timeline[1].DurationSeconds != nil {
+		t.Fatalf("unexpected history: %+v", resp)
+	}
+
+	h = NewOrderHandler(&stubOrderService{err: apperror.NotFound("order not found", nil)}, &stubAssignmentService{}, &stubGeocodingService{}, &stubReceiptService{}, &stubProducer{}, &stubRedis{}, log)
+	rr = httptest.NewRecorder()
+	h.GetOrderHistory(rr, httptest.NewRequest(http.MethodGet, "/api/orders/"+orderID.String()+"/history", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
 	}
 }
 
-func TestOrderHandler_CreateReview_MethodNotAllowed(t *testing.T) {
-	h := newTestOrderHandler(&models.Order{ID: uuid.New()})
-	req := httptest.NewRequest(http.MethodGet, "/api/orders/"+uuid.New().String()+"/review", nil)
+func TestOrderHandler_ContactAttempts(t *testing.T) {
+	orderID := uuid.New()
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	orderService := &stubOrderService{attempts: &models.DeliveryAttempts{
+		OrderID:     orderID,
+		Status:      models.OrderStatusDeliveryFailed,
+		Failed:      1,
+		MaxAttempts: 2,
+		Contacts:    []models.ContactAttempt{{Attempt: 1, Channel: models.ContactChannelCall, Result: models.ContactResultNoAnswer}},
+	}}
+	h := NewOrderHandler(orderService, &stubAssignmentService{}, &stubGeocodingService{}, &stubReceiptService{}, &stubProducer{}, &stubRedis{}, log)
+
+	body := bytes.NewBufferString(`{"channel":"call","result":"no_answer","note":"домофон не работает"}`)
 	rr := httptest.NewRecorder()
-	h.CreateReview(rr, req)
-	if rr.Code != http.StatusMethodNotAllowed {
-		t.Fatalf("expected 405, got %d", rr.Code)
+	h.LogContactAttempt(rr, httptest.NewRequest(http.MethodPost, "/api/orders/"+orderID.String()+"/contact-attempts", body))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rr.Code)
+	}
+	if orderService.contact == nil || orderService.contact.Channel != models.ContactChannelCall || orderService.contact.Note == nil {
+		t.Fatalf("unexpected request passed to service: %+v", orderService.contact)
+	}
+
+	rr = httptest.NewRecorder()
+	h.GetDeliveryAttempts(rr, httptest.NewRequest(http.MethodGet, "/api/orders/"+orderID.String()+"/contact-attempts", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	var resp models.DeliveryAttempts
+	if err := json.NewDecoder(rr.Body).Decode(&resp); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if resp.Failed != 1 || resp.MaxAttempts != 2 || len(resp.Contacts) != 1 {
+		t.Fatalf("unexpected attempts: %+v", resp)
+	}
+
+	h = NewOrderHandler(&stubOrderService{err: apperror.Conflict("contact attempts can only be logged while the order is in delivery", nil)}, &stubAssignmentService{}, &stubGeocodingService{}, &stubReceiptService{}, &stubProducer{}, &stubRedis{}, log)
+	rr = httptest.NewRecorder()
+	h.LogContactAttempt(rr, httptest.NewRequest(http.MethodPost, "/api/orders/"+orderID.String()+"/contact-attempts", bytes.NewBufferString(`{"channel":"sms","result":"unreachable"}`)))
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", rr.Code)
 	}
 }
 
-func TestOrderHandler_CreateReview_Error(t *testing.T) {
+func TestOrderHandler_UpdateOrder(t *testing.T) {
 	orderID := uuid.New()
 	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
-	orderService := &stubOrderService{order: &models.Order{ID: orderID}, err: fmt.Errorf("fail")}
-	h := NewOrderHandler(orderService, &stubAssignmentService{}, &stubGeocodingService{}, &stubProducer{}, &stubRedisMissOrder{}, log)
+	orderService := &stubOrderService{update: &models.OrderUpdate{
+		Order:   &models.Order{ID: orderID, DeliveryAddress: "New street 5", Status: models.OrderStatusCreated},
+		Changes: []models.FieldChange{{Field: "delivery_address", Old: "Old street 1", New: "New street 5"}},
+	}}
+	producer := &stubProducer{}
+	h := NewOrderHandler(orderService, &stubAssignmentService{}, &stubGeocodingService{}, &stubReceiptService{}, producer, &stubRedis{}, log)
 
-	req := httptest.NewRequest(http.MethodPost, "/api/orders/"+orderID.String()+"/review", bytes.NewBufferString(`{"rating":5}`))
 	rr := httptest.NewRecorder()
-	h.CreateReview(rr, req)
-	if rr.Code != http.StatusInternalServerError {
-		t.Fatalf("expected 500, got %d", rr.Code)
+	h.UpdateOrder(rr, httptest.NewRequest(http.MethodPatch, "/api/orders/"+orderID.String(), bytes.NewBufferString(`{"delivery_address":"New street 5"}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	// Координаты нового адреса берутся из геокодера
+	if req := orderService.updateReq; req == nil || req.DeliveryLat == nil || *req.DeliveryLat != 55.0 || req.DeliveryLon == nil || *req.DeliveryLon != 37.0 {
+		t.Fatalf("expected geocoded coordinates, got %+v", orderService.updateReq)
+	}
+	if len(producer.changes) != 1 || producer.changes[0].Field != "delivery_address" {
+		t.Fatalf("expected order.updated event with changes, got %+v", producer.changes)
+	}
+
+	rr = httptest.NewRecorder()
+	h.UpdateOrder(rr, httptest.NewRequest(http.MethodPatch, "/api/orders/"+orderID.String(), bytes.NewBufferString(`{"items":[]}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for empty items, got %d", rr.Code)
+	}
+
+	h = NewOrderHandler(&stubOrderService{err: apperror.Conflict("order in status in_delivery can no longer be modified", nil)}, &stubAssignmentService{}, &stubGeocodingService{}, &stubReceiptService{}, &stubProducer{}, &stubRedis{}, log)
+	rr = httptest.NewRecorder()
+	h.UpdateOrder(rr, httptest.NewRequest(http.MethodPatch, "/api/orders/"+orderID.String(), bytes.NewBufferString(`{"customer_name":"Boris"}`)))
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", rr.Code)
+	}
+}
+
+func TestOrderHandler_ReassignCourier(t *testing.T) {
+	orderID, previousID, courierID := uuid.New(), uuid.New(), uuid.New()
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	orderService := &stubOrderService{reassignment: &models.CourierReassignment{
+		OrderID:                 orderID,
+		OldStatus:               models.OrderStatusAccepted,
+		Status:                  models.OrderStatusAccepted,
+		PreviousCourierID:       previousID,
+		CourierID:               &courierID,
+		Reason:                  "accident",
+		PreviousCourierReleased: true,
+	}}
+	producer := &stubProducer{}
+	h := NewOrderHandler(orderService, &stubAssignmentService{}, &stubGeocodingService{}, &stubReceiptService{}, producer, &stubRedis{}, log)
+
+	body := bytes.NewBufferString(`{"courier_id":"` + courierID.String() + `","reason":"accident"}`)
+	rr := httptest.NewRecorder()
+	h.ReassignCourier(rr, httptest.NewRequest(http.MethodPost, "/api/orders/"+orderID.String()+"/reassign", body))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if orderService.reason != "accident" || producer.unassigned != 1 || producer.assigned != 1 || producer.courierChanges != 2 || producer.status {
+		t.Fatalf("unexpected events: %+v", producer)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ReassignCourier(rr, httptest.NewRequest(http.MethodPost, "/api/orders/"+orderID.String()+"/reassign", bytes.NewBufferString(`{"reason":"accident"}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without courier, got %d", rr.Code)
+	}
+
+	// Снятие курьера возвращает заказ в created и публикует смену статуса
+	orderService.reassignment = &models.CourierReassignment{
+		OrderID:           orderID,
+		OldStatus:         models.OrderStatusAccepted,
+		Status:            models.OrderStatusCreated,
+		PreviousCourierID: previousID,
+		Reason:            "accident",
+	}
+	producer = &stubProducer{}
+	h = NewOrderHandler(orderService, &stubAssignmentService{}, &stubGeocodingService{}, &stubReceiptService{}, producer, &stubRedis{}, log)
+	rr = httptest.NewRecorder()
+	h.UnassignCourier(rr, httptest.NewRequest(http.MethodPost, "/api/orders/"+orderID.String()+"/unassign", bytes.NewBufferString(`{"reason":"accident"}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if producer.unassigned != 1 || producer.assigned != 0 || producer.courierChanges != 0 || !producer.status {
+		t.Fatalf("unexpected events: %+v", producer)
+	}
+
+	h = NewOrderHandler(&stubOrderService{err: apperror.Conflict("courier is not available", nil)}, &stubAssignmentService{}, &stubGeocodingService{}, &stubReceiptService{}, &stubProducer{}, &stubRedis{}, log)
+	rr = httptest.NewRecorder()
+	h.UnassignCourier(rr, httptest.NewRequest(http.MethodPost, "/api/orders/"+orderID.String()+"/unassign", bytes.NewBufferString(`{"reason":"accident"}`)))
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", rr.Code)
 	}
 }
 
@@ -271,7 +474,7 @@ func TestOrderHandler_GetOrders(t *testing.T) {
 
 func TestOrderHandler_GetOrders_Error(t *testing.T) {
 	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
-	h := NewOrderHandler(&stubOrderService{err: fmt.Errorf("fail")}, &stubAssignmentService{}, &stubGeocodingService{}, &stubProducer{}, &stubRedisMissOrder{}, log)
+	h := NewOrderHandler(&stubOrderService{err: fmt.Errorf("fail")}, &stubAssignmentService{}, &stubGeocodingService{}, &stubReceiptService{}, &stubProducer{}, &stubRedisMissOrder{}, log)
 	req := httptest.NewRequest(http.MethodGet, "/api/orders", nil)
 	rr := httptest.NewRecorder()
 	h.GetOrders(rr, req)
@@ -291,11 +494,12 @@ func TestOrderHandler_UpdateStatus_InvalidMethod(t *testing.T) {
 }
 
 func TestOrderHandler_UpdateStatus(t *testing.T) {
-	orderID := uuid.New()
+	orderID, courierID := uuid.New(), uuid.New()
 	order := &models.Order{ID: orderID}
-	stubSvc := &stubOrderService{order: order}
+	stubSvc := &stubOrderService{order: order, released: &courierID, courierID: &courierID}
 	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
-	h := NewOrderHandler(stubSvc, &stubAssignmentService{}, &stubGeocodingService{}, &stubProducer{}, &stubRedis{}, log)
+	producer := &stubProducer{}
+	h := NewOrderHandler(stubSvc, &stubAssignmentService{}, &stubGeocodingService{}, &stubReceiptService{receipt: &models.Receipt{OrderID: orderID}}, producer, &stubRedis{}, log)
 
 	body := bytes.NewBufferString(`{"status":"delivered"}`)
 	req := httptest.NewRequest(http.MethodPut, "/api/orders/"+orderID.String()+"/status", body)
@@ -307,6 +511,16 @@ func TestOrderHandler_UpdateStatus(t *testing.T) {
 	if !stubSvc.statusCalled {
 		t.Fatalf("expected status update call")
 	}
+	if !producer.receipt {
+		t.Fatalf("expected receipt issued event on delivery")
+	}
+	if producer.courierChanges != 1 {
+		t.Fatalf("expected courier status changed event for released courier, got %d", producer.courierChanges)
+	}
+	// В теле нет courier_id: в событие попадает курьер заказа из ответа сервиса
+	if producer.statusCourier == nil || *producer.statusCourier != courierID {
+		t.Fatalf("expected status event with order courier %s, got %v", courierID, producer.statusCourier)
+	}
 }
 
 func TestOrderHandler_UpdateStatus_BadBody(t *testing.T) {
@@ -322,7 +536,7 @@ func TestOrderHandler_UpdateStatus_BadBody(t *testing.T) {
 func TestOrderHandler_UpdateStatus_NotFound(t *testing.T) {
 	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
 	svc := &stubOrderService{err: apperror.NotFound("order not found", nil)}
-	h := NewOrderHandler(svc, &stubAssignmentService{}, &stubGeocodingService{}, &stubProducer{}, &stubRedis{}, log)
+	h := NewOrderHandler(svc, &stubAssignmentService{}, &stubGeocodingService{}, &stubReceiptService{}, &stubProducer{}, &stubRedis{}, log)
 
 	req := httptest.NewRequest(http.MethodPut, "/api/orders/"+uuid.New().String()+"/status", bytes.NewBufferString(`{"status":"delivered"}`))
 	rr := httptest.NewRecorder()
@@ -335,7 +549,7 @@ func TestOrderHandler_UpdateStatus_NotFound(t *testing.T) {
 func TestOrderHandler_UpdateStatus_ServiceError(t *testing.T) {
 	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
 	svc := &stubOrderService{err: fmt.Errorf("fail")}
-	h := NewOrderHandler(svc, &stubAssignmentService{}, &stubGeocodingService{}, &stubProducer{}, &stubRedis{}, log)
+	h := NewOrderHandler(svc, &stubAssignmentService{}, &stubGeocodingService{}, &stubReceiptService{}, &stubProducer{}, &stubRedis{}, log)
 
 	req := httptest.NewRequest(http.MethodPut, "/api/orders/"+uuid.New().String()+"/status", bytes.NewBufferString(`{"status":"delivered"}`))
 	rr := httptest.NewRecorder()
@@ -371,7 +585,7 @@ func TestOrderHandler_AutoAssign(t *testing.T) {
 	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
 	svc := &stubOrderService{order: order}
 	assign := &stubAssignmentService{courier: &models.Courier{ID: uuid.New()}}
-	h := NewOrderHandler(svc, assign, &stubGeocodingService{}, &stubProducer{}, &stubRedis{}, log)
+	h := NewOrderHandler(svc, assign, &stubGeocodingService{}, &stubReceiptService{}, &stubProducer{}, &stubRedis{}, log)
 
 	req := httptest.NewRequest(http.MethodPost, "/api/orders/"+orderID.String()+"/auto-assign", bytes.NewBufferString(`{"delivery_lat":2,"delivery_lon":2}`))
 	rr := httptest.NewRecorder()
@@ -386,7 +600,7 @@ func TestOrderHandler_AutoAssign_Error(t *testing.T) {
 	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
 	svc := &stubOrderService{order: &models.Order{ID: orderID, DeliveryLat: floatPtr(1), DeliveryLon: floatPtr(2)}}
 	assign := &stubAssignmentService{err: fmt.Errorf("assign fail")}
-	h := NewOrderHandler(svc, assign, &stubGeocodingService{}, &stubProducer{}, &stubRedis{}, log)
+	h := NewOrderHandler(svc, assign, &stubGeocodingService{}, &stubReceiptService{}, &stubProducer{}, &stubRedis{}, log)
 
 	req := httptest.NewRequest(http.MethodPost, "/api/orders/"+orderID.String()+"/auto-assign", bytes.NewBufferString(`{"delivery_lat":1,"delivery_lon":2}`))
 	rr := httptest.NewRecorder()
@@ -399,7 +613,7 @@ func TestOrderHandler_AutoAssign_Error(t *testing.T) {
 func TestOrderHandler_AutoAssign_NotFound(t *testing.T) {
 	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
 	svc := &stubOrderService{err: apperror.NotFound("order not found", nil)}
-	h := NewOrderHandler(svc, &stubAssignmentService{}, &stubGeocodingService{}, &stubProducer{}, &stubRedis{}, log)
+	h := NewOrderHandler(svc, &stubAssignmentService{}, &stubGeocodingService{}, &stubReceiptService{}, &stubProducer{}, &stubRedis{}, log)
 
 	req := httptest.NewRequest(http.MethodPost, "/api/orders/"+uuid.New().String()+"/auto-assign", nil)
 	rr := httptest.NewRecorder()
@@ -413,7 +627,7 @@ func TestOrderHandler_AutoAssign_PartialCoordinates(t *testing.T) {
 	orderID := uuid.New()
 	order := &models.Order{ID: orderID}
 	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
-	h := NewOrderHandler(&stubOrderService{order: order}, &stubAssignmentService{}, &stubGeocodingService{}, &stubProducer{}, &stubRedis{}, log)
+	h := NewOrderHandler(&stubOrderService{order: order}, &stubAssignmentService{}, &stubGeocodingService{}, &stubReceiptService{}, &stubProducer{}, &stubRedis{}, log)
 
 	body := bytes.NewBufferString(`{"delivery_lat":10}`)
 	req := httptest.NewRequest(http.MethodPost, "/api/orders/"+orderID.String()+"/auto-assign", body)
@@ -428,7 +642,7 @@ func TestOrderHandler_AutoAssign_MissingOrderCoords(t *testing.T) {
 	orderID := uuid.New()
 	order := &models.Order{ID: orderID}
 	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
-	h := NewOrderHandler(&stubOrderService{order: order}, &stubAssignmentService{}, &stubGeocodingService{}, &stubProducer{}, &stubRedis{}, log)
+	h := NewOrderHandler(&stubOrderService{order: order}, &stubAssignmentService{}, &stubGeocodingService{}, &stubReceiptService{}, &stubProducer{}, &stubRedis{}, log)
 
 	req := httptest.NewRequest(http.MethodPost, "/api/orders/"+orderID.String()+"/auto-assign", nil)
 	rr := httptest.NewRecorder()
@@ -445,7 +659,7 @@ func TestOrderHandler_CreateOrder_AutoAssignWithGeocode(t *testing.T) {
 
 	geo := &recordingGeocoder{}
 	assign := &stubAssignmentService{courier: &models.Courier{ID: uuid.New(), Name: "assigned"}}
-	h := NewOrderHandler(&stubOrderService{order: order, orders: []*models.Order{order}}, assign, geo, &stubProducer{}, &stubRedis{}, log)
+	h := NewOrderHandler(&stubOrderService{order: order, orders: []*models.Order{order}}, assign, geo, &stubReceiptService{}, &stubProducer{}, &stubRedis{}, log)
 
 	body := `{"customer_name":"Geo","customer_phone":"+7999","delivery_address":"delivery addr","pickup_address":"pickup addr","items":[{"name":"Item","quantity":1,"price":10}],"auto_assign":true}`
 	req := httptest.NewRequest(http.MethodPost, "/api/orders", bytes.NewBufferString(body))
@@ -464,3 +678,60 @@ func TestOrderHandler_CreateOrder_AutoAssignWithGeocode(t *testing.T) {
 }
 
 func floatPtr(v float64) *float64 { return &v }
+
+func TestOrderHandler_GetOrders_CursorEnvelope(t *testing.T) {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	now := time.Now()
+	svc := &stubOrderService{orders: []*models.Order{
+		{ID: uuid.New(), CreatedAt: now},
+		{ID: uuid.New(), CreatedAt: now.Add(-time.Minute)},
+		{ID: uuid.New(), CreatedAt: now.Add(-2 * time.Minute)},
+	}}
+	h := NewOrderHandler(svc, &stubAssignmentService{}, &stubGeocodingService{}, &stubReceiptService{}, &stubProducer{}, &stubRedis{}, log)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/orders?limit=2", nil)
+	rr := httptest.NewRecorder()
+	h.GetOrders(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+
+	var page struct {
+		Items      []models.Order `json:"items"`
+		NextCursor *string        `json:"next_cursor"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &page); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if len(page.Items) != 2 || page.NextCursor == nil {
+		t.Fatalf("expected 2 items and next_cursor, got %s", rr.Body.String())
+	}
+	if rr.Header().Get("Deprecation") != "" {
+		t.Fatalf("unexpected Deprecation header without offset")
+	}
+}
+
+func TestOrderHandler_GetOrders_InvalidCursor(t *testing.T) {
+	h := newTestOrderHandler(&models.Order{ID: uuid.New()})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/orders?cursor=not-a-cursor", nil)
+	rr := httptest.NewRecorder()
+	h.GetOrders(rr, req)
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+}
+
+func TestOrderHandler_GetOrders_OffsetDeprecated(t *testing.T) {
+	h := newTestOrderHandler(&models.Order{ID: uuid.New()})
+
+	req := httptest.NewRequest(http.MethodGet, "/api/orders?limit=10&offset=20", nil)
+	rr := httptest.NewRecorder()
+	h.GetOrders(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if rr.Header().Get("Deprecation") != "true" {
+		t.Fatalf("expected Deprecation header for offset pagination")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"delivery-system/internal/config"
 	"delivery-system/internal/logger"
 	"delivery-system/internal/models"
+	"delivery-system/internal/money"
 )
 
 // validateCreateOrderRequest uses no external deps, safe to call.
@@ -18,7 +19,7 @@ func TestValidateCreateOrderRequest(t *testing.T) {
 		DeliveryAddress: "Addr",
 		PickupAddress:   "Pickup",
 		Items: []models.CreateOrderItemRequest{
-			{Name: "Item", Quantity: 1, Price: 10},
+			{Name: "Item", Quantity: 1, Price: money.New(1000, "RUB")},
 		},
 	}
 	if err := h.validateCreateOrderRequest(req); err != nil {
@@ -30,6 +31,16 @@ func TestValidateCreateOrderRequest(t *testing.T) {
 		t.Fatalf("expected error for zero quantity")
 	}
 
+	req.Items[0].Quantity = 1
+	req.Items[0].TaxCategory = "luxury"
+	if err := h.validateCreateOrderRequest(req); err == nil {
+		t.Fatalf("expected error for unknown tax category")
+	}
+	req.Items[0].TaxCategory = models.TaxCategoryReduced
+	if err := h.validateCreateOrderRequest(req); err != nil {
+		t.Fatalf("expected reduced tax category to be valid, got %v", err)
+	}
+
 	lat := 100.0
 	req.Items[0].Quantity = 1
 	req.PickupLat = &lat
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"time"
+
+	"delivery-system/internal/config"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+	"delivery-system/internal/payments"
+)
+
+const maxWebhookBodyBytes = 64 << 10
+
+// PaymentHandler обрабатывает платежи по заказам и вебхуки провайдера.
+type PaymentHandler struct {
+	paymentService PaymentService
+	log            *logger.Logger
+	webhookSecret  string
+	tolerance      time.Duration
+}
+
+// NewPaymentHandler создает обработчик платежей.
+func NewPaymentHandler(paymentService PaymentService, log *logger.Logger, cfg *config.PaymentsConfig) *PaymentHandler {
+	h := &PaymentHandler{
+		paymentService: paymentService,
+		log:            log,
+		tolerance:      5 * time.Minute,
+	}
+	if cfg != nil {
+		h.webhookSecret = cfg.WebhookSecret
+		if cfg.WebhookToleranceSeconds > 0 {
+			h.tolerance = time.Duration(cfg.WebhookToleranceSeconds) * time.Second
+		}
+	}
+	return h
+}
+
+// GetPayment возвращает платеж заказа.
+func (h *PaymentHandler) GetPayment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	orderID, err := extractUUIDFromPath(r.URL.Path, "/api/orders/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	payment, err := h.paymentService.GetPayment(r.Context(), orderID)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to get payment")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, payment)
+}
+
+// RefundPayment выполняет частичный или полный возврат по заказу.
+func (h *PaymentHandler) RefundPayment(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	orderID, err := extractUUIDFromPath(r.URL.Path, "/api/orders/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	var req models.RefundPaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	payment, err := h.paymentService.Refund(r.Context(), orderID, &req)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to refund payment")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, payment)
+}
+
+// Webhook принимает уведомления провайдера, подписанные HMAC (заголовок X-Payment-Signature).
+func (h *PaymentHandler) Webhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodyBytes))
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := payments.VerifySignature(h.webhookSecret, r.Header.Get(payments.SignatureHeader), body, time.Now(), h.tolerance); err != nil {
+		h.log.WithError(err).Warn("Rejected payment webhook")
+		writeErrorResponse(w, http.StatusUnauthorized, "Invalid signature")
+		return
+	}
+
+	var event models.PaymentWebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.paymentService.HandleWebhook(r.Context(), &event); err != nil {
+		writeServiceError(w, h.log, err, "Failed to process payment webhook")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, map[string]string{"status": "ok"})
+}
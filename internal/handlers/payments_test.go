@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/config"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+	"delivery-system/internal/payments"
+
+	"github.com/google/uuid"
+)
+
+type stubPaymentService struct {
+	payment  *models.Payment
+	err      error
+	gotEvent *models.PaymentWebhookEvent
+}
+
+func (s *stubPaymentService) GetPayment(ctx context.Context, orderID uuid.UUID) (*models.Payment, error) {
+	return s.payment, s.err
+}
+func (s *stubPaymentService) Refund(ctx context.Context, orderID uuid.UUID, req *models.RefundPaymentRequest) (*models.Payment, error) {
+	return s.payment, s.err
+}
+func (s *stubPaymentService) HandleWebhook(ctx context.Context, event *models.PaymentWebhookEvent) error {
+	s.gotEvent = event
+	return s.err
+}
+
+func TestPaymentHandler_GetAndRefund(t *testing.T) {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	orderID := uuid.New()
+	svc := &stubPaymentService{payment: &models.Payment{ID: uuid.New(), OrderID: orderID, Status: models.PaymentStatusCaptured}}
+	handler := NewPaymentHandler(svc, log, &config.PaymentsConfig{})
+
+	rr := httptest.NewRecorder()
+	handler.GetPayment(rr, httptest.NewRequest(http.MethodGet, "/api/orders/"+orderID.String()+"/payment", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.RefundPayment(rr, httptest.NewRequest(http.MethodPost, "/api/orders/"+orderID.String()+"/payment/refund", bytes.NewBufferString(`{"amount":10}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+
+	svc.err = apperror.Conflict("only captured payments can be refunded", nil)
+	rr = httptest.NewRecorder()
+	handler.RefundPayment(rr, httptest.NewRequest(http.MethodPost, "/api/orders/"+orderID.String()+"/payment/refund", bytes.NewBufferString(`{"amount":10}`)))
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.GetPayment(rr, httptest.NewRequest(http.MethodGet, "/api/orders/bad/payment", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+}
+
+func TestPaymentHandler_Webhook(t *testing.T) {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	svc := &stubPaymentService{}
+	handler := NewPaymentHandler(svc, log, &config.PaymentsConfig{WebhookSecret: "secret", WebhookToleranceSeconds: 60})
+
+	body := []byte(`{"id":"evt_1","type":"payment.captured","provider_ref":"ref_1","amount":100}`)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/payments/webhook", bytes.NewReader(body))
+	req.Header.Set(payments.SignatureHeader, payments.Sign("secret", time.Now(), body))
+	rr := httptest.NewRecorder()
+	handler.Webhook(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if svc.gotEvent == nil || svc.gotEvent.ID != "evt_1" || svc.gotEvent.ProviderRef != "ref_1" {
+		t.Fatalf("unexpected event passed to service: %+v", svc.gotEvent)
+	}
+
+	svc.gotEvent = nil
+	req = httptest.NewRequest(http.MethodPost, "/api/payments/webhook", bytes.NewReader(body))
+	req.Header.Set(payments.SignatureHeader, payments.Sign("wrong", time.Now(), body))
+	rr = httptest.NewRecorder()
+	handler.Webhook(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for invalid signature, got %d", rr.Code)
+	}
+	if svc.gotEvent != nil {
+		t.Fatalf("service must not be called for invalid signature")
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/api/payments/webhook", bytes.NewReader(body))
+	req.Header.Set(payments.SignatureHeader, payments.Sign("secret", time.Now().Add(-time.Hour), body))
+	rr = httptest.NewRecorder()
+	handler.Webhook(rr, req)
+	if rr.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for stale signature, got %d", rr.Code)
+	}
+
+	svc.err = apperror.NotFound("payment not found", nil)
+	req = httptest.NewRequest(http.MethodPost, "/api/payments/webhook", bytes.NewReader(body))
+	req.Header.Set(payments.SignatureHeader, payments.Sign("secret", time.Now(), body))
+	rr = httptest.NewRecorder()
+	handler.Webhook(rr, req)
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+}
@@ -4,11 +4,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
-	"strconv"
 	"strings"
 
 	"delivery-system/internal/logger"
 	"delivery-system/internal/models"
+	"delivery-system/internal/money"
 )
 
 // PromoHandler обрабатывает промокоды.
@@ -61,23 +61,15 @@ func (h *PromoHandler) ListPromoCodes(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	limit := 50
-	offset := 0
-	if l := r.URL.Query().Get("limit"); l != "" {
-		if v, err := strconv.Atoi(l); err == nil && v > 0 && v <= 200 {
-			limit = v
-		}
-	}
-	if o := r.URL.Query().Get("offset"); o != "" {
-		if v, err := strconv.Atoi(o); err == nil && v >= 0 {
-			offset = v
-		}
+	page, err := parsePageRequest(w, r, 200)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
 	}
 
-	promos, err := h.promoService.ListPromoCodes(r.Context(), limit, offset)
+	promos, err := h.promoService.ListPromoCodes(r.Context(), page)
 	if err != nil {
-		h.log.WithError(err).Error("Failed to list promo codes")
-		writeErrorResponse(w, http.StatusInternalServerError, "Failed to list promo codes")
+		writeServiceError(w, h.log, err, "Failed to list promo codes")
 		return
 	}
 
@@ -160,7 +152,68 @@ func (h *PromoHandler) DeletePromoCode(w http.ResponseWriter, r *http.Request) {
 	writeJSONResponse(w, http.StatusOK, map[string]string{"message": "Promo code deleted"})
 }
 
-func validatePromoRequest(code string, discountType models.DiscountType, amount float64) error {
+// ListRedemptions возвращает историю применений промокода.
+func (h *PromoHandler) ListRedemptions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	code, err := extractPromoCodeFromPath(r.URL.Path)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := parsePageRequest(w, r, 200)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	redemptions, err := h.promoService.ListRedemptions(r.Context(), code, page)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to list promo redemptions")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, redemptions)
+}
+
+// ValidatePromoCode проверяет промокод для корзины без его применения.
+// Неприменимый код возвращается со статусом 200, valid=false и причиной в поле reason.
+func (h *PromoHandler) ValidatePromoCode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	code, err := extractPromoCodeFromPath(r.URL.Path)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if len(code) > 64 {
+		writeErrorResponse(w, http.StatusBadRequest, "promo code is too long")
+		return
+	}
+
+	var req models.ValidatePromoRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	result, err := h.promoService.PreviewPromo(r.Context(), code, &req)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to validate promo code")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, result)
+}
+
+func validatePromoRequest(code string, discountType models.DiscountType, amount money.Money) error {
 	if strings.TrimSpace(code) == "" {
 		return fmt.Errorf("promo code is required")
 	}
@@ -168,7 +221,7 @@ func validatePromoRequest(code string, discountType models.DiscountType, amount
 		return fmt.Errorf("promo code is too long")
 	}
 	// amount/type validated in service; keep simple checks for percent
-	if discountType == models.DiscountTypePercent && (amount <= 0 || amount > 100) {
+	if discountType == models.DiscountTypePercent && (!amount.IsPositive() || amount.Float64() > 100) {
 		return fmt.Errorf("percent amount must be between 0 and 100")
 	}
 	return nil
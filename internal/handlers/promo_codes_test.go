@@ -13,12 +13,17 @@ import (
 	"delivery-system/internal/config"
 	"delivery-system/internal/logger"
 	"delivery-system/internal/models"
+	"delivery-system/internal/money"
+	"delivery-system/internal/pagination"
 )
 
 type stubPromoService struct {
-	promo *models.PromoCode
-	err   error
-	list  []*models.PromoCode
+	promo       *models.PromoCode
+	err         error
+	list        []*models.PromoCode
+	redemptions []*models.PromoRedemption
+	validation  *models.PromoValidation
+	gotCode     string
 }
 
 func (s *stubPromoService) CreatePromoCode(ctx context.Context, req *models.CreatePromoCodeRequest) (*models.PromoCode, error) {
@@ -33,8 +38,24 @@ func (s *stubPromoService) UpdatePromoCode(ctx context.Context, code string, req
 func (s *stubPromoService) DeletePromoCode(ctx context.Context, code string) error {
 	return s.err
 }
-func (s *stubPromoService) ListPromoCodes(ctx context.Context, limit, offset int) ([]*models.PromoCode, error) {
-	return s.list, s.err
+func (s *stubPromoService) ListPromoCodes(ctx context.Context, page pagination.Request) (*pagination.Page[*models.PromoCode], error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &pagination.Page[*models.PromoCode]{Items: s.list}, nil
+}
+func (s *stubPromoService) PreviewPromo(ctx context.Context, code string, req *models.ValidatePromoRequest) (*models.PromoValidation, error) {
+	s.gotCode = code
+	return s.validation, s.err
+}
+func (s *stubPromoService) ListRedemptions(ctx context.Context, code string, page pagination.Request) (*pagination.Page[*models.PromoRedemption], error) {
+	s.gotCode = code
+	if s.err != nil {
+		return nil, s.err
+	}
+	return pagination.NewPage(s.redemptions, page.Limit, func(r *models.PromoRedemption) pagination.Cursor {
+		return pagination.Cursor{CreatedAt: r.RedeemedAt, ID: r.ID.String()}
+	}), nil
 }
 
 func TestPromoHandler_CreateAndGet(t *testing.T) {
@@ -42,7 +63,7 @@ func TestPromoHandler_CreateAndGet(t *testing.T) {
 	p := &models.PromoCode{
 		Code:         "TEST",
 		DiscountType: models.DiscountTypeFixed,
-		Amount:       10,
+		Amount:       money.New(1000, "RUB"),
 		Active:       true,
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
@@ -157,7 +178,7 @@ func TestPromoHandler_List_MethodNotAllowed(t *testing.T) {
 
 func TestPromoHandler_UpdateAndDelete(t *testing.T) {
 	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
-	updated := &models.PromoCode{Code: "TEST", DiscountType: models.DiscountTypePercent, Amount: 20}
+	updated := &models.PromoCode{Code: "TEST", DiscountType: models.DiscountTypePercent, Amount: money.New(2000, "RUB")}
 	service := &stubPromoService{promo: updated}
 	handler := NewPromoHandler(service, log)
 
@@ -290,3 +311,55 @@ func TestPromoHandler_Delete_MethodNotAllowed(t *testing.T) {
 		t.Fatalf("expected 405, got %d", rr.Code)
 	}
 }
+
+func TestPromoHandler_ListRedemptions(t *testing.T) {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	svc := &stubPromoService{redemptions: []*models.PromoRedemption{
+		{Code: "SALE", CustomerPhone: "79991234567", Discount: money.New(5000, "RUB"), Currency: "RUB"},
+	}}
+	handler := NewPromoHandler(svc, log)
+
+	rr := httptest.NewRecorder()
+	handler.ListRedemptions(rr, httptest.NewRequest(http.MethodGet, "/api/promo-codes/SALE/redemptions", nil))
+	if rr.Code != http.StatusOK || svc.gotCode != "SALE" || !bytes.Contains(rr.Body.Bytes(), []byte(`"customer_phone":"79991234567"`)) {
+		t.Fatalf("expected redemptions, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	svc.err = apperror.NotFound("promo code not found", nil)
+	rr = httptest.NewRecorder()
+	handler.ListRedemptions(rr, httptest.NewRequest(http.MethodGet, "/api/promo-codes/NOPE/redemptions", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ListRedemptions(rr, httptest.NewRequest(http.MethodPost, "/api/promo-codes/SALE/redemptions", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rr.Code)
+	}
+}
+
+func TestPromoHandler_ValidatePromoCode(t *testing.T) {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	svc := &stubPromoService{validation: &models.PromoValidation{Code: "OLD", Valid: false, Reason: models.PromoReasonExpired, Message: "promo code expired"}}
+	handler := NewPromoHandler(svc, log)
+	body := `{"items":[{"name":"Pizza","quantity":1,"price":500}],"pickup_lat":55.75,"pickup_lon":37.62,"delivery_lat":55.76,"delivery_lon":37.63}`
+
+	rr := httptest.NewRecorder()
+	handler.ValidatePromoCode(rr, httptest.NewRequest(http.MethodPost, "/api/promo-codes/OLD/validate", bytes.NewBufferString(body)))
+	if rr.Code != http.StatusOK || svc.gotCode != "OLD" || !bytes.Contains(rr.Body.Bytes(), []byte(`"reason":"expired"`)) {
+		t.Fatalf("expected rejected preview, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ValidatePromoCode(rr, httptest.NewRequest(http.MethodPost, "/api/promo-codes/OLD/validate", bytes.NewBufferString("{")))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ValidatePromoCode(rr, httptest.NewRequest(http.MethodGet, "/api/promo-codes/OLD/validate", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rr.Code)
+	}
+}
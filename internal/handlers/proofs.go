@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"delivery-system/internal/config"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const defaultMaxUploadMB = 10
+
+// ProofHandler обрабатывает загрузку и выдачу подтверждений доставки.
+type ProofHandler struct {
+	proofService   ProofService
+	log            *logger.Logger
+	maxUploadBytes int64
+}
+
+// NewProofHandler создает обработчик подтверждений доставки.
+func NewProofHandler(proofService ProofService, log *logger.Logger, cfg *config.StorageConfig) *ProofHandler {
+	maxMB := defaultMaxUploadMB
+	if cfg != nil && cfg.MaxUploadMB > 0 {
+		maxMB = cfg.MaxUploadMB
+	}
+	return &ProofHandler{
+		proofService:   proofService,
+		log:            log,
+		maxUploadBytes: int64(maxMB) << 20,
+	}
+}
+
+// UploadProof принимает фото или подпись (multipart/form-data: type, file).
+// Файл не JPEG, PNG или WebP сохраняется с типом application/octet-stream.
+func (h *ProofHandler) UploadProof(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	orderID, err := extractUUIDFromPath(r.URL.Path, "/api/orders/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	file, ok := parseUpload(w, r, h.maxUploadBytes, imageContentTypes)
+	if !ok {
+		return
+	}
+	defer file.Close()
+	defer func() { _ = r.MultipartForm.RemoveAll() }()
+
+	proofType := models.ProofType(r.FormValue("type"))
+	if proofType != models.ProofTypePhoto && proofType != models.ProofTypeSignature {
+		writeErrorResponse(w, http.StatusBadRequest, "type must be photo or signature")
+		return
+	}
+
+	proof, err := h.proofService.AttachProof(r.Context(), orderID, proofType, file.ContentType, file)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to attach delivery proof")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusCreated, proof)
+}
+
+// ListProofs возвращает список подтверждений доставки по заказу.
+func (h *ProofHandler) ListProofs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	orderID, err := extractUUIDFromPath(r.URL.Path, "/api/orders/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	proofs, err := h.proofService.ListProofs(r.Context(), orderID)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to list delivery proofs")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, proofs)
+}
+
+// GetProofContent отдает содержимое фото или подписи.
+func (h *ProofHandler) GetProofContent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	orderID, err := extractUUIDFromPath(r.URL.Path, "/api/orders/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	proofID, err := extractProofIDFromPath(r.URL.Path)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid proof ID")
+		return
+	}
+
+	proof, content, err := h.proofService.OpenProof(r.Context(), orderID, proofID)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to get delivery proof")
+		return
+	}
+	defer content.Close()
+
+	if err := serveStoredFile(w, content, proof.ContentType, proof.SizeBytes, "proof-"+proofID.String(), imageContentTypes); err != nil {
+		h.log.WithError(err).WithField("proof_id", proofID).Warn("Failed to stream delivery proof")
+	}
+}
+
+// extractProofIDFromPath извлекает ID вложения из пути /api/orders/{id}/proof/{proof_id}
+func extractProofIDFromPath(path string) (uuid.UUID, error) {
+	idx := strings.Index(path, "/proof/")
+	if idx < 0 {
+		return uuid.Nil, fmt.Errorf("invalid path format")
+	}
+	return uuid.Parse(strings.Trim(path[idx+len("/proof/"):], "/"))
+}
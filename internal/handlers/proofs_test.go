@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/config"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type stubProofService struct {
+	proof   *models.DeliveryProof
+	list    []*models.DeliveryProof
+	content string
+	err     error
+
+	gotType     models.ProofType
+	gotBody     string
+	gotOrderID  uuid.UUID
+	gotProofID  uuid.UUID
+	contentType string
+}
+
+func (s *stubProofService) AttachProof(ctx context.Context, orderID uuid.UUID, proofType models.ProofType, contentType string, content io.Reader) (*models.DeliveryProof, error) {
+	data, _ := io.ReadAll(content)
+	s.gotOrderID = orderID
+	s.gotType = proofType
+	s.gotBody = string(data)
+	s.contentType = contentType
+	return s.proof, s.err
+}
+func (s *stubProofService) ListProofs(ctx context.Context, orderID uuid.UUID) ([]*models.DeliveryProof, error) {
+	return s.list, s.err
+}
+func (s *stubProofService) OpenProof(ctx context.Context, orderID, proofID uuid.UUID) (*models.DeliveryProof, io.ReadCloser, error) {
+	s.gotOrderID = orderID
+	s.gotProofID = proofID
+	if s.err != nil {
+		return nil, nil, s.err
+	}
+	return s.proof, io.NopCloser(strings.NewReader(s.content)), nil
+}
+
+func newProofUploadRequest(t *testing.T, path, proofType, content string) *http.Request {
+	body := &bytes.Buffer{}
+	mw := multipart.NewWriter(body)
+	if proofType != "" {
+		_ = mw.WriteField("type", proofType)
+	}
+	if content != "" {
+		fw, err := mw.CreateFormFile("file", "proof.jpg")
+		if err != nil {
+			t.Fatalf("failed to create form file: %v", err)
+		}
+		_, _ = fw.Write([]byte(content))
+	}
+	_ = mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, path, body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	return req
+}
+
+func TestProofHandler_UploadProof(t *testing.T) {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	orderID := uuid.New()
+	svc := &stubProofService{proof: &models.DeliveryProof{ID: uuid.New(), OrderID: orderID, Type: models.ProofTypePhoto, CreatedAt: time.Now()}}
+	handler := NewProofHandler(svc, log, &config.StorageConfig{MaxUploadMB: 1})
+
+	rr := httptest.NewRecorder()
+	handler.UploadProof(rr, newProofUploadRequest(t, "/api/orders/"+orderID.String()+"/proof", "photo", "image-bytes"))
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if svc.gotOrderID != orderID || svc.gotType != models.ProofTypePhoto || svc.gotBody != "image-bytes" {
+		t.Fatalf("unexpected service call: %+v", svc)
+	}
+}
+
+func TestProofHandler_UploadProof_ContentType(t *testing.T) {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	orderID := uuid.New()
+
+	cases := []struct {
+		sent string
+		want string
+	}{
+		{"image/png", "image/png"},
+		{"image/jpeg; charset=binary", "image/jpeg"},
+		{"text/html", "application/octet-stream"},
+		{"image/svg+xml", "application/octet-stream"},
+	}
+	for _, tc := range cases {
+		body := &bytes.Buffer{}
+		mw := multipart.NewWriter(body)
+		_ = mw.WriteField("type", "photo")
+		part, _ := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Disposition": {`form-data; name="file"; filename="proof"`},
+			"Content-Type":        {tc.sent},
+		})
+		_, _ = part.Write([]byte("<script>alert(1)</script>"))
+		_ = mw.Close()
+		req := httptest.NewRequest(http.MethodPost, "/api/orders/"+orderID.String()+"/proof", body)
+		req.Header.Set("Content-Type", mw.FormDataContentType())
+
+		svc := &stubProofService{proof: &models.DeliveryProof{ID: uuid.New()}}
+		rr := httptest.NewRecorder()
+		NewProofHandler(svc, log, nil).UploadProof(rr, req)
+		if rr.Code != http.StatusCreated || svc.contentType != tc.want {
+			t.Fatalf("sent %q: expected stored type %q, got %q (status %d)", tc.sent, tc.want, svc.contentType, rr.Code)
+		}
+	}
+}
+
+func TestProofHandler_UploadProof_Errors(t *testing.T) {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	orderID := uuid.New().String()
+
+	cases := []struct {
+		name   string
+		svc    *stubProofService
+		req    *http.Request
+		status int
+	}{
+		{"method", &stubProofService{}, httptest.NewRequest(http.MethodGet, "/api/orders/"+orderID+"/proof", nil), http.StatusMethodNotAllowed},
+		{"invalid order id", &stubProofService{}, newProofUploadRequest(t, "/api/orders/bad/proof", "photo", "x"), http.StatusBadRequest},
+		{"invalid type", &stubProofService{}, newProofUploadRequest(t, "/api/orders/"+orderID+"/proof", "pin", "x"), http.StatusBadRequest},
+		{"missing file", &stubProofService{}, newProofUploadRequest(t, "/api/orders/"+orderID+"/proof", "photo", ""), http.StatusBadRequest},
+		{"too large", &stubProofService{}, newProofUploadRequest(t, "/api/orders/"+orderID+"/proof", "photo", strings.Repeat("x", 2<<20)), http.StatusRequestEntityTooLarge},
+		{"conflict", &stubProofService{err: apperror.Conflict("proof can only be attached to an order in delivery", nil)}, newProofUploadRequest(t, "/api/orders/"+orderID+"/proof", "signature", "x"), http.StatusConflict},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := NewProofHandler(tc.svc, log, &config.StorageConfig{MaxUploadMB: 1})
+			rr := httptest.NewRecorder()
+			handler.UploadProof(rr, tc.req)
+			if rr.Code != tc.status {
+				t.Fatalf("expected %d, got %d", tc.status, rr.Code)
+			}
+		})
+	}
+}
+
+func TestProofHandler_ListProofs(t *testing.T) {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	orderID := uuid.New()
+	svc := &stubProofService{list: []*models.DeliveryProof{{ID: uuid.New(), OrderID: orderID, Type: models.ProofTypePIN}}}
+	handler := NewProofHandler(svc, log, nil)
+
+	rr := httptest.NewRecorder()
+	handler.ListProofs(rr, httptest.NewRequest(http.MethodGet, "/api/orders/"+orderID.String()+"/proof", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if !strings.Contains(rr.Body.String(), `"type":"pin"`) {
+		t.Fatalf("unexpected body: %s", rr.Body.String())
+	}
+}
+
+func TestProofHandler_GetProofContent(t *testing.T) {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	orderID := uuid.New()
+	proofID := uuid.New()
+	ct := "image/png"
+	svc := &stubProofService{
+		proof:   &models.DeliveryProof{ID: proofID, OrderID: orderID, Type: models.ProofTypeSignature, ContentType: &ct, SizeBytes: 3},
+		content: "png",
+	}
+	handler := NewProofHandler(svc, log, nil)
+
+	rr := httptest.NewRecorder()
+	handler.GetProofContent(rr, httptest.NewRequest(http.MethodGet, "/api/orders/"+orderID.String()+"/proof/"+proofID.String(), nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+	if rr.Header().Get("Content-Type") != ct || rr.Body.String() != "png" {
+		t.Fatalf("unexpected response: %q %q", rr.Header().Get("Content-Type"), rr.Body.String())
+	}
+	if svc.gotOrderID != orderID || svc.gotProofID != proofID {
+		t.Fatalf("unexpected ids passed to service")
+	}
+	if rr.Header().Get("X-Content-Type-Options") != "nosniff" || !strings.HasPrefix(rr.Header().Get("Content-Disposition"), "attachment;") {
+		t.Fatalf("expected safe download headers, got %v", rr.Header())
+	}
+
+	// Тип не из белого списка (старая запись) отдается как двоичный файл
+	html := "text/html"
+	svc.proof.ContentType = &html
+	rr = httptest.NewRecorder()
+	handler.GetProofContent(rr, httptest.NewRequest(http.MethodGet, "/api/orders/"+orderID.String()+"/proof/"+proofID.String(), nil))
+	if rr.Header().Get("Content-Type") != "application/octet-stream" {
+		t.Fatalf("expected octet-stream for html proof, got %q", rr.Header().Get("Content-Type"))
+	}
+
+	rr = httptest.NewRecorder()
+	handler.GetProofContent(rr, httptest.NewRequest(http.MethodGet, "/api/orders/"+orderID.String()+"/proof/bad", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid proof id, got %d", rr.Code)
+	}
+
+	svc.err = apperror.NotFound("delivery proof not found", nil)
+	rr = httptest.NewRecorder()
+	handler.GetProofContent(rr, httptest.NewRequest(http.MethodGet, "/api/orders/"+orderID.String()+"/proof/"+proofID.String(), nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+	"delivery-system/internal/receipts"
+)
+
+// ReceiptHandler отдает чеки по доставленным заказам.
+type ReceiptHandler struct {
+	receiptService ReceiptService
+	log            *logger.Logger
+}
+
+// NewReceiptHandler создает обработчик чеков.
+func NewReceiptHandler(receiptService ReceiptService, log *logger.Logger) *ReceiptHandler {
+	return &ReceiptHandler{
+		receiptService: receiptService,
+		log:            log,
+	}
+}
+
+// GetReceipt возвращает чек заказа. Формат задается параметром format: json (по умолчанию), html или pdf.
+func (h *ReceiptHandler) GetReceipt(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	orderID, err := extractUUIDFromPath(r.URL.Path, "/api/orders/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	format := models.ReceiptFormat(strings.ToLower(r.URL.Query().Get("format")))
+	switch format {
+	case "", models.ReceiptFormatJSON:
+		receipt, err := h.receiptService.GetReceipt(r.Context(), orderID)
+		if err != nil {
+			writeServiceError(w, h.log, err, "Failed to get receipt")
+			return
+		}
+		writeJSONResponse(w, http.StatusOK, receipt)
+	case models.ReceiptFormatHTML, models.ReceiptFormatPDF:
+		document, err := h.receiptService.GetReceiptDocument(r.Context(), orderID, format)
+		if err != nil {
+			writeServiceError(w, h.log, err, "Failed to get receipt")
+			return
+		}
+		w.Header().Set("Content-Type", receipts.ContentType(format))
+		if format == models.ReceiptFormatPDF {
+			w.Header().Set("Content-Disposition", fmt.Sprintf("inline; filename=\"receipt-%s.pdf\"", orderID))
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(document)
+	default:
+		writeErrorResponse(w, http.StatusBadRequest, "format must be one of: json, html, pdf")
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/config"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+
+	"github.com/google/uuid"
+)
+
+type stubReceiptService struct {
+	receipt   *models.Receipt
+	document  []byte
+	err       error
+	gotFormat models.ReceiptFormat
+}
+
+func (s *stubReceiptService) GetReceipt(ctx context.Context, orderID uuid.UUID) (*models.Receipt, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if s.receipt == nil {
+		return nil, apperror.NotFound("receipt not found", nil)
+	}
+	return s.receipt, nil
+}
+func (s *stubReceiptService) GetReceiptDocument(ctx context.Context, orderID uuid.UUID, format models.ReceiptFormat) ([]byte, error) {
+	s.gotFormat = format
+	return s.document, s.err
+}
+
+func TestReceiptHandler_GetReceipt(t *testing.T) {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	orderID := uuid.New()
+	svc := &stubReceiptService{receipt: &models.Receipt{Number: "R-00000001", OrderID: orderID}, document: []byte("%PDF-1.4")}
+	handler := NewReceiptHandler(svc, log)
+	path := "/api/orders/" + orderID.String() + "/receipt"
+
+	rr := httptest.NewRecorder()
+	handler.GetReceipt(rr, httptest.NewRequest(http.MethodGet, path, nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), "R-00000001") {
+		t.Fatalf("expected json receipt, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	handler.GetReceipt(rr, httptest.NewRequest(http.MethodGet, path+"?format=pdf", nil))
+	if rr.Code != http.StatusOK || rr.Header().Get("Content-Type") != "application/pdf" || svc.gotFormat != models.ReceiptFormatPDF {
+		t.Fatalf("expected pdf receipt, got %d %s", rr.Code, rr.Header().Get("Content-Type"))
+	}
+
+	rr = httptest.NewRecorder()
+	handler.GetReceipt(rr, httptest.NewRequest(http.MethodGet, path+"?format=xml", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.GetReceipt(rr, httptest.NewRequest(http.MethodPost, path, nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rr.Code)
+	}
+}
+
+func TestReceiptHandler_GetReceipt_NotFound(t *testing.T) {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	handler := NewReceiptHandler(&stubReceiptService{}, log)
+
+	rr := httptest.NewRecorder()
+	handler.GetReceipt(rr, httptest.NewRequest(http.MethodGet, "/api/orders/"+uuid.New().String()+"/receipt", nil))
+	if rr.Code != http.StatusNotFound {
+		t.Fatalf("expected 404, got %d", rr.Code)
+	}
+}
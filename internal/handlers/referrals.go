@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+)
+
+// ReferralHandler обрабатывает реферальные коды и бонусные счета клиентов.
+type ReferralHandler struct {
+	referralService ReferralService
+	walletService   WalletService
+	log             *logger.Logger
+}
+
+// NewReferralHandler создает обработчик реферальной программы.
+func NewReferralHandler(referralService ReferralService, walletService WalletService, log *logger.Logger) *ReferralHandler {
+	return &ReferralHandler{
+		referralService: referralService,
+		walletService:   walletService,
+		log:             log,
+	}
+}
+
+// CreateReferralCode выдает персональный код клиента. Повторный запрос возвращает тот же код со статусом 200.
+func (h *ReferralHandler) CreateReferralCode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	var req models.CreateReferralCodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+	if req.CustomerPhone == "" {
+		writeErrorResponse(w, http.StatusBadRequest, "customer_phone is required")
+		return
+	}
+
+	code, created, err := h.referralService.GetOrCreateCode(r.Context(), &req)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to create referral code")
+		return
+	}
+
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	writeJSONResponse(w, status, code)
+}
+
+// GetReferralCode возвращает код со счетчиками приглашений.
+func (h *ReferralHandler) GetReferralCode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	code, err := extractPathSegment(r.URL.Path, "/api/referral-codes/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	result, err := h.referralService.GetReferralCode(r.Context(), code)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to get referral code")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, result)
+}
+
+// ListReferrals возвращает приглашения по коду с их статусами и начисленными бонусами.
+func (h *ReferralHandler) ListReferrals(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	code, err := extractPathSegment(r.URL.Path, "/api/referral-codes/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := parsePageRequest(w, r, 200)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	referrals, err := h.referralService.ListReferrals(r.Context(), code, page)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to list referrals")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, referrals)
+}
+
+// GetWallet возвращает остатки бонусов клиента и историю операций.
+func (h *ReferralHandler) GetWallet(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	phone, err := extractPathSegment(r.URL.Path, "/api/wallets/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	page, err := parsePageRequest(w, r, 200)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	wallet, err := h.walletService.GetWallet(r.Context(), phone, page)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to get wallet")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, wallet)
+}
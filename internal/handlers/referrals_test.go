@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/config"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+	"delivery-system/internal/pagination"
+)
+
+type stubReferralService struct {
+	created bool
+	gotCode string
+}
+
+func (s *stubReferralService) GetOrCreateCode(ctx context.Context, req *models.CreateReferralCodeRequest) (*models.ReferralCode, bool, error) {
+	return &models.ReferralCode{Code: "REF-ABCD2345", CustomerPhone: req.CustomerPhone}, s.created, nil
+}
+func (s *stubReferralService) GetReferralCode(ctx context.Context, code string) (*models.ReferralCode, error) {
+	s.gotCode = code
+	return nil, apperror.NotFound("referral code not found", nil)
+}
+func (s *stubReferralService) ListReferrals(ctx context.Context, code string, page pagination.Request) (*pagination.Page[*models.Referral], error) {
+	s.gotCode = code
+	return &pagination.Page[*models.Referral]{Items: []*models.Referral{}}, nil
+}
+
+type stubWalletService struct {
+	gotPhone string
+}
+
+func (s *stubWalletService) GetWallet(ctx context.Context, customerPhone string, page pagination.Request) (*models.Wallet, error) {
+	s.gotPhone = customerPhone
+	return &models.Wallet{CustomerPhone: customerPhone}, nil
+}
+
+func TestReferralHandler_CreateReferralCode(t *testing.T) {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	svc := &stubReferralService{created: true}
+	handler := NewReferralHandler(svc, &stubWalletService{}, log)
+
+	rr := httptest.NewRecorder()
+	handler.CreateReferralCode(rr, httptest.NewRequest(http.MethodPost, "/api/referral-codes", strings.NewReader(`{"customer_phone":"79990000001"}`)))
+	if rr.Code != http.StatusCreated || !strings.Contains(rr.Body.String(), "REF-ABCD2345") {
+		t.Fatalf("expected 201, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	// Повторный запрос отдает существующий код
+	svc.created = false
+	rr = httptest.NewRecorder()
+	handler.CreateReferralCode(rr, httptest.NewRequest(http.MethodPost, "/api/referral-codes", strings.NewReader(`{"customer_phone":"79990000001"}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.CreateReferralCode(rr, httptest.NewRequest(http.MethodPost, "/api/referral-codes", strings.NewReader(`{}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without phone, got %d", rr.Code)
+	}
+}
+
+func TestReferralHandler_GetReferralCodeAndList(t *testing.T) {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	svc := &stubReferralService{}
+	handler := NewReferralHandler(svc, &stubWalletService{}, log)
+
+	rr := httptest.NewRecorder()
+	handler.GetReferralCode(rr, httptest.NewRequest(http.MethodGet, "/api/referral-codes/REF-MISSING", nil))
+	if rr.Code != http.StatusNotFound || svc.gotCode != "REF-MISSING" {
+		t.Fatalf("expected 404 for REF-MISSING, got %d (%s)", rr.Code, svc.gotCode)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.ListReferrals(rr, httptest.NewRequest(http.MethodGet, "/api/referral-codes/REF-ABCD2345/referrals", nil))
+	if rr.Code != http.StatusOK || svc.gotCode != "REF-ABCD2345" {
+		t.Fatalf("expected 200 for REF-ABCD2345, got %d (%s)", rr.Code, svc.gotCode)
+	}
+}
+
+func TestReferralHandler_GetWallet(t *testing.T) {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	wallet := &stubWalletService{}
+	handler := NewReferralHandler(&stubReferralService{}, wallet, log)
+
+	rr := httptest.NewRecorder()
+	handler.GetWallet(rr, httptest.NewRequest(http.MethodGet, "/api/wallets/79990000002", nil))
+	if rr.Code != http.StatusOK || wallet.gotPhone != "79990000002" {
+		t.Fatalf("expected 200, got %d (%s)", rr.Code, wallet.gotPhone)
+	}
+
+	rr = httptest.NewRecorder()
+	handler.GetWallet(rr, httptest.NewRequest(http.MethodPost, "/api/wallets/79990000002", nil))
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rr.Code)
+	}
+}
@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+	"delivery-system/internal/redis"
+)
+
+// ReviewHandler обрабатывает отзывы: создание и правку клиентом, ответы курьеров и модерацию.
+type ReviewHandler struct {
+	reviewService ReviewService
+	redisClient   RedisClient
+	log           *logger.Logger
+}
+
+// NewReviewHandler создает обработчик отзывов
+func NewReviewHandler(reviewService ReviewService, redisClient RedisClient, log *logger.Logger) *ReviewHandler {
+	return &ReviewHandler{
+		reviewService: reviewService,
+		redisClient:   redisClient,
+		log:           log,
+	}
+}
+
+// CreateReview создает отзыв по доставленному заказу
+func (h *ReviewHandler) CreateReview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	orderID, err := extractUUIDFromPath(r.URL.Path, "/api/orders/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	var req models.CreateReviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	review, err := h.reviewService.CreateReview(r.Context(), orderID, &req)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to create review")
+		return
+	}
+
+	h.invalidateCaches(r.Context(), review)
+	writeJSONResponse(w, http.StatusCreated, review)
+}
+
+// UpdateReview правит отзыв по заказу в пределах окна редактирования
+func (h *ReviewHandler) UpdateReview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	orderID, err := extractUUIDFromPath(r.URL.Path, "/api/orders/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid order ID")
+		return
+	}
+
+	var req models.UpdateReviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	review, err := h.reviewService.UpdateReview(r.Context(), orderID, &req)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to update review")
+		return
+	}
+
+	h.invalidateCaches(r.Context(), review)
+	writeJSONResponse(w, http.StatusOK, review)
+}
+
+// ReplyToReview сохраняет ответ курьера на отзыв
+func (h *ReviewHandler) ReplyToReview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	reviewID, err := extractUUIDFromPath(r.URL.Path, "/api/reviews/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid review ID")
+		return
+	}
+
+	var req models.ReviewReplyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	review, err := h.reviewService.ReplyToReview(r.Context(), reviewID, &req)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to reply to review")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, review)
+}
+
+// ModerateReview публикует или скрывает отзыв
+func (h *ReviewHandler) ModerateReview(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	reviewID, err := extractUUIDFromPath(r.URL.Path, "/api/reviews/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid review ID")
+		return
+	}
+
+	var req models.ModerateReviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	review, err := h.reviewService.ModerateReview(r.Context(), reviewID, &req)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to moderate review")
+		return
+	}
+
+	h.invalidateCaches(r.Context(), review)
+	writeJSONResponse(w, http.StatusOK, review)
+}
+
+// GetCourierReviews возвращает опубликованные отзывы по курьеру
+func (h *ReviewHandler) GetCourierReviews(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	courierID, err := extractUUIDFromPath(r.URL.Path, "/api/couriers/")
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid courier ID")
+		return
+	}
+
+	page, err := parsePageRequest(w, r, 100)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	reviews, err := h.reviewService.GetCourierReviews(r.Context(), courierID, page)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to get courier reviews")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, reviews)
+}
+
+// ListReviews возвращает отзывы в состоянии status (по умолчанию pending — очередь модерации)
+func (h *ReviewHandler) ListReviews(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeErrorResponse(w, http.StatusMethodNotAllowed, "Method not allowed")
+		return
+	}
+
+	status := models.ReviewStatusPending
+	if value := r.URL.Query().Get("status"); value != "" {
+		status = models.ReviewStatus(value)
+		if !status.IsValid() {
+			writeErrorResponse(w, http.StatusBadRequest, "Invalid review status")
+			return
+		}
+	}
+
+	page, err := parsePageRequest(w, r, 100)
+	if err != nil {
+		writeErrorResponse(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	reviews, err := h.reviewService.ListReviews(r.Context(), status, page)
+	if err != nil {
+		writeServiceError(w, h.log, err, "Failed to list reviews")
+		return
+	}
+
+	writeJSONResponse(w, http.StatusOK, reviews)
+}
+
+// invalidateCaches сбрасывает кеш заказа, курьера (рейтинг) и аналитики после изменения отзыва (best effort)
+func (h *ReviewHandler) invalidateCaches(ctx context.Context, review *models.Review) {
+	if h.redisClient == nil {
+		return
+	}
+	_ = h.redisClient.Delete(ctx, redis.GenerateKey(redis.KeyPrefixOrder, review.OrderID.String()))
+	_ = h.redisClient.Delete(ctx, redis.GenerateKey(redis.KeyPrefixCourier, review.CourierID.String()))
+	if err := h.redisClient.DeleteByPrefix(ctx, redis.KeyPrefixStats+":"); err != nil {
+		h.log.WithError(err).Warn("Failed to invalidate analytics cache")
+	}
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/config"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+	"delivery-system/internal/pagination"
+
+	"github.com/google/uuid"
+)
+
+type stubReviewService struct {
+	review  *models.Review
+	reviews []*models.Review
+	err     error
+
+	gotStatus     models.ReviewStatus
+	gotModeration *models.ModerateReviewRequest
+	gotReply      *models.ReviewReplyRequest
+}
+
+func (s *stubReviewService) CreateReview(ctx context.Context, orderID uuid.UUID, req *models.CreateReviewRequest) (*models.Review, error) {
+	return s.review, s.err
+}
+func (s *stubReviewService) UpdateReview(ctx context.Context, orderID uuid.UUID, req *models.UpdateReviewRequest) (*models.Review, error) {
+	return s.review, s.err
+}
+func (s *stubReviewService) ReplyToReview(ctx context.Context, reviewID uuid.UUID, req *models.ReviewReplyRequest) (*models.Review, error) {
+	s.gotReply = req
+	return s.review, s.err
+}
+func (s *stubReviewService) ModerateReview(ctx context.Context, reviewID uuid.UUID, req *models.ModerateReviewRequest) (*models.Review, error) {
+	s.gotModeration = req
+	return s.review, s.err
+}
+func (s *stubReviewService) GetCourierReviews(ctx context.Context, courierID uuid.UUID, page pagination.Request) (*pagination.Page[*models.Review], error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &pagination.Page[*models.Review]{Items: s.reviews}, nil
+}
+func (s *stubReviewService) ListReviews(ctx context.Context, status models.ReviewStatus, page pagination.Request) (*pagination.Page[*models.Review], error) {
+	s.gotStatus = status
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &pagination.Page[*models.Review]{Items: s.reviews}, nil
+}
+
+func newReviewHandler(svc *stubReviewService) *ReviewHandler {
+	log := logger.New(&config.LoggerConfig{Level: "error", Format: "json"})
+	return NewReviewHandler(svc, &stubRedis{}, log)
+}
+
+func TestReviewHandler_CreateReview(t *testing.T) {
+	orderID := uuid.New()
+	h := newReviewHandler(&stubReviewService{review: &models.Review{ID: uuid.New(), OrderID: orderID, Rating: 5}})
+
+	body := bytes.NewBufferString(`{"rating":5,"comment":"ok"}`)
+	req := httptest.NewRequest(http.MethodPost, "/api/orders/"+orderID.String()+"/review", body)
+	rr := httptest.NewRecorder()
+
+	h.CreateReview(rr, req)
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", rr.Code)
+	}
+}
+
+func TestReviewHandler_CreateReview_MethodNotAllowed(t *testing.T) {
+	h := newReviewHandler(&stubReviewService{})
+	req := httptest.NewRequest(http.MethodGet, "/api/orders/"+uuid.New().String()+"/review", nil)
+	rr := httptest.NewRecorder()
+	h.CreateReview(rr, req)
+	if rr.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", rr.Code)
+	}
+}
+
+func TestReviewHandler_CreateReview_Error(t *testing.T) {
+	orderID := uuid.New()
+	h := newReviewHandler(&stubReviewService{err: fmt.Errorf("fail")})
+
+	req := httptest.NewRequest(http.MethodPost, "/api/orders/"+orderID.String()+"/review", bytes.NewBufferString(`{"rating":5}`))
+	rr := httptest.NewRecorder()
+	h.CreateReview(rr, req)
+	if rr.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500, got %d", rr.Code)
+	}
+}
+
+func TestReviewHandler_UpdateReview(t *testing.T) {
+	orderID := uuid.New()
+	path := "/api/orders/" + orderID.String() + "/review"
+	svc := &stubReviewService{review: &models.Review{ID: uuid.New(), OrderID: orderID, Rating: 4, Status: models.ReviewStatusPublished}}
+	h := newReviewHandler(svc)
+
+	rr := httptest.NewRecorder()
+	h.UpdateReview(rr, httptest.NewRequest(http.MethodPut, path, bytes.NewBufferString(`{"rating":4,"comment":"better"}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+
+	svc.err = apperror.Conflict("review can only be edited within 48 hours", nil)
+	rr = httptest.NewRecorder()
+	h.UpdateReview(rr, httptest.NewRequest(http.MethodPut, path, bytes.NewBufferString(`{"rating":4}`)))
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d", rr.Code)
+	}
+}
+
+func TestReviewHandler_ReplyToReview(t *testing.T) {
+	reviewID := uuid.New()
+	path := "/api/reviews/" + reviewID.String() + "/reply"
+	svc := &stubReviewService{review: &models.Review{ID: reviewID}}
+	h := newReviewHandler(svc)
+
+	rr := httptest.NewRecorder()
+	h.ReplyToReview(rr, httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(`{"text":"Спасибо!"}`)))
+	if rr.Code != http.StatusOK || svc.gotReply.Text != "Спасибо!" {
+		t.Fatalf("unexpected response %d, reply %+v", rr.Code, svc.gotReply)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ReplyToReview(rr, httptest.NewRequest(http.MethodPost, "/api/reviews/bad/reply", bytes.NewBufferString(`{"text":"x"}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for invalid review id, got %d", rr.Code)
+	}
+
+	svc.err = apperror.Forbidden("only the reviewed courier can reply", nil)
+	rr = httptest.NewRecorder()
+	h.ReplyToReview(rr, httptest.NewRequest(http.MethodPost, path, bytes.NewBufferString(`{"text":"x"}`)))
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("expected 403, got %d", rr.Code)
+	}
+}
+
+func TestReviewHandler_ModerateReview(t *testing.T) {
+	reviewID := uuid.New()
+	path := "/api/reviews/" + reviewID.String() + "/moderation"
+	svc := &stubReviewService{review: &models.Review{ID: reviewID, Status: models.ReviewStatusHidden}}
+	h := newReviewHandler(svc)
+
+	rr := httptest.NewRecorder()
+	h.ModerateReview(rr, httptest.NewRequest(http.MethodPut, path, bytes.NewBufferString(`{"status":"hidden","reason":"abuse"}`)))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", rr.Code, rr.Body.String())
+	}
+	if svc.gotModeration.Status != models.ReviewStatusHidden || svc.gotModeration.Reason != "abuse" {
+		t.Fatalf("unexpected moderation request: %+v", svc.gotModeration)
+	}
+
+	svc.err = apperror.Validation("reason is required to hide a review", nil)
+	rr = httptest.NewRecorder()
+	h.ModerateReview(rr, httptest.NewRequest(http.MethodPut, path, bytes.NewBufferString(`{"status":"hidden"}`)))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rr.Code)
+	}
+}
+
+func TestReviewHandler_GetCourierReviews(t *testing.T) {
+	h := newReviewHandler(&stubReviewService{reviews: []*models.Review{{ID: uuid.New(), Rating: 5}}})
+	id := uuid.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/couriers/"+id.String()+"/reviews?limit=5&offset=0", nil)
+	rr := httptest.NewRecorder()
+	h.GetCourierReviews(rr, req)
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rr.Code)
+	}
+}
+
+func TestReviewHandler_ListReviews(t *testing.T) {
+	svc := &stubReviewService{reviews: []*models.Review{}}
+	h := newReviewHandler(svc)
+
+	rr := httptest.NewRecorder()
+	h.ListReviews(rr, httptest.NewRequest(http.MethodGet, "/api/reviews", nil))
+	if rr.Code != http.StatusOK || svc.gotStatus != models.ReviewStatusPending {
+		t.Fatalf("expected pending queue by default, got %d %q", rr.Code, svc.gotStatus)
+	}
+
+	rr = httptest.NewRecorder()
+	h.ListReviews(rr, httptest.NewRequest(http.MethodGet, "/api/reviews?status=deleted", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 for unknown status, got %d", rr.Code)
+	}
+}
@@ -2,11 +2,18 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
+	"delivery-system/internal/pagination"
+
 	"github.com/google/uuid"
 )
 
@@ -15,6 +22,23 @@ const (
 	defaultCacheTTL = 15 * time.Minute
 )
 
+// binaryContentType — тип, под которым хранится и отдается файл с типом не из белого списка.
+const binaryContentType = "application/octet-stream"
+
+// imageContentTypes — типы загружаемых изображений, которые сохраняются как есть.
+var imageContentTypes = []string{"image/jpeg", "image/png", "image/webp"}
+
+// documentContentTypes — типы документов курьера: сканы и фото.
+var documentContentTypes = append([]string{"application/pdf"}, imageContentTypes...)
+
+// fileExtensions — расширения имени файла в Content-Disposition.
+var fileExtensions = map[string]string{
+	"image/jpeg":      ".jpg",
+	"image/png":       ".png",
+	"image/webp":      ".webp",
+	"application/pdf": ".pdf",
+}
+
 // ErrorResponse представляет структуру ответа с ошибкой
 type ErrorResponse struct {
 	Error   string `json:"error"`
@@ -62,3 +86,113 @@ func extractUUIDFromPath(path, prefix string) (uuid.UUID, error) {
 
 	return id, nil
 }
+
+// extractPathSegment возвращает первый сегмент пути после prefix.
+func extractPathSegment(path, prefix string) (string, error) {
+	if !strings.HasPrefix(path, prefix) {
+		return "", fmt.Errorf("invalid path format")
+	}
+	segment := strings.Split(strings.TrimPrefix(path, prefix), "/")[0]
+	if segment == "" {
+		return "", fmt.Errorf("path parameter is required")
+	}
+	return segment, nil
+}
+
+// parsePageRequest читает параметры страницы списка: limit (по умолчанию 50, не больше maxLimit),
+// cursor из next_cursor предыдущей страницы и устаревший offset. Если передан offset без курсора,
+// в ответ добавляется заголовок Deprecation.
+func parsePageRequest(w http.ResponseWriter, r *http.Request, maxLimit int) (pagination.Request, error) {
+	query := r.URL.Query()
+	page := pagination.Request{Limit: 50}
+	if l := query.Get("limit"); l != "" {
+		if v, err := strconv.Atoi(l); err == nil && v > 0 && v <= maxLimit {
+			page.Limit = v
+		}
+	}
+
+	if token := query.Get("cursor"); token != "" {
+		cursor, err := pagination.Decode(token)
+		if err != nil {
+			return page, err
+		}
+		page.After = cursor
+		return page, nil
+	}
+
+	if o := query.Get("offset"); o != "" {
+		if v, err := strconv.Atoi(o); err == nil && v >= 0 {
+			page.Offset = v
+		}
+		w.Header().Set("Deprecation", "true")
+	}
+	return page, nil
+}
+
+// allowedContentType возвращает тип файла из белого списка allowed, иначе application/octet-stream.
+// Тип присылает клиент, поэтому ему нельзя доверять: text/html, отданный с нашего домена, — это XSS.
+func allowedContentType(contentType string, allowed []string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return binaryContentType
+	}
+	for _, t := range allowed {
+		if mediaType == t {
+			return mediaType
+		}
+	}
+	return binaryContentType
+}
+
+// uploadedFile — файл из поля file формы загрузки с типом, уже сверенным с белым списком.
+type uploadedFile struct {
+	multipart.File
+	ContentType string
+}
+
+// parseUpload разбирает multipart-форму не больше maxBytes и открывает поле file. При ошибке
+// ответ уже записан и возвращается false. Вызывающий закрывает файл и удаляет временные файлы формы
+// (r.MultipartForm.RemoveAll); поля формы доступны через r.FormValue.
+func parseUpload(w http.ResponseWriter, r *http.Request, maxBytes int64, allowed []string) (*uploadedFile, bool) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+	if err := r.ParseMultipartForm(maxBytes); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			writeErrorResponse(w, http.StatusRequestEntityTooLarge, "File is too large")
+			return nil, false
+		}
+		writeErrorResponse(w, http.StatusBadRequest, "Invalid multipart form")
+		return nil, false
+	}
+
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		_ = r.MultipartForm.RemoveAll()
+		writeErrorResponse(w, http.StatusBadRequest, "file is required")
+		return nil, false
+	}
+	return &uploadedFile{File: file, ContentType: allowedContentType(header.Header.Get("Content-Type"), allowed)}, true
+}
+
+// serveStoredFile отдает загруженный файл как вложение: тип повторно сверяется с белым списком
+// (в хранилище могли остаться старые записи), браузеру запрещено угадывать тип по содержимому.
+func serveStoredFile(w http.ResponseWriter, content io.Reader, contentType *string, size int64, name string, allowed []string) error {
+	storedType := ""
+	if contentType != nil {
+		storedType = *contentType
+	}
+	safeType := allowedContentType(storedType, allowed)
+	ext, ok := fileExtensions[safeType]
+	if !ok {
+		ext = ".bin"
+	}
+
+	w.Header().Set("Content-Type", safeType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", name+ext))
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.WriteHeader(http.StatusOK)
+
+	_, err := io.Copy(w, content)
+	return err
+}
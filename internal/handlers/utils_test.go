@@ -35,3 +35,26 @@ func TestWriteJSONResponse(t *testing.T) {
 		t.Fatalf("empty body")
 	}
 }
+
+func TestAllowedContentType(t *testing.T) {
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"image/png", "image/png"},
+		{"IMAGE/JPEG", "image/jpeg"},
+		{"application/pdf", "application/pdf"},
+		{"text/html", "application/octet-stream"},
+		{"image/svg+xml", "application/octet-stream"},
+		{"", "application/octet-stream"},
+		{"not a type", "application/octet-stream"},
+	}
+	for _, tc := range cases {
+		if got := allowedContentType(tc.in, documentContentTypes); got != tc.want {
+			t.Fatalf("allowedContentType(%q) = %q, want %q", tc.in, got, tc.want)
+		}
+	}
+	if got := allowedContentType("application/pdf", imageContentTypes); got != "application/octet-stream" {
+		t.Fatalf("pdf must not pass the image whitelist, got %q", got)
+	}
+}
@@ -69,6 +69,8 @@ func (p *Producer) PublishOrderCreated(order *models.Order) error {
 			CustomerPhone:   order.CustomerPhone,
 			DeliveryAddress: order.DeliveryAddress,
 			TotalAmount:     order.TotalAmount,
+			Currency:        order.Currency,
+			Region:          order.Region,
 		},
 	}
 
@@ -93,6 +95,34 @@ func (p *Producer) PublishOrderStatusChanged(orderID uuid.UUID, oldStatus, newSt
 	return p.publishEvent(p.topics.Orders, event)
 }
 
+// PublishOrderUpdated публикует событие изменения заказа
+func (p *Producer) PublishOrderUpdated(orderID uuid.UUID, changes []models.FieldChange) error {
+	event := models.Event{
+		ID:        uuid.New(),
+		Type:      models.EventTypeOrderUpdated,
+		Timestamp: time.Now(),
+		Data: models.OrderUpdatedEvent{
+			OrderID:   orderID,
+			Changes:   changes,
+			Timestamp: time.Now(),
+		},
+	}
+
+	return p.publishEvent(p.topics.Orders, event)
+}
+
+// PublishReceiptIssued публикует событие выдачи чека по заказу
+func (p *Producer) PublishReceiptIssued(receipt *models.Receipt) error {
+	event := models.Event{
+		ID:        uuid.New(),
+		Type:      models.EventTypeReceiptIssued,
+		Timestamp: time.Now(),
+		Data:      receipt,
+	}
+
+	return p.publishEvent(p.topics.Orders, event)
+}
+
 // PublishCourierAssigned публикует событие назначения курьера
 func (p *Producer) PublishCourierAssigned(orderID, courierID uuid.UUID) error {
 	event := models.Event{
@@ -109,6 +139,24 @@ func (p *Producer) PublishCourierAssigned(orderID, courierID uuid.UUID) error {
 	return p.publishEvent(p.topics.Couriers, event)
 }
 
+// PublishCourierUnassigned публикует событие снятия курьера с заказа; newCourierID задан при переназначении
+func (p *Producer) PublishCourierUnassigned(orderID, courierID uuid.UUID, newCourierID *uuid.UUID, reason string) error {
+	event := models.Event{
+		ID:        uuid.New(),
+		Type:      models.EventTypeCourierUnassigned,
+		Timestamp: time.Now(),
+		Data: models.CourierUnassignedEvent{
+			OrderID:      orderID,
+			CourierID:    courierID,
+			NewCourierID: newCourierID,
+			Reason:       reason,
+			Timestamp:    time.Now(),
+		},
+	}
+
+	return p.publishEvent(p.topics.Couriers, event)
+}
+
 // PublishCourierStatusChanged публикует событие изменения статуса курьера
 func (p *Producer) PublishCourierStatusChanged(courierID uuid.UUID, oldStatus, newStatus models.CourierStatus) error {
 	event := models.Event{
@@ -6,6 +6,7 @@ import (
 	"delivery-system/internal/config"
 	"delivery-system/internal/logger"
 	"delivery-system/internal/models"
+	"delivery-system/internal/money"
 
 	"github.com/IBM/sarama"
 	"github.com/IBM/sarama/mocks"
@@ -35,7 +36,7 @@ func TestPublishEvent(t *testing.T) {
 func TestProducer_WrapperMethods(t *testing.T) {
 	cfg := sarama.NewConfig()
 	mp := mocks.NewSyncProducer(t, cfg)
-	for i := 0; i < 5; i++ {
+	for i := 0; i < 8; i++ {
 		mp.ExpectSendMessageAndSucceed()
 	}
 
@@ -47,7 +48,7 @@ func TestProducer_WrapperMethods(t *testing.T) {
 
 	orderID := uuid.New()
 	courierID := uuid.New()
-	order := &models.Order{ID: orderID, CustomerName: "n", CustomerPhone: "p", DeliveryAddress: "addr", TotalAmount: 10}
+	order := &models.Order{ID: orderID, CustomerName: "n", CustomerPhone: "p", DeliveryAddress: "addr", TotalAmount: money.New(1000, "RUB"), Currency: "RUB"}
 
 	if err := p.PublishOrderCreated(order); err != nil {
 		t.Fatalf("PublishOrderCreated failed: %v", err)
@@ -55,15 +56,24 @@ func TestProducer_WrapperMethods(t *testing.T) {
 	if err := p.PublishOrderStatusChanged(orderID, models.OrderStatusCreated, models.OrderStatusDelivered, &courierID); err != nil {
 		t.Fatalf("PublishOrderStatusChanged failed: %v", err)
 	}
+	if err := p.PublishOrderUpdated(orderID, []models.FieldChange{{Field: "delivery_address", Old: "addr", New: "new addr"}}); err != nil {
+		t.Fatalf("PublishOrderUpdated failed: %v", err)
+	}
 	if err := p.PublishCourierAssigned(orderID, courierID); err != nil {
 		t.Fatalf("PublishCourierAssigned failed: %v", err)
 	}
+	if err := p.PublishCourierUnassigned(orderID, courierID, nil, "courier had an accident"); err != nil {
+		t.Fatalf("PublishCourierUnassigned failed: %v", err)
+	}
 	if err := p.PublishCourierStatusChanged(courierID, models.CourierStatusAvailable, models.CourierStatusBusy); err != nil {
 		t.Fatalf("PublishCourierStatusChanged failed: %v", err)
 	}
 	if err := p.PublishLocationUpdated(courierID, 1, 2); err != nil {
 		t.Fatalf("PublishLocationUpdated failed: %v", err)
 	}
+	if err := p.PublishReceiptIssued(&models.Receipt{ID: uuid.New(), Number: "R-00000001", OrderID: orderID, Total: money.New(1000, "RUB")}); err != nil {
+		t.Fatalf("PublishReceiptIssued failed: %v", err)
+	}
 }
 
 func TestProducer_PublishEvent_Failure(t *testing.T) {
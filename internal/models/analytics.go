@@ -3,6 +3,8 @@ package models
 import (
 	"time"
 
+	"delivery-system/internal/money"
+
 	"github.com/google/uuid"
 )
 
@@ -24,43 +26,80 @@ type AnalyticsFilter struct {
 	TopItemsLimit  int
 	CourierLimit   int
 	IncludePeriods bool
+	Region         string // код региона, пустой — все регионы
+	Currency       string // валюта выручки, пустая — определяется по заказам периода
 }
 
 // KPIMetrics описывает бизнес-показатели за период.
 type KPIMetrics struct {
 	From                   time.Time   `json:"from"`
 	To                     time.Time   `json:"to"`
-	Revenue                float64     `json:"revenue"`
+	Currency               string      `json:"currency"`
+	Region                 string      `json:"region,omitempty"`
+	Revenue                money.Money `json:"revenue"`
 	OrdersCount            int         `json:"orders_count"`
 	AvgDeliveryTimeMinutes float64     `json:"avg_delivery_time_minutes"`
-	AverageCheck           float64     `json:"average_check"`
+	AverageCheck           money.Money `json:"average_check"`
 	TopItems               []TopItem   `json:"top_items"`
 	Periods                []KPIPeriod `json:"periods,omitempty"`
 	GeneratedAt            time.Time   `json:"generated_at"`
 	GroupBy                string      `json:"group_by,omitempty"`
+	// ConversionRates заполняется, только если выручка разных валют пересчитана в Currency
+	ConversionRates map[string]float64 `json:"conversion_rates,omitempty"`
 }
 
 // KPIPeriod хранит агрегированные метрики по периоду.
 type KPIPeriod struct {
-	Period                 string  `json:"period"`
-	Revenue                float64 `json:"revenue"`
-	OrdersCount            int     `json:"orders_count"`
-	AvgDeliveryTimeMinutes float64 `json:"avg_delivery_time_minutes"`
+	Period                 string      `json:"period"`
+	Revenue                money.Money `json:"revenue"`
+	OrdersCount            int         `json:"orders_count"`
+	AvgDeliveryTimeMinutes float64     `json:"avg_delivery_time_minutes"`
 }
 
 // TopItem описывает популярный товар в заказах.
 type TopItem struct {
-	Name     string  `json:"name"`
-	Quantity int     `json:"quantity"`
-	Revenue  float64 `json:"revenue"`
+	Name     string      `json:"name"`
+	Quantity int         `json:"quantity"`
+	Revenue  money.Money `json:"revenue"`
 }
 
 // CourierAnalytics агрегирует метрики по курьерам.
 type CourierAnalytics struct {
-	CourierID              uuid.UUID `json:"courier_id"`
-	CourierName            string    `json:"courier_name"`
-	Rating                 float64   `json:"rating"`
-	Deliveries             int       `json:"deliveries"`
-	Revenue                float64   `json:"revenue"`
-	AvgDeliveryTimeMinutes float64   `json:"avg_delivery_time_minutes"`
+	CourierID              uuid.UUID   `json:"courier_id"`
+	CourierName            string      `json:"courier_name"`
+	Rating                 float64     `json:"rating"`
+	Deliveries             int         `json:"deliveries"`
+	Revenue                money.Money `json:"revenue"`
+	Currency               string      `json:"currency"`
+	AvgDeliveryTimeMinutes float64     `json:"avg_delivery_time_minutes"`
+	// Tags — сколько раз тег встретился в нескрытых отзывах по доставкам периода
+	Tags map[ReviewTag]int `json:"tags,omitempty"`
+}
+
+// MerchantAnalytics агрегирует отзывы по заведению (orders.merchant_name).
+type MerchantAnalytics struct {
+	MerchantName string            `json:"merchant_name"`
+	Reviews      int               `json:"reviews"`       // нескрытые отзывы по доставкам периода
+	RatedReviews int               `json:"rated_reviews"` // из них с оценкой заведения
+	Rating       float64           `json:"rating"`        // средняя оценка заведения, 0 — оценок нет
+	Tags         map[ReviewTag]int `json:"tags,omitempty"`
+}
+
+// StageTiming — сколько времени заказы проводят в статусе до следующего изменения.
+type StageTiming struct {
+	Status      OrderStatus `json:"status"`
+	Transitions int         `json:"transitions"` // сколько раз заказы вышли из статуса за период
+	AvgMinutes  float64     `json:"avg_minutes"`
+	P50Minutes  float64     `json:"p50_minutes"`
+	P90Minutes  float64     `json:"p90_minutes"`
+	MaxMinutes  float64     `json:"max_minutes"`
+}
+
+// StageTimings — время по этапам для заказов, созданных за период.
+type StageTimings struct {
+	From        time.Time     `json:"from"`
+	To          time.Time     `json:"to"`
+	Region      string        `json:"region,omitempty"`
+	Stages      []StageTiming `json:"stages"`
+	GeneratedAt time.Time     `json:"generated_at"`
 }
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+
+	"delivery-system/internal/money"
+
+	"github.com/google/uuid"
+)
+
+// Campaign — маркетинговая кампания, владеющая шаблоном скидки для массово выпущенных промокодов.
+type Campaign struct {
+	ID           uuid.UUID     `json:"id" db:"id"`
+	Name         string        `json:"name" db:"name"`
+	DiscountType DiscountType  `json:"discount_type" db:"discount_type"`
+	Amount       money.Money   `json:"amount" db:"amount"` // для percent — размер скидки в процентах
+	Currency     string        `json:"currency" db:"currency"`
+	MaxUses      int           `json:"max_uses" db:"max_uses"` // лимит использований каждого кода, 0 = безлимит
+	ExpiresAt    *time.Time    `json:"expires_at,omitempty" db:"expires_at"`
+	Active       bool          `json:"active" db:"active"`
+	Rules        PromoRules    `json:"rules" db:"rules"`
+	Stacking     PromoStacking `json:"stacking" db:"stacking"`
+	CodesCount   int           `json:"codes_count" db:"codes_count"`
+	CreatedAt    time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at" db:"updated_at"`
+}
+
+// CreateCampaignRequest описывает запрос на создание кампании.
+type CreateCampaignRequest struct {
+	Name         string        `json:"name"`
+	DiscountType DiscountType  `json:"discount_type"`
+	Amount       money.Money   `json:"amount"`
+	Currency     string        `json:"currency,omitempty"` // по умолчанию — валюта сервиса
+	MaxUses      int           `json:"max_uses,omitempty"`
+	ExpiresAt    *time.Time    `json:"expires_at,omitempty"`
+	Rules        PromoRules    `json:"rules"`
+	Stacking     PromoStacking `json:"stacking,omitempty"` // по умолчанию exclusive
+}
+
+// GenerateCodesRequest описывает выпуск пачки случайных кодов кампании.
+type GenerateCodesRequest struct {
+	Count    int    `json:"count"`
+	Prefix   string `json:"prefix,omitempty"`
+	Alphabet string `json:"alphabet,omitempty"` // по умолчанию — заглавные буквы и цифры без похожих символов
+	Length   int    `json:"length,omitempty"`   // длина случайной части, по умолчанию 8
+}
+
+// GenerateCodesResult — итог выпуска кодов.
+type GenerateCodesResult struct {
+	CampaignID uuid.UUID `json:"campaign_id"`
+	Generated  int       `json:"generated"`
+	CodesCount int       `json:"codes_count"`
+}
+
+// CampaignStats — статистика использования кодов кампании.
+type CampaignStats struct {
+	CampaignID     uuid.UUID   `json:"campaign_id"`
+	Codes          int         `json:"codes"`
+	ActiveCodes    int         `json:"active_codes"`
+	RedeemedCodes  int         `json:"redeemed_codes"` // коды, использованные хотя бы раз
+	Redemptions    int         `json:"redemptions"`
+	RedemptionRate float64     `json:"redemption_rate"` // доля использованных кодов
+	Orders         int         `json:"orders"`          // заказы с кодами кампании, кроме отмененных
+	DiscountTotal  money.Money `json:"discount_total"`
+	OrdersTotal    money.Money `json:"orders_total"`
+	Currency       string      `json:"currency"`
+}
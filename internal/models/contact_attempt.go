@@ -0,0 +1,80 @@
+package models
+
+import (
+	"time"
+
+	"delivery-system/internal/money"
+
+	"github.com/google/uuid"
+)
+
+// ContactChannel — способ связи с клиентом при вручении.
+type ContactChannel string
+
+const (
+	ContactChannelCall      ContactChannel = "call"
+	ContactChannelSMS       ContactChannel = "sms"
+	ContactChannelMessenger ContactChannel = "messenger"
+	ContactChannelDoorbell  ContactChannel = "doorbell"
+)
+
+// IsValid сообщает, известен ли способ связи.
+func (c ContactChannel) IsValid() bool {
+	switch c {
+	case ContactChannelCall, ContactChannelSMS, ContactChannelMessenger, ContactChannelDoorbell:
+		return true
+	default:
+		return false
+	}
+}
+
+// ContactResult — итог попытки связаться с клиентом.
+type ContactResult string
+
+const (
+	ContactResultNoAnswer     ContactResult = "no_answer"
+	ContactResultUnreachable  ContactResult = "unreachable"
+	ContactResultWrongAddress ContactResult = "wrong_address"
+	ContactResultRefused      ContactResult = "refused"
+	ContactResultReached      ContactResult = "reached"
+)
+
+// IsValid сообщает, известен ли итог попытки.
+func (r ContactResult) IsValid() bool {
+	switch r {
+	case ContactResultNoAnswer, ContactResultUnreachable, ContactResultWrongAddress, ContactResultRefused, ContactResultReached:
+		return true
+	default:
+		return false
+	}
+}
+
+// ContactAttempt — запись о попытке связаться с клиентом во время доставки.
+type ContactAttempt struct {
+	ID        uuid.UUID      `json:"id" db:"id"`
+	OrderID   uuid.UUID      `json:"order_id" db:"order_id"`
+	CourierID *uuid.UUID     `json:"courier_id,omitempty" db:"courier_id"`
+	Attempt   int            `json:"attempt" db:"attempt"` // номер попытки вручения, начиная с 1
+	Channel   ContactChannel `json:"channel" db:"channel"`
+	Result    ContactResult  `json:"result" db:"result"`
+	Note      *string        `json:"note,omitempty" db:"note"`
+	LoggedBy  string         `json:"logged_by" db:"logged_by"`
+	CreatedAt time.Time      `json:"created_at" db:"created_at"`
+}
+
+// LogContactAttemptRequest — запрос на запись попытки связи с клиентом.
+type LogContactAttemptRequest struct {
+	Channel ContactChannel `json:"channel"`
+	Result  ContactResult  `json:"result"`
+	Note    *string        `json:"note,omitempty"`
+}
+
+// DeliveryAttempts — попытки вручения заказа и связи с клиентом.
+type DeliveryAttempts struct {
+	OrderID     uuid.UUID        `json:"order_id"`
+	Status      OrderStatus      `json:"status"`
+	Failed      int              `json:"failed"`       // неудачных попыток вручения
+	MaxAttempts int              `json:"max_attempts"` // попыток до обязательного возврата
+	ReturnCost  *money.Money     `json:"return_cost,omitempty"`
+	Contacts    []ContactAttempt `json:"contacts"`
+}
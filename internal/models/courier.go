@@ -28,12 +28,40 @@ type Courier struct {
 	CreatedAt    time.Time     `json:"created_at" db:"created_at"`
 	UpdatedAt    time.Time     `json:"updated_at" db:"updated_at"`
 	LastSeenAt   *time.Time    `json:"last_seen_at,omitempty" db:"last_seen_at"`
+	VehicleType  VehicleType   `json:"vehicle_type" db:"vehicle_type"`
+	// Ограничения транспорта; пустое значение — без ограничения
+	MaxWeightKg *float64 `json:"max_weight_kg,omitempty" db:"max_weight_kg"`
+	MaxVolumeL  *float64 `json:"max_volume_l,omitempty" db:"max_volume_l"`
+	MaxRangeKm  *float64 `json:"max_range_km,omitempty" db:"max_range_km"`
+	// Этап подключения; заказы получают только verified
+	OnboardingStatus OnboardingStatus `json:"onboarding_status" db:"onboarding_status"`
+	OnboardingReason *string          `json:"onboarding_reason,omitempty" db:"onboarding_reason"`
+	VerifiedAt       *time.Time       `json:"verified_at,omitempty" db:"verified_at"`
+}
+
+// CanCarry сообщает, может ли курьер взять груз и проехать маршрут заданной длины.
+func (c *Courier) CanCarry(weightKg, volumeL, distanceKm float64) bool {
+	if c.MaxWeightKg != nil && weightKg > *c.MaxWeightKg {
+		return false
+	}
+	if c.MaxVolumeL != nil && volumeL > *c.MaxVolumeL {
+		return false
+	}
+	if c.MaxRangeKm != nil && distanceKm > *c.MaxRangeKm {
+		return false
+	}
+	return true
 }
 
 // CreateCourierRequest представляет запрос на создание курьера
 type CreateCourierRequest struct {
-	Name  string `json:"name"`
-	Phone string `json:"phone"`
+	Name        string      `json:"name"`
+	Phone       string      `json:"phone"`
+	VehicleType VehicleType `json:"vehicle_type,omitempty"` // пустой — bike
+	// Ограничения транспорта; не указанные берутся из типового профиля транспорта
+	MaxWeightKg *float64 `json:"max_weight_kg,omitempty"`
+	MaxVolumeL  *float64 `json:"max_volume_l,omitempty"`
+	MaxRangeKm  *float64 `json:"max_range_km,omitempty"`
 }
 
 // UpdateCourierStatusRequest представляет запрос на обновление статуса курьера
@@ -0,0 +1,126 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OnboardingStatus — этап подключения курьера. Брать заказы может только verified.
+type OnboardingStatus string
+
+const (
+	OnboardingStatusApplied            OnboardingStatus = "applied"
+	OnboardingStatusDocumentsSubmitted OnboardingStatus = "documents_submitted"
+	OnboardingStatusVerified           OnboardingStatus = "verified"
+	OnboardingStatusSuspended          OnboardingStatus = "suspended"
+	OnboardingStatusDeactivated        OnboardingStatus = "deactivated"
+)
+
+// IsValid сообщает, известен ли этап подключения.
+func (s OnboardingStatus) IsValid() bool {
+	switch s {
+	case OnboardingStatusApplied, OnboardingStatusDocumentsSubmitted, OnboardingStatusVerified,
+		OnboardingStatusSuspended, OnboardingStatusDeactivated:
+		return true
+	default:
+		return false
+	}
+}
+
+// onboardingTransitions — допустимые переходы между этапами подключения. Возврат из documents_submitted
+// в applied — отказ в проверке; deactivated — конечный этап.
+var onboardingTransitions = map[OnboardingStatus][]OnboardingStatus{
+	OnboardingStatusApplied:            {OnboardingStatusDocumentsSubmitted, OnboardingStatusDeactivated},
+	OnboardingStatusDocumentsSubmitted: {OnboardingStatusVerified, OnboardingStatusApplied, OnboardingStatusDeactivated},
+	OnboardingStatusVerified:           {OnboardingStatusSuspended, OnboardingStatusDeactivated},
+	OnboardingStatusSuspended:          {OnboardingStatusVerified, OnboardingStatusDeactivated},
+}
+
+// CanTransitionTo сообщает, можно ли перейти с этапа s на этап next.
+func (s OnboardingStatus) CanTransitionTo(next OnboardingStatus) bool {
+	for _, allowed := range onboardingTransitions[s] {
+		if allowed == next {
+			return true
+		}
+	}
+	return false
+}
+
+// RequiresReason сообщает, нужна ли причина для перехода на этап s с этапа from.
+func (s OnboardingStatus) RequiresReason(from OnboardingStatus) bool {
+	switch s {
+	case OnboardingStatusSuspended, OnboardingStatusDeactivated:
+		return true
+	case OnboardingStatusApplied:
+		return from == OnboardingStatusDocumentsSubmitted
+	default:
+		return false
+	}
+}
+
+// MaxOnboardingReasonLength ограничивает причину отказа, приостановки или отключения.
+const MaxOnboardingReasonLength = 500
+
+// DocumentType — вид документа курьера.
+type DocumentType string
+
+const (
+	DocumentTypeIdentity            DocumentType = "identity"
+	DocumentTypeDriverLicense       DocumentType = "driver_license"
+	DocumentTypeVehicleRegistration DocumentType = "vehicle_registration"
+	DocumentTypeInsurance           DocumentType = "insurance"
+)
+
+// IsValid сообщает, известен ли вид документа.
+func (t DocumentType) IsValid() bool {
+	switch t {
+	case DocumentTypeIdentity, DocumentTypeDriverLicense, DocumentTypeVehicleRegistration, DocumentTypeInsurance:
+		return true
+	default:
+		return false
+	}
+}
+
+// Expires сообщает, есть ли у документа срок действия, который нужно указать при загрузке.
+func (t DocumentType) Expires() bool {
+	return t == DocumentTypeDriverLicense || t == DocumentTypeInsurance
+}
+
+// RequiredDocuments возвращает документы, без которых курьера нельзя подтвердить:
+// удостоверение личности для всех, права и страховка для моторного транспорта.
+func RequiredDocuments(vehicle VehicleType) []DocumentType {
+	if vehicle == VehicleTypeScooter || vehicle == VehicleTypeCar {
+		return []DocumentType{DocumentTypeIdentity, DocumentTypeDriverLicense, DocumentTypeInsurance}
+	}
+	return []DocumentType{DocumentTypeIdentity}
+}
+
+// CourierDocument — загруженный документ курьера; содержимое хранится в BlobStorage под ключом StorageKey.
+type CourierDocument struct {
+	ID          uuid.UUID    `json:"id" db:"id"`
+	CourierID   uuid.UUID    `json:"courier_id" db:"courier_id"`
+	Type        DocumentType `json:"type" db:"doc_type"`
+	StorageKey  string       `json:"-" db:"storage_key"`
+	ContentType *string      `json:"content_type,omitempty" db:"content_type"`
+	SizeBytes   int64        `json:"size_bytes" db:"size_bytes"`
+	ExpiresAt   *time.Time   `json:"expires_at,omitempty" db:"expires_at"`
+	UploadedAt  time.Time    `json:"uploaded_at" db:"uploaded_at"`
+}
+
+// UpdateOnboardingRequest — перевод курьера на другой этап подключения.
+type UpdateOnboardingRequest struct {
+	Status OnboardingStatus `json:"status"`
+	Reason string           `json:"reason,omitempty"` // обязательна для отказа, приостановки и отключения
+}
+
+// OnboardingChange — результат смены этапа подключения.
+type OnboardingChange struct {
+	CourierID uuid.UUID        `json:"courier_id"`
+	OldStatus OnboardingStatus `json:"old_status"`
+	Status    OnboardingStatus `json:"status"`
+	Reason    *string          `json:"reason,omitempty"`
+	// Статус доступности до и после смены; при приостановке и отключении курьер уходит в offline
+	OldCourierStatus CourierStatus `json:"old_courier_status"`
+	CourierStatus    CourierStatus `json:"courier_status"`
+}
@@ -3,6 +3,8 @@ package models
 import (
 	"time"
 
+	"delivery-system/internal/money"
+
 	"github.com/google/uuid"
 )
 
@@ -12,9 +14,12 @@ type EventType string
 const (
 	EventTypeOrderCreated         EventType = "order.created"
 	EventTypeOrderStatusChanged   EventType = "order.status_changed"
+	EventTypeOrderUpdated         EventType = "order.updated"
 	EventTypeCourierAssigned      EventType = "courier.assigned"
+	EventTypeCourierUnassigned    EventType = "courier.unassigned"
 	EventTypeCourierStatusChanged EventType = "courier.status_changed"
 	EventTypeLocationUpdated      EventType = "location.updated"
+	EventTypeReceiptIssued        EventType = "receipt.issued"
 )
 
 // Event представляет базовое событие
@@ -27,11 +32,13 @@ type Event struct {
 
 // OrderCreatedEvent представляет событие создания заказа
 type OrderCreatedEvent struct {
-	OrderID         uuid.UUID `json:"order_id"`
-	CustomerName    string    `json:"customer_name"`
-	CustomerPhone   string    `json:"customer_phone"`
-	DeliveryAddress string    `json:"delivery_address"`
-	TotalAmount     float64   `json:"total_amount"`
+	OrderID         uuid.UUID   `json:"order_id"`
+	CustomerName    string      `json:"customer_name"`
+	CustomerPhone   string      `json:"customer_phone"`
+	DeliveryAddress string      `json:"delivery_address"`
+	TotalAmount     money.Money `json:"total_amount"`
+	Currency        string      `json:"currency"`
+	Region          string      `json:"region"`
 }
 
 // OrderStatusChangedEvent представляет событие изменения статуса заказа
@@ -43,6 +50,13 @@ type OrderStatusChangedEvent struct {
 	Timestamp time.Time   `json:"timestamp"`
 }
 
+// OrderUpdatedEvent представляет событие изменения заказа с изменениями по полям
+type OrderUpdatedEvent struct {
+	OrderID   uuid.UUID     `json:"order_id"`
+	Changes   []FieldChange `json:"changes"`
+	Timestamp time.Time     `json:"timestamp"`
+}
+
 // CourierAssignedEvent представляет событие назначения курьера
 type CourierAssignedEvent struct {
 	OrderID   uuid.UUID `json:"order_id"`
@@ -50,6 +64,15 @@ type CourierAssignedEvent struct {
 	Timestamp time.Time `json:"timestamp"`
 }
 
+// CourierUnassignedEvent представляет событие снятия курьера с заказа диспетчером
+type CourierUnassignedEvent struct {
+	OrderID      uuid.UUID  `json:"order_id"`
+	CourierID    uuid.UUID  `json:"courier_id"`
+	NewCourierID *uuid.UUID `json:"new_courier_id,omitempty"` // курьер, которому передан заказ
+	Reason       string     `json:"reason"`
+	Timestamp    time.Time  `json:"timestamp"`
+}
+
 // CourierStatusChangedEvent представляет событие изменения статуса курьера
 type CourierStatusChangedEvent struct {
 	CourierID uuid.UUID     `json:"courier_id"`
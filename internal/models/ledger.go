@@ -0,0 +1,109 @@
+package models
+
+import (
+	"time"
+
+	"delivery-system/internal/money"
+
+	"github.com/google/uuid"
+)
+
+// LedgerKind описывает вид начисления курьеру.
+type LedgerKind string
+
+const (
+	LedgerKindDelivery   LedgerKind = "delivery"
+	LedgerKindTip        LedgerKind = "tip"
+	LedgerKindBonus      LedgerKind = "bonus"
+	LedgerKindAdjustment LedgerKind = "adjustment"
+)
+
+// LedgerDirection задает сторону проводки.
+type LedgerDirection string
+
+const (
+	LedgerDebit  LedgerDirection = "debit"
+	LedgerCredit LedgerDirection = "credit"
+)
+
+// Счета журнала. Баланс курьера — кредитовое сальдо courier_payable.
+const (
+	LedgerAccountCourierPayable     = "courier_payable"
+	LedgerAccountDeliveryExpense    = "delivery_expense"
+	LedgerAccountCustomerTips       = "customer_tips"
+	LedgerAccountCourierAdjustments = "courier_adjustments"
+)
+
+// LedgerEntry представляет строку проводки (дебет или кредит счета).
+type LedgerEntry struct {
+	ID            uuid.UUID       `json:"id" db:"id"`
+	TransactionID uuid.UUID       `json:"transaction_id" db:"transaction_id"`
+	Account       string          `json:"account" db:"account"`
+	CourierID     *uuid.UUID      `json:"courier_id,omitempty" db:"courier_id"`
+	Direction     LedgerDirection `json:"direction" db:"direction"`
+	Amount        money.Money     `json:"amount" db:"amount"`
+	CreatedAt     time.Time       `json:"created_at" db:"created_at"`
+}
+
+// LedgerTransaction представляет сбалансированную проводку по курьеру.
+// Amount — итоговое изменение баланса курьера (отрицательное для удержаний).
+type LedgerTransaction struct {
+	ID          uuid.UUID     `json:"id" db:"id"`
+	CourierID   uuid.UUID     `json:"courier_id" db:"courier_id"`
+	OrderID     *uuid.UUID    `json:"order_id,omitempty" db:"order_id"`
+	Kind        LedgerKind    `json:"kind" db:"kind"`
+	Amount      money.Money   `json:"amount" db:"amount"`
+	Currency    string        `json:"currency" db:"currency"`
+	Description *string       `json:"description,omitempty" db:"description"`
+	CreatedAt   time.Time     `json:"created_at" db:"created_at"`
+	Entries     []LedgerEntry `json:"entries,omitempty"`
+}
+
+// CreateTipRequest представляет запрос на чаевые курьеру по заказу; сумма — в валюте заказа
+type CreateTipRequest struct {
+	Amount money.Money `json:"amount"`
+}
+
+// CreateAdjustmentRequest представляет ручное начисление или удержание
+type CreateAdjustmentRequest struct {
+	Kind        LedgerKind  `json:"kind"` // bonus | adjustment
+	Amount      money.Money `json:"amount"`
+	Currency    string      `json:"currency,omitempty"` // по умолчанию — валюта заказа или сервиса
+	OrderID     *uuid.UUID  `json:"order_id,omitempty"`
+	Description *string     `json:"description,omitempty"`
+}
+
+// CourierBalance представляет текущий баланс курьера в одной валюте с разбивкой по видам начислений
+type CourierBalance struct {
+	CourierID   uuid.UUID   `json:"courier_id"`
+	Currency    string      `json:"currency"`
+	Balance     money.Money `json:"balance"`
+	Deliveries  money.Money `json:"deliveries"`
+	Tips        money.Money `json:"tips"`
+	Bonuses     money.Money `json:"bonuses"`
+	Adjustments money.Money `json:"adjustments"`
+}
+
+// CourierStatement представляет выписку по курьеру за период в одной валюте
+type CourierStatement struct {
+	CourierID      uuid.UUID            `json:"courier_id"`
+	Currency       string               `json:"currency"`
+	From           time.Time            `json:"from"`
+	To             time.Time            `json:"to"`
+	OpeningBalance money.Money          `json:"opening_balance"`
+	ClosingBalance money.Money          `json:"closing_balance"`
+	Transactions   []*LedgerTransaction `json:"transactions"`
+}
+
+// PayoutLine представляет строку реестра выплат за период: курьер и валюта начислений
+type PayoutLine struct {
+	CourierID   uuid.UUID   `json:"courier_id"`
+	CourierName string      `json:"courier_name"`
+	Currency    string      `json:"currency"`
+	Deliveries  int         `json:"deliveries"`
+	Earnings    money.Money `json:"earnings"`
+	Tips        money.Money `json:"tips"`
+	Bonuses     money.Money `json:"bonuses"`
+	Adjustments money.Money `json:"adjustments"`
+	Total       money.Money `json:"total"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// LoyaltyTransactionKind описывает вид операции с баллами лояльности.
+type LoyaltyTransactionKind string
+
+const (
+	LoyaltyKindEarn     LoyaltyTransactionKind = "earn"     // начисление за доставленный заказ
+	LoyaltyKindRedeem   LoyaltyTransactionKind = "redeem"   // списание в счет заказа
+	LoyaltyKindExpire   LoyaltyTransactionKind = "expire"   // сгорание баллов с истекшим сроком
+	LoyaltyKindReversal LoyaltyTransactionKind = "reversal" // возврат списанных баллов или отзыв начисленных
+)
+
+// LoyaltyTransaction — операция по счету баллов. Начисления положительные, списания отрицательные.
+// Положительные операции образуют партии баллов со своим сроком действия и непотраченным остатком.
+type LoyaltyTransaction struct {
+	ID            uuid.UUID              `json:"id" db:"id"`
+	CustomerPhone string                 `json:"customer_phone" db:"customer_phone"`
+	Kind          LoyaltyTransactionKind `json:"kind" db:"kind"`
+	Points        int                    `json:"points" db:"points"`
+	Remaining     int                    `json:"remaining,omitempty" db:"remaining"`
+	OrderID       *uuid.UUID             `json:"order_id,omitempty" db:"order_id"`
+	ExpiresAt     *time.Time             `json:"expires_at,omitempty" db:"expires_at"`
+	CreatedAt     time.Time              `json:"created_at" db:"created_at"`
+}
+
+// LoyaltyAccount — баланс баллов клиента и ближайшее сгорание.
+type LoyaltyAccount struct {
+	CustomerPhone  string     `json:"customer_phone"`
+	Balance        int        `json:"balance"`
+	ExpiringPoints int        `json:"expiring_points,omitempty"` // сколько баллов сгорит ближайшим
+	NextExpiry     *time.Time `json:"next_expiry,omitempty"`
+}
@@ -3,6 +3,8 @@ package models
 import (
 	"time"
 
+	"delivery-system/internal/money"
+
 	"github.com/google/uuid"
 )
 
@@ -14,11 +16,27 @@ const (
 	OrderStatusAccepted   OrderStatus = "accepted"
 	OrderStatusPreparing  OrderStatus = "preparing"
 	OrderStatusReady      OrderStatus = "ready"
+	OrderStatusPickedUp   OrderStatus = "picked_up"
 	OrderStatusInDelivery OrderStatus = "in_delivery"
 	OrderStatusDelivered  OrderStatus = "delivered"
 	OrderStatusCancelled  OrderStatus = "cancelled"
+	// OrderStatusDeliveryFailed — курьер не смог вручить заказ; возможна повторная попытка или возврат
+	OrderStatusDeliveryFailed OrderStatus = "delivery_failed"
+	OrderStatusReturning      OrderStatus = "returning" // курьер везет заказ обратно в точку забора
+	OrderStatusReturned       OrderStatus = "returned"
 )
 
+// IsValid сообщает, известен ли статус заказа.
+func (s OrderStatus) IsValid() bool {
+	switch s {
+	case OrderStatusCreated, OrderStatusAccepted, OrderStatusPreparing, OrderStatusReady, OrderStatusPickedUp,
+		OrderStatusInDelivery, OrderStatusDelivered, OrderStatusCancelled, OrderStatusDeliveryFailed, OrderStatusReturning, OrderStatusReturned:
+		return true
+	default:
+		return false
+	}
+}
+
 // Order представляет заказ в системе
 type Order struct {
 	ID              uuid.UUID   `json:"id" db:"id"`
@@ -26,15 +44,18 @@ type Order struct {
 	CustomerPhone   string      `json:"customer_phone" db:"customer_phone"`
 	DeliveryAddress string      `json:"delivery_address" db:"delivery_address"`
 	PickupAddress   string      `json:"pickup_address" db:"pickup_address"`
+	MerchantName    *string     `json:"merchant_name,omitempty" db:"merchant_name"`
 	PickupLat       *float64    `json:"pickup_lat,omitempty" db:"pickup_lat"`
 	PickupLon       *float64    `json:"pickup_lon,omitempty" db:"pickup_lon"`
 	DeliveryLat     *float64    `json:"delivery_lat,omitempty" db:"delivery_lat"`
 	DeliveryLon     *float64    `json:"delivery_lon,omitempty" db:"delivery_lon"`
 	Items           []OrderItem `json:"items"`
-	TotalAmount     float64     `json:"total_amount" db:"total_amount"`
-	DeliveryCost    float64     `json:"delivery_cost" db:"delivery_cost"`
-	DiscountAmount  float64     `json:"discount_amount" db:"discount_amount"`
-	PromoCode       *string     `json:"promo_code,omitempty" db:"promo_code"`
+	TotalAmount     money.Money `json:"total_amount" db:"total_amount"`
+	DeliveryCost    money.Money `json:"delivery_cost" db:"delivery_cost"`
+	DiscountAmount  money.Money `json:"discount_amount" db:"discount_amount"`
+	Currency        string      `json:"currency" db:"currency"`
+	Region          string      `json:"region" db:"region_code"`
+	PromoCode       *string     `json:"promo_code,omitempty" db:"promo_code"` // первый из примененных кодов
 	Status          OrderStatus `json:"status" db:"status"`
 	CourierID       *uuid.UUID  `json:"courier_id,omitempty" db:"courier_id"`
 	Rating          *int        `json:"rating,omitempty" db:"rating"`
@@ -42,15 +63,38 @@ type Order struct {
 	CreatedAt       time.Time   `json:"created_at" db:"created_at"`
 	UpdatedAt       time.Time   `json:"updated_at" db:"updated_at"`
 	DeliveredAt     *time.Time  `json:"delivered_at,omitempty" db:"delivered_at"`
+	// DeliveryAttempts — сколько попыток вручения закончились delivery_failed
+	DeliveryAttempts int `json:"delivery_attempts" db:"delivery_attempts"`
+	// ReturnCost — стоимость обратного плеча до точки забора, считается при переводе в returning
+	ReturnCost *money.Money `json:"return_cost,omitempty" db:"return_cost"`
+	// HandoffPIN возвращается только при создании заказа, чтобы показать его клиенту
+	HandoffPIN *string `json:"handoff_pin,omitempty" db:"handoff_pin"`
+	// PromoRedemptions — скидки по каждому коду в порядке применения, возвращаются при создании заказа
+	PromoRedemptions []PromoRedemption `json:"promo_redemptions,omitempty" db:"-"`
+	// WalletCredit — списанные бонусы, входят в DiscountAmount; возвращается при создании заказа
+	WalletCredit *money.Money `json:"wallet_credit,omitempty" db:"wallet_credit"`
+	// Referral — приглашение по реферальному коду, если он был указан при создании заказа
+	Referral *Referral `json:"referral,omitempty" db:"-"`
+	DeviceID *string   `json:"device_id,omitempty" db:"device_id"`
+	// PointsRedeemed и PointsDiscount — списанные баллы и их скидка (входит в DiscountAmount), возвращаются при создании заказа
+	PointsRedeemed int          `json:"points_redeemed,omitempty" db:"points_redeemed"`
+	PointsDiscount *money.Money `json:"points_discount,omitempty" db:"points_discount"`
+	// VehicleType — транспорт, по тарифу которого посчитана доставка; WeightKg и VolumeL — габариты всего заказа
+	VehicleType VehicleType `json:"vehicle_type" db:"vehicle_type"`
+	WeightKg    float64     `json:"weight_kg" db:"weight_kg"`
+	VolumeL     float64     `json:"volume_l" db:"volume_l"`
 }
 
 // OrderItem представляет товар в заказе
 type OrderItem struct {
-	ID       uuid.UUID `json:"id" db:"id"`
-	OrderID  uuid.UUID `json:"order_id" db:"order_id"`
-	Name     string    `json:"name" db:"name"`
-	Quantity int       `json:"quantity" db:"quantity"`
-	Price    float64   `json:"price" db:"price"`
+	ID          uuid.UUID   `json:"id" db:"id"`
+	OrderID     uuid.UUID   `json:"order_id" db:"order_id"`
+	Name        string      `json:"name" db:"name"`
+	Quantity    int         `json:"quantity" db:"quantity"`
+	Price       money.Money `json:"price" db:"price"`
+	TaxCategory TaxCategory `json:"tax_category" db:"tax_category"`
+	WeightKg    float64     `json:"weight_kg" db:"weight_kg"` // вес одной единицы
+	VolumeL     float64     `json:"volume_l" db:"volume_l"`   // объем одной единицы
 }
 
 // CreateOrderRequest представляет запрос на создание заказа
@@ -59,6 +103,7 @@ type CreateOrderRequest struct {
 	CustomerPhone   string                   `json:"customer_phone"`
 	DeliveryAddress string                   `json:"delivery_address"`
 	PickupAddress   string                   `json:"pickup_address"`
+	MerchantName    string                   `json:"merchant_name,omitempty"` // продавец, у которого забирают заказ
 	Items           []CreateOrderItemRequest `json:"items"`
 	AutoAssign      bool                     `json:"auto_assign,omitempty"`
 	PickupLat       *float64                 `json:"pickup_lat,omitempty"`
@@ -66,33 +111,88 @@ type CreateOrderRequest struct {
 	DeliveryLat     *float64                 `json:"delivery_lat,omitempty"`
 	DeliveryLon     *float64                 `json:"delivery_lon,omitempty"`
 	PromoCode       *string                  `json:"promo_code,omitempty"`
+	PromoCodes      []string                 `json:"promo_codes,omitempty"` // несколько кодов, если их можно сочетать
+	ReferralCode    *string                  `json:"referral_code,omitempty"`
+	DeviceID        string                   `json:"device_id,omitempty"`         // идентификатор устройства клиента, по умолчанию из X-Device-ID
+	UseWalletCredit bool                     `json:"use_wallet_credit,omitempty"` // оплатить часть заказа бонусами
+	RedeemPoints    int                      `json:"redeem_points,omitempty"`     // сколько баллов лояльности списать
+}
+
+// AllPromoCodes возвращает коды заказа: promo_code, затем promo_codes.
+func (r *CreateOrderRequest) AllPromoCodes() []string {
+	var codes []string
+	if r.PromoCode != nil && *r.PromoCode != "" {
+		codes = append(codes, *r.PromoCode)
+	}
+	for _, code := range r.PromoCodes {
+		if code != "" {
+			codes = append(codes, code)
+		}
+	}
+	return codes
+}
+
+// UpdateOrderRequest — изменение заказа до начала сборки. Пустые поля не меняются;
+// items заменяет состав заказа целиком.
+type UpdateOrderRequest struct {
+	CustomerName    *string                  `json:"customer_name,omitempty"`
+	DeliveryAddress *string                  `json:"delivery_address,omitempty"`
+	DeliveryLat     *float64                 `json:"delivery_lat,omitempty"`
+	DeliveryLon     *float64                 `json:"delivery_lon,omitempty"`
+	Items           []CreateOrderItemRequest `json:"items,omitempty"`
+}
+
+// FieldChange — изменение одного поля заказа: старое и новое значения.
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// OrderUpdate — заказ после изменения и список измененных полей.
+type OrderUpdate struct {
+	Order   *Order        `json:"order"`
+	Changes []FieldChange `json:"changes"`
 }
 
 // CreateOrderItemRequest представляет запрос на создание товара в заказе
 type CreateOrderItemRequest struct {
-	Name     string  `json:"name"`
-	Quantity int     `json:"quantity"`
-	Price    float64 `json:"price"`
+	Name        string      `json:"name"`
+	Quantity    int         `json:"quantity"`
+	Price       money.Money `json:"price"`
+	TaxCategory TaxCategory `json:"tax_category,omitempty"` // пустая — standard
+	WeightKg    float64     `json:"weight_kg,omitempty"`    // вес одной единицы, кг
+	VolumeL     float64     `json:"volume_l,omitempty"`     // объем одной единицы, л
 }
 
 // UpdateOrderStatusRequest представляет запрос на обновление статуса заказа
 type UpdateOrderStatusRequest struct {
-	Status    OrderStatus `json:"status"`
-	CourierID *uuid.UUID  `json:"courier_id,omitempty"`
+	Status     OrderStatus `json:"status"`
+	CourierID  *uuid.UUID  `json:"courier_id,omitempty"`
+	HandoffPIN *string     `json:"handoff_pin,omitempty"` // PIN клиента для перехода in_delivery -> delivered
 }
 
-// Review представляет отзыв о заказе/курьере
-type Review struct {
-	ID        uuid.UUID `json:"id" db:"id"`
-	OrderID   uuid.UUID `json:"order_id" db:"order_id"`
-	CourierID uuid.UUID `json:"courier_id" db:"courier_id"`
-	Rating    int       `json:"rating" db:"rating"`
-	Comment   *string   `json:"comment,omitempty" db:"comment"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
+// OrderStatusUpdate — результат смены статуса заказа
+type OrderStatusUpdate struct {
+	OrderID   uuid.UUID   `json:"order_id"`
+	OldStatus OrderStatus `json:"old_status"`
+	Status    OrderStatus `json:"status"`
+	CourierID *uuid.UUID  `json:"courier_id,omitempty"`
+	// ReleasedCourierID — курьер, который вернулся в available в той же транзакции
+	ReleasedCourierID *uuid.UUID `json:"released_courier_id,omitempty"`
 }
 
-// CreateReviewRequest представляет запрос на создание отзыва по заказу
-type CreateReviewRequest struct {
-	Rating  int     `json:"rating"`
-	Comment *string `json:"comment,omitempty"`
+// ApplyCurrency проставляет валюту заказа во все денежные поля заказа и его товаров.
+func (o *Order) ApplyCurrency(currency string) {
+	o.Currency = currency
+	o.TotalAmount = o.TotalAmount.In(currency)
+	o.DeliveryCost = o.DeliveryCost.In(currency)
+	o.DiscountAmount = o.DiscountAmount.In(currency)
+	if o.ReturnCost != nil {
+		returnCost := o.ReturnCost.In(currency)
+		o.ReturnCost = &returnCost
+	}
+	for i := range o.Items {
+		o.Items[i].Price = o.Items[i].Price.In(currency)
+	}
 }
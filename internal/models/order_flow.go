@@ -0,0 +1,46 @@
+package models
+
+// TransitionHook — побочное действие, которое выполняется в транзакции смены статуса заказа.
+type TransitionHook string
+
+const (
+	HookVerifyProof      TransitionHook = "verify_proof"      // проверка PIN или фото/подписи при вручении
+	HookCapturePayment   TransitionHook = "capture_payment"   // списание авторизованного платежа
+	HookReleasePayment   TransitionHook = "release_payment"   // отмена авторизации или возврат списания
+	HookReverseDiscounts TransitionHook = "reverse_discounts" // возврат промокодов, бонусов и баллов, отклонение приглашения
+	HookCompleteDelivery TransitionHook = "complete_delivery" // начисления курьеру, баллы, реферальные бонусы и чек
+	HookReleaseCourier   TransitionHook = "release_courier"   // курьер снова available, если у него нет других активных заказов
+	HookFailDelivery     TransitionHook = "fail_delivery"     // учет неудачной попытки; требует записи о связи с клиентом
+	HookRetryDelivery    TransitionHook = "retry_delivery"    // повторная попытка в пределах лимита попыток
+	HookPriceReturn      TransitionHook = "price_return"      // расчет стоимости обратного плеча
+)
+
+// IsValid сообщает, известно ли действие.
+func (h TransitionHook) IsValid() bool {
+	switch h {
+	case HookVerifyProof, HookCapturePayment, HookReleasePayment, HookReverseDiscounts, HookCompleteDelivery, HookReleaseCourier,
+		HookFailDelivery, HookRetryDelivery, HookPriceReturn:
+		return true
+	default:
+		return false
+	}
+}
+
+// OrderTransition — разрешенный переход между статусами заказа.
+type OrderTransition struct {
+	From OrderStatus `json:"from"`
+	To   OrderStatus `json:"to"`
+	// Roles — типы исполнителей (user, courier, system), которым разрешен переход; пусто — всем
+	Roles []string         `json:"roles,omitempty"`
+	Hooks []TransitionHook `json:"hooks,omitempty"`
+}
+
+// Has сообщает, выполняется ли при переходе действие hook.
+func (t OrderTransition) Has(hook TransitionHook) bool {
+	for _, h := range t.Hooks {
+		if h == hook {
+			return true
+		}
+	}
+	return false
+}
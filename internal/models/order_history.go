@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// OrderStatusChange — запись журнала статусов заказа.
+type OrderStatusChange struct {
+	ID        uuid.UUID    `json:"id" db:"id"`
+	OldStatus *OrderStatus `json:"old_status,omitempty" db:"old_status"` // пусто для создания заказа
+	NewStatus OrderStatus  `json:"new_status" db:"new_status"`
+	CourierID *uuid.UUID   `json:"courier_id,omitempty" db:"courier_id"`
+	ChangedBy string       `json:"changed_by" db:"changed_by"` // исполнитель: user:<id>, courier:<id> или system:<компонент>
+	ChangedAt time.Time    `json:"changed_at" db:"changed_at"`
+	Reason    *string      `json:"reason,omitempty" db:"reason"` // причина, например при переназначении курьера
+	// DurationSeconds — сколько заказ пробыл в NewStatus до следующего изменения; пусто для текущего статуса
+	DurationSeconds *float64 `json:"duration_seconds,omitempty" db:"-"`
+}
+
+// OrderHistory — хронология статусов заказа.
+type OrderHistory struct {
+	OrderID  uuid.UUID           `json:"order_id"`
+	Status   OrderStatus         `json:"status"`
+	Timeline []OrderStatusChange `json:"timeline"`
+	// TotalSeconds — время от первой до последней записи хронологии
+	TotalSeconds float64 `json:"total_seconds"`
+}
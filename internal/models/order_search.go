@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"delivery-system/internal/money"
+	"delivery-system/internal/pagination"
+
+	"github.com/google/uuid"
+)
+
+// OrderSearchSort — поле сортировки результатов поиска заказов.
+type OrderSearchSort string
+
+const (
+	OrderSearchSortCreatedAt   OrderSearchSort = "created_at"
+	OrderSearchSortTotalAmount OrderSearchSort = "total_amount"
+	OrderSearchSortRelevance   OrderSearchSort = "relevance" // только вместе со свободным текстом
+)
+
+// OrderSearchQuery — разобранный поисковый запрос по заказам. Пустые поля не ограничивают выборку.
+type OrderSearchQuery struct {
+	Text        string // свободный текст: имя, адреса, продавец, промокод
+	Phone       string // фрагмент номера телефона, только цифры
+	Name        string
+	Address     string
+	PromoCode   string
+	Merchant    string
+	Statuses    []OrderStatus
+	CourierID   *uuid.UUID
+	Currency    string
+	CreatedFrom *time.Time // включительно
+	CreatedTo   *time.Time // не включительно
+	AmountMin   *money.Money
+	AmountMax   *money.Money
+	SortBy      OrderSearchSort
+	SortDesc    bool
+	Page        pagination.Request
+}
+
+// OrderSearchResult — страница найденных заказов ({items, next_cursor}), их общее количество
+// и разбивка по статусам.
+type OrderSearchResult struct {
+	pagination.Page[*Order]
+	Total int `json:"total"`
+	// Facets считаются по всем условиям, кроме статуса, чтобы было видно, сколько заказов в соседних статусах
+	Facets map[OrderStatus]int `json:"facets"`
+}
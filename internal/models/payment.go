@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"delivery-system/internal/money"
+
+	"github.com/google/uuid"
+)
+
+// PaymentStatus представляет состояние платежа по заказу
+type PaymentStatus string
+
+const (
+	PaymentStatusPending           PaymentStatus = "pending" // авторизация еще не выполнена у провайдера
+	PaymentStatusAuthorized        PaymentStatus = "authorized"
+	PaymentStatusCaptured          PaymentStatus = "captured"
+	PaymentStatusPartiallyRefunded PaymentStatus = "partially_refunded"
+	PaymentStatusRefunded          PaymentStatus = "refunded"
+	PaymentStatusVoided            PaymentStatus = "voided"
+	PaymentStatusFailed            PaymentStatus = "failed"
+)
+
+// PaymentOperation — действие у провайдера, отложенное до фиксации транзакции заказа
+type PaymentOperation string
+
+const (
+	PaymentOperationAuthorize PaymentOperation = "authorize"
+	PaymentOperationCapture   PaymentOperation = "capture"
+	PaymentOperationRelease   PaymentOperation = "release" // отмена авторизации или возврат списанного
+	PaymentOperationRefund    PaymentOperation = "refund"  // возврат по запросу на сумму PendingAmount
+)
+
+// Payment представляет платеж по заказу
+type Payment struct {
+	ID               uuid.UUID         `json:"id" db:"id"`
+	OrderID          uuid.UUID         `json:"order_id" db:"order_id"`
+	Provider         string            `json:"provider" db:"provider"`
+	ProviderRef      *string           `json:"provider_ref,omitempty" db:"provider_ref"`
+	Status           PaymentStatus     `json:"status" db:"status"`
+	Amount           money.Money       `json:"amount" db:"amount"`
+	CapturedAmount   money.Money       `json:"captured_amount" db:"captured_amount"`
+	RefundedAmount   money.Money       `json:"refunded_amount" db:"refunded_amount"`
+	Currency         string            `json:"currency" db:"currency"`
+	FailureReason    *string           `json:"failure_reason,omitempty" db:"failure_reason"`
+	PendingOperation *PaymentOperation `json:"pending_operation,omitempty" db:"pending_operation"`
+	PendingAmount    *money.Money      `json:"pending_amount,omitempty" db:"pending_amount"` // сумма назначенного возврата
+	CreatedAt        time.Time         `json:"created_at" db:"created_at"`
+	UpdatedAt        time.Time         `json:"updated_at" db:"updated_at"`
+}
+
+// RefundPaymentRequest представляет запрос на (частичный) возврат
+type RefundPaymentRequest struct {
+	Amount money.Money `json:"amount"`
+	Reason *string     `json:"reason,omitempty"`
+}
+
+// PaymentWebhookEvent представляет уведомление платежного провайдера
+type PaymentWebhookEvent struct {
+	ID          string      `json:"id"`
+	Type        string      `json:"type"` // payment.authorized | payment.captured | payment.voided | payment.refunded | payment.failed
+	ProviderRef string      `json:"provider_ref"`
+	Amount      money.Money `json:"amount"`
+	Reason      *string     `json:"reason,omitempty"`
+	CreatedAt   time.Time   `json:"created_at"`
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"math"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Априорные значения сглаживания: новый курьер без истории получает их, а по мере накопления
+// заказов и отзывов его показатели сходятся к фактическим. Вес — сколько «виртуальных» наблюдений
+// стоит за априорным значением.
+const (
+	PriorRating         = 4.5  // средняя оценка, к которой тянется рейтинг с малым числом отзывов
+	PriorRatingWeight   = 10.0 // столько отзывов нужно, чтобы фактический рейтинг перевесил априорный
+	PriorAcceptanceRate = 0.9
+	PriorCancelRate     = 0.05
+	PriorOnTimeRate     = 0.85
+	PriorEtaRatio       = 1.0 // фактическое время доставки к расчетному
+	PriorRateWeight     = 5.0
+	// priorEtaMinutes — расчетное время одной «виртуальной» доставки при сглаживании EtaRatio
+	priorEtaMinutes = 30.0
+)
+
+// Веса составляющих итоговой оценки; в сумме дают 1.
+const (
+	performanceWeightAcceptance = 0.25
+	performanceWeightCancel     = 0.15
+	performanceWeightOnTime     = 0.25
+	performanceWeightEta        = 0.15
+	performanceWeightRating     = 0.20
+)
+
+// CourierPerformance — накопленные показатели курьера и рассчитанная по ним оценка.
+// Счетчики обновляются инкрементально по событиям заказов.
+type CourierPerformance struct {
+	CourierID          uuid.UUID `json:"courier_id" db:"courier_id"`
+	AssignmentsOffered int       `json:"assignments_offered" db:"assignments_offered"`
+	AssignmentsDropped int       `json:"assignments_dropped" db:"assignments_dropped"` // курьера сняли с заказа
+	OrdersDelivered    int       `json:"orders_delivered" db:"orders_delivered"`
+	OrdersCancelled    int       `json:"orders_cancelled" db:"orders_cancelled"`
+	TimedDeliveries    int       `json:"timed_deliveries" db:"timed_deliveries"` // доставки с известным расчетным временем
+	OnTimeDeliveries   int       `json:"on_time_deliveries" db:"on_time_deliveries"`
+	DeliveryMinutes    float64   `json:"delivery_minutes" db:"delivery_minutes"` // сумма фактического времени по TimedDeliveries
+	EtaMinutes         float64   `json:"eta_minutes" db:"eta_minutes"`           // сумма расчетного времени по TimedDeliveries
+	UpdatedAt          time.Time `json:"updated_at" db:"updated_at"`
+
+	PerformanceScore
+}
+
+// PerformanceScore — сглаженные показатели и итоговая оценка от 0 до 1.
+type PerformanceScore struct {
+	AcceptanceRate float64 `json:"acceptance_rate"`
+	CancelRate     float64 `json:"cancellation_rate"`
+	OnTimeRate     float64 `json:"on_time_rate"`
+	EtaRatio       float64 `json:"eta_ratio"` // среднее фактическое время доставки к расчетному; < 1 — быстрее
+	BayesRating    float64 `json:"bayes_rating"`
+	Score          float64 `json:"score"`
+}
+
+// BayesRating сглаживает средний рейтинг к PriorRating: у курьера с парой отзывов
+// одна плохая оценка не обрушивает рейтинг.
+func BayesRating(rating float64, reviews int) float64 {
+	n := float64(reviews)
+	return (PriorRating*PriorRatingWeight + rating*n) / (PriorRatingWeight + n)
+}
+
+// smoothRate сглаживает долю hits/total к априорному значению.
+func smoothRate(hits, total int, prior float64) float64 {
+	return (prior*PriorRateWeight + float64(hits)) / (PriorRateWeight + float64(total))
+}
+
+// ComputeScore рассчитывает сглаженные показатели и итоговую оценку по счетчикам и рейтингу курьера.
+func (p *CourierPerformance) ComputeScore(rating float64, reviews int) PerformanceScore {
+	offered := p.AssignmentsOffered
+	if offered < p.AssignmentsDropped {
+		offered = p.AssignmentsDropped
+	}
+	finished := p.OrdersDelivered + p.OrdersCancelled
+
+	s := PerformanceScore{
+		AcceptanceRate: smoothRate(offered-p.AssignmentsDropped, offered, PriorAcceptanceRate),
+		CancelRate:     smoothRate(p.OrdersCancelled, finished, PriorCancelRate),
+		OnTimeRate:     smoothRate(p.OnTimeDeliveries, p.TimedDeliveries, PriorOnTimeRate),
+		EtaRatio:       (PriorEtaRatio*priorEtaMinutes*PriorRateWeight + p.DeliveryMinutes) / (priorEtaMinutes*PriorRateWeight + p.EtaMinutes),
+		BayesRating:    BayesRating(rating, reviews),
+	}
+
+	// Доставка вдвое дольше расчетной дает 0, вовремя и быстрее — 1
+	etaScore := math.Max(0, math.Min(1, 2-s.EtaRatio))
+
+	s.Score = performanceWeightAcceptance*s.AcceptanceRate +
+		performanceWeightCancel*(1-s.CancelRate) +
+		performanceWeightOnTime*s.OnTimeRate +
+		performanceWeightEta*etaScore +
+		performanceWeightRating*(s.BayesRating/5)
+	s.Score = math.Round(s.Score*10000) / 10000
+	return s
+}
+
+// PerformanceSnapshot — запись истории оценки после обработки события.
+type PerformanceSnapshot struct {
+	EventID    uuid.UUID `json:"event_id"`
+	EventType  EventType `json:"event_type"`
+	OrderID    uuid.UUID `json:"order_id"`
+	RecordedAt time.Time `json:"recorded_at"`
+	PerformanceScore
+}
+
+// CourierPerformanceReport — текущие показатели курьера и последние записи истории.
+type CourierPerformanceReport struct {
+	CourierPerformance
+	History []*PerformanceSnapshot `json:"history"`
+}
+
+// EstimateDeliveryMinutes — расчетное время от забора заказа до вручения: поездка от точки забора
+// до адреса доставки со средней скоростью транспорта плюс время на передачу заказа.
+func EstimateDeliveryMinutes(vehicle VehicleType, routeKm float64) float64 {
+	const handlingMinutes = 5
+	return routeKm/vehicle.AverageSpeedKmh()*60 + handlingMinutes
+}
@@ -1,6 +1,12 @@
 package models
 
-import "time"
+import (
+	"time"
+
+	"delivery-system/internal/money"
+
+	"github.com/google/uuid"
+)
 
 // DiscountType описывает тип промокода.
 type DiscountType string
@@ -11,34 +17,133 @@ const (
 	DiscountTypeFreeDelivery DiscountType = "free_delivery"
 )
 
+// PromoStacking определяет, можно ли сочетать промокод с другими в одном заказе.
+type PromoStacking string
+
+const (
+	PromoStackingExclusive  PromoStacking = "exclusive"  // применяется только один в заказе
+	PromoStackingCombinable PromoStacking = "combinable" // сочетается с другими combinable-кодами
+)
+
 // PromoCode представляет промокод в системе.
 type PromoCode struct {
-	Code         string       `json:"code" db:"code"`
-	DiscountType DiscountType `json:"discount_type" db:"discount_type"`
-	Amount       float64      `json:"amount" db:"amount"`
-	MaxUses      int          `json:"max_uses" db:"max_uses"`
-	UsedCount    int          `json:"used_count" db:"used_count"`
-	ExpiresAt    *time.Time   `json:"expires_at,omitempty" db:"expires_at"`
-	Active       bool         `json:"active" db:"active"`
-	CreatedAt    time.Time    `json:"created_at" db:"created_at"`
-	UpdatedAt    time.Time    `json:"updated_at" db:"updated_at"`
+	Code         string        `json:"code" db:"code"`
+	DiscountType DiscountType  `json:"discount_type" db:"discount_type"`
+	Amount       money.Money   `json:"amount" db:"amount"` // для percent — размер скидки в процентах
+	Currency     string        `json:"currency" db:"currency"`
+	MaxUses      int           `json:"max_uses" db:"max_uses"`
+	UsedCount    int           `json:"used_count" db:"used_count"`
+	ExpiresAt    *time.Time    `json:"expires_at,omitempty" db:"expires_at"`
+	Active       bool          `json:"active" db:"active"`
+	Rules        PromoRules    `json:"rules" db:"rules"`
+	Stacking     PromoStacking `json:"stacking" db:"stacking"`
+	CampaignID   *uuid.UUID    `json:"campaign_id,omitempty" db:"campaign_id"`
+	CreatedAt    time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at" db:"updated_at"`
 }
 
 // CreatePromoCodeRequest описывает запрос на создание промокода.
 type CreatePromoCodeRequest struct {
-	Code         string       `json:"code"`
-	DiscountType DiscountType `json:"discount_type"`
-	Amount       float64      `json:"amount"`
-	MaxUses      int          `json:"max_uses,omitempty"` // 0 = безлимит
-	ExpiresAt    *time.Time   `json:"expires_at,omitempty"`
-	Active       bool         `json:"active"`
+	Code         string        `json:"code"`
+	DiscountType DiscountType  `json:"discount_type"`
+	Amount       money.Money   `json:"amount"`
+	Currency     string        `json:"currency,omitempty"` // по умолчанию — валюта сервиса
+	MaxUses      int           `json:"max_uses,omitempty"` // 0 = безлимит
+	ExpiresAt    *time.Time    `json:"expires_at,omitempty"`
+	Active       bool          `json:"active"`
+	Rules        PromoRules    `json:"rules"`
+	Stacking     PromoStacking `json:"stacking,omitempty"` // по умолчанию exclusive
 }
 
 // UpdatePromoCodeRequest описывает запрос на обновление промокода.
 type UpdatePromoCodeRequest struct {
-	DiscountType DiscountType `json:"discount_type"`
-	Amount       float64      `json:"amount"`
-	MaxUses      int          `json:"max_uses,omitempty"`
-	ExpiresAt    *time.Time   `json:"expires_at,omitempty"`
-	Active       bool         `json:"active"`
+	DiscountType DiscountType  `json:"discount_type"`
+	Amount       money.Money   `json:"amount"`
+	MaxUses      int           `json:"max_uses,omitempty"`
+	ExpiresAt    *time.Time    `json:"expires_at,omitempty"`
+	Active       bool          `json:"active"`
+	Rules        PromoRules    `json:"rules"`
+	Stacking     PromoStacking `json:"stacking,omitempty"` // по умолчанию exclusive
+}
+
+// PromoRules описывает условия применения промокода. Незаполненные поля ничего не ограничивают.
+// Суммы задаются в валюте промокода, дни недели и часы — по времени региона заказа.
+type PromoRules struct {
+	MinItemsTotal      *money.Money `json:"min_items_total,omitempty"`       // минимальная сумма товаров без доставки
+	FirstOrderOnly     bool         `json:"first_order_only,omitempty"`      // только первый заказ клиента
+	MaxUsesPerCustomer int          `json:"max_uses_per_customer,omitempty"` // лимит по номеру телефона клиента
+	Regions            []string     `json:"regions,omitempty"`               // коды регионов, где действует промокод
+	Weekdays           []string     `json:"weekdays,omitempty"`              // mon, tue, wed, thu, fri, sat, sun
+	HoursFrom          string       `json:"hours_from,omitempty"`            // HH:MM, начало окна действия
+	HoursTo            string       `json:"hours_to,omitempty"`              // HH:MM, конец окна; окно может переходить через полночь
+	MaxDiscount        *money.Money `json:"max_discount,omitempty"`          // потолок скидки для percent
+}
+
+// ApplyCurrency проставляет валюту промокода суммам правил после чтения из JSON.
+func (r *PromoRules) ApplyCurrency(currency string) {
+	if r.MinItemsTotal != nil {
+		v := r.MinItemsTotal.In(currency)
+		r.MinItemsTotal = &v
+	}
+	if r.MaxDiscount != nil {
+		v := r.MaxDiscount.In(currency)
+		r.MaxDiscount = &v
+	}
+}
+
+// PromoRedemption — применение промокода к заказу.
+type PromoRedemption struct {
+	ID            uuid.UUID    `json:"id" db:"id"`
+	Code          string       `json:"code" db:"code"`
+	OrderID       uuid.UUID    `json:"order_id" db:"order_id"`
+	CustomerPhone string       `json:"customer_phone" db:"customer_phone"` // только цифры номера
+	DiscountType  DiscountType `json:"discount_type" db:"discount_type"`
+	Discount      money.Money  `json:"discount_amount" db:"discount_amount"`
+	Currency      string       `json:"currency" db:"currency"`
+	Position      int          `json:"position" db:"position"` // порядок применения в заказе, с 0
+	RedeemedAt    time.Time    `json:"redeemed_at" db:"redeemed_at"`
+	ReversedAt    *time.Time   `json:"reversed_at,omitempty" db:"reversed_at"` // заполняется при отмене заказа
+}
+
+// PromoRejectReason — машиночитаемая причина, по которой промокод не применяется к заказу.
+type PromoRejectReason string
+
+const (
+	PromoReasonNotFound         PromoRejectReason = "not_found"
+	PromoReasonInactive         PromoRejectReason = "inactive"
+	PromoReasonExpired          PromoRejectReason = "expired"
+	PromoReasonUsageLimit       PromoRejectReason = "usage_limit_reached"
+	PromoReasonCustomerLimit    PromoRejectReason = "customer_limit_reached"
+	PromoReasonFirstOrderOnly   PromoRejectReason = "first_order_only"
+	PromoReasonPhoneRequired    PromoRejectReason = "customer_phone_required"
+	PromoReasonRegion           PromoRejectReason = "region_not_allowed"
+	PromoReasonWeekday          PromoRejectReason = "weekday_not_allowed"
+	PromoReasonHours            PromoRejectReason = "outside_hours"
+	PromoReasonMinItemsTotal    PromoRejectReason = "min_items_total_not_met"
+	PromoReasonCurrencyMismatch PromoRejectReason = "currency_mismatch"
+	PromoReasonNotCombinable    PromoRejectReason = "not_combinable"
+)
+
+// ValidatePromoRequest описывает корзину для предварительной проверки промокода.
+type ValidatePromoRequest struct {
+	CustomerPhone string                   `json:"customer_phone,omitempty"` // нужен для правил по истории клиента
+	Items         []CreateOrderItemRequest `json:"items"`
+	PickupLat     *float64                 `json:"pickup_lat"`
+	PickupLon     *float64                 `json:"pickup_lon"`
+	DeliveryLat   *float64                 `json:"delivery_lat"`
+	DeliveryLon   *float64                 `json:"delivery_lon"`
+}
+
+// PromoValidation — результат предварительной проверки промокода для корзины.
+type PromoValidation struct {
+	Code         string            `json:"code"`
+	Valid        bool              `json:"valid"`
+	Reason       PromoRejectReason `json:"reason,omitempty"`
+	Message      string            `json:"message,omitempty"`
+	Discount     money.Money       `json:"discount_amount"`
+	ItemsTotal   money.Money       `json:"items_total"`
+	DeliveryCost money.Money       `json:"delivery_cost"`
+	Total        money.Money       `json:"total_amount"`
+	Currency     string            `json:"currency"`
+	Region       string            `json:"region"`
 }
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ProofType описывает вид подтверждения доставки.
+type ProofType string
+
+const (
+	ProofTypePIN       ProofType = "pin"
+	ProofTypePhoto     ProofType = "photo"
+	ProofTypeSignature ProofType = "signature"
+)
+
+// DeliveryProof представляет подтверждение передачи заказа клиенту.
+// Для фото и подписи содержимое хранится в BlobStorage под ключом StorageKey.
+type DeliveryProof struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	OrderID     uuid.UUID `json:"order_id" db:"order_id"`
+	Type        ProofType `json:"type" db:"proof_type"`
+	StorageKey  *string   `json:"-" db:"storage_key"`
+	ContentType *string   `json:"content_type,omitempty" db:"content_type"`
+	SizeBytes   int64     `json:"size_bytes" db:"size_bytes"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
@@ -0,0 +1,29 @@
+package models
+
+import "github.com/google/uuid"
+
+// MaxReassignReasonLength ограничивает причину снятия или переназначения курьера.
+const MaxReassignReasonLength = 500
+
+// UnassignCourierRequest — запрос диспетчера снять курьера с заказа.
+type UnassignCourierRequest struct {
+	Reason string `json:"reason"`
+}
+
+// ReassignCourierRequest — запрос диспетчера передать заказ другому курьеру.
+type ReassignCourierRequest struct {
+	CourierID uuid.UUID `json:"courier_id"`
+	Reason    string    `json:"reason"`
+}
+
+// CourierReassignment — результат снятия или переназначения курьера.
+type CourierReassignment struct {
+	OrderID           uuid.UUID   `json:"order_id"`
+	OldStatus         OrderStatus `json:"old_status"`
+	Status            OrderStatus `json:"status"`
+	PreviousCourierID uuid.UUID   `json:"previous_courier_id"`
+	CourierID         *uuid.UUID  `json:"courier_id,omitempty"` // пусто, если заказ вернулся в created
+	Reason            string      `json:"reason"`
+	// PreviousCourierReleased — прежний курьер вернулся в available, других активных заказов у него нет
+	PreviousCourierReleased bool `json:"previous_courier_released"`
+}
@@ -0,0 +1,108 @@
+package models
+
+import (
+	"time"
+
+	"delivery-system/internal/money"
+
+	"github.com/google/uuid"
+)
+
+// TaxCategory — налоговая категория позиции чека.
+type TaxCategory string
+
+const (
+	TaxCategoryStandard TaxCategory = "standard" // основная ставка региона
+	TaxCategoryReduced  TaxCategory = "reduced"  // пониженная ставка (например, продукты)
+	TaxCategoryZero     TaxCategory = "zero"     // ставка 0%
+	TaxCategoryExempt   TaxCategory = "exempt"   // без НДС
+)
+
+// IsValid сообщает, известна ли категория.
+func (c TaxCategory) IsValid() bool {
+	switch c {
+	case TaxCategoryStandard, TaxCategoryReduced, TaxCategoryZero, TaxCategoryExempt:
+		return true
+	default:
+		return false
+	}
+}
+
+// ReceiptFormat — формат документа чека.
+type ReceiptFormat string
+
+const (
+	ReceiptFormatJSON ReceiptFormat = "json"
+	ReceiptFormatHTML ReceiptFormat = "html"
+	ReceiptFormatPDF  ReceiptFormat = "pdf"
+)
+
+// ReceiptLineKind — тип строки чека.
+type ReceiptLineKind string
+
+const (
+	ReceiptLineItem     ReceiptLineKind = "item"
+	ReceiptLineDelivery ReceiptLineKind = "delivery"
+)
+
+// Receipt — чек по доставленному заказу. Цены включают налог.
+type Receipt struct {
+	ID             uuid.UUID     `json:"id"`
+	Number         string        `json:"number"`
+	OrderID        uuid.UUID     `json:"order_id"`
+	Region         string        `json:"region"`
+	Currency       string        `json:"currency"`
+	Lines          []ReceiptLine `json:"lines"`
+	ItemsTotal     money.Money   `json:"items_total"`
+	DeliveryFee    money.Money   `json:"delivery_fee"`
+	DiscountAmount money.Money   `json:"discount_amount"`
+	PromoCode      *string       `json:"promo_code,omitempty"`
+	Taxes          []ReceiptTax  `json:"taxes"`
+	TaxTotal       money.Money   `json:"tax_total"`
+	Total          money.Money   `json:"total"`
+	IssuedAt       time.Time     `json:"issued_at"`
+}
+
+// ReceiptLine — позиция чека с долей скидки и налогом в составе цены.
+type ReceiptLine struct {
+	Kind        ReceiptLineKind `json:"kind"`
+	Name        string          `json:"name"`
+	Quantity    int             `json:"quantity"`
+	UnitPrice   money.Money     `json:"unit_price"`
+	Amount      money.Money     `json:"amount"`   // цена * количество
+	Discount    money.Money     `json:"discount"` // доля скидки промокода
+	Total       money.Money     `json:"total"`    // к оплате по строке
+	TaxCategory TaxCategory     `json:"tax_category"`
+	TaxRate     float64         `json:"tax_rate"`
+	TaxAmount   money.Money     `json:"tax_amount"`
+}
+
+// ReceiptTax — итог налога по ставке.
+type ReceiptTax struct {
+	Category TaxCategory `json:"category"`
+	Rate     float64     `json:"rate"`
+	Base     money.Money `json:"base"` // сумма строк с налогом
+	Amount   money.Money `json:"amount"`
+}
+
+// ApplyCurrency проставляет валюту чека во все денежные поля (после чтения из JSON).
+func (r *Receipt) ApplyCurrency(currency string) {
+	r.Currency = currency
+	r.ItemsTotal = r.ItemsTotal.In(currency)
+	r.DeliveryFee = r.DeliveryFee.In(currency)
+	r.DiscountAmount = r.DiscountAmount.In(currency)
+	r.TaxTotal = r.TaxTotal.In(currency)
+	r.Total = r.Total.In(currency)
+	for i := range r.Lines {
+		line := &r.Lines[i]
+		line.UnitPrice = line.UnitPrice.In(currency)
+		line.Amount = line.Amount.In(currency)
+		line.Discount = line.Discount.In(currency)
+		line.Total = line.Total.In(currency)
+		line.TaxAmount = line.TaxAmount.In(currency)
+	}
+	for i := range r.Taxes {
+		r.Taxes[i].Base = r.Taxes[i].Base.In(currency)
+		r.Taxes[i].Amount = r.Taxes[i].Amount.In(currency)
+	}
+}
@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"delivery-system/internal/money"
+
+	"github.com/google/uuid"
+)
+
+// ReferralStatus описывает состояние приглашения.
+type ReferralStatus string
+
+const (
+	ReferralStatusPending  ReferralStatus = "pending"  // ждет доставки первого заказа
+	ReferralStatusRewarded ReferralStatus = "rewarded" // бонусы начислены обоим клиентам
+	ReferralStatusRejected ReferralStatus = "rejected" // отклонено проверками или отменой заказа
+)
+
+// ReferralRejectReason — машинно-читаемая причина отклонения приглашения.
+type ReferralRejectReason string
+
+const (
+	ReferralReasonSelfReferral    ReferralRejectReason = "self_referral"
+	ReferralReasonNotFirstOrder   ReferralRejectReason = "not_first_order"
+	ReferralReasonAlreadyReferred ReferralRejectReason = "already_referred"
+	ReferralReasonSameDevice      ReferralRejectReason = "same_device"
+	ReferralReasonSameAddress     ReferralRejectReason = "same_address"
+	ReferralReasonOrderCancelled  ReferralRejectReason = "order_cancelled"
+)
+
+// ReferralCode — персональный реферальный код клиента.
+type ReferralCode struct {
+	Code          string    `json:"code" db:"code"`
+	CustomerPhone string    `json:"customer_phone" db:"customer_phone"` // только цифры
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	Pending       int       `json:"pending"`
+	Rewarded      int       `json:"rewarded"`
+	Rejected      int       `json:"rejected"`
+}
+
+// CreateReferralCodeRequest — запрос персонального кода; для клиента с кодом возвращается существующий.
+type CreateReferralCodeRequest struct {
+	CustomerPhone string `json:"customer_phone"`
+}
+
+// Referral — приглашение нового клиента по реферальному коду, привязанное к его первому заказу.
+type Referral struct {
+	ID             uuid.UUID             `json:"id" db:"id"`
+	Code           string                `json:"code" db:"code"`
+	ReferrerPhone  string                `json:"referrer_phone" db:"referrer_phone"`
+	RefereePhone   string                `json:"referee_phone" db:"referee_phone"`
+	OrderID        uuid.UUID             `json:"order_id" db:"order_id"`
+	Status         ReferralStatus        `json:"status" db:"status"`
+	RejectReason   *ReferralRejectReason `json:"reject_reason,omitempty" db:"reject_reason"`
+	ReferrerReward money.Money           `json:"referrer_reward" db:"referrer_reward"`
+	RefereeReward  money.Money           `json:"referee_reward" db:"referee_reward"`
+	Currency       string                `json:"currency" db:"currency"`
+	CreatedAt      time.Time             `json:"created_at" db:"created_at"`
+	RewardedAt     *time.Time            `json:"rewarded_at,omitempty" db:"rewarded_at"`
+}
@@ -0,0 +1,45 @@
+package models
+
+// DefaultRegionCode — код региона по умолчанию, собранного из переменных PRICING_*.
+const DefaultRegionCode = "default"
+
+// Region описывает регион обслуживания: валюту, тариф доставки, ставку налога и часовой пояс.
+type Region struct {
+	Code           string     `json:"code"`
+	Name           string     `json:"name,omitempty"`
+	Currency       string     `json:"currency"`                   // ISO 4217, в ней ведутся все суммы заказов региона
+	Timezone       string     `json:"timezone,omitempty"`         // IANA, например Europe/Moscow; пустой — UTC
+	TaxRate        float64    `json:"tax_rate"`                   // основная ставка, доля от 0 до 1, например 0.2 = 20%
+	ReducedTaxRate float64    `json:"reduced_tax_rate,omitempty"` // ставка для товаров категории reduced
+	BaseFare       float64    `json:"base_fare"`
+	PerKm          float64    `json:"per_km"`
+	MinFare        float64    `json:"min_fare"`
+	Bounds         *GeoBounds `json:"bounds,omitempty"` // область региона; у региона по умолчанию не задается
+
+	// Payout — правила выплат курьерам в валюте региона; не задаются только у регионов
+	// в валюте региона по умолчанию, для них действуют PAYOUT_*
+	Payout *RegionPayout `json:"payout,omitempty"`
+
+	// VehicleMultipliers — надбавка к тарифу для транспорта, например {"car": 1.5}; не указанный транспорт — 1
+	VehicleMultipliers map[VehicleType]float64 `json:"vehicle_multipliers,omitempty"`
+}
+
+// RegionPayout — выплата курьеру за доставку: ставка, надбавка за км и минимум.
+type RegionPayout struct {
+	PerDelivery float64 `json:"per_delivery"`
+	PerKm       float64 `json:"per_km"`
+	MinPayout   float64 `json:"min_payout"`
+}
+
+// GeoBounds — прямоугольная область в координатах WGS84.
+type GeoBounds struct {
+	MinLat float64 `json:"min_lat"`
+	MinLon float64 `json:"min_lon"`
+	MaxLat float64 `json:"max_lat"`
+	MaxLon float64 `json:"max_lon"`
+}
+
+// Contains сообщает, попадает ли точка в область (границы включительно).
+func (b GeoBounds) Contains(lat, lon float64) bool {
+	return lat >= b.MinLat && lat <= b.MaxLat && lon >= b.MinLon && lon <= b.MaxLon
+}
@@ -0,0 +1,102 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ReviewStatus — состояние модерации отзыва.
+type ReviewStatus string
+
+const (
+	ReviewStatusPending   ReviewStatus = "pending"   // фильтр нашел нарушение, отзыв ждет модератора и не учитывается в рейтинге
+	ReviewStatusPublished ReviewStatus = "published" // отзыв виден в списке отзывов курьера
+	ReviewStatusHidden    ReviewStatus = "hidden"    // скрыт модератором и не учитывается в рейтинге
+)
+
+// IsValid проверяет, что состояние отзыва известно
+func (s ReviewStatus) IsValid() bool {
+	switch s {
+	case ReviewStatusPending, ReviewStatusPublished, ReviewStatusHidden:
+		return true
+	default:
+		return false
+	}
+}
+
+// ReviewTag — структурированная отметка в отзыве. Теги относятся ко всему заказу, поэтому
+// аналитика считает их и по курьеру, и по заведению.
+type ReviewTag string
+
+const (
+	ReviewTagLate         ReviewTag = "late"
+	ReviewTagColdFood     ReviewTag = "cold_food"
+	ReviewTagRude         ReviewTag = "rude"
+	ReviewTagGreatService ReviewTag = "great_service"
+)
+
+// ReviewTags — все теги в порядке колонок CSV-выгрузки.
+var ReviewTags = []ReviewTag{ReviewTagLate, ReviewTagColdFood, ReviewTagRude, ReviewTagGreatService}
+
+// IsValid проверяет, что тег есть в списке
+func (t ReviewTag) IsValid() bool {
+	switch t {
+	case ReviewTagLate, ReviewTagColdFood, ReviewTagRude, ReviewTagGreatService:
+		return true
+	default:
+		return false
+	}
+}
+
+// Ограничения длины текстов отзыва.
+const (
+	MaxReviewCommentLength    = 2000
+	MaxReviewReplyLength      = 2000
+	MaxModerationReasonLength = 500
+)
+
+// Review представляет отзыв о заказе/курьере
+type Review struct {
+	ID               uuid.UUID    `json:"id" db:"id"`
+	OrderID          uuid.UUID    `json:"order_id" db:"order_id"`
+	CourierID        uuid.UUID    `json:"courier_id" db:"courier_id"`
+	Rating           int          `json:"rating" db:"rating"`
+	MerchantRating   *int         `json:"merchant_rating,omitempty" db:"merchant_rating"` // оценка заведения, если клиент ее поставил
+	Tags             []ReviewTag  `json:"tags,omitempty" db:"tags"`
+	Comment          *string      `json:"comment,omitempty" db:"comment"`
+	Status           ReviewStatus `json:"status" db:"status"`
+	ModerationReason *string      `json:"moderation_reason,omitempty" db:"moderation_reason"`
+	Reply            *string      `json:"reply,omitempty" db:"reply"` // ответ курьера
+	RepliedAt        *time.Time   `json:"replied_at,omitempty" db:"replied_at"`
+	CreatedAt        time.Time    `json:"created_at" db:"created_at"`
+	EditedAt         *time.Time   `json:"edited_at,omitempty" db:"edited_at"`
+	Author           *string      `json:"-" db:"author"` // исполнитель "type:id", создавший отзыв; только он может его править
+}
+
+// CreateReviewRequest представляет запрос на создание отзыва по заказу
+type CreateReviewRequest struct {
+	Rating         int         `json:"rating"`
+	MerchantRating *int        `json:"merchant_rating,omitempty"`
+	Tags           []ReviewTag `json:"tags,omitempty"`
+	Comment        *string     `json:"comment,omitempty"`
+}
+
+// UpdateReviewRequest — правка отзыва клиентом: оценки, теги и комментарий заменяются целиком.
+type UpdateReviewRequest struct {
+	Rating         int         `json:"rating"`
+	MerchantRating *int        `json:"merchant_rating,omitempty"`
+	Tags           []ReviewTag `json:"tags,omitempty"`
+	Comment        *string     `json:"comment,omitempty"`
+}
+
+// ReviewReplyRequest — ответ курьера на отзыв.
+type ReviewReplyRequest struct {
+	Text string `json:"text"`
+}
+
+// ModerateReviewRequest — решение модератора по отзыву. Для скрытия нужна причина.
+type ModerateReviewRequest struct {
+	Status ReviewStatus `json:"status"`
+	Reason string       `json:"reason,omitempty"`
+}
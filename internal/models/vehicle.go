@@ -0,0 +1,75 @@
+package models
+
+// VehicleType представляет транспорт курьера
+type VehicleType string
+
+const (
+	VehicleTypeBike    VehicleType = "bike"
+	VehicleTypeScooter VehicleType = "scooter"
+	VehicleTypeCar     VehicleType = "car"
+)
+
+// VehicleTypes возвращает типы транспорта от меньшего к большему.
+func VehicleTypes() []VehicleType {
+	return []VehicleType{VehicleTypeBike, VehicleTypeScooter, VehicleTypeCar}
+}
+
+// IsValid сообщает, известен ли тип транспорта.
+func (v VehicleType) IsValid() bool {
+	switch v {
+	case VehicleTypeBike, VehicleTypeScooter, VehicleTypeCar:
+		return true
+	default:
+		return false
+	}
+}
+
+// AverageSpeedKmh возвращает среднюю скорость транспорта в городе для расчета времени доставки.
+func (v VehicleType) AverageSpeedKmh() float64 {
+	switch v {
+	case VehicleTypeScooter:
+		return 25
+	case VehicleTypeCar:
+		return 30
+	default:
+		return 15
+	}
+}
+
+// VehicleProfile — типовые ограничения транспорта: по ним выбирается тариф заказа
+// и заполняются ограничения нового курьера, если они не указаны явно.
+type VehicleProfile struct {
+	MaxWeightKg float64 `json:"max_weight_kg"`
+	MaxVolumeL  float64 `json:"max_volume_l"`
+	MaxRangeKm  float64 `json:"max_range_km"`
+}
+
+// DefaultVehicleProfile возвращает типовые ограничения транспорта.
+func DefaultVehicleProfile(v VehicleType) VehicleProfile {
+	switch v {
+	case VehicleTypeScooter:
+		return VehicleProfile{MaxWeightKg: 25, MaxVolumeL: 90, MaxRangeKm: 25}
+	case VehicleTypeCar:
+		return VehicleProfile{MaxWeightKg: 200, MaxVolumeL: 1000, MaxRangeKm: 100}
+	default:
+		return VehicleProfile{MaxWeightKg: 10, MaxVolumeL: 40, MaxRangeKm: 8}
+	}
+}
+
+// Fits сообщает, помещается ли груз и укладывается ли поездка в ограничения.
+func (p VehicleProfile) Fits(weightKg, volumeL, distanceKm float64) bool {
+	return weightKg <= p.MaxWeightKg && volumeL <= p.MaxVolumeL && distanceKm <= p.MaxRangeKm
+}
+
+// VehicleForLoad возвращает наименьший транспорт, типовые ограничения которого подходят для заказа.
+// Если не подходит ни один, возвращается самый крупный: у курьеров могут быть ограничения больше типовых,
+// и возможность перевозки проверяется при назначении по ограничениям конкретного курьера.
+func VehicleForLoad(weightKg, volumeL, distanceKm float64) VehicleType {
+	types := VehicleTypes()
+	for _, v := range types {
+		if DefaultVehicleProfile(v).Fits(weightKg, volumeL, distanceKm) {
+			return v
+		}
+	}
+	return types[len(types)-1]
+}
@@ -0,0 +1,44 @@
+package models
+
+import (
+	"time"
+
+	"delivery-system/internal/money"
+	"delivery-system/internal/pagination"
+
+	"github.com/google/uuid"
+)
+
+// WalletTransactionKind описывает вид операции по бонусному счету клиента.
+type WalletTransactionKind string
+
+const (
+	WalletKindReferralReward WalletTransactionKind = "referral_reward"
+	WalletKindOrderPayment   WalletTransactionKind = "order_payment" // списание бонусов в счет заказа
+	WalletKindOrderRefund    WalletTransactionKind = "order_refund"  // возврат списанных бонусов при отмене заказа
+)
+
+// WalletTransaction — операция по бонусному счету. Начисления положительные, списания отрицательные.
+type WalletTransaction struct {
+	ID            uuid.UUID             `json:"id" db:"id"`
+	CustomerPhone string                `json:"customer_phone" db:"customer_phone"`
+	Kind          WalletTransactionKind `json:"kind" db:"kind"`
+	Amount        money.Money           `json:"amount" db:"amount"`
+	Currency      string                `json:"currency" db:"currency"`
+	OrderID       *uuid.UUID            `json:"order_id,omitempty" db:"order_id"`
+	ReferralID    *uuid.UUID            `json:"referral_id,omitempty" db:"referral_id"`
+	CreatedAt     time.Time             `json:"created_at" db:"created_at"`
+}
+
+// WalletBalance — остаток бонусов клиента в одной валюте.
+type WalletBalance struct {
+	Currency string      `json:"currency"`
+	Balance  money.Money `json:"balance"`
+}
+
+// Wallet — бонусный счет клиента: остатки по валютам и последние операции.
+type Wallet struct {
+	CustomerPhone string                               `json:"customer_phone"`
+	Balances      []WalletBalance                      `json:"balances"`
+	Transactions  *pagination.Page[*WalletTransaction] `json:"transactions"` // операции, новые первыми
+}
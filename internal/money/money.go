@@ -0,0 +1,308 @@
+package money
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency используется, когда валюта суммы не указана явно (например, при чтении из БД).
+var DefaultCurrency = "RUB"
+
+// scale — количество минимальных единиц в основной (копеек в рубле).
+// Все поддерживаемые валюты имеют две десятичные цифры.
+const scale = 100
+
+// ErrInvalidAmount возвращается при разборе некорректной денежной суммы.
+var ErrInvalidAmount = errors.New("invalid money amount")
+
+// Money — денежная сумма в минимальных единицах валюты (копейках) с кодом валюты ISO 4217.
+// В JSON сериализуется числом с двумя знаками после запятой для совместимости с прежним API,
+// в БД — строкой DECIMAL.
+type Money struct {
+	Amount   int64  // сумма в минимальных единицах
+	Currency string // код валюты, пустой — валюта не задана
+}
+
+// New создает сумму из минимальных единиц.
+func New(minor int64, currency string) Money {
+	return Money{Amount: minor, Currency: currency}
+}
+
+// FromFloat переводит сумму в основных единицах в Money с округлением до копейки (half away from zero).
+func FromFloat(v float64, currency string) Money {
+	return Money{Amount: int64(math.Round(v * scale)), Currency: currency}
+}
+
+// Parse разбирает десятичную строку ("123.45", "-0.5", "10") без потери точности.
+// Цифры после второго знака округляются half away from zero.
+func Parse(s, currency string) (Money, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return Money{}, ErrInvalidAmount
+	}
+	if strings.ContainsAny(s, "eE") {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return Money{}, ErrInvalidAmount
+		}
+		return FromFloat(f, currency), nil
+	}
+
+	negative := false
+	switch s[0] {
+	case '-':
+		negative = true
+		s = s[1:]
+	case '+':
+		s = s[1:]
+	}
+
+	intPart, fracPart, _ := strings.Cut(s, ".")
+	if intPart == "" && fracPart == "" {
+		return Money{}, ErrInvalidAmount
+	}
+	if intPart == "" {
+		intPart = "0"
+	}
+	if !isDigits(intPart) || !isDigits(fracPart) {
+		return Money{}, ErrInvalidAmount
+	}
+
+	units, err := strconv.ParseInt(intPart, 10, 64)
+	if err != nil || units > math.MaxInt64/scale-1 {
+		return Money{}, ErrInvalidAmount
+	}
+
+	var cents int64
+	for i := 0; i < 2; i++ {
+		cents *= 10
+		if i < len(fracPart) {
+			cents += int64(fracPart[i] - '0')
+		}
+	}
+	if len(fracPart) > 2 && fracPart[2] >= '5' {
+		cents++
+	}
+
+	amount := units*scale + cents
+	if negative {
+		amount = -amount
+	}
+	return Money{Amount: amount, Currency: currency}, nil
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// IsCurrencyCode проверяет, что код валюты состоит из трех латинских заглавных букв (ISO 4217).
+func IsCurrencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// Zero возвращает нулевую сумму в валюте.
+func Zero(currency string) Money {
+	return Money{Currency: currency}
+}
+
+// In возвращает ту же сумму с указанной валютой.
+func (m Money) In(currency string) Money {
+	m.Currency = currency
+	return m
+}
+
+// SameCurrency сообщает, можно ли складывать суммы. Пустая валюта совместима с любой.
+func (m Money) SameCurrency(o Money) bool {
+	return m.Currency == "" || o.Currency == "" || m.Currency == o.Currency
+}
+
+// Add складывает суммы. Сложение разных валют — ошибка программиста и приводит к панике.
+func (m Money) Add(o Money) Money {
+	return Money{Amount: m.Amount + o.Amount, Currency: m.mustMerge(o)}
+}
+
+// Sub вычитает сумму. Вычитание разных валют приводит к панике.
+func (m Money) Sub(o Money) Money {
+	return Money{Amount: m.Amount - o.Amount, Currency: m.mustMerge(o)}
+}
+
+// Mul умножает сумму на целое количество (например, цену на количество товара).
+func (m Money) Mul(n int64) Money {
+	m.Amount *= n
+	return m
+}
+
+// MulFloat умножает сумму на коэффициент с округлением до копейки.
+func (m Money) MulFloat(f float64) Money {
+	m.Amount = int64(math.Round(float64(m.Amount) * f))
+	return m
+}
+
+// Percent возвращает p процентов от суммы с округлением до копейки.
+func (m Money) Percent(p float64) Money {
+	return m.MulFloat(p / 100)
+}
+
+// Neg возвращает сумму с противоположным знаком.
+func (m Money) Neg() Money {
+	m.Amount = -m.Amount
+	return m
+}
+
+// IsZero сообщает, равна ли сумма нулю.
+func (m Money) IsZero() bool { return m.Amount == 0 }
+
+// IsPositive сообщает, больше ли сумма нуля.
+func (m Money) IsPositive() bool { return m.Amount > 0 }
+
+// IsNegative сообщает, меньше ли сумма нуля.
+func (m Money) IsNegative() bool { return m.Amount < 0 }
+
+// Cmp сравнивает суммы: -1, 0 или 1. Сравнение разных валют приводит к панике.
+func (m Money) Cmp(o Money) int {
+	m.mustMerge(o)
+	switch {
+	case m.Amount < o.Amount:
+		return -1
+	case m.Amount > o.Amount:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Min возвращает меньшую из сумм.
+func Min(a, b Money) Money {
+	if a.Cmp(b) <= 0 {
+		return a.In(a.mustMerge(b))
+	}
+	return b.In(a.mustMerge(b))
+}
+
+// Max возвращает большую из сумм.
+func Max(a, b Money) Money {
+	if a.Cmp(b) >= 0 {
+		return a.In(a.mustMerge(b))
+	}
+	return b.In(a.mustMerge(b))
+}
+
+// Float64 возвращает сумму в основных единицах. Используется только для логов и отображения.
+func (m Money) Float64() float64 {
+	return float64(m.Amount) / scale
+}
+
+// String форматирует сумму как десятичное число с двумя знаками ("123.45").
+func (m Money) String() string {
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, amount/scale, amount%scale)
+}
+
+// Display форматирует сумму с кодом валюты ("123.45 RUB").
+func (m Money) Display() string {
+	if m.Currency == "" {
+		return m.String()
+	}
+	return m.String() + " " + m.Currency
+}
+
+func (m Money) mustMerge(o Money) string {
+	if !m.SameCurrency(o) {
+		panic(fmt.Sprintf("money: currency mismatch %s vs %s", m.Currency, o.Currency))
+	}
+	if m.Currency != "" {
+		return m.Currency
+	}
+	return o.Currency
+}
+
+// MarshalJSON сериализует сумму числом ("total_amount": 123.45).
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON принимает число или строку с числом; валюта не меняется.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := strings.TrimSpace(string(data))
+	if s == "null" {
+		m.Amount = 0
+		return nil
+	}
+	if strings.HasPrefix(s, `"`) {
+		var str string
+		if err := json.Unmarshal(data, &str); err != nil {
+			return err
+		}
+		s = str
+	}
+	parsed, err := Parse(s, m.Currency)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidAmount, s)
+	}
+	*m = parsed
+	return nil
+}
+
+// Scan реализует sql.Scanner для колонок DECIMAL. Если валюта не задана, подставляется DefaultCurrency.
+func (m *Money) Scan(src interface{}) error {
+	currency := m.Currency
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+
+	switch v := src.(type) {
+	case nil:
+		*m = Zero(currency)
+		return nil
+	case []byte:
+		parsed, err := Parse(string(v), currency)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidAmount, v)
+		}
+		*m = parsed
+		return nil
+	case string:
+		parsed, err := Parse(v, currency)
+		if err != nil {
+			return fmt.Errorf("%w: %s", ErrInvalidAmount, v)
+		}
+		*m = parsed
+		return nil
+	case float64:
+		*m = FromFloat(v, currency)
+		return nil
+	case int64:
+		*m = New(v*scale, currency)
+		return nil
+	default:
+		return fmt.Errorf("money: unsupported scan type %T", src)
+	}
+}
+
+// Value реализует driver.Valuer: сумма передается в БД десятичной строкой.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
@@ -0,0 +1,128 @@
+package money
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		in   string
+		want int64
+	}{
+		{"0", 0},
+		{"10", 1000},
+		{"123.45", 12345},
+		{"123.4", 12340},
+		{".5", 50},
+		{"-0.5", -50},
+		{"0.105", 11},
+		{"0.104", 10},
+		{"-1.005", -101},
+		{"523.3333333333", 52333},
+		{"1e2", 10000},
+	}
+	for _, tc := range cases {
+		got, err := Parse(tc.in, "RUB")
+		if err != nil {
+			t.Fatalf("Parse(%q) unexpected error: %v", tc.in, err)
+		}
+		if got.Amount != tc.want || got.Currency != "RUB" {
+			t.Fatalf("Parse(%q) = %+v, want %d RUB", tc.in, got, tc.want)
+		}
+	}
+
+	for _, bad := range []string{"", "-", ".", "abc", "1.2.3", "1,5", "--1"} {
+		if _, err := Parse(bad, "RUB"); err == nil {
+			t.Fatalf("Parse(%q) expected error", bad)
+		}
+	}
+}
+
+func TestArithmeticIsExact(t *testing.T) {
+	// 0.1 + 0.2 во float64 дает 0.30000000000000004
+	sum := FromFloat(0.1, "RUB").Add(FromFloat(0.2, "RUB"))
+	if sum.String() != "0.30" {
+		t.Fatalf("expected 0.30, got %s", sum)
+	}
+
+	price := New(1999, "RUB")
+	if got := price.Mul(3); got.Amount != 5997 {
+		t.Fatalf("expected 5997, got %d", got.Amount)
+	}
+	if got := New(12345, "RUB").Percent(10); got.Amount != 1235 {
+		t.Fatalf("expected 1235, got %d", got.Amount)
+	}
+	if got := New(100, "RUB").Sub(New(250, "RUB")); got.String() != "-1.50" {
+		t.Fatalf("expected -1.50, got %s", got)
+	}
+	if got := Min(New(100, "RUB"), New(50, "")); got.Amount != 50 || got.Currency != "RUB" {
+		t.Fatalf("unexpected min: %+v", got)
+	}
+	if got := Zero("").Add(New(100, "EUR")); got.Currency != "EUR" {
+		t.Fatalf("empty currency must adopt the other one, got %q", got.Currency)
+	}
+}
+
+func TestCurrencyMismatchPanics(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("expected panic on currency mismatch")
+		}
+	}()
+	New(100, "RUB").Add(New(100, "EUR"))
+}
+
+func TestJSONBackwardCompatible(t *testing.T) {
+	data, err := json.Marshal(struct {
+		Total Money `json:"total_amount"`
+	}{Total: New(15050, "RUB")})
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if string(data) != `{"total_amount":150.50}` {
+		t.Fatalf("unexpected json: %s", data)
+	}
+
+	var req struct {
+		Price Money `json:"price"`
+		Str   Money `json:"str"`
+	}
+	if err := json.Unmarshal([]byte(`{"price":99.9,"str":"12.34"}`), &req); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if req.Price.Amount != 9990 || req.Str.Amount != 1234 {
+		t.Fatalf("unexpected values: %+v", req)
+	}
+
+	if err := json.Unmarshal([]byte(`{"price":"abc"}`), &req); err == nil {
+		t.Fatalf("expected error for invalid amount")
+	}
+}
+
+func TestScanAndValue(t *testing.T) {
+	var m Money
+	if err := m.Scan([]byte("1234.56")); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if m.Amount != 123456 || m.Currency != DefaultCurrency {
+		t.Fatalf("unexpected scan result: %+v", m)
+	}
+
+	m = Money{Currency: "EUR"}
+	if err := m.Scan(float64(10.5)); err != nil {
+		t.Fatalf("scan: %v", err)
+	}
+	if m.Amount != 1050 || m.Currency != "EUR" {
+		t.Fatalf("scan must keep preset currency: %+v", m)
+	}
+
+	if err := m.Scan(nil); err != nil || !m.IsZero() {
+		t.Fatalf("nil must scan to zero: %+v %v", m, err)
+	}
+
+	v, err := New(-705, "RUB").Value()
+	if err != nil || v != "-7.05" {
+		t.Fatalf("unexpected value: %v %v", v, err)
+	}
+}
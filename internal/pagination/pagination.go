@@ -0,0 +1,91 @@
+// Package pagination реализует keyset-пагинацию списков: непрозрачный курсор с ключом сортировки
+// последней строки страницы и общий конверт ответа с next_cursor.
+package pagination
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
+	"delivery-system/internal/apperror"
+)
+
+// Cursor — ключ сортировки последней строки страницы. Основной ключ — (created_at, id);
+// Rating и Reviews заполняются для списков, отсортированных по рейтингу, Amount и Rank — для поиска
+// заказов, отсортированного по сумме или релевантности.
+type Cursor struct {
+	Sort      string    `json:"s,omitempty"` // сортировка, для которой выдан курсор
+	CreatedAt time.Time `json:"t"`
+	ID        string    `json:"id"`
+	Rating    *float64  `json:"r,omitempty"`
+	Reviews   *int      `json:"n,omitempty"`
+	Amount    *string   `json:"a,omitempty"` // десятичная строка суммы
+	Rank      *float64  `json:"k,omitempty"`
+}
+
+// Encode возвращает курсор в виде строки для next_cursor.
+func (c Cursor) Encode() string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// Decode разбирает курсор, выданный Encode. Ошибка — apperror.Validation.
+func Decode(token string) (*Cursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, apperror.Validation("invalid cursor", err)
+	}
+	var c Cursor
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, apperror.Validation("invalid cursor", err)
+	}
+	if c.ID == "" || c.CreatedAt.IsZero() {
+		return nil, apperror.Validation("invalid cursor", nil)
+	}
+	return &c, nil
+}
+
+// Request — параметры страницы. After задает продолжение с курсора; Offset оставлен для старых
+// клиентов и применяется, только если курсор не передан. Limit 0 — без ограничения.
+type Request struct {
+	Limit  int
+	Offset int
+	After  *Cursor
+}
+
+// CheckSort проверяет, что курсор выдан для той же сортировки.
+func (r Request) CheckSort(sort string) error {
+	if r.After != nil && r.After.Sort != sort {
+		return apperror.Validation("cursor does not match sort order", nil)
+	}
+	return nil
+}
+
+// Page — страница списка в общем конверте ответа. NextCursor пустой на последней странице.
+type Page[T any] struct {
+	Items      []T     `json:"items"`
+	NextCursor *string `json:"next_cursor"`
+}
+
+// NewPage собирает страницу из строк, выбранных с лимитом limit+1: лишняя строка означает,
+// что есть следующая страница, и курсор строится по последней возвращаемой строке.
+func NewPage[T any](rows []T, limit int, cursorOf func(T) Cursor) *Page[T] {
+	page := &Page[T]{Items: rows}
+	if page.Items == nil {
+		page.Items = []T{}
+	}
+	if limit > 0 && len(rows) > limit {
+		page.Items = rows[:limit]
+		next := cursorOf(page.Items[limit-1]).Encode()
+		page.NextCursor = &next
+	}
+	return page
+}
+
+// FetchLimit возвращает LIMIT для запроса: на одну строку больше страницы, 0 — без ограничения.
+func (r Request) FetchLimit() int {
+	if r.Limit <= 0 {
+		return 0
+	}
+	return r.Limit + 1
+}
@@ -0,0 +1,65 @@
+package pagination
+
+import (
+	"testing"
+	"time"
+
+	"delivery-system/internal/apperror"
+)
+
+func TestCursor_EncodeDecode(t *testing.T) {
+	rating, reviews := 4.75, 12
+	cursor := Cursor{Sort: "rating", CreatedAt: time.Date(2024, 3, 1, 10, 0, 0, 123456000, time.UTC), ID: "abc", Rating: &rating, Reviews: &reviews}
+
+	decoded, err := Decode(cursor.Encode())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if decoded.Sort != "rating" || decoded.ID != "abc" || !decoded.CreatedAt.Equal(cursor.CreatedAt) || *decoded.Rating != rating || *decoded.Reviews != reviews {
+		t.Fatalf("unexpected cursor: %+v", decoded)
+	}
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	for _, token := range []string{"!!!", "bm90LWpzb24", Cursor{}.Encode()} {
+		if _, err := Decode(token); !apperror.Is(err, apperror.KindValidation) {
+			t.Errorf("expected validation error for %q, got %v", token, err)
+		}
+	}
+}
+
+func TestNewPage(t *testing.T) {
+	cursorOf := func(v int) Cursor { return Cursor{CreatedAt: time.Unix(int64(v), 0), ID: "id"} }
+
+	page := NewPage([]int{1, 2, 3}, 2, cursorOf)
+	if len(page.Items) != 2 || page.NextCursor == nil {
+		t.Fatalf("expected trimmed page with next cursor, got %+v", page)
+	}
+	next, err := Decode(*page.NextCursor)
+	if err != nil || next.CreatedAt.Unix() != 2 {
+		t.Fatalf("expected cursor of last returned row, got %+v (%v)", next, err)
+	}
+
+	page = NewPage([]int{1, 2}, 2, cursorOf)
+	if len(page.Items) != 2 || page.NextCursor != nil {
+		t.Fatalf("expected last page without cursor, got %+v", page)
+	}
+
+	page = NewPage[int](nil, 10, cursorOf)
+	if page.Items == nil {
+		t.Fatalf("expected empty items slice instead of nil")
+	}
+}
+
+func TestRequest_CheckSort(t *testing.T) {
+	req := Request{After: &Cursor{Sort: "created_at", CreatedAt: time.Now(), ID: "x"}}
+	if err := req.CheckSort("rating"); !apperror.Is(err, apperror.KindValidation) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	if err := req.CheckSort("created_at"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if (Request{Limit: 10}).FetchLimit() != 11 || (Request{}).FetchLimit() != 0 {
+		t.Fatalf("unexpected fetch limit")
+	}
+}
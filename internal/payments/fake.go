@@ -0,0 +1,134 @@
+package payments
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"delivery-system/internal/money"
+)
+
+type fakePayment struct {
+	authorized money.Money
+	captured   money.Money
+	refunded   money.Money
+	voided     bool
+}
+
+// FakeProvider — встроенный провайдер, хранящий платежи в памяти процесса.
+// Используется в тестах и локальной разработке.
+type FakeProvider struct {
+	mu       sync.Mutex
+	seq      int
+	payments map[string]*fakePayment
+	keys     map[string]string
+
+	// DeclineAuthorize заставляет провайдера отклонять авторизации.
+	DeclineAuthorize bool
+	// DeclineCapture заставляет провайдера отклонять списания.
+	DeclineCapture bool
+}
+
+// NewFakeProvider создает встроенный провайдер.
+func NewFakeProvider() *FakeProvider {
+	return &FakeProvider{payments: make(map[string]*fakePayment), keys: make(map[string]string)}
+}
+
+// Name возвращает идентификатор провайдера.
+func (p *FakeProvider) Name() string {
+	return "fake"
+}
+
+// Authorize холдирует сумму. Повтор с тем же ключом идемпотентности возвращает прежнюю ссылку.
+func (p *FakeProvider) Authorize(ctx context.Context, req AuthorizeRequest) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	if req.Amount.IsNegative() {
+		return "", ErrInvalidAmount
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if ref, ok := p.keys[req.IdempotencyKey]; ok && req.IdempotencyKey != "" {
+		return ref, nil
+	}
+	if p.DeclineAuthorize {
+		return "", ErrDeclined
+	}
+
+	p.seq++
+	ref := fmt.Sprintf("fake_%s_%d", req.OrderID, p.seq)
+	p.payments[ref] = &fakePayment{authorized: req.Amount}
+	if req.IdempotencyKey != "" {
+		p.keys[req.IdempotencyKey] = ref
+	}
+	return ref, nil
+}
+
+// Capture списывает авторизованную сумму.
+func (p *FakeProvider) Capture(ctx context.Context, ref string, amount money.Money) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, ok := p.payments[ref]
+	if !ok {
+		return ErrUnknownPayment
+	}
+	if p.DeclineCapture || payment.voided {
+		return ErrDeclined
+	}
+	if amount.IsNegative() || !amount.SameCurrency(payment.authorized) || amount.Cmp(payment.authorized) > 0 {
+		return ErrInvalidAmount
+	}
+
+	payment.captured = amount
+	return nil
+}
+
+// Void отменяет авторизацию.
+func (p *FakeProvider) Void(ctx context.Context, ref string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, ok := p.payments[ref]
+	if !ok {
+		return ErrUnknownPayment
+	}
+	if payment.captured.IsPositive() {
+		return ErrDeclined
+	}
+
+	payment.voided = true
+	return nil
+}
+
+// Refund возвращает часть списанной суммы.
+func (p *FakeProvider) Refund(ctx context.Context, ref string, amount money.Money) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	payment, ok := p.payments[ref]
+	if !ok {
+		return ErrUnknownPayment
+	}
+	if !amount.IsPositive() || !amount.SameCurrency(payment.captured) || payment.refunded.Add(amount).Cmp(payment.captured) > 0 {
+		return ErrInvalidAmount
+	}
+
+	payment.refunded = payment.refunded.Add(amount)
+	return nil
+}
@@ -0,0 +1,107 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"delivery-system/internal/config"
+	"delivery-system/internal/money"
+
+	"github.com/google/uuid"
+)
+
+func TestFakeProvider_Lifecycle(t *testing.T) {
+	p := NewFakeProvider()
+	ctx := context.Background()
+
+	ref, err := p.Authorize(ctx, AuthorizeRequest{OrderID: uuid.New(), Amount: rub(100)})
+	if err != nil {
+		t.Fatalf("authorize failed: %v", err)
+	}
+
+	if err := p.Capture(ctx, ref, rub(150)); !errors.Is(err, ErrInvalidAmount) {
+		t.Fatalf("expected ErrInvalidAmount for over-capture, got %v", err)
+	}
+	if err := p.Capture(ctx, ref, money.FromFloat(100, "EUR")); !errors.Is(err, ErrInvalidAmount) {
+		t.Fatalf("expected ErrInvalidAmount for currency mismatch, got %v", err)
+	}
+	if err := p.Capture(ctx, ref, rub(100)); err != nil {
+		t.Fatalf("capture failed: %v", err)
+	}
+	if err := p.Void(ctx, ref); !errors.Is(err, ErrDeclined) {
+		t.Fatalf("expected void of captured payment to be declined, got %v", err)
+	}
+
+	if err := p.Refund(ctx, ref, rub(40)); err != nil {
+		t.Fatalf("partial refund failed: %v", err)
+	}
+	if err := p.Refund(ctx, ref, rub(60.01)); !errors.Is(err, ErrInvalidAmount) {
+		t.Fatalf("expected ErrInvalidAmount for over-refund, got %v", err)
+	}
+	if err := p.Refund(ctx, ref, rub(60)); err != nil {
+		t.Fatalf("final refund failed: %v", err)
+	}
+}
+
+func TestFakeProvider_AuthorizeIdempotent(t *testing.T) {
+	p := NewFakeProvider()
+	ctx := context.Background()
+	orderID := uuid.New()
+
+	first, err := p.Authorize(ctx, AuthorizeRequest{OrderID: orderID, Amount: rub(100), IdempotencyKey: "payment:authorize"})
+	if err != nil {
+		t.Fatalf("authorize failed: %v", err)
+	}
+	second, err := p.Authorize(ctx, AuthorizeRequest{OrderID: orderID, Amount: rub(100), IdempotencyKey: "payment:authorize"})
+	if err != nil || second != first {
+		t.Fatalf("expected repeated key to return %s, got %s (%v)", first, second, err)
+	}
+	other, _ := p.Authorize(ctx, AuthorizeRequest{OrderID: orderID, Amount: rub(100), IdempotencyKey: "other:authorize"})
+	if other == first {
+		t.Fatalf("expected a new authorization for another key")
+	}
+	unkeyed, _ := p.Authorize(ctx, AuthorizeRequest{OrderID: orderID, Amount: rub(100)})
+	again, _ := p.Authorize(ctx, AuthorizeRequest{OrderID: orderID, Amount: rub(100)})
+	if unkeyed == again {
+		t.Fatalf("expected requests without key not to be deduplicated")
+	}
+}
+
+func TestFakeProvider_Declines(t *testing.T) {
+	p := NewFakeProvider()
+	ctx := context.Background()
+
+	p.DeclineAuthorize = true
+	if _, err := p.Authorize(ctx, AuthorizeRequest{OrderID: uuid.New(), Amount: rub(10)}); !errors.Is(err, ErrDeclined) {
+		t.Fatalf("expected ErrDeclined, got %v", err)
+	}
+
+	p.DeclineAuthorize = false
+	ref, _ := p.Authorize(ctx, AuthorizeRequest{OrderID: uuid.New(), Amount: rub(10)})
+	if err := p.Void(ctx, ref); err != nil {
+		t.Fatalf("void failed: %v", err)
+	}
+	if err := p.Capture(ctx, ref, rub(10)); !errors.Is(err, ErrDeclined) {
+		t.Fatalf("expected capture of voided payment to be declined, got %v", err)
+	}
+	if err := p.Capture(ctx, "missing", rub(10)); !errors.Is(err, ErrUnknownPayment) {
+		t.Fatalf("expected ErrUnknownPayment, got %v", err)
+	}
+}
+
+func TestNew_Providers(t *testing.T) {
+	if p, err := New(&config.PaymentsConfig{Provider: "none"}); err != nil || p != nil {
+		t.Fatalf("expected nil provider for none, got %v, %v", p, err)
+	}
+	if p, err := New(&config.PaymentsConfig{Provider: "fake"}); err != nil || p == nil {
+		t.Fatalf("expected fake provider, got %v, %v", p, err)
+	}
+	if _, err := New(&config.PaymentsConfig{Provider: "stripe"}); err == nil {
+		t.Fatalf("expected error for unsupported provider")
+	}
+}
+
+func rub(v float64) money.Money {
+	return money.FromFloat(v, "RUB")
+}
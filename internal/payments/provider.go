@@ -0,0 +1,59 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"delivery-system/internal/config"
+	"delivery-system/internal/money"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrDeclined возвращается, если провайдер отклонил операцию.
+	ErrDeclined = errors.New("payment declined")
+	// ErrInvalidAmount возвращается при сумме, превышающей доступную для операции, или в другой валюте.
+	ErrInvalidAmount = errors.New("invalid payment amount")
+	// ErrUnknownPayment возвращается, если провайдер не знает платеж с указанной ссылкой.
+	ErrUnknownPayment = errors.New("unknown payment")
+)
+
+// AuthorizeRequest описывает запрос на авторизацию (холдирование) суммы заказа.
+// IdempotencyKey защищает от двойного холдирования при повторе: провайдер возвращает
+// ссылку уже выполненной авторизации с тем же ключом.
+type AuthorizeRequest struct {
+	OrderID        uuid.UUID
+	Amount         money.Money
+	IdempotencyKey string
+}
+
+// PaymentProvider описывает платежного провайдера с двухстадийной оплатой.
+// Реализации должны быть безопасны для конкурентного использования.
+type PaymentProvider interface {
+	// Name возвращает идентификатор провайдера, сохраняемый вместе с платежом.
+	Name() string
+	// Authorize холдирует сумму и возвращает ссылку на платеж у провайдера.
+	Authorize(ctx context.Context, req AuthorizeRequest) (string, error)
+	// Capture списывает ранее авторизованную сумму (не больше авторизованной).
+	Capture(ctx context.Context, ref string, amount money.Money) error
+	// Void отменяет авторизацию без списания.
+	Void(ctx context.Context, ref string) error
+	// Refund возвращает часть или всю списанную сумму.
+	Refund(ctx context.Context, ref string, amount money.Money) error
+}
+
+// New создает провайдера согласно конфигурации. Для provider=none возвращает nil:
+// платежи отключены.
+func New(cfg *config.PaymentsConfig) (PaymentProvider, error) {
+	switch strings.ToLower(cfg.Provider) {
+	case "", "none":
+		return nil, nil
+	case "fake":
+		return NewFakeProvider(), nil
+	default:
+		return nil, fmt.Errorf("unsupported payments provider: %s", cfg.Provider)
+	}
+}
@@ -0,0 +1,83 @@
+package payments
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SignatureHeader — заголовок с подписью вебхука: "t=<unix>,v1=<hex hmac-sha256>".
+const SignatureHeader = "X-Payment-Signature"
+
+var (
+	// ErrInvalidSignature возвращается при отсутствующей или неверной подписи.
+	ErrInvalidSignature = errors.New("invalid webhook signature")
+	// ErrSignatureExpired возвращается, если метка времени подписи вне допустимого окна.
+	ErrSignatureExpired = errors.New("webhook signature expired")
+)
+
+// Sign формирует значение заголовка подписи для payload.
+// Подписывается строка "<unix>.<payload>", что защищает от повторной отправки старых вебхуков.
+func Sign(secret string, timestamp time.Time, payload []byte) string {
+	ts := timestamp.Unix()
+	return fmt.Sprintf("t=%d,v1=%s", ts, computeSignature(secret, ts, payload))
+}
+
+// VerifySignature проверяет подпись вебхука и свежесть метки времени.
+func VerifySignature(secret, header string, payload []byte, now time.Time, tolerance time.Duration) error {
+	if secret == "" || header == "" {
+		return ErrInvalidSignature
+	}
+
+	var (
+		ts        int64
+		signature string
+		err       error
+	)
+	for _, part := range strings.Split(header, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(part), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "t":
+			ts, err = strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return ErrInvalidSignature
+			}
+		case "v1":
+			signature = value
+		}
+	}
+
+	if ts == 0 || signature == "" {
+		return ErrInvalidSignature
+	}
+
+	expected := computeSignature(secret, ts, payload)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+
+	if tolerance > 0 {
+		age := now.Sub(time.Unix(ts, 0))
+		if age > tolerance || age < -tolerance {
+			return ErrSignatureExpired
+		}
+	}
+
+	return nil
+}
+
+func computeSignature(secret string, ts int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(ts, 10)))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
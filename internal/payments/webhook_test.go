@@ -0,0 +1,39 @@
+package payments
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerifySignature(t *testing.T) {
+	payload := []byte(`{"id":"evt_1","type":"payment.captured"}`)
+	now := time.Unix(1700000000, 0)
+	header := Sign("secret", now, payload)
+
+	if err := VerifySignature("secret", header, payload, now.Add(time.Minute), 5*time.Minute); err != nil {
+		t.Fatalf("expected valid signature, got %v", err)
+	}
+
+	if err := VerifySignature("other", header, payload, now, 5*time.Minute); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature for wrong secret, got %v", err)
+	}
+
+	if err := VerifySignature("secret", header, []byte(`{"id":"evt_2"}`), now, 5*time.Minute); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected ErrInvalidSignature for tampered payload, got %v", err)
+	}
+
+	if err := VerifySignature("secret", header, payload, now.Add(10*time.Minute), 5*time.Minute); !errors.Is(err, ErrSignatureExpired) {
+		t.Fatalf("expected ErrSignatureExpired, got %v", err)
+	}
+
+	for _, h := range []string{"", "v1=abc", "t=abc,v1=abc", "t=1700000000"} {
+		if err := VerifySignature("secret", h, payload, now, 0); !errors.Is(err, ErrInvalidSignature) {
+			t.Fatalf("expected ErrInvalidSignature for header %q, got %v", h, err)
+		}
+	}
+
+	if err := VerifySignature("", header, payload, now, 0); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("expected empty secret to reject all webhooks, got %v", err)
+	}
+}
@@ -0,0 +1,106 @@
+package receipts
+
+import (
+	"sort"
+	"time"
+
+	"delivery-system/internal/models"
+	"delivery-system/internal/money"
+
+	"github.com/google/uuid"
+)
+
+// DeliveryLineName — название строки доставки в чеке.
+const DeliveryLineName = "Delivery"
+
+// Build собирает чек по заказу: позиции, доставку, скидку промокода и налоги по ставкам.
+// Доставка облагается по основной ставке региона.
+func Build(order *models.Order, rates Rates, number string, issuedAt time.Time) *models.Receipt {
+	currency := order.Currency
+	receipt := &models.Receipt{
+		ID:             uuid.New(),
+		Number:         number,
+		OrderID:        order.ID,
+		Region:         order.Region,
+		Currency:       currency,
+		ItemsTotal:     money.Zero(currency),
+		DeliveryFee:    order.DeliveryCost.In(currency),
+		DiscountAmount: money.Zero(currency),
+		PromoCode:      order.PromoCode,
+		TaxTotal:       money.Zero(currency),
+		Total:          money.Zero(currency),
+		IssuedAt:       issuedAt,
+	}
+
+	var lines []Line
+	for _, item := range order.Items {
+		category := item.TaxCategory
+		if category == "" {
+			category = models.TaxCategoryStandard
+		}
+		unitPrice := item.Price.In(currency)
+		amount := unitPrice.Mul(int64(item.Quantity))
+		receipt.ItemsTotal = receipt.ItemsTotal.Add(amount)
+		receipt.Lines = append(receipt.Lines, models.ReceiptLine{
+			Kind:        models.ReceiptLineItem,
+			Name:        item.Name,
+			Quantity:    item.Quantity,
+			UnitPrice:   unitPrice,
+			Amount:      amount,
+			TaxCategory: category,
+		})
+		lines = append(lines, Line{Gross: amount, Category: category})
+	}
+
+	if receipt.DeliveryFee.IsPositive() {
+		receipt.Lines = append(receipt.Lines, models.ReceiptLine{
+			Kind:        models.ReceiptLineDelivery,
+			Name:        DeliveryLineName,
+			Quantity:    1,
+			UnitPrice:   receipt.DeliveryFee,
+			Amount:      receipt.DeliveryFee,
+			TaxCategory: models.TaxCategoryStandard,
+		})
+		lines = append(lines, Line{Gross: receipt.DeliveryFee, Category: models.TaxCategoryStandard})
+	}
+
+	results := Calculate(lines, order.DiscountAmount.In(currency), rates)
+
+	type taxKey struct {
+		category models.TaxCategory
+		rate     float64
+	}
+	taxes := make(map[taxKey]*models.ReceiptTax)
+	for i, result := range results {
+		line := &receipt.Lines[i]
+		line.Discount = result.Discount
+		line.Total = result.Net
+		line.TaxRate = result.Rate
+		line.TaxAmount = result.Tax
+
+		receipt.DiscountAmount = receipt.DiscountAmount.Add(result.Discount)
+		receipt.TaxTotal = receipt.TaxTotal.Add(result.Tax)
+		receipt.Total = receipt.Total.Add(result.Net)
+
+		key := taxKey{category: line.TaxCategory, rate: result.Rate}
+		tax, ok := taxes[key]
+		if !ok {
+			tax = &models.ReceiptTax{Category: key.category, Rate: key.rate, Base: money.Zero(currency), Amount: money.Zero(currency)}
+			taxes[key] = tax
+		}
+		tax.Base = tax.Base.Add(result.Net)
+		tax.Amount = tax.Amount.Add(result.Tax)
+	}
+
+	for _, tax := range taxes {
+		receipt.Taxes = append(receipt.Taxes, *tax)
+	}
+	sort.Slice(receipt.Taxes, func(i, j int) bool {
+		if receipt.Taxes[i].Rate != receipt.Taxes[j].Rate {
+			return receipt.Taxes[i].Rate > receipt.Taxes[j].Rate
+		}
+		return receipt.Taxes[i].Category < receipt.Taxes[j].Category
+	})
+
+	return receipt
+}
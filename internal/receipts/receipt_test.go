@@ -0,0 +1,133 @@
+package receipts
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"delivery-system/internal/models"
+	"delivery-system/internal/money"
+
+	"github.com/google/uuid"
+)
+
+func rub(minor int64) money.Money {
+	return money.New(minor, "RUB")
+}
+
+func TestCalculate_AllocatesDiscountWithoutLosingKopecks(t *testing.T) {
+	lines := []Line{
+		{Gross: rub(1000), Category: models.TaxCategoryStandard},
+		{Gross: rub(1000), Category: models.TaxCategoryReduced},
+		{Gross: rub(1000), Category: models.TaxCategoryExempt},
+	}
+	results := Calculate(lines, rub(100), Rates{Standard: 0.2, Reduced: 0.1})
+
+	var discount int64
+	for _, r := range results {
+		discount += r.Discount.Amount
+	}
+	if discount != 100 {
+		t.Fatalf("expected whole discount allocated, got %d", discount)
+	}
+	if results[0].Discount.Amount != 34 || results[1].Discount.Amount != 33 {
+		t.Fatalf("unexpected allocation: %+v", results)
+	}
+	// 966 * 0.2 / 1.2 = 161
+	if results[0].Tax.Amount != 161 || results[0].Net.Amount != 966 {
+		t.Fatalf("unexpected standard line: %+v", results[0])
+	}
+	// 967 * 0.1 / 1.1 = 87.9 -> 88
+	if results[1].Tax.Amount != 88 {
+		t.Fatalf("unexpected reduced tax: %+v", results[1])
+	}
+	if !results[2].Tax.IsZero() {
+		t.Fatalf("exempt line must not be taxed: %+v", results[2])
+	}
+}
+
+func TestCalculate_DiscountCappedByTotal(t *testing.T) {
+	results := Calculate([]Line{{Gross: rub(500)}}, rub(800), Rates{Standard: 0.2})
+	if results[0].Discount.Amount != 500 || !results[0].Net.IsZero() || !results[0].Tax.IsZero() {
+		t.Fatalf("unexpected capped result: %+v", results[0])
+	}
+}
+
+func TestBuild_ItemsDeliveryDiscountAndTaxes(t *testing.T) {
+	promo := "SALE"
+	order := &models.Order{
+		ID:             uuid.New(),
+		Region:         "default",
+		Currency:       "RUB",
+		DeliveryCost:   rub(20000),
+		DiscountAmount: rub(10000),
+		TotalAmount:    rub(70000),
+		PromoCode:      &promo,
+		Items: []models.OrderItem{
+			{Name: "Pizza", Quantity: 2, Price: rub(20000)},
+			{Name: "Juice", Quantity: 1, Price: rub(20000), TaxCategory: models.TaxCategoryReduced},
+		},
+	}
+
+	receipt := Build(order, Rates{Standard: 0.2, Reduced: 0.1}, "R-00000001", time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+
+	if len(receipt.Lines) != 3 || receipt.Lines[2].Kind != models.ReceiptLineDelivery {
+		t.Fatalf("expected 2 items and delivery line, got %+v", receipt.Lines)
+	}
+	if receipt.Total != order.TotalAmount || receipt.DiscountAmount != rub(10000) || receipt.ItemsTotal != rub(60000) {
+		t.Fatalf("unexpected totals: total=%s discount=%s items=%s", receipt.Total, receipt.DiscountAmount, receipt.ItemsTotal)
+	}
+	if len(receipt.Taxes) != 2 || receipt.Taxes[0].Rate != 0.2 || receipt.Taxes[1].Category != models.TaxCategoryReduced {
+		t.Fatalf("unexpected tax breakdown: %+v", receipt.Taxes)
+	}
+
+	var taxSum money.Money
+	for _, tax := range receipt.Taxes {
+		taxSum = taxSum.Add(tax.Amount)
+	}
+	if taxSum != receipt.TaxTotal {
+		t.Fatalf("tax breakdown %s does not match total %s", taxSum, receipt.TaxTotal)
+	}
+}
+
+func TestRender_HTMLAndPDF(t *testing.T) {
+	order := &models.Order{
+		ID:           uuid.New(),
+		Region:       "default",
+		Currency:     "RUB",
+		DeliveryCost: rub(15000),
+		TotalAmount:  rub(25000),
+		Items:        []models.OrderItem{{Name: "Пицца <Маргарита>", Quantity: 1, Price: rub(10000)}},
+	}
+	receipt := Build(order, Rates{Standard: 0.2}, "R-00000042", time.Now())
+
+	html, err := Render(receipt, models.ReceiptFormatHTML)
+	if err != nil {
+		t.Fatalf("html: %v", err)
+	}
+	if !strings.Contains(string(html), "R-00000042") || !strings.Contains(string(html), "&lt;Маргарита&gt;") || !strings.Contains(string(html), "250.00 RUB") {
+		t.Fatalf("unexpected html: %s", html)
+	}
+
+	pdf, err := Render(receipt, models.ReceiptFormatPDF)
+	if err != nil {
+		t.Fatalf("pdf: %v", err)
+	}
+	if !bytes.HasPrefix(pdf, []byte("%PDF-1.4")) || !bytes.HasSuffix(pdf, []byte("%%EOF\n")) {
+		t.Fatalf("unexpected pdf envelope")
+	}
+	if !bytes.Contains(pdf, []byte("Pitstsa <Margarita>")) || !bytes.Contains(pdf, []byte("250.00 RUB")) {
+		t.Fatalf("pdf must contain transliterated item and total: %s", pdf)
+	}
+
+	if _, err := Render(receipt, "xml"); err == nil {
+		t.Fatalf("expected error for unsupported format")
+	}
+}
+
+func TestPDFEscape(t *testing.T) {
+	if got := pdfEscape(`a(b)\c €`); got != `a\(b\)\\c ?` {
+		t.Fatalf("unexpected escape: %s", got)
+	}
+}
@@ -0,0 +1,104 @@
+package receipts
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"math"
+	"strconv"
+	"strings"
+	texttemplate "text/template"
+
+	"delivery-system/internal/models"
+)
+
+//go:embed templates/*.tmpl
+var templateFS embed.FS
+
+// textWidth — ширина строки текстового чека в символах (для PDF моноширинным шрифтом).
+const textWidth = 64
+
+var templateFuncs = map[string]interface{}{
+	"taxRate":    formatTaxRate,
+	"promoLabel": promoLabel,
+	"rule":       func() string { return strings.Repeat("-", textWidth) },
+}
+
+var (
+	htmlTemplate = htmltemplate.Must(htmltemplate.New("receipt.html.tmpl").Funcs(templateFuncs).ParseFS(templateFS, "templates/receipt.html.tmpl"))
+	textTemplate = texttemplate.Must(texttemplate.New("receipt.txt.tmpl").Funcs(templateFuncs).ParseFS(templateFS, "templates/receipt.txt.tmpl"))
+)
+
+// ContentType возвращает MIME-тип документа чека.
+func ContentType(format models.ReceiptFormat) string {
+	switch format {
+	case models.ReceiptFormatHTML:
+		return "text/html; charset=utf-8"
+	case models.ReceiptFormatPDF:
+		return "application/pdf"
+	default:
+		return "application/json"
+	}
+}
+
+// Render формирует документ чека в указанном формате.
+func Render(receipt *models.Receipt, format models.ReceiptFormat) ([]byte, error) {
+	switch format {
+	case models.ReceiptFormatJSON:
+		return RenderJSON(receipt)
+	case models.ReceiptFormatHTML:
+		return RenderHTML(receipt)
+	case models.ReceiptFormatPDF:
+		return RenderPDF(receipt)
+	default:
+		return nil, fmt.Errorf("unsupported receipt format: %s", format)
+	}
+}
+
+// RenderJSON сериализует чек в JSON.
+func RenderJSON(receipt *models.Receipt) ([]byte, error) {
+	return json.Marshal(receipt)
+}
+
+// RenderHTML формирует HTML-версию чека из шаблона.
+func RenderHTML(receipt *models.Receipt) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := htmlTemplate.Execute(&buf, receipt); err != nil {
+		return nil, fmt.Errorf("failed to render receipt html: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// RenderText формирует текстовую версию чека из шаблона; она же раскладывается в PDF.
+func RenderText(receipt *models.Receipt) (string, error) {
+	var buf bytes.Buffer
+	if err := textTemplate.Execute(&buf, receipt); err != nil {
+		return "", fmt.Errorf("failed to render receipt text: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// RenderPDF формирует PDF-версию чека: текстовый шаблон, набранный моноширинным шрифтом.
+func RenderPDF(receipt *models.Receipt) ([]byte, error) {
+	text, err := RenderText(receipt)
+	if err != nil {
+		return nil, err
+	}
+	return writePDF(strings.Split(strings.TrimRight(text, "\n"), "\n")), nil
+}
+
+func formatTaxRate(category models.TaxCategory, rate float64) string {
+	if category == models.TaxCategoryExempt {
+		return "exempt"
+	}
+	return strconv.FormatFloat(math.Round(rate*10000)/100, 'f', -1, 64) + "%"
+}
+
+func promoLabel(code *string) string {
+	if code == nil || *code == "" {
+		return "Promo discount"
+	}
+	return "Promo discount (" + *code + ")"
+}
@@ -0,0 +1,112 @@
+package receipts
+
+import (
+	"math/big"
+	"sort"
+
+	"delivery-system/internal/models"
+	"delivery-system/internal/money"
+)
+
+// Rates — ставки налога региона по категориям, доли от 0 до 1.
+type Rates struct {
+	Standard float64
+	Reduced  float64
+}
+
+// RateFor возвращает ставку для категории; для zero и exempt налог не начисляется.
+func (r Rates) RateFor(category models.TaxCategory) float64 {
+	switch category {
+	case models.TaxCategoryStandard, "":
+		return r.Standard
+	case models.TaxCategoryReduced:
+		return r.Reduced
+	default:
+		return 0
+	}
+}
+
+// Line — позиция до расчета: сумма с налогом и налоговая категория.
+type Line struct {
+	Gross    money.Money
+	Category models.TaxCategory
+}
+
+// Result — позиция после распределения скидки и выделения налога.
+type Result struct {
+	Discount money.Money // доля скидки
+	Net      money.Money // к оплате: Gross - Discount
+	Rate     float64
+	Tax      money.Money // налог в составе Net
+}
+
+// Calculate распределяет скидку по позициям пропорционально их сумме и выделяет налог из цены.
+// Скидка делится в копейках без потерь: остаток после округления вниз отдается позициям
+// с наибольшими дробными частями. Налог считается по каждой позиции: Net * rate / (1 + rate).
+func Calculate(lines []Line, discount money.Money, rates Rates) []Result {
+	results := make([]Result, len(lines))
+	if len(lines) == 0 {
+		return results
+	}
+
+	currency := lines[0].Gross.Currency
+	var grossTotal int64
+	for _, line := range lines {
+		grossTotal += line.Gross.Amount
+	}
+
+	shares := allocate(lines, discount.Amount, grossTotal)
+	for i, line := range lines {
+		share := money.New(shares[i], currency)
+		net := line.Gross.Sub(share)
+		rate := rates.RateFor(line.Category)
+		results[i] = Result{
+			Discount: share,
+			Net:      net,
+			Rate:     rate,
+			Tax:      net.MulFloat(rate / (1 + rate)),
+		}
+	}
+
+	return results
+}
+
+// allocate делит скидку (не больше общей суммы) пропорционально суммам позиций методом наибольших остатков.
+func allocate(lines []Line, discount, grossTotal int64) []int64 {
+	shares := make([]int64, len(lines))
+	if discount <= 0 || grossTotal <= 0 {
+		return shares
+	}
+	if discount > grossTotal {
+		discount = grossTotal
+	}
+
+	type remainder struct {
+		index int
+		value *big.Int
+	}
+
+	total := big.NewInt(grossTotal)
+	remainders := make([]remainder, 0, len(lines))
+	var allocated int64
+	for i, line := range lines {
+		if line.Gross.Amount <= 0 {
+			continue
+		}
+		product := new(big.Int).Mul(big.NewInt(discount), big.NewInt(line.Gross.Amount))
+		quotient, rest := new(big.Int).QuoRem(product, total, new(big.Int))
+		shares[i] = quotient.Int64()
+		allocated += shares[i]
+		remainders = append(remainders, remainder{index: i, value: rest})
+	}
+
+	sort.SliceStable(remainders, func(a, b int) bool {
+		return remainders[a].value.Cmp(remainders[b].value) > 0
+	})
+	for i := 0; allocated < discount && i < len(remainders); i++ {
+		shares[remainders[i].index]++
+		allocated++
+	}
+
+	return shares
+}
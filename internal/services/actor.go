@@ -0,0 +1,26 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"delivery-system/internal/actor"
+)
+
+// setActorWithTx передает исполнителя из контекста в транзакцию (app.actor), откуда его берет
+// триггер журнала статусов. Значение действует до конца транзакции.
+func setActorWithTx(ctx context.Context, tx *sql.Tx) error {
+	if _, err := tx.ExecContext(ctx, "SELECT set_config('app.actor', $1, true)", actor.FromContext(ctx).String()); err != nil {
+		return fmt.Errorf("failed to set audit actor: %w", err)
+	}
+	return nil
+}
+
+// setReasonWithTx передает причину изменения в транзакцию (app.reason); триггер пишет ее в журнал статусов.
+func setReasonWithTx(ctx context.Context, tx *sql.Tx, reason string) error {
+	if _, err := tx.ExecContext(ctx, "SELECT set_config('app.reason', $1, true)", reason); err != nil {
+		return fmt.Errorf("failed to set audit reason: %w", err)
+	}
+	return nil
+}
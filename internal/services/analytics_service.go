@@ -3,13 +3,20 @@ package services
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	"delivery-system/internal/apperror"
 	"delivery-system/internal/config"
 	"delivery-system/internal/database"
 	"delivery-system/internal/logger"
 	"delivery-system/internal/models"
+	"delivery-system/internal/money"
 	"delivery-system/internal/redis"
+
+	"github.com/lib/pq"
 )
 
 const (
@@ -27,14 +34,23 @@ type AnalyticsService struct {
 	defaultTopItems int
 	defaultCouriers int
 	defaultGroupBy  models.AnalyticsGroupBy
+	pricing         *PricingService
+	reportingCur    string
+	rates           map[string]float64
 }
 
 // NewAnalyticsService создает новый сервис аналитики.
-func NewAnalyticsService(db *database.DB, redisClient *redis.Client, log *logger.Logger, cfg *config.AnalyticsConfig) *AnalyticsService {
+// pricing нужен для фильтра по региону (валюта и часовой пояс); может быть nil.
+func NewAnalyticsService(db *database.DB, redisClient *redis.Client, log *logger.Logger, cfg *config.AnalyticsConfig, pricing *PricingService) *AnalyticsService {
 	cacheTTL := defaultCacheTTL
 	defaultTop := DefaultTopItemsLimit
 	defaultCouriers := DefaultCourierLimit
 	groupBy := models.AnalyticsGroupNone
+	reportingCur := money.DefaultCurrency
+	if pricing != nil {
+		reportingCur = pricing.Currency()
+	}
+	var rates map[string]float64
 
 	if cfg != nil {
 		if cfg.CacheTTLMinutes > 0 {
@@ -50,6 +66,10 @@ func NewAnalyticsService(db *database.DB, redisClient *redis.Client, log *logger
 		case models.AnalyticsGroupDay, models.AnalyticsGroupWeek, models.AnalyticsGroupMonth, models.AnalyticsGroupNone:
 			groupBy = models.AnalyticsGroupBy(cfg.DefaultGroupBy)
 		}
+		if cfg.ReportingCurrency != "" {
+			reportingCur = cfg.ReportingCurrency
+		}
+		rates = cfg.ConversionRates
 	}
 
 	return &AnalyticsService{
@@ -60,6 +80,9 @@ func NewAnalyticsService(db *database.DB, redisClient *redis.Client, log *logger
 		defaultTopItems: defaultTop,
 		defaultCouriers: defaultCouriers,
 		defaultGroupBy:  groupBy,
+		pricing:         pricing,
+		reportingCur:    reportingCur,
+		rates:           rates,
 	}
 }
 
@@ -73,17 +96,22 @@ func (s *AnalyticsService) GetKPIs(ctx context.Context, filter *models.Analytics
 		return &cached, nil
 	}
 
-	summary, err := s.fetchKPISummary(ctx, filter)
+	scope, err := s.resolveScope(ctx, filter)
 	if err != nil {
 		return nil, err
 	}
 
-	periods, err := s.fetchKPIPeriods(ctx, filter)
+	summary, err := s.fetchKPISummary(ctx, filter, scope)
 	if err != nil {
 		return nil, err
 	}
 
-	topItems, err := s.fetchTopItems(ctx, filter)
+	periods, err := s.fetchKPIPeriods(ctx, filter, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	topItems, err := s.fetchTopItems(ctx, filter, scope)
 	if err != nil {
 		return nil, err
 	}
@@ -91,6 +119,9 @@ func (s *AnalyticsService) GetKPIs(ctx context.Context, filter *models.Analytics
 	result := &models.KPIMetrics{
 		From:                   filter.From,
 		To:                     filter.To,
+		Currency:               scope.currency,
+		Region:                 scope.region,
+		ConversionRates:        scope.rates,
 		Revenue:                summary.Revenue,
 		OrdersCount:            summary.OrdersCount,
 		AvgDeliveryTimeMinutes: summary.AvgDeliveryTimeMinutes,
@@ -105,7 +136,7 @@ func (s *AnalyticsService) GetKPIs(ctx context.Context, filter *models.Analytics
 	return result, nil
 }
 
-// GetCourierAnalytics возвращает метрики по курьерам (доставки, выручка, рейтинг).
+// GetCourierAnalytics возвращает метрики по курьерам (доставки, выручка, рейтинг, частоты тегов отзывов).
 func (s *AnalyticsService) GetCourierAnalytics(ctx context.Context, filter *models.AnalyticsFilter) ([]*models.CourierAnalytics, error) {
 	filter = s.normalizeFilter(filter)
 	cacheKey := s.buildCacheKey("couriers", filter)
@@ -115,25 +146,30 @@ func (s *AnalyticsService) GetCourierAnalytics(ctx context.Context, filter *mode
 		return cached, nil
 	}
 
-	query := `
+	scope, err := s.resolveScope(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	conditions, args := scope.conditions([]interface{}{filter.From, filter.To})
+	query := fmt.Sprintf(`
 		SELECT c.id,
 		       c.name,
 		       c.rating,
 		       COUNT(o.id) AS deliveries,
-		       COALESCE(SUM(o.total_amount), 0) AS revenue,
+		       COALESCE(SUM(%[1]s), 0) AS revenue,
 		       COALESCE(AVG(EXTRACT(EPOCH FROM (o.delivered_at - o.created_at)) / 60), 0) AS avg_delivery_minutes
 		FROM couriers c
 		LEFT JOIN orders o ON o.courier_id = c.id 
 			AND o.status = 'delivered'
-			AND o.delivered_at BETWEEN $1 AND $2
+			AND o.delivered_at BETWEEN $1 AND $2%[2]s
 	GROUP BY c.id, c.name, c.rating
 	ORDER BY deliveries DESC, revenue DESC, c.rating DESC, c.name ASC
-	`
+	`, scope.amount("o.total_amount"), conditions)
 
-	args := []interface{}{filter.From, filter.To}
 	if filter.CourierLimit > 0 {
-		query += " LIMIT $3"
 		args = append(args, filter.CourierLimit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
 	}
 
 	rows, err := s.db.QueryContext(ctx, query, args...)
@@ -144,7 +180,7 @@ func (s *AnalyticsService) GetCourierAnalytics(ctx context.Context, filter *mode
 
 	var result []*models.CourierAnalytics
 	for rows.Next() {
-		item := &models.CourierAnalytics{}
+		item := &models.CourierAnalytics{Revenue: money.Zero(scope.currency), Currency: scope.currency}
 		if err := rows.Scan(&item.CourierID, &item.CourierName, &item.Rating, &item.Deliveries, &item.Revenue, &item.AvgDeliveryTimeMinutes); err != nil {
 			return nil, fmt.Errorf("failed to scan courier analytics: %w", err)
 		}
@@ -155,29 +191,173 @@ func (s *AnalyticsService) GetCourierAnalytics(ctx context.Context, filter *mode
 		return nil, fmt.Errorf("failed to iterate courier analytics: %w", err)
 	}
 
+	if len(result) > 0 {
+		ids := make([]string, 0, len(result))
+		for _, item := range result {
+			ids = append(ids, item.CourierID.String())
+		}
+		conditions, args := scope.conditions([]interface{}{filter.From, filter.To})
+		tags, err := s.fetchReviewTags(ctx, "r.courier_id::text", conditions, args, ids)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range result {
+			item.Tags = tags[item.CourierID.String()]
+		}
+	}
+
+	s.saveToCache(ctx, cacheKey, result)
+	return result, nil
+}
+
+// GetMerchantAnalytics возвращает по заведениям число отзывов, среднюю оценку заведения и частоты тегов.
+// Учитываются нескрытые отзывы по заказам, доставленным за период; лимит берется из CourierLimit.
+// Выручка здесь не считается, поэтому заказы в разных валютах складываются без пересчета.
+func (s *AnalyticsService) GetMerchantAnalytics(ctx context.Context, filter *models.AnalyticsFilter) ([]*models.MerchantAnalytics, error) {
+	filter = s.normalizeFilter(filter)
+	cacheKey := s.buildCacheKey("merchants", filter)
+
+	var cached []*models.MerchantAnalytics
+	if s.tryGetFromCache(ctx, cacheKey, &cached) {
+		return cached, nil
+	}
+
+	conditions, args := orderFilterConditions(filter, []interface{}{filter.From, filter.To})
+	query := fmt.Sprintf(`
+		SELECT o.merchant_name,
+		       COUNT(r.id) AS reviews,
+		       COUNT(r.merchant_rating) AS rated_reviews,
+		       COALESCE(AVG(r.merchant_rating), 0) AS merchant_rating
+		FROM reviews r
+		JOIN orders o ON o.id = r.order_id
+	WHERE r.status <> 'hidden' AND o.merchant_name IS NOT NULL
+		AND o.status = 'delivered' AND o.delivered_at BETWEEN $1 AND $2%[1]s
+	GROUP BY o.merchant_name
+	ORDER BY reviews DESC, o.merchant_name ASC
+	`, conditions)
+
+	limitArgs := args
+	if filter.CourierLimit > 0 {
+		limitArgs = append(append([]interface{}{}, args...), filter.CourierLimit)
+		query += fmt.Sprintf(" LIMIT $%d", len(limitArgs))
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, limitArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load merchant analytics: %w", err)
+	}
+	defer rows.Close()
+
+	var result []*models.MerchantAnalytics
+	for rows.Next() {
+		item := &models.MerchantAnalytics{}
+		if err := rows.Scan(&item.MerchantName, &item.Reviews, &item.RatedReviews, &item.Rating); err != nil {
+			return nil, fmt.Errorf("failed to scan merchant analytics: %w", err)
+		}
+		result = append(result, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate merchant analytics: %w", err)
+	}
+
+	if len(result) > 0 {
+		names := make([]string, 0, len(result))
+		for _, item := range result {
+			names = append(names, item.MerchantName)
+		}
+		tags, err := s.fetchReviewTags(ctx, "o.merchant_name", conditions, args, names)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range result {
+			item.Tags = tags[item.MerchantName]
+		}
+	}
+
 	s.saveToCache(ctx, cacheKey, result)
 	return result, nil
 }
 
+// fetchReviewTags считает теги нескрытых отзывов по заказам, доставленным за период, в разрезе subject
+// (SQL-выражение курьера или заведения). conditions и args — фильтры заказов (alias o) с периодом в $1 и $2.
+func (s *AnalyticsService) fetchReviewTags(ctx context.Context, subject, conditions string, args []interface{}, keys []string) (map[string]map[models.ReviewTag]int, error) {
+	args = append(append([]interface{}{}, args...), pq.Array(keys))
+	query := fmt.Sprintf(`
+		SELECT %[1]s AS subject, t.tag, COUNT(*) AS mentions
+		FROM reviews r
+		JOIN orders o ON o.id = r.order_id
+		CROSS JOIN LATERAL unnest(r.tags) AS t(tag)
+	WHERE r.status <> 'hidden'
+		AND o.status = 'delivered' AND o.delivered_at BETWEEN $1 AND $2%[2]s
+		AND %[1]s = ANY($%[3]d)
+	GROUP BY 1, 2
+	`, subject, conditions, len(args))
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load review tags: %w", err)
+	}
+	defer rows.Close()
+
+	result := make(map[string]map[models.ReviewTag]int)
+	for rows.Next() {
+		var (
+			key   string
+			tag   models.ReviewTag
+			count int
+		)
+		if err := rows.Scan(&key, &tag, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan review tag: %w", err)
+		}
+		if result[key] == nil {
+			result[key] = make(map[models.ReviewTag]int)
+		}
+		result[key][tag] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate review tags: %w", err)
+	}
+
+	return result, nil
+}
+
+// orderFilterConditions возвращает фильтры запроса по региону и валюте без пересчета сумм:
+// для метрик без выручки заказы в разных валютах можно считать вместе.
+func orderFilterConditions(filter *models.AnalyticsFilter, args []interface{}) (string, []interface{}) {
+	var b strings.Builder
+	if filter.Currency != "" {
+		args = append(args, filter.Currency)
+		fmt.Fprintf(&b, " AND o.currency = $%d", len(args))
+	}
+	if filter.Region != "" {
+		args = append(args, filter.Region)
+		fmt.Fprintf(&b, " AND o.region_code = $%d", len(args))
+	}
+	return b.String(), args
+}
+
 type kpiSummary struct {
-	Revenue                float64
+	Revenue                money.Money
 	OrdersCount            int
 	AvgDeliveryTimeMinutes float64
-	AverageCheck           float64
+	AverageCheck           money.Money
 }
 
-func (s *AnalyticsService) fetchKPISummary(ctx context.Context, filter *models.AnalyticsFilter) (*kpiSummary, error) {
-	query := `
-		SELECT COALESCE(SUM(total_amount), 0) AS revenue,
+func (s *AnalyticsService) fetchKPISummary(ctx context.Context, filter *models.AnalyticsFilter, scope *revenueScope) (*kpiSummary, error) {
+	conditions, args := scope.conditions([]interface{}{filter.From, filter.To})
+	query := fmt.Sprintf(`
+		SELECT COALESCE(SUM(%[1]s), 0) AS revenue,
 		       COUNT(*) AS orders_count,
-		       COALESCE(AVG(EXTRACT(EPOCH FROM (delivered_at - created_at)) / 60), 0) AS avg_delivery_minutes,
-		       COALESCE(AVG(total_amount), 0) AS average_check
-	FROM orders
-	WHERE status = 'delivered' AND delivered_at BETWEEN $1 AND $2
-	`
-
-	row := s.db.QueryRowContext(ctx, query, filter.From, filter.To)
-	summary := &kpiSummary{}
+		       COALESCE(AVG(EXTRACT(EPOCH FROM (o.delivered_at - o.created_at)) / 60), 0) AS avg_delivery_minutes,
+		       COALESCE(AVG(%[1]s), 0) AS average_check
+	FROM orders o
+	WHERE o.status = 'delivered' AND o.delivered_at BETWEEN $1 AND $2%[2]s
+	`, scope.amount("o.total_amount"), conditions)
+
+	row := s.db.QueryRowContext(ctx, query, args...)
+	summary := &kpiSummary{Revenue: money.Zero(scope.currency), AverageCheck: money.Zero(scope.currency)}
 	if err := row.Scan(&summary.Revenue, &summary.OrdersCount, &summary.AvgDeliveryTimeMinutes, &summary.AverageCheck); err != nil {
 		return nil, fmt.Errorf("failed to load KPI summary: %w", err)
 	}
@@ -185,31 +365,38 @@ func (s *AnalyticsService) fetchKPISummary(ctx context.Context, filter *models.A
 	return summary, nil
 }
 
-func (s *AnalyticsService) fetchKPIPeriods(ctx context.Context, filter *models.AnalyticsFilter) ([]KPIPeriod, error) {
+func (s *AnalyticsService) fetchKPIPeriods(ctx context.Context, filter *models.AnalyticsFilter, scope *revenueScope) ([]KPIPeriod, error) {
 	if filter.GroupBy == models.AnalyticsGroupNone || !filter.IncludePeriods {
 		return nil, nil
 	}
 
-	periodExpr := "date_trunc('day', delivered_at)"
+	// Для отчета по региону периоды считаются в его часовом поясе
+	deliveredAt := "o.delivered_at"
+	if scope.location != nil {
+		deliveredAt = fmt.Sprintf("o.delivered_at AT TIME ZONE %s", pq.QuoteLiteral(scope.location.String()))
+	}
+
+	periodExpr := fmt.Sprintf("date_trunc('day', %s)", deliveredAt)
 	switch filter.GroupBy {
 	case models.AnalyticsGroupWeek:
-		periodExpr = "date_trunc('week', delivered_at)"
+		periodExpr = fmt.Sprintf("date_trunc('week', %s)", deliveredAt)
 	case models.AnalyticsGroupMonth:
-		periodExpr = "date_trunc('month', delivered_at)"
+		periodExpr = fmt.Sprintf("date_trunc('month', %s)", deliveredAt)
 	}
 
+	conditions, args := scope.conditions([]interface{}{filter.From, filter.To})
 	query := fmt.Sprintf(`
 		SELECT %[1]s AS period,
-		       COALESCE(SUM(total_amount), 0) AS revenue,
+		       COALESCE(SUM(%[2]s), 0) AS revenue,
 		       COUNT(*) AS orders_count,
-		       COALESCE(AVG(EXTRACT(EPOCH FROM (delivered_at - created_at)) / 60), 0) AS avg_delivery_minutes
-	FROM orders
-	WHERE status = 'delivered' AND delivered_at BETWEEN $1 AND $2
+		       COALESCE(AVG(EXTRACT(EPOCH FROM (o.delivered_at - o.created_at)) / 60), 0) AS avg_delivery_minutes
+	FROM orders o
+	WHERE o.status = 'delivered' AND o.delivered_at BETWEEN $1 AND $2%[3]s
 	GROUP BY period
 	ORDER BY period ASC
-	`, periodExpr)
+	`, periodExpr, scope.amount("o.total_amount"), conditions)
 
-	rows, err := s.db.QueryContext(ctx, query, filter.From, filter.To)
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load KPI periods: %w", err)
 	}
@@ -219,7 +406,7 @@ func (s *AnalyticsService) fetchKPIPeriods(ctx context.Context, filter *models.A
 	for rows.Next() {
 		var (
 			periodTime time.Time
-			item       KPIPeriod
+			item       = KPIPeriod{Revenue: money.Zero(scope.currency)}
 		)
 		if err := rows.Scan(&periodTime, &item.Revenue, &item.OrdersCount, &item.AvgDeliveryTimeMinutes); err != nil {
 			return nil, fmt.Errorf("failed to scan KPI period: %w", err)
@@ -235,20 +422,22 @@ func (s *AnalyticsService) fetchKPIPeriods(ctx context.Context, filter *models.A
 	return result, nil
 }
 
-func (s *AnalyticsService) fetchTopItems(ctx context.Context, filter *models.AnalyticsFilter) ([]models.TopItem, error) {
-	query := `
+func (s *AnalyticsService) fetchTopItems(ctx context.Context, filter *models.AnalyticsFilter, scope *revenueScope) ([]models.TopItem, error) {
+	conditions, args := scope.conditions([]interface{}{filter.From, filter.To})
+	args = append(args, filter.TopItemsLimit)
+	query := fmt.Sprintf(`
 		SELECT oi.name,
 		       COALESCE(SUM(oi.quantity), 0) AS total_quantity,
-		       COALESCE(SUM(oi.price * oi.quantity), 0) AS revenue
+		       COALESCE(SUM(%[1]s), 0) AS revenue
 	FROM order_items oi
 	JOIN orders o ON o.id = oi.order_id
-	WHERE o.status = 'delivered' AND o.delivered_at BETWEEN $1 AND $2
+	WHERE o.status = 'delivered' AND o.delivered_at BETWEEN $1 AND $2%[2]s
 	GROUP BY oi.name
 	ORDER BY total_quantity DESC, revenue DESC, oi.name ASC
-	LIMIT $3
-	`
+	LIMIT $%[3]d
+	`, scope.amount("oi.price * oi.quantity"), conditions, len(args))
 
-	rows, err := s.db.QueryContext(ctx, query, filter.From, filter.To, filter.TopItemsLimit)
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load top items: %w", err)
 	}
@@ -256,7 +445,7 @@ func (s *AnalyticsService) fetchTopItems(ctx context.Context, filter *models.Ana
 
 	var result []models.TopItem
 	for rows.Next() {
-		var item models.TopItem
+		item := models.TopItem{Revenue: money.Zero(scope.currency)}
 		if err := rows.Scan(&item.Name, &item.Quantity, &item.Revenue); err != nil {
 			return nil, fmt.Errorf("failed to scan top item: %w", err)
 		}
@@ -270,12 +459,153 @@ func (s *AnalyticsService) fetchTopItems(ctx context.Context, filter *models.Ana
 	return result, nil
 }
 
+// revenueScope задает валюту выручки и фильтр по региону.
+// Суммы разных валют складываются только при явно заданных курсах пересчета.
+type revenueScope struct {
+	currency string
+	region   string
+	location *time.Location     // часовой пояс региона для периодов, nil — часовой пояс БД
+	rates    map[string]float64 // курсы к currency; nil — выборка только в одной валюте
+}
+
+// resolveScope определяет валюту отчета: явно заданную, валюту региона или единственную валюту заказов периода.
+// Если заказы периода в нескольких валютах, выручка пересчитывается по курсам, а без курсов запрос отклоняется.
+func (s *AnalyticsService) resolveScope(ctx context.Context, filter *models.AnalyticsFilter) (*revenueScope, error) {
+	scope := &revenueScope{currency: filter.Currency, region: filter.Region}
+	if filter.Region != "" && s.pricing != nil {
+		tariff, ok := s.pricing.Tariff(filter.Region)
+		if !ok {
+			return nil, apperror.Validation("unknown region", nil)
+		}
+		scope.location = tariff.Location
+		if scope.currency == "" {
+			scope.currency = tariff.Currency()
+		}
+	}
+	if scope.currency != "" {
+		return scope, nil
+	}
+
+	currencies, err := s.fetchCurrencies(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(currencies) {
+	case 0:
+		scope.currency = s.reportingCur
+		return scope, nil
+	case 1:
+		scope.currency = currencies[0]
+		return scope, nil
+	}
+
+	rates := make(map[string]float64, len(currencies))
+	var missing []string
+	for _, currency := range currencies {
+		switch {
+		case currency == s.reportingCur:
+			rates[currency] = 1
+		case s.rates[currency] > 0:
+			rates[currency] = s.rates[currency]
+		default:
+			missing = append(missing, currency)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, apperror.Validation(fmt.Sprintf(
+			"orders span several currencies (%s) and no conversion rate to %s is configured for %s; filter by currency or region",
+			strings.Join(currencies, ", "), s.reportingCur, strings.Join(missing, ", ")), nil)
+	}
+
+	scope.currency = s.reportingCur
+	scope.rates = rates
+	return scope, nil
+}
+
+func (s *AnalyticsService) fetchCurrencies(ctx context.Context, filter *models.AnalyticsFilter) ([]string, error) {
+	query := `
+		SELECT DISTINCT o.currency
+	FROM orders o
+	WHERE o.status = 'delivered' AND o.delivered_at BETWEEN $1 AND $2
+	`
+	args := []interface{}{filter.From, filter.To}
+	if filter.Region != "" {
+		query += " AND o.region_code = $3"
+		args = append(args, filter.Region)
+	}
+	query += " ORDER BY o.currency"
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load order currencies: %w", err)
+	}
+	defer rows.Close()
+
+	var currencies []string
+	for rows.Next() {
+		var currency string
+		if err := rows.Scan(&currency); err != nil {
+			return nil, fmt.Errorf("failed to scan order currency: %w", err)
+		}
+		currencies = append(currencies, currency)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate order currencies: %w", err)
+	}
+
+	return currencies, nil
+}
+
+// conditions возвращает фильтры по валюте и региону для заказов (alias o) и дополненный список параметров.
+func (sc *revenueScope) conditions(args []interface{}) (string, []interface{}) {
+	var b strings.Builder
+	if sc.rates == nil {
+		args = append(args, sc.currency)
+		fmt.Fprintf(&b, " AND o.currency = $%d", len(args))
+	} else {
+		args = append(args, pq.Array(sc.rateCurrencies()))
+		fmt.Fprintf(&b, " AND o.currency = ANY($%d)", len(args))
+	}
+	if sc.region != "" {
+		args = append(args, sc.region)
+		fmt.Fprintf(&b, " AND o.region_code = $%d", len(args))
+	}
+	return b.String(), args
+}
+
+// amount возвращает SQL-выражение суммы в валюте отчета; при пересчете сумма умножается на курс валюты заказа.
+// Курсы берутся из конфигурации, а не из запроса, поэтому подставляются литералами.
+func (sc *revenueScope) amount(expr string) string {
+	if sc.rates == nil {
+		return expr
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "(%s) * CASE o.currency", expr)
+	for _, currency := range sc.rateCurrencies() {
+		fmt.Fprintf(&b, " WHEN %s THEN %s", pq.QuoteLiteral(currency), strconv.FormatFloat(sc.rates[currency], 'f', -1, 64))
+	}
+	b.WriteString(" END")
+	return b.String()
+}
+
+func (sc *revenueScope) rateCurrencies() []string {
+	currencies := make([]string, 0, len(sc.rates))
+	for currency := range sc.rates {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	return currencies
+}
+
 // KPIPeriod описывает агрегированные метрики по выбранному интервалу.
 type KPIPeriod = models.KPIPeriod
 
 func (s *AnalyticsService) buildCacheKey(kind string, filter *models.AnalyticsFilter) string {
 	return redis.GenerateKey(redis.KeyPrefixStats, fmt.Sprintf(
-		"%s:%s:%s:%s:%d:%d:%t",
+		"%s:%s:%s:%s:%d:%d:%t:%s:%s",
 		kind,
 		filter.From.Format("2006-01-02"),
 		filter.To.Format("2006-01-02"),
@@ -283,6 +613,8 @@ func (s *AnalyticsService) buildCacheKey(kind string, filter *models.AnalyticsFi
 		filter.TopItemsLimit,
 		filter.CourierLimit,
 		filter.IncludePeriods,
+		filter.Region,
+		filter.Currency,
 	))
 }
 
@@ -5,6 +5,7 @@ import (
 	"testing"
 	"time"
 
+	"delivery-system/internal/apperror"
 	"delivery-system/internal/config"
 	"delivery-system/internal/models"
 	"delivery-system/internal/redis"
@@ -19,7 +20,7 @@ func TestAnalyticsService_GetKPIs_Success(t *testing.T) {
 	defer db.Close()
 
 	log := newTestLogger()
-	service := NewAnalyticsService(db, nil, log, nil)
+	service := NewAnalyticsService(db, nil, log, nil, nil)
 
 	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
 	to := time.Date(2024, 1, 31, 23, 59, 59, 0, time.UTC)
@@ -30,19 +31,23 @@ func TestAnalyticsService_GetKPIs_Success(t *testing.T) {
 		TopItemsLimit: 3,
 	}
 
-	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(total_amount\\), 0\\) AS revenue").
+	mock.ExpectQuery("SELECT DISTINCT o.currency").
 		WithArgs(from, to).
+		WillReturnRows(sqlmock.NewRows([]string{"currency"}).AddRow("RUB"))
+
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(o.total_amount\\), 0\\) AS revenue").
+		WithArgs(from, to, "RUB").
 		WillReturnRows(sqlmock.NewRows([]string{"revenue", "orders_count", "avg_delivery_minutes", "average_check"}).
 			AddRow(1250.50, 10, 42.0, 125.05))
 
-	mock.ExpectQuery("SELECT date_trunc\\('day', delivered_at\\) AS period").
-		WithArgs(from, to).
+	mock.ExpectQuery("SELECT date_trunc\\('day', o.delivered_at\\) AS period").
+		WithArgs(from, to, "RUB").
 		WillReturnRows(sqlmock.NewRows([]string{"period", "revenue", "orders_count", "avg_delivery_minutes"}).
 			AddRow(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), 300.0, 3, 40.0).
 			AddRow(time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), 950.5, 7, 43.0))
 
 	mock.ExpectQuery("SELECT oi.name").
-		WithArgs(from, to, 3).
+		WithArgs(from, to, "RUB", 3).
 		WillReturnRows(sqlmock.NewRows([]string{"name", "total_quantity", "revenue"}).
 			AddRow("Pizza", 15, 800.0).
 			AddRow("Soda", 10, 200.0))
@@ -52,7 +57,7 @@ func TestAnalyticsService_GetKPIs_Success(t *testing.T) {
 		t.Fatalf("expected success, got error: %v", err)
 	}
 
-	if metrics.Revenue != 1250.50 || metrics.OrdersCount != 10 {
+	if metrics.Revenue.String() != "1250.50" || metrics.OrdersCount != 10 || metrics.Currency != "RUB" {
 		t.Fatalf("unexpected metrics summary: %+v", metrics)
 	}
 
@@ -74,7 +79,7 @@ func TestAnalyticsService_GetCourierAnalytics_Success(t *testing.T) {
 	defer db.Close()
 
 	log := newTestLogger()
-	service := NewAnalyticsService(db, nil, log, nil)
+	service := NewAnalyticsService(db, nil, log, nil, nil)
 
 	from := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
 	to := time.Date(2024, 2, 7, 23, 59, 59, 0, time.UTC)
@@ -86,11 +91,21 @@ func TestAnalyticsService_GetCourierAnalytics_Success(t *testing.T) {
 
 	courierID := uuid.New()
 
+	mock.ExpectQuery("SELECT DISTINCT o.currency").
+		WithArgs(from, to).
+		WillReturnRows(sqlmock.NewRows([]string{"currency"}).AddRow("EUR"))
+
 	mock.ExpectQuery("SELECT c.id").
-		WithArgs(from, to, filter.CourierLimit).
+		WithArgs(from, to, "EUR", filter.CourierLimit).
 		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "rating", "deliveries", "revenue", "avg_delivery_minutes"}).
 			AddRow(courierID, "John", 4.8, 12, 1500.0, 38.5))
 
+	mock.ExpectQuery("SELECT r.courier_id::text AS subject, t.tag").
+		WithArgs(from, to, "EUR", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"subject", "tag", "mentions"}).
+			AddRow(courierID.String(), "late", 3).
+			AddRow(courierID.String(), "great_service", 5))
+
 	metrics, err := service.GetCourierAnalytics(context.Background(), filter)
 	if err != nil {
 		t.Fatalf("expected success, got error: %v", err)
@@ -100,10 +115,158 @@ func TestAnalyticsService_GetCourierAnalytics_Success(t *testing.T) {
 		t.Fatalf("expected 1 courier, got %d", len(metrics))
 	}
 
-	if metrics[0].CourierID != courierID || metrics[0].Deliveries != 12 {
+	if metrics[0].CourierID != courierID || metrics[0].Deliveries != 12 || metrics[0].Revenue.Currency != "EUR" {
 		t.Fatalf("unexpected courier metrics: %+v", metrics[0])
 	}
 
+	if metrics[0].Tags[models.ReviewTagLate] != 3 || metrics[0].Tags[models.ReviewTagGreatService] != 5 {
+		t.Fatalf("unexpected courier tags: %+v", metrics[0].Tags)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestAnalyticsService_GetMerchantAnalytics(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewAnalyticsService(db, nil, newTestLogger(), nil, nil)
+
+	from := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 2, 7, 23, 59, 59, 0, time.UTC)
+	filter := &models.AnalyticsFilter{From: from, To: to, CourierLimit: 10, Region: "ru-msk"}
+
+	// Без выручки валюты заказов не сверяются: сразу идет выборка по заведениям
+	mock.ExpectQuery("SELECT o.merchant_name").
+		WithArgs(from, to, "ru-msk", 10).
+		WillReturnRows(sqlmock.NewRows([]string{"merchant_name", "reviews", "rated_reviews", "merchant_rating"}).
+			AddRow("Pizzeria", 4, 3, 3.67).
+			AddRow("Sushi Bar", 1, 0, 0.0))
+
+	mock.ExpectQuery("SELECT o.merchant_name AS subject, t.tag").
+		WithArgs(from, to, "ru-msk", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"subject", "tag", "mentions"}).
+			AddRow("Pizzeria", "cold_food", 2))
+
+	metrics, err := service.GetMerchantAnalytics(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+
+	if len(metrics) != 2 || metrics[0].MerchantName != "Pizzeria" || metrics[0].RatedReviews != 3 {
+		t.Fatalf("unexpected merchant metrics: %+v", metrics)
+	}
+	if metrics[0].Tags[models.ReviewTagColdFood] != 2 || metrics[1].Tags != nil {
+		t.Fatalf("unexpected merchant tags: %+v %+v", metrics[0].Tags, metrics[1].Tags)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestAnalyticsService_GetKPIs_MultipleCurrenciesWithoutRates(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewAnalyticsService(db, nil, newTestLogger(), &config.AnalyticsConfig{ReportingCurrency: "RUB"}, nil)
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 1, 31, 23, 59, 59, 0, time.UTC)
+
+	mock.ExpectQuery("SELECT DISTINCT o.currency").
+		WithArgs(from, to).
+		WillReturnRows(sqlmock.NewRows([]string{"currency"}).AddRow("EUR").AddRow("RUB"))
+
+	_, err := service.GetKPIs(context.Background(), &models.AnalyticsFilter{From: from, To: to, GroupBy: models.AnalyticsGroupNone})
+	if !apperror.Is(err, apperror.KindValidation) {
+		t.Fatalf("expected validation error for mixed currencies, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestAnalyticsService_GetKPIs_ConvertsWithExplicitRates(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	cfg := &config.AnalyticsConfig{ReportingCurrency: "RUB", ConversionRates: map[string]float64{"EUR": 98.5}}
+	service := NewAnalyticsService(db, nil, newTestLogger(), cfg, nil)
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 1, 31, 23, 59, 59, 0, time.UTC)
+
+	mock.ExpectQuery("SELECT DISTINCT o.currency").
+		WithArgs(from, to).
+		WillReturnRows(sqlmock.NewRows([]string{"currency"}).AddRow("EUR").AddRow("RUB"))
+
+	mock.ExpectQuery("SUM\\(\\(o.total_amount\\) \\* CASE o.currency WHEN 'EUR' THEN 98.5 WHEN 'RUB' THEN 1 END\\)").
+		WithArgs(from, to, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"revenue", "orders_count", "avg_delivery_minutes", "average_check"}).
+			AddRow("1985.00", 2, 30.0, "992.50"))
+
+	mock.ExpectQuery("SELECT oi.name").
+		WithArgs(from, to, sqlmock.AnyArg(), DefaultTopItemsLimit).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "total_quantity", "revenue"}))
+
+	metrics, err := service.GetKPIs(context.Background(), &models.AnalyticsFilter{From: from, To: to, GroupBy: models.AnalyticsGroupNone})
+	if err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+	if metrics.Currency != "RUB" || metrics.Revenue.Display() != "1985.00 RUB" || metrics.ConversionRates["EUR"] != 98.5 {
+		t.Fatalf("unexpected converted metrics: %+v", metrics)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestAnalyticsService_GetKPIs_RegionUsesCurrencyAndTimezone(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	pricing, err := NewRegionalPricingService(models.Region{Currency: "RUB"}, []models.Region{{
+		Code:     "de-berlin",
+		Currency: "EUR",
+		Timezone: "Europe/Berlin",
+		Bounds:   &models.GeoBounds{MinLat: 52.3, MinLon: 13.0, MaxLat: 52.7, MaxLon: 13.8},
+		Payout:   &models.RegionPayout{PerDelivery: 3, PerKm: 0.5, MinPayout: 4},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected pricing error: %v", err)
+	}
+	service := NewAnalyticsService(db, nil, newTestLogger(), nil, pricing)
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 1, 31, 23, 59, 59, 0, time.UTC)
+
+	mock.ExpectQuery("SELECT COALESCE\\(SUM\\(o.total_amount\\), 0\\) AS revenue").
+		WithArgs(from, to, "EUR", "de-berlin").
+		WillReturnRows(sqlmock.NewRows([]string{"revenue", "orders_count", "avg_delivery_minutes", "average_check"}).
+			AddRow("40.00", 1, 25.0, "40.00"))
+
+	mock.ExpectQuery("date_trunc\\('day', o.delivered_at AT TIME ZONE 'Europe/Berlin'\\)").
+		WithArgs(from, to, "EUR", "de-berlin").
+		WillReturnRows(sqlmock.NewRows([]string{"period", "revenue", "orders_count", "avg_delivery_minutes"}))
+
+	mock.ExpectQuery("SELECT oi.name").
+		WithArgs(from, to, "EUR", "de-berlin", DefaultTopItemsLimit).
+		WillReturnRows(sqlmock.NewRows([]string{"name", "total_quantity", "revenue"}))
+
+	metrics, err := service.GetKPIs(context.Background(), &models.AnalyticsFilter{From: from, To: to, GroupBy: models.AnalyticsGroupDay, Region: "de-berlin"})
+	if err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+	if metrics.Currency != "EUR" || metrics.Region != "de-berlin" || metrics.Revenue.Currency != "EUR" {
+		t.Fatalf("unexpected regional metrics: %+v", metrics)
+	}
+
+	if _, err := service.GetKPIs(context.Background(), &models.AnalyticsFilter{From: from, To: to, Region: "unknown"}); !apperror.Is(err, apperror.KindValidation) {
+		t.Fatalf("expected validation error for unknown region, got %v", err)
+	}
+
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Fatalf("unmet expectations: %v", err)
 	}
@@ -114,7 +277,7 @@ func TestAnalyticsService_GetKPIs_FromCache(t *testing.T) {
 	defer mr.Close()
 	rdb, _ := redis.Connect(&config.RedisConfig{Host: "127.0.0.1", Port: mr.Port(), DB: 0}, newTestLogger())
 
-	service := NewAnalyticsService(nil, rdb, newTestLogger(), &config.AnalyticsConfig{DefaultGroupBy: "none"})
+	service := NewAnalyticsService(nil, rdb, newTestLogger(), &config.AnalyticsConfig{DefaultGroupBy: "none"}, nil)
 	filter := &models.AnalyticsFilter{
 		From:           time.Unix(0, 0),
 		To:             time.Unix(0, 0),
@@ -141,7 +304,7 @@ func TestAnalyticsService_SaveToCache(t *testing.T) {
 	defer mr.Close()
 	rdb, _ := redis.Connect(&config.RedisConfig{Host: "127.0.0.1", Port: mr.Port(), DB: 0}, newTestLogger())
 
-	svc := NewAnalyticsService(nil, rdb, newTestLogger(), &config.AnalyticsConfig{CacheTTLMinutes: 1})
+	svc := NewAnalyticsService(nil, rdb, newTestLogger(), &config.AnalyticsConfig{CacheTTLMinutes: 1}, nil)
 	key := "analytics:test"
 	svc.saveToCache(context.Background(), key, map[string]string{"ok": "yes"})
 
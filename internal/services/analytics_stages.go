@@ -0,0 +1,113 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/models"
+)
+
+// stageLifecycle задает порядок этапов в отчете; неизвестные статусы идут в конце.
+var stageLifecycle = []models.OrderStatus{
+	models.OrderStatusCreated,
+	models.OrderStatusAccepted,
+	models.OrderStatusPreparing,
+	models.OrderStatusReady,
+	models.OrderStatusPickedUp,
+	models.OrderStatusInDelivery,
+	models.OrderStatusDeliveryFailed,
+	models.OrderStatusReturning,
+	models.OrderStatusDelivered,
+	models.OrderStatusReturned,
+	models.OrderStatusCancelled,
+}
+
+// GetStageTimings возвращает время пребывания заказов в каждом статусе по журналу order_status_history.
+// Учитываются заказы, созданные за период; длительность этапа — время до следующей записи журнала,
+// поэтому текущий статус незавершенных заказов в отчет не попадает. Записи о смене курьера без смены
+// статуса не разбивают этап.
+func (s *AnalyticsService) GetStageTimings(ctx context.Context, filter *models.AnalyticsFilter) (*models.StageTimings, error) {
+	filter = s.normalizeFilter(filter)
+	cacheKey := s.buildCacheKey("stages", filter)
+
+	var cached models.StageTimings
+	if s.tryGetFromCache(ctx, cacheKey, &cached) {
+		return &cached, nil
+	}
+
+	if filter.Region != "" && s.pricing != nil {
+		if _, ok := s.pricing.Tariff(filter.Region); !ok {
+			return nil, apperror.Validation("unknown region", nil)
+		}
+	}
+
+	args := []interface{}{filter.From, filter.To}
+	regionCondition := ""
+	if filter.Region != "" {
+		args = append(args, filter.Region)
+		regionCondition = fmt.Sprintf(" AND o.region_code = $%d", len(args))
+	}
+
+	query := fmt.Sprintf(`
+		WITH stages AS (
+			SELECT h.new_status AS status,
+			       EXTRACT(EPOCH FROM (LEAD(h.changed_at) OVER (PARTITION BY h.order_id ORDER BY h.changed_at, h.id) - h.changed_at)) / 60 AS minutes
+			FROM order_status_history h
+			JOIN orders o ON o.id = h.order_id
+			WHERE o.created_at BETWEEN $1 AND $2%s
+			  AND h.old_status IS DISTINCT FROM h.new_status
+		)
+		SELECT status,
+		       COUNT(*) AS transitions,
+		       AVG(minutes) AS avg_minutes,
+		       percentile_cont(0.5) WITHIN GROUP (ORDER BY minutes) AS p50_minutes,
+		       percentile_cont(0.9) WITHIN GROUP (ORDER BY minutes) AS p90_minutes,
+		       MAX(minutes) AS max_minutes
+		FROM stages
+		WHERE minutes IS NOT NULL
+		GROUP BY status
+	`, regionCondition)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load stage timings: %w", err)
+	}
+	defer rows.Close()
+
+	result := &models.StageTimings{
+		From:        filter.From,
+		To:          filter.To,
+		Region:      filter.Region,
+		Stages:      []models.StageTiming{},
+		GeneratedAt: time.Now(),
+	}
+	for rows.Next() {
+		var stage models.StageTiming
+		if err := rows.Scan(&stage.Status, &stage.Transitions, &stage.AvgMinutes, &stage.P50Minutes, &stage.P90Minutes, &stage.MaxMinutes); err != nil {
+			return nil, fmt.Errorf("failed to scan stage timing: %w", err)
+		}
+		result.Stages = append(result.Stages, stage)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate stage timings: %w", err)
+	}
+
+	sort.SliceStable(result.Stages, func(i, j int) bool {
+		return stagePosition(result.Stages[i].Status) < stagePosition(result.Stages[j].Status)
+	})
+
+	s.saveToCache(ctx, cacheKey, result)
+	return result, nil
+}
+
+func stagePosition(status models.OrderStatus) int {
+	for i, known := range stageLifecycle {
+		if known == status {
+			return i
+		}
+	}
+	return len(stageLifecycle)
+}
@@ -0,0 +1,50 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"delivery-system/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestAnalyticsService_GetStageTimings(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewAnalyticsService(db, nil, newTestLogger(), nil, nil)
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 1, 31, 23, 59, 59, 0, time.UTC)
+
+	mock.ExpectQuery(`LEAD\(h.changed_at\) OVER \(PARTITION BY h.order_id ORDER BY h.changed_at, h.id\).*o.created_at BETWEEN \$1 AND \$2 AND o.region_code = \$3.*percentile_cont\(0.9\)`).
+		WithArgs(from, to, "msk").
+		WillReturnRows(sqlmock.NewRows([]string{"status", "transitions", "avg_minutes", "p50_minutes", "p90_minutes", "max_minutes"}).
+			AddRow(models.OrderStatusInDelivery, 8, 24.5, 22.0, 35.0, 41.0).
+			AddRow(models.OrderStatusCreated, 10, 2.5, 1.5, 6.0, 9.0).
+			AddRow(models.OrderStatusAccepted, 9, 12.0, 11.0, 18.0, 20.0))
+
+	timings, err := service.GetStageTimings(context.Background(), &models.AnalyticsFilter{From: from, To: to, Region: "msk"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(timings.Stages) != 3 {
+		t.Fatalf("expected 3 stages, got %+v", timings.Stages)
+	}
+	order := []models.OrderStatus{models.OrderStatusCreated, models.OrderStatusAccepted, models.OrderStatusInDelivery}
+	for i, status := range order {
+		if timings.Stages[i].Status != status {
+			t.Fatalf("expected lifecycle order %v, got %+v", order, timings.Stages)
+		}
+	}
+	if timings.Stages[2].P90Minutes != 35 || timings.Stages[0].Transitions != 10 || timings.Region != "msk" {
+		t.Fatalf("unexpected timings: %+v", timings)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,505 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/database"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+	"delivery-system/internal/money"
+	"delivery-system/internal/pagination"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// Параметры генерации кодов кампании.
+const (
+	DefaultCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789" // без похожих I/1 и O/0
+	DefaultCodeLength   = 8
+	MaxGeneratedCodes   = 100000
+
+	minCodeLength        = 4
+	maxCodeLength        = 32
+	maxPromoCodeLength   = 64
+	maxGenerateAttempts  = 5
+	minCodeSpaceHeadroom = 4 // во сколько раз пространство кодов должно превышать запрошенное количество
+)
+
+// CampaignService управляет кампаниями и массовым выпуском промокодов.
+type CampaignService struct {
+	db  *database.DB
+	log *logger.Logger
+}
+
+// NewCampaignService создает сервис кампаний.
+func NewCampaignService(db *database.DB, log *logger.Logger) *CampaignService {
+	return &CampaignService{
+		db:  db,
+		log: log,
+	}
+}
+
+const campaignColumns = `c.id, c.name, c.discount_type, c.amount, c.currency, c.max_uses, c.expires_at, c.active, c.rules, c.stacking, c.created_at, c.updated_at,
+	(SELECT COUNT(*) FROM promo_codes p WHERE p.campaign_id = c.id)`
+
+// CreateCampaign создает кампанию с шаблоном скидки.
+func (s *CampaignService) CreateCampaign(ctx context.Context, req *models.CreateCampaignRequest) (*models.Campaign, error) {
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, apperror.Validation("campaign name is required", nil)
+	}
+	if err := validatePromoCodePayload(req.DiscountType, req.Amount); err != nil {
+		return nil, apperror.Validation(err.Error(), err)
+	}
+	if err := validatePromoRules(req.DiscountType, &req.Rules); err != nil {
+		return nil, apperror.Validation(err.Error(), err)
+	}
+	if req.MaxUses < 0 {
+		return nil, apperror.Validation("max_uses must be non-negative", nil)
+	}
+	stacking, err := normalizePromoStacking(req.Stacking)
+	if err != nil {
+		return nil, apperror.Validation(err.Error(), err)
+	}
+
+	currency := strings.ToUpper(req.Currency)
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
+	if !money.IsCurrencyCode(currency) {
+		return nil, apperror.Validation("currency must be a 3-letter ISO 4217 code", nil)
+	}
+
+	now := time.Now()
+	campaign := &models.Campaign{
+		ID:           uuid.New(),
+		Name:         strings.TrimSpace(req.Name),
+		DiscountType: req.DiscountType,
+		Amount:       req.Amount.In(currency),
+		Currency:     currency,
+		MaxUses:      req.MaxUses,
+		ExpiresAt:    req.ExpiresAt,
+		Active:       true,
+		Rules:        req.Rules,
+		Stacking:     stacking,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	campaign.Rules.ApplyCurrency(currency)
+
+	rules, err := encodePromoRules(campaign.Rules)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO campaigns (id, name, discount_type, amount, currency, max_uses, expires_at, active, rules, stacking, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`
+	if _, err := s.db.ExecContext(ctx, query, campaign.ID, campaign.Name, campaign.DiscountType, campaign.Amount, campaign.Currency,
+		campaign.MaxUses, campaign.ExpiresAt, campaign.Active, rules, campaign.Stacking, campaign.CreatedAt, campaign.UpdatedAt); err != nil {
+		return nil, fmt.Errorf("failed to create campaign: %w", err)
+	}
+
+	s.log.WithField("campaign_id", campaign.ID).WithField("name", campaign.Name).Info("Campaign created")
+	return campaign, nil
+}
+
+// GetCampaign возвращает кампанию с количеством выпущенных кодов.
+func (s *CampaignService) GetCampaign(ctx context.Context, campaignID uuid.UUID) (*models.Campaign, error) {
+	query := `SELECT ` + campaignColumns + ` FROM campaigns c WHERE c.id = $1`
+
+	campaign, err := scanCampaign(s.db.QueryRowContext(ctx, query, campaignID).Scan)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperror.NotFound("campaign not found", err)
+		}
+		return nil, fmt.Errorf("failed to get campaign: %w", err)
+	}
+	return campaign, nil
+}
+
+// ListCampaigns возвращает кампании, новые первыми.
+func (s *CampaignService) ListCampaigns(ctx context.Context, page pagination.Request) (*pagination.Page[*models.Campaign], error) {
+	if err := page.CheckSort(sortCreatedAt); err != nil {
+		return nil, err
+	}
+	if page.Limit <= 0 {
+		page.Limit = 50
+	}
+
+	query := `SELECT ` + campaignColumns + ` FROM campaigns c`
+	args := []interface{}{}
+	if page.After != nil {
+		query += " WHERE " + keysetCondition([]string{"c.created_at", "c.id"}, &args, page.After.CreatedAt, page.After.ID)
+	}
+	query += " ORDER BY c.created_at DESC, c.id DESC" + pageLimitClause(page, &args)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list campaigns: %w", err)
+	}
+	defer rows.Close()
+
+	var campaigns []*models.Campaign
+	for rows.Next() {
+		campaign, err := scanCampaign(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan campaign: %w", err)
+		}
+		campaigns = append(campaigns, campaign)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate campaigns: %w", err)
+	}
+
+	return pagination.NewPage(campaigns, page.Limit, func(c *models.Campaign) pagination.Cursor {
+		return pagination.Cursor{Sort: sortCreatedAt, CreatedAt: c.CreatedAt, ID: c.ID.String()}
+	}), nil
+}
+
+// GenerateCodes выпускает пачку уникальных случайных кодов по шаблону кампании.
+// Коды загружаются через COPY во временную таблицу и переносятся в promo_codes с ON CONFLICT DO NOTHING;
+// коллизии с уже существующими кодами догенерируются.
+func (s *CampaignService) GenerateCodes(ctx context.Context, campaignID uuid.UUID, req *models.GenerateCodesRequest) (*models.GenerateCodesResult, error) {
+	spec, err := newCodeSpec(req)
+	if err != nil {
+		return nil, apperror.Validation(err.Error(), err)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Блокировка кампании не дает выпустить коды параллельно с деактивацией
+	var active bool
+	if err := tx.QueryRowContext(ctx, `SELECT active FROM campaigns WHERE id = $1 FOR UPDATE`, campaignID).Scan(&active); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperror.NotFound("campaign not found", err)
+		}
+		return nil, fmt.Errorf("failed to get campaign: %w", err)
+	}
+	if !active {
+		return nil, apperror.Conflict("campaign is inactive", nil)
+	}
+
+	if _, err := tx.ExecContext(ctx, `CREATE TEMP TABLE promo_code_import (code VARCHAR(64) NOT NULL) ON COMMIT DROP`); err != nil {
+		return nil, fmt.Errorf("failed to create import table: %w", err)
+	}
+
+	insertQuery := `
+		INSERT INTO promo_codes (code, discount_type, amount, currency, max_uses, used_count, expires_at, active, rules, stacking, campaign_id, created_at, updated_at)
+		SELECT i.code, c.discount_type, c.amount, c.currency, c.max_uses, 0, c.expires_at, TRUE, c.rules, c.stacking, c.id, NOW(), NOW()
+		FROM promo_code_import i
+		CROSS JOIN campaigns c
+		WHERE c.id = $1
+		ON CONFLICT (code) DO NOTHING
+	`
+
+	seen := make(map[string]struct{}, req.Count)
+	generated := 0
+	for attempt := 0; attempt < maxGenerateAttempts && generated < req.Count; attempt++ {
+		codes, err := spec.generate(req.Count-generated, seen)
+		if err != nil {
+			return nil, err
+		}
+		if attempt > 0 {
+			if _, err := tx.ExecContext(ctx, `TRUNCATE promo_code_import`); err != nil {
+				return nil, fmt.Errorf("failed to reset import table: %w", err)
+			}
+		}
+		if err := copyPromoCodes(ctx, tx, codes); err != nil {
+			return nil, err
+		}
+
+		result, err := tx.ExecContext(ctx, insertQuery, campaignID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to insert promo codes: %w", err)
+		}
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		generated += int(inserted)
+	}
+	if generated < req.Count {
+		return nil, apperror.Conflict("could not generate enough unique codes; use a longer code or a larger alphabet", nil)
+	}
+
+	var total int
+	if err := tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM promo_codes WHERE campaign_id = $1`, campaignID).Scan(&total); err != nil {
+		return nil, fmt.Errorf("failed to count campaign codes: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit promo codes: %w", err)
+	}
+
+	s.log.WithFields(map[string]interface{}{
+		"campaign_id": campaignID,
+		"generated":   generated,
+		"codes_count": total,
+	}).Info("Campaign promo codes generated")
+
+	return &models.GenerateCodesResult{CampaignID: campaignID, Generated: generated, CodesCount: total}, nil
+}
+
+// ListCampaignCodes возвращает все коды кампании для выгрузки.
+func (s *CampaignService) ListCampaignCodes(ctx context.Context, campaignID uuid.UUID) ([]*models.PromoCode, error) {
+	if _, err := s.GetCampaign(ctx, campaignID); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + promoCodeColumns + ` FROM promo_codes WHERE campaign_id = $1 ORDER BY code`
+	rows, err := s.db.QueryContext(ctx, query, campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list campaign codes: %w", err)
+	}
+	defer rows.Close()
+
+	var codes []*models.PromoCode
+	for rows.Next() {
+		promo, err := scanPromoCode(rows.Scan)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan promo code: %w", err)
+		}
+		codes = append(codes, promo)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate campaign codes: %w", err)
+	}
+
+	return codes, nil
+}
+
+// DeactivateCampaign отключает кампанию и все ее коды.
+func (s *CampaignService) DeactivateCampaign(ctx context.Context, campaignID uuid.UUID) (*models.Campaign, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	now := time.Now()
+	result, err := tx.ExecContext(ctx, `UPDATE campaigns SET active = FALSE, updated_at = $1 WHERE id = $2`, now, campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deactivate campaign: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rows == 0 {
+		return nil, apperror.NotFound("campaign not found", nil)
+	}
+
+	result, err = tx.ExecContext(ctx, `UPDATE promo_codes SET active = FALSE, updated_at = $1 WHERE campaign_id = $2 AND active`, now, campaignID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deactivate campaign codes: %w", err)
+	}
+	deactivated, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit campaign deactivation: %w", err)
+	}
+
+	s.log.WithField("campaign_id", campaignID).WithField("codes", deactivated).Info("Campaign deactivated")
+	return s.GetCampaign(ctx, campaignID)
+}
+
+// GetCampaignStats возвращает статистику использования кодов кампании.
+// Суммы заказов учитываются только в валюте кампании.
+func (s *CampaignService) GetCampaignStats(ctx context.Context, campaignID uuid.UUID) (*models.CampaignStats, error) {
+	campaign, err := s.GetCampaign(ctx, campaignID)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &models.CampaignStats{
+		CampaignID:    campaignID,
+		Currency:      campaign.Currency,
+		DiscountTotal: money.Zero(campaign.Currency),
+		OrdersTotal:   money.Zero(campaign.Currency),
+	}
+
+	codesQuery := `
+		SELECT COUNT(*),
+			COUNT(*) FILTER (WHERE active),
+			COUNT(*) FILTER (WHERE used_count > 0),
+			COALESCE(SUM(used_count), 0)
+		FROM promo_codes
+		WHERE campaign_id = $1
+	`
+	if err := s.db.QueryRowContext(ctx, codesQuery, campaignID).Scan(&stats.Codes, &stats.ActiveCodes, &stats.RedeemedCodes, &stats.Redemptions); err != nil {
+		return nil, fmt.Errorf("failed to get campaign code stats: %w", err)
+	}
+	if stats.Codes > 0 {
+		stats.RedemptionRate = math.Round(float64(stats.RedeemedCodes)/float64(stats.Codes)*10000) / 10000
+	}
+
+	// Скидка считается по применениям кодов кампании: в заказе могут быть и другие коды
+	ordersQuery := `
+		SELECT COUNT(*), COALESCE(SUM(r.discount), 0), COALESCE(SUM(o.total_amount), 0)
+		FROM (
+			SELECT pr.order_id, SUM(pr.discount_amount) AS discount
+			FROM promo_redemptions pr
+			JOIN promo_codes p ON p.code = pr.code
+			WHERE p.campaign_id = $1 AND pr.reversed_at IS NULL AND pr.currency = $2
+			GROUP BY pr.order_id
+		) r
+		JOIN orders o ON o.id = r.order_id
+	`
+	if err := s.db.QueryRowContext(ctx, ordersQuery, campaignID, campaign.Currency).Scan(&stats.Orders, &stats.DiscountTotal, &stats.OrdersTotal); err != nil {
+		return nil, fmt.Errorf("failed to get campaign order stats: %w", err)
+	}
+
+	return stats, nil
+}
+
+func scanCampaign(scan func(dest ...interface{}) error) (*models.Campaign, error) {
+	c := &models.Campaign{}
+	var rules []byte
+	if err := scan(&c.ID, &c.Name, &c.DiscountType, &c.Amount, &c.Currency, &c.MaxUses, &c.ExpiresAt, &c.Active,
+		&rules, &c.Stacking, &c.CreatedAt, &c.UpdatedAt, &c.CodesCount); err != nil {
+		return nil, err
+	}
+	c.Amount = c.Amount.In(c.Currency)
+	var err error
+	if c.Rules, err = decodePromoRules(rules, c.Currency); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// copyPromoCodes загружает коды во временную таблицу через COPY.
+func copyPromoCodes(ctx context.Context, tx *sql.Tx, codes []string) error {
+	stmt, err := tx.PrepareContext(ctx, pq.CopyIn("promo_code_import", "code"))
+	if err != nil {
+		return fmt.Errorf("failed to prepare copy: %w", err)
+	}
+	defer stmt.Close()
+
+	for _, code := range codes {
+		if _, err := stmt.ExecContext(ctx, code); err != nil {
+			return fmt.Errorf("failed to copy promo code: %w", err)
+		}
+	}
+	if _, err := stmt.ExecContext(ctx); err != nil {
+		return fmt.Errorf("failed to flush copy: %w", err)
+	}
+	return nil
+}
+
+// codeSpec — проверенные параметры генерации кодов.
+type codeSpec struct {
+	prefix   string
+	alphabet []byte
+	length   int
+}
+
+func newCodeSpec(req *models.GenerateCodesRequest) (*codeSpec, error) {
+	if req.Count <= 0 || req.Count > MaxGeneratedCodes {
+		return nil, fmt.Errorf("count must be between 1 and %d", MaxGeneratedCodes)
+	}
+
+	spec := &codeSpec{prefix: req.Prefix, length: req.Length}
+	if spec.length == 0 {
+		spec.length = DefaultCodeLength
+	}
+	if spec.length < minCodeLength || spec.length > maxCodeLength {
+		return nil, fmt.Errorf("length must be between %d and %d", minCodeLength, maxCodeLength)
+	}
+	if len(spec.prefix)+spec.length > maxPromoCodeLength {
+		return nil, fmt.Errorf("prefix and length must not exceed %d characters", maxPromoCodeLength)
+	}
+	for _, r := range spec.prefix {
+		if !isCodeChar(r) && r != '-' && r != '_' {
+			return nil, fmt.Errorf("prefix may contain only letters, digits, '-' and '_'")
+		}
+	}
+
+	alphabet := req.Alphabet
+	if alphabet == "" {
+		alphabet = DefaultCodeAlphabet
+	}
+	unique := make(map[rune]struct{}, len(alphabet))
+	for _, r := range alphabet {
+		if !isCodeChar(r) {
+			return nil, fmt.Errorf("alphabet may contain only latin letters and digits")
+		}
+		if _, ok := unique[r]; ok {
+			return nil, fmt.Errorf("alphabet must not contain duplicate characters")
+		}
+		unique[r] = struct{}{}
+	}
+	if len(unique) < 2 {
+		return nil, fmt.Errorf("alphabet must contain at least 2 characters")
+	}
+	spec.alphabet = []byte(alphabet)
+
+	// Пространство кодов должно быть заметно больше запрошенного, иначе коллизии не дадут выпустить пачку
+	space := math.Pow(float64(len(spec.alphabet)), float64(spec.length))
+	if space < float64(req.Count)*minCodeSpaceHeadroom {
+		return nil, fmt.Errorf("alphabet and length allow too few unique codes for count %d", req.Count)
+	}
+
+	return spec, nil
+}
+
+// generate возвращает n новых кодов, не встречавшихся в seen, и добавляет их туда.
+func (c *codeSpec) generate(n int, seen map[string]struct{}) ([]string, error) {
+	// Байты выше limit отбрасываются, чтобы символы алфавита выпадали равновероятно
+	limit := 256 - 256%len(c.alphabet)
+	buf := make([]byte, 256)
+	pos := len(buf)
+	next := func() (byte, error) {
+		for {
+			if pos == len(buf) {
+				if _, err := rand.Read(buf); err != nil {
+					return 0, fmt.Errorf("failed to read random bytes: %w", err)
+				}
+				pos = 0
+			}
+			b := buf[pos]
+			pos++
+			if int(b) < limit {
+				return c.alphabet[int(b)%len(c.alphabet)], nil
+			}
+		}
+	}
+
+	codes := make([]string, 0, n)
+	code := make([]byte, c.length)
+	for len(codes) < n {
+		for i := range code {
+			ch, err := next()
+			if err != nil {
+				return nil, err
+			}
+			code[i] = ch
+		}
+		value := c.prefix + string(code)
+		if _, ok := seen[value]; ok {
+			continue
+		}
+		seen[value] = struct{}{}
+		codes = append(codes, value)
+	}
+	return codes, nil
+}
+
+func isCodeChar(r rune) bool {
+	return (r >= 'A' && r <= 'Z') || (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9')
+}
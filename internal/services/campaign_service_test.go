@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+func TestCodeSpec_Generate(t *testing.T) {
+	spec, err := newCodeSpec(&models.GenerateCodesRequest{Count: 500, Prefix: "BLOG-", Alphabet: "ABC123", Length: 6})
+	if err != nil {
+		t.Fatalf("expected valid spec, got %v", err)
+	}
+
+	seen := map[string]struct{}{}
+	codes, err := spec.generate(500, seen)
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if len(codes) != 500 || len(seen) != 500 {
+		t.Fatalf("expected 500 unique codes, got %d (seen %d)", len(codes), len(seen))
+	}
+	for _, code := range codes {
+		body := strings.TrimPrefix(code, "BLOG-")
+		if body == code || len(body) != 6 || strings.Trim(body, "ABC123") != "" {
+			t.Fatalf("unexpected code %q", code)
+		}
+	}
+}
+
+func TestNewCodeSpec_Validation(t *testing.T) {
+	cases := []models.GenerateCodesRequest{
+		{Count: 0},
+		{Count: MaxGeneratedCodes + 1},
+		{Count: 10, Length: 2},
+		{Count: 10, Alphabet: "AAB"},
+		{Count: 10, Alphabet: "A"},
+		{Count: 10, Alphabet: "AB-"},
+		{Count: 10, Prefix: "BAD PREFIX"},
+		{Count: 10, Prefix: strings.Repeat("P", 60)},
+		{Count: 10000, Alphabet: "AB", Length: 8},
+	}
+	for _, req := range cases {
+		if _, err := newCodeSpec(&req); err == nil {
+			t.Fatalf("expected validation error for %+v", req)
+		}
+	}
+}
+
+func TestCampaignService_GenerateCodes_RetriesCollisions(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewCampaignService(db, newTestLogger())
+	campaignID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT active FROM campaigns").
+		WithArgs(campaignID).
+		WillReturnRows(sqlmock.NewRows([]string{"active"}).AddRow(true))
+	mock.ExpectExec("CREATE TEMP TABLE promo_code_import").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+
+	// Первая пачка: один из трех кодов уже существует
+	prepare := mock.ExpectPrepare("COPY")
+	for i := 0; i < 3; i++ {
+		prepare.ExpectExec().WithArgs(sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	prepare.ExpectExec().WithoutArgs().WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO promo_codes").
+		WithArgs(campaignID).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+
+	// Вторая пачка догенерирует недостающий код
+	mock.ExpectExec("TRUNCATE promo_code_import").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	prepare = mock.ExpectPrepare("COPY")
+	prepare.ExpectExec().WithArgs(sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(0, 1))
+	prepare.ExpectExec().WithoutArgs().WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO promo_codes").
+		WithArgs(campaignID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM promo_codes WHERE campaign_id").
+		WithArgs(campaignID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(3))
+	mock.ExpectCommit()
+
+	result, err := service.GenerateCodes(context.Background(), campaignID, &models.GenerateCodesRequest{Count: 3, Prefix: "X"})
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if result.Generated != 3 || result.CodesCount != 3 {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCampaignService_GenerateCodes_InactiveCampaign(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewCampaignService(db, newTestLogger())
+	campaignID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT active FROM campaigns").
+		WithArgs(campaignID).
+		WillReturnRows(sqlmock.NewRows([]string{"active"}).AddRow(false))
+	mock.ExpectRollback()
+
+	_, err := service.GenerateCodes(context.Background(), campaignID, &models.GenerateCodesRequest{Count: 10})
+	if !apperror.Is(err, apperror.KindConflict) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+}
+
+func TestCampaignService_DeactivateCampaign(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewCampaignService(db, newTestLogger())
+	campaignID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("UPDATE campaigns SET active = FALSE").
+		WithArgs(sqlmock.AnyArg(), campaignID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE promo_codes SET active = FALSE").
+		WithArgs(sqlmock.AnyArg(), campaignID).
+		WillReturnResult(sqlmock.NewResult(0, 120))
+	mock.ExpectCommit()
+	mock.ExpectQuery("SELECT c.id, c.name").
+		WithArgs(campaignID).
+		WillReturnRows(campaignRows().AddRow(campaignID, "Blogger", models.DiscountTypePercent, 10.0, "RUB", 1, nil, false, "{}", "exclusive", time.Now(), time.Now(), 120))
+
+	campaign, err := service.DeactivateCampaign(context.Background(), campaignID)
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if campaign.Active || campaign.CodesCount != 120 {
+		t.Fatalf("unexpected campaign: %+v", campaign)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCampaignService_GetCampaignStats(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewCampaignService(db, newTestLogger())
+	campaignID := uuid.New()
+
+	mock.ExpectQuery("SELECT c.id, c.name").
+		WithArgs(campaignID).
+		WillReturnRows(campaignRows().AddRow(campaignID, "Blogger", models.DiscountTypeFixed, 100.0, "RUB", 1, nil, true, "{}", "combinable", time.Now(), time.Now(), 4))
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\),").
+		WithArgs(campaignID).
+		WillReturnRows(sqlmock.NewRows([]string{"count", "active", "redeemed", "redemptions"}).AddRow(4, 3, 1, 1))
+	mock.ExpectQuery("FROM promo_redemptions pr").
+		WithArgs(campaignID, "RUB").
+		WillReturnRows(sqlmock.NewRows([]string{"count", "discount", "total"}).AddRow(1, "100.00", "650.00"))
+
+	stats, err := service.GetCampaignStats(context.Background(), campaignID)
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if stats.Codes != 4 || stats.RedemptionRate != 0.25 || stats.DiscountTotal != rub(100) || stats.OrdersTotal != rub(650) {
+		t.Fatalf("unexpected stats: %+v", stats)
+	}
+}
+
+func campaignRows() *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"id", "name", "discount_type", "amount", "currency", "max_uses", "expires_at", "active", "rules", "stacking", "created_at", "updated_at", "codes_count"})
+}
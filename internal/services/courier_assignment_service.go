@@ -2,9 +2,11 @@ package services
 
 import (
 	"context"
+	"database/sql"
 	"fmt"
 	"math"
 
+	"delivery-system/internal/actor"
 	"delivery-system/internal/apperror"
 	"delivery-system/internal/database"
 	"delivery-system/internal/logger"
@@ -33,30 +35,33 @@ func NewCourierAssignmentService(db *database.DB, courierService *CourierService
 
 // CourierScore представляет оценку курьера для назначения
 type CourierScore struct {
-	CourierID     uuid.UUID
-	CourierName   string
-	DistanceScore float64 // 0-1, где 1 = лучший (ближайший)
-	RatingScore   float64 // 0-1, где 1 = лучший (рейтинг 5)
-	WorkloadScore float64 // 0-1, где 1 = лучший (нет активных заказов)
-	TotalScore    float64 // взвешенная сумма
-	Distance      float64 // расстояние в км
-	Rating        float64
-	ActiveOrders  int
+	CourierID        uuid.UUID
+	CourierName      string
+	DistanceScore    float64 // 0-1, где 1 = лучший (ближайший)
+	RatingScore      float64 // 0-1, где 1 = лучший (сглаженный рейтинг 5)
+	WorkloadScore    float64 // 0-1, где 1 = лучший (нет активных заказов)
+	PerformanceScore float64 // 0-1, оценка работы курьера по истории заказов
+	TotalScore       float64 // взвешенная сумма
+	Distance         float64 // расстояние в км
+	Rating           float64
+	ActiveOrders     int
 }
 
 // AssignmentWeights представляет веса для алгоритма назначения
 type AssignmentWeights struct {
-	Distance float64 // по умолчанию 0.40
-	Rating   float64 // по умолчанию 0.30
-	Workload float64 // по умолчанию 0.30
+	Distance    float64 // по умолчанию 0.35
+	Rating      float64 // по умолчанию 0.20
+	Workload    float64 // по умолчанию 0.25
+	Performance float64 // по умолчанию 0.20
 }
 
 // DefaultWeights возвращает стандартные веса
 func DefaultWeights() AssignmentWeights {
 	return AssignmentWeights{
-		Distance: 0.40,
-		Rating:   0.30,
-		Workload: 0.30,
+		Distance:    0.35,
+		Rating:      0.20,
+		Workload:    0.25,
+		Performance: 0.20,
 	}
 }
 
@@ -98,11 +103,24 @@ func (s *CourierAssignmentService) AutoAssignCourier(ctx context.Context, orderI
 		return nil, apperror.Conflict("no couriers with known location available", nil)
 	}
 
+	// Оставляем курьеров, чей транспорт выдержит вес и объем заказа и проедет весь маршрут
+	var capableCouriers []*models.Courier
+	for _, c := range couriersWithLocation {
+		routeKm := courierRouteDistance(c, order, deliveryLat, deliveryLon)
+		if c.CanCarry(order.WeightKg, order.VolumeL, routeKm) {
+			capableCouriers = append(capableCouriers, c)
+		}
+	}
+
+	if len(capableCouriers) == 0 {
+		return nil, apperror.Conflict("no available couriers can carry this order", nil)
+	}
+
 	// Рассчитываем оценки для каждого курьера
 	weights := DefaultWeights()
-	scores := make([]CourierScore, 0, len(couriersWithLocation))
+	scores := make([]CourierScore, 0, len(capableCouriers))
 
-	for _, courier := range couriersWithLocation {
+	for _, courier := range capableCouriers {
 		score := s.calculateCourierScore(ctx, courier, deliveryLat, deliveryLon, weights)
 		scores = append(scores, score)
 	}
@@ -115,24 +133,27 @@ func (s *CourierAssignmentService) AutoAssignCourier(ctx context.Context, orderI
 		}
 	}
 
-	// Назначаем заказ лучшему курьеру
-	err = s.courierService.AssignOrderToCourier(ctx, orderID, bestScore.CourierID)
+	// Назначаем заказ лучшему курьеру; в журнале статусов назначение записывается от автоназначения
+	err = s.courierService.AssignOrderToCourier(actor.WithContext(ctx, actor.System("auto_assign")), orderID, bestScore.CourierID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to assign order to courier: %w", err)
 	}
 
 	// Логируем причину выбора
 	s.log.WithFields(map[string]interface{}{
-		"order_id":       orderID,
-		"courier_id":     bestScore.CourierID,
-		"courier_name":   bestScore.CourierName,
-		"total_score":    bestScore.TotalScore,
-		"distance_score": bestScore.DistanceScore,
-		"rating_score":   bestScore.RatingScore,
-		"workload_score": bestScore.WorkloadScore,
-		"distance_km":    bestScore.Distance,
-		"rating":         bestScore.Rating,
-		"active_orders":  bestScore.ActiveOrders,
+		"order_id":          orderID,
+		"courier_id":        bestScore.CourierID,
+		"courier_name":      bestScore.CourierName,
+		"total_score":       bestScore.TotalScore,
+		"distance_score":    bestScore.DistanceScore,
+		"rating_score":      bestScore.RatingScore,
+		"workload_score":    bestScore.WorkloadScore,
+		"performance_score": bestScore.PerformanceScore,
+		"distance_km":       bestScore.Distance,
+		"rating":            bestScore.Rating,
+		"active_orders":     bestScore.ActiveOrders,
+		"weight_kg":         order.WeightKg,
+		"volume_l":          order.VolumeL,
 	}).Info("Courier auto-assigned based on scoring algorithm")
 
 	// Возвращаем назначенного курьера
@@ -160,8 +181,9 @@ func (s *CourierAssignmentService) calculateCourierScore(ctx context.Context, co
 		score.DistanceScore = 1.0 - (distance / maxDistance)
 	}
 
-	// Rating Score: нормализуем рейтинг от 0 до 5 в диапазон 0-1
-	score.RatingScore = courier.Rating / 5.0
+	// Rating Score: нормализуем рейтинг от 0 до 5 в диапазон 0-1. Рейтинг сглаживается по числу отзывов,
+	// чтобы новичок с единственной пятеркой не обходил опытных курьеров
+	score.RatingScore = models.BayesRating(courier.Rating, courier.TotalReviews) / 5.0
 
 	// Workload Score: получаем количество активных заказов у курьера
 	activeOrders := s.getActiveCourierOrders(ctx, courier.ID)
@@ -176,21 +198,43 @@ func (s *CourierAssignmentService) calculateCourierScore(ctx context.Context, co
 		score.WorkloadScore = 1.0 - (float64(activeOrders) / maxOrders)
 	}
 
+	// Performance Score: оценка по принятым, отмененным и своевременным доставкам
+	score.PerformanceScore = s.getPerformanceScore(ctx, courier)
+
 	// Рассчитываем взвешенную сумму
 	score.TotalScore = (score.DistanceScore * weights.Distance) +
 		(score.RatingScore * weights.Rating) +
-		(score.WorkloadScore * weights.Workload)
+		(score.WorkloadScore * weights.Workload) +
+		(score.PerformanceScore * weights.Performance)
 
 	return score
 }
 
+// courierRouteDistance возвращает длину маршрута курьера: до точки забора и от нее до адреса доставки.
+// Если у заказа нет координат забора, считается расстояние до точки доставки. Если неизвестна позиция
+// курьера, участок до первой точки не учитывается.
+func courierRouteDistance(courier *models.Courier, order *models.Order, deliveryLat, deliveryLon float64) float64 {
+	located := courier.CurrentLat != nil && courier.CurrentLon != nil
+	if order.PickupLat == nil || order.PickupLon == nil {
+		if !located {
+			return 0
+		}
+		return calculateDistance(*courier.CurrentLat, *courier.CurrentLon, deliveryLat, deliveryLon)
+	}
+	distance := calculateDistance(*order.PickupLat, *order.PickupLon, deliveryLat, deliveryLon)
+	if located {
+		distance += calculateDistance(*courier.CurrentLat, *courier.CurrentLon, *order.PickupLat, *order.PickupLon)
+	}
+	return distance
+}
+
 // getActiveCourierOrders возвращает количество активных заказов у курьера
 func (s *CourierAssignmentService) getActiveCourierOrders(ctx context.Context, courierID uuid.UUID) int {
 	query := `
 		SELECT COUNT(*) 
 		FROM orders 
 		WHERE courier_id = $1 
-		  AND status IN ('accepted', 'preparing', 'ready', 'in_delivery')
+		  AND status IN ('accepted', 'preparing', 'ready', 'picked_up', 'in_delivery', 'delivery_failed', 'returning')
 	`
 
 	var count int
@@ -203,6 +247,29 @@ func (s *CourierAssignmentService) getActiveCourierOrders(ctx context.Context, c
 	return count
 }
 
+// getPerformanceScore считает оценку работы курьера по сохраненным счетчикам и текущему рейтингу:
+// сохраненная оценка устаревает, как только меняется рейтинг. Если событий по курьеру еще не было
+// или счетчики не удалось прочитать, используются априорные значения.
+func (s *CourierAssignmentService) getPerformanceScore(ctx context.Context, courier *models.Courier) float64 {
+	query := `
+		SELECT assignments_offered, assignments_dropped, orders_delivered, orders_cancelled,
+			timed_deliveries, on_time_deliveries, delivery_minutes, eta_minutes
+		FROM courier_performance
+		WHERE courier_id = $1
+	`
+	p := &models.CourierPerformance{CourierID: courier.ID}
+	err := s.db.QueryRowContext(ctx, query, courier.ID).Scan(&p.AssignmentsOffered, &p.AssignmentsDropped, &p.OrdersDelivered, &p.OrdersCancelled,
+		&p.TimedDeliveries, &p.OnTimeDeliveries, &p.DeliveryMinutes, &p.EtaMinutes)
+	if err != nil {
+		if err != sql.ErrNoRows {
+			s.log.WithError(err).WithField("courier_id", courier.ID).Warn("Failed to get courier performance counters, using prior")
+		}
+		p = &models.CourierPerformance{CourierID: courier.ID}
+	}
+
+	return p.ComputeScore(courier.Rating, courier.TotalReviews).Score
+}
+
 // calculateDistance вычисляет расстояние между двумя точками по формуле гаверсинуса (в км)
 func calculateDistance(lat1, lon1, lat2, lon2 float64) float64 {
 	const earthRadiusKm = 6371.0
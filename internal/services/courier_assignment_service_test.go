@@ -2,10 +2,13 @@ package services
 
 import (
 	"context"
+	"database/sql"
 	"errors"
+	"math"
 	"testing"
 	"time"
 
+	"delivery-system/internal/apperror"
 	"delivery-system/internal/database"
 	"delivery-system/internal/models"
 
@@ -18,12 +21,12 @@ func expectOrderGet(mock sqlmock.Sqlmock, orderID uuid.UUID, status models.Order
 		WithArgs(orderID).
 		WillReturnRows(sqlmock.NewRows([]string{
 			"id", "customer_name", "customer_phone", "delivery_address", "pickup_address", "pickup_lat", "pickup_lon", "delivery_lat", "delivery_lon",
-			"total_amount", "delivery_cost", "discount_amount", "promo_code", "status", "courier_id", "rating", "review_comment", "created_at", "updated_at", "delivered_at",
-		}).AddRow(orderID, "Name", "Phone", "Addr", "Pickup", 55.0, 37.0, 56.0, 38.0, 100.0, 10.0, 0.0, nil, status, courierID, nil, nil, now, now, nil))
+			"total_amount", "delivery_cost", "discount_amount", "currency", "region_code", "promo_code", "status", "courier_id", "rating", "review_comment", "created_at", "updated_at", "delivered_at", "merchant_name", "delivery_attempts", "return_cost", "vehicle_type", "weight_kg", "volume_l",
+		}).AddRow(orderID, "Name", "Phone", "Addr", "Pickup", 55.0, 37.0, 56.0, 38.0, 100.0, 10.0, 0.0, "RUB", "default", nil, status, courierID, nil, nil, now, now, nil, nil, 0, nil, "bike", 0.0, 0.0))
 
-	mock.ExpectQuery("SELECT id, order_id, name, quantity, price FROM order_items").
+	mock.ExpectQuery("SELECT id, order_id, name, quantity, price, tax_category, weight_kg, volume_l FROM order_items").
 		WithArgs(orderID).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "name", "quantity", "price"}))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "name", "quantity", "price", "tax_category", "weight_kg", "volume_l"}))
 }
 
 func TestCalculateDistance(t *testing.T) {
@@ -38,6 +41,50 @@ func TestCalculateDistance(t *testing.T) {
 	}
 }
 
+// performanceCounterRows возвращает строку счетчиков courier_performance.
+func performanceCounterRows(p *models.CourierPerformance) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"assignments_offered", "assignments_dropped", "orders_delivered", "orders_cancelled",
+		"timed_deliveries", "on_time_deliveries", "delivery_minutes", "eta_minutes"}).
+		AddRow(p.AssignmentsOffered, p.AssignmentsDropped, p.OrdersDelivered, p.OrdersCancelled,
+			p.TimedDeliveries, p.OnTimeDeliveries, p.DeliveryMinutes, p.EtaMinutes)
+}
+
+func TestCourierAssignmentService_PerformanceScoreUsesCurrentRating(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	log := newTestLogger()
+	orderService := NewOrderService(db, log, newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
+	assignmentService := NewCourierAssignmentService(db, NewCourierService(db, log), orderService, log)
+
+	courierID := uuid.New()
+	counters := &models.CourierPerformance{AssignmentsOffered: 5, OrdersDelivered: 5, TimedDeliveries: 5, OnTimeDeliveries: 5, DeliveryMinutes: 100, EtaMinutes: 100}
+	for i := 0; i < 2; i++ {
+		mock.ExpectQuery("SELECT assignments_offered, .* FROM courier_performance").
+			WithArgs(courierID).
+			WillReturnRows(performanceCounterRows(counters))
+	}
+
+	// Счетчики те же, но рейтинг упал после новых отзывов: оценка должна упасть вместе с ним
+	before := assignmentService.getPerformanceScore(context.Background(), &models.Courier{ID: courierID, Rating: 5.0, TotalReviews: 40})
+	after := assignmentService.getPerformanceScore(context.Background(), &models.Courier{ID: courierID, Rating: 3.0, TotalReviews: 60})
+	if before != counters.ComputeScore(5.0, 40).Score || after != counters.ComputeScore(3.0, 60).Score || after >= before {
+		t.Fatalf("expected score to follow current rating, got %.4f then %.4f", before, after)
+	}
+
+	// Без событий по курьеру используется априорная оценка с его рейтингом
+	mock.ExpectQuery("SELECT assignments_offered, .* FROM courier_performance").
+		WithArgs(courierID).
+		WillReturnError(sql.ErrNoRows)
+	if prior := assignmentService.getPerformanceScore(context.Background(), &models.Courier{ID: courierID, Rating: 3.0, TotalReviews: 60}); prior != (&models.CourierPerformance{}).ComputeScore(3.0, 60).Score {
+		t.Fatalf("unexpected prior score %.4f", prior)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
 func TestCalculateDistance_SamePoint(t *testing.T) {
 	distance := calculateDistance(55.7558, 37.6173, 55.7558, 37.6173)
 	if distance != 0 {
@@ -51,19 +98,20 @@ func TestCourierAssignmentService_CalculateCourierScore(t *testing.T) {
 
 	ctx := context.Background()
 	log := newTestLogger()
-	orderService := NewOrderService(db, log, newTestPricingService(), nil)
+	orderService := NewOrderService(db, log, newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
 	courierService := NewCourierService(db, log)
 	assignmentService := NewCourierAssignmentService(db, courierService, orderService, log)
 
 	courierID := uuid.New()
 	lat, lon := 55.7558, 37.6173
 	courier := &models.Courier{
-		ID:         courierID,
-		Name:       "Test Courier",
-		Status:     models.CourierStatusAvailable,
-		CurrentLat: &lat,
-		CurrentLon: &lon,
-		Rating:     4.5,
+		ID:           courierID,
+		Name:         "Test Courier",
+		Status:       models.CourierStatusAvailable,
+		CurrentLat:   &lat,
+		CurrentLon:   &lon,
+		Rating:       5.0,
+		TotalReviews: 10,
 	}
 
 	// Mock для подсчёта активных заказов
@@ -71,6 +119,12 @@ func TestCourierAssignmentService_CalculateCourierScore(t *testing.T) {
 		WithArgs(courierID).
 		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
 
+	// Счетчики работы курьера: оценка считается по ним и текущему рейтингу
+	counters := &models.CourierPerformance{AssignmentsOffered: 10, AssignmentsDropped: 1, OrdersDelivered: 8, OrdersCancelled: 1, TimedDeliveries: 8, OnTimeDeliveries: 7, DeliveryMinutes: 200, EtaMinutes: 240}
+	mock.ExpectQuery("SELECT assignments_offered, .* FROM courier_performance").
+		WithArgs(courierID).
+		WillReturnRows(performanceCounterRows(counters))
+
 	// Целевая точка доставки (близко к курьеру: ~5 км)
 	targetLat, targetLon := 55.8, 37.6
 
@@ -86,8 +140,8 @@ func TestCourierAssignmentService_CalculateCourierScore(t *testing.T) {
 		t.Fatalf("expected positive distance, got %.2f", score.Distance)
 	}
 
-	// Проверяем рейтинг score (4.5/5 = 0.9)
-	expectedRatingScore := 0.9
+	// Проверяем рейтинг score: 5.0 по 10 отзывам сглаживается к 4.75, 4.75/5 = 0.95
+	expectedRatingScore := 0.95
 	if score.RatingScore != expectedRatingScore {
 		t.Fatalf("expected rating score %.2f, got %.2f", expectedRatingScore, score.RatingScore)
 	}
@@ -101,7 +155,11 @@ func TestCourierAssignmentService_CalculateCourierScore(t *testing.T) {
 	// TotalScore должен быть взвешенной суммой
 	expectedTotal := (score.DistanceScore * weights.Distance) +
 		(score.RatingScore * weights.Rating) +
-		(score.WorkloadScore * weights.Workload)
+		(score.WorkloadScore * weights.Workload) +
+		(score.PerformanceScore * weights.Performance)
+	if want := counters.ComputeScore(5.0, 10).Score; score.PerformanceScore != want {
+		t.Fatalf("expected performance score %.4f, got %.4f", want, score.PerformanceScore)
+	}
 	if score.TotalScore != expectedTotal {
 		t.Fatalf("expected total score %.2f, got %.2f", expectedTotal, score.TotalScore)
 	}
@@ -114,19 +172,22 @@ func TestCourierAssignmentService_CalculateCourierScore(t *testing.T) {
 func TestDefaultWeights(t *testing.T) {
 	weights := DefaultWeights()
 
-	if weights.Distance != 0.40 {
-		t.Fatalf("expected distance weight 0.40, got %.2f", weights.Distance)
+	if weights.Distance != 0.35 {
+		t.Fatalf("expected distance weight 0.35, got %.2f", weights.Distance)
+	}
+	if weights.Rating != 0.20 {
+		t.Fatalf("expected rating weight 0.20, got %.2f", weights.Rating)
 	}
-	if weights.Rating != 0.30 {
-		t.Fatalf("expected rating weight 0.30, got %.2f", weights.Rating)
+	if weights.Workload != 0.25 {
+		t.Fatalf("expected workload weight 0.25, got %.2f", weights.Workload)
 	}
-	if weights.Workload != 0.30 {
-		t.Fatalf("expected workload weight 0.30, got %.2f", weights.Workload)
+	if weights.Performance != 0.20 {
+		t.Fatalf("expected performance weight 0.20, got %.2f", weights.Performance)
 	}
 
 	// Проверяем, что сумма весов равна 1.0
-	total := weights.Distance + weights.Rating + weights.Workload
-	if total != 1.0 {
+	total := weights.Distance + weights.Rating + weights.Workload + weights.Performance
+	if math.Abs(total-1.0) > 1e-9 {
 		t.Fatalf("expected total weight 1.0, got %.2f", total)
 	}
 }
@@ -166,24 +227,28 @@ func TestCourierAssignmentService_AutoAssign(t *testing.T) {
 
 	orderRows := sqlmock.NewRows([]string{
 		"id", "customer_name", "customer_phone", "delivery_address", "pickup_address", "pickup_lat", "pickup_lon", "delivery_lat", "delivery_lon",
-		"total_amount", "delivery_cost", "discount_amount", "promo_code", "status", "courier_id", "rating", "review_comment", "created_at", "updated_at", "delivered_at",
-	}).AddRow(orderID, "Name", "Phone", "Addr", "Pickup", 55.0, 37.0, 56.0, 38.0, 100.0, 10.0, 0.0, nil, models.OrderStatusCreated, nil, nil, nil, now, now, nil)
+		"total_amount", "delivery_cost", "discount_amount", "currency", "region_code", "promo_code", "status", "courier_id", "rating", "review_comment", "created_at", "updated_at", "delivered_at", "merchant_name", "delivery_attempts", "return_cost", "vehicle_type", "weight_kg", "volume_l",
+	}).AddRow(orderID, "Name", "Phone", "Addr", "Pickup", 55.0, 37.0, 56.0, 38.0, 100.0, 10.0, 0.0, "RUB", "default", nil, models.OrderStatusCreated, nil, nil, nil, now, now, nil, nil, 0, nil, "bike", 0.0, 0.0)
 	mock.ExpectQuery("SELECT id, customer_name").WithArgs(orderID).WillReturnRows(orderRows)
-	mock.ExpectQuery("SELECT id, order_id, name, quantity, price FROM order_items").WithArgs(orderID).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "name", "quantity", "price"}))
+	mock.ExpectQuery("SELECT id, order_id, name, quantity, price, tax_category, weight_kg, volume_l FROM order_items").WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "name", "quantity", "price", "tax_category", "weight_kg", "volume_l"}))
 
 	courierRows := sqlmock.NewRows([]string{
-		"id", "name", "phone", "status", "current_lat", "current_lon", "rating", "total_reviews", "created_at", "updated_at", "last_seen_at",
-	}).AddRow(courierID, "C", "p", models.CourierStatusAvailable, 55.0, 37.0, 4.5, 0, now, now, nil)
+		"id", "name", "phone", "status", "current_lat", "current_lon", "rating", "total_reviews", "created_at", "updated_at", "last_seen_at", "vehicle_type", "max_weight_kg", "max_volume_l", "max_range_km", "onboarding_status", "onboarding_reason", "verified_at",
+	}).AddRow(courierID, "C", "p", models.CourierStatusAvailable, 55.0, 37.0, 4.5, 0, now, now, nil, "bike", nil, nil, nil, models.OnboardingStatusVerified, nil, nil)
 	mock.ExpectQuery("SELECT id, name, phone, status, current_lat, current_lon").WillReturnRows(courierRows)
 
 	mock.ExpectQuery("SELECT COUNT\\(\\*\\).*FROM orders").WithArgs(courierID).
 		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectQuery("SELECT assignments_offered, .* FROM courier_performance").WithArgs(courierID).
+		WillReturnRows(performanceCounterRows(&models.CourierPerformance{}))
 
 	mock.ExpectBegin()
-	mock.ExpectQuery("SELECT status FROM couriers WHERE id = \\$1 FOR UPDATE").
+	mock.ExpectQuery("SELECT status, onboarding_status FROM couriers WHERE id = \\$1 FOR UPDATE").
 		WithArgs(courierID).
-		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(string(models.CourierStatusAvailable)))
+		WillReturnRows(sqlmock.NewRows([]string{"status", "onboarding_status"}).AddRow(string(models.CourierStatusAvailable), models.OnboardingStatusVerified))
+	expectAssignOrderLock(mock, orderID, courierID, models.OrderStatusCreated, vehicleCheckRow(0, 55.75, 37.60, 25, 25))
+	mock.ExpectExec("SELECT set_config").WithArgs("system:auto_assign").WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectExec("UPDATE orders").
 		WithArgs(courierID, models.OrderStatusAccepted, sqlmock.AnyArg(), orderID, models.OrderStatusCreated).
 		WillReturnResult(sqlmock.NewResult(0, 1))
@@ -192,12 +257,12 @@ func TestCourierAssignmentService_AutoAssign(t *testing.T) {
 		WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectCommit()
 
-	mock.ExpectQuery("SELECT id, name, phone, status, current_lat, current_lon, rating, total_reviews, created_at, updated_at, last_seen_at FROM couriers").
+	mock.ExpectQuery("SELECT id, name, phone, status, current_lat, current_lon, rating, total_reviews, created_at, updated_at, last_seen_at, vehicle_type, max_weight_kg, max_volume_l, max_range_km,\\s+onboarding_status, onboarding_reason, verified_at FROM couriers").
 		WithArgs(courierID).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "phone", "status", "current_lat", "current_lon", "rating", "total_reviews", "created_at", "updated_at", "last_seen_at"}).
-			AddRow(courierID, "C", "p", models.CourierStatusAvailable, 55.0, 37.0, 4.5, 0, now, now, nil))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "phone", "status", "current_lat", "current_lon", "rating", "total_reviews", "created_at", "updated_at", "last_seen_at", "vehicle_type", "max_weight_kg", "max_volume_l", "max_range_km", "onboarding_status", "onboarding_reason", "verified_at"}).
+			AddRow(courierID, "C", "p", models.CourierStatusAvailable, 55.0, 37.0, 4.5, 0, now, now, nil, "bike", nil, nil, nil, models.OnboardingStatusVerified, nil, nil))
 
-	orderSvc := NewOrderService(db, log, newTestPricingService(), nil)
+	orderSvc := NewOrderService(db, log, newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
 	courierSvc := NewCourierService(db, log)
 	service := NewCourierAssignmentService(db, courierSvc, orderSvc, log)
 
@@ -220,7 +285,7 @@ func TestCourierAssignmentService_AutoAssign_OrderNotCreated(t *testing.T) {
 
 	ctx := context.Background()
 	log := newTestLogger()
-	orderSvc := NewOrderService(db, log, newTestPricingService(), nil)
+	orderSvc := NewOrderService(db, log, newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
 	courierSvc := NewCourierService(db, log)
 	service := NewCourierAssignmentService(db, courierSvc, orderSvc, log)
 
@@ -239,7 +304,7 @@ func TestCourierAssignmentService_AutoAssign_OrderAlreadyAssigned(t *testing.T)
 
 	ctx := context.Background()
 	log := newTestLogger()
-	orderSvc := NewOrderService(db, log, newTestPricingService(), nil)
+	orderSvc := NewOrderService(db, log, newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
 	courierSvc := NewCourierService(db, log)
 	service := NewCourierAssignmentService(db, courierSvc, orderSvc, log)
 
@@ -259,7 +324,7 @@ func TestCourierAssignmentService_AutoAssign_NoAvailableCouriers(t *testing.T) {
 
 	ctx := context.Background()
 	log := newTestLogger()
-	orderSvc := NewOrderService(db, log, newTestPricingService(), nil)
+	orderSvc := NewOrderService(db, log, newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
 	courierSvc := NewCourierService(db, log)
 	service := NewCourierAssignmentService(db, courierSvc, orderSvc, log)
 
@@ -269,7 +334,7 @@ func TestCourierAssignmentService_AutoAssign_NoAvailableCouriers(t *testing.T) {
 
 	mock.ExpectQuery("SELECT id, name, phone, status, current_lat, current_lon").
 		WillReturnRows(sqlmock.NewRows([]string{
-			"id", "name", "phone", "status", "current_lat", "current_lon", "rating", "total_reviews", "created_at", "updated_at", "last_seen_at",
+			"id", "name", "phone", "status", "current_lat", "current_lon", "rating", "total_reviews", "created_at", "updated_at", "last_seen_at", "vehicle_type", "max_weight_kg", "max_volume_l", "max_range_km", "onboarding_status", "onboarding_reason", "verified_at",
 		}))
 
 	if _, err := service.AutoAssignCourier(ctx, orderID, 56.0, 38.0); err == nil {
@@ -283,7 +348,7 @@ func TestCourierAssignmentService_AutoAssign_NoCouriersWithLocation(t *testing.T
 
 	ctx := context.Background()
 	log := newTestLogger()
-	orderSvc := NewOrderService(db, log, newTestPricingService(), nil)
+	orderSvc := NewOrderService(db, log, newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
 	courierSvc := NewCourierService(db, log)
 	service := NewCourierAssignmentService(db, courierSvc, orderSvc, log)
 
@@ -294,14 +359,55 @@ func TestCourierAssignmentService_AutoAssign_NoCouriersWithLocation(t *testing.T
 	courierID := uuid.New()
 	mock.ExpectQuery("SELECT id, name, phone, status, current_lat, current_lon").
 		WillReturnRows(sqlmock.NewRows([]string{
-			"id", "name", "phone", "status", "current_lat", "current_lon", "rating", "total_reviews", "created_at", "updated_at", "last_seen_at",
-		}).AddRow(courierID, "C", "p", models.CourierStatusAvailable, nil, nil, 4.5, 0, now, now, nil))
+			"id", "name", "phone", "status", "current_lat", "current_lon", "rating", "total_reviews", "created_at", "updated_at", "last_seen_at", "vehicle_type", "max_weight_kg", "max_volume_l", "max_range_km", "onboarding_status", "onboarding_reason", "verified_at",
+		}).AddRow(courierID, "C", "p", models.CourierStatusAvailable, nil, nil, 4.5, 0, now, now, nil, "bike", nil, nil, nil, models.OnboardingStatusVerified, nil, nil))
 
 	if _, err := service.AutoAssignCourier(ctx, orderID, 56.0, 38.0); err == nil {
 		t.Fatalf("expected error for couriers without location")
 	}
 }
 
+func TestCourierAssignmentService_AutoAssign_NoCapableCouriers(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	ctx := context.Background()
+	log := newTestLogger()
+	orderSvc := NewOrderService(db, log, newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
+	courierSvc := NewCourierService(db, log)
+	service := NewCourierAssignmentService(db, courierSvc, orderSvc, log)
+
+	orderID := uuid.New()
+	now := time.Now()
+	// Заказ на 30 кг с маршрутом около 130 км
+	mock.ExpectQuery("SELECT id, customer_name").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "customer_name", "customer_phone", "delivery_address", "pickup_address", "pickup_lat", "pickup_lon", "delivery_lat", "delivery_lon",
+			"total_amount", "delivery_cost", "discount_amount", "currency", "region_code", "promo_code", "status", "courier_id", "rating", "review_comment", "created_at", "updated_at", "delivered_at", "merchant_name", "delivery_attempts", "return_cost", "vehicle_type", "weight_kg", "volume_l",
+		}).AddRow(orderID, "Name", "Phone", "Addr", "Pickup", 55.0, 37.0, 56.0, 38.0, 100.0, 10.0, 0.0, "RUB", "default", nil, models.OrderStatusCreated, nil, nil, nil, now, now, nil, nil, 0, nil, "car", 30.0, 60.0))
+	mock.ExpectQuery("SELECT id, order_id, name, quantity, price, tax_category, weight_kg, volume_l FROM order_items").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "name", "quantity", "price", "tax_category", "weight_kg", "volume_l"}))
+
+	// Велокурьер не поднимет груз, машине не хватит дальности
+	mock.ExpectQuery("SELECT id, name, phone, status, current_lat, current_lon").
+		WillReturnRows(sqlmock.NewRows([]string{
+			"id", "name", "phone", "status", "current_lat", "current_lon", "rating", "total_reviews", "created_at", "updated_at", "last_seen_at", "vehicle_type", "max_weight_kg", "max_volume_l", "max_range_km", "onboarding_status", "onboarding_reason", "verified_at",
+		}).
+			AddRow(uuid.New(), "Bike", "p1", models.CourierStatusAvailable, 55.0, 37.0, 5.0, 0, now, now, nil, "bike", 10.0, 40.0, 300.0, models.OnboardingStatusVerified, nil, nil).
+			AddRow(uuid.New(), "Car", "p2", models.CourierStatusAvailable, 55.0, 37.0, 4.0, 0, now, now, nil, "car", 200.0, 1000.0, 100.0, models.OnboardingStatusVerified, nil, nil))
+
+	_, err := service.AutoAssignCourier(ctx, orderID, 56.0, 38.0)
+	if !apperror.Is(err, apperror.KindConflict) {
+		t.Fatalf("expected conflict when no courier can carry the order, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
 func TestCourierAssignmentService_getActiveCourierOrders_ErrorReturnsZero(t *testing.T) {
 	db, mock := newMockDB(t)
 	defer db.Close()
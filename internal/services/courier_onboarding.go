@@ -0,0 +1,418 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"delivery-system/internal/actor"
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/database"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+	"delivery-system/internal/storage"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// CourierOnboardingService ведет подключение курьеров: документы, их сроки действия и этапы проверки.
+type CourierOnboardingService struct {
+	db      *database.DB
+	storage storage.BlobStorage
+	flow    *OrderFlow
+	log     *logger.Logger
+	now     func() time.Time
+}
+
+// NewCourierOnboardingService создает сервис подключения курьеров. flow задает конечные статусы заказов,
+// по которым проверяется, что у приостанавливаемого курьера нет активных заказов.
+func NewCourierOnboardingService(db *database.DB, blobStorage storage.BlobStorage, flow *OrderFlow, log *logger.Logger) *CourierOnboardingService {
+	if flow == nil {
+		flow, _ = NewOrderFlow(DefaultOrderTransitions(), 0)
+	}
+	return &CourierOnboardingService{
+		db:      db,
+		storage: blobStorage,
+		flow:    flow,
+		log:     log,
+		now:     time.Now,
+	}
+}
+
+// courierDocumentColumns — колонки документа в порядке, который ожидает scanCourierDocument.
+const courierDocumentColumns = `id, courier_id, doc_type, storage_key, content_type, size_bytes, expires_at, uploaded_at`
+
+// scanCourierDocument читает документ из строки, выбранной с courierDocumentColumns.
+func scanCourierDocument(row rowScanner) (*models.CourierDocument, error) {
+	d := &models.CourierDocument{}
+	if err := row.Scan(&d.ID, &d.CourierID, &d.Type, &d.StorageKey, &d.ContentType, &d.SizeBytes, &d.ExpiresAt, &d.UploadedAt); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// UploadDocument сохраняет документ курьера. Для прав и страховки нужен срок действия в будущем.
+// Когда у курьера на этапе applied собраны все обязательные действующие документы, он переходит
+// в documents_submitted. Курьер может загружать только собственные документы.
+func (s *CourierOnboardingService) UploadDocument(ctx context.Context, courierID uuid.UUID, docType models.DocumentType, contentType string, expiresAt *time.Time, content io.Reader) (*models.CourierDocument, error) {
+	if !docType.IsValid() {
+		return nil, apperror.Validation("invalid document type", nil)
+	}
+	if docType.Expires() && expiresAt == nil {
+		return nil, apperror.Validation(fmt.Sprintf("expires_at is required for %s", docType), nil)
+	}
+	if expiresAt != nil && !expiresAt.After(s.now()) {
+		return nil, apperror.Validation("document is already expired", nil)
+	}
+	if a := actor.FromContext(ctx); a.Type == actor.TypeCourier && a.ID != courierID.String() {
+		return nil, apperror.Forbidden("couriers can only upload their own documents", nil)
+	}
+
+	var status models.OnboardingStatus
+	if err := s.db.QueryRowContext(ctx, "SELECT onboarding_status FROM couriers WHERE id = $1", courierID).Scan(&status); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperror.NotFound("courier not found", err)
+		}
+		return nil, fmt.Errorf("failed to get courier: %w", err)
+	}
+	if status == models.OnboardingStatusDeactivated {
+		return nil, apperror.Conflict("courier is deactivated", nil)
+	}
+
+	docID := uuid.New()
+	key := fmt.Sprintf("couriers/%s/documents/%s", courierID, docID)
+
+	size, err := s.storage.Put(ctx, key, content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store courier document: %w", err)
+	}
+
+	doc := &models.CourierDocument{
+		ID:         docID,
+		CourierID:  courierID,
+		Type:       docType,
+		StorageKey: key,
+		SizeBytes:  size,
+		ExpiresAt:  expiresAt,
+		UploadedAt: s.now(),
+	}
+	if contentType != "" {
+		doc.ContentType = &contentType
+	}
+
+	submitted, err := s.saveDocument(ctx, doc)
+	if err != nil {
+		if delErr := s.storage.Delete(ctx, key); delErr != nil {
+			s.log.WithError(delErr).WithField("storage_key", key).Warn("Failed to remove orphaned courier document blob")
+		}
+		return nil, err
+	}
+
+	s.log.WithFields(map[string]interface{}{
+		"courier_id":          courierID,
+		"document_id":         docID,
+		"document_type":       docType,
+		"size_bytes":          size,
+		"documents_submitted": submitted,
+	}).Info("Courier document uploaded")
+
+	return doc, nil
+}
+
+// saveDocument записывает документ и, если комплект собран, переводит курьера из applied
+// в documents_submitted. Возвращает true, если этап сменился.
+func (s *CourierOnboardingService) saveDocument(ctx context.Context, doc *models.CourierDocument) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var (
+		status  models.OnboardingStatus
+		vehicle models.VehicleType
+	)
+	err = tx.QueryRowContext(ctx, "SELECT onboarding_status, vehicle_type FROM couriers WHERE id = $1 FOR UPDATE", doc.CourierID).Scan(&status, &vehicle)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return false, apperror.NotFound("courier not found", err)
+		}
+		return false, fmt.Errorf("failed to lock courier: %w", err)
+	}
+	if status == models.OnboardingStatusDeactivated {
+		return false, apperror.Conflict("courier is deactivated", nil)
+	}
+
+	query := `
+		INSERT INTO courier_documents (id, courier_id, doc_type, storage_key, content_type, size_bytes, expires_at, uploaded_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	if _, err := tx.ExecContext(ctx, query, doc.ID, doc.CourierID, doc.Type, doc.StorageKey, doc.ContentType, doc.SizeBytes, doc.ExpiresAt, doc.UploadedAt); err != nil {
+		return false, fmt.Errorf("failed to save courier document: %w", err)
+	}
+
+	submitted := false
+	if status == models.OnboardingStatusApplied {
+		missing, err := s.missingDocumentsWithTx(ctx, tx, doc.CourierID, vehicle)
+		if err != nil {
+			return false, err
+		}
+		if len(missing) == 0 {
+			if _, err := tx.ExecContext(ctx, "UPDATE couriers SET onboarding_status = $1, onboarding_reason = NULL, updated_at = $2 WHERE id = $3",
+				models.OnboardingStatusDocumentsSubmitted, s.now(), doc.CourierID); err != nil {
+				return false, fmt.Errorf("failed to update onboarding status: %w", err)
+			}
+			submitted = true
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit courier document: %w", err)
+	}
+	return submitted, nil
+}
+
+// missingDocumentsWithTx возвращает обязательные документы, которых у курьера нет или у которых
+// истек срок действия. Учитывается только последняя загрузка каждого вида.
+func (s *CourierOnboardingService) missingDocumentsWithTx(ctx context.Context, tx *sql.Tx, courierID uuid.UUID, vehicle models.VehicleType) ([]models.DocumentType, error) {
+	query := `
+		SELECT DISTINCT ON (doc_type) doc_type, expires_at
+		FROM courier_documents
+		WHERE courier_id = $1
+		ORDER BY doc_type, uploaded_at DESC
+	`
+	rows, err := tx.QueryContext(ctx, query, courierID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get courier documents: %w", err)
+	}
+	defer rows.Close()
+
+	now := s.now()
+	valid := make(map[models.DocumentType]bool)
+	for rows.Next() {
+		var (
+			docType   models.DocumentType
+			expiresAt *time.Time
+		)
+		if err := rows.Scan(&docType, &expiresAt); err != nil {
+			return nil, fmt.Errorf("failed to scan courier document: %w", err)
+		}
+		valid[docType] = expiresAt == nil || expiresAt.After(now)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate courier documents: %w", err)
+	}
+
+	var missing []models.DocumentType
+	for _, required := range models.RequiredDocuments(vehicle) {
+		if !valid[required] {
+			missing = append(missing, required)
+		}
+	}
+	return missing, nil
+}
+
+// ListDocuments возвращает документы курьера в порядке загрузки.
+func (s *CourierOnboardingService) ListDocuments(ctx context.Context, courierID uuid.UUID) ([]*models.CourierDocument, error) {
+	query := `SELECT ` + courierDocumentColumns + ` FROM courier_documents WHERE courier_id = $1 ORDER BY uploaded_at ASC`
+
+	rows, err := s.db.QueryContext(ctx, query, courierID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list courier documents: %w", err)
+	}
+	defer rows.Close()
+
+	docs := []*models.CourierDocument{}
+	for rows.Next() {
+		doc, err := scanCourierDocument(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan courier document: %w", err)
+		}
+		docs = append(docs, doc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate courier documents: %w", err)
+	}
+
+	return docs, nil
+}
+
+// OpenDocument возвращает метаданные документа и поток с его содержимым.
+func (s *CourierOnboardingService) OpenDocument(ctx context.Context, courierID, documentID uuid.UUID) (*models.CourierDocument, io.ReadCloser, error) {
+	query := `SELECT ` + courierDocumentColumns + ` FROM courier_documents WHERE id = $1 AND courier_id = $2`
+
+	doc, err := scanCourierDocument(s.db.QueryRowContext(ctx, query, documentID, courierID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, apperror.NotFound("courier document not found", err)
+		}
+		return nil, nil, fmt.Errorf("failed to get courier document: %w", err)
+	}
+
+	rc, err := s.storage.Open(ctx, doc.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, nil, apperror.NotFound("courier document content not found", err)
+		}
+		return nil, nil, fmt.Errorf("failed to open courier document: %w", err)
+	}
+
+	return doc, rc, nil
+}
+
+// ListExpiringDocuments возвращает действующие версии документов, срок которых истекает до before
+// (включая уже истекшие), чтобы заранее запросить у курьеров новые. Отключенные курьеры не учитываются.
+func (s *CourierOnboardingService) ListExpiringDocuments(ctx context.Context, before time.Time) ([]*models.CourierDocument, error) {
+	query := `
+		SELECT ` + courierDocumentColumns + `
+		FROM (
+			SELECT DISTINCT ON (d.courier_id, d.doc_type) d.*
+			FROM courier_documents d
+			JOIN couriers c ON c.id = d.courier_id
+			WHERE c.onboarding_status <> $1
+			ORDER BY d.courier_id, d.doc_type, d.uploaded_at DESC
+		) latest
+		WHERE expires_at IS NOT NULL AND expires_at < $2
+		ORDER BY expires_at ASC, id ASC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, models.OnboardingStatusDeactivated, before)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list expiring courier documents: %w", err)
+	}
+	defer rows.Close()
+
+	docs := []*models.CourierDocument{}
+	for rows.Next() {
+		doc, err := scanCourierDocument(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan courier document: %w", err)
+		}
+		docs = append(docs, doc)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate courier documents: %w", err)
+	}
+
+	return docs, nil
+}
+
+// UpdateOnboarding переводит курьера на другой этап подключения. Подтвердить курьера можно только
+// с полным комплектом действующих документов. При приостановке и отключении курьер уходит в offline;
+// курьера с незавершенными заказами сначала нужно с них снять.
+func (s *CourierOnboardingService) UpdateOnboarding(ctx context.Context, courierID uuid.UUID, req *models.UpdateOnboardingRequest) (*models.OnboardingChange, error) {
+	if actor.FromContext(ctx).Type == actor.TypeCourier {
+		return nil, apperror.Forbidden("couriers cannot change onboarding status", nil)
+	}
+	if !req.Status.IsValid() {
+		return nil, apperror.Validation("invalid onboarding status", nil)
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if len([]rune(reason)) > models.MaxOnboardingReasonLength {
+		return nil, apperror.Validation(fmt.Sprintf("reason must be at most %d characters", models.MaxOnboardingReasonLength), nil)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var (
+		oldStatus     models.OnboardingStatus
+		vehicle       models.VehicleType
+		courierStatus models.CourierStatus
+	)
+	err = tx.QueryRowContext(ctx, "SELECT onboarding_status, vehicle_type, status FROM couriers WHERE id = $1 FOR UPDATE", courierID).
+		Scan(&oldStatus, &vehicle, &courierStatus)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperror.NotFound("courier not found", err)
+		}
+		return nil, fmt.Errorf("failed to lock courier: %w", err)
+	}
+	oldCourierStatus := courierStatus
+
+	if !oldStatus.CanTransitionTo(req.Status) {
+		return nil, apperror.Conflict(fmt.Sprintf("cannot change onboarding status from %s to %s", oldStatus, req.Status), nil)
+	}
+	if req.Status.RequiresReason(oldStatus) && reason == "" {
+		return nil, apperror.Validation("reason is required", nil)
+	}
+
+	switch req.Status {
+	case models.OnboardingStatusVerified:
+		missing, err := s.missingDocumentsWithTx(ctx, tx, courierID, vehicle)
+		if err != nil {
+			return nil, err
+		}
+		if len(missing) > 0 {
+			names := make([]string, len(missing))
+			for i, docType := range missing {
+				names[i] = string(docType)
+			}
+			return nil, apperror.Conflict("missing or expired documents: "+strings.Join(names, ", "), nil)
+		}
+	case models.OnboardingStatusSuspended, models.OnboardingStatusDeactivated:
+		terminal := make([]string, len(s.flow.TerminalStatuses()))
+		for i, status := range s.flow.TerminalStatuses() {
+			terminal[i] = string(status)
+		}
+		var active bool
+		activeQuery := `SELECT EXISTS (SELECT 1 FROM orders WHERE courier_id = $1 AND status <> ALL($2))`
+		if err := tx.QueryRowContext(ctx, activeQuery, courierID, pq.Array(terminal)).Scan(&active); err != nil {
+			return nil, fmt.Errorf("failed to check active orders: %w", err)
+		}
+		if active {
+			return nil, apperror.Conflict("courier has active orders, reassign them first", nil)
+		}
+		courierStatus = models.CourierStatusOffline
+	}
+
+	var reasonPtr *string
+	if reason != "" {
+		reasonPtr = &reason
+	}
+
+	now := s.now()
+	var verifiedAt *time.Time
+	if req.Status == models.OnboardingStatusVerified {
+		verifiedAt = &now
+	}
+
+	query := `
+		UPDATE couriers
+		SET onboarding_status = $1, onboarding_reason = $2, status = $3, updated_at = $4,
+		    verified_at = COALESCE($5, verified_at)
+		WHERE id = $6
+	`
+	if _, err := tx.ExecContext(ctx, query, req.Status, reasonPtr, courierStatus, now, verifiedAt, courierID); err != nil {
+		return nil, fmt.Errorf("failed to update onboarding status: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit onboarding status: %w", err)
+	}
+
+	s.log.WithFields(map[string]interface{}{
+		"courier_id": courierID,
+		"old_status": oldStatus,
+		"new_status": req.Status,
+		"reason":     reason,
+		"actor":      actor.FromContext(ctx).String(),
+	}).Info("Courier onboarding status changed")
+
+	return &models.OnboardingChange{
+		CourierID:        courierID,
+		OldStatus:        oldStatus,
+		Status:           req.Status,
+		Reason:           reasonPtr,
+		OldCourierStatus: oldCourierStatus,
+		CourierStatus:    courierStatus,
+	}, nil
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"delivery-system/internal/actor"
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+func TestCourierOnboarding_UploadDocument_CompletesSubmission(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	blobs := newTestBlobStorage(t)
+	service := NewCourierOnboardingService(db, blobs, nil, newTestLogger())
+	courierID := uuid.New()
+
+	mock.ExpectQuery("SELECT onboarding_status FROM couriers").
+		WithArgs(courierID).
+		WillReturnRows(sqlmock.NewRows([]string{"onboarding_status"}).AddRow(models.OnboardingStatusApplied))
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT onboarding_status, vehicle_type FROM couriers WHERE id = \\$1 FOR UPDATE").
+		WithArgs(courierID).
+		WillReturnRows(sqlmock.NewRows([]string{"onboarding_status", "vehicle_type"}).AddRow(models.OnboardingStatusApplied, models.VehicleTypeBike))
+	mock.ExpectExec("INSERT INTO courier_documents").
+		WithArgs(sqlmock.AnyArg(), courierID, models.DocumentTypeIdentity, sqlmock.AnyArg(), sqlmock.AnyArg(), int64(8), nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT DISTINCT ON \\(doc_type\\) doc_type, expires_at").
+		WithArgs(courierID).
+		WillReturnRows(sqlmock.NewRows([]string{"doc_type", "expires_at"}).AddRow(models.DocumentTypeIdentity, nil))
+	mock.ExpectExec("UPDATE couriers SET onboarding_status").
+		WithArgs(models.OnboardingStatusDocumentsSubmitted, sqlmock.AnyArg(), courierID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	ctx := actor.WithContext(context.Background(), actor.Actor{Type: actor.TypeCourier, ID: courierID.String()})
+	doc, err := service.UploadDocument(ctx, courierID, models.DocumentTypeIdentity, "image/jpeg", nil, strings.NewReader("passport"))
+	if err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+
+	rc, err := blobs.Open(context.Background(), doc.StorageKey)
+	if err != nil {
+		t.Fatalf("expected stored blob, got %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	if string(data) != "passport" {
+		t.Fatalf("unexpected blob content: %q", data)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCourierOnboarding_UploadDocument_Validation(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewCourierOnboardingService(db, newTestBlobStorage(t), nil, newTestLogger())
+	courierID := uuid.New()
+	expired := time.Now().AddDate(0, 0, -1)
+
+	if _, err := service.UploadDocument(context.Background(), courierID, models.DocumentTypeDriverLicense, "", nil, strings.NewReader("x")); !apperror.Is(err, apperror.KindValidation) {
+		t.Fatalf("expected validation error for licence without expiry, got %v", err)
+	}
+	if _, err := service.UploadDocument(context.Background(), courierID, models.DocumentTypeInsurance, "", &expired, strings.NewReader("x")); !apperror.Is(err, apperror.KindValidation) {
+		t.Fatalf("expected validation error for expired document, got %v", err)
+	}
+	if _, err := service.UploadDocument(context.Background(), courierID, "passport", "", nil, strings.NewReader("x")); !apperror.Is(err, apperror.KindValidation) {
+		t.Fatalf("expected validation error for unknown type, got %v", err)
+	}
+
+	other := actor.WithContext(context.Background(), actor.Actor{Type: actor.TypeCourier, ID: uuid.NewString()})
+	if _, err := service.UploadDocument(other, courierID, models.DocumentTypeIdentity, "", nil, strings.NewReader("x")); !apperror.Is(err, apperror.KindForbidden) {
+		t.Fatalf("expected forbidden for another courier, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCourierOnboarding_Verify_MissingDocuments(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewCourierOnboardingService(db, newTestBlobStorage(t), nil, newTestLogger())
+	courierID := uuid.New()
+	expired := time.Now().AddDate(0, 0, -3)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT onboarding_status, vehicle_type, status FROM couriers WHERE id = \\$1 FOR UPDATE").
+		WithArgs(courierID).
+		WillReturnRows(sqlmock.NewRows([]string{"onboarding_status", "vehicle_type", "status"}).
+			AddRow(models.OnboardingStatusDocumentsSubmitted, models.VehicleTypeCar, models.CourierStatusOffline))
+	mock.ExpectQuery("SELECT DISTINCT ON \\(doc_type\\) doc_type, expires_at").
+		WithArgs(courierID).
+		WillReturnRows(sqlmock.NewRows([]string{"doc_type", "expires_at"}).
+			AddRow(models.DocumentTypeDriverLicense, expired).
+			AddRow(models.DocumentTypeIdentity, nil))
+	mock.ExpectRollback()
+
+	_, err := service.UpdateOnboarding(context.Background(), courierID, &models.UpdateOnboardingRequest{Status: models.OnboardingStatusVerified})
+	if !apperror.Is(err, apperror.KindConflict) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "driver_license, insurance") {
+		t.Fatalf("expected missing documents in error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCourierOnboarding_Verify_Success(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewCourierOnboardingService(db, newTestBlobStorage(t), nil, newTestLogger())
+	courierID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT onboarding_status, vehicle_type, status FROM couriers").
+		WithArgs(courierID).
+		WillReturnRows(sqlmock.NewRows([]string{"onboarding_status", "vehicle_type", "status"}).
+			AddRow(models.OnboardingStatusDocumentsSubmitted, models.VehicleTypeBike, models.CourierStatusOffline))
+	mock.ExpectQuery("SELECT DISTINCT ON \\(doc_type\\) doc_type, expires_at").
+		WithArgs(courierID).
+		WillReturnRows(sqlmock.NewRows([]string{"doc_type", "expires_at"}).AddRow(models.DocumentTypeIdentity, nil))
+	mock.ExpectExec("UPDATE couriers").
+		WithArgs(models.OnboardingStatusVerified, nil, models.CourierStatusOffline, sqlmock.AnyArg(), sqlmock.AnyArg(), courierID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	change, err := service.UpdateOnboarding(context.Background(), courierID, &models.UpdateOnboardingRequest{Status: models.OnboardingStatusVerified})
+	if err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+	if change.OldStatus != models.OnboardingStatusDocumentsSubmitted || change.Status != models.OnboardingStatusVerified {
+		t.Fatalf("unexpected change: %+v", change)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCourierOnboarding_Suspend(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewCourierOnboardingService(db, newTestBlobStorage(t), nil, newTestLogger())
+	courierID := uuid.New()
+	req := &models.UpdateOnboardingRequest{Status: models.OnboardingStatusSuspended, Reason: " licence expired "}
+
+	// Без причины приостановить нельзя
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT onboarding_status, vehicle_type, status FROM couriers").
+		WithArgs(courierID).
+		WillReturnRows(sqlmock.NewRows([]string{"onboarding_status", "vehicle_type", "status"}).
+			AddRow(models.OnboardingStatusVerified, models.VehicleTypeCar, models.CourierStatusAvailable))
+	mock.ExpectRollback()
+	if _, err := service.UpdateOnboarding(context.Background(), courierID, &models.UpdateOnboardingRequest{Status: models.OnboardingStatusSuspended}); !apperror.Is(err, apperror.KindValidation) {
+		t.Fatalf("expected validation error without reason, got %v", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT onboarding_status, vehicle_type, status FROM couriers").
+		WithArgs(courierID).
+		WillReturnRows(sqlmock.NewRows([]string{"onboarding_status", "vehicle_type", "status"}).
+			AddRow(models.OnboardingStatusVerified, models.VehicleTypeCar, models.CourierStatusAvailable))
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(courierID, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec("UPDATE couriers").
+		WithArgs(models.OnboardingStatusSuspended, sqlmock.AnyArg(), models.CourierStatusOffline, sqlmock.AnyArg(), nil, courierID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	change, err := service.UpdateOnboarding(context.Background(), courierID, req)
+	if err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+	if change.OldCourierStatus != models.CourierStatusAvailable || change.CourierStatus != models.CourierStatusOffline {
+		t.Fatalf("expected courier to go offline, got %+v", change)
+	}
+	if change.Reason == nil || *change.Reason != "licence expired" {
+		t.Fatalf("unexpected reason: %v", change.Reason)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCourierOnboarding_Suspend_ActiveOrders(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewCourierOnboardingService(db, newTestBlobStorage(t), nil, newTestLogger())
+	courierID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT onboarding_status, vehicle_type, status FROM couriers").
+		WithArgs(courierID).
+		WillReturnRows(sqlmock.NewRows([]string{"onboarding_status", "vehicle_type", "status"}).
+			AddRow(models.OnboardingStatusVerified, models.VehicleTypeBike, models.CourierStatusBusy))
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(courierID, sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	_, err := service.UpdateOnboarding(context.Background(), courierID, &models.UpdateOnboardingRequest{Status: models.OnboardingStatusDeactivated, Reason: "left"})
+	if !apperror.Is(err, apperror.KindConflict) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCourierOnboarding_UpdateOnboarding_Rules(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewCourierOnboardingService(db, newTestBlobStorage(t), nil, newTestLogger())
+	courierID := uuid.New()
+
+	courier := actor.WithContext(context.Background(), actor.Actor{Type: actor.TypeCourier, ID: courierID.String()})
+	if _, err := service.UpdateOnboarding(courier, courierID, &models.UpdateOnboardingRequest{Status: models.OnboardingStatusVerified}); !apperror.Is(err, apperror.KindForbidden) {
+		t.Fatalf("expected forbidden for courier actor, got %v", err)
+	}
+
+	// Из deactivated переходов нет
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT onboarding_status, vehicle_type, status FROM couriers").
+		WithArgs(courierID).
+		WillReturnRows(sqlmock.NewRows([]string{"onboarding_status", "vehicle_type", "status"}).
+			AddRow(models.OnboardingStatusDeactivated, models.VehicleTypeBike, models.CourierStatusOffline))
+	mock.ExpectRollback()
+	if _, err := service.UpdateOnboarding(context.Background(), courierID, &models.UpdateOnboardingRequest{Status: models.OnboardingStatusVerified}); !apperror.Is(err, apperror.KindConflict) {
+		t.Fatalf("expected conflict for deactivated courier, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestOnboardingStatus_Transitions(t *testing.T) {
+	cases := []struct {
+		from, to models.OnboardingStatus
+		allowed  bool
+	}{
+		{models.OnboardingStatusApplied, models.OnboardingStatusDocumentsSubmitted, true},
+		{models.OnboardingStatusApplied, models.OnboardingStatusVerified, false},
+		{models.OnboardingStatusDocumentsSubmitted, models.OnboardingStatusApplied, true},
+		{models.OnboardingStatusVerified, models.OnboardingStatusSuspended, true},
+		{models.OnboardingStatusSuspended, models.OnboardingStatusVerified, true},
+		{models.OnboardingStatusDeactivated, models.OnboardingStatusApplied, false},
+	}
+	for _, c := range cases {
+		if got := c.from.CanTransitionTo(c.to); got != c.allowed {
+			t.Errorf("%s -> %s: expected %v, got %v", c.from, c.to, c.allowed, got)
+		}
+	}
+
+	if !models.OnboardingStatusApplied.RequiresReason(models.OnboardingStatusDocumentsSubmitted) {
+		t.Fatalf("expected rejection to require a reason")
+	}
+	if models.OnboardingStatusVerified.RequiresReason(models.OnboardingStatusSuspended) {
+		t.Fatalf("expected reinstatement without a reason")
+	}
+}
@@ -0,0 +1,303 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/database"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// CourierPerformanceService ведет оценку работы курьеров. Счетчики обновляются по событиям заказов
+// из Kafka, после каждого события в историю пишется снимок оценки.
+type CourierPerformanceService struct {
+	db  *database.DB
+	log *logger.Logger
+}
+
+// NewCourierPerformanceService создает сервис оценки работы курьеров
+func NewCourierPerformanceService(db *database.DB, log *logger.Logger) *CourierPerformanceService {
+	return &CourierPerformanceService{
+		db:  db,
+		log: log,
+	}
+}
+
+// performanceUpdate изменяет счетчики курьера по одному событию внутри транзакции обработки.
+type performanceUpdate func(ctx context.Context, tx *sql.Tx, p *models.CourierPerformance, vehicle models.VehicleType) error
+
+// HandleEvent учитывает событие заказа в оценке курьера: назначение, снятие с заказа, доставку и отмену.
+// События доставляются как минимум один раз, повторное событие с тем же ID игнорируется.
+func (s *CourierPerformanceService) HandleEvent(ctx context.Context, event *models.Event) error {
+	switch event.Type {
+	case models.EventTypeCourierAssigned:
+		var data models.CourierAssignedEvent
+		if err := decodeEventData(event, &data); err != nil {
+			return err
+		}
+		return s.recordEvent(ctx, event, data.CourierID, data.OrderID, func(_ context.Context, _ *sql.Tx, p *models.CourierPerformance, _ models.VehicleType) error {
+			p.AssignmentsOffered++
+			return nil
+		})
+
+	case models.EventTypeCourierUnassigned:
+		var data models.CourierUnassignedEvent
+		if err := decodeEventData(event, &data); err != nil {
+			return err
+		}
+		return s.recordEvent(ctx, event, data.CourierID, data.OrderID, func(_ context.Context, _ *sql.Tx, p *models.CourierPerformance, _ models.VehicleType) error {
+			p.AssignmentsDropped++
+			return nil
+		})
+
+	case models.EventTypeOrderStatusChanged:
+		var data models.OrderStatusChangedEvent
+		if err := decodeEventData(event, &data); err != nil {
+			return err
+		}
+		if data.CourierID == nil {
+			return nil
+		}
+		switch data.NewStatus {
+		case models.OrderStatusDelivered:
+			return s.recordEvent(ctx, event, *data.CourierID, data.OrderID, func(ctx context.Context, tx *sql.Tx, p *models.CourierPerformance, vehicle models.VehicleType) error {
+				p.OrdersDelivered++
+				actual, eta, ok, err := s.deliveryTimingWithTx(ctx, tx, data.OrderID, vehicle)
+				if err != nil || !ok {
+					return err
+				}
+				p.TimedDeliveries++
+				p.DeliveryMinutes += actual
+				p.EtaMinutes += eta
+				if actual <= eta {
+					p.OnTimeDeliveries++
+				}
+				return nil
+			})
+		case models.OrderStatusCancelled:
+			return s.recordEvent(ctx, event, *data.CourierID, data.OrderID, func(_ context.Context, _ *sql.Tx, p *models.CourierPerformance, _ models.VehicleType) error {
+				p.OrdersCancelled++
+				return nil
+			})
+		}
+	}
+
+	return nil
+}
+
+// decodeEventData разбирает данные события в типизированную структуру: после чтения из Kafka
+// они приходят как map[string]interface{}.
+func decodeEventData(event *models.Event, dst interface{}) error {
+	raw, err := json.Marshal(event.Data)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s event data: %w", event.Type, err)
+	}
+	if err := json.Unmarshal(raw, dst); err != nil {
+		return fmt.Errorf("failed to decode %s event data: %w", event.Type, err)
+	}
+	return nil
+}
+
+// recordEvent применяет update к счетчикам курьера, пересчитывает оценку и пишет снимок в историю.
+// Если событие уже обработано, транзакция откатывается без изменений.
+func (s *CourierPerformanceService) recordEvent(ctx context.Context, event *models.Event, courierID, orderID uuid.UUID, update performanceUpdate) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var (
+		rating  float64
+		reviews int
+		vehicle models.VehicleType
+	)
+	err = tx.QueryRowContext(ctx, "SELECT rating, total_reviews, vehicle_type FROM couriers WHERE id = $1", courierID).Scan(&rating, &reviews, &vehicle)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			// Курьер удален: повторная доставка события ничего не изменит
+			s.log.WithFields(map[string]interface{}{
+				"event_id":   event.ID,
+				"courier_id": courierID,
+			}).Warn("Skipping performance event for unknown courier")
+			return nil
+		}
+		return fmt.Errorf("failed to get courier rating: %w", err)
+	}
+
+	perf, err := s.lockPerformanceWithTx(ctx, tx, courierID, rating, reviews)
+	if err != nil {
+		return err
+	}
+	if err := update(ctx, tx, perf, vehicle); err != nil {
+		return err
+	}
+	score := perf.ComputeScore(rating, reviews)
+
+	historyQuery := `
+		INSERT INTO courier_performance_history (event_id, courier_id, event_type, order_id, acceptance_rate, cancellation_rate,
+			on_time_rate, eta_ratio, bayes_rating, score, recorded_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, NOW())
+		ON CONFLICT (event_id) DO NOTHING
+	`
+	result, err := tx.ExecContext(ctx, historyQuery, event.ID, courierID, event.Type, orderID,
+		score.AcceptanceRate, score.CancelRate, score.OnTimeRate, score.EtaRatio, score.BayesRating, score.Score)
+	if err != nil {
+		return fmt.Errorf("failed to record performance history: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		s.log.WithField("event_id", event.ID).Debug("Performance event already processed")
+		return nil
+	}
+
+	updateQuery := `
+		UPDATE courier_performance
+		SET assignments_offered = $1, assignments_dropped = $2, orders_delivered = $3, orders_cancelled = $4,
+			timed_deliveries = $5, on_time_deliveries = $6, delivery_minutes = $7, eta_minutes = $8,
+			score = $9, updated_at = NOW()
+		WHERE courier_id = $10
+	`
+	if _, err := tx.ExecContext(ctx, updateQuery, perf.AssignmentsOffered, perf.AssignmentsDropped, perf.OrdersDelivered, perf.OrdersCancelled,
+		perf.TimedDeliveries, perf.OnTimeDeliveries, perf.DeliveryMinutes, perf.EtaMinutes, score.Score, courierID); err != nil {
+		return fmt.Errorf("failed to update courier performance: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit courier performance: %w", err)
+	}
+
+	s.log.WithFields(map[string]interface{}{
+		"event_id":   event.ID,
+		"event_type": event.Type,
+		"courier_id": courierID,
+		"score":      score.Score,
+	}).Debug("Courier performance updated")
+
+	return nil
+}
+
+// lockPerformanceWithTx блокирует счетчики курьера, создавая строку с априорной оценкой при первом событии.
+func (s *CourierPerformanceService) lockPerformanceWithTx(ctx context.Context, tx *sql.Tx, courierID uuid.UUID, rating float64, reviews int) (*models.CourierPerformance, error) {
+	initial := (&models.CourierPerformance{}).ComputeScore(rating, reviews)
+	if _, err := tx.ExecContext(ctx, "INSERT INTO courier_performance (courier_id, score) VALUES ($1, $2) ON CONFLICT (courier_id) DO NOTHING", courierID, initial.Score); err != nil {
+		return nil, fmt.Errorf("failed to init courier performance: %w", err)
+	}
+
+	query := `
+		SELECT assignments_offered, assignments_dropped, orders_delivered, orders_cancelled,
+			timed_deliveries, on_time_deliveries, delivery_minutes, eta_minutes
+		FROM courier_performance
+		WHERE courier_id = $1
+		FOR UPDATE
+	`
+	p := &models.CourierPerformance{CourierID: courierID}
+	err := tx.QueryRowContext(ctx, query, courierID).Scan(&p.AssignmentsOffered, &p.AssignmentsDropped, &p.OrdersDelivered, &p.OrdersCancelled,
+		&p.TimedDeliveries, &p.OnTimeDeliveries, &p.DeliveryMinutes, &p.EtaMinutes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock courier performance: %w", err)
+	}
+	return p, nil
+}
+
+// deliveryTimingWithTx возвращает фактическое и расчетное время доставки в минутах: от забора заказа
+// до вручения. ok = false, если у заказа нет координат или отметок времени.
+func (s *CourierPerformanceService) deliveryTimingWithTx(ctx context.Context, tx *sql.Tx, orderID uuid.UUID, vehicle models.VehicleType) (actual, eta float64, ok bool, err error) {
+	query := `
+		SELECT o.pickup_lat, o.pickup_lon, o.delivery_lat, o.delivery_lon, o.delivered_at,
+			(SELECT MAX(h.changed_at) FROM order_status_history h WHERE h.order_id = o.id AND h.new_status = $2)
+		FROM orders o
+		WHERE o.id = $1
+	`
+	var (
+		pickupLat, pickupLon, deliveryLat, deliveryLon *float64
+		deliveredAt, pickedUpAt                        sql.NullTime
+	)
+	err = tx.QueryRowContext(ctx, query, orderID, models.OrderStatusPickedUp).Scan(&pickupLat, &pickupLon, &deliveryLat, &deliveryLon, &deliveredAt, &pickedUpAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return 0, 0, false, nil
+		}
+		return 0, 0, false, fmt.Errorf("failed to get delivery timing: %w", err)
+	}
+	if pickupLat == nil || pickupLon == nil || deliveryLat == nil || deliveryLon == nil || !deliveredAt.Valid || !pickedUpAt.Valid {
+		return 0, 0, false, nil
+	}
+
+	actual = deliveredAt.Time.Sub(pickedUpAt.Time).Minutes()
+	if actual < 0 {
+		return 0, 0, false, nil
+	}
+	eta = models.EstimateDeliveryMinutes(vehicle, calculateDistance(*pickupLat, *pickupLon, *deliveryLat, *deliveryLon))
+	return actual, eta, true, nil
+}
+
+// GetPerformance возвращает показатели курьера с оценкой по текущему рейтингу и последние historyLimit
+// записей истории. Курьер без событий получает априорную оценку.
+func (s *CourierPerformanceService) GetPerformance(ctx context.Context, courierID uuid.UUID, historyLimit int) (*models.CourierPerformanceReport, error) {
+	var (
+		rating  float64
+		reviews int
+	)
+	err := s.db.QueryRowContext(ctx, "SELECT rating, total_reviews FROM couriers WHERE id = $1", courierID).Scan(&rating, &reviews)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperror.NotFound("courier not found", err)
+		}
+		return nil, fmt.Errorf("failed to get courier: %w", err)
+	}
+
+	report := &models.CourierPerformanceReport{History: []*models.PerformanceSnapshot{}}
+	p := &report.CourierPerformance
+	p.CourierID = courierID
+
+	query := `
+		SELECT assignments_offered, assignments_dropped, orders_delivered, orders_cancelled,
+			timed_deliveries, on_time_deliveries, delivery_minutes, eta_minutes, updated_at
+		FROM courier_performance
+		WHERE courier_id = $1
+	`
+	err = s.db.QueryRowContext(ctx, query, courierID).Scan(&p.AssignmentsOffered, &p.AssignmentsDropped, &p.OrdersDelivered, &p.OrdersCancelled,
+		&p.TimedDeliveries, &p.OnTimeDeliveries, &p.DeliveryMinutes, &p.EtaMinutes, &p.UpdatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get courier performance: %w", err)
+	}
+	p.PerformanceScore = p.ComputeScore(rating, reviews)
+
+	if historyLimit <= 0 {
+		return report, nil
+	}
+
+	historyQuery := `
+		SELECT event_id, event_type, order_id, acceptance_rate, cancellation_rate, on_time_rate,
+			eta_ratio, bayes_rating, score, recorded_at
+		FROM courier_performance_history
+		WHERE courier_id = $1
+		ORDER BY recorded_at DESC
+		LIMIT $2
+	`
+	rows, err := s.db.QueryContext(ctx, historyQuery, courierID, historyLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get performance history: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		h := &models.PerformanceSnapshot{}
+		if err := rows.Scan(&h.EventID, &h.EventType, &h.OrderID, &h.AcceptanceRate, &h.CancelRate, &h.OnTimeRate,
+			&h.EtaRatio, &h.BayesRating, &h.Score, &h.RecordedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan performance history: %w", err)
+		}
+		report.History = append(report.History, h)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate performance history: %w", err)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,176 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+var performanceCounterColumns = []string{
+	"assignments_offered", "assignments_dropped", "orders_delivered", "orders_cancelled",
+	"timed_deliveries", "on_time_deliveries", "delivery_minutes", "eta_minutes",
+}
+
+func expectPerformanceLock(mock sqlmock.Sqlmock, courierID uuid.UUID, vehicle models.VehicleType) {
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT rating, total_reviews, vehicle_type FROM couriers").
+		WithArgs(courierID).
+		WillReturnRows(sqlmock.NewRows([]string{"rating", "total_reviews", "vehicle_type"}).AddRow(4.8, 20, vehicle))
+	mock.ExpectExec("INSERT INTO courier_performance \\(courier_id, score\\)").
+		WithArgs(courierID, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT assignments_offered, .* FROM courier_performance\\s+WHERE courier_id = \\$1\\s+FOR UPDATE").
+		WithArgs(courierID).
+		WillReturnRows(sqlmock.NewRows(performanceCounterColumns).AddRow(10, 1, 8, 1, 4, 3, 100.0, 110.0))
+}
+
+func TestCourierPerformance_HandleEvent_DeliveredOnTime(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewCourierPerformanceService(db, newTestLogger())
+	courierID := uuid.New()
+	orderID := uuid.New()
+	eventID := uuid.New()
+	deliveredAt := time.Now()
+
+	expectPerformanceLock(mock, courierID, models.VehicleTypeCar)
+	// ~3.3 км на машине: расчетно ~7 мин езды + 5 мин на передачу, фактически 8 минут
+	mock.ExpectQuery("SELECT o.pickup_lat, o.pickup_lon, o.delivery_lat, o.delivery_lon, o.delivered_at").
+		WithArgs(orderID, models.OrderStatusPickedUp).
+		WillReturnRows(sqlmock.NewRows([]string{"pickup_lat", "pickup_lon", "delivery_lat", "delivery_lon", "delivered_at", "picked_up_at"}).
+			AddRow(55.75, 37.60, 55.78, 37.60, deliveredAt, deliveredAt.Add(-8*time.Minute)))
+	mock.ExpectExec("INSERT INTO courier_performance_history").
+		WithArgs(eventID, courierID, models.EventTypeOrderStatusChanged, orderID,
+			sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE courier_performance").
+		WithArgs(10, 1, 9, 1, 5, 4, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), courierID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	// Данные события приходят из Kafka в виде map после разбора JSON
+	event := &models.Event{
+		ID:   eventID,
+		Type: models.EventTypeOrderStatusChanged,
+		Data: map[string]interface{}{
+			"order_id":   orderID.String(),
+			"old_status": string(models.OrderStatusInDelivery),
+			"new_status": string(models.OrderStatusDelivered),
+			"courier_id": courierID.String(),
+		},
+	}
+	if err := service.HandleEvent(context.Background(), event); err != nil {
+		t.Fatalf("HandleEvent returned error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCourierPerformance_HandleEvent_DuplicateIgnored(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewCourierPerformanceService(db, newTestLogger())
+	courierID := uuid.New()
+	orderID := uuid.New()
+
+	expectPerformanceLock(mock, courierID, models.VehicleTypeBike)
+	mock.ExpectExec("INSERT INTO courier_performance_history").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	event := &models.Event{
+		ID:   uuid.New(),
+		Type: models.EventTypeCourierUnassigned,
+		Data: models.CourierUnassignedEvent{OrderID: orderID, CourierID: courierID, Reason: "courier is sick"},
+	}
+	if err := service.HandleEvent(context.Background(), event); err != nil {
+		t.Fatalf("HandleEvent returned error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCourierPerformance_HandleEvent_SkipsIrrelevant(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewCourierPerformanceService(db, newTestLogger())
+	courierID := uuid.New()
+
+	events := []*models.Event{
+		{ID: uuid.New(), Type: models.EventTypeOrderStatusChanged, Data: models.OrderStatusChangedEvent{OrderID: uuid.New(), NewStatus: models.OrderStatusCancelled}},
+		{ID: uuid.New(), Type: models.EventTypeOrderStatusChanged, Data: models.OrderStatusChangedEvent{OrderID: uuid.New(), NewStatus: models.OrderStatusPreparing, CourierID: &courierID}},
+		{ID: uuid.New(), Type: models.EventTypeOrderCreated, Data: models.OrderCreatedEvent{OrderID: uuid.New()}},
+	}
+	for _, event := range events {
+		if err := service.HandleEvent(context.Background(), event); err != nil {
+			t.Fatalf("HandleEvent returned error: %v", err)
+		}
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCourierPerformance_GetPerformance_PriorForNewCourier(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewCourierPerformanceService(db, newTestLogger())
+	courierID := uuid.New()
+
+	mock.ExpectQuery("SELECT rating, total_reviews FROM couriers").
+		WithArgs(courierID).
+		WillReturnRows(sqlmock.NewRows([]string{"rating", "total_reviews"}).AddRow(5.0, 1))
+	mock.ExpectQuery("SELECT assignments_offered, .* FROM courier_performance").
+		WithArgs(courierID).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery("SELECT event_id, event_type, order_id").
+		WithArgs(courierID, 20).
+		WillReturnRows(sqlmock.NewRows([]string{"event_id", "event_type", "order_id", "acceptance_rate", "cancellation_rate", "on_time_rate", "eta_ratio", "bayes_rating", "score", "recorded_at"}))
+
+	report, err := service.GetPerformance(context.Background(), courierID, 20)
+	if err != nil {
+		t.Fatalf("GetPerformance returned error: %v", err)
+	}
+	// Единственная пятерка почти не сдвигает рейтинг от априорного 4.5
+	if report.BayesRating < 4.5 || report.BayesRating > 4.6 {
+		t.Fatalf("expected smoothed rating near prior, got %.2f", report.BayesRating)
+	}
+	if report.AcceptanceRate != models.PriorAcceptanceRate || report.OnTimeRate != models.PriorOnTimeRate {
+		t.Fatalf("expected prior rates, got %+v", report.PerformanceScore)
+	}
+	if report.Score <= 0 || report.Score > 1 || len(report.History) != 0 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCourierPerformance_GetPerformance_NotFound(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewCourierPerformanceService(db, newTestLogger())
+	courierID := uuid.New()
+
+	mock.ExpectQuery("SELECT rating, total_reviews FROM couriers").
+		WithArgs(courierID).
+		WillReturnError(sql.ErrNoRows)
+
+	if _, err := service.GetPerformance(context.Background(), courierID, 10); !apperror.Is(err, apperror.KindNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"delivery-system/internal/database"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// CourierStatusPublisher публикует смену статуса курьера.
+type CourierStatusPublisher interface {
+	PublishCourierStatusChanged(courierID uuid.UUID, oldStatus, newStatus models.CourierStatus) error
+}
+
+// CourierReconciler периодически возвращает в available курьеров, которые остались busy без активных заказов,
+// например после ручной правки данных или сбоя между сервисами.
+type CourierReconciler struct {
+	db        *database.DB
+	log       *logger.Logger
+	flow      *OrderFlow
+	publisher CourierStatusPublisher
+	interval  time.Duration
+	grace     time.Duration
+}
+
+// NewCourierReconciler создает сверку статусов курьеров. interval <= 0 выключает периодический запуск;
+// grace — сколько курьер должен пробыть busy без изменений, чтобы не задеть назначение в процессе.
+func NewCourierReconciler(db *database.DB, log *logger.Logger, flow *OrderFlow, publisher CourierStatusPublisher, interval, grace time.Duration) *CourierReconciler {
+	if flow == nil {
+		flow, _ = NewOrderFlow(DefaultOrderTransitions(), 0)
+	}
+	return &CourierReconciler{
+		db:        db,
+		log:       log,
+		flow:      flow,
+		publisher: publisher,
+		interval:  interval,
+		grace:     grace,
+	}
+}
+
+// Run запускает сверку с заданным периодом до отмены контекста.
+func (r *CourierReconciler) Run(ctx context.Context) {
+	if r.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	r.log.WithField("interval", r.interval.String()).Info("Courier status reconciler started")
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := r.Reconcile(ctx); err != nil {
+				r.log.WithError(err).Error("Failed to reconcile courier statuses")
+			}
+		}
+	}
+}
+
+// Reconcile одним запросом переводит в available busy-курьеров без незавершенных заказов
+// и публикует courier.status_changed для каждого. Возвращает освобожденных курьеров.
+func (r *CourierReconciler) Reconcile(ctx context.Context) ([]uuid.UUID, error) {
+	terminal := make([]string, len(r.flow.TerminalStatuses()))
+	for i, status := range r.flow.TerminalStatuses() {
+		terminal[i] = string(status)
+	}
+
+	now := time.Now()
+	query := `
+		UPDATE couriers c
+		SET status = $1, updated_at = $2
+		WHERE c.status = $3 AND c.updated_at < $4
+		  AND NOT EXISTS (
+			SELECT 1 FROM orders o
+			WHERE o.courier_id = c.id AND o.status <> ALL($5)
+		  )
+		RETURNING c.id
+	`
+	rows, err := r.db.QueryContext(ctx, query, models.CourierStatusAvailable, now, models.CourierStatusBusy, now.Add(-r.grace), pq.Array(terminal))
+	if err != nil {
+		return nil, fmt.Errorf("failed to reconcile couriers: %w", err)
+	}
+	defer rows.Close()
+
+	var released []uuid.UUID
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan courier: %w", err)
+		}
+		released = append(released, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate couriers: %w", err)
+	}
+
+	for _, id := range released {
+		if r.publisher == nil {
+			break
+		}
+		// Событие публикуется после фиксации изменения (best effort)
+		if err := r.publisher.PublishCourierStatusChanged(id, models.CourierStatusBusy, models.CourierStatusAvailable); err != nil {
+			r.log.WithError(err).WithField("courier_id", id).Error("Failed to publish courier status changed event")
+		}
+	}
+
+	if len(released) > 0 {
+		r.log.WithField("couriers", len(released)).Warn("Released couriers stuck in busy without active orders")
+	}
+	return released, nil
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"delivery-system/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+type recordingStatusPublisher struct {
+	released []uuid.UUID
+	err      error
+}
+
+func (p *recordingStatusPublisher) PublishCourierStatusChanged(courierID uuid.UUID, oldStatus, newStatus models.CourierStatus) error {
+	if oldStatus == models.CourierStatusBusy && newStatus == models.CourierStatusAvailable {
+		p.released = append(p.released, courierID)
+	}
+	return p.err
+}
+
+func TestCourierReconciler_Reconcile(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	publisher := &recordingStatusPublisher{err: fmt.Errorf("kafka is down")}
+	reconciler := NewCourierReconciler(db, newTestLogger(), nil, publisher, time.Minute, 5*time.Minute)
+	first, second := uuid.New(), uuid.New()
+
+	mock.ExpectQuery("UPDATE couriers c SET status = \\$1, updated_at = \\$2 WHERE c.status = \\$3 AND c.updated_at < \\$4 AND NOT EXISTS").
+		WithArgs(models.CourierStatusAvailable, sqlmock.AnyArg(), models.CourierStatusBusy, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(first).AddRow(second))
+
+	released, err := reconciler.Reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(released) != 2 || released[0] != first || released[1] != second {
+		t.Fatalf("unexpected released couriers: %v", released)
+	}
+	// Ошибка публикации не откатывает сверку
+	if len(publisher.released) != 2 {
+		t.Fatalf("expected status changed event per courier, got %v", publisher.released)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCourierReconciler_RunDisabled(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	reconciler := NewCourierReconciler(db, newTestLogger(), nil, nil, 0, 0)
+	done := make(chan struct{})
+	go func() {
+		reconciler.Run(context.Background())
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatalf("expected disabled reconciler to return immediately")
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
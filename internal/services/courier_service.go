@@ -11,11 +11,15 @@ import (
 	"delivery-system/internal/database"
 	"delivery-system/internal/logger"
 	"delivery-system/internal/models"
+	"delivery-system/internal/pagination"
 
 	"github.com/google/uuid"
 	"github.com/lib/pq"
 )
 
+// courierSortRating — сортировка курьеров по рейтингу, затем по числу отзывов.
+const courierSortRating = "rating"
+
 // CourierService представляет сервис для работы с курьерами
 type CourierService struct {
 	db  *database.DB
@@ -32,6 +36,21 @@ func NewCourierService(db *database.DB, log *logger.Logger) *CourierService {
 
 // CreateCourier создает нового курьера
 func (s *CourierService) CreateCourier(ctx context.Context, req *models.CreateCourierRequest) (*models.Courier, error) {
+	vehicle := req.VehicleType
+	if vehicle == "" {
+		vehicle = models.VehicleTypeBike
+	}
+	if !vehicle.IsValid() {
+		return nil, apperror.Validation("invalid vehicle type", nil)
+	}
+	for _, limit := range []*float64{req.MaxWeightKg, req.MaxVolumeL, req.MaxRangeKm} {
+		if limit != nil && *limit <= 0 {
+			return nil, apperror.Validation("vehicle limits must be positive", nil)
+		}
+	}
+
+	// Не указанные ограничения берутся из типового профиля транспорта
+	profile := models.DefaultVehicleProfile(vehicle)
 	courier := &models.Courier{
 		ID:           uuid.New(),
 		Name:         req.Name,
@@ -41,15 +60,23 @@ func (s *CourierService) CreateCourier(ctx context.Context, req *models.CreateCo
 		TotalReviews: 0,
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
+		VehicleType:  vehicle,
+		MaxWeightKg:  limitOrDefault(req.MaxWeightKg, profile.MaxWeightKg),
+		MaxVolumeL:   limitOrDefault(req.MaxVolumeL, profile.MaxVolumeL),
+		MaxRangeKm:   limitOrDefault(req.MaxRangeKm, profile.MaxRangeKm),
+		// Новый курьер не получает заказы, пока не пройдет проверку документов
+		OnboardingStatus: models.OnboardingStatusApplied,
 	}
 
 	query := `
-		INSERT INTO couriers (id, name, phone, status, rating, total_reviews, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		INSERT INTO couriers (id, name, phone, status, rating, total_reviews, created_at, updated_at,
+		                      vehicle_type, max_weight_kg, max_volume_l, max_range_km, onboarding_status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
 	`
 
 	_, err := s.db.ExecContext(ctx, query, courier.ID, courier.Name, courier.Phone,
-		courier.Status, courier.Rating, courier.TotalReviews, courier.CreatedAt, courier.UpdatedAt)
+		courier.Status, courier.Rating, courier.TotalReviews, courier.CreatedAt, courier.UpdatedAt,
+		courier.VehicleType, courier.MaxWeightKg, courier.MaxVolumeL, courier.MaxRangeKm, courier.OnboardingStatus)
 	if err != nil {
 		var pqErr *pq.Error
 		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
@@ -62,27 +89,46 @@ func (s *CourierService) CreateCourier(ctx context.Context, req *models.CreateCo
 		"courier_id":   courier.ID,
 		"courier_name": courier.Name,
 		"phone":        courier.Phone,
+		"vehicle_type": courier.VehicleType,
 	}).Info("Courier created successfully")
 
 	return courier, nil
 }
 
-// GetCourier получает курьера по ID
-func (s *CourierService) GetCourier(ctx context.Context, courierID uuid.UUID) (*models.Courier, error) {
-	courier := &models.Courier{}
+// limitOrDefault возвращает указанное ограничение или значение из профиля транспорта.
+func limitOrDefault(limit *float64, fallback float64) *float64 {
+	if limit != nil {
+		return limit
+	}
+	return &fallback
+}
 
-	query := `
-		SELECT id, name, phone, status, current_lat, current_lon, rating, total_reviews,
-		       created_at, updated_at, last_seen_at
-		FROM couriers 
-		WHERE id = $1
-	`
+// courierColumns — колонки курьера в порядке, который ожидает scanCourier.
+const courierColumns = `id, name, phone, status, current_lat, current_lon, rating, total_reviews,
+	created_at, updated_at, last_seen_at, vehicle_type, max_weight_kg, max_volume_l, max_range_km,
+	onboarding_status, onboarding_reason, verified_at`
 
-	err := s.db.QueryRowContext(ctx, query, courierID).Scan(
+// scanCourier читает курьера из строки, выбранной с courierColumns.
+func scanCourier(row rowScanner) (*models.Courier, error) {
+	courier := &models.Courier{}
+	err := row.Scan(
 		&courier.ID, &courier.Name, &courier.Phone, &courier.Status,
 		&courier.CurrentLat, &courier.CurrentLon, &courier.Rating, &courier.TotalReviews,
 		&courier.CreatedAt, &courier.UpdatedAt, &courier.LastSeenAt,
+		&courier.VehicleType, &courier.MaxWeightKg, &courier.MaxVolumeL, &courier.MaxRangeKm,
+		&courier.OnboardingStatus, &courier.OnboardingReason, &courier.VerifiedAt,
 	)
+	if err != nil {
+		return nil, err
+	}
+	return courier, nil
+}
+
+// GetCourier получает курьера по ID
+func (s *CourierService) GetCourier(ctx context.Context, courierID uuid.UUID) (*models.Courier, error) {
+	query := `SELECT ` + courierColumns + ` FROM couriers WHERE id = $1`
+
+	courier, err := scanCourier(s.db.QueryRowContext(ctx, query, courierID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, apperror.NotFound("courier not found", err)
@@ -126,47 +172,52 @@ func (s *CourierService) UpdateCourierStatus(ctx context.Context, courierID uuid
 	return nil
 }
 
-// GetCouriers получает список курьеров с фильтрацией
-func (s *CourierService) GetCouriers(ctx context.Context, status *models.CourierStatus, minRating *float64, limit, offset int, orderBy string) ([]*models.Courier, error) {
-	query := `
-		SELECT id, name, phone, status, current_lat, current_lon, rating, total_reviews,
-		       created_at, updated_at, last_seen_at
-		FROM couriers 
-		WHERE 1=1
-	`
+// GetCouriers получает страницу курьеров с фильтрацией. orderBy — created_at (по умолчанию) или rating.
+func (s *CourierService) GetCouriers(ctx context.Context, status *models.CourierStatus, onboarding *models.OnboardingStatus, minRating *float64, orderBy string, page pagination.Request) (*pagination.Page[*models.Courier], error) {
+	if orderBy != courierSortRating {
+		orderBy = sortCreatedAt
+	}
+	if err := page.CheckSort(orderBy); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + courierColumns + ` FROM couriers WHERE 1=1`
 	args := []interface{}{}
-	argIndex := 1
 
 	if status != nil {
-		query += fmt.Sprintf(" AND status = $%d", argIndex)
 		args = append(args, *status)
-		argIndex++
+		query += fmt.Sprintf(" AND status = $%d", len(args))
+	}
+
+	if onboarding != nil {
+		args = append(args, *onboarding)
+		query += fmt.Sprintf(" AND onboarding_status = $%d", len(args))
 	}
 
 	if minRating != nil {
-		query += fmt.Sprintf(" AND rating >= $%d", argIndex)
 		args = append(args, *minRating)
-		argIndex++
+		query += fmt.Sprintf(" AND rating >= $%d", len(args))
 	}
 
-	// Сортировка
+	// Сортировка; id в конце делает порядок однозначным для курсора
 	switch orderBy {
-	case "rating":
-		query += " ORDER BY rating DESC, total_reviews DESC, created_at DESC"
+	case courierSortRating:
+		if page.After != nil {
+			if page.After.Rating == nil || page.After.Reviews == nil {
+				return nil, apperror.Validation("invalid cursor", nil)
+			}
+			query += " AND " + keysetCondition([]string{"rating", "total_reviews", "created_at", "id"}, &args,
+				*page.After.Rating, *page.After.Reviews, page.After.CreatedAt, page.After.ID)
+		}
+		query += " ORDER BY rating DESC, total_reviews DESC, created_at DESC, id DESC"
 	default:
-		query += " ORDER BY created_at DESC"
-	}
-
-	if limit > 0 {
-		query += fmt.Sprintf(" LIMIT $%d", argIndex)
-		args = append(args, limit)
-		argIndex++
+		if page.After != nil {
+			query += " AND " + keysetCondition([]string{"created_at", "id"}, &args, page.After.CreatedAt, page.After.ID)
+		}
+		query += " ORDER BY created_at DESC, id DESC"
 	}
 
-	if offset > 0 {
-		query += fmt.Sprintf(" OFFSET $%d", argIndex)
-		args = append(args, offset)
-	}
+	query += pageLimitClause(page, &args)
 
 	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -176,10 +227,8 @@ func (s *CourierService) GetCouriers(ctx context.Context, status *models.Courier
 
 	var couriers []*models.Courier
 	for rows.Next() {
-		courier := &models.Courier{}
-		if err := rows.Scan(&courier.ID, &courier.Name, &courier.Phone, &courier.Status,
-			&courier.CurrentLat, &courier.CurrentLon, &courier.Rating, &courier.TotalReviews,
-			&courier.CreatedAt, &courier.UpdatedAt, &courier.LastSeenAt); err != nil {
+		courier, err := scanCourier(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan courier: %w", err)
 		}
 		couriers = append(couriers, courier)
@@ -189,13 +238,25 @@ func (s *CourierService) GetCouriers(ctx context.Context, status *models.Courier
 		return nil, fmt.Errorf("failed to iterate couriers: %w", err)
 	}
 
-	return couriers, nil
+	return pagination.NewPage(couriers, page.Limit, func(c *models.Courier) pagination.Cursor {
+		cursor := pagination.Cursor{Sort: orderBy, CreatedAt: c.CreatedAt, ID: c.ID.String()}
+		if orderBy == courierSortRating {
+			rating, reviews := c.Rating, c.TotalReviews
+			cursor.Rating, cursor.Reviews = &rating, &reviews
+		}
+		return cursor
+	}), nil
 }
 
-// GetAvailableCouriers получает список доступных курьеров
+// GetAvailableCouriers получает список доступных курьеров, прошедших проверку
 func (s *CourierService) GetAvailableCouriers(ctx context.Context) ([]*models.Courier, error) {
 	status := models.CourierStatusAvailable
-	return s.GetCouriers(ctx, &status, nil, 0, 0, "created_at")
+	onboarding := models.OnboardingStatusVerified
+	page, err := s.GetCouriers(ctx, &status, &onboarding, nil, sortCreatedAt, pagination.Request{})
+	if err != nil {
+		return nil, err
+	}
+	return page.Items, nil
 }
 
 // AssignOrderToCourier назначает заказ курьеру
@@ -207,9 +268,9 @@ func (s *CourierService) AssignOrderToCourier(ctx context.Context, orderID, cour
 	defer func() { _ = tx.Rollback() }()
 
 	// Проверяем, что курьер доступен и блокируем строку, чтобы избежать гонок
-	var courierStatus string
-	courierQuery := "SELECT status FROM couriers WHERE id = $1 FOR UPDATE"
-	err = tx.QueryRowContext(ctx, courierQuery, courierID).Scan(&courierStatus)
+	var courierStatus, onboardingStatus string
+	courierQuery := "SELECT status, onboarding_status FROM couriers WHERE id = $1 FOR UPDATE"
+	err = tx.QueryRowContext(ctx, courierQuery, courierID).Scan(&courierStatus, &onboardingStatus)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return apperror.NotFound("courier not found", err)
@@ -217,10 +278,33 @@ func (s *CourierService) AssignOrderToCourier(ctx context.Context, orderID, cour
 		return fmt.Errorf("failed to check courier status: %w", err)
 	}
 
+	if onboardingStatus != string(models.OnboardingStatusVerified) {
+		return apperror.Conflict("courier is not verified", nil)
+	}
 	if courierStatus != string(models.CourierStatusAvailable) {
 		return apperror.Conflict("courier is not available", nil)
 	}
 
+	// Заказ блокируется после курьера, как и при переназначении, и проверяется транспорт курьера
+	var orderStatus string
+	err = tx.QueryRowContext(ctx, "SELECT status FROM orders WHERE id = $1 FOR UPDATE", orderID).Scan(&orderStatus)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return apperror.NotFound("order not found", err)
+		}
+		return fmt.Errorf("failed to lock order: %w", err)
+	}
+	if orderStatus != string(models.OrderStatusCreated) {
+		return apperror.Conflict("order not found or already assigned", nil)
+	}
+	if err := checkCourierCanCarryWithTx(ctx, tx, orderID, courierID); err != nil {
+		return err
+	}
+
+	if err := setActorWithTx(ctx, tx); err != nil {
+		return err
+	}
+
 	// Назначаем заказ курьеру и меняем статус заказа, если он ещё не занят
 	orderQuery := `
 		UPDATE orders 
@@ -6,6 +6,7 @@ import (
 	"testing"
 	"time"
 
+	"delivery-system/internal/apperror"
 	"delivery-system/internal/models"
 
 	"github.com/DATA-DOG/go-sqlmock"
@@ -25,7 +26,7 @@ func TestCourierService_CreateCourier_Success(t *testing.T) {
 	}
 
 	mock.ExpectExec("INSERT INTO couriers").
-		WithArgs(sqlmock.AnyArg(), req.Name, req.Phone, models.CourierStatusOffline, 0.0, 0, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(sqlmock.AnyArg(), req.Name, req.Phone, models.CourierStatusOffline, 0.0, 0, sqlmock.AnyArg(), sqlmock.AnyArg(), models.VehicleTypeBike, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), models.OnboardingStatusApplied).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	courier, err := service.CreateCourier(context.Background(), req)
@@ -41,6 +42,44 @@ func TestCourierService_CreateCourier_Success(t *testing.T) {
 		t.Fatalf("expected status offline, got %v", courier.Status)
 	}
 
+	if courier.OnboardingStatus != models.OnboardingStatusApplied {
+		t.Fatalf("expected onboarding status applied, got %v", courier.OnboardingStatus)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCourierService_CreateCourier_Vehicle(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewCourierService(db, newTestLogger())
+
+	// Дальность указана явно, вес и объем берутся из профиля машины
+	maxRange := 40.0
+	req := &models.CreateCourierRequest{Name: "Driver", Phone: "+79001112234", VehicleType: models.VehicleTypeCar, MaxRangeKm: &maxRange}
+	profile := models.DefaultVehicleProfile(models.VehicleTypeCar)
+
+	mock.ExpectExec("INSERT INTO couriers").
+		WithArgs(sqlmock.AnyArg(), req.Name, req.Phone, models.CourierStatusOffline, 0.0, 0, sqlmock.AnyArg(), sqlmock.AnyArg(),
+			models.VehicleTypeCar, &profile.MaxWeightKg, &profile.MaxVolumeL, &maxRange, models.OnboardingStatusApplied).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	courier, err := service.CreateCourier(context.Background(), req)
+	if err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+	if courier.VehicleType != models.VehicleTypeCar || *courier.MaxWeightKg != profile.MaxWeightKg || *courier.MaxRangeKm != maxRange {
+		t.Fatalf("unexpected vehicle limits: %+v", courier)
+	}
+
+	invalid := &models.CreateCourierRequest{Name: "Pilot", Phone: "+79001112235", VehicleType: "plane"}
+	if _, err := service.CreateCourier(context.Background(), invalid); !apperror.Is(err, apperror.KindValidation) {
+		t.Fatalf("expected validation error for unknown vehicle, got %v", err)
+	}
+
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Fatalf("unmet expectations: %v", err)
 	}
@@ -59,7 +98,7 @@ func TestCourierService_CreateCourier_DuplicatePhone(t *testing.T) {
 	}
 
 	mock.ExpectExec("INSERT INTO couriers").
-		WithArgs(sqlmock.AnyArg(), req.Name, req.Phone, models.CourierStatusOffline, 0.0, 0, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(sqlmock.AnyArg(), req.Name, req.Phone, models.CourierStatusOffline, 0.0, 0, sqlmock.AnyArg(), sqlmock.AnyArg(), models.VehicleTypeBike, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), models.OnboardingStatusApplied).
 		WillReturnError(sql.ErrConnDone)
 
 	_, err := service.CreateCourier(context.Background(), req)
@@ -84,8 +123,8 @@ func TestCourierService_GetCourier_Success(t *testing.T) {
 
 	mock.ExpectQuery("SELECT id, name, phone, status, current_lat, current_lon").
 		WithArgs(courierID).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "phone", "status", "current_lat", "current_lon", "rating", "total_reviews", "created_at", "updated_at", "last_seen_at"}).
-			AddRow(courierID, "Bob", "+79998887766", models.CourierStatusAvailable, lat, lon, 4.5, 10, time.Now(), time.Now(), time.Now()))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "phone", "status", "current_lat", "current_lon", "rating", "total_reviews", "created_at", "updated_at", "last_seen_at", "vehicle_type", "max_weight_kg", "max_volume_l", "max_range_km", "onboarding_status", "onboarding_reason", "verified_at"}).
+			AddRow(courierID, "Bob", "+79998887766", models.CourierStatusAvailable, lat, lon, 4.5, 10, time.Now(), time.Now(), time.Now(), "bike", nil, nil, nil, models.OnboardingStatusVerified, nil, nil))
 
 	courier, err := service.GetCourier(context.Background(), courierID)
 	if err != nil {
@@ -190,11 +229,13 @@ func TestCourierService_AssignOrderToCourier_Success(t *testing.T) {
 	courierID := uuid.New()
 
 	mock.ExpectBegin()
-	mock.ExpectQuery("SELECT status FROM couriers WHERE id").
+	mock.ExpectQuery("SELECT status, onboarding_status FROM couriers WHERE id").
 		WithArgs(courierID).
-		WillReturnRows(sqlmock.NewRows([]string{"status"}).
-			AddRow(models.CourierStatusAvailable))
+		WillReturnRows(sqlmock.NewRows([]string{"status", "onboarding_status"}).
+			AddRow(models.CourierStatusAvailable, models.OnboardingStatusVerified))
+	expectAssignOrderLock(mock, orderID, courierID, models.OrderStatusCreated, vehicleCheckRow(8, 55.75, 37.60, 25, 25))
 
+	mock.ExpectExec("SELECT set_config").WithArgs("system:unknown").WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectExec("UPDATE orders SET courier_id").
 		WithArgs(courierID, models.OrderStatusAccepted, sqlmock.AnyArg(), orderID, models.OrderStatusCreated).
 		WillReturnResult(sqlmock.NewResult(1, 1))
@@ -226,10 +267,10 @@ func TestCourierService_AssignOrderToCourier_CourierNotAvailable(t *testing.T) {
 	courierID := uuid.New()
 
 	mock.ExpectBegin()
-	mock.ExpectQuery("SELECT status FROM couriers WHERE id").
+	mock.ExpectQuery("SELECT status, onboarding_status FROM couriers WHERE id").
 		WithArgs(courierID).
-		WillReturnRows(sqlmock.NewRows([]string{"status"}).
-			AddRow(models.CourierStatusBusy))
+		WillReturnRows(sqlmock.NewRows([]string{"status", "onboarding_status"}).
+			AddRow(models.CourierStatusBusy, models.OnboardingStatusVerified))
 
 	mock.ExpectRollback()
 
@@ -243,6 +284,32 @@ func TestCourierService_AssignOrderToCourier_CourierNotAvailable(t *testing.T) {
 	}
 }
 
+func TestCourierService_AssignOrderToCourier_CourierNotVerified(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewCourierService(db, newTestLogger())
+
+	orderID := uuid.New()
+	courierID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, onboarding_status FROM couriers WHERE id").
+		WithArgs(courierID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "onboarding_status"}).
+			AddRow(models.CourierStatusAvailable, models.OnboardingStatusDocumentsSubmitted))
+	mock.ExpectRollback()
+
+	err := service.AssignOrderToCourier(context.Background(), orderID, courierID)
+	if !apperror.Is(err, apperror.KindConflict) {
+		t.Fatalf("expected conflict for unverified courier, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
 func TestCourierService_AssignOrderToCourier_CourierNotFound(t *testing.T) {
 	db, mock := newMockDB(t)
 	defer db.Close()
@@ -254,7 +321,7 @@ func TestCourierService_AssignOrderToCourier_CourierNotFound(t *testing.T) {
 	courierID := uuid.New()
 
 	mock.ExpectBegin()
-	mock.ExpectQuery("SELECT status FROM couriers WHERE id").
+	mock.ExpectQuery("SELECT status, onboarding_status FROM couriers WHERE id").
 		WithArgs(courierID).
 		WillReturnError(sql.ErrNoRows)
 
@@ -281,20 +348,19 @@ func TestCourierService_AssignOrderToCourier_OrderNotFound(t *testing.T) {
 	courierID := uuid.New()
 
 	mock.ExpectBegin()
-	mock.ExpectQuery("SELECT status FROM couriers WHERE id").
+	mock.ExpectQuery("SELECT status, onboarding_status FROM couriers WHERE id").
 		WithArgs(courierID).
-		WillReturnRows(sqlmock.NewRows([]string{"status"}).
-			AddRow(models.CourierStatusAvailable))
-
-	mock.ExpectExec("UPDATE orders SET courier_id").
-		WithArgs(courierID, models.OrderStatusAccepted, sqlmock.AnyArg(), orderID, models.OrderStatusCreated).
-		WillReturnResult(sqlmock.NewResult(1, 0))
+		WillReturnRows(sqlmock.NewRows([]string{"status", "onboarding_status"}).
+			AddRow(models.CourierStatusAvailable, models.OnboardingStatusVerified))
+	mock.ExpectQuery("SELECT status FROM orders WHERE id = \\$1 FOR UPDATE").
+		WithArgs(orderID).
+		WillReturnError(sql.ErrNoRows)
 
 	mock.ExpectRollback()
 
 	err := service.AssignOrderToCourier(context.Background(), orderID, courierID)
-	if err == nil {
-		t.Fatalf("expected error, got nil")
+	if !apperror.Is(err, apperror.KindNotFound) {
+		t.Fatalf("expected not found, got %v", err)
 	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -302,6 +368,59 @@ func TestCourierService_AssignOrderToCourier_OrderNotFound(t *testing.T) {
 	}
 }
 
+// expectAssignOrderLock ожидает блокировку заказа в статусе status и, если заказ еще свободен,
+// проверку транспорта курьера со строкой vehicle.
+func expectAssignOrderLock(mock sqlmock.Sqlmock, orderID, courierID uuid.UUID, status models.OrderStatus, vehicle *sqlmock.Rows) {
+	mock.ExpectQuery("SELECT status FROM orders WHERE id = \\$1 FOR UPDATE").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(status))
+	if status == models.OrderStatusCreated {
+		mock.ExpectQuery("SELECT o.weight_kg, o.volume_l").
+			WithArgs(orderID, courierID).
+			WillReturnRows(vehicle)
+	}
+}
+
+func TestCourierService_AssignOrderToCourier_LockedOrderChecks(t *testing.T) {
+	cases := []struct {
+		name    string
+		status  models.OrderStatus
+		vehicle *sqlmock.Rows
+	}{
+		{"already assigned", models.OrderStatusAccepted, nil},
+		// 150 кг на велосипеде с типовым лимитом 10 кг
+		{"overweight", models.OrderStatusCreated, vehicleCheckRow(150, 55.75, 37.60, 10, 8)},
+		// Курьер в 60 км от точки забора, дальность скутера 25 км
+		{"out of range", models.OrderStatusCreated, vehicleCheckRow(8, 55.75, 38.55, 25, 25)},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			defer db.Close()
+
+			service := NewCourierService(db, newTestLogger())
+			orderID := uuid.New()
+			courierID := uuid.New()
+
+			mock.ExpectBegin()
+			mock.ExpectQuery("SELECT status, onboarding_status FROM couriers WHERE id").
+				WithArgs(courierID).
+				WillReturnRows(sqlmock.NewRows([]string{"status", "onboarding_status"}).
+					AddRow(models.CourierStatusAvailable, models.OnboardingStatusVerified))
+			expectAssignOrderLock(mock, orderID, courierID, tc.status, tc.vehicle)
+			mock.ExpectRollback()
+
+			err := service.AssignOrderToCourier(context.Background(), orderID, courierID)
+			if !apperror.Is(err, apperror.KindConflict) {
+				t.Fatalf("expected conflict, got %v", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
 func TestCourierService_GetAvailableCouriers(t *testing.T) {
 	db, mock := newMockDB(t)
 	defer db.Close()
@@ -309,11 +428,11 @@ func TestCourierService_GetAvailableCouriers(t *testing.T) {
 	log := newTestLogger()
 	service := NewCourierService(db, log)
 
-	rows := sqlmock.NewRows([]string{"id", "name", "phone", "status", "current_lat", "current_lon", "rating", "total_reviews", "created_at", "updated_at", "last_seen_at"}).
-		AddRow(uuid.New(), "Available Courier", "+79009998877", models.CourierStatusAvailable, 55.0, 37.0, 4.8, 5, time.Now(), time.Now(), time.Now())
+	rows := sqlmock.NewRows([]string{"id", "name", "phone", "status", "current_lat", "current_lon", "rating", "total_reviews", "created_at", "updated_at", "last_seen_at", "vehicle_type", "max_weight_kg", "max_volume_l", "max_range_km", "onboarding_status", "onboarding_reason", "verified_at"}).
+		AddRow(uuid.New(), "Available Courier", "+79009998877", models.CourierStatusAvailable, 55.0, 37.0, 4.8, 5, time.Now(), time.Now(), time.Now(), "bike", nil, nil, nil, models.OnboardingStatusVerified, nil, nil)
 
 	mock.ExpectQuery("SELECT id, name, phone, status, current_lat, current_lon, rating, total_reviews").
-		WithArgs(models.CourierStatusAvailable).
+		WithArgs(models.CourierStatusAvailable, models.OnboardingStatusVerified).
 		WillReturnRows(rows)
 
 	couriers, err := service.GetAvailableCouriers(context.Background())
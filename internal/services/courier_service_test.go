@@ -5,7 +5,9 @@ import (
 	"testing"
 	"time"
 
+	"delivery-system/internal/apperror"
 	"delivery-system/internal/models"
+	"delivery-system/internal/pagination"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/google/uuid"
@@ -20,22 +22,23 @@ func TestCourierService_GetCouriers_WithFilters(t *testing.T) {
 
 	status := models.CourierStatusAvailable
 	minRating := 4.5
-	limit, offset := 10, 0
+	limit := 10
 
-	rows := sqlmock.NewRows([]string{"id", "name", "phone", "status", "current_lat", "current_lon", "rating", "total_reviews", "created_at", "updated_at", "last_seen_at"}).
-		AddRow(uuid.New(), "John Doe", "+7000", status, 55.0, 37.0, 4.6, 3, time.Now(), time.Now(), time.Now())
+	rows := sqlmock.NewRows([]string{"id", "name", "phone", "status", "current_lat", "current_lon", "rating", "total_reviews", "created_at", "updated_at", "last_seen_at", "vehicle_type", "max_weight_kg", "max_volume_l", "max_range_km", "onboarding_status", "onboarding_reason", "verified_at"}).
+		AddRow(uuid.New(), "John Doe", "+7000", status, 55.0, 37.0, 4.6, 3, time.Now(), time.Now(), time.Now(), "bike", nil, nil, nil, models.OnboardingStatusVerified, nil, nil)
 
-	mock.ExpectQuery("SELECT id, name, phone, status, current_lat, current_lon, rating, total_reviews,\\s+created_at, updated_at, last_seen_at\\s+FROM couriers").
-		WithArgs(status, minRating, limit).
+	mock.ExpectQuery("SELECT id, name, phone, status, current_lat, current_lon, rating, total_reviews,\\s+created_at, updated_at, last_seen_at, vehicle_type, max_weight_kg, max_volume_l, max_range_km,\\s+onboarding_status, onboarding_reason, verified_at FROM couriers").
+		WithArgs(status, minRating, limit+1).
 		WillReturnRows(rows)
 
-	couriers, err := service.GetCouriers(context.Background(), &status, &minRating, limit, offset, "created_at")
+	page, err := service.GetCouriers(context.Background(), &status, nil, &minRating, "created_at", pagination.Request{Limit: limit})
 	if err != nil {
 		t.Fatalf("expected success, got error: %v", err)
 	}
 
-	if len(couriers) != 1 {
-		t.Fatalf("expected 1 courier, got %d", len(couriers))
+	couriers := page.Items
+	if len(couriers) != 1 || page.NextCursor != nil {
+		t.Fatalf("expected 1 courier and no next page, got %d", len(couriers))
 	}
 
 	if couriers[0].Rating < minRating {
@@ -54,19 +57,59 @@ func TestCourierService_GetCouriers_NoFilters(t *testing.T) {
 	log := newTestLogger()
 	service := NewCourierService(db, log)
 
-	rows := sqlmock.NewRows([]string{"id", "name", "phone", "status", "current_lat", "current_lon", "rating", "total_reviews", "created_at", "updated_at", "last_seen_at"}).
-		AddRow(uuid.New(), "Alice", "+7001", models.CourierStatusOffline, nil, nil, 0.0, 0, time.Now(), time.Now(), nil)
+	rows := sqlmock.NewRows([]string{"id", "name", "phone", "status", "current_lat", "current_lon", "rating", "total_reviews", "created_at", "updated_at", "last_seen_at", "vehicle_type", "max_weight_kg", "max_volume_l", "max_range_km", "onboarding_status", "onboarding_reason", "verified_at"}).
+		AddRow(uuid.New(), "Alice", "+7001", models.CourierStatusOffline, nil, nil, 0.0, 0, time.Now(), time.Now(), nil, "bike", nil, nil, nil, models.OnboardingStatusVerified, nil, nil)
 
-	mock.ExpectQuery("SELECT id, name, phone, status, current_lat, current_lon, rating, total_reviews,\\s+created_at, updated_at, last_seen_at\\s+FROM couriers").
+	mock.ExpectQuery("SELECT id, name, phone, status, current_lat, current_lon, rating, total_reviews,\\s+created_at, updated_at, last_seen_at, vehicle_type, max_weight_kg, max_volume_l, max_range_km,\\s+onboarding_status, onboarding_reason, verified_at FROM couriers").
 		WillReturnRows(rows)
 
-	couriers, err := service.GetCouriers(context.Background(), nil, nil, 0, 0, "created_at")
+	page, err := service.GetCouriers(context.Background(), nil, nil, nil, "created_at", pagination.Request{})
 	if err != nil {
 		t.Fatalf("expected success, got error: %v", err)
 	}
 
-	if len(couriers) != 1 {
-		t.Fatalf("expected 1 courier, got %d", len(couriers))
+	if len(page.Items) != 1 {
+		t.Fatalf("expected 1 courier, got %d", len(page.Items))
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestCourierService_GetCouriers_RatingCursor(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewCourierService(db, newTestLogger())
+
+	rating, reviews := 4.8, 20
+	after := &pagination.Cursor{Sort: "rating", CreatedAt: time.Now().Add(-time.Hour), ID: uuid.New().String(), Rating: &rating, Reviews: &reviews}
+	now := time.Now()
+	rows := sqlmock.NewRows([]string{"id", "name", "phone", "status", "current_lat", "current_lon", "rating", "total_reviews", "created_at", "updated_at", "last_seen_at", "vehicle_type", "max_weight_kg", "max_volume_l", "max_range_km", "onboarding_status", "onboarding_reason", "verified_at"}).
+		AddRow(uuid.New(), "A", "+7001", models.CourierStatusAvailable, nil, nil, 4.7, 10, now, now, nil, "bike", nil, nil, nil, models.OnboardingStatusVerified, nil, nil).
+		AddRow(uuid.New(), "B", "+7002", models.CourierStatusAvailable, nil, nil, 4.5, 8, now, now, nil, "bike", nil, nil, nil, models.OnboardingStatusVerified, nil, nil)
+
+	mock.ExpectQuery(`FROM couriers\s+WHERE 1=1 AND \(rating, total_reviews, created_at, id\) < \(\$1, \$2, \$3, \$4\) ORDER BY rating DESC, total_reviews DESC, created_at DESC, id DESC LIMIT \$5`).
+		WithArgs(rating, reviews, after.CreatedAt, after.ID, 2).
+		WillReturnRows(rows)
+
+	page, err := service.GetCouriers(context.Background(), nil, nil, nil, "rating", pagination.Request{Limit: 1, After: after})
+	if err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+	if len(page.Items) != 1 || page.NextCursor == nil {
+		t.Fatalf("expected one courier and next cursor, got %d", len(page.Items))
+	}
+	next, err := pagination.Decode(*page.NextCursor)
+	if err != nil || next.Sort != "rating" || next.Rating == nil || *next.Rating != 4.7 || *next.Reviews != 10 {
+		t.Fatalf("unexpected next cursor: %+v (%v)", next, err)
+	}
+
+	// Курсор сортировки по дате не подходит для сортировки по рейтингу
+	_, err = service.GetCouriers(context.Background(), nil, nil, nil, "rating", pagination.Request{Limit: 1, After: &pagination.Cursor{Sort: "created_at", CreatedAt: now, ID: "x"}})
+	if !apperror.Is(err, apperror.KindValidation) {
+		t.Fatalf("expected validation error, got %v", err)
 	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
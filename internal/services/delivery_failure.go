@@ -0,0 +1,193 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"delivery-system/internal/actor"
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// LogContactAttempt записывает попытку курьера связаться с клиентом. Попытка относится к текущей
+// попытке вручения (delivery_attempts + 1); без нее заказ нельзя перевести в delivery_failed.
+func (s *OrderService) LogContactAttempt(ctx context.Context, orderID uuid.UUID, req *models.LogContactAttemptRequest) (*models.ContactAttempt, error) {
+	if req == nil || !req.Channel.IsValid() {
+		return nil, apperror.Validation("channel must be one of call, sms, messenger, doorbell", nil)
+	}
+	if !req.Result.IsValid() {
+		return nil, apperror.Validation("result must be one of no_answer, unreachable, wrong_address, refused, reached", nil)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var (
+		status    models.OrderStatus
+		courierID *uuid.UUID
+		attempts  int
+	)
+	err = tx.QueryRowContext(ctx, "SELECT status, courier_id, delivery_attempts FROM orders WHERE id = $1 FOR UPDATE", orderID).
+		Scan(&status, &courierID, &attempts)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperror.NotFound("order not found", err)
+		}
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+	if status != models.OrderStatusInDelivery {
+		return nil, apperror.Conflict("contact attempts can only be logged while the order is in delivery", nil)
+	}
+
+	// Курьер может записывать попытки только по своему заказу
+	who := actor.FromContext(ctx)
+	if who.Type == actor.TypeCourier && (courierID == nil || courierID.String() != who.ID) {
+		return nil, apperror.Forbidden("order is assigned to another courier", nil)
+	}
+
+	contact := &models.ContactAttempt{
+		OrderID:   orderID,
+		CourierID: courierID,
+		Attempt:   attempts + 1,
+		Channel:   req.Channel,
+		Result:    req.Result,
+		Note:      req.Note,
+		LoggedBy:  who.String(),
+	}
+	query := `
+		INSERT INTO order_contact_attempts (order_id, courier_id, attempt, channel, result, note, logged_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id, created_at
+	`
+	err = tx.QueryRowContext(ctx, query, orderID, courierID, contact.Attempt, contact.Channel, contact.Result, contact.Note, contact.LoggedBy).
+		Scan(&contact.ID, &contact.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to log contact attempt: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit contact attempt: %w", err)
+	}
+
+	s.log.WithFields(map[string]interface{}{
+		"order_id": orderID,
+		"attempt":  contact.Attempt,
+		"result":   contact.Result,
+	}).Info("Customer contact attempt logged")
+
+	return contact, nil
+}
+
+// GetDeliveryAttempts возвращает число неудачных попыток вручения, лимит и журнал связи с клиентом.
+func (s *OrderService) GetDeliveryAttempts(ctx context.Context, orderID uuid.UUID) (*models.DeliveryAttempts, error) {
+	result := &models.DeliveryAttempts{
+		OrderID:     orderID,
+		MaxAttempts: s.flow.MaxDeliveryAttempts(),
+		Contacts:    []models.ContactAttempt{},
+	}
+
+	var currency string
+	err := s.db.QueryRowContext(ctx, "SELECT status, delivery_attempts, return_cost, currency FROM orders WHERE id = $1", orderID).
+		Scan(&result.Status, &result.Failed, &result.ReturnCost, &currency)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperror.NotFound("order not found", err)
+		}
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+	if result.ReturnCost != nil {
+		returnCost := result.ReturnCost.In(currency)
+		result.ReturnCost = &returnCost
+	}
+
+	query := `
+		SELECT id, order_id, courier_id, attempt, channel, result, note, logged_by, created_at
+		FROM order_contact_attempts
+		WHERE order_id = $1
+		ORDER BY attempt, created_at, id
+	`
+	rows, err := s.db.QueryContext(ctx, query, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get contact attempts: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c models.ContactAttempt
+		if err := rows.Scan(&c.ID, &c.OrderID, &c.CourierID, &c.Attempt, &c.Channel, &c.Result, &c.Note, &c.LoggedBy, &c.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan contact attempt: %w", err)
+		}
+		result.Contacts = append(result.Contacts, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate contact attempts: %w", err)
+	}
+
+	return result, nil
+}
+
+// failDeliveryWithTx засчитывает неудачную попытку вручения. Перед этим курьер должен
+// записать хотя бы одну попытку связаться с клиентом в рамках текущей попытки.
+func (s *OrderService) failDeliveryWithTx(ctx context.Context, tx *sql.Tx, orderID uuid.UUID) error {
+	query := `
+		UPDATE orders
+		SET delivery_attempts = delivery_attempts + 1
+		WHERE id = $1
+		  AND EXISTS (
+			SELECT 1 FROM order_contact_attempts c
+			WHERE c.order_id = orders.id AND c.attempt = orders.delivery_attempts + 1
+		  )
+	`
+	result, err := tx.ExecContext(ctx, query, orderID)
+	if err != nil {
+		return fmt.Errorf("failed to record failed delivery: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return apperror.Conflict("log a customer contact attempt before marking delivery as failed", nil)
+	}
+	return nil
+}
+
+// retryDeliveryWithTx разрешает повторную попытку вручения, пока не исчерпан лимит попыток.
+func (s *OrderService) retryDeliveryWithTx(ctx context.Context, tx *sql.Tx, orderID uuid.UUID) error {
+	var attempts int
+	if err := tx.QueryRowContext(ctx, "SELECT delivery_attempts FROM orders WHERE id = $1", orderID).Scan(&attempts); err != nil {
+		return fmt.Errorf("failed to get delivery attempts: %w", err)
+	}
+	if attempts >= s.flow.MaxDeliveryAttempts() {
+		return apperror.Conflict(fmt.Sprintf("delivery attempts limit of %d reached, order must be returned", s.flow.MaxDeliveryAttempts()), nil)
+	}
+	return nil
+}
+
+// priceReturnWithTx считает стоимость обратного плеча от адреса доставки до точки забора.
+func (s *OrderService) priceReturnWithTx(ctx context.Context, tx *sql.Tx, orderID uuid.UUID) error {
+	var (
+		region, currency                               string
+		vehicle                                        models.VehicleType
+		pickupLat, pickupLon, deliveryLat, deliveryLon sql.NullFloat64
+	)
+	query := `SELECT region_code, currency, vehicle_type, pickup_lat, pickup_lon, delivery_lat, delivery_lon FROM orders WHERE id = $1`
+	if err := tx.QueryRowContext(ctx, query, orderID).Scan(&region, &currency, &vehicle, &pickupLat, &pickupLon, &deliveryLat, &deliveryLon); err != nil {
+		return fmt.Errorf("failed to get order route: %w", err)
+	}
+	if !pickupLat.Valid || !pickupLon.Valid || !deliveryLat.Valid || !deliveryLon.Valid {
+		return apperror.Conflict("order has no coordinates to price the return leg", nil)
+	}
+
+	cost := s.pricing.QuoteReturn(region, vehicle, deliveryLat.Float64, deliveryLon.Float64, pickupLat.Float64, pickupLon.Float64).In(currency)
+	if _, err := tx.ExecContext(ctx, "UPDATE orders SET return_cost = $1 WHERE id = $2", cost, orderID); err != nil {
+		return fmt.Errorf("failed to save return cost: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,239 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"delivery-system/internal/actor"
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+func expectStatusSelect(mock sqlmock.Sqlmock, orderID uuid.UUID, status models.OrderStatus, courierID interface{}) {
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, courier_id, delivered_at, handoff_pin FROM orders").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "courier_id", "delivered_at", "handoff_pin"}).
+			AddRow(status, courierID, nil, "1234"))
+}
+
+func TestOrderService_LogContactAttempt(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewOrderService(db, newTestLogger(), newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
+	orderID := uuid.New()
+	courierID := uuid.New()
+	note := "домофон не работает"
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, courier_id, delivery_attempts FROM orders WHERE id = \\$1 FOR UPDATE").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "courier_id", "delivery_attempts"}).AddRow(models.OrderStatusInDelivery, courierID, 1))
+	// Вторая попытка вручения: запись относится к attempt = 2
+	mock.ExpectQuery("INSERT INTO order_contact_attempts").
+		WithArgs(orderID, &courierID, 2, models.ContactChannelCall, models.ContactResultNoAnswer, &note, "courier:"+courierID.String()).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at"}).AddRow(uuid.New(), time.Now()))
+	mock.ExpectCommit()
+
+	ctx := actor.WithContext(context.Background(), actor.Actor{Type: actor.TypeCourier, ID: courierID.String()})
+	contact, err := service.LogContactAttempt(ctx, orderID, &models.LogContactAttemptRequest{
+		Channel: models.ContactChannelCall,
+		Result:  models.ContactResultNoAnswer,
+		Note:    &note,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if contact.Attempt != 2 || contact.LoggedBy != "courier:"+courierID.String() {
+		t.Fatalf("unexpected contact attempt: %+v", contact)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestOrderService_LogContactAttempt_Rejected(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewOrderService(db, newTestLogger(), newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
+	orderID := uuid.New()
+	req := &models.LogContactAttemptRequest{Channel: models.ContactChannelSMS, Result: models.ContactResultUnreachable}
+
+	if _, err := service.LogContactAttempt(context.Background(), orderID, &models.LogContactAttemptRequest{Channel: "pigeon", Result: models.ContactResultReached}); !apperror.Is(err, apperror.KindValidation) {
+		t.Fatalf("expected validation error for unknown channel, got %v", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, courier_id, delivery_attempts FROM orders").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "courier_id", "delivery_attempts"}).AddRow(models.OrderStatusReady, nil, 0))
+	mock.ExpectRollback()
+	if _, err := service.LogContactAttempt(context.Background(), orderID, req); !apperror.Is(err, apperror.KindConflict) {
+		t.Fatalf("expected conflict outside of delivery, got %v", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, courier_id, delivery_attempts FROM orders").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "courier_id", "delivery_attempts"}).AddRow(models.OrderStatusInDelivery, uuid.New(), 0))
+	mock.ExpectRollback()
+	other := actor.WithContext(context.Background(), actor.Actor{Type: actor.TypeCourier, ID: uuid.NewString()})
+	if _, err := service.LogContactAttempt(other, orderID, req); !apperror.Is(err, apperror.KindForbidden) {
+		t.Fatalf("expected forbidden for another courier, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestOrderService_UpdateOrderStatus_DeliveryFailed_RequiresContactAttempt(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewOrderService(db, newTestLogger(), newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
+	orderID := uuid.New()
+
+	expectStatusSelect(mock, orderID, models.OrderStatusInDelivery, uuid.New())
+	mock.ExpectExec("UPDATE orders SET delivery_attempts = delivery_attempts \\+ 1 WHERE id = \\$1 AND EXISTS").
+		WithArgs(orderID).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	_, err := service.UpdateOrderStatus(context.Background(), orderID, &models.UpdateOrderStatusRequest{Status: models.OrderStatusDeliveryFailed})
+	if !apperror.Is(err, apperror.KindConflict) {
+		t.Fatalf("expected conflict without contact attempt, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestOrderService_UpdateOrderStatus_RetryDelivery_Limit(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewOrderService(db, newTestLogger(), newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
+	orderID := uuid.New()
+	courierID := uuid.New()
+	req := &models.UpdateOrderStatusRequest{Status: models.OrderStatusInDelivery}
+
+	// Первая неудача: повтор разрешен
+	expectStatusSelect(mock, orderID, models.OrderStatusDeliveryFailed, courierID)
+	mock.ExpectQuery("SELECT delivery_attempts FROM orders").WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"delivery_attempts"}).AddRow(1))
+	mock.ExpectExec("SELECT set_config").WithArgs("system:unknown").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE orders SET status").
+		WithArgs(models.OrderStatusInDelivery, &courierID, sqlmock.AnyArg(), sqlmock.AnyArg(), orderID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if _, err := service.UpdateOrderStatus(context.Background(), orderID, req); err != nil {
+		t.Fatalf("expected retry within limit, got %v", err)
+	}
+
+	// Лимит по умолчанию исчерпан: заказ нужно возвращать
+	expectStatusSelect(mock, orderID, models.OrderStatusDeliveryFailed, courierID)
+	mock.ExpectQuery("SELECT delivery_attempts FROM orders").WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"delivery_attempts"}).AddRow(DefaultMaxDeliveryAttempts))
+	mock.ExpectRollback()
+	if _, err := service.UpdateOrderStatus(context.Background(), orderID, req); !apperror.Is(err, apperror.KindConflict) {
+		t.Fatalf("expected conflict after attempts limit, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestOrderService_UpdateOrderStatus_Return(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	pricing := newTestPricingService()
+	service := NewOrderService(db, newTestLogger(), pricing, nil, nil, nil, nil, nil, nil, nil, nil)
+	orderID := uuid.New()
+	courierID := uuid.New()
+
+	// delivery_failed -> returning: обратное плечо оценивается, курьер остается занят
+	expectStatusSelect(mock, orderID, models.OrderStatusDeliveryFailed, courierID)
+	mock.ExpectQuery("SELECT region_code, currency, vehicle_type, pickup_lat, pickup_lon, delivery_lat, delivery_lon FROM orders").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"region_code", "currency", "vehicle_type", "pickup_lat", "pickup_lon", "delivery_lat", "delivery_lon"}).
+			AddRow(pricing.DefaultTariff().Code(), "RUB", models.VehicleTypeBike, 55.75, 37.61, 55.80, 37.70))
+	returnCost := pricing.QuoteReturn(pricing.DefaultTariff().Code(), models.VehicleTypeBike, 55.80, 37.70, 55.75, 37.61)
+	mock.ExpectExec("UPDATE orders SET return_cost = \\$1 WHERE id = \\$2").
+		WithArgs(returnCost, orderID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("SELECT set_config").WithArgs("system:unknown").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE orders SET status").
+		WithArgs(models.OrderStatusReturning, &courierID, sqlmock.AnyArg(), sqlmock.AnyArg(), orderID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if _, err := service.UpdateOrderStatus(context.Background(), orderID, &models.UpdateOrderStatusRequest{Status: models.OrderStatusReturning}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// returning -> returned: возврат подтвержден, курьер освобождается
+	expectStatusSelect(mock, orderID, models.OrderStatusReturning, courierID)
+	mock.ExpectExec("SELECT set_config").WithArgs("system:unknown").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE orders SET status").
+		WithArgs(models.OrderStatusReturned, &courierID, sqlmock.AnyArg(), sqlmock.AnyArg(), orderID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE couriers SET status").
+		WithArgs(models.CourierStatusAvailable, sqlmock.AnyArg(), courierID, models.CourierStatusBusy, orderID, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	if _, err := service.UpdateOrderStatus(context.Background(), orderID, &models.UpdateOrderStatusRequest{Status: models.OrderStatusReturned}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Вернуть заказ, минуя returning, нельзя
+	expectStatusSelect(mock, orderID, models.OrderStatusDeliveryFailed, courierID)
+	mock.ExpectRollback()
+	if _, err := service.UpdateOrderStatus(context.Background(), orderID, &models.UpdateOrderStatusRequest{Status: models.OrderStatusReturned}); !apperror.Is(err, apperror.KindConflict) {
+		t.Fatalf("expected conflict for delivery_failed -> returned, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestOrderService_GetDeliveryAttempts(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewOrderService(db, newTestLogger(), newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
+	orderID := uuid.New()
+	now := time.Now()
+
+	mock.ExpectQuery("SELECT status, delivery_attempts, return_cost, currency FROM orders").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "delivery_attempts", "return_cost", "currency"}).
+			AddRow(models.OrderStatusReturning, 2, "250.00", "EUR"))
+	mock.ExpectQuery("SELECT id, order_id, courier_id, attempt, channel, result, note, logged_by, created_at FROM order_contact_attempts").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "courier_id", "attempt", "channel", "result", "note", "logged_by", "created_at"}).
+			AddRow(uuid.New(), orderID, nil, 1, "call", "no_answer", nil, "courier:1", now).
+			AddRow(uuid.New(), orderID, nil, 2, "doorbell", "unreachable", nil, "courier:1", now))
+
+	attempts, err := service.GetDeliveryAttempts(context.Background(), orderID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if attempts.Failed != 2 || attempts.MaxAttempts != DefaultMaxDeliveryAttempts || len(attempts.Contacts) != 2 {
+		t.Fatalf("unexpected attempts: %+v", attempts)
+	}
+	if attempts.ReturnCost == nil || attempts.ReturnCost.Display() != "250.00 EUR" {
+		t.Fatalf("expected return cost in order currency, got %+v", attempts.ReturnCost)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,517 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/database"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+	"delivery-system/internal/money"
+
+	"github.com/google/uuid"
+)
+
+// PayoutRules рассчитывает выплату курьеру за доставку.
+type PayoutRules struct {
+	PerDelivery money.Money
+	PerKm       money.Money
+	MinPayout   money.Money
+}
+
+// NewPayoutRules создаёт правила выплат в указанной валюте; пустая — валюта по умолчанию.
+func NewPayoutRules(perDelivery, perKm, minPayout float64, currency string) *PayoutRules {
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
+	return &PayoutRules{
+		PerDelivery: money.FromFloat(perDelivery, currency),
+		PerKm:       money.FromFloat(perKm, currency),
+		MinPayout:   money.FromFloat(minPayout, currency),
+	}
+}
+
+// Currency возвращает валюту выплат.
+func (r *PayoutRules) Currency() string {
+	return r.PerDelivery.Currency
+}
+
+// Calculate считает выплату за доставку по расстоянию маршрута.
+func (r *PayoutRules) Calculate(distanceKm float64) money.Money {
+	if distanceKm < 0 {
+		distanceKm = 0
+	}
+
+	payout := r.PerDelivery.Add(r.PerKm.MulFloat(distanceKm))
+	return money.Max(payout, r.MinPayout)
+}
+
+// EarningsService ведет журнал начислений курьерам по двойной записи. Каждая проводка
+// ведется в одной валюте, балансы и выплаты по разным валютам не складываются.
+type EarningsService struct {
+	db      *database.DB
+	log     *logger.Logger
+	rules   *PayoutRules
+	pricing *PricingService
+}
+
+// NewEarningsService создает сервис начислений курьерам. rules — правила выплат по умолчанию;
+// pricing, если задан, дает правила регионов заказа.
+func NewEarningsService(db *database.DB, log *logger.Logger, rules *PayoutRules, pricing *PricingService) *EarningsService {
+	return &EarningsService{
+		db:      db,
+		log:     log,
+		rules:   rules,
+		pricing: pricing,
+	}
+}
+
+// payoutRules возвращает правила выплат региона заказа, а если их нет — правила по умолчанию.
+// Правила в другой валюте не применяются: начисление в чужой валюте исказило бы баланс.
+func (s *EarningsService) payoutRules(regionCode, currency string) (*PayoutRules, error) {
+	if s.pricing != nil {
+		if tariff, ok := s.pricing.Tariff(regionCode); ok && tariff.Payout != nil {
+			return tariff.Payout, nil
+		}
+	}
+	if s.rules.Currency() != currency {
+		return nil, apperror.Conflict(fmt.Sprintf("no payout rules for region %q in %s", regionCode, currency), nil)
+	}
+	return s.rules, nil
+}
+
+// RecordDeliveryEarnings начисляет курьеру выплату за доставленный заказ в рамках транзакции смены статуса.
+// Повторный вызов для того же заказа ничего не делает.
+func (s *EarningsService) RecordDeliveryEarnings(ctx context.Context, tx *sql.Tx, orderID, courierID uuid.UUID) error {
+	var (
+		pickupLat, pickupLon, deliveryLat, deliveryLon sql.NullFloat64
+		regionCode, currency                           string
+	)
+	query := `SELECT pickup_lat, pickup_lon, delivery_lat, delivery_lon, region_code, currency FROM orders WHERE id = $1`
+	if err := tx.QueryRowContext(ctx, query, orderID).Scan(&pickupLat, &pickupLon, &deliveryLat, &deliveryLon, &regionCode, &currency); err != nil {
+		if err == sql.ErrNoRows {
+			return apperror.NotFound("order not found", err)
+		}
+		return fmt.Errorf("failed to get order route: %w", err)
+	}
+
+	var distanceKm float64
+	if pickupLat.Valid && pickupLon.Valid && deliveryLat.Valid && deliveryLon.Valid {
+		distanceKm = calculateDistance(pickupLat.Float64, pickupLon.Float64, deliveryLat.Float64, deliveryLon.Float64)
+	}
+
+	rules, err := s.payoutRules(regionCode, currency)
+	if err != nil {
+		return err
+	}
+	amount := rules.Calculate(distanceKm)
+	if !amount.IsPositive() {
+		return nil
+	}
+
+	entry := &models.LedgerTransaction{
+		ID:        uuid.New(),
+		CourierID: courierID,
+		OrderID:   &orderID,
+		Kind:      models.LedgerKindDelivery,
+		Amount:    amount,
+		Currency:  amount.Currency,
+		CreatedAt: time.Now(),
+	}
+
+	inserted, err := s.postTransaction(ctx, tx, entry, models.LedgerAccountDeliveryExpense)
+	if err != nil {
+		return err
+	}
+
+	if inserted {
+		s.log.WithFields(map[string]interface{}{
+			"order_id":    orderID,
+			"courier_id":  courierID,
+			"amount":      entry.Amount,
+			"distance_km": distanceKm,
+		}).Info("Courier delivery earnings recorded")
+	}
+
+	return nil
+}
+
+// AddTip начисляет чаевые курьеру, доставившему заказ, в валюте заказа.
+func (s *EarningsService) AddTip(ctx context.Context, orderID uuid.UUID, req *models.CreateTipRequest) (*models.LedgerTransaction, error) {
+	if req == nil || !req.Amount.IsPositive() {
+		return nil, apperror.Validation("tip amount must be positive", nil)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	var (
+		status    models.OrderStatus
+		courierID *uuid.UUID
+		currency  string
+	)
+	if err := tx.QueryRowContext(ctx, "SELECT status, courier_id, currency FROM orders WHERE id = $1 FOR SHARE", orderID).Scan(&status, &courierID, &currency); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperror.NotFound("order not found", err)
+		}
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	if status != models.OrderStatusDelivered {
+		return nil, apperror.Conflict("tips can only be added to delivered orders", nil)
+	}
+	if courierID == nil {
+		return nil, apperror.Conflict("order has no courier", nil)
+	}
+
+	entry := &models.LedgerTransaction{
+		ID:        uuid.New(),
+		CourierID: *courierID,
+		OrderID:   &orderID,
+		Kind:      models.LedgerKindTip,
+		Amount:    req.Amount.In(currency),
+		Currency:  currency,
+		CreatedAt: time.Now(),
+	}
+
+	if _, err := s.postTransaction(ctx, tx, entry, models.LedgerAccountCustomerTips); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit tip: %w", err)
+	}
+
+	s.log.WithFields(map[string]interface{}{
+		"order_id":   orderID,
+		"courier_id": *courierID,
+		"amount":     entry.Amount,
+	}).Info("Courier tip recorded")
+
+	return entry, nil
+}
+
+// CreateAdjustment проводит бонус или ручную корректировку баланса курьера.
+func (s *EarningsService) CreateAdjustment(ctx context.Context, courierID uuid.UUID, req *models.CreateAdjustmentRequest) (*models.LedgerTransaction, error) {
+	if req == nil {
+		return nil, apperror.Validation("request is required", nil)
+	}
+
+	amount := req.Amount
+	switch req.Kind {
+	case models.LedgerKindBonus:
+		if !amount.IsPositive() {
+			return nil, apperror.Validation("bonus amount must be positive", nil)
+		}
+	case models.LedgerKindAdjustment:
+		if amount.IsZero() {
+			return nil, apperror.Validation("adjustment amount must not be zero", nil)
+		}
+		if req.Description == nil || *req.Description == "" {
+			return nil, apperror.Validation("description is required for adjustments", nil)
+		}
+	default:
+		return nil, apperror.Validation("kind must be bonus or adjustment", nil)
+	}
+
+	currency := strings.ToUpper(strings.TrimSpace(req.Currency))
+	if currency != "" && !money.IsCurrencyCode(currency) {
+		return nil, apperror.Validation("currency must be a 3-letter ISO 4217 code", nil)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Корректировка по заказу ведется в валюте заказа
+	if req.OrderID != nil {
+		var orderCurrency string
+		if err := tx.QueryRowContext(ctx, "SELECT currency FROM orders WHERE id = $1", *req.OrderID).Scan(&orderCurrency); err != nil {
+			if err == sql.ErrNoRows {
+				return nil, apperror.NotFound("order not found", err)
+			}
+			return nil, fmt.Errorf("failed to get order currency: %w", err)
+		}
+		if currency != "" && currency != orderCurrency {
+			return nil, apperror.Validation("currency must match the order currency "+orderCurrency, nil)
+		}
+		currency = orderCurrency
+	}
+	if currency == "" {
+		currency = s.rules.Currency()
+	}
+
+	var exists bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM couriers WHERE id = $1)", courierID).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to check courier: %w", err)
+	}
+	if !exists {
+		return nil, apperror.NotFound("courier not found", nil)
+	}
+
+	entry := &models.LedgerTransaction{
+		ID:          uuid.New(),
+		CourierID:   courierID,
+		OrderID:     req.OrderID,
+		Kind:        req.Kind,
+		Amount:      amount.In(currency),
+		Currency:    currency,
+		Description: req.Description,
+		CreatedAt:   time.Now(),
+	}
+
+	if _, err := s.postTransaction(ctx, tx, entry, models.LedgerAccountCourierAdjustments); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit adjustment: %w", err)
+	}
+
+	s.log.WithFields(map[string]interface{}{
+		"courier_id": courierID,
+		"kind":       req.Kind,
+		"amount":     amount,
+	}).Info("Courier ledger adjustment recorded")
+
+	return entry, nil
+}
+
+// GetBalance возвращает балансы курьера по счету courier_payable, по одному на каждую валюту начислений.
+func (s *EarningsService) GetBalance(ctx context.Context, courierID uuid.UUID) ([]*models.CourierBalance, error) {
+	if err := s.ensureCourierExists(ctx, courierID); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT t.currency, t.kind,
+		       COALESCE(SUM(CASE WHEN e.direction = 'credit' THEN e.amount ELSE -e.amount END), 0)
+		FROM ledger_entries e
+		JOIN ledger_transactions t ON t.id = e.transaction_id
+		WHERE e.account = $1 AND e.courier_id = $2
+		GROUP BY t.currency, t.kind
+		ORDER BY t.currency
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, models.LedgerAccountCourierPayable, courierID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get courier balance: %w", err)
+	}
+	defer rows.Close()
+
+	balances := []*models.CourierBalance{}
+	var balance *models.CourierBalance
+	for rows.Next() {
+		var (
+			currency string
+			kind     models.LedgerKind
+			amount   money.Money
+		)
+		if err := rows.Scan(&currency, &kind, &amount); err != nil {
+			return nil, fmt.Errorf("failed to scan courier balance: %w", err)
+		}
+
+		if balance == nil || balance.Currency != currency {
+			zero := money.Zero(currency)
+			balance = &models.CourierBalance{CourierID: courierID, Currency: currency,
+				Balance: zero, Deliveries: zero, Tips: zero, Bonuses: zero, Adjustments: zero}
+			balances = append(balances, balance)
+		}
+		amount = amount.In(currency)
+
+		switch kind {
+		case models.LedgerKindDelivery:
+			balance.Deliveries = amount
+		case models.LedgerKindTip:
+			balance.Tips = amount
+		case models.LedgerKindBonus:
+			balance.Bonuses = amount
+		case models.LedgerKindAdjustment:
+			balance.Adjustments = amount
+		}
+		balance.Balance = balance.Balance.Add(amount)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate courier balance: %w", err)
+	}
+
+	return balances, nil
+}
+
+// GetStatement возвращает выписку по курьеру за период [from, to] в валюте currency
+// (пустая — валюта выплат по умолчанию).
+func (s *EarningsService) GetStatement(ctx context.Context, courierID uuid.UUID, currency string, from, to time.Time) (*models.CourierStatement, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		currency = s.rules.Currency()
+	}
+	if !money.IsCurrencyCode(currency) {
+		return nil, apperror.Validation("currency must be a 3-letter ISO 4217 code", nil)
+	}
+	if err := s.ensureCourierExists(ctx, courierID); err != nil {
+		return nil, err
+	}
+
+	statement := &models.CourierStatement{
+		CourierID:      courierID,
+		Currency:       currency,
+		From:           from,
+		To:             to,
+		OpeningBalance: money.Zero(currency),
+		Transactions:   []*models.LedgerTransaction{},
+	}
+
+	openingQuery := `
+		SELECT COALESCE(SUM(CASE WHEN e.direction = 'credit' THEN e.amount ELSE -e.amount END), 0)
+		FROM ledger_entries e
+		JOIN ledger_transactions t ON t.id = e.transaction_id
+		WHERE e.account = $1 AND e.courier_id = $2 AND t.currency = $3 AND t.created_at < $4
+	`
+	if err := s.db.QueryRowContext(ctx, openingQuery, models.LedgerAccountCourierPayable, courierID, currency, from).Scan(&statement.OpeningBalance); err != nil {
+		return nil, fmt.Errorf("failed to get opening balance: %w", err)
+	}
+
+	query := `
+		SELECT id, courier_id, order_id, kind, amount, currency, description, created_at
+		FROM ledger_transactions
+		WHERE courier_id = $1 AND currency = $2 AND created_at >= $3 AND created_at <= $4
+		ORDER BY created_at ASC, id ASC
+	`
+	rows, err := s.db.QueryContext(ctx, query, courierID, currency, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get courier statement: %w", err)
+	}
+	defer rows.Close()
+
+	closing := statement.OpeningBalance
+	for rows.Next() {
+		t := &models.LedgerTransaction{Amount: money.Zero(currency)}
+		if err := rows.Scan(&t.ID, &t.CourierID, &t.OrderID, &t.Kind, &t.Amount, &t.Currency, &t.Description, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan ledger transaction: %w", err)
+		}
+		closing = closing.Add(t.Amount)
+		statement.Transactions = append(statement.Transactions, t)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate ledger transactions: %w", err)
+	}
+
+	statement.ClosingBalance = closing
+	return statement, nil
+}
+
+// GetPayouts формирует реестр выплат за период [from, to]: строка на курьера и валюту начислений.
+func (s *EarningsService) GetPayouts(ctx context.Context, from, to time.Time) ([]*models.PayoutLine, error) {
+	query := `
+		SELECT c.id, c.name, t.currency,
+		       COUNT(*) FILTER (WHERE t.kind = 'delivery') AS deliveries,
+		       COALESCE(SUM(CASE WHEN e.direction = 'credit' THEN e.amount ELSE -e.amount END) FILTER (WHERE t.kind = 'delivery'), 0) AS earnings,
+		       COALESCE(SUM(CASE WHEN e.direction = 'credit' THEN e.amount ELSE -e.amount END) FILTER (WHERE t.kind = 'tip'), 0) AS tips,
+		       COALESCE(SUM(CASE WHEN e.direction = 'credit' THEN e.amount ELSE -e.amount END) FILTER (WHERE t.kind = 'bonus'), 0) AS bonuses,
+		       COALESCE(SUM(CASE WHEN e.direction = 'credit' THEN e.amount ELSE -e.amount END) FILTER (WHERE t.kind = 'adjustment'), 0) AS adjustments
+		FROM ledger_entries e
+		JOIN ledger_transactions t ON t.id = e.transaction_id
+		JOIN couriers c ON c.id = e.courier_id
+		WHERE e.account = $1 AND t.created_at >= $2 AND t.created_at <= $3
+		GROUP BY c.id, c.name, t.currency
+		ORDER BY c.name ASC, t.currency ASC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, models.LedgerAccountCourierPayable, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get payouts: %w", err)
+	}
+	defer rows.Close()
+
+	lines := []*models.PayoutLine{}
+	for rows.Next() {
+		line := &models.PayoutLine{}
+		if err := rows.Scan(&line.CourierID, &line.CourierName, &line.Currency, &line.Deliveries, &line.Earnings, &line.Tips, &line.Bonuses, &line.Adjustments); err != nil {
+			return nil, fmt.Errorf("failed to scan payout line: %w", err)
+		}
+		line.Earnings = line.Earnings.In(line.Currency)
+		line.Tips = line.Tips.In(line.Currency)
+		line.Bonuses = line.Bonuses.In(line.Currency)
+		line.Adjustments = line.Adjustments.In(line.Currency)
+		line.Total = line.Earnings.Add(line.Tips).Add(line.Bonuses).Add(line.Adjustments)
+		lines = append(lines, line)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate payouts: %w", err)
+	}
+
+	return lines, nil
+}
+
+// postTransaction записывает проводку и две строки: счет-источник и счет курьера.
+// Возвращает false, если начисление за доставку по заказу уже существует.
+func (s *EarningsService) postTransaction(ctx context.Context, tx *sql.Tx, entry *models.LedgerTransaction, counterAccount string) (bool, error) {
+	insertTx := `
+		INSERT INTO ledger_transactions (id, courier_id, order_id, kind, amount, currency, description, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (order_id) WHERE kind = 'delivery' DO NOTHING
+	`
+	result, err := tx.ExecContext(ctx, insertTx, entry.ID, entry.CourierID, entry.OrderID, entry.Kind, entry.Amount, entry.Currency, entry.Description, entry.CreatedAt)
+	if err != nil {
+		return false, fmt.Errorf("failed to create ledger transaction: %w", err)
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
+	}
+	if rowsAffected == 0 {
+		return false, nil
+	}
+
+	// Положительная сумма: дебет счета-источника, кредит счета курьера; отрицательная — наоборот
+	courierDirection, counterDirection := models.LedgerCredit, models.LedgerDebit
+	if entry.Amount.IsNegative() {
+		courierDirection, counterDirection = models.LedgerDebit, models.LedgerCredit
+	}
+	amount := entry.Amount
+	if amount.IsNegative() {
+		amount = amount.Neg()
+	}
+	courierID := entry.CourierID
+
+	entry.Entries = []models.LedgerEntry{
+		{ID: uuid.New(), TransactionID: entry.ID, Account: counterAccount, Direction: counterDirection, Amount: amount, CreatedAt: entry.CreatedAt},
+		{ID: uuid.New(), TransactionID: entry.ID, Account: models.LedgerAccountCourierPayable, CourierID: &courierID, Direction: courierDirection, Amount: amount, CreatedAt: entry.CreatedAt},
+	}
+
+	insertEntry := `
+		INSERT INTO ledger_entries (id, transaction_id, account, courier_id, direction, amount, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	for _, e := range entry.Entries {
+		if _, err := tx.ExecContext(ctx, insertEntry, e.ID, e.TransactionID, e.Account, e.CourierID, e.Direction, e.Amount, e.CreatedAt); err != nil {
+			return false, fmt.Errorf("failed to create ledger entry: %w", err)
+		}
+	}
+
+	return true, nil
+}
+
+func (s *EarningsService) ensureCourierExists(ctx context.Context, courierID uuid.UUID) error {
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM couriers WHERE id = $1)", courierID).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check courier: %w", err)
+	}
+	if !exists {
+		return apperror.NotFound("courier not found", nil)
+	}
+	return nil
+}
@@ -0,0 +1,394 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/models"
+	"delivery-system/internal/money"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+func newTestPayoutRules() *PayoutRules {
+	return NewPayoutRules(60, 12, 90, "RUB")
+}
+
+func rub(v float64) money.Money {
+	return money.FromFloat(v, "RUB")
+}
+
+func TestPayoutRules_Calculate(t *testing.T) {
+	rules := newTestPayoutRules()
+
+	if got := rules.Calculate(0); got.String() != "90.00" {
+		t.Fatalf("expected min payout 90, got %s", got)
+	}
+	if got := rules.Calculate(5); got.String() != "120.00" {
+		t.Fatalf("expected 120, got %s", got)
+	}
+	if got := rules.Calculate(-3); got.String() != "90.00" {
+		t.Fatalf("expected negative distance to be treated as zero, got %s", got)
+	}
+	if got := rules.Calculate(1.234); got.String() != "90.00" {
+		t.Fatalf("expected min payout for short route, got %s", got)
+	}
+	if got := rules.Calculate(2.5555); got.String() != "90.67" {
+		t.Fatalf("expected rounded payout 90.67, got %s", got)
+	}
+}
+
+func TestEarningsService_RecordDeliveryEarnings(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewEarningsService(db, newTestLogger(), newTestPayoutRules(), nil)
+	orderID := uuid.New()
+	courierID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT pickup_lat, pickup_lon, delivery_lat, delivery_lon, region_code, currency FROM orders").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"pickup_lat", "pickup_lon", "delivery_lat", "delivery_lon", "region_code", "currency"}).
+			AddRow(55.0, 37.0, 55.0, 37.0, "default", "RUB"))
+	mock.ExpectExec("INSERT INTO ledger_transactions").
+		WithArgs(sqlmock.AnyArg(), courierID, sqlmock.AnyArg(), models.LedgerKindDelivery, rub(90.0), "RUB", nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), models.LedgerAccountDeliveryExpense, nil, models.LedgerDebit, rub(90.0), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), models.LedgerAccountCourierPayable, sqlmock.AnyArg(), models.LedgerCredit, rub(90.0), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("failed to begin tx: %v", err)
+	}
+	if err := service.RecordDeliveryEarnings(context.Background(), tx, orderID, courierID); err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestEarningsService_RecordDeliveryEarnings_RegionPayout(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	pricing, err := NewRegionalPricingService(models.Region{Currency: "RUB", BaseFare: 100, PerKm: 20, MinFare: 150}, []models.Region{
+		{Code: "berlin", Currency: "EUR", BaseFare: 3, PerKm: 1, MinFare: 5, Bounds: &models.GeoBounds{MinLat: 52, MinLon: 13, MaxLat: 53, MaxLon: 14},
+			Payout: &models.RegionPayout{PerDelivery: 2, PerKm: 0.5, MinPayout: 4}},
+	})
+	if err != nil {
+		t.Fatalf("pricing: %v", err)
+	}
+	service := NewEarningsService(db, newTestLogger(), newTestPayoutRules(), pricing)
+	orderID := uuid.New()
+	courierID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT pickup_lat, pickup_lon, delivery_lat, delivery_lon, region_code, currency FROM orders").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"pickup_lat", "pickup_lon", "delivery_lat", "delivery_lon", "region_code", "currency"}).
+			AddRow(52.5, 13.4, 52.5, 13.4, "berlin", "EUR"))
+	mock.ExpectExec("INSERT INTO ledger_transactions").
+		WithArgs(sqlmock.AnyArg(), courierID, sqlmock.AnyArg(), models.LedgerKindDelivery, money.FromFloat(4, "EUR"), "EUR", nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectRollback()
+
+	tx, _ := db.BeginTx(context.Background(), nil)
+	if err := service.RecordDeliveryEarnings(context.Background(), tx, orderID, courierID); err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+	_ = tx.Rollback()
+
+	// Правила по умолчанию не применяются к заказу в другой валюте
+	service = NewEarningsService(db, newTestLogger(), newTestPayoutRules(), nil)
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT pickup_lat, pickup_lon, delivery_lat, delivery_lon, region_code, currency FROM orders").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"pickup_lat", "pickup_lon", "delivery_lat", "delivery_lon", "region_code", "currency"}).
+			AddRow(52.5, 13.4, 52.5, 13.4, "berlin", "EUR"))
+	mock.ExpectRollback()
+
+	tx, _ = db.BeginTx(context.Background(), nil)
+	if err := service.RecordDeliveryEarnings(context.Background(), tx, orderID, courierID); !apperror.Is(err, apperror.KindConflict) {
+		t.Fatalf("expected conflict for missing EUR payout rules, got %v", err)
+	}
+	_ = tx.Rollback()
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestEarningsService_RecordDeliveryEarnings_AlreadyRecorded(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewEarningsService(db, newTestLogger(), newTestPayoutRules(), nil)
+	orderID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT pickup_lat, pickup_lon, delivery_lat, delivery_lon, region_code, currency FROM orders").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"pickup_lat", "pickup_lon", "delivery_lat", "delivery_lon", "region_code", "currency"}).
+			AddRow(nil, nil, nil, nil, "default", "RUB"))
+	mock.ExpectExec("INSERT INTO ledger_transactions").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("failed to begin tx: %v", err)
+	}
+	if err := service.RecordDeliveryEarnings(context.Background(), tx, orderID, uuid.New()); err != nil {
+		t.Fatalf("expected duplicate to be ignored, got %v", err)
+	}
+	_ = tx.Rollback()
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestEarningsService_AddTip(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewEarningsService(db, newTestLogger(), newTestPayoutRules(), nil)
+	orderID := uuid.New()
+	courierID := uuid.New()
+
+	// Чаевые начисляются в валюте заказа
+	eur := money.FromFloat(50.5, "EUR")
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, courier_id, currency FROM orders").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "courier_id", "currency"}).AddRow(models.OrderStatusDelivered, courierID, "EUR"))
+	mock.ExpectExec("INSERT INTO ledger_transactions").
+		WithArgs(sqlmock.AnyArg(), courierID, sqlmock.AnyArg(), models.LedgerKindTip, eur, "EUR", nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), models.LedgerAccountCustomerTips, nil, models.LedgerDebit, eur, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), models.LedgerAccountCourierPayable, sqlmock.AnyArg(), models.LedgerCredit, eur, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	entry, err := service.AddTip(context.Background(), orderID, &models.CreateTipRequest{Amount: money.FromFloat(50.499, "RUB")})
+	if err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+	if entry.CourierID != courierID || len(entry.Entries) != 2 || entry.Currency != "EUR" {
+		t.Fatalf("unexpected tip transaction: %+v", entry)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestEarningsService_AddTip_Errors(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewEarningsService(db, newTestLogger(), newTestPayoutRules(), nil)
+	orderID := uuid.New()
+
+	if _, err := service.AddTip(context.Background(), orderID, &models.CreateTipRequest{Amount: rub(0)}); !apperror.Is(err, apperror.KindValidation) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, courier_id, currency FROM orders").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "courier_id", "currency"}).AddRow(models.OrderStatusInDelivery, uuid.New(), "RUB"))
+	mock.ExpectRollback()
+
+	if _, err := service.AddTip(context.Background(), orderID, &models.CreateTipRequest{Amount: rub(10)}); !apperror.Is(err, apperror.KindConflict) {
+		t.Fatalf("expected conflict error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestEarningsService_CreateAdjustment_Negative(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewEarningsService(db, newTestLogger(), newTestPayoutRules(), nil)
+	courierID := uuid.New()
+	desc := "damaged item"
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(courierID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectExec("INSERT INTO ledger_transactions").
+		WithArgs(sqlmock.AnyArg(), courierID, nil, models.LedgerKindAdjustment, rub(-30.0), "RUB", desc, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), models.LedgerAccountCourierAdjustments, nil, models.LedgerCredit, rub(30.0), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), models.LedgerAccountCourierPayable, sqlmock.AnyArg(), models.LedgerDebit, rub(30.0), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	req := &models.CreateAdjustmentRequest{Kind: models.LedgerKindAdjustment, Amount: rub(-30), Description: &desc}
+	if _, err := service.CreateAdjustment(context.Background(), courierID, req); err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestEarningsService_CreateAdjustment_Validation(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewEarningsService(db, newTestLogger(), newTestPayoutRules(), nil)
+	courierID := uuid.New()
+
+	cases := []*models.CreateAdjustmentRequest{
+		{Kind: models.LedgerKindBonus, Amount: rub(-5)},
+		{Kind: models.LedgerKindAdjustment, Amount: rub(10)},
+		{Kind: models.LedgerKindDelivery, Amount: rub(10)},
+		{Kind: models.LedgerKindBonus, Amount: rub(10), Currency: "euro"},
+	}
+	for _, req := range cases {
+		if _, err := service.CreateAdjustment(context.Background(), courierID, req); !apperror.Is(err, apperror.KindValidation) {
+			t.Fatalf("expected validation error for %+v, got %v", req, err)
+		}
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(courierID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectRollback()
+
+	if _, err := service.CreateAdjustment(context.Background(), courierID, &models.CreateAdjustmentRequest{Kind: models.LedgerKindBonus, Amount: rub(100)}); !apperror.Is(err, apperror.KindNotFound) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestEarningsService_GetBalance(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewEarningsService(db, newTestLogger(), newTestPayoutRules(), nil)
+	courierID := uuid.New()
+
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(courierID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("SELECT t.currency, t.kind").
+		WithArgs(models.LedgerAccountCourierPayable, courierID).
+		WillReturnRows(sqlmock.NewRows([]string{"currency", "kind", "sum"}).
+			AddRow("EUR", models.LedgerKindDelivery, 15.0).
+			AddRow("RUB", models.LedgerKindDelivery, 300.0).
+			AddRow("RUB", models.LedgerKindTip, 50.0).
+			AddRow("RUB", models.LedgerKindAdjustment, -20.0))
+
+	balances, err := service.GetBalance(context.Background(), courierID)
+	if err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+	if len(balances) != 2 {
+		t.Fatalf("expected a balance per currency, got %d", len(balances))
+	}
+	if eur := balances[0]; eur.Currency != "EUR" || eur.Balance != money.FromFloat(15, "EUR") || eur.Tips != money.Zero("EUR") {
+		t.Fatalf("unexpected EUR balance: %+v", eur)
+	}
+	if balance := balances[1]; balance.Balance != rub(330) || balance.Deliveries != rub(300) || balance.Tips != rub(50) || balance.Adjustments != rub(-20) {
+		t.Fatalf("unexpected balance: %+v", balance)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestEarningsService_GetStatement(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewEarningsService(db, newTestLogger(), newTestPayoutRules(), nil)
+	courierID := uuid.New()
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 5, 31, 23, 59, 59, 0, time.UTC)
+
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs(courierID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("SELECT COALESCE").
+		WithArgs(models.LedgerAccountCourierPayable, courierID, "RUB", from).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(100.0))
+	mock.ExpectQuery("SELECT id, courier_id, order_id, kind, amount, currency, description, created_at FROM ledger_transactions").
+		WithArgs(courierID, "RUB", from, to).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "courier_id", "order_id", "kind", "amount", "currency", "description", "created_at"}).
+			AddRow(uuid.New(), courierID, uuid.New(), models.LedgerKindDelivery, 120.0, "RUB", nil, from.Add(time.Hour)).
+			AddRow(uuid.New(), courierID, nil, models.LedgerKindBonus, 30.0, "RUB", "weekend", from.Add(2*time.Hour)))
+
+	statement, err := service.GetStatement(context.Background(), courierID, "", from, to)
+	if err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+	if statement.OpeningBalance != rub(100) || statement.ClosingBalance != rub(250) || len(statement.Transactions) != 2 {
+		t.Fatalf("unexpected statement: %+v", statement)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestEarningsService_GetPayouts(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewEarningsService(db, newTestLogger(), newTestPayoutRules(), nil)
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 5, 31, 23, 59, 59, 0, time.UTC)
+
+	mock.ExpectQuery("SELECT c.id, c.name").
+		WithArgs(models.LedgerAccountCourierPayable, from, to).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "name", "currency", "deliveries", "earnings", "tips", "bonuses", "adjustments"}).
+			AddRow(uuid.New(), "Иван", "EUR", 1, 12.0, 0.0, 0.0, 0.0).
+			AddRow(uuid.New(), "Иван", "RUB", 3, 300.0, 40.0, 0.0, -10.0))
+
+	lines, err := service.GetPayouts(context.Background(), from, to)
+	if err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+	if len(lines) != 2 || lines[0].Total != money.FromFloat(12, "EUR") || lines[1].Total != rub(330) || lines[1].Deliveries != 3 {
+		t.Fatalf("unexpected payouts: %+v", lines)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,527 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"time"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/config"
+	"delivery-system/internal/database"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+	"delivery-system/internal/money"
+	"delivery-system/internal/pagination"
+
+	"github.com/google/uuid"
+)
+
+const defaultLoyaltyTTLDays = 365
+
+// PointsRedemption — результат списания баллов в счет заказа.
+type PointsRedemption struct {
+	Points   int
+	Discount money.Money
+}
+
+// LoyaltyService ведет баллы лояльности клиентов. Баллы начисляются партиями со сроком действия,
+// списываются начиная с ближайших к сгоранию; истекшие партии сгорают при обращении к счету.
+type LoyaltyService struct {
+	db          *database.DB
+	log         *logger.Logger
+	earnRate    float64
+	earnRates   map[string]float64
+	pointValue  float64
+	pointValues map[string]float64
+	ttl         time.Duration
+}
+
+// NewLoyaltyService создает сервис баллов лояльности.
+func NewLoyaltyService(db *database.DB, log *logger.Logger, cfg *config.LoyaltyConfig) *LoyaltyService {
+	s := &LoyaltyService{
+		db:         db,
+		log:        log,
+		pointValue: 1,
+		ttl:        defaultLoyaltyTTLDays * 24 * time.Hour,
+	}
+	if cfg != nil {
+		s.earnRate = cfg.EarnRate
+		s.earnRates = cfg.EarnRates
+		if cfg.PointValue > 0 {
+			s.pointValue = cfg.PointValue
+		}
+		s.pointValues = cfg.PointValues
+		if cfg.TTLDays > 0 {
+			s.ttl = time.Duration(cfg.TTLDays) * 24 * time.Hour
+		}
+	}
+	return s
+}
+
+// GetAccount возвращает баланс баллов клиента, предварительно списав сгоревшие партии.
+func (s *LoyaltyService) GetAccount(ctx context.Context, customerPhone string) (*models.LoyaltyAccount, error) {
+	phone := normalizePhone(customerPhone)
+	if phone == "" {
+		return nil, apperror.Validation("customer phone is required", nil)
+	}
+	if err := s.expire(ctx, phone); err != nil {
+		return nil, err
+	}
+
+	account := &models.LoyaltyAccount{CustomerPhone: phone}
+	if err := s.db.QueryRowContext(ctx, `SELECT balance FROM loyalty_accounts WHERE customer_phone = $1`, phone).Scan(&account.Balance); err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get loyalty balance: %w", err)
+	}
+
+	var nextExpiry sql.NullTime
+	query := `
+		SELECT expires_at, SUM(remaining)
+		FROM loyalty_transactions
+		WHERE customer_phone = $1 AND remaining > 0
+		GROUP BY expires_at
+		ORDER BY expires_at
+		LIMIT 1
+	`
+	if err := s.db.QueryRowContext(ctx, query, phone).Scan(&nextExpiry, &account.ExpiringPoints); err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to get next points expiry: %w", err)
+	}
+	if nextExpiry.Valid {
+		account.NextExpiry = &nextExpiry.Time
+	}
+
+	return account, nil
+}
+
+// ListHistory возвращает операции по баллам клиента, новые первыми.
+func (s *LoyaltyService) ListHistory(ctx context.Context, customerPhone string, page pagination.Request) (*pagination.Page[*models.LoyaltyTransaction], error) {
+	phone := normalizePhone(customerPhone)
+	if phone == "" {
+		return nil, apperror.Validation("customer phone is required", nil)
+	}
+	if err := page.CheckSort(sortCreatedAt); err != nil {
+		return nil, err
+	}
+	if page.Limit <= 0 {
+		page.Limit = 50
+	}
+	if err := s.expire(ctx, phone); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT id, customer_phone, kind, points, remaining, order_id, expires_at, created_at
+		FROM loyalty_transactions
+		WHERE customer_phone = $1`
+	args := []interface{}{phone}
+	if page.After != nil {
+		query += " AND " + keysetCondition([]string{"created_at", "id"}, &args, page.After.CreatedAt, page.After.ID)
+	}
+	query += " ORDER BY created_at DESC, id DESC" + pageLimitClause(page, &args)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list loyalty transactions: %w", err)
+	}
+	defer rows.Close()
+
+	var history []*models.LoyaltyTransaction
+	for rows.Next() {
+		t := &models.LoyaltyTransaction{}
+		if err := rows.Scan(&t.ID, &t.CustomerPhone, &t.Kind, &t.Points, &t.Remaining, &t.OrderID, &t.ExpiresAt, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan loyalty transaction: %w", err)
+		}
+		history = append(history, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate loyalty transactions: %w", err)
+	}
+
+	return pagination.NewPage(history, page.Limit, func(t *models.LoyaltyTransaction) pagination.Cursor {
+		return pagination.Cursor{Sort: sortCreatedAt, CreatedAt: t.CreatedAt, ID: t.ID.String()}
+	}), nil
+}
+
+// RedeemWithTx списывает баллы в счет заказа в рамках транзакции его создания. Скидка не превышает due:
+// лишние баллы не списываются. Если баллов на счете меньше запрошенного, возвращается конфликт.
+func (s *LoyaltyService) RedeemWithTx(ctx context.Context, tx *sql.Tx, customerPhone string, orderID uuid.UUID, points int, due money.Money) (*PointsRedemption, error) {
+	result := &PointsRedemption{Discount: money.Zero(due.Currency)}
+	if points < 0 {
+		return nil, apperror.Validation("redeem_points must not be negative", nil)
+	}
+	if points == 0 {
+		return result, nil
+	}
+
+	phone := normalizePhone(customerPhone)
+	if phone == "" {
+		return nil, apperror.Validation("customer phone is required to redeem points", nil)
+	}
+
+	now := time.Now()
+	balance, err := lockLoyaltyAccount(ctx, tx, phone)
+	if err != nil {
+		return nil, err
+	}
+	expired, err := expireLoyaltyLots(ctx, tx, phone, now)
+	if err != nil {
+		return nil, err
+	}
+	balance -= expired
+	if points > balance {
+		return nil, apperror.Conflict(fmt.Sprintf("not enough loyalty points: %d available", balance), nil)
+	}
+
+	value := money.FromFloat(s.valueFor(due.Currency), due.Currency)
+	if value.IsPositive() {
+		if byDue := int(due.Amount / value.Amount); points > byDue {
+			points = byDue
+		}
+	}
+	if points <= 0 {
+		return result, nil
+	}
+
+	latestExpiry, err := consumeLoyaltyLots(ctx, tx, phone, points)
+	if err != nil {
+		return nil, err
+	}
+	if err := addLoyaltyBalance(ctx, tx, phone, -points, now); err != nil {
+		return nil, err
+	}
+	if err := insertLoyaltyTransaction(ctx, tx, &models.LoyaltyTransaction{
+		ID:            uuid.New(),
+		CustomerPhone: phone,
+		Kind:          models.LoyaltyKindRedeem,
+		Points:        -points,
+		OrderID:       &orderID,
+		ExpiresAt:     latestExpiry,
+		CreatedAt:     now,
+	}); err != nil {
+		return nil, err
+	}
+
+	result.Points = points
+	result.Discount = value.Mul(int64(points))
+	return result, nil
+}
+
+// EarnWithTx начисляет баллы за доставленный заказ по ставке его валюты от оплаченной суммы.
+func (s *LoyaltyService) EarnWithTx(ctx context.Context, tx *sql.Tx, orderID uuid.UUID) error {
+	var (
+		phone    string
+		currency string
+		total    money.Money
+	)
+	query := `SELECT customer_phone, currency, total_amount FROM orders WHERE id = $1`
+	if err := tx.QueryRowContext(ctx, query, orderID).Scan(&phone, &currency, &total); err != nil {
+		return fmt.Errorf("failed to get order for loyalty points: %w", err)
+	}
+
+	phone = normalizePhone(phone)
+	points := int(math.Floor(total.In(currency).Float64() * s.rateFor(currency)))
+	if phone == "" || points <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(s.ttl)
+	if err := addLoyaltyBalance(ctx, tx, phone, points, now); err != nil {
+		return err
+	}
+	if err := insertLoyaltyTransaction(ctx, tx, &models.LoyaltyTransaction{
+		ID:            uuid.New(),
+		CustomerPhone: phone,
+		Kind:          models.LoyaltyKindEarn,
+		Points:        points,
+		Remaining:     points,
+		OrderID:       &orderID,
+		ExpiresAt:     &expiresAt,
+		CreatedAt:     now,
+	}); err != nil {
+		return err
+	}
+
+	s.log.WithFields(map[string]interface{}{
+		"order_id": orderID,
+		"points":   points,
+	}).Info("Loyalty points earned")
+
+	return nil
+}
+
+// ReverseOrderWithTx возвращает на счет баллы, списанные в счет отмененного заказа.
+// Возвращенные баллы сохраняют срок действия самой поздней из потраченных партий.
+func (s *LoyaltyService) ReverseOrderWithTx(ctx context.Context, tx *sql.Tx, orderID uuid.UUID) error {
+	var (
+		phone     string
+		points    int
+		expiresAt *time.Time
+	)
+	query := `
+		SELECT customer_phone, points, expires_at
+		FROM loyalty_transactions
+		WHERE order_id = $1 AND kind = 'redeem'
+	`
+	if err := tx.QueryRowContext(ctx, query, orderID).Scan(&phone, &points, &expiresAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return fmt.Errorf("failed to get points redemption: %w", err)
+	}
+
+	restored := -points
+	now := time.Now()
+	if err := addLoyaltyBalance(ctx, tx, phone, restored, now); err != nil {
+		return err
+	}
+	return insertLoyaltyTransaction(ctx, tx, &models.LoyaltyTransaction{
+		ID:            uuid.New(),
+		CustomerPhone: phone,
+		Kind:          models.LoyaltyKindReversal,
+		Points:        restored,
+		Remaining:     restored,
+		OrderID:       &orderID,
+		ExpiresAt:     expiresAt,
+		CreatedAt:     now,
+	})
+}
+
+// ReverseForRefundWithTx отзывает начисленные за заказ баллы пропорционально возвращенной сумме.
+// refunded — суммарный возврат по платежу, поэтому повторный вызов отзывает только разницу.
+// Уже потраченные клиентом баллы не отзываются.
+func (s *LoyaltyService) ReverseForRefundWithTx(ctx context.Context, tx *sql.Tx, orderID uuid.UUID, refunded, captured money.Money) error {
+	if !captured.IsPositive() || !refunded.IsPositive() {
+		return nil
+	}
+
+	var (
+		phone    string
+		earned   int
+		reversed int
+	)
+	query := `
+		SELECT e.customer_phone, e.points,
+		       COALESCE((SELECT -SUM(r.points) FROM loyalty_transactions r
+		                 WHERE r.order_id = e.order_id AND r.kind = 'reversal' AND r.points < 0), 0)
+		FROM loyalty_transactions e
+		WHERE e.order_id = $1 AND e.kind = 'earn'
+	`
+	if err := tx.QueryRowContext(ctx, query, orderID).Scan(&phone, &earned, &reversed); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return fmt.Errorf("failed to get earned points: %w", err)
+	}
+
+	refundedMinor := money.Min(refunded.In(captured.Currency), captured).Amount
+	target := int(int64(earned) * refundedMinor / captured.Amount)
+	delta := target - reversed
+	if delta <= 0 {
+		return nil
+	}
+
+	now := time.Now()
+	balance, err := lockLoyaltyAccount(ctx, tx, phone)
+	if err != nil {
+		return err
+	}
+	expired, err := expireLoyaltyLots(ctx, tx, phone, now)
+	if err != nil {
+		return err
+	}
+	if available := balance - expired; delta > available {
+		delta = available
+	}
+	if delta <= 0 {
+		return nil
+	}
+
+	if _, err := consumeLoyaltyLots(ctx, tx, phone, delta); err != nil {
+		return err
+	}
+	if err := addLoyaltyBalance(ctx, tx, phone, -delta, now); err != nil {
+		return err
+	}
+	return insertLoyaltyTransaction(ctx, tx, &models.LoyaltyTransaction{
+		ID:            uuid.New(),
+		CustomerPhone: phone,
+		Kind:          models.LoyaltyKindReversal,
+		Points:        -delta,
+		OrderID:       &orderID,
+		CreatedAt:     now,
+	})
+}
+
+// expire списывает сгоревшие партии клиента в отдельной транзакции.
+func (s *LoyaltyService) expire(ctx context.Context, phone string) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err := lockLoyaltyAccount(ctx, tx, phone); err != nil {
+		return err
+	}
+	if _, err := expireLoyaltyLots(ctx, tx, phone, time.Now()); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit points expiry: %w", err)
+	}
+	return nil
+}
+
+func (s *LoyaltyService) rateFor(currency string) float64 {
+	if rate, ok := s.earnRates[currency]; ok {
+		return rate
+	}
+	return s.earnRate
+}
+
+func (s *LoyaltyService) valueFor(currency string) float64 {
+	if value, ok := s.pointValues[currency]; ok {
+		return value
+	}
+	return s.pointValue
+}
+
+// lockLoyaltyAccount блокирует счет клиента и возвращает баланс; для клиента без счета — 0.
+func lockLoyaltyAccount(ctx context.Context, tx *sql.Tx, phone string) (int, error) {
+	var balance int
+	query := `SELECT balance FROM loyalty_accounts WHERE customer_phone = $1 FOR UPDATE`
+	if err := tx.QueryRowContext(ctx, query, phone).Scan(&balance); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to lock loyalty account: %w", err)
+	}
+	return balance, nil
+}
+
+// expireLoyaltyLots обнуляет остатки истекших партий, уменьшает баланс и возвращает число сгоревших баллов.
+func expireLoyaltyLots(ctx context.Context, tx *sql.Tx, phone string, now time.Time) (int, error) {
+	var expired int
+	query := `
+		WITH expired AS (
+			SELECT id, remaining
+			FROM loyalty_transactions
+			WHERE customer_phone = $1 AND remaining > 0 AND expires_at <= $2
+			FOR UPDATE
+		), cleared AS (
+			UPDATE loyalty_transactions t
+			SET remaining = 0
+			FROM expired e
+			WHERE t.id = e.id
+		)
+		SELECT COALESCE(SUM(remaining), 0) FROM expired
+	`
+	if err := tx.QueryRowContext(ctx, query, phone, now).Scan(&expired); err != nil {
+		return 0, fmt.Errorf("failed to expire loyalty points: %w", err)
+	}
+	if expired == 0 {
+		return 0, nil
+	}
+
+	if err := addLoyaltyBalance(ctx, tx, phone, -expired, now); err != nil {
+		return 0, err
+	}
+	if err := insertLoyaltyTransaction(ctx, tx, &models.LoyaltyTransaction{
+		ID:            uuid.New(),
+		CustomerPhone: phone,
+		Kind:          models.LoyaltyKindExpire,
+		Points:        -expired,
+		CreatedAt:     now,
+	}); err != nil {
+		return 0, err
+	}
+	return expired, nil
+}
+
+// consumeLoyaltyLots уменьшает остатки партий начиная с ближайших к сгоранию и возвращает
+// самый поздний срок действия среди затронутых партий.
+func consumeLoyaltyLots(ctx context.Context, tx *sql.Tx, phone string, points int) (*time.Time, error) {
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, remaining, expires_at
+		FROM loyalty_transactions
+		WHERE customer_phone = $1 AND remaining > 0
+		ORDER BY expires_at, created_at
+		FOR UPDATE
+	`, phone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock loyalty points: %w", err)
+	}
+
+	type lotUse struct {
+		id   uuid.UUID
+		left int
+	}
+	var (
+		uses   []lotUse
+		latest *time.Time
+	)
+	left := points
+	for rows.Next() && left > 0 {
+		var (
+			id        uuid.UUID
+			remaining int
+			expiresAt *time.Time
+		)
+		if err := rows.Scan(&id, &remaining, &expiresAt); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan loyalty points: %w", err)
+		}
+		take := remaining
+		if take > left {
+			take = left
+		}
+		left -= take
+		uses = append(uses, lotUse{id: id, left: remaining - take})
+		if expiresAt != nil {
+			latest = expiresAt
+		}
+	}
+	if err := rows.Err(); err != nil {
+		rows.Close()
+		return nil, fmt.Errorf("failed to iterate loyalty points: %w", err)
+	}
+	rows.Close()
+
+	if left > 0 {
+		return nil, apperror.Conflict("not enough loyalty points", nil)
+	}
+
+	for _, use := range uses {
+		if _, err := tx.ExecContext(ctx, `UPDATE loyalty_transactions SET remaining = $1 WHERE id = $2`, use.left, use.id); err != nil {
+			return nil, fmt.Errorf("failed to update loyalty points: %w", err)
+		}
+	}
+	return latest, nil
+}
+
+func addLoyaltyBalance(ctx context.Context, tx *sql.Tx, phone string, delta int, now time.Time) error {
+	query := `
+		INSERT INTO loyalty_accounts (customer_phone, balance, updated_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (customer_phone)
+		DO UPDATE SET balance = loyalty_accounts.balance + EXCLUDED.balance, updated_at = EXCLUDED.updated_at
+	`
+	if _, err := tx.ExecContext(ctx, query, phone, delta, now); err != nil {
+		return fmt.Errorf("failed to update loyalty balance: %w", err)
+	}
+	return nil
+}
+
+func insertLoyaltyTransaction(ctx context.Context, tx *sql.Tx, t *models.LoyaltyTransaction) error {
+	query := `
+		INSERT INTO loyalty_transactions (id, customer_phone, kind, points, remaining, order_id, expires_at, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	if _, err := tx.ExecContext(ctx, query, t.ID, t.CustomerPhone, t.Kind, t.Points, t.Remaining, t.OrderID, t.ExpiresAt, t.CreatedAt); err != nil {
+		return fmt.Errorf("failed to record loyalty transaction: %w", err)
+	}
+	return nil
+}
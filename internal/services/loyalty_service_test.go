@@ -0,0 +1,229 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/config"
+	"delivery-system/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+func newTestLoyaltyService(t *testing.T) (*LoyaltyService, sqlmock.Sqlmock, func()) {
+	db, mock := newMockDB(t)
+	service := NewLoyaltyService(db, newTestLogger(), &config.LoyaltyConfig{
+		EarnRate:    0.05,
+		EarnRates:   map[string]float64{"EUR": 1},
+		PointValue:  1,
+		PointValues: map[string]float64{"EUR": 0.01},
+		TTLDays:     30,
+	})
+	return service, mock, func() { _ = db.Close() }
+}
+
+func TestLoyaltyService_RedeemWithTx_CapsAtDueAndUsesOldestLots(t *testing.T) {
+	service, mock, closeDB := newTestLoyaltyService(t)
+	defer closeDB()
+
+	orderID := uuid.New()
+	soon := time.Now().Add(24 * time.Hour)
+	later := time.Now().Add(72 * time.Hour)
+	firstLot, secondLot := uuid.New(), uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT balance FROM loyalty_accounts").
+		WithArgs("79990000002").
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(500))
+	mock.ExpectQuery("WITH expired AS").
+		WithArgs("79990000002", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(0))
+	mock.ExpectQuery("SELECT id, remaining, expires_at FROM loyalty_transactions").
+		WithArgs("79990000002").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "remaining", "expires_at"}).
+			AddRow(firstLot, 100, soon).
+			AddRow(secondLot, 400, later))
+	mock.ExpectExec("UPDATE loyalty_transactions SET remaining").
+		WithArgs(0, firstLot).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE loyalty_transactions SET remaining").
+		WithArgs(250, secondLot).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO loyalty_accounts").
+		WithArgs("79990000002", -250, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO loyalty_transactions").
+		WithArgs(sqlmock.AnyArg(), "79990000002", models.LoyaltyKindRedeem, -250, 0, &orderID, later, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	tx, err := service.db.Begin()
+	if err != nil {
+		t.Fatalf("failed to begin tx: %v", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Запрошено 400 баллов, но к оплате осталось 250 — списывается 250
+	redemption, err := service.RedeemWithTx(context.Background(), tx, "+7 999 000-00-02", orderID, 400, rub(250))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if redemption.Points != 250 || redemption.Discount != rub(250) {
+		t.Fatalf("unexpected redemption: %+v", redemption)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestLoyaltyService_RedeemWithTx_NotEnoughPointsAfterExpiry(t *testing.T) {
+	service, mock, closeDB := newTestLoyaltyService(t)
+	defer closeDB()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT balance FROM loyalty_accounts").
+		WithArgs("79990000002").
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(120))
+	mock.ExpectQuery("WITH expired AS").
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(100))
+	mock.ExpectExec("INSERT INTO loyalty_accounts").
+		WithArgs("79990000002", -100, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO loyalty_transactions").
+		WithArgs(sqlmock.AnyArg(), "79990000002", models.LoyaltyKindExpire, -100, 0, nil, nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	tx, err := service.db.Begin()
+	if err != nil {
+		t.Fatalf("failed to begin tx: %v", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = service.RedeemWithTx(context.Background(), tx, "79990000002", uuid.New(), 50, rub(500))
+	if !apperror.Is(err, apperror.KindConflict) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestLoyaltyService_EarnWithTx(t *testing.T) {
+	cases := []struct {
+		name     string
+		currency string
+		total    string
+		points   int
+	}{
+		{name: "default rate", currency: "RUB", total: "259.90", points: 12},
+		{name: "currency rate", currency: "EUR", total: "17.50", points: 17},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			service, mock, closeDB := newTestLoyaltyService(t)
+			defer closeDB()
+
+			orderID := uuid.New()
+			mock.ExpectBegin()
+			mock.ExpectQuery("SELECT customer_phone, currency, total_amount FROM orders").
+				WithArgs(orderID).
+				WillReturnRows(sqlmock.NewRows([]string{"customer_phone", "currency", "total_amount"}).AddRow("+79990000002", tc.currency, tc.total))
+			mock.ExpectExec("INSERT INTO loyalty_accounts").
+				WithArgs("79990000002", tc.points, sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec("INSERT INTO loyalty_transactions").
+				WithArgs(sqlmock.AnyArg(), "79990000002", models.LoyaltyKindEarn, tc.points, tc.points, &orderID, sqlmock.AnyArg(), sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+
+			tx, err := service.db.Begin()
+			if err != nil {
+				t.Fatalf("failed to begin tx: %v", err)
+			}
+			defer func() { _ = tx.Rollback() }()
+
+			if err := service.EarnWithTx(context.Background(), tx, orderID); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestLoyaltyService_ReverseOrderWithTx(t *testing.T) {
+	service, mock, closeDB := newTestLoyaltyService(t)
+	defer closeDB()
+
+	orderID := uuid.New()
+	expiresAt := time.Now().Add(48 * time.Hour)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT customer_phone, points, expires_at FROM loyalty_transactions").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"customer_phone", "points", "expires_at"}).AddRow("79990000002", -250, expiresAt))
+	mock.ExpectExec("INSERT INTO loyalty_accounts").
+		WithArgs("79990000002", 250, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO loyalty_transactions").
+		WithArgs(sqlmock.AnyArg(), "79990000002", models.LoyaltyKindReversal, 250, 250, &orderID, expiresAt, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	tx, err := service.db.Begin()
+	if err != nil {
+		t.Fatalf("failed to begin tx: %v", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := service.ReverseOrderWithTx(context.Background(), tx, orderID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestLoyaltyService_ReverseForRefundWithTx_Proportional(t *testing.T) {
+	service, mock, closeDB := newTestLoyaltyService(t)
+	defer closeDB()
+
+	orderID := uuid.New()
+	lot := uuid.New()
+
+	// Начислено 100 баллов, 10 уже отозвано; возвращено 50 из 200 — отзываем еще 15
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT e.customer_phone, e.points").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"customer_phone", "points", "reversed"}).AddRow("79990000002", 100, 10))
+	mock.ExpectQuery("SELECT balance FROM loyalty_accounts").
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow(90))
+	mock.ExpectQuery("WITH expired AS").
+		WillReturnRows(sqlmock.NewRows([]string{"sum"}).AddRow(0))
+	mock.ExpectQuery("SELECT id, remaining, expires_at FROM loyalty_transactions").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "remaining", "expires_at"}).AddRow(lot, 90, time.Now().Add(time.Hour)))
+	mock.ExpectExec("UPDATE loyalty_transactions SET remaining").
+		WithArgs(75, lot).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO loyalty_accounts").
+		WithArgs("79990000002", -15, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO loyalty_transactions").
+		WithArgs(sqlmock.AnyArg(), "79990000002", models.LoyaltyKindReversal, -15, 0, &orderID, nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	tx, err := service.db.Begin()
+	if err != nil {
+		t.Fatalf("failed to begin tx: %v", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := service.ReverseForRefundWithTx(context.Background(), tx, orderID, rub(50), rub(200)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
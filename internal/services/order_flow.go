@@ -0,0 +1,162 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"delivery-system/internal/actor"
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/models"
+)
+
+// OrderFlow — машина состояний заказа: разрешенные переходы, роли исполнителей и действия при переходе.
+type OrderFlow struct {
+	transitions         map[models.OrderStatus]map[models.OrderStatus]models.OrderTransition
+	terminal            []models.OrderStatus
+	maxDeliveryAttempts int
+}
+
+// DefaultMaxDeliveryAttempts — сколько раз курьер может не вручить заказ, прежде чем повтор запрещается.
+const DefaultMaxDeliveryAttempts = 2
+
+// DefaultOrderTransitions возвращает переходы по умолчанию, если ORDER_FLOW_FILE не задан. Роли в них
+// не заданы: X-Actor не аутентифицируется, и ограничения по ролям включаются только через файл переходов.
+func DefaultOrderTransitions() []models.OrderTransition {
+	cancel := []models.TransitionHook{models.HookReleasePayment, models.HookReverseDiscounts, models.HookReleaseCourier}
+	transitions := []models.OrderTransition{
+		{From: models.OrderStatusCreated, To: models.OrderStatusAccepted},
+		{From: models.OrderStatusAccepted, To: models.OrderStatusPreparing},
+		{From: models.OrderStatusPreparing, To: models.OrderStatusReady},
+		{From: models.OrderStatusReady, To: models.OrderStatusPickedUp},
+		{From: models.OrderStatusReady, To: models.OrderStatusInDelivery},
+		{From: models.OrderStatusPickedUp, To: models.OrderStatusInDelivery},
+		{
+			From:  models.OrderStatusInDelivery,
+			To:    models.OrderStatusDelivered,
+			Hooks: []models.TransitionHook{models.HookVerifyProof, models.HookCapturePayment, models.HookCompleteDelivery, models.HookReleaseCourier},
+		},
+		{From: models.OrderStatusInDelivery, To: models.OrderStatusDeliveryFailed, Hooks: []models.TransitionHook{models.HookFailDelivery}},
+		{From: models.OrderStatusDeliveryFailed, To: models.OrderStatusInDelivery, Hooks: []models.TransitionHook{models.HookRetryDelivery}},
+		{From: models.OrderStatusDeliveryFailed, To: models.OrderStatusReturning, Hooks: []models.TransitionHook{models.HookPriceReturn}},
+		// Курьер освобождается только после того, как точка забора подтвердила возврат
+		{From: models.OrderStatusReturning, To: models.OrderStatusReturned, Hooks: []models.TransitionHook{models.HookReleaseCourier}},
+	}
+
+	for _, from := range []models.OrderStatus{
+		models.OrderStatusCreated, models.OrderStatusAccepted, models.OrderStatusPreparing, models.OrderStatusReady,
+		models.OrderStatusPickedUp, models.OrderStatusInDelivery,
+	} {
+		transitions = append(transitions, models.OrderTransition{From: from, To: models.OrderStatusCancelled, Hooks: cancel})
+	}
+	return transitions
+}
+
+// LoadOrderTransitions читает переходы из JSON-файла.
+func LoadOrderTransitions(path string) ([]models.OrderTransition, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read order flow file: %w", err)
+	}
+
+	var transitions []models.OrderTransition
+	if err := json.Unmarshal(data, &transitions); err != nil {
+		return nil, fmt.Errorf("failed to parse order flow file: %w", err)
+	}
+	return transitions, nil
+}
+
+// NewOrderFlow проверяет переходы и собирает машину состояний. Статусы должны быть известны
+// (их же разрешает CHECK на orders.status), а заказ должен иметь выход из created.
+// maxDeliveryAttempts <= 0 заменяется на DefaultMaxDeliveryAttempts.
+func NewOrderFlow(transitions []models.OrderTransition, maxDeliveryAttempts int) (*OrderFlow, error) {
+	if maxDeliveryAttempts <= 0 {
+		maxDeliveryAttempts = DefaultMaxDeliveryAttempts
+	}
+	flow := &OrderFlow{
+		transitions:         make(map[models.OrderStatus]map[models.OrderStatus]models.OrderTransition),
+		maxDeliveryAttempts: maxDeliveryAttempts,
+	}
+
+	for _, t := range transitions {
+		if !t.From.IsValid() || !t.To.IsValid() {
+			return nil, fmt.Errorf("order flow: unknown status in transition %s -> %s", t.From, t.To)
+		}
+		if t.From == t.To {
+			return nil, fmt.Errorf("order flow: transition %s -> %s does not change status", t.From, t.To)
+		}
+		for _, hook := range t.Hooks {
+			if !hook.IsValid() {
+				return nil, fmt.Errorf("order flow: unknown hook %q in transition %s -> %s", hook, t.From, t.To)
+			}
+		}
+		for _, role := range t.Roles {
+			switch actor.Type(role) {
+			case actor.TypeUser, actor.TypeCourier, actor.TypeSystem:
+			default:
+				return nil, fmt.Errorf("order flow: unknown role %q in transition %s -> %s", role, t.From, t.To)
+			}
+		}
+
+		if flow.transitions[t.From] == nil {
+			flow.transitions[t.From] = make(map[models.OrderStatus]models.OrderTransition)
+		}
+		if _, exists := flow.transitions[t.From][t.To]; exists {
+			return nil, fmt.Errorf("order flow: duplicate transition %s -> %s", t.From, t.To)
+		}
+		flow.transitions[t.From][t.To] = t
+	}
+
+	if len(flow.transitions[models.OrderStatusCreated]) == 0 {
+		return nil, fmt.Errorf("order flow: no transitions from %s", models.OrderStatusCreated)
+	}
+
+	for _, t := range transitions {
+		if len(flow.transitions[t.To]) == 0 && !containsStatus(flow.terminal, t.To) {
+			flow.terminal = append(flow.terminal, t.To)
+		}
+	}
+	return flow, nil
+}
+
+// Transition возвращает переход from -> to, если он разрешен исполнителю из контекста.
+// Повторная установка текущего статуса разрешена и не выполняет действий.
+func (f *OrderFlow) Transition(ctx context.Context, from, to models.OrderStatus) (models.OrderTransition, error) {
+	if !to.IsValid() {
+		return models.OrderTransition{}, apperror.Validation("unknown order status", nil)
+	}
+	if from == to {
+		return models.OrderTransition{From: from, To: to}, nil
+	}
+
+	t, ok := f.transitions[from][to]
+	if !ok {
+		return models.OrderTransition{}, apperror.Conflict("invalid order status transition", nil)
+	}
+
+	if len(t.Roles) > 0 {
+		role := string(actor.FromContext(ctx).Type)
+		allowed := false
+		for _, r := range t.Roles {
+			if r == role {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return models.OrderTransition{}, apperror.Forbidden(fmt.Sprintf("%s is not allowed to move order from %s to %s", role, from, to), nil)
+		}
+	}
+	return t, nil
+}
+
+// TerminalStatuses возвращает статусы без исходящих переходов: заказ в них завершен.
+func (f *OrderFlow) TerminalStatuses() []models.OrderStatus {
+	return f.terminal
+}
+
+// MaxDeliveryAttempts возвращает лимит неудачных попыток вручения: после него retry_delivery запрещен.
+func (f *OrderFlow) MaxDeliveryAttempts() int {
+	return f.maxDeliveryAttempts
+}
@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"delivery-system/internal/actor"
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/models"
+
+	"github.com/google/uuid"
+)
+
+func TestOrderFlow_Default(t *testing.T) {
+	flow, err := NewOrderFlow(DefaultOrderTransitions(), 0)
+	if err != nil {
+		t.Fatalf("default flow: %v", err)
+	}
+	ctx := context.Background()
+
+	delivered, err := flow.Transition(ctx, models.OrderStatusInDelivery, models.OrderStatusDelivered)
+	if err != nil || !delivered.Has(models.HookCapturePayment) || !delivered.Has(models.HookReleaseCourier) {
+		t.Fatalf("unexpected delivered transition %+v (%v)", delivered, err)
+	}
+	if _, err := flow.Transition(ctx, models.OrderStatusCreated, models.OrderStatusDelivered); !apperror.Is(err, apperror.KindConflict) {
+		t.Fatalf("expected conflict for skipped statuses, got %v", err)
+	}
+	if _, err := flow.Transition(ctx, models.OrderStatusDelivered, models.OrderStatusCancelled); !apperror.Is(err, apperror.KindConflict) {
+		t.Fatalf("expected conflict from terminal status, got %v", err)
+	}
+	if _, err := flow.Transition(ctx, models.OrderStatusCreated, "lost"); !apperror.Is(err, apperror.KindValidation) {
+		t.Fatalf("expected validation error for unknown status, got %v", err)
+	}
+	if same, err := flow.Transition(ctx, models.OrderStatusReady, models.OrderStatusReady); err != nil || len(same.Hooks) != 0 {
+		t.Fatalf("expected no-op transition, got %+v (%v)", same, err)
+	}
+
+	terminal := flow.TerminalStatuses()
+	for _, status := range []models.OrderStatus{models.OrderStatusDelivered, models.OrderStatusCancelled, models.OrderStatusReturned} {
+		if !containsStatus(terminal, status) {
+			t.Fatalf("expected %s to be terminal, got %v", status, terminal)
+		}
+	}
+	if len(terminal) != 3 {
+		t.Fatalf("unexpected terminal statuses %v", terminal)
+	}
+}
+
+func TestOrderFlow_DeliveryFailure(t *testing.T) {
+	flow, err := NewOrderFlow(DefaultOrderTransitions(), 0)
+	if err != nil {
+		t.Fatalf("default flow: %v", err)
+	}
+	ctx := context.Background()
+
+	if flow.MaxDeliveryAttempts() != DefaultMaxDeliveryAttempts {
+		t.Fatalf("expected default attempts limit, got %d", flow.MaxDeliveryAttempts())
+	}
+	if failed, err := flow.Transition(ctx, models.OrderStatusInDelivery, models.OrderStatusDeliveryFailed); err != nil || !failed.Has(models.HookFailDelivery) {
+		t.Fatalf("unexpected failed transition %+v (%v)", failed, err)
+	}
+	if returning, err := flow.Transition(ctx, models.OrderStatusDeliveryFailed, models.OrderStatusReturning); err != nil || !returning.Has(models.HookPriceReturn) || returning.Has(models.HookReleaseCourier) {
+		t.Fatalf("courier must stay busy until return is confirmed, got %+v (%v)", returning, err)
+	}
+	if returned, err := flow.Transition(ctx, models.OrderStatusReturning, models.OrderStatusReturned); err != nil || !returned.Has(models.HookReleaseCourier) {
+		t.Fatalf("unexpected returned transition %+v (%v)", returned, err)
+	}
+	if _, err := flow.Transition(ctx, models.OrderStatusDeliveryFailed, models.OrderStatusReturned); !apperror.Is(err, apperror.KindConflict) {
+		t.Fatalf("expected conflict when skipping returning, got %v", err)
+	}
+
+	custom, err := NewOrderFlow(DefaultOrderTransitions(), 3)
+	if err != nil || custom.MaxDeliveryAttempts() != 3 {
+		t.Fatalf("expected configured attempts limit, got %v (%v)", custom, err)
+	}
+}
+
+func TestOrderFlow_Roles(t *testing.T) {
+	flow, err := NewOrderFlow([]models.OrderTransition{
+		{From: models.OrderStatusCreated, To: models.OrderStatusAccepted},
+		{From: models.OrderStatusAccepted, To: models.OrderStatusReady, Roles: []string{"user"}},
+		{From: models.OrderStatusReady, To: models.OrderStatusPickedUp, Roles: []string{"courier"}},
+	}, 0)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	courier := actor.WithContext(context.Background(), actor.Actor{Type: actor.TypeCourier, ID: uuid.NewString()})
+	if _, err := flow.Transition(courier, models.OrderStatusAccepted, models.OrderStatusReady); !apperror.Is(err, apperror.KindForbidden) {
+		t.Fatalf("expected forbidden for courier, got %v", err)
+	}
+	if _, err := flow.Transition(courier, models.OrderStatusReady, models.OrderStatusPickedUp); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := flow.Transition(actor.WithContext(context.Background(), actor.Anonymous), models.OrderStatusAccepted, models.OrderStatusReady); err != nil {
+		t.Fatalf("expected merchant to skip preparing, got %v", err)
+	}
+}
+
+func TestOrderFlow_DefaultAllowsAnyRole(t *testing.T) {
+	flow, err := NewOrderFlow(DefaultOrderTransitions(), 0)
+	if err != nil {
+		t.Fatalf("default flow: %v", err)
+	}
+	anonymous := actor.WithContext(context.Background(), actor.Anonymous)
+	courier := actor.WithContext(context.Background(), actor.Actor{Type: actor.TypeCourier, ID: uuid.NewString()})
+
+	// Без ORDER_FLOW_FILE клиенты без X-Actor и курьеры проводят заказ так же, как раньше
+	cases := []struct {
+		from, to models.OrderStatus
+		ctx      context.Context
+	}{
+		{models.OrderStatusReady, models.OrderStatusInDelivery, anonymous},
+		{models.OrderStatusInDelivery, models.OrderStatusDelivered, anonymous},
+		{models.OrderStatusReturning, models.OrderStatusReturned, anonymous},
+		{models.OrderStatusCreated, models.OrderStatusAccepted, courier},
+		{models.OrderStatusInDelivery, models.OrderStatusCancelled, courier},
+	}
+	for _, tc := range cases {
+		if _, err := flow.Transition(tc.ctx, tc.from, tc.to); err != nil {
+			t.Errorf("%s -> %s by %s: unexpected error %v", tc.from, tc.to, actor.FromContext(tc.ctx), err)
+		}
+	}
+	for _, transition := range DefaultOrderTransitions() {
+		if len(transition.Roles) != 0 {
+			t.Fatalf("default transition %s -> %s must not restrict roles", transition.From, transition.To)
+		}
+	}
+}
+
+func TestNewOrderFlow_Invalid(t *testing.T) {
+	cases := map[string][]models.OrderTransition{
+		"unknown status": {{From: models.OrderStatusCreated, To: "lost"}},
+		"self":           {{From: models.OrderStatusCreated, To: models.OrderStatusCreated}},
+		"unknown hook":   {{From: models.OrderStatusCreated, To: models.OrderStatusAccepted, Hooks: []models.TransitionHook{"notify"}}},
+		"unknown role":   {{From: models.OrderStatusCreated, To: models.OrderStatusAccepted, Roles: []string{"admin"}}},
+		"duplicate": {
+			{From: models.OrderStatusCreated, To: models.OrderStatusAccepted},
+			{From: models.OrderStatusCreated, To: models.OrderStatusAccepted},
+		},
+		"no exit from created": {{From: models.OrderStatusAccepted, To: models.OrderStatusReady}},
+	}
+	for name, transitions := range cases {
+		if _, err := NewOrderFlow(transitions, 0); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestLoadOrderTransitions_Example(t *testing.T) {
+	transitions, err := LoadOrderTransitions("../../docs/order_flow.example.json")
+	if err != nil {
+		t.Fatalf("load example: %v", err)
+	}
+	if _, err := NewOrderFlow(transitions, 0); err != nil {
+		t.Fatalf("example flow is invalid: %v", err)
+	}
+}
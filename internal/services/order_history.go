@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// GetOrderHistory возвращает хронологию статусов заказа из order_status_history
+// с длительностью пребывания в каждом статусе.
+func (s *OrderService) GetOrderHistory(ctx context.Context, orderID uuid.UUID) (*models.OrderHistory, error) {
+	history := &models.OrderHistory{OrderID: orderID, Timeline: []models.OrderStatusChange{}}
+
+	if err := s.db.QueryRowContext(ctx, "SELECT status FROM orders WHERE id = $1", orderID).Scan(&history.Status); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperror.NotFound("order not found", err)
+		}
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+
+	query := `
+		SELECT id, old_status, new_status, courier_id, COALESCE(changed_by, 'system'), changed_at, reason
+		FROM order_status_history
+		WHERE order_id = $1
+		ORDER BY changed_at, id
+	`
+	rows, err := s.db.QueryContext(ctx, query, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order history: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			change    models.OrderStatusChange
+			oldStatus sql.NullString
+		)
+		if err := rows.Scan(&change.ID, &oldStatus, &change.NewStatus, &change.CourierID, &change.ChangedBy, &change.ChangedAt, &change.Reason); err != nil {
+			return nil, fmt.Errorf("failed to scan order history: %w", err)
+		}
+		if oldStatus.Valid {
+			status := models.OrderStatus(oldStatus.String)
+			change.OldStatus = &status
+		}
+		history.Timeline = append(history.Timeline, change)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate order history: %w", err)
+	}
+
+	// Длительность статуса — время до следующей записи; текущий статус остается открытым
+	for i := 0; i+1 < len(history.Timeline); i++ {
+		seconds := history.Timeline[i+1].ChangedAt.Sub(history.Timeline[i].ChangedAt).Seconds()
+		history.Timeline[i].DurationSeconds = &seconds
+	}
+	if n := len(history.Timeline); n > 1 {
+		history.TotalSeconds = history.Timeline[n-1].ChangedAt.Sub(history.Timeline[0].ChangedAt).Seconds()
+	}
+
+	return history, nil
+}
@@ -0,0 +1,75 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+func TestOrderService_GetOrderHistory(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewOrderService(db, newTestLogger(), newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
+
+	orderID := uuid.New()
+	courierID := uuid.New()
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	mock.ExpectQuery("SELECT status FROM orders WHERE id = \\$1").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.OrderStatusInDelivery))
+	mock.ExpectQuery("SELECT id, old_status, new_status, courier_id, COALESCE\\(changed_by, 'system'\\), changed_at, reason FROM order_status_history").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "old_status", "new_status", "courier_id", "changed_by", "changed_at", "reason"}).
+			AddRow(uuid.New(), nil, models.OrderStatusCreated, nil, "user:anonymous", created, nil).
+			AddRow(uuid.New(), models.OrderStatusCreated, models.OrderStatusAccepted, courierID, "system:auto_assign", created.Add(90*time.Second), nil).
+			AddRow(uuid.New(), models.OrderStatusAccepted, models.OrderStatusInDelivery, courierID, "courier:"+courierID.String(), created.Add(10*time.Minute), nil))
+
+	history, err := service.GetOrderHistory(context.Background(), orderID)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(history.Timeline) != 3 || history.Timeline[0].OldStatus != nil {
+		t.Fatalf("unexpected timeline: %+v", history.Timeline)
+	}
+	if d := history.Timeline[0].DurationSeconds; d == nil || *d != 90 {
+		t.Fatalf("expected 90s in created, got %v", d)
+	}
+	if d := history.Timeline[1].DurationSeconds; d == nil || *d != 510 {
+		t.Fatalf("expected 510s in accepted, got %v", d)
+	}
+	if history.Timeline[2].DurationSeconds != nil {
+		t.Fatalf("expected open duration for current status")
+	}
+	if history.TotalSeconds != 600 || history.Timeline[1].ChangedBy != "system:auto_assign" {
+		t.Fatalf("unexpected history: %+v", history)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestOrderService_GetOrderHistory_NotFound(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewOrderService(db, newTestLogger(), newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
+
+	orderID := uuid.New()
+	mock.ExpectQuery("SELECT status FROM orders WHERE id = \\$1").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}))
+
+	if _, err := service.GetOrderHistory(context.Background(), orderID); !apperror.Is(err, apperror.KindNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
@@ -0,0 +1,271 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"delivery-system/internal/actor"
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// unassignableStatuses — статусы, в которых курьера можно снять с заказа: товар еще не у курьера.
+var unassignableStatuses = []models.OrderStatus{
+	models.OrderStatusAccepted,
+	models.OrderStatusPreparing,
+	models.OrderStatusReady,
+}
+
+// UnassignCourier снимает курьера с заказа: заказ возвращается в created, курьер — в available,
+// если у него не осталось других активных заказов. Причина попадает в журнал статусов.
+func (s *OrderService) UnassignCourier(ctx context.Context, orderID uuid.UUID, reason string) (*models.CourierReassignment, error) {
+	reason, err := checkReassignment(ctx, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	status, previousCourierID, err := s.lockAssignmentWithTx(ctx, tx, orderID, nil)
+	if err != nil {
+		return nil, err
+	}
+	if !containsStatus(unassignableStatuses, status) {
+		return nil, apperror.Conflict(fmt.Sprintf("courier cannot be unassigned from order in status %s, reassign it instead", status), nil)
+	}
+
+	if err := setActorWithTx(ctx, tx); err != nil {
+		return nil, err
+	}
+	if err := setReasonWithTx(ctx, tx, reason); err != nil {
+		return nil, err
+	}
+
+	orderQuery := `
+		UPDATE orders
+		SET courier_id = NULL, status = $1, updated_at = $2
+		WHERE id = $3
+	`
+	if _, err := tx.ExecContext(ctx, orderQuery, models.OrderStatusCreated, time.Now(), orderID); err != nil {
+		return nil, fmt.Errorf("failed to unassign courier: %w", err)
+	}
+
+	released, err := s.releaseCourierWithTx(ctx, tx, orderID, previousCourierID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit courier unassignment: %w", err)
+	}
+
+	s.log.WithFields(map[string]interface{}{
+		"order_id":   orderID,
+		"courier_id": previousCourierID,
+		"reason":     reason,
+	}).Info("Courier unassigned from order")
+
+	return &models.CourierReassignment{
+		OrderID:                 orderID,
+		OldStatus:               status,
+		Status:                  models.OrderStatusCreated,
+		PreviousCourierID:       previousCourierID,
+		Reason:                  reason,
+		PreviousCourierReleased: released,
+	}, nil
+}
+
+// ReassignCourier передает заказ другому доступному курьеру без смены статуса заказа. Транспорт нового
+// курьера должен выдержать заказ и проехать маршрут, как при автоназначении.
+// Новый курьер становится busy, прежний — available, если у него не осталось других активных заказов.
+func (s *OrderService) ReassignCourier(ctx context.Context, orderID, courierID uuid.UUID, reason string) (*models.CourierReassignment, error) {
+	reason, err := checkReassignment(ctx, reason)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	status, previousCourierID, err := s.lockAssignmentWithTx(ctx, tx, orderID, &courierID)
+	if err != nil {
+		return nil, err
+	}
+	if previousCourierID == courierID {
+		return nil, apperror.Conflict("order is already assigned to this courier", nil)
+	}
+	if containsStatus(s.flow.TerminalStatuses(), status) {
+		return nil, apperror.Conflict(fmt.Sprintf("order in status %s can no longer be reassigned", status), nil)
+	}
+	if err := checkCourierCanCarryWithTx(ctx, tx, orderID, courierID); err != nil {
+		return nil, err
+	}
+
+	if err := setActorWithTx(ctx, tx); err != nil {
+		return nil, err
+	}
+	if err := setReasonWithTx(ctx, tx, reason); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE orders SET courier_id = $1, updated_at = $2 WHERE id = $3", courierID, time.Now(), orderID); err != nil {
+		return nil, fmt.Errorf("failed to reassign order: %w", err)
+	}
+
+	// Новый курьер занимается только если он всё ещё доступен
+	courierUpdateQuery := `
+		UPDATE couriers
+		SET status = $1, updated_at = $2
+		WHERE id = $3 AND status = $4
+	`
+	result, err := tx.ExecContext(ctx, courierUpdateQuery, models.CourierStatusBusy, time.Now(), courierID, models.CourierStatusAvailable)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update courier status: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows affected when updating courier: %w", err)
+	}
+	if rowsAffected == 0 {
+		return nil, apperror.Conflict("courier is not available", nil)
+	}
+
+	released, err := s.releaseCourierWithTx(ctx, tx, orderID, previousCourierID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit courier reassignment: %w", err)
+	}
+
+	s.log.WithFields(map[string]interface{}{
+		"order_id":            orderID,
+		"courier_id":          courierID,
+		"previous_courier_id": previousCourierID,
+		"reason":              reason,
+	}).Info("Order reassigned to another courier")
+
+	return &models.CourierReassignment{
+		OrderID:                 orderID,
+		OldStatus:               status,
+		Status:                  status,
+		PreviousCourierID:       previousCourierID,
+		CourierID:               &courierID,
+		Reason:                  reason,
+		PreviousCourierReleased: released,
+	}, nil
+}
+
+// lockAssignmentWithTx блокирует курьеров заказа и сам заказ. Как и в AssignOrderToCourier, строки
+// курьеров блокируются раньше заказа (в порядке id), поэтому встречные назначения не взаимоблокируются.
+// Новый курьер должен быть доступен. Возвращает статус заказа и текущего курьера.
+func (s *OrderService) lockAssignmentWithTx(ctx context.Context, tx *sql.Tx, orderID uuid.UUID, newCourierID *uuid.UUID) (models.OrderStatus, uuid.UUID, error) {
+	var (
+		status    models.OrderStatus
+		courierID *uuid.UUID
+	)
+	err := tx.QueryRowContext(ctx, "SELECT status, courier_id FROM orders WHERE id = $1", orderID).Scan(&status, &courierID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return "", uuid.Nil, apperror.NotFound("order not found", err)
+		}
+		return "", uuid.Nil, fmt.Errorf("failed to get order: %w", err)
+	}
+	if courierID == nil {
+		return "", uuid.Nil, apperror.Conflict("order has no courier assigned", nil)
+	}
+
+	couriers := []uuid.UUID{*courierID}
+	if newCourierID != nil && *newCourierID != *courierID {
+		couriers = append(couriers, *newCourierID)
+	}
+	sort.Slice(couriers, func(i, j int) bool { return couriers[i].String() < couriers[j].String() })
+
+	for _, id := range couriers {
+		var courierStatus, onboardingStatus string
+		if err := tx.QueryRowContext(ctx, "SELECT status, onboarding_status FROM couriers WHERE id = $1 FOR UPDATE", id).Scan(&courierStatus, &onboardingStatus); err != nil {
+			if err == sql.ErrNoRows {
+				return "", uuid.Nil, apperror.NotFound("courier not found", err)
+			}
+			return "", uuid.Nil, fmt.Errorf("failed to check courier status: %w", err)
+		}
+		if newCourierID == nil || id != *newCourierID || id == *courierID {
+			continue
+		}
+		if onboardingStatus != string(models.OnboardingStatusVerified) {
+			return "", uuid.Nil, apperror.Conflict("courier is not verified", nil)
+		}
+		if courierStatus != string(models.CourierStatusAvailable) {
+			return "", uuid.Nil, apperror.Conflict("courier is not available", nil)
+		}
+	}
+
+	// Заказ перечитывается под блокировкой: за это время его могли передать другому курьеру
+	var lockedCourierID *uuid.UUID
+	err = tx.QueryRowContext(ctx, "SELECT status, courier_id FROM orders WHERE id = $1 FOR UPDATE", orderID).Scan(&status, &lockedCourierID)
+	if err != nil {
+		return "", uuid.Nil, fmt.Errorf("failed to lock order: %w", err)
+	}
+	if lockedCourierID == nil || *lockedCourierID != *courierID {
+		return "", uuid.Nil, apperror.Conflict("order assignment changed concurrently, retry the request", nil)
+	}
+
+	return status, *courierID, nil
+}
+
+// checkCourierCanCarryWithTx проверяет, что транспорт курьера выдержит вес и объем заказа и проедет
+// маршрут от текущей позиции курьера через точку забора до клиента. Строки уже заблокированы вызывающим.
+func checkCourierCanCarryWithTx(ctx context.Context, tx *sql.Tx, orderID, courierID uuid.UUID) error {
+	order := &models.Order{}
+	courier := &models.Courier{}
+	query := `
+		SELECT o.weight_kg, o.volume_l, o.pickup_lat, o.pickup_lon, o.delivery_lat, o.delivery_lon,
+		       c.current_lat, c.current_lon, c.max_weight_kg, c.max_volume_l, c.max_range_km
+		FROM orders o, couriers c
+		WHERE o.id = $1 AND c.id = $2
+	`
+	if err := tx.QueryRowContext(ctx, query, orderID, courierID).Scan(&order.WeightKg, &order.VolumeL,
+		&order.PickupLat, &order.PickupLon, &order.DeliveryLat, &order.DeliveryLon,
+		&courier.CurrentLat, &courier.CurrentLon, &courier.MaxWeightKg, &courier.MaxVolumeL, &courier.MaxRangeKm); err != nil {
+		return fmt.Errorf("failed to check courier vehicle: %w", err)
+	}
+
+	// Без координат клиента длину маршрута не посчитать — проверяются только вес и объем
+	routeKm := 0.0
+	if order.DeliveryLat != nil && order.DeliveryLon != nil {
+		routeKm = courierRouteDistance(courier, order, *order.DeliveryLat, *order.DeliveryLon)
+	}
+	if !courier.CanCarry(order.WeightKg, order.VolumeL, routeKm) {
+		return apperror.Conflict("courier vehicle cannot carry this order", nil)
+	}
+	return nil
+}
+
+// checkReassignment проверяет причину и исполнителя: снимать и переназначать курьеров может только диспетчер.
+func checkReassignment(ctx context.Context, reason string) (string, error) {
+	if actor.FromContext(ctx).Type == actor.TypeCourier {
+		return "", apperror.Forbidden("couriers cannot reassign orders", nil)
+	}
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return "", apperror.Validation("reason is required", nil)
+	}
+	if len([]rune(reason)) > models.MaxReassignReasonLength {
+		return "", apperror.Validation(fmt.Sprintf("reason must be at most %d characters", models.MaxReassignReasonLength), nil)
+	}
+	return reason, nil
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"delivery-system/internal/actor"
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+func TestOrderService_UnassignCourier(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewOrderService(db, newTestLogger(), newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
+	orderID, courierID := uuid.New(), uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, courier_id FROM orders WHERE id = \\$1").WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "courier_id"}).AddRow(models.OrderStatusPreparing, courierID))
+	mock.ExpectQuery("SELECT status, onboarding_status FROM couriers WHERE id = \\$1 FOR UPDATE").WithArgs(courierID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "onboarding_status"}).AddRow(models.CourierStatusBusy, models.OnboardingStatusVerified))
+	mock.ExpectQuery("SELECT status, courier_id FROM orders WHERE id = \\$1 FOR UPDATE").WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "courier_id"}).AddRow(models.OrderStatusPreparing, courierID))
+	mock.ExpectExec("SELECT set_config\\('app.actor'").WithArgs("system:unknown").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("SELECT set_config\\('app.reason'").WithArgs("courier had an accident").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE orders SET courier_id = NULL, status = \\$1").
+		WithArgs(models.OrderStatusCreated, sqlmock.AnyArg(), orderID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE couriers SET status").
+		WithArgs(models.CourierStatusAvailable, sqlmock.AnyArg(), courierID, models.CourierStatusBusy, orderID, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	result, err := service.UnassignCourier(context.Background(), orderID, "  courier had an accident ")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Status != models.OrderStatusCreated || result.OldStatus != models.OrderStatusPreparing || result.CourierID != nil {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if result.PreviousCourierID != courierID || !result.PreviousCourierReleased || result.Reason != "courier had an accident" {
+		t.Fatalf("unexpected previous courier: %+v", result)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestOrderService_UnassignCourier_Rejected(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewOrderService(db, newTestLogger(), newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
+	orderID, courierID := uuid.New(), uuid.New()
+
+	if _, err := service.UnassignCourier(context.Background(), orderID, " "); !apperror.Is(err, apperror.KindValidation) {
+		t.Fatalf("expected validation error without reason, got %v", err)
+	}
+	courier := actor.WithContext(context.Background(), actor.Actor{Type: actor.TypeCourier, ID: courierID.String()})
+	if _, err := service.UnassignCourier(courier, orderID, "tired"); !apperror.Is(err, apperror.KindForbidden) {
+		t.Fatalf("expected forbidden for courier actor, got %v", err)
+	}
+
+	// Товар уже у курьера: снять нельзя, только передать другому
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, courier_id FROM orders WHERE id = \\$1").WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "courier_id"}).AddRow(models.OrderStatusInDelivery, courierID))
+	mock.ExpectQuery("SELECT status, onboarding_status FROM couriers WHERE id = \\$1 FOR UPDATE").WithArgs(courierID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "onboarding_status"}).AddRow(models.CourierStatusBusy, models.OnboardingStatusVerified))
+	mock.ExpectQuery("SELECT status, courier_id FROM orders WHERE id = \\$1 FOR UPDATE").WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "courier_id"}).AddRow(models.OrderStatusInDelivery, courierID))
+	mock.ExpectRollback()
+
+	if _, err := service.UnassignCourier(context.Background(), orderID, "courier had an accident"); !apperror.Is(err, apperror.KindConflict) {
+		t.Fatalf("expected conflict for order in delivery, got %v", err)
+	}
+
+	// Заказ без курьера
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, courier_id FROM orders WHERE id = \\$1").WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "courier_id"}).AddRow(models.OrderStatusCreated, nil))
+	mock.ExpectRollback()
+
+	if _, err := service.UnassignCourier(context.Background(), orderID, "courier had an accident"); !apperror.Is(err, apperror.KindConflict) {
+		t.Fatalf("expected conflict for unassigned order, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+// vehicleCheckRow — строка проверки транспорта при переназначении: заказ весом weightKg с забором
+// в (55.75, 37.62) и доставкой в (55.80, 37.70), курьер в (lat, lon) с ограничениями maxWeightKg и maxRangeKm.
+func vehicleCheckRow(weightKg, lat, lon, maxWeightKg, maxRangeKm float64) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"weight_kg", "volume_l", "pickup_lat", "pickup_lon", "delivery_lat", "delivery_lon",
+		"current_lat", "current_lon", "max_weight_kg", "max_volume_l", "max_range_km"}).
+		AddRow(weightKg, 10.0, 55.75, 37.62, 55.80, 37.70, lat, lon, maxWeightKg, 90.0, maxRangeKm)
+}
+
+func TestOrderService_ReassignCourier(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewOrderService(db, newTestLogger(), newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
+	orderID := uuid.New()
+	// Курьеры блокируются в порядке id: сначала новый, затем прежний
+	newCourierID := uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	previousCourierID := uuid.MustParse("99999999-9999-9999-9999-999999999999")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, courier_id FROM orders WHERE id = \\$1").WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "courier_id"}).AddRow(models.OrderStatusInDelivery, previousCourierID))
+	mock.ExpectQuery("SELECT status, onboarding_status FROM couriers WHERE id = \\$1 FOR UPDATE").WithArgs(newCourierID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "onboarding_status"}).AddRow(models.CourierStatusAvailable, models.OnboardingStatusVerified))
+	mock.ExpectQuery("SELECT status, onboarding_status FROM couriers WHERE id = \\$1 FOR UPDATE").WithArgs(previousCourierID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "onboarding_status"}).AddRow(models.CourierStatusBusy, models.OnboardingStatusVerified))
+	mock.ExpectQuery("SELECT status, courier_id FROM orders WHERE id = \\$1 FOR UPDATE").WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "courier_id"}).AddRow(models.OrderStatusInDelivery, previousCourierID))
+	// Скутер (до 25 кг, 25 км) выдерживает заказ 8 кг и маршрут около 10 км
+	mock.ExpectQuery("SELECT o.weight_kg, o.volume_l, .* FROM orders o, couriers c WHERE o.id = \\$1 AND c.id = \\$2").
+		WithArgs(orderID, newCourierID).
+		WillReturnRows(vehicleCheckRow(8, 55.75, 37.60, 25, 25))
+	mock.ExpectExec("SELECT set_config\\('app.actor'").WithArgs("user:dispatcher").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("SELECT set_config\\('app.reason'").WithArgs("scooter broke down").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE orders SET courier_id = \\$1, updated_at = \\$2 WHERE id = \\$3").
+		WithArgs(newCourierID, sqlmock.AnyArg(), orderID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE couriers SET status = \\$1, updated_at = \\$2 WHERE id = \\$3 AND status = \\$4$").
+		WithArgs(models.CourierStatusBusy, sqlmock.AnyArg(), newCourierID, models.CourierStatusAvailable).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	// У прежнего курьера есть другой активный заказ — он остается busy
+	mock.ExpectExec("UPDATE couriers SET status").
+		WithArgs(models.CourierStatusAvailable, sqlmock.AnyArg(), previousCourierID, models.CourierStatusBusy, orderID, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	dispatcher := actor.WithContext(context.Background(), actor.Actor{Type: actor.TypeUser, ID: "dispatcher"})
+	result, err := service.ReassignCourier(dispatcher, orderID, newCourierID, "scooter broke down")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Status != models.OrderStatusInDelivery || result.CourierID == nil || *result.CourierID != newCourierID {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if result.PreviousCourierID != previousCourierID || result.PreviousCourierReleased {
+		t.Fatalf("previous courier must stay busy: %+v", result)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestOrderService_ReassignCourier_NotAvailable(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewOrderService(db, newTestLogger(), newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
+	orderID := uuid.New()
+	newCourierID := uuid.MustParse("11111111-1111-1111-1111-111111111111")
+	previousCourierID := uuid.MustParse("99999999-9999-9999-9999-999999999999")
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, courier_id FROM orders WHERE id = \\$1").WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "courier_id"}).AddRow(models.OrderStatusAccepted, previousCourierID))
+	mock.ExpectQuery("SELECT status, onboarding_status FROM couriers WHERE id = \\$1 FOR UPDATE").WithArgs(newCourierID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "onboarding_status"}).AddRow(models.CourierStatusBusy, models.OnboardingStatusVerified))
+	mock.ExpectRollback()
+
+	if _, err := service.ReassignCourier(context.Background(), orderID, newCourierID, "accident"); !apperror.Is(err, apperror.KindConflict) {
+		t.Fatalf("expected conflict for busy courier, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestOrderService_ReassignCourier_VehicleCannotCarry(t *testing.T) {
+	cases := []struct {
+		name string
+		row  *sqlmock.Rows
+	}{
+		// Велосипед с лимитом 10 кг не берет заказ 15 кг
+		{"overweight", vehicleCheckRow(15, 55.75, 37.60, 10, 8)},
+		// Маршрут от курьера через точку забора до клиента длиннее запаса хода в 8 км
+		{"out of range", vehicleCheckRow(5, 55.70, 37.50, 10, 8)},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			db, mock := newMockDB(t)
+			defer db.Close()
+
+			service := NewOrderService(db, newTestLogger(), newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
+			orderID := uuid.New()
+			newCourierID := uuid.MustParse("11111111-1111-1111-1111-111111111111")
+			previousCourierID := uuid.MustParse("99999999-9999-9999-9999-999999999999")
+
+			mock.ExpectBegin()
+			mock.ExpectQuery("SELECT status, courier_id FROM orders WHERE id = \\$1").WithArgs(orderID).
+				WillReturnRows(sqlmock.NewRows([]string{"status", "courier_id"}).AddRow(models.OrderStatusReady, previousCourierID))
+			mock.ExpectQuery("SELECT status, onboarding_status FROM couriers WHERE id = \\$1 FOR UPDATE").WithArgs(newCourierID).
+				WillReturnRows(sqlmock.NewRows([]string{"status", "onboarding_status"}).AddRow(models.CourierStatusAvailable, models.OnboardingStatusVerified))
+			mock.ExpectQuery("SELECT status, onboarding_status FROM couriers WHERE id = \\$1 FOR UPDATE").WithArgs(previousCourierID).
+				WillReturnRows(sqlmock.NewRows([]string{"status", "onboarding_status"}).AddRow(models.CourierStatusBusy, models.OnboardingStatusVerified))
+			mock.ExpectQuery("SELECT status, courier_id FROM orders WHERE id = \\$1 FOR UPDATE").WithArgs(orderID).
+				WillReturnRows(sqlmock.NewRows([]string{"status", "courier_id"}).AddRow(models.OrderStatusReady, previousCourierID))
+			mock.ExpectQuery("SELECT o.weight_kg, o.volume_l, .* FROM orders o, couriers c").
+				WithArgs(orderID, newCourierID).
+				WillReturnRows(tc.row)
+			mock.ExpectRollback()
+
+			if _, err := service.ReassignCourier(context.Background(), orderID, newCourierID, "scooter broke down"); !apperror.Is(err, apperror.KindConflict) {
+				t.Fatalf("expected conflict, got %v", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,281 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/models"
+	"delivery-system/internal/pagination"
+
+	"github.com/google/uuid"
+)
+
+// SearchOrders ищет заказы по условиям запроса и считает разбивку найденного по статусам.
+// Страница выбирается по курсору в порядке сортировки запроса.
+func (s *OrderService) SearchOrders(ctx context.Context, q *models.OrderSearchQuery) (*models.OrderSearchResult, error) {
+	sortKey := orderSearchSortKey(q)
+	if err := q.Page.CheckSort(sortKey); err != nil {
+		return nil, err
+	}
+	if q.Page.Limit <= 0 {
+		q.Page.Limit = 50
+	}
+
+	// Фасеты считаются без фильтра по статусу, список и total — с ним
+	facetWhere, facetArgs := buildOrderSearchFilter(q, false)
+	facetQuery := `SELECT status, COUNT(*) FROM orders o WHERE ` + facetWhere + ` GROUP BY status`
+
+	rows, err := s.db.QueryContext(ctx, facetQuery, facetArgs...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count orders by status: %w", err)
+	}
+	defer rows.Close()
+
+	result := &models.OrderSearchResult{
+		Page:   pagination.Page[*models.Order]{Items: []*models.Order{}},
+		Facets: map[models.OrderStatus]int{},
+	}
+	for rows.Next() {
+		var (
+			status models.OrderStatus
+			count  int
+		)
+		if err := rows.Scan(&status, &count); err != nil {
+			return nil, fmt.Errorf("failed to scan order facet: %w", err)
+		}
+		result.Facets[status] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate order facets: %w", err)
+	}
+
+	for status, count := range result.Facets {
+		if len(q.Statuses) == 0 || containsStatus(q.Statuses, status) {
+			result.Total += count
+		}
+	}
+	if result.Total == 0 {
+		return result, nil
+	}
+
+	where, args := buildOrderSearchFilter(q, true)
+	columns := orderColumns
+	rankExpr := ""
+	if sortKey == string(models.OrderSearchSortRelevance) {
+		args = append(args, orderSearchTSQuery(q.Text))
+		rankExpr = fmt.Sprintf("ts_rank(o.search_vector, to_tsquery('simple', $%d))", len(args))
+		columns += ", " + rankExpr
+	}
+	keyset, err := orderSearchKeyset(q, sortKey, rankExpr, &args)
+	if err != nil {
+		return nil, err
+	}
+	query := `SELECT ` + columns + ` FROM orders o WHERE ` + where + keyset + pageLimitClause(q.Page, &args)
+
+	orderRows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search orders: %w", err)
+	}
+	defer orderRows.Close()
+
+	var orders []*models.Order
+	ranks := map[uuid.UUID]float64{}
+	for orderRows.Next() {
+		var rank float64
+		var row rowScanner = orderRows
+		if rankExpr != "" {
+			row = withExtraColumns{orderRows, []interface{}{&rank}}
+		}
+		order, err := scanOrder(row)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order: %w", err)
+		}
+		order.ApplyCurrency(order.Currency)
+		if rankExpr != "" {
+			ranks[order.ID] = rank
+		}
+		orders = append(orders, order)
+	}
+	if err := orderRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate orders: %w", err)
+	}
+
+	result.Page = *pagination.NewPage(orders, q.Page.Limit, func(o *models.Order) pagination.Cursor {
+		cursor := pagination.Cursor{Sort: sortKey, CreatedAt: o.CreatedAt, ID: o.ID.String()}
+		switch sortKey {
+		case string(models.OrderSearchSortRelevance):
+			rank := ranks[o.ID]
+			cursor.Rank = &rank
+		case string(models.OrderSearchSortTotalAmount), "-" + string(models.OrderSearchSortTotalAmount):
+			amount := o.TotalAmount.String()
+			cursor.Amount = &amount
+		}
+		return cursor
+	})
+	return result, nil
+}
+
+// withExtraColumns дочитывает колонки, выбранные после orderColumns, в extra.
+type withExtraColumns struct {
+	rowScanner
+	extra []interface{}
+}
+
+func (r withExtraColumns) Scan(dest ...interface{}) error {
+	return r.rowScanner.Scan(append(dest, r.extra...)...)
+}
+
+// buildOrderSearchFilter собирает условие WHERE для поиска. Фрагменты имени, адреса, продавца и телефона
+// ищутся через LIKE по триграммным индексам, свободный текст — по search_vector.
+func buildOrderSearchFilter(q *models.OrderSearchQuery, withStatus bool) (string, []interface{}) {
+	conditions := []string{"1=1"}
+	args := []interface{}{}
+	add := func(condition string, value interface{}) {
+		args = append(args, value)
+		conditions = append(conditions, strings.ReplaceAll(condition, "?", fmt.Sprintf("$%d", len(args))))
+	}
+
+	if tsQuery := orderSearchTSQuery(q.Text); tsQuery != "" {
+		add("o.search_vector @@ to_tsquery('simple', ?)", tsQuery)
+	}
+	if q.Phone != "" {
+		add(`regexp_replace(o.customer_phone, '\D', '', 'g') LIKE ?`, "%"+q.Phone+"%")
+	}
+	if q.Name != "" {
+		add("o.customer_name ILIKE ?", likePattern(q.Name))
+	}
+	if q.Address != "" {
+		add("o.delivery_address ILIKE ?", likePattern(q.Address))
+	}
+	if q.Merchant != "" {
+		add("o.merchant_name ILIKE ?", likePattern(q.Merchant))
+	}
+	if q.PromoCode != "" {
+		add("EXISTS (SELECT 1 FROM promo_redemptions pr WHERE pr.order_id = o.id AND pr.code = ?)", q.PromoCode)
+	}
+	if q.CourierID != nil {
+		add("o.courier_id = ?", *q.CourierID)
+	}
+	if q.Currency != "" {
+		add("o.currency = ?", q.Currency)
+	}
+	if q.CreatedFrom != nil {
+		add("o.created_at >= ?", *q.CreatedFrom)
+	}
+	if q.CreatedTo != nil {
+		add("o.created_at < ?", *q.CreatedTo)
+	}
+	if q.AmountMin != nil {
+		add("o.total_amount >= ?", *q.AmountMin)
+	}
+	if q.AmountMax != nil {
+		add("o.total_amount <= ?", *q.AmountMax)
+	}
+	if withStatus && len(q.Statuses) > 0 {
+		placeholders := make([]string, len(q.Statuses))
+		for i, status := range q.Statuses {
+			args = append(args, status)
+			placeholders[i] = fmt.Sprintf("$%d", len(args))
+		}
+		conditions = append(conditions, "o.status IN ("+strings.Join(placeholders, ", ")+")")
+	}
+
+	return strings.Join(conditions, " AND "), args
+}
+
+// orderSearchSortKey возвращает сортировку поиска, записываемую в курсор: created_at, total_amount
+// или relevance, с "-" в начале для убывания. Релевантность без текста сортируется как -created_at.
+func orderSearchSortKey(q *models.OrderSearchQuery) string {
+	sortBy := q.SortBy
+	desc := q.SortDesc
+	switch sortBy {
+	case models.OrderSearchSortRelevance:
+		if orderSearchTSQuery(q.Text) != "" {
+			return string(sortBy)
+		}
+		sortBy, desc = models.OrderSearchSortCreatedAt, true
+	case models.OrderSearchSortTotalAmount:
+	default:
+		sortBy = models.OrderSearchSortCreatedAt
+	}
+	if desc {
+		return "-" + string(sortBy)
+	}
+	return string(sortBy)
+}
+
+// orderSearchKeyset возвращает условие "после курсора" и ORDER BY для сортировки sortKey.
+// Все колонки ключа идут в одном направлении, id в конце делает порядок однозначным.
+func orderSearchKeyset(q *models.OrderSearchQuery, sortKey, rankExpr string, args *[]interface{}) (string, error) {
+	desc := strings.HasPrefix(sortKey, "-") || sortKey == string(models.OrderSearchSortRelevance)
+	columns := []string{"o.created_at", "o.id"}
+	var values []interface{}
+	after := q.Page.After
+	if after != nil {
+		values = []interface{}{after.CreatedAt, after.ID}
+	}
+
+	switch strings.TrimPrefix(sortKey, "-") {
+	case string(models.OrderSearchSortTotalAmount):
+		columns = append([]string{"o.total_amount"}, columns...)
+		if after != nil {
+			if after.Amount == nil {
+				return "", apperror.Validation("invalid cursor", nil)
+			}
+			values = append([]interface{}{*after.Amount}, values...)
+		}
+	case string(models.OrderSearchSortRelevance):
+		columns = append([]string{rankExpr}, columns...)
+		if after != nil {
+			if after.Rank == nil {
+				return "", apperror.Validation("invalid cursor", nil)
+			}
+			values = append([]interface{}{*after.Rank}, values...)
+		}
+	}
+
+	clause := ""
+	direction := " ASC"
+	if desc {
+		direction = " DESC"
+	}
+	if after != nil {
+		if desc {
+			clause = " AND " + keysetCondition(columns, args, values...)
+		} else {
+			clause = " AND " + keysetConditionAsc(columns, args, values...)
+		}
+	}
+	return clause + " ORDER BY " + strings.Join(columns, direction+", ") + direction, nil
+}
+
+// orderSearchTSQuery превращает свободный текст в tsquery с поиском по префиксу каждого слова:
+// "иван лен" -> "иван:* & лен:*". Символы синтаксиса tsquery отбрасываются.
+func orderSearchTSQuery(text string) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	terms := make([]string, 0, len(words))
+	for _, word := range words {
+		terms = append(terms, word+":*")
+	}
+	return strings.Join(terms, " & ")
+}
+
+// likePattern экранирует спецсимволы LIKE и ищет значение как подстроку.
+func likePattern(value string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(value)
+	return "%" + escaped + "%"
+}
+
+func containsStatus(statuses []models.OrderStatus, status models.OrderStatus) bool {
+	for _, s := range statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
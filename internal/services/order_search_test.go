@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/models"
+	"delivery-system/internal/pagination"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+var searchOrderColumns = []string{"id", "customer_name", "customer_phone", "delivery_address", "pickup_address", "pickup_lat", "pickup_lon", "delivery_lat", "delivery_lon", "total_amount", "delivery_cost", "discount_amount", "currency", "region_code", "promo_code", "status", "courier_id", "rating", "review_comment", "created_at", "updated_at", "delivered_at", "merchant_name", "delivery_attempts", "return_cost", "vehicle_type", "weight_kg", "volume_l"}
+
+func TestOrderService_SearchOrders(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewOrderService(db, newTestLogger(), newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	minAmount := rub(100)
+	q := &models.OrderSearchQuery{
+		Text:        "ленина 5",
+		Phone:       "999",
+		Name:        "an_na",
+		PromoCode:   "SALE10",
+		Statuses:    []models.OrderStatus{models.OrderStatusDelivered, models.OrderStatusCancelled},
+		CreatedFrom: &from,
+		AmountMin:   &minAmount,
+		SortBy:      models.OrderSearchSortRelevance,
+		Page:        pagination.Request{Limit: 1},
+	}
+
+	// Фасеты не учитывают фильтр по статусу
+	mock.ExpectQuery(`SELECT status, COUNT\(\*\) FROM orders o WHERE .*search_vector @@ to_tsquery\('simple', \$1\).* LIKE \$2.*customer_name ILIKE \$3.*pr.code = \$4.*created_at >= \$5.*total_amount >= \$6 GROUP BY status`).
+		WithArgs("ленина:* & 5:*", "%999%", `%an\_na%`, "SALE10", from, minAmount).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "count"}).
+			AddRow(models.OrderStatusDelivered, 3).
+			AddRow(models.OrderStatusCancelled, 1).
+			AddRow(models.OrderStatusCreated, 2))
+
+	firstID := uuid.New()
+	mock.ExpectQuery(`SELECT id, customer_name.*, ts_rank\(o.search_vector, to_tsquery\('simple', \$9\)\) FROM orders o WHERE .*o.status IN \(\$7, \$8\) ORDER BY ts_rank\(o.search_vector, to_tsquery\('simple', \$9\)\) DESC, o.created_at DESC, o.id DESC LIMIT \$10`).
+		WithArgs("ленина:* & 5:*", "%999%", `%an\_na%`, "SALE10", from, minAmount,
+			models.OrderStatusDelivered, models.OrderStatusCancelled, "ленина:* & 5:*", 2).
+		WillReturnRows(sqlmock.NewRows(append(searchOrderColumns, "rank")).
+			AddRow(firstID, "Anna", "+79991234567", "Lenina 5", "WH", 55.75, 37.61, 55.80, 37.70, 300.0, 180.0, 0.0, "RUB", "default", "SALE10", models.OrderStatusDelivered, nil, nil, nil, time.Now(), time.Now(), nil, "Pizzeria", 0, nil, "bike", 0.0, 0.0, 0.25).
+			AddRow(uuid.New(), "Anna", "+79991234567", "Lenina 5", "WH", 55.75, 37.61, 55.80, 37.70, 300.0, 180.0, 0.0, "RUB", "default", "SALE10", models.OrderStatusCancelled, nil, nil, nil, time.Now(), time.Now(), nil, nil, 0, nil, "bike", 0.0, 0.0, 0.1))
+
+	result, err := service.SearchOrders(context.Background(), q)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if result.Total != 4 {
+		t.Fatalf("expected total 4 for selected statuses, got %d", result.Total)
+	}
+	if result.Facets[models.OrderStatusCreated] != 2 {
+		t.Fatalf("expected facet for unselected status, got %v", result.Facets)
+	}
+	if len(result.Items) != 1 || result.Items[0].MerchantName == nil || *result.Items[0].MerchantName != "Pizzeria" {
+		t.Fatalf("unexpected orders: %+v", result.Items)
+	}
+	if result.NextCursor == nil {
+		t.Fatalf("expected next cursor")
+	}
+	cursor, err := pagination.Decode(*result.NextCursor)
+	if err != nil || cursor.Sort != "relevance" || cursor.Rank == nil || *cursor.Rank != 0.25 || cursor.ID != firstID.String() {
+		t.Fatalf("expected relevance cursor of the last returned order, got %+v (%v)", cursor, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestOrderService_SearchOrders_NoMatches(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewOrderService(db, newTestLogger(), newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
+
+	mock.ExpectQuery(`SELECT status, COUNT\(\*\) FROM orders o WHERE 1=1 AND o.merchant_name ILIKE \$1 GROUP BY status`).
+		WithArgs("%sushi%").
+		WillReturnRows(sqlmock.NewRows([]string{"status", "count"}))
+
+	result, err := service.SearchOrders(context.Background(), &models.OrderSearchQuery{
+		Merchant: "sushi",
+		SortBy:   models.OrderSearchSortCreatedAt,
+		SortDesc: true,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Total != 0 || len(result.Items) != 0 {
+		t.Fatalf("expected empty result, got %+v", result)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestOrderService_SearchOrders_AmountCursor(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewOrderService(db, newTestLogger(), newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
+
+	amount := "300.00"
+	after := &pagination.Cursor{Sort: "total_amount", CreatedAt: time.Now(), ID: uuid.New().String(), Amount: &amount}
+	q := &models.OrderSearchQuery{
+		Merchant: "sushi",
+		SortBy:   models.OrderSearchSortTotalAmount,
+		Page:     pagination.Request{Limit: 10, After: after},
+	}
+
+	mock.ExpectQuery(`SELECT status, COUNT\(\*\) FROM orders o`).
+		WithArgs("%sushi%").
+		WillReturnRows(sqlmock.NewRows([]string{"status", "count"}).AddRow(models.OrderStatusDelivered, 5))
+	mock.ExpectQuery(`FROM orders o WHERE 1=1 AND o.merchant_name ILIKE \$1 AND \(o.total_amount, o.created_at, o.id\) > \(\$2, \$3, \$4\) ORDER BY o.total_amount ASC, o.created_at ASC, o.id ASC LIMIT \$5`).
+		WithArgs("%sushi%", amount, after.CreatedAt, after.ID, 11).
+		WillReturnRows(sqlmock.NewRows(searchOrderColumns))
+
+	result, err := service.SearchOrders(context.Background(), q)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Total != 5 || len(result.Items) != 0 || result.NextCursor != nil {
+		t.Fatalf("unexpected result: %+v", result)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+
+	// Курсор другой сортировки отклоняется
+	q.SortDesc = true
+	if _, err := service.SearchOrders(context.Background(), q); !apperror.Is(err, apperror.KindValidation) {
+		t.Fatalf("expected validation error for cursor of another sort, got %v", err)
+	}
+}
+
+func TestOrderSearchTSQuery(t *testing.T) {
+	if got := orderSearchTSQuery(`Red  square! & 'x' |`); got != "red:* & square:* & x:*" {
+		t.Fatalf("unexpected tsquery: %q", got)
+	}
+	if got := orderSearchTSQuery(" !! "); got != "" {
+		t.Fatalf("expected empty tsquery, got %q", got)
+	}
+}
@@ -2,33 +2,58 @@ package services
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
 	"database/sql"
 	"fmt"
+	"math/big"
+	"strings"
 	"time"
 
 	"delivery-system/internal/apperror"
 	"delivery-system/internal/database"
 	"delivery-system/internal/logger"
 	"delivery-system/internal/models"
+	"delivery-system/internal/money"
+	"delivery-system/internal/pagination"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
 // OrderService представляет сервис для работы с заказами
 type OrderService struct {
-	db      *database.DB
-	log     *logger.Logger
-	pricing *PricingService
-	promo   *PromoService
+	db        *database.DB
+	log       *logger.Logger
+	pricing   *PricingService
+	promo     *PromoService
+	earnings  *EarningsService
+	payments  *PaymentService
+	receipts  *ReceiptService
+	referrals *ReferralService
+	wallet    *WalletService
+	loyalty   *LoyaltyService
+	flow      *OrderFlow
 }
 
-// NewOrderService создает новый экземпляр сервиса заказов
-func NewOrderService(db *database.DB, log *logger.Logger, pricing *PricingService, promo *PromoService) *OrderService {
+// NewOrderService создает новый экземпляр сервиса заказов.
+// flow задает машину состояний заказа; nil — переходы по умолчанию.
+func NewOrderService(db *database.DB, log *logger.Logger, pricing *PricingService, promo *PromoService, earnings *EarningsService, payments *PaymentService, receipts *ReceiptService, referrals *ReferralService, wallet *WalletService, loyalty *LoyaltyService, flow *OrderFlow) *OrderService {
+	if flow == nil {
+		flow, _ = NewOrderFlow(DefaultOrderTransitions(), 0)
+	}
 	return &OrderService{
-		db:      db,
-		log:     log,
-		pricing: pricing,
-		promo:   promo,
+		db:        db,
+		log:       log,
+		pricing:   pricing,
+		promo:     promo,
+		earnings:  earnings,
+		payments:  payments,
+		receipts:  receipts,
+		referrals: referrals,
+		wallet:    wallet,
+		loyalty:   loyalty,
+		flow:      flow,
 	}
 }
 
@@ -45,62 +70,164 @@ func (s *OrderService) CreateOrder(ctx context.Context, req *models.CreateOrderR
 	}
 	defer func() { _ = tx.Rollback() }()
 
-	// Расчет суммарной стоимости товаров
-	var itemsTotal float64
-	for _, item := range req.Items {
-		itemsTotal += item.Price * float64(item.Quantity)
-	}
+	// Регион определяется по точке забора; все суммы заказа ведутся в его валюте, в минимальных единицах
+	quote := s.pricing.QuoteCart(req.Items, *req.PickupLat, *req.PickupLon, *req.DeliveryLat, *req.DeliveryLon)
+	tariff := quote.Tariff
+	currency := tariff.Currency()
+	itemsTotal := quote.ItemsTotal
+	deliveryCost := quote.DeliveryCost
 
-	// Расчет стоимости доставки
-	distanceKm := calculateDistance(*req.PickupLat, *req.PickupLon, *req.DeliveryLat, *req.DeliveryLon)
-	deliveryCost := s.pricing.CalculateCost(distanceKm)
+	orderID := uuid.New()
 
-	// Применение промокода, если указан
-	var discountAmount float64
-	if req.PromoCode != nil && *req.PromoCode != "" {
+	// Применение промокодов, если указаны
+	discountAmount := money.Zero(currency)
+	var promoCode *string
+	var redemptions []models.PromoRedemption
+	if codes := req.AllPromoCodes(); len(codes) > 0 {
 		if s.promo == nil {
 			return nil, apperror.Validation("promo codes are not supported", nil)
 		}
 
-		discountAmount, err = s.promo.ApplyPromoWithTx(ctx, tx, *req.PromoCode, itemsTotal, deliveryCost)
+		application, err := s.promo.ApplyPromoWithTx(ctx, tx, codes, PromoOrder{
+			OrderID:       orderID,
+			CustomerPhone: req.CustomerPhone,
+			Region:        tariff.Code(),
+			Location:      tariff.Location,
+			ItemsTotal:    itemsTotal,
+			DeliveryCost:  deliveryCost,
+		})
+		if err != nil {
+			return nil, err
+		}
+		discountAmount = application.Discount
+		redemptions = application.Redemptions
+		promoCode = &codes[0]
+	}
+
+	// Баллы лояльности списываются отдельной скидкой после промокодов
+	var pointsRedeemed int
+	var pointsDiscount *money.Money
+	if req.RedeemPoints > 0 {
+		if s.loyalty == nil {
+			return nil, apperror.Validation("loyalty points are not supported", nil)
+		}
+
+		due := money.Max(itemsTotal.Add(deliveryCost).Sub(discountAmount), money.Zero(currency))
+		redemption, err := s.loyalty.RedeemWithTx(ctx, tx, req.CustomerPhone, orderID, req.RedeemPoints, due)
+		if err != nil {
+			return nil, err
+		}
+		discountAmount = discountAmount.Add(redemption.Discount)
+		pointsRedeemed = redemption.Points
+		pointsDiscount = &redemption.Discount
+	}
+
+	// Бонусы списываются после промокодов и баллов и покрывают не больше оставшейся суммы
+	var walletCredit *money.Money
+	if req.UseWalletCredit {
+		if s.wallet == nil {
+			return nil, apperror.Validation("wallet credit is not supported", nil)
+		}
+
+		due := money.Max(itemsTotal.Add(deliveryCost).Sub(discountAmount), money.Zero(currency))
+		spent, err := s.wallet.SpendWithTx(ctx, tx, req.CustomerPhone, orderID, due)
+		if err != nil {
+			return nil, err
+		}
+		discountAmount = discountAmount.Add(spent)
+		walletCredit = &spent
+	}
+
+	var referral *models.Referral
+	if req.ReferralCode != nil && *req.ReferralCode != "" {
+		if s.referrals == nil {
+			return nil, apperror.Validation("referral codes are not supported", nil)
+		}
+
+		referral, err = s.referrals.AttachWithTx(ctx, tx, ReferralOrder{
+			OrderID:         orderID,
+			Code:            *req.ReferralCode,
+			CustomerPhone:   req.CustomerPhone,
+			DeviceID:        req.DeviceID,
+			DeliveryAddress: req.DeliveryAddress,
+			Currency:        currency,
+		})
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	totalAmount := itemsTotal + deliveryCost - discountAmount
-	if totalAmount < 0 {
-		totalAmount = 0
+	var deviceID *string
+	if req.DeviceID != "" {
+		deviceID = &req.DeviceID
+	}
+	var merchantName *string
+	if name := strings.TrimSpace(req.MerchantName); name != "" {
+		merchantName = &name
+	}
+
+	totalAmount := money.Max(itemsTotal.Add(deliveryCost).Sub(discountAmount), money.Zero(currency))
+
+	// PIN для подтверждения передачи заказа клиенту
+	handoffPIN, err := generateHandoffPIN()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate handoff pin: %w", err)
 	}
 
 	// Создание заказа
-	orderID := uuid.New()
 	order := &models.Order{
-		ID:              orderID,
-		CustomerName:    req.CustomerName,
-		CustomerPhone:   req.CustomerPhone,
-		DeliveryAddress: req.DeliveryAddress,
-		PickupAddress:   req.PickupAddress,
-		PickupLat:       req.PickupLat,
-		PickupLon:       req.PickupLon,
-		DeliveryLat:     req.DeliveryLat,
-		DeliveryLon:     req.DeliveryLon,
-		TotalAmount:     totalAmount,
-		DeliveryCost:    deliveryCost,
-		DiscountAmount:  discountAmount,
-		PromoCode:       req.PromoCode,
-		Status:          models.OrderStatusCreated,
-		CreatedAt:       time.Now(),
-		UpdatedAt:       time.Now(),
+		ID:               orderID,
+		CustomerName:     req.CustomerName,
+		CustomerPhone:    req.CustomerPhone,
+		DeliveryAddress:  req.DeliveryAddress,
+		PickupAddress:    req.PickupAddress,
+		MerchantName:     merchantName,
+		PickupLat:        req.PickupLat,
+		PickupLon:        req.PickupLon,
+		DeliveryLat:      req.DeliveryLat,
+		DeliveryLon:      req.DeliveryLon,
+		TotalAmount:      totalAmount,
+		DeliveryCost:     deliveryCost,
+		DiscountAmount:   discountAmount,
+		Currency:         currency,
+		Region:           tariff.Code(),
+		PromoCode:        promoCode,
+		Status:           models.OrderStatusCreated,
+		CreatedAt:        time.Now(),
+		UpdatedAt:        time.Now(),
+		HandoffPIN:       &handoffPIN,
+		PromoRedemptions: redemptions,
+		WalletCredit:     walletCredit,
+		Referral:         referral,
+		DeviceID:         deviceID,
+		PointsRedeemed:   pointsRedeemed,
+		PointsDiscount:   pointsDiscount,
+		VehicleType:      quote.VehicleType,
+		WeightKg:         quote.WeightKg,
+		VolumeL:          quote.VolumeL,
+	}
+
+	storedCredit := money.Zero(currency)
+	if walletCredit != nil {
+		storedCredit = *walletCredit
+	}
+	storedPointsDiscount := money.Zero(currency)
+	if pointsDiscount != nil {
+		storedPointsDiscount = *pointsDiscount
+	}
+
+	if err := setActorWithTx(ctx, tx); err != nil {
+		return nil, err
 	}
 
 	query := `
-		INSERT INTO orders (id, customer_name, customer_phone, delivery_address, pickup_address, pickup_lat, pickup_lon, delivery_lat, delivery_lon, total_amount, delivery_cost, discount_amount, promo_code, status, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+		INSERT INTO orders (id, customer_name, customer_phone, delivery_address, pickup_address, pickup_lat, pickup_lon, delivery_lat, delivery_lon, total_amount, delivery_cost, discount_amount, currency, region_code, promo_code, status, created_at, updated_at, handoff_pin, device_id, wallet_credit, points_redeemed, points_discount, merchant_name, vehicle_type, weight_kg, volume_l)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25, $26, $27)
 	`
 	_, err = tx.ExecContext(ctx, query, order.ID, order.CustomerName, order.CustomerPhone,
 		order.DeliveryAddress, order.PickupAddress, order.PickupLat, order.PickupLon, order.DeliveryLat, order.DeliveryLon,
-		order.TotalAmount, order.DeliveryCost, order.DiscountAmount, order.PromoCode, order.Status, order.CreatedAt, order.UpdatedAt)
+		order.TotalAmount, order.DeliveryCost, order.DiscountAmount, order.Currency, order.Region, order.PromoCode, order.Status, order.CreatedAt, order.UpdatedAt, order.HandoffPIN,
+		order.DeviceID, storedCredit, order.PointsRedeemed, storedPointsDiscount, order.MerchantName, order.VehicleType, order.WeightKg, order.VolumeL)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create order: %w", err)
 	}
@@ -108,28 +235,50 @@ func (s *OrderService) CreateOrder(ctx context.Context, req *models.CreateOrderR
 	// Добавление товаров в заказ
 	for _, item := range req.Items {
 		itemID := uuid.New()
+		price := item.Price.In(currency)
+		taxCategory := item.TaxCategory
+		if taxCategory == "" {
+			taxCategory = models.TaxCategoryStandard
+		}
 		itemQuery := `
-			INSERT INTO order_items (id, order_id, name, quantity, price)
-			VALUES ($1, $2, $3, $4, $5)
+			INSERT INTO order_items (id, order_id, name, quantity, price, tax_category, weight_kg, volume_l)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
 		`
-		_, err = tx.ExecContext(ctx, itemQuery, itemID, orderID, item.Name, item.Quantity, item.Price)
+		_, err = tx.ExecContext(ctx, itemQuery, itemID, orderID, item.Name, item.Quantity, price, taxCategory, item.WeightKg, item.VolumeL)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create order item: %w", err)
 		}
 
 		order.Items = append(order.Items, models.OrderItem{
-			ID:       itemID,
-			OrderID:  orderID,
-			Name:     item.Name,
-			Quantity: item.Quantity,
-			Price:    item.Price,
+			ID:          itemID,
+			OrderID:     orderID,
+			Name:        item.Name,
+			Quantity:    item.Quantity,
+			Price:       price,
+			TaxCategory: taxCategory,
+			WeightKg:    item.WeightKg,
+			VolumeL:     item.VolumeL,
 		})
 	}
 
+	// Платеж сохраняется вместе с заказом, а авторизуется у провайдера только после коммита
+	if s.payments.Enabled() {
+		if _, err = s.payments.CreateWithTx(ctx, tx, order.ID, order.TotalAmount); err != nil {
+			return nil, err
+		}
+	}
+
 	if err = tx.Commit(); err != nil {
 		return nil, fmt.Errorf("failed to commit transaction: %w", err)
 	}
 
+	if s.payments.Enabled() {
+		if err := s.payments.ProcessPending(ctx, order.ID); err != nil {
+			// Авторизацию повторит сверка платежей
+			s.log.WithError(err).WithField("order_id", order.ID).Error("Failed to authorize payment")
+		}
+	}
+
 	s.log.WithFields(map[string]interface{}{
 		"order_id":      order.ID,
 		"customer_name": order.CustomerName,
@@ -139,23 +288,37 @@ func (s *OrderService) CreateOrder(ctx context.Context, req *models.CreateOrderR
 	return order, nil
 }
 
-// GetOrder получает заказ по ID
-func (s *OrderService) GetOrder(ctx context.Context, orderID uuid.UUID) (*models.Order, error) {
-	order := &models.Order{}
+// orderColumns — колонки заказа в порядке, который ожидает scanOrder.
+const orderColumns = `id, customer_name, customer_phone, delivery_address, pickup_address, pickup_lat, pickup_lon, delivery_lat, delivery_lon, total_amount, delivery_cost, discount_amount, currency, region_code, promo_code,
+	status, courier_id, rating, review_comment, created_at, updated_at, delivered_at, merchant_name,
+	delivery_attempts, return_cost, vehicle_type, weight_kg, volume_l`
 
-	query := `
-		SELECT id, customer_name, customer_phone, delivery_address, pickup_address, pickup_lat, pickup_lon, delivery_lat, delivery_lon, total_amount, delivery_cost, discount_amount, promo_code,
-		       status, courier_id, rating, review_comment, created_at, updated_at, delivered_at
-		FROM orders 
-		WHERE id = $1
-	`
+// rowScanner — общий интерфейс *sql.Row и *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
 
-	err := s.db.QueryRowContext(ctx, query, orderID).Scan(
+// scanOrder читает заказ из строки, выбранной с orderColumns.
+func scanOrder(row rowScanner) (*models.Order, error) {
+	order := &models.Order{}
+	err := row.Scan(
 		&order.ID, &order.CustomerName, &order.CustomerPhone, &order.DeliveryAddress, &order.PickupAddress,
-		&order.PickupLat, &order.PickupLon, &order.DeliveryLat, &order.DeliveryLon, &order.TotalAmount, &order.DeliveryCost, &order.DiscountAmount, &order.PromoCode,
+		&order.PickupLat, &order.PickupLon, &order.DeliveryLat, &order.DeliveryLon, &order.TotalAmount, &order.DeliveryCost, &order.DiscountAmount, &order.Currency, &order.Region, &order.PromoCode,
 		&order.Status, &order.CourierID, &order.Rating, &order.ReviewComment,
-		&order.CreatedAt, &order.UpdatedAt, &order.DeliveredAt,
+		&order.CreatedAt, &order.UpdatedAt, &order.DeliveredAt, &order.MerchantName,
+		&order.DeliveryAttempts, &order.ReturnCost, &order.VehicleType, &order.WeightKg, &order.VolumeL,
 	)
+	if err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
+// GetOrder получает заказ по ID
+func (s *OrderService) GetOrder(ctx context.Context, orderID uuid.UUID) (*models.Order, error) {
+	query := `SELECT ` + orderColumns + ` FROM orders WHERE id = $1`
+
+	order, err := scanOrder(s.db.QueryRowContext(ctx, query, orderID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, apperror.NotFound("order not found", err)
@@ -165,7 +328,7 @@ func (s *OrderService) GetOrder(ctx context.Context, orderID uuid.UUID) (*models
 
 	// Получение товаров заказа
 	itemsQuery := `
-		SELECT id, order_id, name, quantity, price
+		SELECT id, order_id, name, quantity, price, tax_category, weight_kg, volume_l
 		FROM order_items
 		WHERE order_id = $1
 	`
@@ -178,7 +341,7 @@ func (s *OrderService) GetOrder(ctx context.Context, orderID uuid.UUID) (*models
 
 	for rows.Next() {
 		var item models.OrderItem
-		if err := rows.Scan(&item.ID, &item.OrderID, &item.Name, &item.Quantity, &item.Price); err != nil {
+		if err := rows.Scan(&item.ID, &item.OrderID, &item.Name, &item.Quantity, &item.Price, &item.TaxCategory, &item.WeightKg, &item.VolumeL); err != nil {
 			return nil, fmt.Errorf("failed to scan order item: %w", err)
 		}
 		order.Items = append(order.Items, item)
@@ -188,13 +351,14 @@ func (s *OrderService) GetOrder(ctx context.Context, orderID uuid.UUID) (*models
 		return nil, fmt.Errorf("failed to iterate order items: %w", err)
 	}
 
+	order.ApplyCurrency(order.Currency)
 	return order, nil
 }
 
-// CreateReview создает отзыв по заказу и обновляет рейтинг курьера
-func (s *OrderService) CreateReview(ctx context.Context, orderID uuid.UUID, req *models.CreateReviewRequest) (*models.Review, error) {
-	if req.Rating < 1 || req.Rating > 5 {
-		return nil, apperror.Validation("rating must be between 1 and 5", nil)
+// UpdateOrderStatus обновляет статус заказа
+func (s *OrderService) UpdateOrderStatus(ctx context.Context, orderID uuid.UUID, req *models.UpdateOrderStatusRequest) (*models.OrderStatusUpdate, error) {
+	if req == nil || req.Status == "" {
+		return nil, apperror.Validation("status is required", nil)
 	}
 
 	tx, err := s.db.BeginTx(ctx, nil)
@@ -203,116 +367,68 @@ func (s *OrderService) CreateReview(ctx context.Context, orderID uuid.UUID, req
 	}
 	defer func() { _ = tx.Rollback() }()
 
-	// Получаем заказ и привязанного курьера, проверяем статус и отсутствие отзыва
-	var courierID uuid.UUID
-	var status models.OrderStatus
-	var existingRating sql.NullInt32
+	var (
+		currentStatus      models.OrderStatus
+		currentCourierID   *uuid.UUID
+		currentDeliveredAt sql.NullTime
+		handoffPIN         sql.NullString
+	)
 
-	query := `
-		SELECT courier_id, status, rating
+	selectQuery := `
+		SELECT status, courier_id, delivered_at, handoff_pin
 		FROM orders
 		WHERE id = $1
 		FOR UPDATE
 	`
-
-	if err := tx.QueryRowContext(ctx, query, orderID).Scan(&courierID, &status, &existingRating); err != nil {
+	if err := tx.QueryRowContext(ctx, selectQuery, orderID).Scan(&currentStatus, &currentCourierID, &currentDeliveredAt, &handoffPIN); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, apperror.NotFound("order not found", err)
 		}
-		return nil, fmt.Errorf("failed to fetch order for review: %w", err)
-	}
-
-	if courierID == uuid.Nil {
-		return nil, apperror.Conflict("order has no assigned courier", nil)
+		return nil, fmt.Errorf("failed to fetch order status: %w", err)
 	}
 
-	if status != models.OrderStatusDelivered {
-		return nil, apperror.Conflict("order is not delivered yet", nil)
-	}
-
-	if existingRating.Valid {
-		return nil, apperror.Conflict("review already exists for this order", nil)
-	}
-
-	reviewID := uuid.New()
-	review := &models.Review{
-		ID:        reviewID,
-		OrderID:   orderID,
-		CourierID: courierID,
-		Rating:    req.Rating,
-		Comment:   req.Comment,
-		CreatedAt: time.Now(),
-	}
-
-	insertReviewQuery := `
-		INSERT INTO reviews (id, order_id, courier_id, rating, comment, created_at)
-		VALUES ($1, $2, $3, $4, $5, $6)
-	`
-	if _, err := tx.ExecContext(ctx, insertReviewQuery, review.ID, review.OrderID, review.CourierID, review.Rating, review.Comment, review.CreatedAt); err != nil {
-		return nil, fmt.Errorf("failed to insert review: %w", err)
-	}
-
-	updateOrderQuery := `
-		UPDATE orders
-		SET rating = $1, review_comment = $2, updated_at = $3
-		WHERE id = $4
-	`
-	if _, err := tx.ExecContext(ctx, updateOrderQuery, review.Rating, review.Comment, time.Now(), orderID); err != nil {
-		return nil, fmt.Errorf("failed to update order with review: %w", err)
+	transition, err := s.flow.Transition(ctx, currentStatus, req.Status)
+	if err != nil {
+		return nil, err
 	}
 
-	if err := tx.Commit(); err != nil {
-		return nil, fmt.Errorf("failed to commit review transaction: %w", err)
+	if transition.Has(models.HookVerifyProof) {
+		if err := s.verifyProofOfDelivery(ctx, tx, orderID, handoffPIN, req.HandoffPIN); err != nil {
+			return nil, err
+		}
 	}
 
-	s.log.WithFields(map[string]interface{}{
-		"order_id":   orderID,
-		"courier_id": courierID,
-		"rating":     review.Rating,
-	}).Info("Review created and courier rating update triggered")
-
-	return review, nil
-}
-
-// UpdateOrderStatus обновляет статус заказа
-func (s *OrderService) UpdateOrderStatus(ctx context.Context, orderID uuid.UUID, req *models.UpdateOrderStatusRequest) error {
-	if req == nil || req.Status == "" {
-		return apperror.Validation("status is required", nil)
+	// Неудачная доставка: попытка засчитывается по журналу связи, повтор — в пределах лимита,
+	// возврат оценивается до того, как курьер повезет заказ обратно
+	if transition.Has(models.HookFailDelivery) {
+		if err := s.failDeliveryWithTx(ctx, tx, orderID); err != nil {
+			return nil, err
+		}
 	}
-
-	tx, err := s.db.BeginTx(ctx, nil)
-	if err != nil {
-		return fmt.Errorf("failed to begin transaction: %w", err)
+	if transition.Has(models.HookRetryDelivery) {
+		if err := s.retryDeliveryWithTx(ctx, tx, orderID); err != nil {
+			return nil, err
+		}
 	}
-	defer func() { _ = tx.Rollback() }()
-
-	var (
-		currentStatus      models.OrderStatus
-		currentCourierID   *uuid.UUID
-		currentDeliveredAt sql.NullTime
-	)
-
-	selectQuery := `
-		SELECT status, courier_id, delivered_at
-		FROM orders
-		WHERE id = $1
-		FOR UPDATE
-	`
-	if err := tx.QueryRowContext(ctx, selectQuery, orderID).Scan(&currentStatus, &currentCourierID, &currentDeliveredAt); err != nil {
-		if err == sql.ErrNoRows {
-			return apperror.NotFound("order not found", err)
+	if transition.Has(models.HookPriceReturn) {
+		if err := s.priceReturnWithTx(ctx, tx, orderID); err != nil {
+			return nil, err
 		}
-		return fmt.Errorf("failed to fetch order status: %w", err)
 	}
 
-	if !isValidOrderStatusTransition(currentStatus, req.Status) {
-		return apperror.Conflict("invalid order status transition", nil)
+	// Списание или отмена оплаты назначаются в транзакции, а провайдер вызывается после коммита:
+	// откат следующих шагов не должен оставить деньги списанными
+	paymentScheduled := false
+	if s.payments.Enabled() {
+		if paymentScheduled, err = s.payments.OnOrderStatusChange(ctx, tx, orderID, transition); err != nil {
+			return nil, err
+		}
 	}
 
 	newCourierID := currentCourierID
 	if req.CourierID != nil {
 		if *req.CourierID == uuid.Nil {
-			return apperror.Validation("courier_id must be a valid UUID", nil)
+			return nil, apperror.Validation("courier_id must be a valid UUID", nil)
 		}
 		newCourierID = req.CourierID
 	}
@@ -329,6 +445,10 @@ func (s *OrderService) UpdateOrderStatus(ctx context.Context, orderID uuid.UUID,
 		deliveredAt = sql.NullTime{Valid: false}
 	}
 
+	if err := setActorWithTx(ctx, tx); err != nil {
+		return nil, err
+	}
+
 	updateQuery := `
 		UPDATE orders
 		SET status = $1, courier_id = $2, updated_at = $3, delivered_at = $4
@@ -336,20 +456,70 @@ func (s *OrderService) UpdateOrderStatus(ctx context.Context, orderID uuid.UUID,
 	`
 	result, err := tx.ExecContext(ctx, updateQuery, req.Status, newCourierID, now, deliveredAt, orderID)
 	if err != nil {
-		return fmt.Errorf("failed to update order status: %w", err)
+		return nil, fmt.Errorf("failed to update order status: %w", err)
 	}
 
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		return fmt.Errorf("failed to get rows affected: %w", err)
+		return nil, fmt.Errorf("failed to get rows affected: %w", err)
 	}
 
 	if rowsAffected == 0 {
-		return apperror.NotFound("order not found", nil)
+		return nil, apperror.NotFound("order not found", nil)
+	}
+
+	// При отмене заказа использования промокодов, бонусы и баллы возвращаются, а приглашение по нему отклоняется
+	if transition.Has(models.HookReverseDiscounts) {
+		if s.promo != nil {
+			if err := s.promo.ReverseRedemptionsWithTx(ctx, tx, orderID); err != nil {
+				return nil, err
+			}
+		}
+		if s.wallet != nil {
+			if err := s.wallet.RefundOrderWithTx(ctx, tx, orderID); err != nil {
+				return nil, err
+			}
+		}
+		if s.referrals != nil {
+			if err := s.referrals.RejectWithTx(ctx, tx, orderID, models.ReferralReasonOrderCancelled); err != nil {
+				return nil, err
+			}
+		}
+		if s.loyalty != nil {
+			if err := s.loyalty.ReverseOrderWithTx(ctx, tx, orderID); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if transition.Has(models.HookCompleteDelivery) {
+		if err := s.completeDeliveryWithTx(ctx, tx, orderID, newCourierID); err != nil {
+			return nil, err
+		}
+	}
+
+	update := &models.OrderStatusUpdate{OrderID: orderID, OldStatus: currentStatus, Status: req.Status, CourierID: newCourierID}
+
+	// Курьер освобождается в той же транзакции, если у него не осталось других активных заказов
+	if transition.Has(models.HookReleaseCourier) && newCourierID != nil {
+		released, err := s.releaseCourierWithTx(ctx, tx, orderID, *newCourierID)
+		if err != nil {
+			return nil, err
+		}
+		if released {
+			update.ReleasedCourierID = newCourierID
+		}
 	}
 
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("failed to commit order status update: %w", err)
+		return nil, fmt.Errorf("failed to commit order status update: %w", err)
+	}
+
+	if paymentScheduled {
+		if err := s.payments.ProcessPending(ctx, orderID); err != nil {
+			// Операцию повторит сверка платежей
+			s.log.WithError(err).WithField("order_id", orderID).Error("Failed to process payment operation")
+		}
 	}
 
 	s.log.WithFields(map[string]interface{}{
@@ -358,44 +528,33 @@ func (s *OrderService) UpdateOrderStatus(ctx context.Context, orderID uuid.UUID,
 		"courier_id": newCourierID,
 	}).Info("Order status updated")
 
-	return nil
+	return update, nil
 }
 
-// GetOrders получает список заказов с фильтрацией
-func (s *OrderService) GetOrders(ctx context.Context, status *models.OrderStatus, courierID *uuid.UUID, limit, offset int) ([]*models.Order, error) {
-	query := `
-		SELECT id, customer_name, customer_phone, delivery_address, pickup_address, pickup_lat, pickup_lon, delivery_lat, delivery_lon, total_amount, delivery_cost, discount_amount, promo_code,
-		       status, courier_id, rating, review_comment, created_at, updated_at, delivered_at
-		FROM orders 
-		WHERE 1=1
-	`
+// GetOrders получает страницу заказов с фильтрацией, от новых к старым
+func (s *OrderService) GetOrders(ctx context.Context, status *models.OrderStatus, courierID *uuid.UUID, page pagination.Request) (*pagination.Page[*models.Order], error) {
+	if err := page.CheckSort(sortCreatedAt); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + orderColumns + ` FROM orders WHERE 1=1`
 	args := []interface{}{}
-	argIndex := 1
 
 	if status != nil {
-		query += fmt.Sprintf(" AND status = $%d", argIndex)
 		args = append(args, *status)
-		argIndex++
+		query += fmt.Sprintf(" AND status = $%d", len(args))
 	}
 
 	if courierID != nil {
-		query += fmt.Sprintf(" AND courier_id = $%d", argIndex)
 		args = append(args, *courierID)
-		argIndex++
+		query += fmt.Sprintf(" AND courier_id = $%d", len(args))
 	}
 
-	query += " ORDER BY created_at DESC"
-
-	if limit > 0 {
-		query += fmt.Sprintf(" LIMIT $%d", argIndex)
-		args = append(args, limit)
-		argIndex++
+	if page.After != nil {
+		query += " AND " + keysetCondition([]string{"created_at", "id"}, &args, page.After.CreatedAt, page.After.ID)
 	}
 
-	if offset > 0 {
-		query += fmt.Sprintf(" OFFSET $%d", argIndex)
-		args = append(args, offset)
-	}
+	query += " ORDER BY created_at DESC, id DESC" + pageLimitClause(page, &args)
 
 	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
@@ -405,14 +564,11 @@ func (s *OrderService) GetOrders(ctx context.Context, status *models.OrderStatus
 
 	var orders []*models.Order
 	for rows.Next() {
-		order := &models.Order{}
-		if err := rows.Scan(&order.ID, &order.CustomerName, &order.CustomerPhone,
-			&order.DeliveryAddress, &order.PickupAddress, &order.PickupLat, &order.PickupLon, &order.DeliveryLat, &order.DeliveryLon,
-			&order.TotalAmount, &order.DeliveryCost, &order.DiscountAmount, &order.PromoCode, &order.Status,
-			&order.CourierID, &order.Rating, &order.ReviewComment,
-			&order.CreatedAt, &order.UpdatedAt, &order.DeliveredAt); err != nil {
+		order, err := scanOrder(rows)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan order: %w", err)
 		}
+		order.ApplyCurrency(order.Currency)
 		orders = append(orders, order)
 	}
 
@@ -420,59 +576,132 @@ func (s *OrderService) GetOrders(ctx context.Context, status *models.OrderStatus
 		return nil, fmt.Errorf("failed to iterate orders: %w", err)
 	}
 
-	return orders, nil
+	return pagination.NewPage(orders, page.Limit, func(o *models.Order) pagination.Cursor {
+		return pagination.Cursor{Sort: sortCreatedAt, CreatedAt: o.CreatedAt, ID: o.ID.String()}
+	}), nil
 }
 
-// GetCourierReviews возвращает отзывы по курьеру
-func (s *OrderService) GetCourierReviews(ctx context.Context, courierID uuid.UUID, limit, offset int) ([]*models.Review, error) {
-	query := `
-		SELECT id, order_id, courier_id, rating, comment, created_at
-		FROM reviews
-		WHERE courier_id = $1
-		ORDER BY created_at DESC
-		LIMIT $2 OFFSET $3
+// verifyProofOfDelivery проверяет PIN клиента либо наличие загруженного фото/подписи.
+// Успешная проверка PIN фиксируется в delivery_proofs в той же транзакции. Неверный PIN
+// засчитывается в handoff_pin_failures, после maxHandoffPINAttempts ошибок PIN не принимается.
+// Проверка идет первой в переходе, до других изменений: при неверном PIN транзакция фиксируется
+// только со счетчиком, иначе откат перехода стер бы неудачную попытку.
+func (s *OrderService) verifyProofOfDelivery(ctx context.Context, tx *sql.Tx, orderID uuid.UUID, expectedPIN sql.NullString, providedPIN *string) error {
+	if providedPIN != nil && *providedPIN != "" {
+		var failures int
+		if err := tx.QueryRowContext(ctx, "SELECT handoff_pin_failures FROM orders WHERE id = $1", orderID).Scan(&failures); err != nil {
+			return fmt.Errorf("failed to check handoff pin attempts: %w", err)
+		}
+		if failures >= maxHandoffPINAttempts {
+			return apperror.Conflict("handoff pin attempts exhausted: upload a photo or signature", nil)
+		}
+
+		if !expectedPIN.Valid || subtle.ConstantTimeCompare([]byte(expectedPIN.String), []byte(*providedPIN)) != 1 {
+			if _, err := tx.ExecContext(ctx, "UPDATE orders SET handoff_pin_failures = handoff_pin_failures + 1 WHERE id = $1", orderID); err != nil {
+				return fmt.Errorf("failed to record handoff pin failure: %w", err)
+			}
+			if err := tx.Commit(); err != nil {
+				return fmt.Errorf("failed to commit handoff pin failure: %w", err)
+			}
+			return apperror.Validation("invalid handoff pin", nil)
+		}
+
+		insertQuery := `
+			INSERT INTO delivery_proofs (id, order_id, proof_type, created_at)
+			VALUES ($1, $2, $3, $4)
+		`
+		if _, err := tx.ExecContext(ctx, insertQuery, uuid.New(), orderID, models.ProofTypePIN, time.Now()); err != nil {
+			return fmt.Errorf("failed to record pin proof: %w", err)
+		}
+		return nil
+	}
+
+	var attachments int
+	countQuery := `
+		SELECT COUNT(*)
+		FROM delivery_proofs
+		WHERE order_id = $1 AND proof_type IN ('photo', 'signature')
 	`
+	if err := tx.QueryRowContext(ctx, countQuery, orderID).Scan(&attachments); err != nil {
+		return fmt.Errorf("failed to check delivery proofs: %w", err)
+	}
 
-	rows, err := s.db.QueryContext(ctx, query, courierID, limit, offset)
+	if attachments == 0 {
+		return apperror.Conflict("proof of delivery required: provide handoff_pin or upload a photo or signature", nil)
+	}
+
+	return nil
+}
+
+// maxHandoffPINAttempts — сколько неверных PIN принимается по заказу, прежде чем вручение
+// подтверждается только фото или подписью.
+const maxHandoffPINAttempts = 5
+
+// generateHandoffPIN генерирует случайный 4-значный PIN.
+func generateHandoffPIN() (string, error) {
+	n, err := rand.Int(rand.Reader, big.NewInt(10000))
 	if err != nil {
-		return nil, fmt.Errorf("failed to get courier reviews: %w", err)
+		return "", err
 	}
-	defer rows.Close()
+	return fmt.Sprintf("%04d", n.Int64()), nil
+}
 
-	var reviews []*models.Review
-	for rows.Next() {
-		review := &models.Review{}
-		if err := rows.Scan(&review.ID, &review.OrderID, &review.CourierID, &review.Rating, &review.Comment, &review.CreatedAt); err != nil {
-			return nil, fmt.Errorf("failed to scan review: %w", err)
+// completeDeliveryWithTx выполняет начисления по доставленному заказу в транзакции смены статуса.
+func (s *OrderService) completeDeliveryWithTx(ctx context.Context, tx *sql.Tx, orderID uuid.UUID, courierID *uuid.UUID) error {
+	// Баллы за заказ начисляются при доставке от оплаченной суммы
+	if s.loyalty != nil {
+		if err := s.loyalty.EarnWithTx(ctx, tx, orderID); err != nil {
+			return err
 		}
-		reviews = append(reviews, review)
 	}
 
-	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("failed to iterate reviews: %w", err)
+	// Бонусы за приглашение начисляются при доставке первого заказа нового клиента
+	if s.referrals != nil {
+		if err := s.referrals.RewardWithTx(ctx, tx, orderID); err != nil {
+			return err
+		}
+	}
+
+	// Начисление курьеру за доставку фиксируется в той же транзакции
+	if s.earnings != nil && courierID != nil {
+		if err := s.earnings.RecordDeliveryEarnings(ctx, tx, orderID, *courierID); err != nil {
+			return err
+		}
 	}
 
-	return reviews, nil
+	// Чек формируется вместе с переводом в delivered, чтобы не было доставленных заказов без чека
+	if s.receipts != nil {
+		if err := s.receipts.IssueWithTx(ctx, tx, orderID); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func isValidOrderStatusTransition(from, to models.OrderStatus) bool {
-	if from == to {
-		return true
-	}
-	switch from {
-	case models.OrderStatusCreated:
-		return to == models.OrderStatusAccepted || to == models.OrderStatusCancelled
-	case models.OrderStatusAccepted:
-		return to == models.OrderStatusPreparing || to == models.OrderStatusCancelled
-	case models.OrderStatusPreparing:
-		return to == models.OrderStatusReady || to == models.OrderStatusCancelled
-	case models.OrderStatusReady:
-		return to == models.OrderStatusInDelivery || to == models.OrderStatusCancelled
-	case models.OrderStatusInDelivery:
-		return to == models.OrderStatusDelivered || to == models.OrderStatusCancelled
-	case models.OrderStatusDelivered, models.OrderStatusCancelled:
-		return false
-	default:
-		return false
+// releaseCourierWithTx возвращает курьера в available, если у него не осталось незавершенных заказов.
+// Возвращает true, если статус курьера изменился.
+func (s *OrderService) releaseCourierWithTx(ctx context.Context, tx *sql.Tx, orderID, courierID uuid.UUID) (bool, error) {
+	terminal := make([]string, len(s.flow.TerminalStatuses()))
+	for i, status := range s.flow.TerminalStatuses() {
+		terminal[i] = string(status)
+	}
+
+	query := `
+		UPDATE couriers
+		SET status = $1, updated_at = $2
+		WHERE id = $3 AND status = $4
+		  AND NOT EXISTS (
+			SELECT 1 FROM orders
+			WHERE courier_id = $3 AND id <> $5 AND status <> ALL($6)
+		  )
+	`
+	result, err := tx.ExecContext(ctx, query, models.CourierStatusAvailable, time.Now(), courierID, models.CourierStatusBusy, orderID, pq.Array(terminal))
+	if err != nil {
+		return false, fmt.Errorf("failed to release courier: %w", err)
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get rows affected: %w", err)
 	}
+	return rowsAffected > 0, nil
 }
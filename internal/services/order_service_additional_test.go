@@ -3,10 +3,17 @@ package services
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"testing"
 	"time"
 
+	"delivery-system/internal/actor"
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/config"
 	"delivery-system/internal/models"
+	"delivery-system/internal/money"
+	"delivery-system/internal/pagination"
+	"delivery-system/internal/payments"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/google/uuid"
@@ -17,34 +24,36 @@ func TestOrderService_CreateOrder_Success(t *testing.T) {
 	defer db.Close()
 
 	log := newTestLogger()
-	service := NewOrderService(db, log, newTestPricingService(), nil)
+	service := NewOrderService(db, log, newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
 
 	req := &models.CreateOrderRequest{
 		CustomerName:    "Test Customer",
 		CustomerPhone:   "+79991234567",
 		DeliveryAddress: "Moscow, Street 1",
 		PickupAddress:   "Moscow, Warehouse 1",
+		MerchantName:    " Pizzeria ",
 		PickupLat:       floatPtr(55.75),
 		PickupLon:       floatPtr(37.61),
 		DeliveryLat:     floatPtr(55.80),
 		DeliveryLon:     floatPtr(37.70),
 		Items: []models.CreateOrderItemRequest{
-			{Name: "Item1", Quantity: 2, Price: 100.0},
-			{Name: "Item2", Quantity: 1, Price: 50.0},
+			{Name: "Item1", Quantity: 2, Price: money.New(10000, "RUB")},
+			{Name: "Item2", Quantity: 1, Price: money.New(5000, "RUB"), TaxCategory: models.TaxCategoryReduced},
 		},
 	}
 
 	mock.ExpectBegin()
+	mock.ExpectExec("SELECT set_config").WithArgs("system:unknown").WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectExec("INSERT INTO orders").
-		WithArgs(sqlmock.AnyArg(), req.CustomerName, req.CustomerPhone, req.DeliveryAddress, req.PickupAddress, req.PickupLat, req.PickupLon, req.DeliveryLat, req.DeliveryLon, sqlmock.AnyArg(), sqlmock.AnyArg(), 0.0, nil, models.OrderStatusCreated, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WithArgs(sqlmock.AnyArg(), req.CustomerName, req.CustomerPhone, req.DeliveryAddress, req.PickupAddress, req.PickupLat, req.PickupLon, req.DeliveryLat, req.DeliveryLon, sqlmock.AnyArg(), sqlmock.AnyArg(), money.New(0, "RUB"), "RUB", "default", nil, models.OrderStatusCreated, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), nil, money.New(0, "RUB"), 0, money.New(0, "RUB"), "Pizzeria", models.VehicleTypeBike, 0.0, 0.0).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	mock.ExpectExec("INSERT INTO order_items").
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "Item1", 2, 100.0).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "Item1", 2, money.New(10000, "RUB"), models.TaxCategoryStandard, 0.0, 0.0).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	mock.ExpectExec("INSERT INTO order_items").
-		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "Item2", 1, 50.0).
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "Item2", 1, money.New(5000, "RUB"), models.TaxCategoryReduced, 0.0, 0.0).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	mock.ExpectCommit()
@@ -58,6 +67,138 @@ func TestOrderService_CreateOrder_Success(t *testing.T) {
 		t.Fatalf("expected 2 items, got %d", len(order.Items))
 	}
 
+	if order.Currency != "RUB" || order.TotalAmount != money.New(25000, "RUB").Add(order.DeliveryCost) {
+		t.Fatalf("unexpected order totals: total=%s delivery=%s", order.TotalAmount.Display(), order.DeliveryCost.Display())
+	}
+
+	if order.HandoffPIN == nil || len(*order.HandoffPIN) != 4 {
+		t.Fatalf("expected 4-digit handoff pin, got %v", order.HandoffPIN)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestOrderService_CreateOrder_WalletCreditAndReferral(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	log := newTestLogger()
+	wallet := NewWalletService(db, log)
+	referrals := NewReferralService(db, log, wallet, &config.ReferralConfig{ReferrerReward: 300, RefereeReward: 200})
+	service := NewOrderService(db, log, newTestPricingService(), nil, nil, nil, nil, referrals, wallet, nil, nil)
+
+	code := "REF-ABCD2345"
+	req := &models.CreateOrderRequest{
+		CustomerName:    "Test Customer",
+		CustomerPhone:   "+79990000002",
+		DeliveryAddress: "Moscow, Street 2",
+		PickupAddress:   "Moscow, Warehouse 1",
+		PickupLat:       floatPtr(55.75),
+		PickupLon:       floatPtr(37.61),
+		DeliveryLat:     floatPtr(55.75),
+		DeliveryLon:     floatPtr(37.61),
+		Items: []models.CreateOrderItemRequest{
+			{Name: "Item1", Quantity: 1, Price: money.New(10000, "RUB")},
+		},
+		ReferralCode:    &code,
+		DeviceID:        "device-2",
+		UseWalletCredit: true,
+	}
+
+	// Товары 100 + минимальная доставка 150, бонусов на счете 300 — списывается 250
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT balance FROM wallets").
+		WithArgs("79990000002", "RUB").
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow("300.00"))
+	mock.ExpectExec("UPDATE wallets").
+		WithArgs(rub(250), sqlmock.AnyArg(), "79990000002", "RUB").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO wallet_transactions").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT customer_phone FROM referral_codes").
+		WithArgs(code).
+		WillReturnRows(sqlmock.NewRows([]string{"customer_phone"}).AddRow("79990000001"))
+	mock.ExpectQuery("FROM referrals WHERE referee_phone").
+		WillReturnRows(sqlmock.NewRows([]string{"referred", "has_orders"}).AddRow(false, false))
+	mock.ExpectQuery("SELECT (.+) FROM orders").
+		WillReturnRows(sqlmock.NewRows([]string{"same_device", "same_address"}).AddRow(false, false))
+	mock.ExpectExec("INSERT INTO referrals").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("SELECT set_config").WithArgs("system:unknown").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO orders").
+		WithArgs(sqlmock.AnyArg(), req.CustomerName, req.CustomerPhone, req.DeliveryAddress, req.PickupAddress, req.PickupLat, req.PickupLon, req.DeliveryLat, req.DeliveryLon, rub(0), rub(150), rub(250), "RUB", "default", nil, models.OrderStatusCreated, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), "device-2", rub(250), 0, rub(0), nil, models.VehicleTypeBike, 0.0, 0.0).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO order_items").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	order, err := service.CreateOrder(context.Background(), req)
+	if err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+
+	if order.WalletCredit == nil || *order.WalletCredit != rub(250) || !order.TotalAmount.IsZero() {
+		t.Fatalf("unexpected wallet credit %v, total %s", order.WalletCredit, order.TotalAmount)
+	}
+	if order.Referral == nil || order.Referral.Status != models.ReferralStatusPending {
+		t.Fatalf("expected pending referral, got %+v", order.Referral)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestOrderService_CreateOrder_UsesPickupRegion(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	pricing, err := NewRegionalPricingService(models.Region{Currency: "RUB", BaseFare: 100, PerKm: 20, MinFare: 150}, []models.Region{{
+		Code:     "de-berlin",
+		Currency: "EUR",
+		BaseFare: 3,
+		PerKm:    1,
+		MinFare:  5,
+		Bounds:   &models.GeoBounds{MinLat: 52.3, MinLon: 13.0, MaxLat: 52.7, MaxLon: 13.8},
+		Payout:   &models.RegionPayout{PerDelivery: 3, PerKm: 0.5, MinPayout: 4},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected pricing error: %v", err)
+	}
+	service := NewOrderService(db, newTestLogger(), pricing, nil, nil, nil, nil, nil, nil, nil, nil)
+
+	req := &models.CreateOrderRequest{
+		CustomerName:    "Anna",
+		CustomerPhone:   "+491701234567",
+		DeliveryAddress: "Berlin, Street 2",
+		PickupAddress:   "Berlin, Kitchen 1",
+		PickupLat:       floatPtr(52.52),
+		PickupLon:       floatPtr(13.40),
+		DeliveryLat:     floatPtr(52.52),
+		DeliveryLon:     floatPtr(13.40),
+		Items:           []models.CreateOrderItemRequest{{Name: "Bowl", Quantity: 1, Price: money.New(1250, "")}},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec("SELECT set_config").WithArgs("system:unknown").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO orders").
+		WithArgs(sqlmock.AnyArg(), req.CustomerName, req.CustomerPhone, req.DeliveryAddress, req.PickupAddress, req.PickupLat, req.PickupLon, req.DeliveryLat, req.DeliveryLon, money.New(1750, "EUR"), money.New(500, "EUR"), money.New(0, "EUR"), "EUR", "de-berlin", nil, models.OrderStatusCreated, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), nil, money.New(0, "EUR"), 0, money.New(0, "EUR"), nil, models.VehicleTypeBike, 0.0, 0.0).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO order_items").
+		WithArgs(sqlmock.AnyArg(), sqlmock.AnyArg(), "Bowl", 1, money.New(1250, "EUR"), models.TaxCategoryStandard, 0.0, 0.0).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	order, err := service.CreateOrder(context.Background(), req)
+	if err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+	if order.Region != "de-berlin" || order.Currency != "EUR" || order.TotalAmount.Display() != "17.50 EUR" {
+		t.Fatalf("unexpected regional order: region=%s total=%s", order.Region, order.TotalAmount.Display())
+	}
+
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Fatalf("unmet expectations: %v", err)
 	}
@@ -72,20 +213,20 @@ func TestOrderService_GetOrder_Success(t *testing.T) {
 	defer db.Close()
 
 	log := newTestLogger()
-	service := NewOrderService(db, log, newTestPricingService(), nil)
+	service := NewOrderService(db, log, newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
 
 	orderID := uuid.New()
 	courierID := uuid.New()
 
-	mock.ExpectQuery("SELECT id, customer_name, customer_phone, delivery_address, pickup_address, pickup_lat, pickup_lon, delivery_lat, delivery_lon, total_amount, delivery_cost, discount_amount, promo_code").
+	mock.ExpectQuery("SELECT id, customer_name, customer_phone, delivery_address, pickup_address, pickup_lat, pickup_lon, delivery_lat, delivery_lon, total_amount, delivery_cost, discount_amount, currency, region_code, promo_code").
 		WithArgs(orderID).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "customer_name", "customer_phone", "delivery_address", "pickup_address", "pickup_lat", "pickup_lon", "delivery_lat", "delivery_lon", "total_amount", "delivery_cost", "discount_amount", "promo_code", "status", "courier_id", "rating", "review_comment", "created_at", "updated_at", "delivered_at"}).
-			AddRow(orderID, "John", "+79991234567", "Moscow", "Warehouse", 55.75, 37.61, 55.80, 37.70, 500.0, 200.0, 20.0, "SALE10", models.OrderStatusDelivered, courierID, 5, "good", time.Now(), time.Now(), time.Now()))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "customer_name", "customer_phone", "delivery_address", "pickup_address", "pickup_lat", "pickup_lon", "delivery_lat", "delivery_lon", "total_amount", "delivery_cost", "discount_amount", "currency", "region_code", "promo_code", "status", "courier_id", "rating", "review_comment", "created_at", "updated_at", "delivered_at", "merchant_name", "delivery_attempts", "return_cost", "vehicle_type", "weight_kg", "volume_l"}).
+			AddRow(orderID, "John", "+79991234567", "Moscow", "Warehouse", 55.75, 37.61, 55.80, 37.70, 500.0, 200.0, 20.0, "RUB", "default", "SALE10", models.OrderStatusDelivered, courierID, 5, "good", time.Now(), time.Now(), time.Now(), nil, 0, nil, "bike", 0.0, 0.0))
 
-	mock.ExpectQuery("SELECT id, order_id, name, quantity, price FROM order_items").
+	mock.ExpectQuery("SELECT id, order_id, name, quantity, price, tax_category, weight_kg, volume_l FROM order_items").
 		WithArgs(orderID).
-		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "name", "quantity", "price"}).
-			AddRow(uuid.New(), orderID, "Pizza", 1, 500.0))
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "name", "quantity", "price", "tax_category", "weight_kg", "volume_l"}).
+			AddRow(uuid.New(), orderID, "Pizza", 1, 500.0, "standard", 0.0, 0.0))
 
 	order, err := service.GetOrder(context.Background(), orderID)
 	if err != nil {
@@ -110,11 +251,11 @@ func TestOrderService_GetOrder_NotFound(t *testing.T) {
 	defer db.Close()
 
 	log := newTestLogger()
-	service := NewOrderService(db, log, newTestPricingService(), nil)
+	service := NewOrderService(db, log, newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
 
 	orderID := uuid.New()
 
-	mock.ExpectQuery("SELECT id, customer_name, customer_phone, delivery_address, pickup_address, pickup_lat, pickup_lon, delivery_lat, delivery_lon, total_amount, delivery_cost, discount_amount, promo_code").
+	mock.ExpectQuery("SELECT id, customer_name, customer_phone, delivery_address, pickup_address, pickup_lat, pickup_lon, delivery_lat, delivery_lon, total_amount, delivery_cost, discount_amount, currency, region_code, promo_code").
 		WithArgs(orderID).
 		WillReturnError(sql.ErrNoRows)
 
@@ -133,7 +274,7 @@ func TestOrderService_UpdateOrderStatus_Success(t *testing.T) {
 	defer db.Close()
 
 	log := newTestLogger()
-	service := NewOrderService(db, log, newTestPricingService(), nil)
+	service := NewOrderService(db, log, newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
 
 	orderID := uuid.New()
 	courierID := uuid.New()
@@ -143,18 +284,21 @@ func TestOrderService_UpdateOrderStatus_Success(t *testing.T) {
 	}
 
 	mock.ExpectBegin()
-	mock.ExpectQuery("SELECT status, courier_id, delivered_at FROM orders").
+	mock.ExpectQuery("SELECT status, courier_id, delivered_at, handoff_pin FROM orders").
 		WithArgs(orderID).
-		WillReturnRows(sqlmock.NewRows([]string{"status", "courier_id", "delivered_at"}).
-			AddRow(models.OrderStatusReady, nil, nil))
+		WillReturnRows(sqlmock.NewRows([]string{"status", "courier_id", "delivered_at", "handoff_pin"}).
+			AddRow(models.OrderStatusReady, nil, nil, "1234"))
 
+	// Исполнитель из контекста передается в транзакцию для триггера журнала
+	mock.ExpectExec("SELECT set_config").WithArgs("courier:" + courierID.String()).WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectExec("UPDATE orders SET status").
 		WithArgs(req.Status, req.CourierID, sqlmock.AnyArg(), sqlmock.AnyArg(), orderID).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	mock.ExpectCommit()
 
-	err := service.UpdateOrderStatus(context.Background(), orderID, req)
+	ctx := actor.WithContext(context.Background(), actor.Actor{Type: actor.TypeCourier, ID: courierID.String()})
+	_, err := service.UpdateOrderStatus(ctx, orderID, req)
 	if err != nil {
 		t.Fatalf("expected success, got error: %v", err)
 	}
@@ -169,31 +313,231 @@ func TestOrderService_UpdateOrderStatus_Delivered(t *testing.T) {
 	defer db.Close()
 
 	log := newTestLogger()
-	service := NewOrderService(db, log, newTestPricingService(), nil)
+	service := NewOrderService(db, log, newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
 
 	orderID := uuid.New()
 	courierID := uuid.New()
+	pin := "1234"
 	req := &models.UpdateOrderStatusRequest{
-		Status:    models.OrderStatusDelivered,
-		CourierID: &courierID,
+		Status:     models.OrderStatusDelivered,
+		CourierID:  &courierID,
+		HandoffPIN: &pin,
 	}
 
 	mock.ExpectBegin()
-	mock.ExpectQuery("SELECT status, courier_id, delivered_at FROM orders").
+	mock.ExpectQuery("SELECT status, courier_id, delivered_at, handoff_pin FROM orders").
 		WithArgs(orderID).
-		WillReturnRows(sqlmock.NewRows([]string{"status", "courier_id", "delivered_at"}).
-			AddRow(models.OrderStatusInDelivery, courierID, nil))
+		WillReturnRows(sqlmock.NewRows([]string{"status", "courier_id", "delivered_at", "handoff_pin"}).
+			AddRow(models.OrderStatusInDelivery, courierID, nil, "1234"))
+	mock.ExpectQuery("SELECT handoff_pin_failures FROM orders").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"handoff_pin_failures"}).AddRow(0))
+
+	mock.ExpectExec("INSERT INTO delivery_proofs").
+		WithArgs(sqlmock.AnyArg(), orderID, models.ProofTypePIN, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
 
+	mock.ExpectExec("SELECT set_config").WithArgs("system:unknown").WillReturnResult(sqlmock.NewResult(0, 1))
 	mock.ExpectExec("UPDATE orders SET status").
 		WithArgs(req.Status, req.CourierID, sqlmock.AnyArg(), sqlmock.AnyArg(), orderID).
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
+	// Курьер освобождается, если у него нет других незавершенных заказов
+	mock.ExpectExec("UPDATE couriers SET status = \\$1, updated_at = \\$2 WHERE id = \\$3 AND status = \\$4 AND NOT EXISTS").
+		WithArgs(models.CourierStatusAvailable, sqlmock.AnyArg(), courierID, models.CourierStatusBusy, orderID, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
 	mock.ExpectCommit()
 
-	err := service.UpdateOrderStatus(context.Background(), orderID, req)
+	update, err := service.UpdateOrderStatus(context.Background(), orderID, req)
 	if err != nil {
 		t.Fatalf("expected success, got error: %v", err)
 	}
+	if update.OldStatus != models.OrderStatusInDelivery || update.ReleasedCourierID == nil || *update.ReleasedCourierID != courierID {
+		t.Fatalf("expected released courier in update, got %+v", update)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestOrderService_UpdateOrderStatus_Delivered_RecordsEarnings(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	log := newTestLogger()
+	earnings := NewEarningsService(db, log, NewPayoutRules(60, 12, 90, "RUB"), nil)
+	service := NewOrderService(db, log, newTestPricingService(), nil, earnings, nil, nil, nil, nil, nil, nil)
+
+	orderID := uuid.New()
+	courierID := uuid.New()
+	pin := "1234"
+	req := &models.UpdateOrderStatusRequest{Status: models.OrderStatusDelivered, HandoffPIN: &pin}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, courier_id, delivered_at, handoff_pin FROM orders").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "courier_id", "delivered_at", "handoff_pin"}).
+			AddRow(models.OrderStatusInDelivery, courierID, nil, "1234"))
+	mock.ExpectQuery("SELECT handoff_pin_failures FROM orders").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"handoff_pin_failures"}).AddRow(0))
+	mock.ExpectExec("INSERT INTO delivery_proofs").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("SELECT set_config").WithArgs("system:unknown").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE orders SET status").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectQuery("SELECT pickup_lat, pickup_lon, delivery_lat, delivery_lon, region_code, currency FROM orders").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"pickup_lat", "pickup_lon", "delivery_lat", "delivery_lon", "region_code", "currency"}).
+			AddRow(55.75, 37.61, 55.75, 37.61, "default", "RUB"))
+	mock.ExpectExec("INSERT INTO ledger_transactions").
+		WithArgs(sqlmock.AnyArg(), courierID, sqlmock.AnyArg(), models.LedgerKindDelivery, rub(90), "RUB", nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO ledger_entries").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE couriers").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if _, err := service.UpdateOrderStatus(context.Background(), orderID, req); err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestOrderService_UpdateOrderStatus_Delivered_WrongPIN(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	log := newTestLogger()
+	service := NewOrderService(db, log, newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
+
+	orderID := uuid.New()
+	pin := "0000"
+	req := &models.UpdateOrderStatusRequest{Status: models.OrderStatusDelivered, HandoffPIN: &pin}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, courier_id, delivered_at, handoff_pin FROM orders").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "courier_id", "delivered_at", "handoff_pin"}).
+			AddRow(models.OrderStatusInDelivery, uuid.New(), nil, "1234"))
+	mock.ExpectQuery("SELECT handoff_pin_failures FROM orders").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"handoff_pin_failures"}).AddRow(0))
+	// Неудачная попытка фиксируется, хотя переход отклонен
+	mock.ExpectExec("UPDATE orders SET handoff_pin_failures = handoff_pin_failures \\+ 1").
+		WithArgs(orderID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	_, err := service.UpdateOrderStatus(context.Background(), orderID, req)
+	if !apperror.Is(err, apperror.KindValidation) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestOrderService_UpdateOrderStatus_Delivered_PINAttemptsExhausted(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	log := newTestLogger()
+	service := NewOrderService(db, log, newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
+
+	orderID := uuid.New()
+	pin := "1234"
+	req := &models.UpdateOrderStatusRequest{Status: models.OrderStatusDelivered, HandoffPIN: &pin}
+
+	// После исчерпания попыток не принимается даже верный PIN
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, courier_id, delivered_at, handoff_pin FROM orders").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "courier_id", "delivered_at", "handoff_pin"}).
+			AddRow(models.OrderStatusInDelivery, uuid.New(), nil, "1234"))
+	mock.ExpectQuery("SELECT handoff_pin_failures FROM orders").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"handoff_pin_failures"}).AddRow(maxHandoffPINAttempts))
+	mock.ExpectRollback()
+
+	_, err := service.UpdateOrderStatus(context.Background(), orderID, req)
+	if !apperror.Is(err, apperror.KindConflict) {
+		t.Fatalf("expected conflict after exhausted attempts, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestOrderService_UpdateOrderStatus_Delivered_WithAttachment(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	log := newTestLogger()
+	service := NewOrderService(db, log, newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
+
+	orderID := uuid.New()
+	req := &models.UpdateOrderStatusRequest{Status: models.OrderStatusDelivered}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, courier_id, delivered_at, handoff_pin FROM orders").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "courier_id", "delivered_at", "handoff_pin"}).
+			AddRow(models.OrderStatusInDelivery, uuid.New(), nil, "1234"))
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM delivery_proofs").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectExec("SELECT set_config").WithArgs("system:unknown").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE orders SET status").
+		WithArgs(req.Status, sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), orderID).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE couriers").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	if _, err := service.UpdateOrderStatus(context.Background(), orderID, req); err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestOrderService_UpdateOrderStatus_Delivered_NoProof(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	log := newTestLogger()
+	service := NewOrderService(db, log, newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
+
+	orderID := uuid.New()
+	req := &models.UpdateOrderStatusRequest{Status: models.OrderStatusDelivered}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, courier_id, delivered_at, handoff_pin FROM orders").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "courier_id", "delivered_at", "handoff_pin"}).
+			AddRow(models.OrderStatusInDelivery, uuid.New(), nil, "1234"))
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM delivery_proofs").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(0))
+	mock.ExpectRollback()
+
+	_, err := service.UpdateOrderStatus(context.Background(), orderID, req)
+	if !apperror.Is(err, apperror.KindConflict) {
+		t.Fatalf("expected conflict error, got %v", err)
+	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
 		t.Fatalf("unmet expectations: %v", err)
@@ -205,7 +549,7 @@ func TestOrderService_UpdateOrderStatus_NotFound(t *testing.T) {
 	defer db.Close()
 
 	log := newTestLogger()
-	service := NewOrderService(db, log, newTestPricingService(), nil)
+	service := NewOrderService(db, log, newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
 
 	orderID := uuid.New()
 	req := &models.UpdateOrderStatusRequest{
@@ -213,12 +557,12 @@ func TestOrderService_UpdateOrderStatus_NotFound(t *testing.T) {
 	}
 
 	mock.ExpectBegin()
-	mock.ExpectQuery("SELECT status, courier_id, delivered_at FROM orders").
+	mock.ExpectQuery("SELECT status, courier_id, delivered_at, handoff_pin FROM orders").
 		WithArgs(orderID).
 		WillReturnError(sql.ErrNoRows)
 	mock.ExpectRollback()
 
-	err := service.UpdateOrderStatus(context.Background(), orderID, req)
+	_, err := service.UpdateOrderStatus(context.Background(), orderID, req)
 	if err == nil {
 		t.Fatalf("expected error, got nil")
 	}
@@ -233,26 +577,26 @@ func TestOrderService_GetOrders_WithFilters(t *testing.T) {
 	defer db.Close()
 
 	log := newTestLogger()
-	service := NewOrderService(db, log, newTestPricingService(), nil)
+	service := NewOrderService(db, log, newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
 
 	status := models.OrderStatusCreated
 	courierID := uuid.New()
-	limit, offset := 10, 0
+	limit := 10
 
-	rows := sqlmock.NewRows([]string{"id", "customer_name", "customer_phone", "delivery_address", "pickup_address", "pickup_lat", "pickup_lon", "delivery_lat", "delivery_lon", "total_amount", "delivery_cost", "discount_amount", "promo_code", "status", "courier_id", "rating", "review_comment", "created_at", "updated_at", "delivered_at"}).
-		AddRow(uuid.New(), "Alice", "+79001234567", "Moscow", "Warehouse", 55.75, 37.61, 55.80, 37.70, 300.0, 180.0, 0.0, nil, status, courierID, nil, nil, time.Now(), time.Now(), nil)
+	rows := sqlmock.NewRows([]string{"id", "customer_name", "customer_phone", "delivery_address", "pickup_address", "pickup_lat", "pickup_lon", "delivery_lat", "delivery_lon", "total_amount", "delivery_cost", "discount_amount", "currency", "region_code", "promo_code", "status", "courier_id", "rating", "review_comment", "created_at", "updated_at", "delivered_at", "merchant_name", "delivery_attempts", "return_cost", "vehicle_type", "weight_kg", "volume_l"}).
+		AddRow(uuid.New(), "Alice", "+79001234567", "Moscow", "Warehouse", 55.75, 37.61, 55.80, 37.70, 300.0, 180.0, 0.0, "RUB", "default", nil, status, courierID, nil, nil, time.Now(), time.Now(), nil, nil, 0, nil, "bike", 0.0, 0.0)
 
-	mock.ExpectQuery("SELECT id, customer_name, customer_phone, delivery_address, pickup_address, pickup_lat, pickup_lon, delivery_lat, delivery_lon, total_amount, delivery_cost, discount_amount, promo_code").
-		WithArgs(status, courierID, limit).
+	mock.ExpectQuery("SELECT id, customer_name, customer_phone, delivery_address, pickup_address, pickup_lat, pickup_lon, delivery_lat, delivery_lon, total_amount, delivery_cost, discount_amount, currency, region_code, promo_code").
+		WithArgs(status, courierID, limit+1).
 		WillReturnRows(rows)
 
-	orders, err := service.GetOrders(context.Background(), &status, &courierID, limit, offset)
+	page, err := service.GetOrders(context.Background(), &status, &courierID, pagination.Request{Limit: limit})
 	if err != nil {
 		t.Fatalf("expected success, got error: %v", err)
 	}
 
-	if len(orders) != 1 {
-		t.Fatalf("expected 1 order, got %d", len(orders))
+	if len(page.Items) != 1 || page.NextCursor != nil {
+		t.Fatalf("expected 1 order and no next page, got %d", len(page.Items))
 	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -265,21 +609,156 @@ func TestOrderService_GetOrders_NoFilters(t *testing.T) {
 	defer db.Close()
 
 	log := newTestLogger()
-	service := NewOrderService(db, log, newTestPricingService(), nil)
+	service := NewOrderService(db, log, newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
 
-	rows := sqlmock.NewRows([]string{"id", "customer_name", "customer_phone", "delivery_address", "pickup_address", "pickup_lat", "pickup_lon", "delivery_lat", "delivery_lon", "total_amount", "delivery_cost", "discount_amount", "promo_code", "status", "courier_id", "rating", "review_comment", "created_at", "updated_at", "delivered_at"}).
-		AddRow(uuid.New(), "Bob", "+79009876543", "SPb", "WH", 55.75, 37.61, 55.80, 37.70, 200.0, 170.0, 0.0, nil, models.OrderStatusCreated, nil, nil, nil, time.Now(), time.Now(), nil)
+	rows := sqlmock.NewRows([]string{"id", "customer_name", "customer_phone", "delivery_address", "pickup_address", "pickup_lat", "pickup_lon", "delivery_lat", "delivery_lon", "total_amount", "delivery_cost", "discount_amount", "currency", "region_code", "promo_code", "status", "courier_id", "rating", "review_comment", "created_at", "updated_at", "delivered_at", "merchant_name", "delivery_attempts", "return_cost", "vehicle_type", "weight_kg", "volume_l"}).
+		AddRow(uuid.New(), "Bob", "+79009876543", "SPb", "WH", 55.75, 37.61, 55.80, 37.70, 200.0, 170.0, 0.0, "RUB", "default", nil, models.OrderStatusCreated, nil, nil, nil, time.Now(), time.Now(), nil, nil, 0, nil, "bike", 0.0, 0.0)
 
-	mock.ExpectQuery("SELECT id, customer_name, customer_phone, delivery_address, pickup_address, pickup_lat, pickup_lon, delivery_lat, delivery_lon, total_amount, delivery_cost, discount_amount, promo_code").
+	mock.ExpectQuery("SELECT id, customer_name, customer_phone, delivery_address, pickup_address, pickup_lat, pickup_lon, delivery_lat, delivery_lon, total_amount, delivery_cost, discount_amount, currency, region_code, promo_code").
 		WillReturnRows(rows)
 
-	orders, err := service.GetOrders(context.Background(), nil, nil, 0, 0)
+	page, err := service.GetOrders(context.Background(), nil, nil, pagination.Request{})
 	if err != nil {
 		t.Fatalf("expected success, got error: %v", err)
 	}
 
-	if len(orders) != 1 {
-		t.Fatalf("expected 1 order, got %d", len(orders))
+	if len(page.Items) != 1 {
+		t.Fatalf("expected 1 order, got %d", len(page.Items))
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestOrderService_GetOrders_Cursor(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewOrderService(db, newTestLogger(), newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
+
+	after := &pagination.Cursor{Sort: "created_at", CreatedAt: time.Now().Add(-time.Hour), ID: uuid.New().String()}
+	rows := sqlmock.NewRows([]string{"id", "customer_name", "customer_phone", "delivery_address", "pickup_address", "pickup_lat", "pickup_lon", "delivery_lat", "delivery_lon", "total_amount", "delivery_cost", "discount_amount", "currency", "region_code", "promo_code", "status", "courier_id", "rating", "review_comment", "created_at", "updated_at", "delivered_at", "merchant_name", "delivery_attempts", "return_cost", "vehicle_type", "weight_kg", "volume_l"}).
+		AddRow(uuid.New(), "Bob", "+79009876543", "SPb", "WH", 55.75, 37.61, 55.80, 37.70, 200.0, 170.0, 0.0, "RUB", "default", nil, models.OrderStatusCreated, nil, nil, nil, time.Now(), time.Now(), nil, nil, 0, nil, "bike", 0.0, 0.0)
+
+	// offset игнорируется, если передан курсор
+	mock.ExpectQuery(`FROM orders WHERE 1=1 AND \(created_at, id\) < \(\$1, \$2\) ORDER BY created_at DESC, id DESC LIMIT \$3$`).
+		WithArgs(after.CreatedAt, after.ID, 3).
+		WillReturnRows(rows)
+
+	page, err := service.GetOrders(context.Background(), nil, nil, pagination.Request{Limit: 2, Offset: 40, After: after})
+	if err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+	if len(page.Items) != 1 || page.NextCursor != nil {
+		t.Fatalf("expected last page with 1 order, got %d", len(page.Items))
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestOrderService_UpdateOrderStatus_PaymentGate(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	log := newTestLogger()
+	paymentSvc := NewPaymentService(db, payments.NewFakeProvider(), log, &config.PaymentsConfig{GateTransitions: true}, nil)
+	service := NewOrderService(db, log, newTestPricingService(), nil, nil, paymentSvc, nil, nil, nil, nil, nil)
+
+	orderID := uuid.New()
+	req := &models.UpdateOrderStatusRequest{Status: models.OrderStatusAccepted}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT status, courier_id, delivered_at, handoff_pin FROM orders").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status", "courier_id", "delivered_at", "handoff_pin"}).
+			AddRow(models.OrderStatusCreated, nil, nil, "1234"))
+	mock.ExpectQuery("SELECT id, order_id, provider, provider_ref, status").
+		WithArgs(orderID).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	_, err := service.UpdateOrderStatus(context.Background(), orderID, req)
+	if !apperror.Is(err, apperror.KindConflict) {
+		t.Fatalf("expected conflict without payment, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestOrderService_UpdateOrderStatus_CaptureAfterCommit(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	log := newTestLogger()
+	provider := &countingProvider{FakeProvider: payments.NewFakeProvider()}
+	orderID := uuid.New()
+	courierID := uuid.New()
+	ref, _ := provider.Authorize(context.Background(), payments.AuthorizeRequest{OrderID: orderID, Amount: rub(100)})
+	paymentSvc := NewPaymentService(db, provider, log, &config.PaymentsConfig{}, nil)
+	service := NewOrderService(db, log, newTestPricingService(), nil, nil, paymentSvc, nil, nil, nil, nil, nil)
+
+	pin := "1234"
+	req := &models.UpdateOrderStatusRequest{Status: models.OrderStatusDelivered, HandoffPIN: &pin}
+
+	expectDelivery := func() {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT status, courier_id, delivered_at, handoff_pin FROM orders").
+			WithArgs(orderID).
+			WillReturnRows(sqlmock.NewRows([]string{"status", "courier_id", "delivered_at", "handoff_pin"}).
+				AddRow(models.OrderStatusInDelivery, courierID, nil, "1234"))
+		mock.ExpectQuery("SELECT handoff_pin_failures FROM orders").
+			WithArgs(orderID).
+			WillReturnRows(sqlmock.NewRows([]string{"handoff_pin_failures"}).AddRow(0))
+		mock.ExpectExec("INSERT INTO delivery_proofs").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectQuery("SELECT id, order_id, provider, provider_ref, status").
+			WithArgs(orderID).
+			WillReturnRows(paymentRow(orderID, ref, models.PaymentStatusAuthorized, 100, 0, 0))
+		mock.ExpectExec("UPDATE payments").
+			WithArgs(models.PaymentStatusAuthorized, ref, rub(0), rub(0), nil, "capture", nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectExec("SELECT set_config").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("UPDATE orders SET status").
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+
+	// Сбой освобождения курьера откатывает переход: деньги не должны быть списаны
+	expectDelivery()
+	mock.ExpectExec("UPDATE couriers").
+		WillReturnError(errors.New("db down"))
+	mock.ExpectRollback()
+
+	if _, err := service.UpdateOrderStatus(context.Background(), orderID, req); err == nil {
+		t.Fatalf("expected error when courier release fails")
+	}
+	if provider.captures != 0 {
+		t.Fatalf("payment captured although the order transaction rolled back")
+	}
+
+	// После успешного коммита списание выполняется отдельной транзакцией
+	expectDelivery()
+	mock.ExpectExec("UPDATE couriers").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, order_id, provider, provider_ref, status").
+		WithArgs(orderID).
+		WillReturnRows(pendingPaymentRow(orderID, &ref, models.PaymentStatusAuthorized, models.PaymentOperationCapture, 100, 0))
+	mock.ExpectExec("UPDATE payments").
+		WithArgs(models.PaymentStatusCaptured, ref, rub(100), rub(0), nil, nil, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if _, err := service.UpdateOrderStatus(context.Background(), orderID, req); err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+	if provider.captures != 1 {
+		t.Fatalf("expected capture after commit, got %d", provider.captures)
 	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
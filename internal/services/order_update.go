@@ -0,0 +1,259 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/models"
+	"delivery-system/internal/money"
+
+	"github.com/google/uuid"
+)
+
+// UpdateOrder меняет получателя, адрес доставки или состав заказа, пока заказ в created или accepted.
+// В одной транзакции пересчитываются стоимость доставки, скидки промокодов и итог, а авторизация
+// оплаты переносится на новую сумму. Возвращает заказ и изменения по полям.
+func (s *OrderService) UpdateOrder(ctx context.Context, orderID uuid.UUID, req *models.UpdateOrderRequest) (*models.OrderUpdate, error) {
+	if req == nil || (req.CustomerName == nil && req.DeliveryAddress == nil && req.DeliveryLat == nil && req.DeliveryLon == nil && req.Items == nil) {
+		return nil, apperror.Validation("nothing to update", nil)
+	}
+	if req.CustomerName != nil && strings.TrimSpace(*req.CustomerName) == "" {
+		return nil, apperror.Validation("customer name must not be empty", nil)
+	}
+	if req.DeliveryAddress != nil && strings.TrimSpace(*req.DeliveryAddress) == "" {
+		return nil, apperror.Validation("delivery address must not be empty", nil)
+	}
+	if (req.DeliveryLat == nil) != (req.DeliveryLon == nil) || (req.DeliveryAddress != nil && req.DeliveryLat == nil) {
+		return nil, apperror.Validation("delivery coordinates are required for a new delivery address", nil)
+	}
+	if req.Items != nil && len(req.Items) == 0 {
+		return nil, apperror.Validation("order items are required", nil)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	order, err := scanOrder(tx.QueryRowContext(ctx, `SELECT `+orderColumns+` FROM orders WHERE id = $1 FOR UPDATE`, orderID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperror.NotFound("order not found", err)
+		}
+		return nil, fmt.Errorf("failed to get order: %w", err)
+	}
+	if order.Status != models.OrderStatusCreated && order.Status != models.OrderStatusAccepted {
+		return nil, apperror.Conflict(fmt.Sprintf("order in status %s can no longer be modified", order.Status), nil)
+	}
+	if order.PickupLat == nil || order.PickupLon == nil {
+		return nil, apperror.Conflict("order has no pickup coordinates to price the delivery", nil)
+	}
+	order.ApplyCurrency(order.Currency)
+	currency := order.Currency
+
+	var walletCredit, pointsDiscount money.Money
+	if err := tx.QueryRowContext(ctx, "SELECT wallet_credit, points_discount FROM orders WHERE id = $1", orderID).Scan(&walletCredit, &pointsDiscount); err != nil {
+		return nil, fmt.Errorf("failed to get order discounts: %w", err)
+	}
+	walletCredit = walletCredit.In(currency)
+	pointsDiscount = pointsDiscount.In(currency)
+
+	if order.Items, err = s.orderItemsWithTx(ctx, tx, orderID, currency); err != nil {
+		return nil, err
+	}
+
+	updated := *order
+	if req.CustomerName != nil {
+		updated.CustomerName = strings.TrimSpace(*req.CustomerName)
+	}
+	if req.DeliveryAddress != nil {
+		updated.DeliveryAddress = strings.TrimSpace(*req.DeliveryAddress)
+	}
+	if req.DeliveryLat != nil {
+		updated.DeliveryLat, updated.DeliveryLon = req.DeliveryLat, req.DeliveryLon
+	}
+	if updated.DeliveryLat == nil || updated.DeliveryLon == nil {
+		return nil, apperror.Conflict("order has no delivery coordinates to price the delivery", nil)
+	}
+
+	items := req.Items
+	if items == nil {
+		for _, item := range order.Items {
+			items = append(items, models.CreateOrderItemRequest{Name: item.Name, Quantity: item.Quantity, Price: item.Price, TaxCategory: item.TaxCategory, WeightKg: item.WeightKg, VolumeL: item.VolumeL})
+		}
+	}
+	quote := s.pricing.QuoteCart(items, *updated.PickupLat, *updated.PickupLon, *updated.DeliveryLat, *updated.DeliveryLon)
+	if quote.Tariff.Currency() != currency {
+		return nil, apperror.Conflict("order region tariff has changed currency, recreate the order", nil)
+	}
+	updated.DeliveryCost = quote.DeliveryCost
+	updated.VehicleType = quote.VehicleType
+	updated.WeightKg = quote.WeightKg
+	updated.VolumeL = quote.VolumeL
+
+	// Промокоды проверяются заново по новой корзине; неподходящий код отклоняет изменение
+	promoDiscount := money.Zero(currency)
+	if s.promo != nil {
+		tariff := quote.Tariff
+		application, err := s.promo.RevalidateWithTx(ctx, tx, PromoOrder{
+			OrderID:       orderID,
+			CustomerPhone: order.CustomerPhone,
+			Region:        tariff.Code(),
+			Location:      tariff.Location,
+			ItemsTotal:    quote.ItemsTotal,
+			DeliveryCost:  quote.DeliveryCost,
+		})
+		if err != nil {
+			return nil, err
+		}
+		promoDiscount = application.Discount
+	}
+
+	// Баллы и бонусы уже списаны и не пересчитываются: новая сумма должна их покрывать
+	due := quote.ItemsTotal.Add(quote.DeliveryCost).Sub(promoDiscount)
+	if due.Sub(pointsDiscount).Sub(walletCredit).IsNegative() {
+		return nil, apperror.Conflict("new order total is less than redeemed points and wallet credit", nil)
+	}
+	updated.DiscountAmount = promoDiscount.Add(pointsDiscount).Add(walletCredit)
+	updated.TotalAmount = money.Max(quote.ItemsTotal.Add(quote.DeliveryCost).Sub(updated.DiscountAmount), money.Zero(currency))
+	updated.UpdatedAt = time.Now()
+
+	updateQuery := `
+		UPDATE orders
+		SET customer_name = $1, delivery_address = $2, delivery_lat = $3, delivery_lon = $4,
+			delivery_cost = $5, discount_amount = $6, total_amount = $7, updated_at = $8,
+			vehicle_type = $9, weight_kg = $10, volume_l = $11
+		WHERE id = $12
+	`
+	if _, err := tx.ExecContext(ctx, updateQuery, updated.CustomerName, updated.DeliveryAddress, updated.DeliveryLat, updated.DeliveryLon,
+		updated.DeliveryCost, updated.DiscountAmount, updated.TotalAmount, updated.UpdatedAt,
+		updated.VehicleType, updated.WeightKg, updated.VolumeL, orderID); err != nil {
+		return nil, fmt.Errorf("failed to update order: %w", err)
+	}
+
+	if req.Items != nil {
+		if _, err := tx.ExecContext(ctx, "DELETE FROM order_items WHERE order_id = $1", orderID); err != nil {
+			return nil, fmt.Errorf("failed to replace order items: %w", err)
+		}
+		updated.Items = nil
+		for _, item := range req.Items {
+			orderItem := models.OrderItem{
+				ID:          uuid.New(),
+				OrderID:     orderID,
+				Name:        item.Name,
+				Quantity:    item.Quantity,
+				Price:       item.Price.In(currency),
+				TaxCategory: item.TaxCategory,
+				WeightKg:    item.WeightKg,
+				VolumeL:     item.VolumeL,
+			}
+			if orderItem.TaxCategory == "" {
+				orderItem.TaxCategory = models.TaxCategoryStandard
+			}
+			itemQuery := `
+				INSERT INTO order_items (id, order_id, name, quantity, price, tax_category, weight_kg, volume_l)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			`
+			if _, err := tx.ExecContext(ctx, itemQuery, orderItem.ID, orderID, orderItem.Name, orderItem.Quantity, orderItem.Price, orderItem.TaxCategory,
+				orderItem.WeightKg, orderItem.VolumeL); err != nil {
+				return nil, fmt.Errorf("failed to create order item: %w", err)
+			}
+			updated.Items = append(updated.Items, orderItem)
+		}
+	}
+
+	// Новая сумма холдируется до коммита; лишняя авторизация отменяется по его результату
+	var finishPayment func(committed bool)
+	if updated.TotalAmount != order.TotalAmount && s.payments.Enabled() {
+		if finishPayment, err = s.payments.ReauthorizeWithTx(ctx, tx, orderID, updated.TotalAmount); err != nil {
+			return nil, err
+		}
+	}
+
+	err = tx.Commit()
+	if finishPayment != nil {
+		finishPayment(err == nil)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to commit order update: %w", err)
+	}
+
+	changes := diffOrders(order, &updated)
+	s.log.WithFields(map[string]interface{}{
+		"order_id":     orderID,
+		"changes":      len(changes),
+		"total_amount": updated.TotalAmount,
+	}).Info("Order updated")
+
+	return &models.OrderUpdate{Order: &updated, Changes: changes}, nil
+}
+
+// orderItemsWithTx читает товары заказа внутри транзакции.
+func (s *OrderService) orderItemsWithTx(ctx context.Context, tx *sql.Tx, orderID uuid.UUID, currency string) ([]models.OrderItem, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT id, order_id, name, quantity, price, tax_category, weight_kg, volume_l FROM order_items WHERE order_id = $1", orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order items: %w", err)
+	}
+	defer rows.Close()
+
+	var items []models.OrderItem
+	for rows.Next() {
+		var item models.OrderItem
+		if err := rows.Scan(&item.ID, &item.OrderID, &item.Name, &item.Quantity, &item.Price, &item.TaxCategory, &item.WeightKg, &item.VolumeL); err != nil {
+			return nil, fmt.Errorf("failed to scan order item: %w", err)
+		}
+		item.Price = item.Price.In(currency)
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate order items: %w", err)
+	}
+	return items, nil
+}
+
+// diffOrders перечисляет поля, изменившиеся после правки заказа, в порядке полей заказа.
+func diffOrders(before, after *models.Order) []models.FieldChange {
+	changes := []models.FieldChange{}
+	add := func(field string, old, new interface{}, changed bool) {
+		if changed {
+			changes = append(changes, models.FieldChange{Field: field, Old: old, New: new})
+		}
+	}
+
+	add("customer_name", before.CustomerName, after.CustomerName, before.CustomerName != after.CustomerName)
+	add("delivery_address", before.DeliveryAddress, after.DeliveryAddress, before.DeliveryAddress != after.DeliveryAddress)
+	add("delivery_lat", before.DeliveryLat, after.DeliveryLat, !equalFloatPtr(before.DeliveryLat, after.DeliveryLat))
+	add("delivery_lon", before.DeliveryLon, after.DeliveryLon, !equalFloatPtr(before.DeliveryLon, after.DeliveryLon))
+	add("items", before.Items, after.Items, !equalItems(before.Items, after.Items))
+	add("vehicle_type", before.VehicleType, after.VehicleType, before.VehicleType != after.VehicleType)
+	add("delivery_cost", before.DeliveryCost, after.DeliveryCost, before.DeliveryCost != after.DeliveryCost)
+	add("discount_amount", before.DiscountAmount, after.DiscountAmount, before.DiscountAmount != after.DiscountAmount)
+	add("total_amount", before.TotalAmount, after.TotalAmount, before.TotalAmount != after.TotalAmount)
+	return changes
+}
+
+func equalFloatPtr(a, b *float64) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+// equalItems сравнивает состав заказа без учета идентификаторов строк.
+func equalItems(a, b []models.OrderItem) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Name != b[i].Name || a[i].Quantity != b[i].Quantity || a[i].Price != b[i].Price || a[i].TaxCategory != b[i].TaxCategory ||
+			a[i].WeightKg != b[i].WeightKg || a[i].VolumeL != b[i].VolumeL {
+			return false
+		}
+	}
+	return true
+}
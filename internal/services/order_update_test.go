@@ -0,0 +1,153 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+func expectOrderForUpdate(mock sqlmock.Sqlmock, orderID uuid.UUID, status models.OrderStatus, total float64) {
+	now := time.Now()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, customer_name, .* FROM orders WHERE id = \\$1 FOR UPDATE").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "customer_name", "customer_phone", "delivery_address", "pickup_address", "pickup_lat", "pickup_lon", "delivery_lat", "delivery_lon", "total_amount", "delivery_cost", "discount_amount", "currency", "region_code", "promo_code", "status", "courier_id", "rating", "review_comment", "created_at", "updated_at", "delivered_at", "merchant_name", "delivery_attempts", "return_cost", "vehicle_type", "weight_kg", "volume_l"}).
+			AddRow(orderID, "Anna", "+79991234567", "Old street 1", "Warehouse", 55.75, 37.61, 55.80, 37.70, total, 180.0, 0.0, "RUB", "default", nil, status, nil, nil, nil, now, now, nil, nil, 0, nil, "bike", 0.0, 0.0))
+}
+
+func TestOrderService_UpdateOrder(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	pricing := newTestPricingService()
+	service := NewOrderService(db, newTestLogger(), pricing, nil, nil, nil, nil, nil, nil, nil, nil)
+	orderID := uuid.New()
+	address := "New street 5"
+	lat, lon := 55.76, 37.62
+	req := &models.UpdateOrderRequest{
+		DeliveryAddress: &address,
+		DeliveryLat:     &lat,
+		DeliveryLon:     &lon,
+		Items:           []models.CreateOrderItemRequest{{Name: "Pizza", Quantity: 2, Price: rub(300)}},
+	}
+	deliveryCost := pricing.CalculateCost(calculateDistance(55.75, 37.61, lat, lon))
+	total := rub(600).Add(deliveryCost)
+
+	expectOrderForUpdate(mock, orderID, models.OrderStatusAccepted, 480)
+	mock.ExpectQuery("SELECT wallet_credit, points_discount FROM orders").WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"wallet_credit", "points_discount"}).AddRow(0.0, 0.0))
+	mock.ExpectQuery("SELECT id, order_id, name, quantity, price, tax_category, weight_kg, volume_l FROM order_items").WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "name", "quantity", "price", "tax_category", "weight_kg", "volume_l"}).
+			AddRow(uuid.New(), orderID, "Pizza", 1, 300.0, models.TaxCategoryStandard, 0.0, 0.0))
+	mock.ExpectExec("UPDATE orders SET customer_name = \\$1, delivery_address = \\$2").
+		WithArgs("Anna", address, &lat, &lon, deliveryCost, rub(0), total, sqlmock.AnyArg(), models.VehicleTypeBike, 0.0, 0.0, orderID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("DELETE FROM order_items WHERE order_id = \\$1").WithArgs(orderID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO order_items").
+		WithArgs(sqlmock.AnyArg(), orderID, "Pizza", 2, rub(300), models.TaxCategoryStandard, 0.0, 0.0).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	update, err := service.UpdateOrder(context.Background(), orderID, req)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if update.Order.TotalAmount != total || update.Order.DeliveryAddress != address || len(update.Order.Items) != 1 {
+		t.Fatalf("unexpected order: %+v", update.Order)
+	}
+
+	fields := map[string]models.FieldChange{}
+	for _, change := range update.Changes {
+		fields[change.Field] = change
+	}
+	for _, field := range []string{"delivery_address", "delivery_lat", "delivery_lon", "items", "delivery_cost", "total_amount"} {
+		if _, ok := fields[field]; !ok {
+			t.Fatalf("expected %s in changes, got %+v", field, update.Changes)
+		}
+	}
+	if _, ok := fields["customer_name"]; ok {
+		t.Fatalf("unchanged customer name must not be in diff: %+v", update.Changes)
+	}
+	if fields["delivery_address"].Old != "Old street 1" || fields["delivery_address"].New != address {
+		t.Fatalf("unexpected address change: %+v", fields["delivery_address"])
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestOrderService_UpdateOrder_NotAllowedAfterAccepted(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewOrderService(db, newTestLogger(), newTestPricingService(), nil, nil, nil, nil, nil, nil, nil, nil)
+	orderID := uuid.New()
+	name := "Boris"
+
+	expectOrderForUpdate(mock, orderID, models.OrderStatusPreparing, 480)
+	mock.ExpectRollback()
+
+	if _, err := service.UpdateOrder(context.Background(), orderID, &models.UpdateOrderRequest{CustomerName: &name}); !apperror.Is(err, apperror.KindConflict) {
+		t.Fatalf("expected conflict for preparing order, got %v", err)
+	}
+	if _, err := service.UpdateOrder(context.Background(), orderID, &models.UpdateOrderRequest{}); !apperror.Is(err, apperror.KindValidation) {
+		t.Fatalf("expected validation error for empty update, got %v", err)
+	}
+	address := "No coords"
+	if _, err := service.UpdateOrder(context.Background(), orderID, &models.UpdateOrderRequest{DeliveryAddress: &address}); !apperror.Is(err, apperror.KindValidation) {
+		t.Fatalf("expected validation error for address without coordinates, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestOrderService_UpdateOrder_PromoRevalidated(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	promo := NewPromoService(db, newTestLogger(), nil, nil)
+	service := NewOrderService(db, newTestLogger(), newTestPricingService(), promo, nil, nil, nil, nil, nil, nil, nil)
+	orderID := uuid.New()
+
+	expectOrderForUpdate(mock, orderID, models.OrderStatusCreated, 430)
+	mock.ExpectQuery("SELECT wallet_credit, points_discount FROM orders").WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"wallet_credit", "points_discount"}).AddRow(0.0, 0.0))
+	mock.ExpectQuery("SELECT id, order_id, name, quantity, price, tax_category, weight_kg, volume_l FROM order_items").WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "name", "quantity", "price", "tax_category", "weight_kg", "volume_l"}).
+			AddRow(uuid.New(), orderID, "Pizza", 2, 150.0, models.TaxCategoryStandard, 0.0, 0.0))
+
+	// Применение кода отменяется и проверяется заново: корзина стала меньше минимальной суммы
+	mock.ExpectQuery("SELECT code FROM promo_redemptions WHERE order_id = \\$1 AND reversed_at IS NULL").WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"code"}).AddRow("SALE50"))
+	mock.ExpectExec("WITH reversed AS").WithArgs(orderID, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT discount_type, amount, currency, max_uses, used_count, expires_at, active, rules, stacking FROM promo_codes").
+		WithArgs("SALE50").
+		WillReturnRows(sqlmock.NewRows([]string{"discount_type", "amount", "currency", "max_uses", "used_count", "expires_at", "active", "rules", "stacking"}).
+			AddRow(models.DiscountTypeFixed, 50.0, "RUB", 0, 1, nil, true, `{"min_items_total":300}`, "exclusive"))
+	mock.ExpectRollback()
+
+	_, err := service.UpdateOrder(context.Background(), orderID, &models.UpdateOrderRequest{
+		Items: []models.CreateOrderItemRequest{{Name: "Pizza", Quantity: 1, Price: rub(150)}},
+	})
+	if !apperror.Is(err, apperror.KindConflict) {
+		t.Fatalf("expected promo rejection, got %v", err)
+	}
+	if reason, ok := promoRejectReason(err); !ok || reason != models.PromoReasonMinItemsTotal {
+		t.Fatalf("expected min items total reason, got %v (%v)", reason, ok)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,45 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+
+	"delivery-system/internal/pagination"
+)
+
+// keysetCondition возвращает условие "строка после курсора" для сортировки по убыванию всех columns:
+// (created_at, id) < ($3, $4). Значения курсора добавляются в args.
+func keysetCondition(columns []string, args *[]interface{}, values ...interface{}) string {
+	return keysetCompare(columns, "<", args, values)
+}
+
+// keysetConditionAsc — то же условие для сортировки по возрастанию всех columns: (created_at, id) > ($3, $4).
+func keysetConditionAsc(columns []string, args *[]interface{}, values ...interface{}) string {
+	return keysetCompare(columns, ">", args, values)
+}
+
+func keysetCompare(columns []string, op string, args *[]interface{}, values []interface{}) string {
+	placeholders := make([]string, len(values))
+	for i, value := range values {
+		*args = append(*args, value)
+		placeholders[i] = fmt.Sprintf("$%d", len(*args))
+	}
+	return "(" + strings.Join(columns, ", ") + ") " + op + " (" + strings.Join(placeholders, ", ") + ")"
+}
+
+// pageLimitClause возвращает LIMIT с запасом в одну строку для next_cursor и OFFSET, если курсор не передан.
+func pageLimitClause(page pagination.Request, args *[]interface{}) string {
+	clause := ""
+	if limit := page.FetchLimit(); limit > 0 {
+		*args = append(*args, limit)
+		clause += fmt.Sprintf(" LIMIT $%d", len(*args))
+	}
+	if page.After == nil && page.Offset > 0 {
+		*args = append(*args, page.Offset)
+		clause += fmt.Sprintf(" OFFSET $%d", len(*args))
+	}
+	return clause
+}
+
+// sortCreatedAt — сортировка списков по (created_at, id) от новых к старым.
+const sortCreatedAt = "created_at"
@@ -0,0 +1,695 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/config"
+	"delivery-system/internal/database"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+	"delivery-system/internal/money"
+	"delivery-system/internal/payments"
+
+	"github.com/google/uuid"
+)
+
+// Типы вебхуков платежного провайдера
+const (
+	PaymentEventAuthorized = "payment.authorized"
+	PaymentEventCaptured   = "payment.captured"
+	PaymentEventVoided     = "payment.voided"
+	PaymentEventRefunded   = "payment.refunded"
+	PaymentEventFailed     = "payment.failed"
+)
+
+// PaymentService управляет жизненным циклом платежа заказа:
+// авторизация при создании, списание при доставке, отмена и возвраты.
+type PaymentService struct {
+	db       *database.DB
+	provider payments.PaymentProvider
+	log      *logger.Logger
+	gate     bool
+	loyalty  *LoyaltyService
+	// retryInterval — период повтора операций, не выполненных после коммита
+	retryInterval time.Duration
+}
+
+// NewPaymentService создает сервис платежей. provider == nil означает, что платежи отключены.
+// loyalty, если задан, отзывает баллы за заказ при возвратах.
+func NewPaymentService(db *database.DB, provider payments.PaymentProvider, log *logger.Logger, cfg *config.PaymentsConfig, loyalty *LoyaltyService) *PaymentService {
+	gate := false
+	retryInterval := time.Minute
+	if cfg != nil {
+		gate = cfg.GateTransitions
+		retryInterval = time.Duration(cfg.RetryIntervalSeconds) * time.Second
+	}
+	return &PaymentService{
+		db:       db,
+		provider: provider,
+		log:      log,
+		gate:     gate,
+		loyalty:  loyalty,
+
+		retryInterval: retryInterval,
+	}
+}
+
+// Enabled сообщает, подключен ли платежный провайдер.
+func (s *PaymentService) Enabled() bool {
+	return s != nil && s.provider != nil
+}
+
+// CreateWithTx сохраняет платеж заказа в рамках транзакции создания заказа. Провайдер здесь не
+// вызывается: авторизация помечается ожидающей и выполняется ProcessPending после коммита, чтобы
+// откат заказа не оставлял у провайдера авторизацию без платежа.
+func (s *PaymentService) CreateWithTx(ctx context.Context, tx *sql.Tx, orderID uuid.UUID, amount money.Money) (*models.Payment, error) {
+	if !s.Enabled() {
+		return nil, nil
+	}
+
+	now := time.Now()
+	operation := models.PaymentOperationAuthorize
+	payment := &models.Payment{
+		ID:               uuid.New(),
+		OrderID:          orderID,
+		Provider:         s.provider.Name(),
+		Status:           models.PaymentStatusPending,
+		Amount:           amount,
+		CapturedAmount:   money.Zero(amount.Currency),
+		RefundedAmount:   money.Zero(amount.Currency),
+		Currency:         amount.Currency,
+		PendingOperation: &operation,
+		CreatedAt:        now,
+		UpdatedAt:        now,
+	}
+
+	query := `
+		INSERT INTO payments (id, order_id, provider, provider_ref, status, amount, captured_amount, refunded_amount, currency, failure_reason, pending_operation, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	`
+	if _, err := tx.ExecContext(ctx, query, payment.ID, payment.OrderID, payment.Provider, payment.ProviderRef, payment.Status,
+		payment.Amount, payment.CapturedAmount, payment.RefundedAmount, payment.Currency, payment.FailureReason, payment.PendingOperation,
+		payment.CreatedAt, payment.UpdatedAt); err != nil {
+		return nil, fmt.Errorf("failed to save payment: %w", err)
+	}
+
+	return payment, nil
+}
+
+// ReauthorizeWithTx переносит авторизацию на новую сумму заказа после его изменения: новая сумма
+// холдируется до отмены прежней, чтобы заказ не остался без авторизации. Отказ провайдера
+// отклоняет изменение заказа. Неуспешный платеж не трогается, списанный изменить нельзя.
+// Холдирование обратимо, поэтому выполняется в транзакции; возвращаемый finish вызывается после
+// коммита: при успехе он отменяет прежнюю авторизацию, при откате — новую.
+func (s *PaymentService) ReauthorizeWithTx(ctx context.Context, tx *sql.Tx, orderID uuid.UUID, amount money.Money) (func(committed bool), error) {
+	if !s.Enabled() {
+		return nil, nil
+	}
+
+	payment, err := s.lockPayment(ctx, tx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if payment == nil || payment.Amount == amount {
+		return nil, nil
+	}
+
+	switch payment.Status {
+	case models.PaymentStatusFailed, models.PaymentStatusVoided:
+		return nil, nil
+	case models.PaymentStatusAuthorized:
+	case models.PaymentStatusPending:
+		return nil, apperror.Conflict("payment authorization is still in progress", nil)
+	default:
+		return nil, apperror.Conflict("payment has already been captured", nil)
+	}
+
+	// Каждая попытка холдирует заново: откатившаяся попытка отменяет свою авторизацию
+	ref, err := s.provider.Authorize(ctx, payments.AuthorizeRequest{
+		OrderID:        orderID,
+		Amount:         amount,
+		IdempotencyKey: payment.ID.String() + ":reauthorize:" + uuid.NewString(),
+	})
+	if err != nil {
+		return nil, apperror.Conflict("payment re-authorization failed", err)
+	}
+	previousRef := payment.ProviderRef
+	finish := func(committed bool) {
+		// Отменяется прежняя авторизация после коммита или новая, если изменение заказа откатилось
+		voidRef := ref
+		if committed {
+			if previousRef == nil {
+				return
+			}
+			voidRef = *previousRef
+		}
+		if err := s.provider.Void(context.WithoutCancel(ctx), voidRef); err != nil {
+			// Авторизация истечет у провайдера
+			s.log.WithError(err).WithField("order_id", orderID).Warn("Failed to void superseded authorization")
+		}
+	}
+
+	payment.ProviderRef = &ref
+	payment.Amount = amount
+	payment.Currency = amount.Currency
+	payment.UpdatedAt = time.Now()
+	query := `
+		UPDATE payments
+		SET provider_ref = $1, amount = $2, currency = $3, updated_at = $4
+		WHERE id = $5
+	`
+	if _, err := tx.ExecContext(ctx, query, payment.ProviderRef, payment.Amount, payment.Currency, payment.UpdatedAt, payment.ID); err != nil {
+		finish(false)
+		return nil, fmt.Errorf("failed to update payment: %w", err)
+	}
+	return finish, nil
+}
+
+// OnOrderStatusChange применяет к платежу переход заказа в рамках той же транзакции:
+// capture_payment помечает платеж к списанию, release_payment — к отмене авторизации или возврату.
+// Сами вызовы провайдера выполняет ProcessPending после коммита; scheduled сообщает, что операция
+// назначена. При включенном gate_transitions запрещает переходы без авторизованного (или списанного) платежа.
+func (s *PaymentService) OnOrderStatusChange(ctx context.Context, tx *sql.Tx, orderID uuid.UUID, t models.OrderTransition) (scheduled bool, err error) {
+	if !s.Enabled() || t.From == t.To {
+		return false, nil
+	}
+
+	payment, err := s.lockPayment(ctx, tx, orderID)
+	if err != nil {
+		return false, err
+	}
+
+	switch {
+	case t.Has(models.HookReleasePayment):
+		if payment == nil {
+			return false, nil
+		}
+		switch payment.Status {
+		case models.PaymentStatusPending, models.PaymentStatusAuthorized, models.PaymentStatusCaptured, models.PaymentStatusPartiallyRefunded:
+			return true, s.schedule(ctx, tx, payment, models.PaymentOperationRelease)
+		default:
+			return false, nil
+		}
+	case t.Has(models.HookCapturePayment):
+		if payment == nil {
+			if s.gate {
+				return false, apperror.Conflict("payment is required before delivery", nil)
+			}
+			return false, nil
+		}
+		switch payment.Status {
+		case models.PaymentStatusCaptured, models.PaymentStatusPartiallyRefunded, models.PaymentStatusRefunded:
+			return false, nil
+		case models.PaymentStatusAuthorized:
+			return true, s.schedule(ctx, tx, payment, models.PaymentOperationCapture)
+		case models.PaymentStatusPending:
+			if s.gate {
+				return false, apperror.Conflict("payment is not authorized", nil)
+			}
+			// Авторизация еще не прошла: списание назначается вместо нее и выполнится сразу после авторизации
+			return true, s.schedule(ctx, tx, payment, models.PaymentOperationCapture)
+		default:
+			if s.gate {
+				return false, apperror.Conflict("payment is not authorized", nil)
+			}
+			return false, nil
+		}
+	default:
+		if !s.gate {
+			return false, nil
+		}
+		if payment == nil || (payment.Status != models.PaymentStatusAuthorized && payment.Status != models.PaymentStatusCaptured) {
+			return false, apperror.Conflict("payment is not authorized", nil)
+		}
+		return false, nil
+	}
+}
+
+// ProcessPending выполняет у провайдера операцию, назначенную платежу заказа, и сохраняет результат.
+// Вызывается после коммита транзакции заказа; без назначенной операции ничего не делает.
+// Отказ в авторизации переводит платеж в failed, неудачное списание или отмена остаются
+// назначенными и повторяются сверкой.
+func (s *PaymentService) ProcessPending(ctx context.Context, orderID uuid.UUID) error {
+	_, err := s.processPending(ctx, orderID)
+	return err
+}
+
+// processPending выполняет назначенную операцию и возвращает платеж после нее (nil, если платежа нет).
+func (s *PaymentService) processPending(ctx context.Context, orderID uuid.UUID) (*models.Payment, error) {
+	if !s.Enabled() {
+		return nil, nil
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	payment, err := s.lockPayment(ctx, tx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if payment == nil || payment.PendingOperation == nil {
+		return payment, nil
+	}
+
+	operation := *payment.PendingOperation
+	if err := s.runOperation(ctx, payment, operation); err != nil {
+		reason := err.Error()
+		payment.FailureReason = &reason
+		s.log.WithError(err).WithFields(map[string]interface{}{
+			"order_id":  orderID,
+			"operation": operation,
+		}).Warn("Payment operation failed, will retry")
+	} else {
+		payment.PendingOperation = nil
+		payment.PendingAmount = nil
+	}
+
+	if err := s.updatePayment(ctx, tx, payment); err != nil {
+		return nil, err
+	}
+	if operation == models.PaymentOperationRefund && payment.PendingOperation == nil {
+		if err := s.reverseLoyaltyPoints(ctx, tx, payment); err != nil {
+			return nil, err
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit payment operation: %w", err)
+	}
+
+	s.log.WithFields(map[string]interface{}{
+		"order_id":  orderID,
+		"operation": operation,
+		"status":    payment.Status,
+	}).Info("Payment operation processed")
+
+	return payment, nil
+}
+
+// Run периодически повторяет операции, не выполненные сразу после коммита, до отмены контекста.
+func (s *PaymentService) Run(ctx context.Context) {
+	if !s.Enabled() || s.retryInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.retryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.RetryPending(ctx); err != nil {
+				s.log.WithError(err).Error("Failed to retry pending payment operations")
+			}
+		}
+	}
+}
+
+// RetryPending выполняет операции, назначенные раньше чем retry_interval назад: свежие еще
+// выполняет сам запрос, завершивший транзакцию заказа.
+func (s *PaymentService) RetryPending(ctx context.Context) error {
+	query := `
+		SELECT order_id
+		FROM payments
+		WHERE pending_operation IS NOT NULL AND updated_at < $1
+		ORDER BY updated_at
+		LIMIT 100
+	`
+	rows, err := s.db.QueryContext(ctx, query, time.Now().Add(-s.retryInterval))
+	if err != nil {
+		return fmt.Errorf("failed to get pending payments: %w", err)
+	}
+	var orderIDs []uuid.UUID
+	for rows.Next() {
+		var orderID uuid.UUID
+		if err := rows.Scan(&orderID); err != nil {
+			rows.Close()
+			return fmt.Errorf("failed to scan pending payment: %w", err)
+		}
+		orderIDs = append(orderIDs, orderID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("failed to iterate pending payments: %w", err)
+	}
+
+	for _, orderID := range orderIDs {
+		if err := s.ProcessPending(ctx, orderID); err != nil {
+			s.log.WithError(err).WithField("order_id", orderID).Error("Failed to process pending payment operation")
+		}
+	}
+	return nil
+}
+
+// GetPayment возвращает платеж по заказу.
+func (s *PaymentService) GetPayment(ctx context.Context, orderID uuid.UUID) (*models.Payment, error) {
+	query := `
+		SELECT ` + paymentColumns + `
+		FROM payments
+		WHERE order_id = $1
+	`
+
+	payment, err := scanPayment(s.db.QueryRowContext(ctx, query, orderID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperror.NotFound("payment not found", err)
+		}
+		return nil, fmt.Errorf("failed to get payment: %w", err)
+	}
+
+	return payment, nil
+}
+
+// Refund выполняет частичный или полный возврат списанной суммы. Как и остальные операции, возврат
+// назначается в транзакции и выполняется у провайдера после коммита: если провайдер недоступен,
+// платеж возвращается с pending_operation = refund, и возврат повторит сверка.
+func (s *PaymentService) Refund(ctx context.Context, orderID uuid.UUID, req *models.RefundPaymentRequest) (*models.Payment, error) {
+	if !s.Enabled() {
+		return nil, apperror.Conflict("payments are disabled", nil)
+	}
+	if req == nil || !req.Amount.IsPositive() {
+		return nil, apperror.Validation("refund amount must be positive", nil)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	payment, err := s.lockPayment(ctx, tx, orderID)
+	if err != nil {
+		return nil, err
+	}
+	if payment == nil {
+		return nil, apperror.NotFound("payment not found", nil)
+	}
+
+	if payment.Status != models.PaymentStatusCaptured && payment.Status != models.PaymentStatusPartiallyRefunded {
+		return nil, apperror.Conflict("only captured payments can be refunded", nil)
+	}
+	if payment.PendingOperation != nil {
+		return nil, apperror.Conflict("payment operation is still in progress", nil)
+	}
+
+	amount := req.Amount.In(payment.Currency)
+	remaining := payment.CapturedAmount.Sub(payment.RefundedAmount)
+	if amount.Cmp(remaining) > 0 {
+		return nil, apperror.Validation(fmt.Sprintf("refund amount exceeds refundable balance %s", remaining), nil)
+	}
+
+	payment.PendingAmount = &amount
+	if err := s.schedule(ctx, tx, payment, models.PaymentOperationRefund); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit refund: %w", err)
+	}
+
+	s.log.WithFields(map[string]interface{}{
+		"order_id": orderID,
+		"amount":   amount,
+	}).Info("Payment refund scheduled")
+
+	processed, err := s.processPending(ctx, orderID)
+	if err != nil {
+		// Возврат уже назначен, его выполнит сверка
+		s.log.WithError(err).WithField("order_id", orderID).Error("Failed to process payment refund")
+		return payment, nil
+	}
+	return processed, nil
+}
+
+// HandleWebhook применяет уведомление провайдера. Повторная доставка того же события игнорируется.
+func (s *PaymentService) HandleWebhook(ctx context.Context, event *models.PaymentWebhookEvent) error {
+	if !s.Enabled() {
+		return apperror.Conflict("payments are disabled", nil)
+	}
+	if event == nil || event.ID == "" || event.Type == "" || event.ProviderRef == "" {
+		return apperror.Validation("id, type and provider_ref are required", nil)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	query := `
+		SELECT ` + paymentColumns + `
+		FROM payments
+		WHERE provider = $1 AND provider_ref = $2
+		FOR UPDATE
+	`
+	payment, err := scanPayment(tx.QueryRowContext(ctx, query, s.provider.Name(), event.ProviderRef))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return apperror.NotFound("payment not found", err)
+		}
+		return fmt.Errorf("failed to get payment: %w", err)
+	}
+
+	result, err := tx.ExecContext(ctx, `
+		INSERT INTO payment_webhook_events (id, payment_id, event_type, received_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (id) DO NOTHING
+	`, event.ID, payment.ID, event.Type, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to record webhook event: %w", err)
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return fmt.Errorf("failed to get rows affected: %w", err)
+	} else if rows == 0 {
+		s.log.WithField("event_id", event.ID).Info("Duplicate payment webhook ignored")
+		return nil
+	}
+
+	if err := applyWebhookEvent(payment, event); err != nil {
+		return err
+	}
+
+	if err := s.updatePayment(ctx, tx, payment); err != nil {
+		return err
+	}
+	if event.Type == PaymentEventRefunded {
+		if err := s.reverseLoyaltyPoints(ctx, tx, payment); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit webhook: %w", err)
+	}
+
+	s.log.WithFields(map[string]interface{}{
+		"event_id":   event.ID,
+		"event_type": event.Type,
+		"order_id":   payment.OrderID,
+		"status":     payment.Status,
+	}).Info("Payment webhook processed")
+
+	return nil
+}
+
+// schedule назначает платежу операцию, которую ProcessPending выполнит после коммита.
+func (s *PaymentService) schedule(ctx context.Context, tx *sql.Tx, payment *models.Payment, operation models.PaymentOperation) error {
+	payment.PendingOperation = &operation
+	return s.updatePayment(ctx, tx, payment)
+}
+
+// runOperation вызывает провайдера и переводит платеж в новое состояние. Ошибка означает,
+// что операцию нужно повторить.
+func (s *PaymentService) runOperation(ctx context.Context, payment *models.Payment, operation models.PaymentOperation) error {
+	switch operation {
+	case models.PaymentOperationAuthorize:
+		if payment.Status != models.PaymentStatusPending {
+			return nil
+		}
+		if !s.authorize(ctx, payment) {
+			return nil
+		}
+	case models.PaymentOperationCapture:
+		// Заказ доставлен до авторизации: сначала авторизуется, затем сразу списывается
+		if payment.Status == models.PaymentStatusPending && !s.authorize(ctx, payment) {
+			return nil
+		}
+		if payment.Status != models.PaymentStatusAuthorized {
+			return nil
+		}
+		if err := s.provider.Capture(ctx, *payment.ProviderRef, payment.Amount); err != nil {
+			return err
+		}
+		payment.Status = models.PaymentStatusCaptured
+		payment.CapturedAmount = payment.Amount
+	case models.PaymentOperationRelease:
+		switch payment.Status {
+		case models.PaymentStatusPending:
+			// Заказ отменен до авторизации: у провайдера отменять нечего
+			payment.Status = models.PaymentStatusVoided
+		case models.PaymentStatusAuthorized:
+			if err := s.provider.Void(ctx, *payment.ProviderRef); err != nil {
+				return err
+			}
+			payment.Status = models.PaymentStatusVoided
+		case models.PaymentStatusCaptured, models.PaymentStatusPartiallyRefunded:
+			remaining := payment.CapturedAmount.Sub(payment.RefundedAmount)
+			if err := s.provider.Refund(ctx, *payment.ProviderRef, remaining); err != nil {
+				return err
+			}
+			payment.RefundedAmount = payment.CapturedAmount
+			payment.Status = models.PaymentStatusRefunded
+		}
+	case models.PaymentOperationRefund:
+		if payment.PendingAmount == nil || (payment.Status != models.PaymentStatusCaptured && payment.Status != models.PaymentStatusPartiallyRefunded) {
+			return nil
+		}
+		// Остаток мог уменьшиться по вебхуку провайдера: больше остатка не возвращается
+		amount := money.Min(*payment.PendingAmount, payment.CapturedAmount.Sub(payment.RefundedAmount))
+		if amount.IsPositive() {
+			if err := s.provider.Refund(ctx, *payment.ProviderRef, amount); err != nil {
+				return err
+			}
+			payment.RefundedAmount = payment.RefundedAmount.Add(amount)
+		}
+		payment.Status = refundStatus(payment)
+	}
+	payment.FailureReason = nil
+	return nil
+}
+
+// authorize холдирует сумму платежа у провайдера. Ключ идемпотентности привязан к платежу, поэтому
+// повтор после сбоя сохранения не холдирует сумму второй раз. Отказ не повторяется: платеж
+// переводится в failed, и authorize возвращает false.
+func (s *PaymentService) authorize(ctx context.Context, payment *models.Payment) bool {
+	ref, err := s.provider.Authorize(ctx, payments.AuthorizeRequest{
+		OrderID:        payment.OrderID,
+		Amount:         payment.Amount,
+		IdempotencyKey: payment.ID.String() + ":" + string(models.PaymentOperationAuthorize),
+	})
+	if err != nil {
+		reason := err.Error()
+		payment.Status = models.PaymentStatusFailed
+		payment.FailureReason = &reason
+		s.log.WithError(err).WithField("order_id", payment.OrderID).Warn("Payment authorization failed")
+		return false
+	}
+	payment.ProviderRef = &ref
+	payment.Status = models.PaymentStatusAuthorized
+	payment.FailureReason = nil
+	return true
+}
+
+// reverseLoyaltyPoints отзывает баллы за заказ пропорционально возвращенной сумме.
+func (s *PaymentService) reverseLoyaltyPoints(ctx context.Context, tx *sql.Tx, payment *models.Payment) error {
+	if s.loyalty == nil {
+		return nil
+	}
+	return s.loyalty.ReverseForRefundWithTx(ctx, tx, payment.OrderID, payment.RefundedAmount, payment.CapturedAmount)
+}
+
+// lockPayment блокирует платеж заказа; возвращает nil, если платежа нет.
+func (s *PaymentService) lockPayment(ctx context.Context, tx *sql.Tx, orderID uuid.UUID) (*models.Payment, error) {
+	query := `
+		SELECT ` + paymentColumns + `
+		FROM payments
+		WHERE order_id = $1
+		FOR UPDATE
+	`
+
+	payment, err := scanPayment(tx.QueryRowContext(ctx, query, orderID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to lock payment: %w", err)
+	}
+
+	return payment, nil
+}
+
+func (s *PaymentService) updatePayment(ctx context.Context, tx *sql.Tx, payment *models.Payment) error {
+	payment.UpdatedAt = time.Now()
+	query := `
+		UPDATE payments
+		SET status = $1, provider_ref = $2, captured_amount = $3, refunded_amount = $4, failure_reason = $5, pending_operation = $6,
+			pending_amount = $7, updated_at = $8
+		WHERE id = $9
+	`
+	if _, err := tx.ExecContext(ctx, query, payment.Status, payment.ProviderRef, payment.CapturedAmount, payment.RefundedAmount, payment.FailureReason,
+		payment.PendingOperation, payment.PendingAmount, payment.UpdatedAt, payment.ID); err != nil {
+		return fmt.Errorf("failed to update payment: %w", err)
+	}
+	return nil
+}
+
+// applyWebhookEvent переводит платеж в новое состояние; устаревшие события не откатывают статус назад.
+func applyWebhookEvent(payment *models.Payment, event *models.PaymentWebhookEvent) error {
+	switch event.Type {
+	case PaymentEventAuthorized:
+		if payment.Status == models.PaymentStatusFailed {
+			payment.Status = models.PaymentStatusAuthorized
+			payment.FailureReason = nil
+		}
+	case PaymentEventCaptured:
+		if payment.Status == models.PaymentStatusAuthorized {
+			payment.Status = models.PaymentStatusCaptured
+			payment.CapturedAmount = payment.Amount
+			if captured := event.Amount.In(payment.Currency); captured.IsPositive() && captured.Cmp(payment.Amount) < 0 {
+				payment.CapturedAmount = captured
+			}
+		}
+	case PaymentEventVoided:
+		if payment.Status == models.PaymentStatusAuthorized {
+			payment.Status = models.PaymentStatusVoided
+		}
+	case PaymentEventRefunded:
+		// amount — суммарно возвращенная сумма по платежу
+		refunded := money.Min(event.Amount.In(payment.Currency), payment.CapturedAmount)
+		if refunded.Cmp(payment.RefundedAmount) > 0 {
+			payment.RefundedAmount = refunded
+			payment.Status = refundStatus(payment)
+		}
+	case PaymentEventFailed:
+		if payment.Status == models.PaymentStatusAuthorized {
+			payment.Status = models.PaymentStatusFailed
+			payment.FailureReason = event.Reason
+		}
+	default:
+		return apperror.Validation("unsupported webhook event type", nil)
+	}
+	return nil
+}
+
+func refundStatus(payment *models.Payment) models.PaymentStatus {
+	if payment.RefundedAmount.Cmp(payment.CapturedAmount) >= 0 {
+		return models.PaymentStatusRefunded
+	}
+	return models.PaymentStatusPartiallyRefunded
+}
+
+// paymentColumns — колонки payments в порядке scanPayment.
+const paymentColumns = `id, order_id, provider, provider_ref, status, amount, captured_amount, refunded_amount, currency, failure_reason, pending_operation, pending_amount, created_at, updated_at`
+
+func scanPayment(row *sql.Row) (*models.Payment, error) {
+	p := &models.Payment{}
+	if err := row.Scan(&p.ID, &p.OrderID, &p.Provider, &p.ProviderRef, &p.Status, &p.Amount,
+		&p.CapturedAmount, &p.RefundedAmount, &p.Currency, &p.FailureReason, &p.PendingOperation, &p.PendingAmount, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		return nil, err
+	}
+	p.Amount = p.Amount.In(p.Currency)
+	p.CapturedAmount = p.CapturedAmount.In(p.Currency)
+	p.RefundedAmount = p.RefundedAmount.In(p.Currency)
+	if p.PendingAmount != nil {
+		v := p.PendingAmount.In(p.Currency)
+		p.PendingAmount = &v
+	}
+	return p, nil
+}
@@ -0,0 +1,561 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/config"
+	"delivery-system/internal/models"
+	"delivery-system/internal/money"
+	"delivery-system/internal/payments"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+var paymentRowColumns = []string{"id", "order_id", "provider", "provider_ref", "status", "amount", "captured_amount", "refunded_amount", "currency", "failure_reason", "pending_operation", "pending_amount", "created_at", "updated_at"}
+
+func paymentRow(orderID uuid.UUID, ref string, status models.PaymentStatus, amount, captured, refunded float64) *sqlmock.Rows {
+	return sqlmock.NewRows(paymentRowColumns).
+		AddRow(uuid.New(), orderID, "fake", ref, status, amount, captured, refunded, "RUB", nil, nil, nil, time.Now(), time.Now())
+}
+
+// pendingPaymentRow возвращает платеж с назначенной, но еще не выполненной операцией.
+func pendingPaymentRow(orderID uuid.UUID, ref *string, status models.PaymentStatus, operation models.PaymentOperation, amount, captured float64) *sqlmock.Rows {
+	return sqlmock.NewRows(paymentRowColumns).
+		AddRow(uuid.New(), orderID, "fake", ref, status, amount, captured, 0, "RUB", nil, string(operation), nil, time.Now(), time.Now())
+}
+
+// refundPaymentRow возвращает списанный платеж с назначенным возвратом на сумму pending.
+func refundPaymentRow(orderID uuid.UUID, ref string, captured, refunded, pending float64) *sqlmock.Rows {
+	return sqlmock.NewRows(paymentRowColumns).
+		AddRow(uuid.New(), orderID, "fake", ref, models.PaymentStatusCaptured, captured, captured, refunded, "RUB", nil, string(models.PaymentOperationRefund), pending, time.Now(), time.Now())
+}
+
+// countingProvider считает вызовы провайдера поверх встроенного.
+type countingProvider struct {
+	*payments.FakeProvider
+	authorizes, captures, voids, refunds int
+}
+
+func (p *countingProvider) Authorize(ctx context.Context, req payments.AuthorizeRequest) (string, error) {
+	p.authorizes++
+	return p.FakeProvider.Authorize(ctx, req)
+}
+
+func (p *countingProvider) Capture(ctx context.Context, ref string, amount money.Money) error {
+	p.captures++
+	return p.FakeProvider.Capture(ctx, ref, amount)
+}
+
+func (p *countingProvider) Void(ctx context.Context, ref string) error {
+	p.voids++
+	return p.FakeProvider.Void(ctx, ref)
+}
+
+func (p *countingProvider) Refund(ctx context.Context, ref string, amount money.Money) error {
+	p.refunds++
+	return p.FakeProvider.Refund(ctx, ref, amount)
+}
+
+func TestPaymentService_CreateWithTx(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	provider := &countingProvider{FakeProvider: payments.NewFakeProvider()}
+	service := NewPaymentService(db, provider, newTestLogger(), &config.PaymentsConfig{}, nil)
+	orderID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO payments").
+		WithArgs(sqlmock.AnyArg(), orderID, "fake", nil, models.PaymentStatusPending, rub(250), rub(0), rub(0), "RUB", nil, "authorize", sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectRollback()
+
+	// Провайдер не вызывается в транзакции заказа: откат не оставляет авторизацию без платежа
+	tx, _ := db.BeginTx(context.Background(), nil)
+	payment, err := service.CreateWithTx(context.Background(), tx, orderID, rub(250))
+	if err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+	_ = tx.Rollback()
+
+	if payment.Status != models.PaymentStatusPending || provider.authorizes != 0 {
+		t.Fatalf("expected pending payment without provider call, got %+v (%d authorizations)", payment, provider.authorizes)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestPaymentService_ProcessPending_Authorize(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewPaymentService(db, payments.NewFakeProvider(), newTestLogger(), &config.PaymentsConfig{}, nil)
+	orderID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, order_id, provider, provider_ref, status").
+		WithArgs(orderID).
+		WillReturnRows(pendingPaymentRow(orderID, nil, models.PaymentStatusPending, models.PaymentOperationAuthorize, 250, 0))
+	mock.ExpectExec("UPDATE payments").
+		WithArgs(models.PaymentStatusAuthorized, sqlmock.AnyArg(), rub(0), rub(0), nil, nil, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if err := service.ProcessPending(context.Background(), orderID); err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestPaymentService_ProcessPending_AuthorizeRetryIsIdempotent(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	provider := payments.NewFakeProvider()
+	service := NewPaymentService(db, provider, newTestLogger(), &config.PaymentsConfig{}, nil)
+	orderID := uuid.New()
+	paymentID := uuid.New()
+
+	// Прошлая попытка авторизовала платеж, но не сохранила результат: повтор получает ту же ссылку
+	ref, _ := provider.Authorize(context.Background(), payments.AuthorizeRequest{
+		OrderID:        orderID,
+		Amount:         rub(250),
+		IdempotencyKey: paymentID.String() + ":authorize",
+	})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, order_id, provider, provider_ref, status").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows(paymentRowColumns).
+			AddRow(paymentID, orderID, "fake", nil, models.PaymentStatusPending, 250.0, 0, 0, "RUB", nil, "authorize", nil, time.Now(), time.Now()))
+	mock.ExpectExec("UPDATE payments").
+		WithArgs(models.PaymentStatusAuthorized, ref, rub(0), rub(0), nil, nil, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if err := service.ProcessPending(context.Background(), orderID); err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestPaymentService_ProcessPending_AuthorizeDeclined(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	provider := payments.NewFakeProvider()
+	provider.DeclineAuthorize = true
+	service := NewPaymentService(db, provider, newTestLogger(), &config.PaymentsConfig{}, nil)
+	orderID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, order_id, provider, provider_ref, status").
+		WithArgs(orderID).
+		WillReturnRows(pendingPaymentRow(orderID, nil, models.PaymentStatusPending, models.PaymentOperationAuthorize, 100, 0))
+	mock.ExpectExec("UPDATE payments").
+		WithArgs(models.PaymentStatusFailed, nil, rub(0), rub(0), payments.ErrDeclined.Error(), nil, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if err := service.ProcessPending(context.Background(), orderID); err != nil {
+		t.Fatalf("declined authorization must not fail, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestPaymentService_Disabled(t *testing.T) {
+	service := NewPaymentService(nil, nil, newTestLogger(), &config.PaymentsConfig{GateTransitions: true}, nil)
+	if service.Enabled() {
+		t.Fatalf("expected payments to be disabled without provider")
+	}
+	if _, err := service.OnOrderStatusChange(context.Background(), nil, uuid.New(), defaultTransition(t, models.OrderStatusCreated, models.OrderStatusAccepted)); err != nil {
+		t.Fatalf("expected no gating when payments are disabled, got %v", err)
+	}
+
+	var nilService *PaymentService
+	if nilService.Enabled() {
+		t.Fatalf("expected nil service to be disabled")
+	}
+}
+
+func TestPaymentService_OnOrderStatusChange_Gate(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewPaymentService(db, payments.NewFakeProvider(), newTestLogger(), &config.PaymentsConfig{GateTransitions: true}, nil)
+	orderID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, order_id, provider, provider_ref, status").
+		WithArgs(orderID).
+		WillReturnRows(paymentRow(orderID, "ref", models.PaymentStatusFailed, 100, 0, 0))
+	mock.ExpectRollback()
+
+	tx, _ := db.BeginTx(context.Background(), nil)
+	_, err := service.OnOrderStatusChange(context.Background(), tx, orderID, defaultTransition(t, models.OrderStatusCreated, models.OrderStatusAccepted))
+	_ = tx.Rollback()
+	if !apperror.Is(err, apperror.KindConflict) {
+		t.Fatalf("expected conflict for failed payment, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestPaymentService_OnOrderStatusChange_CaptureOnDelivered(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	provider := &countingProvider{FakeProvider: payments.NewFakeProvider()}
+	orderID := uuid.New()
+	ref, _ := provider.Authorize(context.Background(), payments.AuthorizeRequest{OrderID: orderID, Amount: rub(100)})
+	service := NewPaymentService(db, provider, newTestLogger(), &config.PaymentsConfig{GateTransitions: true}, nil)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, order_id, provider, provider_ref, status").
+		WithArgs(orderID).
+		WillReturnRows(paymentRow(orderID, ref, models.PaymentStatusAuthorized, 100, 0, 0))
+	mock.ExpectExec("UPDATE payments").
+		WithArgs(models.PaymentStatusAuthorized, ref, rub(0), rub(0), nil, "capture", nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	tx, _ := db.BeginTx(context.Background(), nil)
+	scheduled, err := service.OnOrderStatusChange(context.Background(), tx, orderID, defaultTransition(t, models.OrderStatusInDelivery, models.OrderStatusDelivered))
+	if err != nil || !scheduled {
+		t.Fatalf("expected scheduled capture, got %v %v", scheduled, err)
+	}
+	_ = tx.Commit()
+	if provider.captures != 0 {
+		t.Fatalf("capture must wait for the order transaction to commit")
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, order_id, provider, provider_ref, status").
+		WithArgs(orderID).
+		WillReturnRows(pendingPaymentRow(orderID, &ref, models.PaymentStatusAuthorized, models.PaymentOperationCapture, 100, 0))
+	mock.ExpectExec("UPDATE payments").
+		WithArgs(models.PaymentStatusCaptured, ref, rub(100), rub(0), nil, nil, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if err := service.ProcessPending(context.Background(), orderID); err != nil {
+		t.Fatalf("expected capture, got %v", err)
+	}
+	if provider.captures != 1 {
+		t.Fatalf("expected one capture, got %d", provider.captures)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestPaymentService_OnOrderStatusChange_DeliveredBeforeAuthorize(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	provider := &countingProvider{FakeProvider: payments.NewFakeProvider()}
+	service := NewPaymentService(db, provider, newTestLogger(), &config.PaymentsConfig{}, nil)
+	orderID := uuid.New()
+
+	// Без gate заказ доставлен до авторизации: списание назначается вместо авторизации
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, order_id, provider, provider_ref, status").
+		WithArgs(orderID).
+		WillReturnRows(pendingPaymentRow(orderID, nil, models.PaymentStatusPending, models.PaymentOperationAuthorize, 100, 0))
+	mock.ExpectExec("UPDATE payments").
+		WithArgs(models.PaymentStatusPending, nil, rub(0), rub(0), nil, "capture", nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	tx, _ := db.BeginTx(context.Background(), nil)
+	scheduled, err := service.OnOrderStatusChange(context.Background(), tx, orderID, defaultTransition(t, models.OrderStatusInDelivery, models.OrderStatusDelivered))
+	if err != nil || !scheduled {
+		t.Fatalf("expected scheduled capture, got %v %v", scheduled, err)
+	}
+	_ = tx.Commit()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, order_id, provider, provider_ref, status").
+		WithArgs(orderID).
+		WillReturnRows(pendingPaymentRow(orderID, nil, models.PaymentStatusPending, models.PaymentOperationCapture, 100, 0))
+	mock.ExpectExec("UPDATE payments").
+		WithArgs(models.PaymentStatusCaptured, sqlmock.AnyArg(), rub(100), rub(0), nil, nil, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if err := service.ProcessPending(context.Background(), orderID); err != nil {
+		t.Fatalf("expected capture, got %v", err)
+	}
+	if provider.authorizes != 1 || provider.captures != 1 {
+		t.Fatalf("expected authorize and capture, got %d and %d", provider.authorizes, provider.captures)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestPaymentService_ProcessPending_CaptureDeclined(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	provider := payments.NewFakeProvider()
+	orderID := uuid.New()
+	ref, _ := provider.Authorize(context.Background(), payments.AuthorizeRequest{OrderID: orderID, Amount: rub(100)})
+	provider.DeclineCapture = true
+	service := NewPaymentService(db, provider, newTestLogger(), &config.PaymentsConfig{GateTransitions: true}, nil)
+
+	// Неудачное списание остается назначенным, чтобы его повторила сверка
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, order_id, provider, provider_ref, status").
+		WithArgs(orderID).
+		WillReturnRows(pendingPaymentRow(orderID, &ref, models.PaymentStatusAuthorized, models.PaymentOperationCapture, 100, 0))
+	mock.ExpectExec("UPDATE payments").
+		WithArgs(models.PaymentStatusAuthorized, ref, rub(0), rub(0), payments.ErrDeclined.Error(), "capture", nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if err := service.ProcessPending(context.Background(), orderID); err != nil {
+		t.Fatalf("expected failure to be recorded, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestPaymentService_OnOrderStatusChange_VoidOnCancel(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	provider := payments.NewFakeProvider()
+	orderID := uuid.New()
+	ref, _ := provider.Authorize(context.Background(), payments.AuthorizeRequest{OrderID: orderID, Amount: rub(100)})
+	service := NewPaymentService(db, provider, newTestLogger(), &config.PaymentsConfig{}, nil)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, order_id, provider, provider_ref, status").
+		WithArgs(orderID).
+		WillReturnRows(paymentRow(orderID, ref, models.PaymentStatusAuthorized, 100, 0, 0))
+	mock.ExpectExec("UPDATE payments").
+		WithArgs(models.PaymentStatusAuthorized, ref, rub(0), rub(0), nil, "release", nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	tx, _ := db.BeginTx(context.Background(), nil)
+	if _, err := service.OnOrderStatusChange(context.Background(), tx, orderID, defaultTransition(t, models.OrderStatusReady, models.OrderStatusCancelled)); err != nil {
+		t.Fatalf("expected scheduled void, got %v", err)
+	}
+	_ = tx.Commit()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, order_id, provider, provider_ref, status").
+		WithArgs(orderID).
+		WillReturnRows(pendingPaymentRow(orderID, &ref, models.PaymentStatusAuthorized, models.PaymentOperationRelease, 100, 0))
+	mock.ExpectExec("UPDATE payments").
+		WithArgs(models.PaymentStatusVoided, ref, rub(0), rub(0), nil, nil, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if err := service.ProcessPending(context.Background(), orderID); err != nil {
+		t.Fatalf("expected void, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestPaymentService_Refund(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	provider := &countingProvider{FakeProvider: payments.NewFakeProvider()}
+	orderID := uuid.New()
+	ref, _ := provider.Authorize(context.Background(), payments.AuthorizeRequest{OrderID: orderID, Amount: rub(100)})
+	_ = provider.Capture(context.Background(), ref, rub(100))
+	service := NewPaymentService(db, provider, newTestLogger(), &config.PaymentsConfig{}, nil)
+
+	// Возврат назначается в транзакции; если она не зафиксирована, провайдер не вызывается
+	expectRefundScheduled := func() {
+		mock.ExpectBegin()
+		mock.ExpectQuery("SELECT id, order_id, provider, provider_ref, status").
+			WithArgs(orderID).
+			WillReturnRows(paymentRow(orderID, ref, models.PaymentStatusCaptured, 100, 100, 0))
+		mock.ExpectExec("UPDATE payments").
+			WithArgs(models.PaymentStatusCaptured, ref, rub(100), rub(0), nil, "refund", rub(30), sqlmock.AnyArg(), sqlmock.AnyArg()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+	}
+	expectRefundScheduled()
+	mock.ExpectCommit().WillReturnError(errors.New("connection lost"))
+
+	if _, err := service.Refund(context.Background(), orderID, &models.RefundPaymentRequest{Amount: rub(30)}); err == nil {
+		t.Fatalf("expected commit error")
+	}
+	if provider.refunds != 0 {
+		t.Fatalf("refund must wait for the payment transaction to commit")
+	}
+
+	expectRefundScheduled()
+	mock.ExpectCommit()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, order_id, provider, provider_ref, status").
+		WithArgs(orderID).
+		WillReturnRows(refundPaymentRow(orderID, ref, 100, 0, 30))
+	mock.ExpectExec("UPDATE payments").
+		WithArgs(models.PaymentStatusPartiallyRefunded, ref, rub(100), rub(30), nil, nil, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	payment, err := service.Refund(context.Background(), orderID, &models.RefundPaymentRequest{Amount: rub(30)})
+	if err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+	if payment.RefundedAmount != rub(30) || payment.Status != models.PaymentStatusPartiallyRefunded || payment.PendingOperation != nil {
+		t.Fatalf("unexpected payment: %+v", payment)
+	}
+	if provider.refunds != 1 {
+		t.Fatalf("expected one refund, got %d", provider.refunds)
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, order_id, provider, provider_ref, status").
+		WithArgs(orderID).
+		WillReturnRows(paymentRow(orderID, ref, models.PaymentStatusPartiallyRefunded, 100, 100, 30))
+	mock.ExpectRollback()
+
+	if _, err := service.Refund(context.Background(), orderID, &models.RefundPaymentRequest{Amount: rub(80)}); !apperror.Is(err, apperror.KindValidation) {
+		t.Fatalf("expected validation error for over-refund, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestPaymentService_ProcessPending_RefundFails(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewPaymentService(db, payments.NewFakeProvider(), newTestLogger(), &config.PaymentsConfig{}, nil)
+	orderID := uuid.New()
+
+	// Провайдер не знает платеж: возврат остается назначенным с суммой, его повторит сверка
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, order_id, provider, provider_ref, status").
+		WithArgs(orderID).
+		WillReturnRows(refundPaymentRow(orderID, "missing", 100, 0, 40))
+	mock.ExpectExec("UPDATE payments").
+		WithArgs(models.PaymentStatusCaptured, "missing", rub(100), rub(0), payments.ErrUnknownPayment.Error(), "refund", rub(40), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if err := service.ProcessPending(context.Background(), orderID); err != nil {
+		t.Fatalf("expected failure to be recorded, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestPaymentService_HandleWebhook(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewPaymentService(db, payments.NewFakeProvider(), newTestLogger(), &config.PaymentsConfig{}, nil)
+	orderID := uuid.New()
+	event := &models.PaymentWebhookEvent{ID: "evt_1", Type: PaymentEventCaptured, ProviderRef: "ref_1", Amount: rub(100)}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, order_id, provider, provider_ref, status").
+		WithArgs("fake", "ref_1").
+		WillReturnRows(paymentRow(orderID, "ref_1", models.PaymentStatusAuthorized, 100, 0, 0))
+	mock.ExpectExec("INSERT INTO payment_webhook_events").
+		WithArgs("evt_1", sqlmock.AnyArg(), PaymentEventCaptured, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE payments").
+		WithArgs(models.PaymentStatusCaptured, "ref_1", rub(100), rub(0), nil, nil, nil, sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	if err := service.HandleWebhook(context.Background(), event); err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+
+	// Повторная доставка события не меняет платеж
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, order_id, provider, provider_ref, status").
+		WithArgs("fake", "ref_1").
+		WillReturnRows(paymentRow(orderID, "ref_1", models.PaymentStatusCaptured, 100, 100, 0))
+	mock.ExpectExec("INSERT INTO payment_webhook_events").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectRollback()
+
+	if err := service.HandleWebhook(context.Background(), event); err != nil {
+		t.Fatalf("expected duplicate to be ignored, got %v", err)
+	}
+
+	if err := service.HandleWebhook(context.Background(), &models.PaymentWebhookEvent{ID: "evt_2"}); !apperror.Is(err, apperror.KindValidation) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestApplyWebhookEvent_DoesNotRegress(t *testing.T) {
+	payment := &models.Payment{Status: models.PaymentStatusCaptured, Amount: rub(100), CapturedAmount: rub(100)}
+
+	if err := applyWebhookEvent(payment, &models.PaymentWebhookEvent{Type: PaymentEventVoided}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if payment.Status != models.PaymentStatusCaptured {
+		t.Fatalf("late void must not change captured payment, got %s", payment.Status)
+	}
+
+	if err := applyWebhookEvent(payment, &models.PaymentWebhookEvent{Type: PaymentEventRefunded, Amount: rub(100)}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if payment.Status != models.PaymentStatusRefunded {
+		t.Fatalf("expected refunded, got %s", payment.Status)
+	}
+
+	if err := applyWebhookEvent(payment, &models.PaymentWebhookEvent{Type: "payment.unknown"}); !apperror.Is(err, apperror.KindValidation) {
+		t.Fatalf("expected validation error for unknown type, got %v", err)
+	}
+}
+
+// defaultTransition возвращает переход из машины состояний по умолчанию.
+func defaultTransition(t *testing.T, from, to models.OrderStatus) models.OrderTransition {
+	t.Helper()
+	flow, err := NewOrderFlow(DefaultOrderTransitions(), 0)
+	if err != nil {
+		t.Fatalf("default flow: %v", err)
+	}
+	transition, err := flow.Transition(context.Background(), from, to)
+	if err != nil {
+		t.Fatalf("transition %s -> %s: %v", from, to, err)
+	}
+	return transition
+}
@@ -1,34 +1,281 @@
 package services
 
-import "math"
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
 
-// PricingService рассчитывает стоимость доставки по расстоянию.
+	"delivery-system/internal/models"
+	"delivery-system/internal/money"
+)
+
+// RegionTariff — регион с тарифом, переведенным в денежные суммы его валюты.
+type RegionTariff struct {
+	Region   models.Region
+	BaseFare money.Money
+	PerKm    money.Money
+	MinFare  money.Money
+	Location *time.Location
+	// Payout — правила выплат курьерам региона; nil — действуют правила по умолчанию
+	Payout *PayoutRules
+}
+
+// Code возвращает код региона.
+func (t *RegionTariff) Code() string { return t.Region.Code }
+
+// Currency возвращает валюту региона.
+func (t *RegionTariff) Currency() string { return t.Region.Currency }
+
+// CalculateCost считает цену с учётом базовой ставки, тарифа за км и минимальной цены.
+// Стоимость километров округляется до копейки один раз, после умножения.
+func (t *RegionTariff) CalculateCost(distanceKm float64) money.Money {
+	return t.calculateCost(distanceKm, 1)
+}
+
+// CalculateVehicleCost считает цену по тарифу региона с надбавкой за транспорт:
+// базовая ставка, тариф за км и минимальная цена умножаются на коэффициент транспорта.
+func (t *RegionTariff) CalculateVehicleCost(vehicle models.VehicleType, distanceKm float64) money.Money {
+	multiplier, ok := t.Region.VehicleMultipliers[vehicle]
+	if !ok {
+		multiplier = 1
+	}
+	return t.calculateCost(distanceKm, multiplier)
+}
+
+func (t *RegionTariff) calculateCost(distanceKm, multiplier float64) money.Money {
+	if distanceKm < 0 {
+		distanceKm = 0
+	}
+
+	cost := t.BaseFare.MulFloat(multiplier).Add(t.PerKm.MulFloat(distanceKm * multiplier))
+	return money.Max(cost, t.MinFare.MulFloat(multiplier))
+}
+
+// PricingService рассчитывает стоимость доставки по тарифу региона.
 type PricingService struct {
-	BaseFare float64
-	PerKm    float64
-	MinFare  float64
+	defaultTariff *RegionTariff
+	regions       []*RegionTariff
 }
 
-// NewPricingService создаёт сервис с тарифами.
-func NewPricingService(baseFare, perKm, minFare float64) *PricingService {
-	return &PricingService{
+// NewPricingService создаёт сервис с единственным регионом по умолчанию в указанной валюте.
+func NewPricingService(baseFare, perKm, minFare float64, currency string) *PricingService {
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
+	region := models.Region{
+		Code:     models.DefaultRegionCode,
+		Currency: currency,
 		BaseFare: baseFare,
 		PerKm:    perKm,
 		MinFare:  minFare,
 	}
+	return &PricingService{defaultTariff: newRegionTariff(region, time.UTC)}
 }
 
-// CalculateCost считает цену с учётом базовой ставки, тарифа за км и минимальной цены.
-func (s *PricingService) CalculateCost(distanceKm float64) float64 {
-	if distanceKm < 0 {
-		distanceKm = 0
+// NewRegionalPricingService создаёт сервис с регионом по умолчанию и дополнительными регионами.
+// Регион заказа определяется по точке забора: первый регион, в область которого она попала.
+func NewRegionalPricingService(defaultRegion models.Region, regions []models.Region) (*PricingService, error) {
+	if defaultRegion.Code == "" {
+		defaultRegion.Code = models.DefaultRegionCode
+	}
+	if defaultRegion.Currency == "" {
+		defaultRegion.Currency = money.DefaultCurrency
+	}
+	defaultRegion.Bounds = nil
+
+	defaultTariff, err := buildRegionTariff(defaultRegion)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &PricingService{defaultTariff: defaultTariff}
+	seen := map[string]bool{defaultRegion.Code: true}
+	for _, region := range regions {
+		if region.Bounds == nil {
+			return nil, fmt.Errorf("region %q: bounds are required", region.Code)
+		}
+		if seen[region.Code] {
+			return nil, fmt.Errorf("region %q: duplicate code", region.Code)
+		}
+		tariff, err := buildRegionTariff(region)
+		if err != nil {
+			return nil, err
+		}
+		// Правила выплат по умолчанию заданы в валюте региона по умолчанию
+		if tariff.Payout == nil && tariff.Currency() != defaultTariff.Currency() {
+			return nil, fmt.Errorf("region %q: payout is required for currency %s", tariff.Code(), tariff.Currency())
+		}
+		seen[region.Code] = true
+		s.regions = append(s.regions, tariff)
+	}
+
+	return s, nil
+}
+
+// LoadRegions читает список регионов из JSON-файла.
+func LoadRegions(path string) ([]models.Region, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read regions file: %w", err)
 	}
 
-	cost := s.BaseFare + (distanceKm * s.PerKm)
-	if cost < s.MinFare {
-		cost = s.MinFare
+	var regions []models.Region
+	if err := json.Unmarshal(data, &regions); err != nil {
+		return nil, fmt.Errorf("failed to parse regions file: %w", err)
 	}
+	return regions, nil
+}
+
+// DefaultTariff возвращает тариф региона по умолчанию.
+func (s *PricingService) DefaultTariff() *RegionTariff {
+	return s.defaultTariff
+}
 
-	// Округляем до 2 знаков
-	return math.Round(cost*100) / 100
+// CartQuote — стоимость корзины по тарифу региона точки забора.
+type CartQuote struct {
+	Tariff       *RegionTariff
+	ItemsTotal   money.Money
+	DeliveryCost money.Money
+	// VehicleType — наименьший транспорт, который подходит по габаритам и расстоянию; доставка считается по его тарифу
+	VehicleType models.VehicleType
+	WeightKg    float64
+	VolumeL     float64
+}
+
+// QuoteCart считает сумму товаров и стоимость доставки в валюте региона точки забора.
+func (s *PricingService) QuoteCart(items []models.CreateOrderItemRequest, pickupLat, pickupLon, deliveryLat, deliveryLon float64) CartQuote {
+	tariff := s.ResolveTariff(pickupLat, pickupLon)
+	currency := tariff.Currency()
+
+	itemsTotal := money.Zero(currency)
+	var weightKg, volumeL float64
+	for _, item := range items {
+		itemsTotal = itemsTotal.Add(item.Price.In(currency).Mul(int64(item.Quantity)))
+		weightKg += item.WeightKg * float64(item.Quantity)
+		volumeL += item.VolumeL * float64(item.Quantity)
+	}
+
+	distanceKm := calculateDistance(pickupLat, pickupLon, deliveryLat, deliveryLon)
+	vehicle := models.VehicleForLoad(weightKg, volumeL, distanceKm)
+	return CartQuote{
+		Tariff:       tariff,
+		ItemsTotal:   itemsTotal,
+		DeliveryCost: tariff.CalculateVehicleCost(vehicle, distanceKm),
+		VehicleType:  vehicle,
+		WeightKg:     weightKg,
+		VolumeL:      volumeL,
+	}
+}
+
+// QuoteReturn считает стоимость обратного плеча от адреса доставки до точки забора
+// по тарифу региона заказа и его транспорта. Если регион больше не настроен, он определяется по точке забора.
+func (s *PricingService) QuoteReturn(regionCode string, vehicle models.VehicleType, deliveryLat, deliveryLon, pickupLat, pickupLon float64) money.Money {
+	tariff, ok := s.Tariff(regionCode)
+	if !ok {
+		tariff = s.ResolveTariff(pickupLat, pickupLon)
+	}
+	return tariff.CalculateVehicleCost(vehicle, calculateDistance(deliveryLat, deliveryLon, pickupLat, pickupLon))
+}
+
+// ResolveTariff определяет регион по координатам точки забора.
+// Если точка не попала ни в один регион, используется регион по умолчанию.
+func (s *PricingService) ResolveTariff(lat, lon float64) *RegionTariff {
+	for _, tariff := range s.regions {
+		if tariff.Region.Bounds.Contains(lat, lon) {
+			return tariff
+		}
+	}
+	return s.defaultTariff
+}
+
+// Tariff возвращает тариф региона по коду.
+func (s *PricingService) Tariff(code string) (*RegionTariff, bool) {
+	if code == s.defaultTariff.Code() {
+		return s.defaultTariff, true
+	}
+	for _, tariff := range s.regions {
+		if tariff.Code() == code {
+			return tariff, true
+		}
+	}
+	return nil, false
+}
+
+// Regions возвращает все регионы, начиная с региона по умолчанию.
+func (s *PricingService) Regions() []models.Region {
+	result := make([]models.Region, 0, len(s.regions)+1)
+	result = append(result, s.defaultTariff.Region)
+	for _, tariff := range s.regions {
+		result = append(result, tariff.Region)
+	}
+	return result
+}
+
+// Currency возвращает валюту региона по умолчанию.
+func (s *PricingService) Currency() string {
+	return s.defaultTariff.Currency()
+}
+
+// CalculateCost считает цену доставки по тарифу региона по умолчанию.
+func (s *PricingService) CalculateCost(distanceKm float64) money.Money {
+	return s.defaultTariff.CalculateCost(distanceKm)
+}
+
+func buildRegionTariff(region models.Region) (*RegionTariff, error) {
+	region.Code = strings.TrimSpace(region.Code)
+	region.Currency = strings.ToUpper(strings.TrimSpace(region.Currency))
+
+	if region.Code == "" {
+		return nil, fmt.Errorf("region code is required")
+	}
+	if !money.IsCurrencyCode(region.Currency) {
+		return nil, fmt.Errorf("region %q: currency must be a 3-letter ISO 4217 code", region.Code)
+	}
+	if region.TaxRate < 0 || region.TaxRate >= 1 || region.ReducedTaxRate < 0 || region.ReducedTaxRate >= 1 {
+		return nil, fmt.Errorf("region %q: tax rates must be between 0 and 1", region.Code)
+	}
+	if region.BaseFare < 0 || region.PerKm < 0 || region.MinFare < 0 {
+		return nil, fmt.Errorf("region %q: fares must not be negative", region.Code)
+	}
+	if b := region.Bounds; b != nil && (b.MinLat > b.MaxLat || b.MinLon > b.MaxLon) {
+		return nil, fmt.Errorf("region %q: bounds min must not exceed max", region.Code)
+	}
+	if p := region.Payout; p != nil && (p.PerDelivery < 0 || p.PerKm < 0 || p.MinPayout < 0) {
+		return nil, fmt.Errorf("region %q: payout must not be negative", region.Code)
+	}
+	for vehicle, multiplier := range region.VehicleMultipliers {
+		if !vehicle.IsValid() {
+			return nil, fmt.Errorf("region %q: unknown vehicle type %q", region.Code, vehicle)
+		}
+		if multiplier <= 0 {
+			return nil, fmt.Errorf("region %q: vehicle multiplier must be positive", region.Code)
+		}
+	}
+
+	location := time.UTC
+	if region.Timezone != "" {
+		loc, err := time.LoadLocation(region.Timezone)
+		if err != nil {
+			return nil, fmt.Errorf("region %q: invalid timezone: %w", region.Code, err)
+		}
+		location = loc
+	}
+
+	return newRegionTariff(region, location), nil
+}
+
+func newRegionTariff(region models.Region, location *time.Location) *RegionTariff {
+	tariff := &RegionTariff{
+		Region:   region,
+		BaseFare: money.FromFloat(region.BaseFare, region.Currency),
+		PerKm:    money.FromFloat(region.PerKm, region.Currency),
+		MinFare:  money.FromFloat(region.MinFare, region.Currency),
+		Location: location,
+	}
+	if p := region.Payout; p != nil {
+		tariff.Payout = NewPayoutRules(p.PerDelivery, p.PerKm, p.MinPayout, region.Currency)
+	}
+	return tariff
 }
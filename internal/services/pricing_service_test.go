@@ -1,17 +1,157 @@
 package services
 
-import "testing"
+import (
+	"testing"
+
+	"delivery-system/internal/models"
+)
 
 func TestCalculateCost_MinFare(t *testing.T) {
-	svc := NewPricingService(100, 20, 150)
-	if cost := svc.CalculateCost(1); cost != 150 {
-		t.Fatalf("expected min fare 150, got %.2f", cost)
+	svc := NewPricingService(100, 20, 150, "RUB")
+	if cost := svc.CalculateCost(1); cost.String() != "150.00" {
+		t.Fatalf("expected min fare 150, got %s", cost)
 	}
 }
 
 func TestCalculateCost_NegativeDistance(t *testing.T) {
-	svc := NewPricingService(50, 10, 0)
-	if cost := svc.CalculateCost(-5); cost != 50 {
-		t.Fatalf("expected base fare for negative distance, got %.2f", cost)
+	svc := NewPricingService(50, 10, 0, "RUB")
+	if cost := svc.CalculateCost(-5); cost.String() != "50.00" {
+		t.Fatalf("expected base fare for negative distance, got %s", cost)
+	}
+}
+
+func TestCalculateCost_RoundsToMinorUnits(t *testing.T) {
+	svc := NewPricingService(99.99, 12.33, 0, "EUR")
+	cost := svc.CalculateCost(3.333)
+	// 99.99 + 12.33 * 3.333 = 99.99 + 41.09589 -> 141.09
+	if cost.Amount != 14109 || cost.Currency != "EUR" {
+		t.Fatalf("expected 141.09 EUR, got %s", cost.Display())
+	}
+}
+
+func TestPricingService_ResolveTariff(t *testing.T) {
+	svc, err := NewRegionalPricingService(models.Region{Code: "ru-moscow", Currency: "RUB", BaseFare: 100, PerKm: 20, MinFare: 150}, []models.Region{{
+		Code:     "kz-almaty",
+		Currency: "kzt",
+		Timezone: "Asia/Almaty",
+		BaseFare: 500,
+		PerKm:    100,
+		MinFare:  700,
+		Bounds:   &models.GeoBounds{MinLat: 43.1, MinLon: 76.7, MaxLat: 43.4, MaxLon: 77.1},
+		Payout:   &models.RegionPayout{PerDelivery: 300, PerKm: 60, MinPayout: 450},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	almaty := svc.ResolveTariff(43.25, 76.9)
+	if almaty.Code() != "kz-almaty" || almaty.Currency() != "KZT" || almaty.Location.String() != "Asia/Almaty" {
+		t.Fatalf("unexpected tariff: %+v", almaty.Region)
+	}
+	if cost := almaty.CalculateCost(3); cost.Display() != "800.00 KZT" {
+		t.Fatalf("expected 800 KZT, got %s", cost.Display())
+	}
+
+	if fallback := svc.ResolveTariff(55.75, 37.61); fallback.Code() != "ru-moscow" {
+		t.Fatalf("expected default region for point outside regions, got %s", fallback.Code())
+	}
+	if _, ok := svc.Tariff("kz-almaty"); !ok {
+		t.Fatalf("expected region lookup by code")
+	}
+	if payout := almaty.Payout.Calculate(3); payout.Display() != "480.00 KZT" {
+		t.Fatalf("expected regional payout 480 KZT, got %s", payout.Display())
+	}
+
+	// Регион в другой валюте без правил выплат получил бы выплаты по умолчанию в рублях
+	_, err = NewRegionalPricingService(models.Region{Currency: "RUB"}, []models.Region{{
+		Code: "de-berlin", Currency: "EUR", Bounds: &models.GeoBounds{MinLat: 52, MinLon: 13, MaxLat: 53, MaxLon: 14},
+	}})
+	if err == nil {
+		t.Fatalf("expected error for region without payout in another currency")
+	}
+}
+
+func TestPricingService_QuoteReturn(t *testing.T) {
+	svc, err := NewRegionalPricingService(models.Region{Code: "ru-moscow", Currency: "RUB", BaseFare: 100, PerKm: 20, MinFare: 150}, []models.Region{{
+		Code:     "kz-almaty",
+		Currency: "KZT",
+		BaseFare: 500,
+		PerKm:    100,
+		MinFare:  700,
+		Bounds:   &models.GeoBounds{MinLat: 43.1, MinLon: 76.7, MaxLat: 43.4, MaxLon: 77.1},
+		Payout:   &models.RegionPayout{PerDelivery: 300, PerKm: 60, MinPayout: 450},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Тот же адрес: обратное плечо стоит минимальную цену тарифа заказа
+	if cost := svc.QuoteReturn("kz-almaty", models.VehicleTypeBike, 43.25, 76.9, 43.25, 76.9); cost.Display() != "700.00 KZT" {
+		t.Fatalf("expected min fare of order region, got %s", cost.Display())
+	}
+	// Регион удален из настроек — тариф определяется по точке забора
+	if cost := svc.QuoteReturn("removed", models.VehicleTypeBike, 55.75, 37.61, 55.75, 37.61); cost.Display() != "150.00 RUB" {
+		t.Fatalf("expected fallback to pickup region, got %s", cost.Display())
+	}
+
+	want := svc.CalculateCost(calculateDistance(55.80, 37.70, 55.75, 37.61))
+	if cost := svc.QuoteReturn("ru-moscow", models.VehicleTypeBike, 55.80, 37.70, 55.75, 37.61); cost != want {
+		t.Fatalf("expected %s for return leg, got %s", want.Display(), cost.Display())
+	}
+}
+
+func TestPricingService_QuoteCartVehicle(t *testing.T) {
+	svc, err := NewRegionalPricingService(models.Region{
+		Currency:           "RUB",
+		BaseFare:           100,
+		PerKm:              20,
+		MinFare:            150,
+		VehicleMultipliers: map[models.VehicleType]float64{models.VehicleTypeCar: 1.5},
+	}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Легкий заказ на короткое расстояние везет велокурьер по базовому тарифу
+	light := svc.QuoteCart([]models.CreateOrderItemRequest{{Name: "Pizza", Quantity: 2, Price: rub(500), WeightKg: 1.2, VolumeL: 6}}, 55.75, 37.61, 55.76, 37.62)
+	if light.VehicleType != models.VehicleTypeBike || light.WeightKg != 2.4 || light.VolumeL != 12 {
+		t.Fatalf("unexpected light quote: %+v", light)
+	}
+	if light.DeliveryCost != svc.CalculateCost(calculateDistance(55.75, 37.61, 55.76, 37.62)) {
+		t.Fatalf("expected base tariff for bike, got %s", light.DeliveryCost.Display())
+	}
+
+	// Тяжелый заказ требует машину: тариф умножается на коэффициент
+	heavy := svc.QuoteCart([]models.CreateOrderItemRequest{{Name: "Water 19l", Quantity: 3, Price: rub(300), WeightKg: 19, VolumeL: 19}}, 55.75, 37.61, 55.76, 37.62)
+	if heavy.VehicleType != models.VehicleTypeCar {
+		t.Fatalf("expected car for 57 kg, got %s", heavy.VehicleType)
+	}
+	if heavy.DeliveryCost.Display() != "225.00 RUB" {
+		t.Fatalf("expected min fare with car multiplier, got %s", heavy.DeliveryCost.Display())
+	}
+
+	// Заказ больше типовой машины не отклоняется: его тарифицируют как машину, а везти его
+	// сможет курьер с ограничениями больше типовых
+	oversized := svc.QuoteCart([]models.CreateOrderItemRequest{{Name: "Cement", Quantity: 10, Price: rub(400), WeightKg: 50, VolumeL: 40}}, 55.75, 37.61, 55.76, 37.62)
+	if oversized.VehicleType != models.VehicleTypeCar || oversized.WeightKg != 500 {
+		t.Fatalf("expected car tariff for 500 kg, got %+v", oversized)
+	}
+}
+
+func TestNewRegionalPricingService_Validation(t *testing.T) {
+	bounds := &models.GeoBounds{MinLat: 1, MinLon: 1, MaxLat: 2, MaxLon: 2}
+	cases := map[string]models.Region{
+		"missing bounds": {Code: "a", Currency: "EUR"},
+		"bad currency":   {Code: "a", Currency: "EURO", Bounds: bounds},
+		"bad timezone":   {Code: "a", Currency: "EUR", Timezone: "Mars/Base", Bounds: bounds},
+		"bad tax rate":   {Code: "a", Currency: "EUR", TaxRate: 1.5, Bounds: bounds},
+		"duplicate code": {Code: models.DefaultRegionCode, Currency: "EUR", Bounds: bounds},
+		"bad vehicle":    {Code: "a", Currency: "EUR", Bounds: bounds, VehicleMultipliers: map[models.VehicleType]float64{"truck": 2}},
+		"bad multiplier": {Code: "a", Currency: "EUR", Bounds: bounds, VehicleMultipliers: map[models.VehicleType]float64{models.VehicleTypeCar: 0}},
+	}
+	for name, region := range cases {
+		if _, err := NewRegionalPricingService(models.Region{Currency: "RUB"}, []models.Region{region}); err == nil {
+			t.Fatalf("%s: expected error", name)
+		}
 	}
 }
@@ -0,0 +1,270 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/models"
+	"delivery-system/internal/money"
+
+	"github.com/google/uuid"
+)
+
+// PromoOrder — параметры заказа, по которым проверяются правила промокода.
+type PromoOrder struct {
+	OrderID       uuid.UUID // заказ, к которому записываются применения кодов
+	CustomerPhone string
+	Region        string
+	Location      *time.Location // часовой пояс региона; nil — UTC
+	ItemsTotal    money.Money
+	DeliveryCost  money.Money
+}
+
+var promoWeekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// promoRejection — причина отказа, вложенная в apperror, чтобы предпросмотр мог вернуть ее клиенту.
+type promoRejection struct {
+	reason models.PromoRejectReason
+}
+
+func (r *promoRejection) Error() string { return string(r.reason) }
+
+// rejectPromo возвращает конфликт с машиночитаемой причиной отказа.
+func rejectPromo(reason models.PromoRejectReason, msg string) error {
+	return apperror.Conflict(msg, &promoRejection{reason: reason})
+}
+
+// promoRejectReason извлекает причину отказа из ошибки применения промокода.
+func promoRejectReason(err error) (models.PromoRejectReason, bool) {
+	var rejection *promoRejection
+	if errors.As(err, &rejection) {
+		return rejection.reason, true
+	}
+	return "", false
+}
+
+// validatePromoRules проверяет корректность правил при создании и изменении промокода.
+func validatePromoRules(discountType models.DiscountType, rules *models.PromoRules) error {
+	if rules.MinItemsTotal != nil && rules.MinItemsTotal.IsNegative() {
+		return fmt.Errorf("rules.min_items_total must be non-negative")
+	}
+	if rules.MaxUsesPerCustomer < 0 {
+		return fmt.Errorf("rules.max_uses_per_customer must be non-negative")
+	}
+	for _, region := range rules.Regions {
+		if strings.TrimSpace(region) == "" {
+			return fmt.Errorf("rules.regions must not contain empty codes")
+		}
+	}
+	for _, day := range rules.Weekdays {
+		if _, ok := promoWeekdays[day]; !ok {
+			return fmt.Errorf("rules.weekdays must contain only: mon, tue, wed, thu, fri, sat, sun")
+		}
+	}
+	if (rules.HoursFrom == "") != (rules.HoursTo == "") {
+		return fmt.Errorf("rules.hours_from and rules.hours_to must be set together")
+	}
+	if rules.HoursFrom != "" {
+		from, errFrom := parseClock(rules.HoursFrom)
+		to, errTo := parseClock(rules.HoursTo)
+		if errFrom != nil || errTo != nil {
+			return fmt.Errorf("rules.hours_from and rules.hours_to must be in HH:MM format")
+		}
+		if from == to {
+			return fmt.Errorf("rules.hours_from and rules.hours_to must differ")
+		}
+	}
+	if rules.MaxDiscount != nil {
+		if discountType != models.DiscountTypePercent {
+			return fmt.Errorf("rules.max_discount is allowed only for percent discount")
+		}
+		if !rules.MaxDiscount.IsPositive() {
+			return fmt.Errorf("rules.max_discount must be positive")
+		}
+	}
+	return nil
+}
+
+// validateMaxDiscountCurrency проверяет, что потолок скидки, заданный в валюте промокода, можно
+// сравнить со скидкой в каждом регионе, где действует промокод. Процентная скидка считается в валюте
+// заказа, и в регионе с другой валютой промокод отклонялся бы на каждом заказе. Без списка регионов
+// промокод действует везде, и валюта промокода должна быть у всех регионов.
+func validateMaxDiscountCurrency(currency string, rules *models.PromoRules, regions []models.Region) error {
+	if rules.MaxDiscount == nil {
+		return nil
+	}
+	for _, region := range regions {
+		if region.Currency == currency {
+			continue
+		}
+		if len(rules.Regions) == 0 {
+			return fmt.Errorf("rules.max_discount is in %s, but region %s uses %s: limit the promo code with rules.regions", currency, region.Code, region.Currency)
+		}
+		for _, code := range rules.Regions {
+			if code == region.Code {
+				return fmt.Errorf("rules.max_discount is in %s, but region %s uses %s", currency, region.Code, region.Currency)
+			}
+		}
+	}
+	return nil
+}
+
+// checkPromoRules проверяет правила, не требующие обращения к БД.
+// Возвращает apperror.Conflict с описанием первого нарушенного правила.
+func checkPromoRules(rules *models.PromoRules, order PromoOrder, now time.Time) error {
+	if len(rules.Regions) > 0 && !containsString(rules.Regions, order.Region) {
+		return rejectPromo(models.PromoReasonRegion, fmt.Sprintf("promo code is not valid in region %s", order.Region))
+	}
+
+	location := order.Location
+	if location == nil {
+		location = time.UTC
+	}
+	local := now.In(location)
+
+	if len(rules.Weekdays) > 0 {
+		allowed := false
+		for _, day := range rules.Weekdays {
+			if promoWeekdays[day] == local.Weekday() {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return rejectPromo(models.PromoReasonWeekday, fmt.Sprintf("promo code is valid only on %s", strings.Join(rules.Weekdays, ", ")))
+		}
+	}
+
+	if rules.HoursFrom != "" && rules.HoursTo != "" {
+		from, _ := parseClock(rules.HoursFrom)
+		to, _ := parseClock(rules.HoursTo)
+		minute := local.Hour()*60 + local.Minute()
+		inWindow := minute >= from && minute < to
+		if from > to {
+			inWindow = minute >= from || minute < to
+		}
+		if !inWindow {
+			return rejectPromo(models.PromoReasonHours, fmt.Sprintf("promo code is valid only between %s and %s", rules.HoursFrom, rules.HoursTo))
+		}
+	}
+
+	if rules.MinItemsTotal != nil {
+		if !order.ItemsTotal.SameCurrency(*rules.MinItemsTotal) {
+			return rejectPromo(models.PromoReasonCurrencyMismatch, "promo code currency does not match order currency")
+		}
+		if order.ItemsTotal.Cmp(*rules.MinItemsTotal) < 0 {
+			return rejectPromo(models.PromoReasonMinItemsTotal, fmt.Sprintf("promo code requires items total of at least %s", rules.MinItemsTotal.Display()))
+		}
+	}
+
+	return nil
+}
+
+// checkCustomerPromoRules проверяет правила по истории заказов клиента. Вызывается после блокировки
+// строки промокода, поэтому параллельные заказы с тем же кодом не обходят лимит.
+func checkCustomerPromoRules(ctx context.Context, tx *sql.Tx, code string, rules *models.PromoRules, order PromoOrder) error {
+	if !rules.FirstOrderOnly && rules.MaxUsesPerCustomer == 0 {
+		return nil
+	}
+
+	phone := normalizePhone(order.CustomerPhone)
+	if phone == "" {
+		return rejectPromo(models.PromoReasonPhoneRequired, "promo code requires customer phone")
+	}
+
+	if rules.FirstOrderOnly {
+		var hasOrders bool
+		query := `
+			SELECT EXISTS(
+				SELECT 1 FROM orders
+				WHERE regexp_replace(customer_phone, '\D', '', 'g') = $1 AND status <> 'cancelled' AND id <> $2
+			)
+		`
+		// Сам заказ не учитывается: при изменении заказа код проверяется повторно
+		if err := tx.QueryRowContext(ctx, query, phone, order.OrderID).Scan(&hasOrders); err != nil {
+			return fmt.Errorf("failed to check customer orders: %w", err)
+		}
+		if hasOrders {
+			return rejectPromo(models.PromoReasonFirstOrderOnly, "promo code is valid for the first order only")
+		}
+	}
+
+	if rules.MaxUsesPerCustomer > 0 {
+		var used int
+		query := `
+			SELECT COUNT(*) FROM promo_redemptions
+			WHERE code = $1 AND customer_phone = $2 AND reversed_at IS NULL
+		`
+		if err := tx.QueryRowContext(ctx, query, code, phone).Scan(&used); err != nil {
+			return fmt.Errorf("failed to count customer promo usage: %w", err)
+		}
+		if used >= rules.MaxUsesPerCustomer {
+			return rejectPromo(models.PromoReasonCustomerLimit, "promo code usage limit per customer reached")
+		}
+	}
+
+	return nil
+}
+
+// decodePromoRules читает правила из JSONB и проставляет валюту промокода.
+func decodePromoRules(raw []byte, currency string) (models.PromoRules, error) {
+	var rules models.PromoRules
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &rules); err != nil {
+			return rules, fmt.Errorf("failed to decode promo rules: %w", err)
+		}
+	}
+	rules.ApplyCurrency(currency)
+	return rules, nil
+}
+
+func encodePromoRules(rules models.PromoRules) (string, error) {
+	data, err := json.Marshal(rules)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode promo rules: %w", err)
+	}
+	return string(data), nil
+}
+
+// parseClock разбирает время HH:MM в минуты от начала суток.
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, err
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// normalizePhone оставляет в номере только цифры, чтобы +7 (999) 123-45-67 и 79991234567 совпадали.
+func normalizePhone(phone string) string {
+	var b strings.Builder
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/models"
+	"delivery-system/internal/money"
+)
+
+func moneyPtr(m money.Money) *money.Money { return &m }
+
+func TestValidatePromoRules(t *testing.T) {
+	valid := &models.PromoRules{
+		MinItemsTotal:      moneyPtr(rub(500)),
+		MaxUsesPerCustomer: 1,
+		Regions:            []string{"default"},
+		Weekdays:           []string{"mon", "fri"},
+		HoursFrom:          "22:00",
+		HoursTo:            "02:00",
+		MaxDiscount:        moneyPtr(rub(300)),
+	}
+	if err := validatePromoRules(models.DiscountTypePercent, valid); err != nil {
+		t.Fatalf("expected valid rules, got %v", err)
+	}
+
+	cases := []struct {
+		name  string
+		typ   models.DiscountType
+		rules models.PromoRules
+	}{
+		{"negative min total", models.DiscountTypeFixed, models.PromoRules{MinItemsTotal: moneyPtr(rub(-1))}},
+		{"negative per customer", models.DiscountTypeFixed, models.PromoRules{MaxUsesPerCustomer: -1}},
+		{"unknown weekday", models.DiscountTypeFixed, models.PromoRules{Weekdays: []string{"monday"}}},
+		{"half window", models.DiscountTypeFixed, models.PromoRules{HoursFrom: "10:00"}},
+		{"bad clock", models.DiscountTypeFixed, models.PromoRules{HoursFrom: "25:00", HoursTo: "10:00"}},
+		{"cap on fixed", models.DiscountTypeFixed, models.PromoRules{MaxDiscount: moneyPtr(rub(100))}},
+	}
+	for _, tc := range cases {
+		if err := validatePromoRules(tc.typ, &tc.rules); err == nil {
+			t.Fatalf("%s: expected validation error", tc.name)
+		}
+	}
+}
+
+func TestValidateMaxDiscountCurrency(t *testing.T) {
+	regions := []models.Region{{Code: "default", Currency: "RUB"}, {Code: "spb", Currency: "RUB"}, {Code: "de-berlin", Currency: "EUR"}}
+	capped := func(codes ...string) *models.PromoRules {
+		return &models.PromoRules{Regions: codes, MaxDiscount: moneyPtr(rub(300))}
+	}
+
+	if err := validateMaxDiscountCurrency("RUB", capped("default", "spb"), regions); err != nil {
+		t.Fatalf("expected cap valid in RUB regions, got %v", err)
+	}
+	if err := validateMaxDiscountCurrency("RUB", &models.PromoRules{}, regions); err != nil {
+		t.Fatalf("expected promo without cap to be valid everywhere, got %v", err)
+	}
+	if err := validateMaxDiscountCurrency("RUB", capped("spb", "de-berlin"), regions); err == nil || !strings.Contains(err.Error(), "de-berlin") {
+		t.Fatalf("expected error for EUR region, got %v", err)
+	}
+	if err := validateMaxDiscountCurrency("RUB", capped(), regions); err == nil {
+		t.Fatalf("expected error for promo valid in every region")
+	}
+	if err := validateMaxDiscountCurrency("EUR", capped("de-berlin"), regions); err != nil {
+		t.Fatalf("expected EUR cap valid in EUR region, got %v", err)
+	}
+}
+
+func TestCheckPromoRules(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	// Пятница, 23:30 по Москве
+	now := time.Date(2024, 5, 3, 20, 30, 0, 0, time.UTC)
+	order := PromoOrder{Region: "msk", Location: moscow, ItemsTotal: rub(600)}
+
+	rules := &models.PromoRules{
+		Regions:       []string{"msk"},
+		Weekdays:      []string{"fri", "sat"},
+		HoursFrom:     "22:00",
+		HoursTo:       "02:00",
+		MinItemsTotal: moneyPtr(rub(500)),
+	}
+	if err := checkPromoRules(rules, order, now); err != nil {
+		t.Fatalf("expected rules to pass, got %v", err)
+	}
+
+	cases := []struct {
+		name    string
+		mutate  func(r *models.PromoRules)
+		message string
+	}{
+		{"region", func(r *models.PromoRules) { r.Regions = []string{"spb"} }, "not valid in region msk"},
+		{"weekday", func(r *models.PromoRules) { r.Weekdays = []string{"mon"} }, "valid only on mon"},
+		{"hours", func(r *models.PromoRules) { r.HoursFrom, r.HoursTo = "10:00", "18:00" }, "between 10:00 and 18:00"},
+		{"min total", func(r *models.PromoRules) { r.MinItemsTotal = moneyPtr(rub(700)) }, "at least 700.00 RUB"},
+	}
+	for _, tc := range cases {
+		r := *rules
+		tc.mutate(&r)
+		err := checkPromoRules(&r, order, now)
+		if !apperror.Is(err, apperror.KindConflict) || !strings.Contains(err.Error(), tc.message) {
+			t.Fatalf("%s: expected conflict %q, got %v", tc.name, tc.message, err)
+		}
+	}
+}
+
+func TestNormalizePhone(t *testing.T) {
+	if got := normalizePhone("+7 (999) 123-45-67"); got != "79991234567" {
+		t.Fatalf("unexpected phone: %s", got)
+	}
+}
@@ -6,28 +6,55 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"sort"
+	"strings"
 	"time"
 
 	"delivery-system/internal/apperror"
+	"delivery-system/internal/config"
 	"delivery-system/internal/database"
 	"delivery-system/internal/logger"
 	"delivery-system/internal/models"
+	"delivery-system/internal/money"
+	"delivery-system/internal/pagination"
 
+	"github.com/google/uuid"
 	"github.com/lib/pq"
 )
 
+// Порядок применения скидок при сочетании промокодов. Бесплатная доставка всегда применяется первой.
+const (
+	PromoStackingFixedFirst   = "fixed_first"   // фиксированные скидки, затем процент от остатка
+	PromoStackingPercentFirst = "percent_first" // процент от полной суммы, затем фиксированные
+
+	defaultMaxPromoCodesPerOrder = 3
+)
+
 // PromoService управляет промокодами и расчётом скидок.
 type PromoService struct {
-	db  *database.DB
-	log *logger.Logger
+	db           *database.DB
+	log          *logger.Logger
+	pricing      *PricingService
+	percentFirst bool
+	maxCodes     int
 }
 
-// NewPromoService создаёт сервис промокодов.
-func NewPromoService(db *database.DB, log *logger.Logger) *PromoService {
-	return &PromoService{
-		db:  db,
-		log: log,
+// NewPromoService создаёт сервис промокодов. Тарифы нужны для предпросмотра скидки по корзине.
+// Без конфигурации фиксированные скидки применяются раньше процентных, а в заказе допускается до трех кодов.
+func NewPromoService(db *database.DB, log *logger.Logger, pricing *PricingService, cfg *config.PromoConfig) *PromoService {
+	service := &PromoService{
+		db:       db,
+		log:      log,
+		pricing:  pricing,
+		maxCodes: defaultMaxPromoCodesPerOrder,
 	}
+	if cfg != nil {
+		service.percentFirst = cfg.StackingOrder == PromoStackingPercentFirst
+		if cfg.MaxCodesPerOrder > 0 {
+			service.maxCodes = cfg.MaxCodesPerOrder
+		}
+	}
+	return service
 }
 
 // CreatePromoCode создаёт новый промокод.
@@ -35,24 +62,51 @@ func (s *PromoService) CreatePromoCode(ctx context.Context, req *models.CreatePr
 	if err := validatePromoCodePayload(req.DiscountType, req.Amount); err != nil {
 		return nil, apperror.Validation(err.Error(), err)
 	}
+	if err := validatePromoRules(req.DiscountType, &req.Rules); err != nil {
+		return nil, apperror.Validation(err.Error(), err)
+	}
+	stacking, err := normalizePromoStacking(req.Stacking)
+	if err != nil {
+		return nil, apperror.Validation(err.Error(), err)
+	}
+
+	currency := strings.ToUpper(req.Currency)
+	if currency == "" {
+		currency = money.DefaultCurrency
+	}
+	if !money.IsCurrencyCode(currency) {
+		return nil, apperror.Validation("currency must be a 3-letter ISO 4217 code", nil)
+	}
+	if err := s.validateMaxDiscountCurrency(currency, &req.Rules); err != nil {
+		return nil, err
+	}
 
 	promo := &models.PromoCode{
 		Code:         req.Code,
 		DiscountType: req.DiscountType,
-		Amount:       req.Amount,
+		Amount:       req.Amount.In(currency),
+		Currency:     currency,
 		MaxUses:      req.MaxUses,
 		ExpiresAt:    req.ExpiresAt,
 		Active:       req.Active,
+		Rules:        req.Rules,
+		Stacking:     stacking,
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
 
+	promo.Rules.ApplyCurrency(currency)
+	rules, err := encodePromoRules(promo.Rules)
+	if err != nil {
+		return nil, err
+	}
+
 	query := `
-		INSERT INTO promo_codes (code, discount_type, amount, max_uses, used_count, expires_at, active, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, 0, $5, $6, $7, $8)
+		INSERT INTO promo_codes (code, discount_type, amount, currency, max_uses, used_count, expires_at, active, rules, stacking, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, 0, $6, $7, $8, $9, $10, $11)
 	`
 
-	_, err := s.db.ExecContext(ctx, query, promo.Code, promo.DiscountType, promo.Amount, promo.MaxUses, promo.ExpiresAt, promo.Active, promo.CreatedAt, promo.UpdatedAt)
+	_, err = s.db.ExecContext(ctx, query, promo.Code, promo.DiscountType, promo.Amount, promo.Currency, promo.MaxUses, promo.ExpiresAt, promo.Active, rules, promo.Stacking, promo.CreatedAt, promo.UpdatedAt)
 	if err != nil {
 		var pqErr *pq.Error
 		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
@@ -65,19 +119,51 @@ func (s *PromoService) CreatePromoCode(ctx context.Context, req *models.CreatePr
 	return promo, nil
 }
 
+// validateMaxDiscountCurrency сверяет валюту потолка скидки с валютами регионов, где действует промокод.
+func (s *PromoService) validateMaxDiscountCurrency(currency string, rules *models.PromoRules) error {
+	if s.pricing == nil {
+		return nil
+	}
+	if err := validateMaxDiscountCurrency(currency, rules, s.pricing.Regions()); err != nil {
+		return apperror.Validation(err.Error(), err)
+	}
+	return nil
+}
+
 // UpdatePromoCode обновляет параметры промокода.
 func (s *PromoService) UpdatePromoCode(ctx context.Context, code string, req *models.UpdatePromoCodeRequest) (*models.PromoCode, error) {
 	if err := validatePromoCodePayload(req.DiscountType, req.Amount); err != nil {
 		return nil, apperror.Validation(err.Error(), err)
 	}
+	if err := validatePromoRules(req.DiscountType, &req.Rules); err != nil {
+		return nil, apperror.Validation(err.Error(), err)
+	}
+	stacking, err := normalizePromoStacking(req.Stacking)
+	if err != nil {
+		return nil, apperror.Validation(err.Error(), err)
+	}
+	if req.Rules.MaxDiscount != nil {
+		// Валюта промокода не меняется, потолок сверяется с ней
+		current, err := s.GetPromoCode(ctx, code)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.validateMaxDiscountCurrency(current.Currency, &req.Rules); err != nil {
+			return nil, err
+		}
+	}
+	rules, err := encodePromoRules(req.Rules)
+	if err != nil {
+		return nil, err
+	}
 
 	query := `
 		UPDATE promo_codes
-		SET discount_type = $1, amount = $2, max_uses = $3, expires_at = $4, active = $5, updated_at = $6
-		WHERE code = $7
+		SET discount_type = $1, amount = $2, max_uses = $3, expires_at = $4, active = $5, rules = $6, stacking = $7, updated_at = $8
+		WHERE code = $9
 	`
 
-	result, err := s.db.ExecContext(ctx, query, req.DiscountType, req.Amount, req.MaxUses, req.ExpiresAt, req.Active, time.Now(), code)
+	result, err := s.db.ExecContext(ctx, query, req.DiscountType, req.Amount, req.MaxUses, req.ExpiresAt, req.Active, rules, stacking, time.Now(), code)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update promo code: %w", err)
 	}
@@ -109,19 +195,15 @@ func (s *PromoService) DeletePromoCode(ctx context.Context, code string) error {
 	return nil
 }
 
+// promoCodeColumns — колонки promo_codes в порядке scanPromoCode.
+const promoCodeColumns = `code, discount_type, amount, currency, max_uses, used_count, expires_at, active, rules, stacking, campaign_id, created_at, updated_at`
+
 // GetPromoCode возвращает промокод по коду.
 func (s *PromoService) GetPromoCode(ctx context.Context, code string) (*models.PromoCode, error) {
-	query := `
-		SELECT code, discount_type, amount, max_uses, used_count, expires_at, active, created_at, updated_at
-		FROM promo_codes
-		WHERE code = $1
-	`
+	query := `SELECT ` + promoCodeColumns + ` FROM promo_codes WHERE code = $1`
 
-	promo := &models.PromoCode{}
-	if err := s.db.QueryRowContext(ctx, query, code).Scan(
-		&promo.Code, &promo.DiscountType, &promo.Amount, &promo.MaxUses, &promo.UsedCount,
-		&promo.ExpiresAt, &promo.Active, &promo.CreatedAt, &promo.UpdatedAt,
-	); err != nil {
+	promo, err := scanPromoCode(s.db.QueryRowContext(ctx, query, code).Scan)
+	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, apperror.NotFound("promo code not found", err)
 		}
@@ -130,19 +212,23 @@ func (s *PromoService) GetPromoCode(ctx context.Context, code string) (*models.P
 	return promo, nil
 }
 
-// ListPromoCodes возвращает список промокодов.
-func (s *PromoService) ListPromoCodes(ctx context.Context, limit, offset int) ([]*models.PromoCode, error) {
-	if limit <= 0 {
-		limit = 50
+// ListPromoCodes возвращает страницу промокодов, от новых к старым.
+func (s *PromoService) ListPromoCodes(ctx context.Context, page pagination.Request) (*pagination.Page[*models.PromoCode], error) {
+	if err := page.CheckSort(sortCreatedAt); err != nil {
+		return nil, err
 	}
-	query := `
-		SELECT code, discount_type, amount, max_uses, used_count, expires_at, active, created_at, updated_at
-		FROM promo_codes
-		ORDER BY created_at DESC
-		LIMIT $1 OFFSET $2
-	`
+	if page.Limit <= 0 {
+		page.Limit = 50
+	}
+
+	query := `SELECT ` + promoCodeColumns + ` FROM promo_codes`
+	args := []interface{}{}
+	if page.After != nil {
+		query += " WHERE " + keysetCondition([]string{"created_at", "code"}, &args, page.After.CreatedAt, page.After.ID)
+	}
+	query += " ORDER BY created_at DESC, code DESC" + pageLimitClause(page, &args)
 
-	rows, err := s.db.QueryContext(ctx, query, limit, offset)
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list promo codes: %w", err)
 	}
@@ -150,8 +236,8 @@ func (s *PromoService) ListPromoCodes(ctx context.Context, limit, offset int) ([
 
 	var promos []*models.PromoCode
 	for rows.Next() {
-		p := &models.PromoCode{}
-		if err := rows.Scan(&p.Code, &p.DiscountType, &p.Amount, &p.MaxUses, &p.UsedCount, &p.ExpiresAt, &p.Active, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		p, err := scanPromoCode(rows.Scan)
+		if err != nil {
 			return nil, fmt.Errorf("failed to scan promo code: %w", err)
 		}
 		promos = append(promos, p)
@@ -161,97 +247,469 @@ func (s *PromoService) ListPromoCodes(ctx context.Context, limit, offset int) ([
 		return nil, fmt.Errorf("failed to iterate promo codes: %w", err)
 	}
 
-	return promos, nil
+	return pagination.NewPage(promos, page.Limit, func(p *models.PromoCode) pagination.Cursor {
+		return pagination.Cursor{Sort: sortCreatedAt, CreatedAt: p.CreatedAt, ID: p.Code}
+	}), nil
 }
 
-// ApplyPromoWithTx рассчитывает скидку и увеличивает счётчик использования в рамках транзакции.
-func (s *PromoService) ApplyPromoWithTx(ctx context.Context, tx *sql.Tx, code string, itemsTotal, deliveryCost float64) (float64, error) {
+// scanPromoCode читает строку с колонками promoCodeColumns.
+func scanPromoCode(scan func(dest ...interface{}) error) (*models.PromoCode, error) {
+	p := &models.PromoCode{}
+	var rules []byte
+	if err := scan(&p.Code, &p.DiscountType, &p.Amount, &p.Currency, &p.MaxUses, &p.UsedCount,
+		&p.ExpiresAt, &p.Active, &rules, &p.Stacking, &p.CampaignID, &p.CreatedAt, &p.UpdatedAt); err != nil {
+		return nil, err
+	}
+	p.Amount = p.Amount.In(p.Currency)
+	var err error
+	if p.Rules, err = decodePromoRules(rules, p.Currency); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// PromoApplication — итог применения промокодов к заказу.
+type PromoApplication struct {
+	Discount    money.Money              // суммарная скидка в валюте заказа
+	Redemptions []models.PromoRedemption // применения в порядке расчета
+}
+
+// ApplyPromoWithTx проверяет правила и сочетаемость промокодов, рассчитывает скидки, увеличивает счётчики
+// использования и записывает применения в рамках транзакции создания заказа. Скидка возвращается в валюте
+// заказа; фиксированная скидка в другой валюте не применяется.
+func (s *PromoService) ApplyPromoWithTx(ctx context.Context, tx *sql.Tx, codes []string, order PromoOrder) (*PromoApplication, error) {
+	codes, err := s.normalizeOrderCodes(codes)
+	if err != nil {
+		return nil, err
+	}
+
+	base := order.ItemsTotal.Add(order.DeliveryCost)
+	application := &PromoApplication{Discount: money.Zero(base.Currency)}
+	if len(codes) == 0 {
+		return application, nil
+	}
+
+	// Строки блокируются в порядке кодов, чтобы параллельные заказы с теми же кодами не ждали друг друга по кругу
+	locked := append([]string(nil), codes...)
+	sort.Strings(locked)
+	now := time.Now()
+	promos := make(map[string]*orderPromo, len(codes))
+	for _, code := range locked {
+		promo, err := loadOrderPromo(ctx, tx, code, order, now, true)
+		if err != nil {
+			return nil, err
+		}
+		promos[code] = promo
+	}
+
+	if len(codes) > 1 {
+		for _, code := range codes {
+			if promos[code].stacking != models.PromoStackingCombinable {
+				return nil, rejectPromo(models.PromoReasonNotCombinable, fmt.Sprintf("promo code %s cannot be combined with other promo codes", code))
+			}
+		}
+	}
+
+	ordered := make([]*orderPromo, 0, len(codes))
+	for _, code := range codes {
+		ordered = append(ordered, promos[code])
+	}
+	sort.SliceStable(ordered, func(i, j int) bool {
+		return s.stackingRank(ordered[i].discountType) < s.stackingRank(ordered[j].discountType)
+	})
+
+	phone := normalizePhone(order.CustomerPhone)
+	remaining := base
+	deliveryLeft := order.DeliveryCost
+	for position, promo := range ordered {
+		discount, err := promo.discount(remaining, deliveryLeft)
+		if err != nil {
+			return nil, err
+		}
+		remaining = remaining.Sub(discount)
+		if promo.discountType == models.DiscountTypeFreeDelivery {
+			deliveryLeft = money.Zero(base.Currency)
+		}
+
+		redemption := models.PromoRedemption{
+			ID:            uuid.New(),
+			Code:          promo.code,
+			OrderID:       order.OrderID,
+			CustomerPhone: phone,
+			DiscountType:  promo.discountType,
+			Discount:      discount,
+			Currency:      base.Currency,
+			Position:      position,
+			RedeemedAt:    now,
+		}
+		if err := recordPromoRedemption(ctx, tx, &redemption); err != nil {
+			return nil, err
+		}
+
+		application.Discount = application.Discount.Add(discount)
+		application.Redemptions = append(application.Redemptions, redemption)
+	}
+
+	return application, nil
+}
+
+// PreviewPromo проверяет промокод для корзины и рассчитывает скидку так же, как при создании заказа,
+// но ничего не записывает: used_count и история применений не меняются. Неприменимый код
+// возвращается с valid=false и причиной, а не ошибкой.
+func (s *PromoService) PreviewPromo(ctx context.Context, code string, req *models.ValidatePromoRequest) (*models.PromoValidation, error) {
+	if req.PickupLat == nil || req.PickupLon == nil || req.DeliveryLat == nil || req.DeliveryLon == nil {
+		return nil, apperror.Validation("pickup and delivery coordinates are required for pricing", nil)
+	}
+	if len(req.Items) == 0 {
+		return nil, apperror.Validation("items are required", nil)
+	}
+	for i, item := range req.Items {
+		if item.Quantity <= 0 || item.Price.IsNegative() {
+			return nil, apperror.Validation(fmt.Sprintf("item %d: quantity must be positive and price non-negative", i+1), nil)
+		}
+	}
+	if s.pricing == nil {
+		return nil, fmt.Errorf("pricing is not configured for promo preview")
+	}
+
+	quote := s.pricing.QuoteCart(req.Items, *req.PickupLat, *req.PickupLon, *req.DeliveryLat, *req.DeliveryLon)
+	base := quote.ItemsTotal.Add(quote.DeliveryCost)
+	result := &models.PromoValidation{
+		Code:         code,
+		Discount:     money.Zero(base.Currency),
+		ItemsTotal:   quote.ItemsTotal,
+		DeliveryCost: quote.DeliveryCost,
+		Total:        base,
+		Currency:     base.Currency,
+		Region:       quote.Tariff.Code(),
+	}
+
+	tx, err := s.db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	order := PromoOrder{
+		CustomerPhone: req.CustomerPhone,
+		Region:        quote.Tariff.Code(),
+		Location:      quote.Tariff.Location,
+		ItemsTotal:    quote.ItemsTotal,
+		DeliveryCost:  quote.DeliveryCost,
+	}
+	promo, err := loadOrderPromo(ctx, tx, code, order, time.Now(), false)
+	if err == nil {
+		var discount money.Money
+		if discount, err = promo.discount(base, quote.DeliveryCost); err == nil {
+			result.Valid = true
+			result.Discount = discount
+			result.Total = base.Sub(discount)
+			return result, nil
+		}
+	}
+
+	reason, ok := promoRejectReason(err)
+	if !ok {
+		return nil, err
+	}
+	result.Reason = reason
+	result.Message = err.Error()
+	return result, nil
+}
+
+// ReverseRedemptionsWithTx отменяет применения промокодов заказа и возвращает кодам использования.
+// Повторный вызов для того же заказа ничего не меняет.
+func (s *PromoService) ReverseRedemptionsWithTx(ctx context.Context, tx *sql.Tx, orderID uuid.UUID) error {
+	query := `
+		WITH reversed AS (
+			UPDATE promo_redemptions
+			SET reversed_at = $2
+			WHERE order_id = $1 AND reversed_at IS NULL
+			RETURNING code
+		)
+		UPDATE promo_codes p
+		SET used_count = GREATEST(p.used_count - 1, 0), updated_at = $2
+		FROM reversed r
+		WHERE p.code = r.code
+	`
+	result, err := tx.ExecContext(ctx, query, orderID, time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to reverse promo redemptions: %w", err)
+	}
+	if rows, err := result.RowsAffected(); err == nil && rows > 0 {
+		s.log.WithField("order_id", orderID).WithField("codes", rows).Info("Promo redemptions reversed")
+	}
+	return nil
+}
+
+// RevalidateWithTx заново применяет действующие промокоды заказа к измененной корзине: прежние
+// применения отменяются, коды проверяются по текущим правилам и скидка пересчитывается. Если код
+// больше не подходит, возвращается отказ с причиной, и изменение заказа откатывается.
+func (s *PromoService) RevalidateWithTx(ctx context.Context, tx *sql.Tx, order PromoOrder) (*PromoApplication, error) {
+	rows, err := tx.QueryContext(ctx, "SELECT code FROM promo_redemptions WHERE order_id = $1 AND reversed_at IS NULL ORDER BY position", order.OrderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order promo codes: %w", err)
+	}
+	var codes []string
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to scan order promo code: %w", err)
+		}
+		codes = append(codes, code)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate order promo codes: %w", err)
+	}
+
+	if len(codes) == 0 {
+		return &PromoApplication{Discount: money.Zero(order.ItemsTotal.Currency)}, nil
+	}
+	if err := s.ReverseRedemptionsWithTx(ctx, tx, order.OrderID); err != nil {
+		return nil, err
+	}
+	return s.ApplyPromoWithTx(ctx, tx, codes, order)
+}
+
+// ListRedemptions возвращает историю применений промокода, начиная с последних.
+// История доступна и после удаления промокода.
+func (s *PromoService) ListRedemptions(ctx context.Context, code string, page pagination.Request) (*pagination.Page[*models.PromoRedemption], error) {
+	if err := page.CheckSort(sortCreatedAt); err != nil {
+		return nil, err
+	}
+	if page.Limit <= 0 {
+		page.Limit = 50
+	}
+
+	var exists bool
+	existsQuery := `
+		SELECT EXISTS(SELECT 1 FROM promo_codes WHERE code = $1)
+			OR EXISTS(SELECT 1 FROM promo_redemptions WHERE code = $1)
+	`
+	if err := s.db.QueryRowContext(ctx, existsQuery, code).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to check promo code: %w", err)
+	}
+	if !exists {
+		return nil, apperror.NotFound("promo code not found", nil)
+	}
+
+	query := `
+		SELECT id, code, order_id, customer_phone, discount_type, discount_amount, currency, position, redeemed_at, reversed_at
+		FROM promo_redemptions
+		WHERE code = $1`
+	args := []interface{}{code}
+	if page.After != nil {
+		query += " AND " + keysetCondition([]string{"redeemed_at", "id"}, &args, page.After.CreatedAt, page.After.ID)
+	}
+	query += " ORDER BY redeemed_at DESC, id DESC" + pageLimitClause(page, &args)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list promo redemptions: %w", err)
+	}
+	defer rows.Close()
+
+	var redemptions []*models.PromoRedemption
+	for rows.Next() {
+		r := &models.PromoRedemption{}
+		if err := rows.Scan(&r.ID, &r.Code, &r.OrderID, &r.CustomerPhone, &r.DiscountType, &r.Discount,
+			&r.Currency, &r.Position, &r.RedeemedAt, &r.ReversedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan promo redemption: %w", err)
+		}
+		r.Discount = r.Discount.In(r.Currency)
+		redemptions = append(redemptions, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate promo redemptions: %w", err)
+	}
+
+	return pagination.NewPage(redemptions, page.Limit, func(r *models.PromoRedemption) pagination.Cursor {
+		return pagination.Cursor{Sort: sortCreatedAt, CreatedAt: r.RedeemedAt, ID: r.ID.String()}
+	}), nil
+}
+
+// normalizeOrderCodes убирает пустые коды и проверяет лимит и повторы.
+func (s *PromoService) normalizeOrderCodes(codes []string) ([]string, error) {
+	result := make([]string, 0, len(codes))
+	for _, code := range codes {
+		code = strings.TrimSpace(code)
+		if code == "" {
+			continue
+		}
+		if containsString(result, code) {
+			return nil, apperror.Validation(fmt.Sprintf("promo code %s is specified more than once", code), nil)
+		}
+		result = append(result, code)
+	}
+	if len(result) > s.maxCodes {
+		return nil, apperror.Validation(fmt.Sprintf("at most %d promo codes per order are allowed", s.maxCodes), nil)
+	}
+	return result, nil
+}
+
+// stackingRank задает порядок применения скидок разных типов.
+func (s *PromoService) stackingRank(discountType models.DiscountType) int {
+	switch discountType {
+	case models.DiscountTypeFreeDelivery:
+		return 0
+	case models.DiscountTypeFixed:
+		if s.percentFirst {
+			return 2
+		}
+		return 1
+	case models.DiscountTypePercent:
+		if s.percentFirst {
+			return 1
+		}
+		return 2
+	default:
+		return 3
+	}
+}
+
+// orderPromo — заблокированный и проверенный промокод заказа.
+type orderPromo struct {
+	code         string
+	discountType models.DiscountType
+	amount       money.Money
+	currency     string
+	stacking     models.PromoStacking
+	rules        models.PromoRules
+}
+
+// loadOrderPromo читает промокод и проверяет, что его можно применить к заказу. С lock строка
+// блокируется до конца транзакции; без него — только чтение для предпросмотра.
+func loadOrderPromo(ctx context.Context, tx *sql.Tx, code string, order PromoOrder, now time.Time, lock bool) (*orderPromo, error) {
 	query := `
-		SELECT discount_type, amount, max_uses, used_count, expires_at, active
+		SELECT discount_type, amount, currency, max_uses, used_count, expires_at, active, rules, stacking
 		FROM promo_codes
 		WHERE code = $1
-		FOR UPDATE
 	`
+	if lock {
+		query += ` FOR UPDATE`
+	}
 
 	var (
-		discountType models.DiscountType
-		amount       float64
-		maxUses      int
-		usedCount    int
-		expiresAt    *time.Time
-		active       bool
+		promo     = &orderPromo{code: code}
+		maxUses   int
+		usedCount int
+		expiresAt *time.Time
+		active    bool
+		rawRules  []byte
 	)
 
-	if err := tx.QueryRowContext(ctx, query, code).Scan(&discountType, &amount, &maxUses, &usedCount, &expiresAt, &active); err != nil {
+	if err := tx.QueryRowContext(ctx, query, code).Scan(&promo.discountType, &promo.amount, &promo.currency, &maxUses, &usedCount,
+		&expiresAt, &active, &rawRules, &promo.stacking); err != nil {
 		if err == sql.ErrNoRows {
-			return 0, apperror.NotFound("promo code not found", err)
+			return nil, apperror.NotFound(fmt.Sprintf("promo code %s not found", code), &promoRejection{reason: models.PromoReasonNotFound})
 		}
-		return 0, fmt.Errorf("failed to get promo code: %w", err)
+		return nil, fmt.Errorf("failed to get promo code: %w", err)
 	}
 
 	if !active {
-		return 0, apperror.Conflict("promo code is inactive", nil)
+		return nil, rejectPromo(models.PromoReasonInactive, "promo code is inactive")
 	}
 
-	if expiresAt != nil && expiresAt.Before(time.Now()) {
-		return 0, apperror.Conflict("promo code expired", nil)
+	if expiresAt != nil && expiresAt.Before(now) {
+		return nil, rejectPromo(models.PromoReasonExpired, "promo code expired")
 	}
 
 	if maxUses > 0 && usedCount >= maxUses {
-		return 0, apperror.Conflict("promo code usage limit reached", nil)
+		return nil, rejectPromo(models.PromoReasonUsageLimit, "promo code usage limit reached")
 	}
 
-	totalBase := itemsTotal + deliveryCost
-	discount := calculateDiscount(discountType, amount, totalBase, deliveryCost)
+	rules, err := decodePromoRules(rawRules, promo.currency)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkPromoRules(&rules, order, now); err != nil {
+		return nil, err
+	}
+	if err := checkCustomerPromoRules(ctx, tx, code, &rules, order); err != nil {
+		return nil, err
+	}
+	promo.rules = rules
+	promo.amount = promo.amount.In(promo.currency)
 
+	return promo, nil
+}
+
+// discount рассчитывает скидку от оставшейся суммы заказа после ранее примененных кодов.
+func (p *orderPromo) discount(remaining, deliveryLeft money.Money) (money.Money, error) {
+	if p.discountType == models.DiscountTypeFixed && !remaining.SameCurrency(p.amount) {
+		return money.Money{}, rejectPromo(models.PromoReasonCurrencyMismatch, "promo code currency does not match order currency")
+	}
+	discount := calculateDiscount(p.discountType, p.amount.In(remaining.Currency), remaining, deliveryLeft)
+	if p.discountType == models.DiscountTypePercent && p.rules.MaxDiscount != nil {
+		if !discount.SameCurrency(*p.rules.MaxDiscount) {
+			return money.Money{}, rejectPromo(models.PromoReasonCurrencyMismatch, "promo code currency does not match order currency")
+		}
+		discount = money.Min(discount, *p.rules.MaxDiscount)
+	}
+	return money.Min(discount, remaining), nil
+}
+
+// recordPromoRedemption увеличивает счётчик использования кода и записывает применение.
+func recordPromoRedemption(ctx context.Context, tx *sql.Tx, r *models.PromoRedemption) error {
 	updateQuery := `
 		UPDATE promo_codes
 		SET used_count = used_count + 1, updated_at = $1
 		WHERE code = $2
 	`
-	if _, err := tx.ExecContext(ctx, updateQuery, time.Now(), code); err != nil {
-		return 0, fmt.Errorf("failed to update promo usage: %w", err)
+	if _, err := tx.ExecContext(ctx, updateQuery, r.RedeemedAt, r.Code); err != nil {
+		return fmt.Errorf("failed to update promo usage: %w", err)
 	}
 
-	return discount, nil
+	// Повторное применение кода к тому же заказу (после изменения заказа) восстанавливает отмененную запись
+	insertQuery := `
+		INSERT INTO promo_redemptions (id, code, order_id, customer_phone, discount_type, discount_amount, currency, position, redeemed_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (order_id, code) DO UPDATE
+		SET discount_type = EXCLUDED.discount_type, discount_amount = EXCLUDED.discount_amount, currency = EXCLUDED.currency,
+			position = EXCLUDED.position, redeemed_at = EXCLUDED.redeemed_at, reversed_at = NULL
+	`
+	if _, err := tx.ExecContext(ctx, insertQuery, r.ID, r.Code, r.OrderID, r.CustomerPhone, r.DiscountType, r.Discount,
+		r.Currency, r.Position, r.RedeemedAt); err != nil {
+		return fmt.Errorf("failed to record promo redemption: %w", err)
+	}
+	return nil
 }
 
-func calculateDiscount(discountType models.DiscountType, amount, baseTotal, deliveryCost float64) float64 {
+// calculateDiscount возвращает скидку не больше базы. Для percent amount задает процент.
+func calculateDiscount(discountType models.DiscountType, amount, baseTotal, deliveryCost money.Money) money.Money {
+	zero := money.Zero(baseTotal.Currency)
 	switch discountType {
 	case models.DiscountTypeFixed:
-		if amount < 0 {
-			return 0
+		if amount.IsNegative() {
+			return zero
 		}
-		if amount > baseTotal {
-			return baseTotal
-		}
-		return round2(amount)
+		return money.Min(amount, baseTotal)
 	case models.DiscountTypePercent:
-		if amount <= 0 {
-			return 0
-		}
-		if amount > 100 {
-			amount = 100
+		if !amount.IsPositive() {
+			return zero
 		}
-		return round2(baseTotal * amount / 100.0)
+		percent := math.Min(amount.Float64(), 100)
+		return baseTotal.Percent(percent)
 	case models.DiscountTypeFreeDelivery:
-		if deliveryCost < 0 {
-			return 0
+		if deliveryCost.IsNegative() {
+			return zero
 		}
-		return round2(deliveryCost)
+		return deliveryCost
 	default:
-		return 0
+		return zero
 	}
 }
 
-func validatePromoCodePayload(discountType models.DiscountType, amount float64) error {
+func validatePromoCodePayload(discountType models.DiscountType, amount money.Money) error {
 	switch discountType {
 	case models.DiscountTypeFixed:
-		if amount < 0 {
+		if amount.IsNegative() {
 			return fmt.Errorf("amount must be non-negative for fixed discount")
 		}
 	case models.DiscountTypePercent:
-		if amount <= 0 || amount > 100 {
+		if !amount.IsPositive() || amount.Float64() > 100 {
 			return fmt.Errorf("percent amount must be between 0 and 100")
 		}
 	case models.DiscountTypeFreeDelivery:
@@ -262,6 +720,14 @@ func validatePromoCodePayload(discountType models.DiscountType, amount float64)
 	return nil
 }
 
-func round2(v float64) float64 {
-	return math.Round(v*100) / 100
+// normalizePromoStacking проверяет режим сочетания; пустой означает exclusive.
+func normalizePromoStacking(stacking models.PromoStacking) (models.PromoStacking, error) {
+	switch stacking {
+	case "":
+		return models.PromoStackingExclusive, nil
+	case models.PromoStackingExclusive, models.PromoStackingCombinable:
+		return stacking, nil
+	default:
+		return "", fmt.Errorf("stacking must be one of: exclusive, combinable")
+	}
 }
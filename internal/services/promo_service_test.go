@@ -7,9 +7,13 @@ import (
 	"testing"
 	"time"
 
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/config"
 	"delivery-system/internal/models"
+	"delivery-system/internal/pagination"
 
 	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
 )
 
 func TestPromoService_ApplyPercent(t *testing.T) {
@@ -17,19 +21,21 @@ func TestPromoService_ApplyPercent(t *testing.T) {
 	defer db.Close()
 
 	log := newTestLogger()
-	service := NewPromoService(db, log)
+	service := NewPromoService(db, log, nil, nil)
 
 	code := "SALE10"
 
 	mock.ExpectBegin()
-	mock.ExpectQuery("SELECT discount_type, amount, max_uses, used_count, expires_at, active FROM promo_codes").
+	mock.ExpectQuery("SELECT discount_type, amount, currency, max_uses, used_count, expires_at, active, rules, stacking FROM promo_codes").
 		WithArgs(code).
-		WillReturnRows(sqlmock.NewRows([]string{"discount_type", "amount", "max_uses", "used_count", "expires_at", "active"}).
-			AddRow(models.DiscountTypePercent, 10.0, 5, 1, nil, true))
+		WillReturnRows(sqlmock.NewRows([]string{"discount_type", "amount", "currency", "max_uses", "used_count", "expires_at", "active", "rules", "stacking"}).
+			AddRow(models.DiscountTypePercent, 10.0, "RUB", 5, 1, nil, true, "{}", "exclusive"))
 
 	mock.ExpectExec("UPDATE promo_codes").
 		WithArgs(sqlmock.AnyArg(), code).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO promo_redemptions").
+		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
 	tx, err := db.Begin()
@@ -37,7 +43,7 @@ func TestPromoService_ApplyPercent(t *testing.T) {
 		t.Fatalf("failed to begin tx: %v", err)
 	}
 
-	discount, err := service.ApplyPromoWithTx(context.Background(), tx, code, 200, 50)
+	application, err := service.ApplyPromoWithTx(context.Background(), tx, []string{code}, PromoOrder{ItemsTotal: rub(200), DeliveryCost: rub(50)})
 	if err != nil {
 		t.Fatalf("expected success, got error: %v", err)
 	}
@@ -46,8 +52,8 @@ func TestPromoService_ApplyPercent(t *testing.T) {
 		t.Fatalf("commit failed: %v", err)
 	}
 
-	if discount != 25.0 {
-		t.Fatalf("expected discount 25.0, got %.2f", discount)
+	if application.Discount != rub(25) {
+		t.Fatalf("expected discount 25.00, got %s", application.Discount)
 	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -60,30 +66,32 @@ func TestPromoService_ApplyFreeDelivery(t *testing.T) {
 	defer db.Close()
 
 	log := newTestLogger()
-	service := NewPromoService(db, log)
+	service := NewPromoService(db, log, nil, nil)
 
 	code := "FREEDEL"
 
 	mock.ExpectBegin()
-	mock.ExpectQuery("SELECT discount_type, amount, max_uses, used_count, expires_at, active FROM promo_codes").
+	mock.ExpectQuery("SELECT discount_type, amount, currency, max_uses, used_count, expires_at, active, rules, stacking FROM promo_codes").
 		WithArgs(code).
-		WillReturnRows(sqlmock.NewRows([]string{"discount_type", "amount", "max_uses", "used_count", "expires_at", "active"}).
-			AddRow(models.DiscountTypeFreeDelivery, 0.0, 0, 0, nil, true))
+		WillReturnRows(sqlmock.NewRows([]string{"discount_type", "amount", "currency", "max_uses", "used_count", "expires_at", "active", "rules", "stacking"}).
+			AddRow(models.DiscountTypeFreeDelivery, 0.0, "RUB", 0, 0, nil, true, "{}", "exclusive"))
 
 	mock.ExpectExec("UPDATE promo_codes").
 		WithArgs(sqlmock.AnyArg(), code).
 		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO promo_redemptions").
+		WillReturnResult(sqlmock.NewResult(1, 1))
 	mock.ExpectCommit()
 
 	tx, _ := db.Begin()
-	discount, err := service.ApplyPromoWithTx(context.Background(), tx, code, 120, 80)
+	application, err := service.ApplyPromoWithTx(context.Background(), tx, []string{code}, PromoOrder{ItemsTotal: rub(120), DeliveryCost: rub(80)})
 	if err != nil {
 		t.Fatalf("expected success, got error: %v", err)
 	}
 	_ = tx.Commit()
 
-	if discount != 80 {
-		t.Fatalf("expected discount 80, got %.2f", discount)
+	if application.Discount != rub(80) {
+		t.Fatalf("expected discount 80.00, got %s", application.Discount)
 	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -96,21 +104,21 @@ func TestPromoService_ApplyPromo_Expired(t *testing.T) {
 	defer db.Close()
 
 	log := newTestLogger()
-	service := NewPromoService(db, log)
+	service := NewPromoService(db, log, nil, nil)
 
 	code := "OLD"
 	expired := time.Now().Add(-time.Hour)
 
 	mock.ExpectBegin()
-	mock.ExpectQuery("SELECT discount_type, amount, max_uses, used_count, expires_at, active FROM promo_codes").
+	mock.ExpectQuery("SELECT discount_type, amount, currency, max_uses, used_count, expires_at, active, rules, stacking FROM promo_codes").
 		WithArgs(code).
-		WillReturnRows(sqlmock.NewRows([]string{"discount_type", "amount", "max_uses", "used_count", "expires_at", "active"}).
-			AddRow(models.DiscountTypeFixed, 50.0, 0, 0, expired, true))
+		WillReturnRows(sqlmock.NewRows([]string{"discount_type", "amount", "currency", "max_uses", "used_count", "expires_at", "active", "rules", "stacking"}).
+			AddRow(models.DiscountTypeFixed, 50.0, "RUB", 0, 0, expired, true, "{}", "exclusive"))
 	// Expect rollback due to error
 	mock.ExpectRollback()
 
 	tx, _ := db.Begin()
-	if _, err := service.ApplyPromoWithTx(context.Background(), tx, code, 100, 20); err == nil {
+	if _, err := service.ApplyPromoWithTx(context.Background(), tx, []string{code}, PromoOrder{ItemsTotal: rub(100), DeliveryCost: rub(20)}); err == nil {
 		t.Fatalf("expected error for expired promo")
 	}
 	_ = tx.Rollback()
@@ -125,19 +133,19 @@ func TestPromoService_ApplyPromo_LimitReached(t *testing.T) {
 	defer db.Close()
 
 	log := newTestLogger()
-	service := NewPromoService(db, log)
+	service := NewPromoService(db, log, nil, nil)
 
 	code := "USED"
 
 	mock.ExpectBegin()
-	mock.ExpectQuery("SELECT discount_type, amount, max_uses, used_count, expires_at, active FROM promo_codes").
+	mock.ExpectQuery("SELECT discount_type, amount, currency, max_uses, used_count, expires_at, active, rules, stacking FROM promo_codes").
 		WithArgs(code).
-		WillReturnRows(sqlmock.NewRows([]string{"discount_type", "amount", "max_uses", "used_count", "expires_at", "active"}).
-			AddRow(models.DiscountTypeFixed, 50.0, 1, 1, nil, true))
+		WillReturnRows(sqlmock.NewRows([]string{"discount_type", "amount", "currency", "max_uses", "used_count", "expires_at", "active", "rules", "stacking"}).
+			AddRow(models.DiscountTypeFixed, 50.0, "RUB", 1, 1, nil, true, "{}", "exclusive"))
 	mock.ExpectRollback()
 
 	tx, _ := db.Begin()
-	if _, err := service.ApplyPromoWithTx(context.Background(), tx, code, 100, 20); err == nil {
+	if _, err := service.ApplyPromoWithTx(context.Background(), tx, []string{code}, PromoOrder{ItemsTotal: rub(100), DeliveryCost: rub(20)}); err == nil {
 		t.Fatalf("expected error for usage limit")
 	}
 	_ = tx.Rollback()
@@ -151,13 +159,13 @@ func TestPromoService_CreateUpdateDeleteAndList(t *testing.T) {
 	db, mock := newMockDB(t)
 	defer db.Close()
 	log := newTestLogger()
-	service := NewPromoService(db, log)
+	service := NewPromoService(db, log, nil, nil)
 
 	mock.ExpectExec("INSERT INTO promo_codes").WillReturnResult(sqlmock.NewResult(1, 1))
 	promo, err := service.CreatePromoCode(context.Background(), &models.CreatePromoCodeRequest{
 		Code:         "NEW",
 		DiscountType: models.DiscountTypePercent,
-		Amount:       10,
+		Amount:       rub(10),
 		MaxUses:      5,
 		ExpiresAt:    nil,
 		Active:       true,
@@ -171,17 +179,17 @@ func TestPromoService_CreateUpdateDeleteAndList(t *testing.T) {
 	expAt := time.Now()
 	mock.ExpectQuery("SELECT code, discount_type").
 		WithArgs("NEW").
-		WillReturnRows(sqlmock.NewRows([]string{"code", "discount_type", "amount", "max_uses", "used_count", "expires_at", "active", "created_at", "updated_at"}).
-			AddRow("NEW", models.DiscountTypePercent, 15.0, 10, 0, &expAt, true, time.Now(), time.Now()))
+		WillReturnRows(sqlmock.NewRows([]string{"code", "discount_type", "amount", "currency", "max_uses", "used_count", "expires_at", "active", "rules", "stacking", "campaign_id", "created_at", "updated_at"}).
+			AddRow("NEW", models.DiscountTypePercent, 15.0, "RUB", 10, 0, &expAt, true, "{}", "exclusive", nil, time.Now(), time.Now()))
 
 	updated, err := service.UpdatePromoCode(context.Background(), "NEW", &models.UpdatePromoCodeRequest{
 		DiscountType: models.DiscountTypePercent,
-		Amount:       15,
+		Amount:       rub(15),
 		MaxUses:      10,
 		ExpiresAt:    &expAt,
 		Active:       true,
 	})
-	if err != nil || updated.Amount != 15 {
+	if err != nil || updated.Amount != rub(15) {
 		t.Fatalf("update failed: %v", err)
 	}
 
@@ -193,12 +201,12 @@ func TestPromoService_CreateUpdateDeleteAndList(t *testing.T) {
 	}
 
 	mock.ExpectQuery("SELECT code, discount_type").
-		WillReturnRows(sqlmock.NewRows([]string{"code", "discount_type", "amount", "max_uses", "used_count", "expires_at", "active", "created_at", "updated_at"}).
-			AddRow("A", models.DiscountTypeFixed, 5.0, 0, 0, time.Now(), true, time.Now(), time.Now()).
-			AddRow("B", models.DiscountTypePercent, 10.0, 0, 0, time.Now(), true, time.Now(), time.Now()))
-	list, err := service.ListPromoCodes(context.Background(), 0, 0)
-	if err != nil || len(list) != 2 {
-		t.Fatalf("list failed: %v len=%d", err, len(list))
+		WillReturnRows(sqlmock.NewRows([]string{"code", "discount_type", "amount", "currency", "max_uses", "used_count", "expires_at", "active", "rules", "stacking", "campaign_id", "created_at", "updated_at"}).
+			AddRow("A", models.DiscountTypeFixed, 5.0, "RUB", 0, 0, time.Now(), true, "{}", "exclusive", nil, time.Now(), time.Now()).
+			AddRow("B", models.DiscountTypePercent, 10.0, "RUB", 0, 0, time.Now(), true, "{}", "exclusive", nil, time.Now(), time.Now()))
+	list, err := service.ListPromoCodes(context.Background(), pagination.Request{})
+	if err != nil || len(list.Items) != 2 {
+		t.Fatalf("list failed: %v", err)
 	}
 
 	if err := mock.ExpectationsWereMet(); err != nil {
@@ -210,28 +218,90 @@ func TestPromoService_CreatePromoCode_InvalidPayload(t *testing.T) {
 	db, _ := newMockDB(t)
 	defer db.Close()
 
-	service := NewPromoService(db, newTestLogger())
+	service := NewPromoService(db, newTestLogger(), nil, nil)
 	if _, err := service.CreatePromoCode(context.Background(), &models.CreatePromoCodeRequest{
 		Code:         "BAD",
 		DiscountType: models.DiscountTypePercent,
-		Amount:       150,
+		Amount:       rub(150),
 	}); err == nil {
 		t.Fatalf("expected validation error")
 	}
 }
 
+func TestPromoService_MaxDiscountCurrency(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	pricing, err := NewRegionalPricingService(models.Region{Currency: "RUB", BaseFare: 100, PerKm: 20, MinFare: 150}, []models.Region{{
+		Code:     "de-berlin",
+		Currency: "EUR",
+		BaseFare: 3,
+		PerKm:    1,
+		MinFare:  5,
+		Bounds:   &models.GeoBounds{MinLat: 52.3, MinLon: 13.0, MaxLat: 52.7, MaxLon: 13.8},
+		Payout:   &models.RegionPayout{PerDelivery: 3, PerKm: 0.5, MinPayout: 4},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected pricing error: %v", err)
+	}
+	service := NewPromoService(db, newTestLogger(), pricing, nil)
+
+	// Потолок в рублях не сравнить со скидкой в евро: промокод без регионов отклоняется
+	_, err = service.CreatePromoCode(context.Background(), &models.CreatePromoCodeRequest{
+		Code:         "CAPPED",
+		DiscountType: models.DiscountTypePercent,
+		Amount:       rub(10),
+		Active:       true,
+		Rules:        models.PromoRules{MaxDiscount: moneyPtr(rub(300))},
+	})
+	if !apperror.Is(err, apperror.KindValidation) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+
+	mock.ExpectExec("INSERT INTO promo_codes").WillReturnResult(sqlmock.NewResult(1, 1))
+	if _, err := service.CreatePromoCode(context.Background(), &models.CreatePromoCodeRequest{
+		Code:         "BERLIN",
+		DiscountType: models.DiscountTypePercent,
+		Amount:       rub(10),
+		Currency:     "EUR",
+		Active:       true,
+		Rules:        models.PromoRules{Regions: []string{"de-berlin"}, MaxDiscount: moneyPtr(rub(20))},
+	}); err != nil {
+		t.Fatalf("expected EUR cap limited to Berlin to be valid, got %v", err)
+	}
+
+	// При изменении потолок сверяется с валютой сохраненного промокода
+	mock.ExpectQuery("SELECT code, discount_type").
+		WithArgs("BERLIN").
+		WillReturnRows(sqlmock.NewRows([]string{"code", "discount_type", "amount", "currency", "max_uses", "used_count", "expires_at", "active", "rules", "stacking", "campaign_id", "created_at", "updated_at"}).
+			AddRow("BERLIN", models.DiscountTypePercent, 10.0, "EUR", 0, 0, nil, true, `{"regions":["de-berlin"],"max_discount":"20"}`, "exclusive", nil, time.Now(), time.Now()))
+	_, err = service.UpdatePromoCode(context.Background(), "BERLIN", &models.UpdatePromoCodeRequest{
+		DiscountType: models.DiscountTypePercent,
+		Amount:       rub(10),
+		Active:       true,
+		Rules:        models.PromoRules{Regions: []string{"default", "de-berlin"}, MaxDiscount: moneyPtr(rub(20))},
+	})
+	if !apperror.Is(err, apperror.KindValidation) {
+		t.Fatalf("expected validation error on update, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
 func TestPromoService_UpdatePromoCode_NotFound(t *testing.T) {
 	db, mock := newMockDB(t)
 	defer db.Close()
 
-	service := NewPromoService(db, newTestLogger())
+	service := NewPromoService(db, newTestLogger(), nil, nil)
 
 	mock.ExpectExec("UPDATE promo_codes").
 		WillReturnResult(sqlmock.NewResult(0, 0))
 
 	_, err := service.UpdatePromoCode(context.Background(), "MISS", &models.UpdatePromoCodeRequest{
 		DiscountType: models.DiscountTypeFixed,
-		Amount:       10,
+		Amount:       rub(10),
 		Active:       true,
 	})
 	if err == nil {
@@ -243,14 +313,14 @@ func TestPromoService_UpdatePromoCode_RowsAffectedError(t *testing.T) {
 	db, mock := newMockDB(t)
 	defer db.Close()
 
-	service := NewPromoService(db, newTestLogger())
+	service := NewPromoService(db, newTestLogger(), nil, nil)
 
 	mock.ExpectExec("UPDATE promo_codes").
 		WillReturnResult(sqlmock.NewErrorResult(errors.New("rows affected error")))
 
 	if _, err := service.UpdatePromoCode(context.Background(), "X", &models.UpdatePromoCodeRequest{
 		DiscountType: models.DiscountTypeFixed,
-		Amount:       10,
+		Amount:       rub(10),
 		Active:       true,
 	}); err == nil {
 		t.Fatalf("expected rows affected error")
@@ -261,7 +331,7 @@ func TestPromoService_DeletePromoCode_NotFound(t *testing.T) {
 	db, mock := newMockDB(t)
 	defer db.Close()
 
-	service := NewPromoService(db, newTestLogger())
+	service := NewPromoService(db, newTestLogger(), nil, nil)
 
 	mock.ExpectExec("DELETE FROM promo_codes").
 		WithArgs("MISS").
@@ -276,7 +346,7 @@ func TestPromoService_DeletePromoCode_RowsAffectedError(t *testing.T) {
 	db, mock := newMockDB(t)
 	defer db.Close()
 
-	service := NewPromoService(db, newTestLogger())
+	service := NewPromoService(db, newTestLogger(), nil, nil)
 
 	mock.ExpectExec("DELETE FROM promo_codes").
 		WithArgs("X").
@@ -291,7 +361,7 @@ func TestPromoService_GetPromoCode_NotFound(t *testing.T) {
 	db, mock := newMockDB(t)
 	defer db.Close()
 	log := newTestLogger()
-	service := NewPromoService(db, log)
+	service := NewPromoService(db, log, nil, nil)
 
 	mock.ExpectQuery("SELECT code, discount_type").
 		WithArgs("MISS").
@@ -303,40 +373,382 @@ func TestPromoService_GetPromoCode_NotFound(t *testing.T) {
 }
 
 func TestValidatePromoCodePayload(t *testing.T) {
-	if err := validatePromoCodePayload(models.DiscountTypeFixed, -1); err == nil {
+	if err := validatePromoCodePayload(models.DiscountTypeFixed, rub(-1)); err == nil {
 		t.Fatalf("expected error for negative amount")
 	}
-	if err := validatePromoCodePayload("unknown", 10); err == nil {
+	if err := validatePromoCodePayload("unknown", rub(10)); err == nil {
 		t.Fatalf("expected error for invalid type")
 	}
-	if err := validatePromoCodePayload(models.DiscountTypePercent, 150); err == nil {
+	if err := validatePromoCodePayload(models.DiscountTypePercent, rub(150)); err == nil {
 		t.Fatalf("expected error for >100 percent")
 	}
-	if err := validatePromoCodePayload(models.DiscountTypePercent, 50); err != nil {
+	if err := validatePromoCodePayload(models.DiscountTypePercent, rub(50)); err != nil {
 		t.Fatalf("expected valid percent, got %v", err)
 	}
 }
 
 func TestCalculateDiscount(t *testing.T) {
-	if v := calculateDiscount(models.DiscountTypeFixed, 10, 100, 20); v != 10 {
-		t.Fatalf("expected fixed 10, got %v", v)
+	if v := calculateDiscount(models.DiscountTypeFixed, rub(10), rub(100), rub(20)); v != rub(10) {
+		t.Fatalf("expected fixed 10, got %s", v)
 	}
-	if v := calculateDiscount(models.DiscountTypePercent, 10, 200, 0); v != 20 {
-		t.Fatalf("expected percent 20, got %v", v)
+	if v := calculateDiscount(models.DiscountTypePercent, rub(10), rub(200), rub(0)); v != rub(20) {
+		t.Fatalf("expected percent 20, got %s", v)
 	}
-	if v := calculateDiscount(models.DiscountTypeFreeDelivery, 0, 100, 30); v != 30 {
-		t.Fatalf("expected free delivery 30, got %v", v)
+	if v := calculateDiscount(models.DiscountTypeFreeDelivery, rub(0), rub(100), rub(30)); v != rub(30) {
+		t.Fatalf("expected free delivery 30, got %s", v)
 	}
 }
 
 func TestCalculateDiscount_EdgeCases(t *testing.T) {
-	if v := calculateDiscount(models.DiscountTypeFixed, -5, 100, 10); v != 0 {
-		t.Fatalf("expected zero for negative fixed discount, got %v", v)
+	if v := calculateDiscount(models.DiscountTypeFixed, rub(-5), rub(100), rub(10)); v != rub(0) {
+		t.Fatalf("expected zero for negative fixed discount, got %s", v)
+	}
+	if v := calculateDiscount(models.DiscountTypePercent, rub(150), rub(100), rub(0)); v != rub(100) {
+		t.Fatalf("expected capped percent discount, got %s", v)
+	}
+	if v := calculateDiscount(models.DiscountType("unknown"), rub(10), rub(100), rub(10)); v != rub(0) {
+		t.Fatalf("expected zero for unknown type, got %s", v)
+	}
+}
+
+func TestPromoService_ApplyPromo_CurrencyMismatch(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewPromoService(db, newTestLogger(), nil, nil)
+	code := "EUR5"
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT discount_type, amount, currency, max_uses, used_count, expires_at, active, rules, stacking FROM promo_codes").
+		WithArgs(code).
+		WillReturnRows(sqlmock.NewRows([]string{"discount_type", "amount", "currency", "max_uses", "used_count", "expires_at", "active", "rules", "stacking"}).
+			AddRow(models.DiscountTypeFixed, 5.0, "EUR", 0, 0, nil, true, "{}", "exclusive"))
+	mock.ExpectRollback()
+
+	tx, _ := db.Begin()
+	_, err := service.ApplyPromoWithTx(context.Background(), tx, []string{code}, PromoOrder{ItemsTotal: rub(100), DeliveryCost: rub(20)})
+	if !apperror.Is(err, apperror.KindConflict) {
+		t.Fatalf("expected conflict for currency mismatch, got %v", err)
+	}
+	_ = tx.Rollback()
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestPromoService_ApplyPromo_PerCustomerLimit(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewPromoService(db, newTestLogger(), nil, nil)
+	code := "ONCE"
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT discount_type, amount, currency, max_uses, used_count, expires_at, active, rules, stacking FROM promo_codes").
+		WithArgs(code).
+		WillReturnRows(sqlmock.NewRows([]string{"discount_type", "amount", "currency", "max_uses", "used_count", "expires_at", "active", "rules", "stacking"}).
+			AddRow(models.DiscountTypeFixed, 50.0, "RUB", 0, 3, nil, true, `{"max_uses_per_customer":1}`, "exclusive"))
+	mock.ExpectQuery("SELECT COUNT\\(\\*\\) FROM promo_redemptions").
+		WithArgs(code, "79991234567").
+		WillReturnRows(sqlmock.NewRows([]string{"count"}).AddRow(1))
+	mock.ExpectRollback()
+
+	tx, _ := db.Begin()
+	_, err := service.ApplyPromoWithTx(context.Background(), tx, []string{code}, PromoOrder{CustomerPhone: "+7 999 123-45-67", ItemsTotal: rub(100), DeliveryCost: rub(20)})
+	if !apperror.Is(err, apperror.KindConflict) || err.Error() != "promo code usage limit per customer reached" {
+		t.Fatalf("expected per-customer conflict, got %v", err)
+	}
+	_ = tx.Rollback()
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestPromoService_ApplyPromo_FirstOrderAndDiscountCap(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewPromoService(db, newTestLogger(), nil, nil)
+	code := "WELCOME"
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT discount_type, amount, currency, max_uses, used_count, expires_at, active, rules, stacking FROM promo_codes").
+		WithArgs(code).
+		WillReturnRows(sqlmock.NewRows([]string{"discount_type", "amount", "currency", "max_uses", "used_count", "expires_at", "active", "rules", "stacking"}).
+			AddRow(models.DiscountTypePercent, 50.0, "RUB", 0, 0, nil, true, `{"first_order_only":true,"max_discount":150}`, "exclusive"))
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs("79991234567", sqlmock.AnyArg()).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectExec("UPDATE promo_codes").
+		WithArgs(sqlmock.AnyArg(), code).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO promo_redemptions").
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	tx, _ := db.Begin()
+	application, err := service.ApplyPromoWithTx(context.Background(), tx, []string{code}, PromoOrder{CustomerPhone: "79991234567", ItemsTotal: rub(800), DeliveryCost: rub(200)})
+	if err != nil {
+		t.Fatalf("expected success, got error: %v", err)
 	}
-	if v := calculateDiscount(models.DiscountTypePercent, 150, 100, 0); v != 100 {
-		t.Fatalf("expected capped percent discount, got %v", v)
+	_ = tx.Commit()
+
+	if application.Discount != rub(150) {
+		t.Fatalf("expected discount capped at 150.00, got %s", application.Discount)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
 	}
-	if v := calculateDiscount(models.DiscountType("unknown"), 10, 100, 10); v != 0 {
-		t.Fatalf("expected zero for unknown type, got %v", v)
+}
+
+func promoLockRows(discountType models.DiscountType, amount float64, stacking models.PromoStacking) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{"discount_type", "amount", "currency", "max_uses", "used_count", "expires_at", "active", "rules", "stacking"}).
+		AddRow(discountType, amount, "RUB", 0, 0, nil, true, "{}", stacking)
+}
+
+func TestPromoService_ApplyPromo_StackingOrder(t *testing.T) {
+	cases := []struct {
+		order    string
+		first    string
+		second   string
+		expected int64
+	}{
+		{order: PromoStackingFixedFirst, first: "FIX100", second: "PCT10", expected: 190},
+		{order: PromoStackingPercentFirst, first: "PCT10", second: "FIX100", expected: 200},
+	}
+
+	for _, tc := range cases {
+		db, mock := newMockDB(t)
+		service := NewPromoService(db, newTestLogger(), nil, &config.PromoConfig{StackingOrder: tc.order})
+
+		mock.ExpectBegin()
+		// Строки блокируются в алфавитном порядке кодов
+		mock.ExpectQuery("SELECT discount_type, amount").WithArgs("FIX100").
+			WillReturnRows(promoLockRows(models.DiscountTypeFixed, 100, models.PromoStackingCombinable))
+		mock.ExpectQuery("SELECT discount_type, amount").WithArgs("PCT10").
+			WillReturnRows(promoLockRows(models.DiscountTypePercent, 10, models.PromoStackingCombinable))
+		for i, code := range []string{tc.first, tc.second} {
+			mock.ExpectExec("UPDATE promo_codes").WithArgs(sqlmock.AnyArg(), code).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+			mock.ExpectExec("INSERT INTO promo_redemptions").
+				WithArgs(sqlmock.AnyArg(), code, sqlmock.AnyArg(), "79991234567", sqlmock.AnyArg(), sqlmock.AnyArg(), "RUB", i, sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(0, 1))
+		}
+		mock.ExpectCommit()
+
+		tx, _ := db.Begin()
+		application, err := service.ApplyPromoWithTx(context.Background(), tx, []string{"PCT10", "FIX100"},
+			PromoOrder{OrderID: uuid.New(), CustomerPhone: "+7 999 123-45-67", ItemsTotal: rub(900), DeliveryCost: rub(100)})
+		if err != nil {
+			t.Fatalf("%s: expected success, got %v", tc.order, err)
+		}
+		_ = tx.Commit()
+
+		if application.Discount != rub(float64(tc.expected)) || len(application.Redemptions) != 2 || application.Redemptions[0].Code != tc.first {
+			t.Fatalf("%s: unexpected application: %+v", tc.order, application)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatalf("%s: unmet expectations: %v", tc.order, err)
+		}
+		db.Close()
+	}
+}
+
+func TestPromoService_ApplyPromo_ExclusiveCannotBeCombined(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewPromoService(db, newTestLogger(), nil, nil)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT discount_type, amount").WithArgs("A").
+		WillReturnRows(promoLockRows(models.DiscountTypeFixed, 50, models.PromoStackingCombinable))
+	mock.ExpectQuery("SELECT discount_type, amount").WithArgs("B").
+		WillReturnRows(promoLockRows(models.DiscountTypeFixed, 50, models.PromoStackingExclusive))
+	mock.ExpectRollback()
+
+	tx, _ := db.Begin()
+	_, err := service.ApplyPromoWithTx(context.Background(), tx, []string{"B", "A"}, PromoOrder{ItemsTotal: rub(500), DeliveryCost: rub(100)})
+	if !apperror.Is(err, apperror.KindConflict) || err.Error() != "promo code B cannot be combined with other promo codes" {
+		t.Fatalf("expected stacking conflict, got %v", err)
+	}
+	_ = tx.Rollback()
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestPromoService_ApplyPromo_InvalidCodeList(t *testing.T) {
+	db, _ := newMockDB(t)
+	defer db.Close()
+
+	service := NewPromoService(db, newTestLogger(), nil, &config.PromoConfig{MaxCodesPerOrder: 2})
+
+	if _, err := service.ApplyPromoWithTx(context.Background(), nil, []string{"A", " A "}, PromoOrder{}); !apperror.Is(err, apperror.KindValidation) {
+		t.Fatalf("expected validation error for duplicate code, got %v", err)
+	}
+	if _, err := service.ApplyPromoWithTx(context.Background(), nil, []string{"A", "B", "C"}, PromoOrder{}); !apperror.Is(err, apperror.KindValidation) {
+		t.Fatalf("expected validation error for too many codes, got %v", err)
+	}
+}
+
+func TestPromoService_ReverseRedemptionsWithTx(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewPromoService(db, newTestLogger(), nil, nil)
+	orderID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectExec("WITH reversed AS \\(\\s+UPDATE promo_redemptions").
+		WithArgs(orderID, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+
+	tx, _ := db.Begin()
+	if err := service.ReverseRedemptionsWithTx(context.Background(), tx, orderID); err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	_ = tx.Commit()
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestPromoService_ListRedemptions(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewPromoService(db, newTestLogger(), nil, nil)
+	orderID := uuid.New()
+	reversedAt := time.Now()
+
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs("SALE").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	columns := []string{"id", "code", "order_id", "customer_phone", "discount_type", "discount_amount", "currency", "position", "redeemed_at", "reversed_at"}
+	lastID, lastAt := uuid.New(), time.Now().Add(-time.Hour)
+	mock.ExpectQuery("FROM promo_redemptions WHERE code = \\$1 ORDER BY redeemed_at DESC, id DESC LIMIT \\$2").
+		WithArgs("SALE", 2).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(lastID, "SALE", orderID, "79991234567", models.DiscountTypeFixed, "100.00", "RUB", 0, lastAt, &reversedAt).
+			AddRow(uuid.New(), "SALE", uuid.New(), "79991234567", models.DiscountTypeFixed, "100.00", "RUB", 0, lastAt.Add(-time.Hour), nil))
+
+	redemptions, err := service.ListRedemptions(context.Background(), "SALE", pagination.Request{Limit: 1})
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	if len(redemptions.Items) != 1 || redemptions.Items[0].OrderID != orderID || redemptions.Items[0].Discount != rub(100) || redemptions.Items[0].ReversedAt == nil {
+		t.Fatalf("unexpected redemptions: %+v", redemptions.Items)
+	}
+	if redemptions.NextCursor == nil {
+		t.Fatalf("expected next cursor")
+	}
+
+	// Следующая страница выбирается по (redeemed_at, id) последней строки
+	cursor, err := pagination.Decode(*redemptions.NextCursor)
+	if err != nil {
+		t.Fatalf("invalid cursor: %v", err)
+	}
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs("SALE").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectQuery("FROM promo_redemptions WHERE code = \\$1 AND \\(redeemed_at, id\\) < \\(\\$2, \\$3\\) ORDER BY redeemed_at DESC, id DESC LIMIT \\$4").
+		WithArgs("SALE", sqlmock.AnyArg(), lastID.String(), 2).
+		WillReturnRows(sqlmock.NewRows(columns))
+	next, err := service.ListRedemptions(context.Background(), "SALE", pagination.Request{Limit: 1, After: cursor})
+	if err != nil || len(next.Items) != 0 || next.NextCursor != nil {
+		t.Fatalf("expected empty last page, got %+v (%v)", next, err)
+	}
+
+	mock.ExpectQuery("SELECT EXISTS").
+		WithArgs("NOPE").
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	if _, err := service.ListRedemptions(context.Background(), "NOPE", pagination.Request{Limit: 10}); !apperror.Is(err, apperror.KindNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func newPreviewRequest(phone string) *models.ValidatePromoRequest {
+	lat, lon := 55.75, 37.62
+	return &models.ValidatePromoRequest{
+		CustomerPhone: phone,
+		Items:         []models.CreateOrderItemRequest{{Name: "Pizza", Quantity: 2, Price: rub(200)}},
+		PickupLat:     &lat,
+		PickupLon:     &lon,
+		DeliveryLat:   &lat,
+		DeliveryLon:   &lon,
+	}
+}
+
+func TestPromoService_PreviewPromo(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewPromoService(db, newTestLogger(), newTestPricingService(), nil)
+
+	// Предпросмотр только читает промокод: без FOR UPDATE и без изменения used_count
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT discount_type, amount, currency, max_uses, used_count, expires_at, active, rules, stacking FROM promo_codes WHERE code = \\$1$").
+		WithArgs("SALE10").
+		WillReturnRows(promoLockRows(models.DiscountTypePercent, 10, models.PromoStackingExclusive))
+	mock.ExpectRollback()
+
+	result, err := service.PreviewPromo(context.Background(), "SALE10", newPreviewRequest(""))
+	if err != nil {
+		t.Fatalf("expected success, got %v", err)
+	}
+	// 400 товаров + 150 минимальная доставка, скидка 10%
+	if !result.Valid || result.Discount != rub(55) || result.Total != rub(495) || result.Reason != "" {
+		t.Fatalf("unexpected preview: %+v", result)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestPromoService_PreviewPromo_Rejected(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewPromoService(db, newTestLogger(), newTestPricingService(), nil)
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT discount_type, amount").
+		WithArgs("BIG").
+		WillReturnRows(sqlmock.NewRows([]string{"discount_type", "amount", "currency", "max_uses", "used_count", "expires_at", "active", "rules", "stacking"}).
+			AddRow(models.DiscountTypeFixed, 100.0, "RUB", 0, 0, nil, true, `{"min_items_total":1000}`, "exclusive"))
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT discount_type, amount").
+		WithArgs("NOPE").
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	result, err := service.PreviewPromo(context.Background(), "BIG", newPreviewRequest(""))
+	if err != nil {
+		t.Fatalf("expected result, got %v", err)
+	}
+	if result.Valid || result.Reason != models.PromoReasonMinItemsTotal || result.Discount != rub(0) || result.Total != rub(550) {
+		t.Fatalf("unexpected preview: %+v", result)
+	}
+
+	result, err = service.PreviewPromo(context.Background(), "NOPE", newPreviewRequest(""))
+	if err != nil || result.Valid || result.Reason != models.PromoReasonNotFound {
+		t.Fatalf("expected not_found reason, got %+v %v", result, err)
+	}
+
+	if _, err := service.PreviewPromo(context.Background(), "BIG", &models.ValidatePromoRequest{}); !apperror.Is(err, apperror.KindValidation) {
+		t.Fatalf("expected validation error without coordinates, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
 	}
 }
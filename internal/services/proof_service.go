@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/database"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+	"delivery-system/internal/storage"
+
+	"github.com/google/uuid"
+)
+
+// ProofService управляет вложениями, подтверждающими доставку (фото, подпись).
+type ProofService struct {
+	db      *database.DB
+	storage storage.BlobStorage
+	log     *logger.Logger
+}
+
+// NewProofService создает сервис подтверждений доставки.
+func NewProofService(db *database.DB, blobStorage storage.BlobStorage, log *logger.Logger) *ProofService {
+	return &ProofService{
+		db:      db,
+		storage: blobStorage,
+		log:     log,
+	}
+}
+
+// AttachProof сохраняет фото или подпись для заказа, находящегося в доставке.
+func (s *ProofService) AttachProof(ctx context.Context, orderID uuid.UUID, proofType models.ProofType, contentType string, content io.Reader) (*models.DeliveryProof, error) {
+	if proofType != models.ProofTypePhoto && proofType != models.ProofTypeSignature {
+		return nil, apperror.Validation("proof type must be photo or signature", nil)
+	}
+
+	var status models.OrderStatus
+	if err := s.db.QueryRowContext(ctx, "SELECT status FROM orders WHERE id = $1", orderID).Scan(&status); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperror.NotFound("order not found", err)
+		}
+		return nil, fmt.Errorf("failed to get order status: %w", err)
+	}
+
+	if status != models.OrderStatusInDelivery {
+		return nil, apperror.Conflict("proof can only be attached to an order in delivery", nil)
+	}
+
+	proofID := uuid.New()
+	key := fmt.Sprintf("proofs/%s/%s", orderID, proofID)
+
+	size, err := s.storage.Put(ctx, key, content)
+	if err != nil {
+		return nil, fmt.Errorf("failed to store proof: %w", err)
+	}
+
+	proof := &models.DeliveryProof{
+		ID:         proofID,
+		OrderID:    orderID,
+		Type:       proofType,
+		StorageKey: &key,
+		SizeBytes:  size,
+		CreatedAt:  time.Now(),
+	}
+	if contentType != "" {
+		proof.ContentType = &contentType
+	}
+
+	query := `
+		INSERT INTO delivery_proofs (id, order_id, proof_type, storage_key, content_type, size_bytes, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	`
+	if _, err := s.db.ExecContext(ctx, query, proof.ID, proof.OrderID, proof.Type, proof.StorageKey, proof.ContentType, proof.SizeBytes, proof.CreatedAt); err != nil {
+		if delErr := s.storage.Delete(ctx, key); delErr != nil {
+			s.log.WithError(delErr).WithField("storage_key", key).Warn("Failed to remove orphaned proof blob")
+		}
+		return nil, fmt.Errorf("failed to save proof: %w", err)
+	}
+
+	s.log.WithFields(map[string]interface{}{
+		"order_id":   orderID,
+		"proof_id":   proofID,
+		"proof_type": proofType,
+		"size_bytes": size,
+	}).Info("Delivery proof attached")
+
+	return proof, nil
+}
+
+// ListProofs возвращает подтверждения доставки по заказу.
+func (s *ProofService) ListProofs(ctx context.Context, orderID uuid.UUID) ([]*models.DeliveryProof, error) {
+	query := `
+		SELECT id, order_id, proof_type, storage_key, content_type, size_bytes, created_at
+		FROM delivery_proofs
+		WHERE order_id = $1
+		ORDER BY created_at ASC
+	`
+
+	rows, err := s.db.QueryContext(ctx, query, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list delivery proofs: %w", err)
+	}
+	defer rows.Close()
+
+	proofs := []*models.DeliveryProof{}
+	for rows.Next() {
+		p := &models.DeliveryProof{}
+		if err := rows.Scan(&p.ID, &p.OrderID, &p.Type, &p.StorageKey, &p.ContentType, &p.SizeBytes, &p.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan delivery proof: %w", err)
+		}
+		proofs = append(proofs, p)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate delivery proofs: %w", err)
+	}
+
+	return proofs, nil
+}
+
+// OpenProof возвращает метаданные вложения и поток с его содержимым.
+func (s *ProofService) OpenProof(ctx context.Context, orderID, proofID uuid.UUID) (*models.DeliveryProof, io.ReadCloser, error) {
+	query := `
+		SELECT id, order_id, proof_type, storage_key, content_type, size_bytes, created_at
+		FROM delivery_proofs
+		WHERE id = $1 AND order_id = $2
+	`
+
+	p := &models.DeliveryProof{}
+	if err := s.db.QueryRowContext(ctx, query, proofID, orderID).Scan(&p.ID, &p.OrderID, &p.Type, &p.StorageKey, &p.ContentType, &p.SizeBytes, &p.CreatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil, apperror.NotFound("delivery proof not found", err)
+		}
+		return nil, nil, fmt.Errorf("failed to get delivery proof: %w", err)
+	}
+
+	if p.StorageKey == nil {
+		return nil, nil, apperror.NotFound("delivery proof has no attachment", nil)
+	}
+
+	rc, err := s.storage.Open(ctx, *p.StorageKey)
+	if err != nil {
+		if errors.Is(err, storage.ErrNotFound) {
+			return nil, nil, apperror.NotFound("delivery proof content not found", err)
+		}
+		return nil, nil, fmt.Errorf("failed to open delivery proof: %w", err)
+	}
+
+	return p, rc, nil
+}
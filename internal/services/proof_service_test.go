@@ -0,0 +1,192 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/models"
+	"delivery-system/internal/storage"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+func newTestBlobStorage(t *testing.T) *storage.LocalStorage {
+	s, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+	return s
+}
+
+func TestProofService_AttachProof_Success(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	blobs := newTestBlobStorage(t)
+	service := NewProofService(db, blobs, newTestLogger())
+	orderID := uuid.New()
+
+	mock.ExpectQuery("SELECT status FROM orders").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.OrderStatusInDelivery))
+	mock.ExpectExec("INSERT INTO delivery_proofs").
+		WithArgs(sqlmock.AnyArg(), orderID, models.ProofTypePhoto, sqlmock.AnyArg(), sqlmock.AnyArg(), int64(5), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	proof, err := service.AttachProof(context.Background(), orderID, models.ProofTypePhoto, "image/jpeg", strings.NewReader("photo"))
+	if err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+
+	if proof.SizeBytes != 5 || proof.ContentType == nil || *proof.ContentType != "image/jpeg" {
+		t.Fatalf("unexpected proof: %+v", proof)
+	}
+
+	rc, err := blobs.Open(context.Background(), *proof.StorageKey)
+	if err != nil {
+		t.Fatalf("expected stored blob, got %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	if string(data) != "photo" {
+		t.Fatalf("unexpected blob content: %q", data)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestProofService_AttachProof_InvalidType(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewProofService(db, newTestBlobStorage(t), newTestLogger())
+
+	_, err := service.AttachProof(context.Background(), uuid.New(), models.ProofTypePIN, "", strings.NewReader("x"))
+	if !apperror.Is(err, apperror.KindValidation) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestProofService_AttachProof_WrongStatus(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewProofService(db, newTestBlobStorage(t), newTestLogger())
+	orderID := uuid.New()
+
+	mock.ExpectQuery("SELECT status FROM orders").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"status"}).AddRow(models.OrderStatusReady))
+
+	_, err := service.AttachProof(context.Background(), orderID, models.ProofTypeSignature, "image/png", strings.NewReader("x"))
+	if !apperror.Is(err, apperror.KindConflict) {
+		t.Fatalf("expected conflict error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestProofService_AttachProof_OrderNotFound(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewProofService(db, newTestBlobStorage(t), newTestLogger())
+	orderID := uuid.New()
+
+	mock.ExpectQuery("SELECT status FROM orders").
+		WithArgs(orderID).
+		WillReturnError(sql.ErrNoRows)
+
+	_, err := service.AttachProof(context.Background(), orderID, models.ProofTypePhoto, "", strings.NewReader("x"))
+	if !apperror.Is(err, apperror.KindNotFound) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestProofService_ListAndOpenProof(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	blobs := newTestBlobStorage(t)
+	service := NewProofService(db, blobs, newTestLogger())
+	orderID := uuid.New()
+	proofID := uuid.New()
+	key := "proofs/" + orderID.String() + "/" + proofID.String()
+
+	if _, err := blobs.Put(context.Background(), key, strings.NewReader("signature")); err != nil {
+		t.Fatalf("failed to seed blob: %v", err)
+	}
+
+	columns := []string{"id", "order_id", "proof_type", "storage_key", "content_type", "size_bytes", "created_at"}
+	mock.ExpectQuery("SELECT id, order_id, proof_type, storage_key, content_type, size_bytes, created_at FROM delivery_proofs").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(uuid.New(), orderID, models.ProofTypePIN, nil, nil, 0, time.Now()).
+			AddRow(proofID, orderID, models.ProofTypeSignature, key, "image/png", 9, time.Now()))
+
+	proofs, err := service.ListProofs(context.Background(), orderID)
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(proofs) != 2 {
+		t.Fatalf("expected 2 proofs, got %d", len(proofs))
+	}
+
+	mock.ExpectQuery("SELECT id, order_id, proof_type, storage_key, content_type, size_bytes, created_at FROM delivery_proofs").
+		WithArgs(proofID, orderID).
+		WillReturnRows(sqlmock.NewRows(columns).
+			AddRow(proofID, orderID, models.ProofTypeSignature, key, "image/png", 9, time.Now()))
+
+	proof, rc, err := service.OpenProof(context.Background(), orderID, proofID)
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	if string(data) != "signature" || proof.Type != models.ProofTypeSignature {
+		t.Fatalf("unexpected proof content %q / %+v", data, proof)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestProofService_OpenProof_NotFound(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewProofService(db, newTestBlobStorage(t), newTestLogger())
+	orderID := uuid.New()
+	proofID := uuid.New()
+
+	mock.ExpectQuery("SELECT id, order_id, proof_type").
+		WithArgs(proofID, orderID).
+		WillReturnError(sql.ErrNoRows)
+
+	if _, _, err := service.OpenProof(context.Background(), orderID, proofID); !apperror.Is(err, apperror.KindNotFound) {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/database"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+	"delivery-system/internal/receipts"
+
+	"github.com/google/uuid"
+)
+
+// ReceiptService формирует чеки по доставленным заказам и отдает их в JSON, HTML и PDF.
+type ReceiptService struct {
+	db      *database.DB
+	log     *logger.Logger
+	pricing *PricingService
+}
+
+// NewReceiptService создает сервис чеков. Ставки налога берутся из региона заказа.
+func NewReceiptService(db *database.DB, log *logger.Logger, pricing *PricingService) *ReceiptService {
+	return &ReceiptService{
+		db:      db,
+		log:     log,
+		pricing: pricing,
+	}
+}
+
+// IssueWithTx формирует чек по заказу в рамках транзакции перевода в delivered.
+// Если чек уже выдан, ничего не делает.
+func (s *ReceiptService) IssueWithTx(ctx context.Context, tx *sql.Tx, orderID uuid.UUID) error {
+	var exists bool
+	if err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM receipts WHERE order_id = $1)`, orderID).Scan(&exists); err != nil {
+		return fmt.Errorf("failed to check receipt: %w", err)
+	}
+	if exists {
+		return nil
+	}
+
+	order, err := s.loadOrder(ctx, tx, orderID)
+	if err != nil {
+		return err
+	}
+
+	tariff := s.pricing.DefaultTariff()
+	if regional, ok := s.pricing.Tariff(order.Region); ok {
+		tariff = regional
+	}
+	rates := receipts.Rates{Standard: tariff.Region.TaxRate, Reduced: tariff.Region.ReducedTaxRate}
+
+	var seq int64
+	if err := tx.QueryRowContext(ctx, `SELECT nextval('receipt_number_seq')`).Scan(&seq); err != nil {
+		return fmt.Errorf("failed to allocate receipt number: %w", err)
+	}
+
+	receipt := receipts.Build(order, rates, fmt.Sprintf("R-%08d", seq), time.Now().In(tariff.Location))
+
+	payload, err := receipts.RenderJSON(receipt)
+	if err != nil {
+		return fmt.Errorf("failed to render receipt json: %w", err)
+	}
+	html, err := receipts.RenderHTML(receipt)
+	if err != nil {
+		return err
+	}
+	pdf, err := receipts.RenderPDF(receipt)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO receipts (id, order_id, number, currency, total, tax_total, payload, html, pdf, issued_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+	if _, err := tx.ExecContext(ctx, query, receipt.ID, receipt.OrderID, receipt.Number, receipt.Currency,
+		receipt.Total, receipt.TaxTotal, string(payload), string(html), pdf, receipt.IssuedAt); err != nil {
+		return fmt.Errorf("failed to save receipt: %w", err)
+	}
+
+	s.log.WithFields(map[string]interface{}{
+		"order_id":       orderID,
+		"receipt_number": receipt.Number,
+		"total":          receipt.Total,
+		"tax_total":      receipt.TaxTotal,
+	}).Info("Receipt issued")
+
+	return nil
+}
+
+// GetReceipt возвращает чек заказа.
+func (s *ReceiptService) GetReceipt(ctx context.Context, orderID uuid.UUID) (*models.Receipt, error) {
+	var (
+		currency string
+		payload  []byte
+	)
+	query := `SELECT currency, payload FROM receipts WHERE order_id = $1`
+	if err := s.db.QueryRowContext(ctx, query, orderID).Scan(&currency, &payload); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperror.NotFound("receipt not found", err)
+		}
+		return nil, fmt.Errorf("failed to get receipt: %w", err)
+	}
+
+	var receipt models.Receipt
+	if err := json.Unmarshal(payload, &receipt); err != nil {
+		return nil, fmt.Errorf("failed to decode receipt: %w", err)
+	}
+	receipt.ApplyCurrency(currency)
+
+	return &receipt, nil
+}
+
+// GetReceiptDocument возвращает документ чека в формате html или pdf, сформированный при выдаче.
+func (s *ReceiptService) GetReceiptDocument(ctx context.Context, orderID uuid.UUID, format models.ReceiptFormat) ([]byte, error) {
+	var column string
+	switch format {
+	case models.ReceiptFormatHTML:
+		column = "html"
+	case models.ReceiptFormatPDF:
+		column = "pdf"
+	case models.ReceiptFormatJSON:
+		column = "payload"
+	default:
+		return nil, apperror.Validation("format must be one of: json, html, pdf", nil)
+	}
+
+	var document []byte
+	query := fmt.Sprintf(`SELECT %s FROM receipts WHERE order_id = $1`, column)
+	if err := s.db.QueryRowContext(ctx, query, orderID).Scan(&document); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperror.NotFound("receipt not found", err)
+		}
+		return nil, fmt.Errorf("failed to get receipt document: %w", err)
+	}
+
+	return document, nil
+}
+
+func (s *ReceiptService) loadOrder(ctx context.Context, tx *sql.Tx, orderID uuid.UUID) (*models.Order, error) {
+	order := &models.Order{ID: orderID}
+	query := `
+		SELECT total_amount, delivery_cost, discount_amount, currency, region_code, promo_code
+		FROM orders
+		WHERE id = $1
+	`
+	if err := tx.QueryRowContext(ctx, query, orderID).Scan(&order.TotalAmount, &order.DeliveryCost, &order.DiscountAmount,
+		&order.Currency, &order.Region, &order.PromoCode); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperror.NotFound("order not found", err)
+		}
+		return nil, fmt.Errorf("failed to get order for receipt: %w", err)
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		SELECT id, order_id, name, quantity, price, tax_category
+		FROM order_items
+		WHERE order_id = $1
+		ORDER BY name, id
+	`, orderID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order items: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var item models.OrderItem
+		if err := rows.Scan(&item.ID, &item.OrderID, &item.Name, &item.Quantity, &item.Price, &item.TaxCategory); err != nil {
+			return nil, fmt.Errorf("failed to scan order item: %w", err)
+		}
+		order.Items = append(order.Items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate order items: %w", err)
+	}
+
+	order.ApplyCurrency(order.Currency)
+	return order, nil
+}
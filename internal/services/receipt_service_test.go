@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+func newTestReceiptService(t *testing.T) (*ReceiptService, sqlmock.Sqlmock, func()) {
+	t.Helper()
+	db, mock := newMockDB(t)
+	pricing, err := NewRegionalPricingService(models.Region{
+		Code: models.DefaultRegionCode, Currency: "RUB", TaxRate: 0.2, ReducedTaxRate: 0.1,
+		BaseFare: 100, PerKm: 20, MinFare: 150,
+	}, nil)
+	if err != nil {
+		t.Fatalf("pricing: %v", err)
+	}
+	return NewReceiptService(db, newTestLogger(), pricing), mock, func() { _ = db.Close() }
+}
+
+func TestReceiptService_IssueWithTx(t *testing.T) {
+	service, mock, closeDB := newTestReceiptService(t)
+	defer closeDB()
+
+	orderID := uuid.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS\\(SELECT 1 FROM receipts").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+	mock.ExpectQuery("SELECT total_amount, delivery_cost, discount_amount, currency, region_code, promo_code FROM orders").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"total_amount", "delivery_cost", "discount_amount", "currency", "region_code", "promo_code"}).
+			AddRow(650.0, 150.0, 0.0, "RUB", "default", nil))
+	mock.ExpectQuery("SELECT id, order_id, name, quantity, price, tax_category FROM order_items").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "order_id", "name", "quantity", "price", "tax_category"}).
+			AddRow(uuid.New(), orderID, "Juice", 2, 100.0, "reduced").
+			AddRow(uuid.New(), orderID, "Pizza", 1, 300.0, "standard"))
+	mock.ExpectQuery("SELECT nextval\\('receipt_number_seq'\\)").
+		WillReturnRows(sqlmock.NewRows([]string{"nextval"}).AddRow(7))
+	mock.ExpectExec("INSERT INTO receipts").
+		WithArgs(sqlmock.AnyArg(), orderID, "R-00000007", "RUB", rub(650), rub(93.18), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	tx, err := service.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	if err := service.IssueWithTx(context.Background(), tx, orderID); err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestReceiptService_IssueWithTx_AlreadyIssued(t *testing.T) {
+	service, mock, closeDB := newTestReceiptService(t)
+	defer closeDB()
+
+	orderID := uuid.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT EXISTS\\(SELECT 1 FROM receipts").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+	mock.ExpectRollback()
+
+	tx, err := service.db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	if err := service.IssueWithTx(context.Background(), tx, orderID); err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+	_ = tx.Rollback()
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestReceiptService_GetReceipt(t *testing.T) {
+	service, mock, closeDB := newTestReceiptService(t)
+	defer closeDB()
+
+	orderID := uuid.New()
+	payload, _ := json.Marshal(models.Receipt{Number: "R-00000001", OrderID: orderID, Total: rub(250), IssuedAt: time.Now()})
+	mock.ExpectQuery("SELECT currency, payload FROM receipts").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"currency", "payload"}).AddRow("RUB", payload))
+
+	receipt, err := service.GetReceipt(context.Background(), orderID)
+	if err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+	if receipt.Number != "R-00000001" || receipt.Total != rub(250) {
+		t.Fatalf("unexpected receipt: %+v", receipt)
+	}
+}
+
+func TestReceiptService_GetReceipt_NotFound(t *testing.T) {
+	service, mock, closeDB := newTestReceiptService(t)
+	defer closeDB()
+
+	orderID := uuid.New()
+	mock.ExpectQuery("SELECT currency, payload FROM receipts").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"currency", "payload"}))
+
+	if _, err := service.GetReceipt(context.Background(), orderID); !apperror.Is(err, apperror.KindNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestReceiptService_GetReceiptDocument_InvalidFormat(t *testing.T) {
+	service, _, closeDB := newTestReceiptService(t)
+	defer closeDB()
+
+	if _, err := service.GetReceiptDocument(context.Background(), uuid.New(), "xml"); !apperror.Is(err, apperror.KindValidation) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+}
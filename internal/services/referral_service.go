@@ -0,0 +1,384 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/config"
+	"delivery-system/internal/database"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+	"delivery-system/internal/money"
+	"delivery-system/internal/pagination"
+
+	"github.com/google/uuid"
+)
+
+const (
+	referralCodeLength   = 8
+	referralCodeAttempts = 5
+)
+
+var addressSpaces = regexp.MustCompile(`\s+`)
+
+// ReferralOrder — данные заказа нового клиента, нужные для проверки приглашения.
+type ReferralOrder struct {
+	OrderID         uuid.UUID
+	Code            string
+	CustomerPhone   string
+	DeviceID        string
+	DeliveryAddress string
+	Currency        string
+}
+
+// ReferralService выдает персональные реферальные коды и начисляет бонусы за приглашенных клиентов.
+// Бонусы начисляются обоим клиентам при доставке первого заказа приглашенного.
+type ReferralService struct {
+	db             *database.DB
+	log            *logger.Logger
+	wallet         *WalletService
+	referrerReward float64
+	refereeReward  float64
+	codePrefix     string
+}
+
+// NewReferralService создает сервис реферальной программы. Бонусы зачисляются на счета в WalletService.
+func NewReferralService(db *database.DB, log *logger.Logger, wallet *WalletService, cfg *config.ReferralConfig) *ReferralService {
+	s := &ReferralService{
+		db:     db,
+		log:    log,
+		wallet: wallet,
+	}
+	if cfg != nil {
+		s.referrerReward = cfg.ReferrerReward
+		s.refereeReward = cfg.RefereeReward
+		s.codePrefix = cfg.CodePrefix
+	}
+	return s
+}
+
+// GetOrCreateCode возвращает персональный код клиента, выпуская его при первом обращении.
+// Второе значение сообщает, был ли код создан сейчас.
+func (s *ReferralService) GetOrCreateCode(ctx context.Context, req *models.CreateReferralCodeRequest) (*models.ReferralCode, bool, error) {
+	phone := normalizePhone(req.CustomerPhone)
+	if phone == "" {
+		return nil, false, apperror.Validation("customer_phone is required", nil)
+	}
+
+	if existing, err := s.findCodeByPhone(ctx, phone); err != nil || existing != nil {
+		return existing, false, err
+	}
+
+	spec, err := newCodeSpec(&models.GenerateCodesRequest{Count: 1, Prefix: s.codePrefix, Length: referralCodeLength})
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid referral code prefix: %w", err)
+	}
+
+	seen := make(map[string]struct{})
+	for attempt := 0; attempt < referralCodeAttempts; attempt++ {
+		codes, err := spec.generate(1, seen)
+		if err != nil {
+			return nil, false, err
+		}
+
+		// Код не должен совпадать с промокодом, чтобы клиент не путал их при оформлении заказа
+		code := &models.ReferralCode{Code: codes[0], CustomerPhone: phone, CreatedAt: time.Now()}
+		insertQuery := `
+			INSERT INTO referral_codes (code, customer_phone, created_at)
+			SELECT $1, $2, $3
+			WHERE NOT EXISTS (SELECT 1 FROM promo_codes WHERE code = $1)
+			ON CONFLICT DO NOTHING
+		`
+		result, err := s.db.ExecContext(ctx, insertQuery, code.Code, code.CustomerPhone, code.CreatedAt)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to create referral code: %w", err)
+		}
+		inserted, err := result.RowsAffected()
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to get rows affected: %w", err)
+		}
+		if inserted == 1 {
+			s.log.WithFields(map[string]interface{}{
+				"code":           code.Code,
+				"customer_phone": phone,
+			}).Info("Referral code created")
+			return code, true, nil
+		}
+
+		// Код мог выпустить параллельный запрос того же клиента
+		if existing, err := s.findCodeByPhone(ctx, phone); err != nil || existing != nil {
+			return existing, false, err
+		}
+	}
+
+	return nil, false, fmt.Errorf("failed to generate unique referral code after %d attempts", referralCodeAttempts)
+}
+
+// GetReferralCode возвращает код со счетчиками приглашений по статусам.
+func (s *ReferralService) GetReferralCode(ctx context.Context, code string) (*models.ReferralCode, error) {
+	query := `
+		SELECT c.code, c.customer_phone, c.created_at,
+		       COUNT(r.id) FILTER (WHERE r.status = 'pending'),
+		       COUNT(r.id) FILTER (WHERE r.status = 'rewarded'),
+		       COUNT(r.id) FILTER (WHERE r.status = 'rejected')
+		FROM referral_codes c
+		LEFT JOIN referrals r ON r.code = c.code
+		WHERE c.code = $1
+		GROUP BY c.code, c.customer_phone, c.created_at
+	`
+	result := &models.ReferralCode{}
+	if err := s.db.QueryRowContext(ctx, query, code).Scan(&result.Code, &result.CustomerPhone, &result.CreatedAt,
+		&result.Pending, &result.Rewarded, &result.Rejected); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperror.NotFound("referral code not found", err)
+		}
+		return nil, fmt.Errorf("failed to get referral code: %w", err)
+	}
+	return result, nil
+}
+
+// ListReferrals возвращает приглашения по коду, новые первыми.
+func (s *ReferralService) ListReferrals(ctx context.Context, code string, page pagination.Request) (*pagination.Page[*models.Referral], error) {
+	if err := page.CheckSort(sortCreatedAt); err != nil {
+		return nil, err
+	}
+	if page.Limit <= 0 {
+		page.Limit = 50
+	}
+
+	var exists bool
+	if err := s.db.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM referral_codes WHERE code = $1)`, code).Scan(&exists); err != nil {
+		return nil, fmt.Errorf("failed to check referral code: %w", err)
+	}
+	if !exists {
+		return nil, apperror.NotFound("referral code not found", nil)
+	}
+
+	query := `
+		SELECT id, code, referrer_phone, referee_phone, order_id, status, reject_reason,
+		       referrer_reward, referee_reward, currency, created_at, rewarded_at
+		FROM referrals
+		WHERE code = $1`
+	args := []interface{}{code}
+	if page.After != nil {
+		query += " AND " + keysetCondition([]string{"created_at", "id"}, &args, page.After.CreatedAt, page.After.ID)
+	}
+	query += " ORDER BY created_at DESC, id DESC" + pageLimitClause(page, &args)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list referrals: %w", err)
+	}
+	defer rows.Close()
+
+	var referrals []*models.Referral
+	for rows.Next() {
+		r := &models.Referral{}
+		if err := rows.Scan(&r.ID, &r.Code, &r.ReferrerPhone, &r.RefereePhone, &r.OrderID, &r.Status, &r.RejectReason,
+			&r.ReferrerReward, &r.RefereeReward, &r.Currency, &r.CreatedAt, &r.RewardedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan referral: %w", err)
+		}
+		r.ReferrerReward = r.ReferrerReward.In(r.Currency)
+		r.RefereeReward = r.RefereeReward.In(r.Currency)
+		referrals = append(referrals, r)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate referrals: %w", err)
+	}
+
+	return pagination.NewPage(referrals, page.Limit, func(r *models.Referral) pagination.Cursor {
+		return pagination.Cursor{Sort: sortCreatedAt, CreatedAt: r.CreatedAt, ID: r.ID.String()}
+	}), nil
+}
+
+// AttachWithTx привязывает заказ нового клиента к реферальному коду. Приглашение, не прошедшее
+// проверки, сохраняется отклоненным с причиной и не мешает оформить заказ.
+func (s *ReferralService) AttachWithTx(ctx context.Context, tx *sql.Tx, order ReferralOrder) (*models.Referral, error) {
+	code := strings.TrimSpace(order.Code)
+	phone := normalizePhone(order.CustomerPhone)
+	if phone == "" {
+		return nil, apperror.Validation("customer phone is required for referral code", nil)
+	}
+
+	var referrerPhone string
+	if err := tx.QueryRowContext(ctx, `SELECT customer_phone FROM referral_codes WHERE code = $1`, code).Scan(&referrerPhone); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperror.NotFound("referral code not found", err)
+		}
+		return nil, fmt.Errorf("failed to get referral code: %w", err)
+	}
+
+	reason, err := s.checkReferral(ctx, tx, referrerPhone, phone, order)
+	if err != nil {
+		return nil, err
+	}
+
+	referral := &models.Referral{
+		ID:             uuid.New(),
+		Code:           code,
+		ReferrerPhone:  referrerPhone,
+		RefereePhone:   phone,
+		OrderID:        order.OrderID,
+		Status:         models.ReferralStatusPending,
+		ReferrerReward: money.Zero(order.Currency),
+		RefereeReward:  money.Zero(order.Currency),
+		Currency:       order.Currency,
+		CreatedAt:      time.Now(),
+	}
+	if reason != "" {
+		referral.Status = models.ReferralStatusRejected
+		referral.RejectReason = &reason
+	}
+
+	insertQuery := `
+		INSERT INTO referrals (id, code, referrer_phone, referee_phone, order_id, status, reject_reason, currency, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+	if _, err := tx.ExecContext(ctx, insertQuery, referral.ID, referral.Code, referral.ReferrerPhone, referral.RefereePhone,
+		referral.OrderID, referral.Status, referral.RejectReason, referral.Currency, referral.CreatedAt); err != nil {
+		return nil, fmt.Errorf("failed to record referral: %w", err)
+	}
+
+	if reason != "" {
+		s.log.WithFields(map[string]interface{}{
+			"code":     code,
+			"order_id": order.OrderID,
+			"reason":   reason,
+		}).Warn("Referral rejected")
+	}
+
+	return referral, nil
+}
+
+// RewardWithTx начисляет бонусы обоим клиентам при доставке заказа с ожидающим приглашением.
+func (s *ReferralService) RewardWithTx(ctx context.Context, tx *sql.Tx, orderID uuid.UUID) error {
+	referral := &models.Referral{OrderID: orderID}
+	query := `
+		SELECT id, referrer_phone, referee_phone, currency
+		FROM referrals
+		WHERE order_id = $1 AND status = 'pending'
+		FOR UPDATE
+	`
+	if err := tx.QueryRowContext(ctx, query, orderID).Scan(&referral.ID, &referral.ReferrerPhone, &referral.RefereePhone, &referral.Currency); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return fmt.Errorf("failed to get referral: %w", err)
+	}
+
+	referral.ReferrerReward = money.FromFloat(s.referrerReward, referral.Currency)
+	referral.RefereeReward = money.FromFloat(s.refereeReward, referral.Currency)
+
+	rewards := []*models.WalletTransaction{
+		{CustomerPhone: referral.ReferrerPhone, Amount: referral.ReferrerReward},
+		{CustomerPhone: referral.RefereePhone, Amount: referral.RefereeReward},
+	}
+	for _, reward := range rewards {
+		reward.Kind = models.WalletKindReferralReward
+		reward.Currency = referral.Currency
+		reward.OrderID = &orderID
+		reward.ReferralID = &referral.ID
+		if err := s.wallet.CreditWithTx(ctx, tx, reward); err != nil {
+			return err
+		}
+	}
+
+	updateQuery := `
+		UPDATE referrals
+		SET status = 'rewarded', referrer_reward = $1, referee_reward = $2, rewarded_at = $3
+		WHERE id = $4
+	`
+	if _, err := tx.ExecContext(ctx, updateQuery, referral.ReferrerReward, referral.RefereeReward, time.Now(), referral.ID); err != nil {
+		return fmt.Errorf("failed to mark referral rewarded: %w", err)
+	}
+
+	s.log.WithFields(map[string]interface{}{
+		"referral_id": referral.ID,
+		"order_id":    orderID,
+	}).Info("Referral rewarded")
+
+	return nil
+}
+
+// RejectWithTx отклоняет ожидающее приглашение отмененного заказа; клиент сможет воспользоваться кодом снова.
+func (s *ReferralService) RejectWithTx(ctx context.Context, tx *sql.Tx, orderID uuid.UUID, reason models.ReferralRejectReason) error {
+	query := `
+		UPDATE referrals
+		SET status = 'rejected', reject_reason = $1
+		WHERE order_id = $2 AND status = 'pending'
+	`
+	if _, err := tx.ExecContext(ctx, query, reason, orderID); err != nil {
+		return fmt.Errorf("failed to reject referral: %w", err)
+	}
+	return nil
+}
+
+// findCodeByPhone возвращает код клиента или nil, если код еще не выпущен.
+func (s *ReferralService) findCodeByPhone(ctx context.Context, phone string) (*models.ReferralCode, error) {
+	code := &models.ReferralCode{}
+	query := `SELECT code, customer_phone, created_at FROM referral_codes WHERE customer_phone = $1`
+	if err := s.db.QueryRowContext(ctx, query, phone).Scan(&code.Code, &code.CustomerPhone, &code.CreatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get referral code: %w", err)
+	}
+	return code, nil
+}
+
+// checkReferral проверяет приглашение на злоупотребления и возвращает причину отклонения или пустую строку.
+func (s *ReferralService) checkReferral(ctx context.Context, tx *sql.Tx, referrerPhone, refereePhone string, order ReferralOrder) (models.ReferralRejectReason, error) {
+	if referrerPhone == refereePhone {
+		return models.ReferralReasonSelfReferral, nil
+	}
+
+	var alreadyReferred, hasOrders bool
+	historyQuery := `
+		SELECT
+			EXISTS(SELECT 1 FROM referrals WHERE referee_phone = $1 AND status <> 'rejected'),
+			EXISTS(
+				SELECT 1 FROM orders
+				WHERE regexp_replace(customer_phone, '\D', '', 'g') = $1 AND status <> 'cancelled'
+			)
+	`
+	if err := tx.QueryRowContext(ctx, historyQuery, refereePhone).Scan(&alreadyReferred, &hasOrders); err != nil {
+		return "", fmt.Errorf("failed to check referee history: %w", err)
+	}
+	if alreadyReferred {
+		return models.ReferralReasonAlreadyReferred, nil
+	}
+	if hasOrders {
+		return models.ReferralReasonNotFirstOrder, nil
+	}
+
+	// Совпадение устройства или адреса доставки с заказами пригласившего — признак приглашения самого себя
+	var sameDevice, sameAddress bool
+	overlapQuery := `
+		SELECT
+			COALESCE(bool_or($2 <> '' AND device_id = $2), false),
+			COALESCE(bool_or(lower(regexp_replace(trim(delivery_address), '\s+', ' ', 'g')) = $3), false)
+		FROM orders
+		WHERE regexp_replace(customer_phone, '\D', '', 'g') = $1
+	`
+	if err := tx.QueryRowContext(ctx, overlapQuery, referrerPhone, order.DeviceID, normalizeAddress(order.DeliveryAddress)).Scan(&sameDevice, &sameAddress); err != nil {
+		return "", fmt.Errorf("failed to check referrer orders: %w", err)
+	}
+	if sameDevice {
+		return models.ReferralReasonSameDevice, nil
+	}
+	if sameAddress {
+		return models.ReferralReasonSameAddress, nil
+	}
+
+	return "", nil
+}
+
+// normalizeAddress приводит адрес к нижнему регистру и схлопывает пробелы для сравнения.
+func normalizeAddress(address string) string {
+	return strings.ToLower(addressSpaces.ReplaceAllString(strings.TrimSpace(address), " "))
+}
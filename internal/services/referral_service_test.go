@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/config"
+	"delivery-system/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+func newTestReferralService(t *testing.T) (*ReferralService, sqlmock.Sqlmock, func()) {
+	db, mock := newMockDB(t)
+	log := newTestLogger()
+	service := NewReferralService(db, log, NewWalletService(db, log), &config.ReferralConfig{
+		ReferrerReward: 300,
+		RefereeReward:  200,
+		CodePrefix:     "REF-",
+	})
+	return service, mock, func() { _ = db.Close() }
+}
+
+func TestReferralService_GetOrCreateCode(t *testing.T) {
+	service, mock, closeDB := newTestReferralService(t)
+	defer closeDB()
+
+	mock.ExpectQuery("SELECT code, customer_phone, created_at FROM referral_codes").
+		WithArgs("79991234567").
+		WillReturnRows(sqlmock.NewRows([]string{"code", "customer_phone", "created_at"}))
+	mock.ExpectExec("INSERT INTO referral_codes").
+		WithArgs(sqlmock.AnyArg(), "79991234567", sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	code, created, err := service.GetOrCreateCode(context.Background(), &models.CreateReferralCodeRequest{CustomerPhone: "+7 (999) 123-45-67"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !created || len(code.Code) != len("REF-")+referralCodeLength || code.Code[:4] != "REF-" {
+		t.Fatalf("unexpected code: %+v created=%v", code, created)
+	}
+
+	// Повторный запрос возвращает уже выпущенный код
+	mock.ExpectQuery("SELECT code, customer_phone, created_at FROM referral_codes").
+		WithArgs("79991234567").
+		WillReturnRows(sqlmock.NewRows([]string{"code", "customer_phone", "created_at"}).AddRow(code.Code, "79991234567", time.Now()))
+
+	again, created, err := service.GetOrCreateCode(context.Background(), &models.CreateReferralCodeRequest{CustomerPhone: "79991234567"})
+	if err != nil || created || again.Code != code.Code {
+		t.Fatalf("expected existing code %s, got %+v created=%v err=%v", code.Code, again, created, err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestReferralService_AttachWithTx(t *testing.T) {
+	cases := []struct {
+		name        string
+		phone       string
+		history     []bool // already_referred, has_orders
+		overlap     []bool // same_device, same_address
+		wantStatus  models.ReferralStatus
+		wantReason  models.ReferralRejectReason
+		skipHistory bool
+	}{
+		{name: "pending", phone: "79990000002", history: []bool{false, false}, overlap: []bool{false, false}, wantStatus: models.ReferralStatusPending},
+		{name: "self referral", phone: "+7 999 000-00-01", skipHistory: true, wantStatus: models.ReferralStatusRejected, wantReason: models.ReferralReasonSelfReferral},
+		{name: "not first order", phone: "79990000002", history: []bool{false, true}, wantStatus: models.ReferralStatusRejected, wantReason: models.ReferralReasonNotFirstOrder},
+		{name: "same device", phone: "79990000002", history: []bool{false, false}, overlap: []bool{true, false}, wantStatus: models.ReferralStatusRejected, wantReason: models.ReferralReasonSameDevice},
+		{name: "same address", phone: "79990000002", history: []bool{false, false}, overlap: []bool{false, true}, wantStatus: models.ReferralStatusRejected, wantReason: models.ReferralReasonSameAddress},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			service, mock, closeDB := newTestReferralService(t)
+			defer closeDB()
+
+			mock.ExpectBegin()
+			mock.ExpectQuery("SELECT customer_phone FROM referral_codes").
+				WithArgs("REF-ABCD2345").
+				WillReturnRows(sqlmock.NewRows([]string{"customer_phone"}).AddRow("79990000001"))
+			if !tc.skipHistory {
+				mock.ExpectQuery("FROM referrals WHERE referee_phone").
+					WithArgs("79990000002").
+					WillReturnRows(sqlmock.NewRows([]string{"referred", "has_orders"}).AddRow(tc.history[0], tc.history[1]))
+			}
+			if tc.overlap != nil {
+				mock.ExpectQuery("SELECT (.+) FROM orders").
+					WithArgs("79990000001", "device-1", "ул. ленина, 1").
+					WillReturnRows(sqlmock.NewRows([]string{"same_device", "same_address"}).AddRow(tc.overlap[0], tc.overlap[1]))
+			}
+			mock.ExpectExec("INSERT INTO referrals").
+				WithArgs(sqlmock.AnyArg(), "REF-ABCD2345", "79990000001", sqlmock.AnyArg(), sqlmock.AnyArg(), tc.wantStatus, sqlmock.AnyArg(), "RUB", sqlmock.AnyArg()).
+				WillReturnResult(sqlmock.NewResult(1, 1))
+
+			tx, err := service.db.Begin()
+			if err != nil {
+				t.Fatalf("failed to begin tx: %v", err)
+			}
+			defer func() { _ = tx.Rollback() }()
+
+			referral, err := service.AttachWithTx(context.Background(), tx, ReferralOrder{
+				OrderID:         uuid.New(),
+				Code:            " REF-ABCD2345 ",
+				CustomerPhone:   tc.phone,
+				DeviceID:        "device-1",
+				DeliveryAddress: "  Ул. Ленина,   1 ",
+				Currency:        "RUB",
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if referral.Status != tc.wantStatus {
+				t.Fatalf("expected status %s, got %s", tc.wantStatus, referral.Status)
+			}
+			if tc.wantReason != "" && (referral.RejectReason == nil || *referral.RejectReason != tc.wantReason) {
+				t.Fatalf("expected reason %s, got %v", tc.wantReason, referral.RejectReason)
+			}
+
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestReferralService_AttachWithTx_UnknownCode(t *testing.T) {
+	service, mock, closeDB := newTestReferralService(t)
+	defer closeDB()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT customer_phone FROM referral_codes").
+		WithArgs("NOPE").
+		WillReturnRows(sqlmock.NewRows([]string{"customer_phone"}))
+
+	tx, err := service.db.Begin()
+	if err != nil {
+		t.Fatalf("failed to begin tx: %v", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	_, err = service.AttachWithTx(context.Background(), tx, ReferralOrder{OrderID: uuid.New(), Code: "NOPE", CustomerPhone: "79990000002", Currency: "RUB"})
+	if !apperror.Is(err, apperror.KindNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestReferralService_RewardWithTx(t *testing.T) {
+	service, mock, closeDB := newTestReferralService(t)
+	defer closeDB()
+
+	orderID := uuid.New()
+	referralID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, referrer_phone, referee_phone, currency FROM referrals").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "referrer_phone", "referee_phone", "currency"}).
+			AddRow(referralID, "79990000001", "79990000002", "RUB"))
+	mock.ExpectExec("INSERT INTO wallets").
+		WithArgs("79990000001", "RUB", rub(300), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO wallet_transactions").
+		WithArgs(sqlmock.AnyArg(), "79990000001", models.WalletKindReferralReward, rub(300), "RUB", &orderID, &referralID, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO wallets").
+		WithArgs("79990000002", "RUB", rub(200), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO wallet_transactions").
+		WithArgs(sqlmock.AnyArg(), "79990000002", models.WalletKindReferralReward, rub(200), "RUB", &orderID, &referralID, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE referrals").
+		WithArgs(rub(300), rub(200), sqlmock.AnyArg(), referralID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	tx, err := service.db.Begin()
+	if err != nil {
+		t.Fatalf("failed to begin tx: %v", err)
+	}
+	if err := service.RewardWithTx(context.Background(), tx, orderID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("commit failed: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestReferralService_RewardWithTx_NoPendingReferral(t *testing.T) {
+	service, mock, closeDB := newTestReferralService(t)
+	defer closeDB()
+
+	orderID := uuid.New()
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, referrer_phone, referee_phone, currency FROM referrals").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "referrer_phone", "referee_phone", "currency"}))
+
+	tx, err := service.db.Begin()
+	if err != nil {
+		t.Fatalf("failed to begin tx: %v", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := service.RewardWithTx(context.Background(), tx, orderID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Причины, по которым фильтр отправляет текст на модерацию.
+const (
+	reviewFlagProfanity = "profanity"
+)
+
+// redactedPlaceholder заменяет в тексте отзыва найденные персональные данные.
+const redactedPlaceholder = "[скрыто]"
+
+// defaultBlockedWords — встроенный список запрещенных слов. Сравнение идет по началу слова,
+// поэтому достаточно корня: "бляд" найдет и "блядь", и "блядский".
+var defaultBlockedWords = []string{
+	"fuck", "shit", "bitch", "asshole", "cunt", "motherfucker",
+	"хуй", "хуе", "хуи", "пизд", "ебан", "ебат", "ебал", "ебну", "бляд", "блят",
+	"сука", "суки", "мудак", "мудил", "гандон", "пидор", "пидар", "долбоеб", "уебок", "уебищ",
+}
+
+var (
+	emailPattern = regexp.MustCompile(`[\p{L}0-9._%+-]+@[\p{L}0-9.-]+\.\p{L}{2,}`)
+	// 10–19 цифр подряд, в том числе с пробелами, скобками и дефисами: телефоны и номера карт
+	digitsPattern = regexp.MustCompile(`\+?\d(?:[\s()-]*\d){9,18}`)
+)
+
+// ReviewFilter — шаг проверки текста отзыва. Возвращает текст после правки и причину,
+// по которой текст нужно отправить на модерацию (пустая — текст можно публиковать).
+type ReviewFilter func(text string) (string, string)
+
+// ReviewModerator прогоняет текст отзыва или ответа через цепочку фильтров.
+type ReviewModerator struct {
+	filters []ReviewFilter
+}
+
+// NewReviewModerator создает цепочку по умолчанию: скрытие персональных данных, затем проверка
+// на запрещенные слова (встроенный список плюс blockedWords).
+func NewReviewModerator(blockedWords []string) *ReviewModerator {
+	return &ReviewModerator{filters: []ReviewFilter{RedactPII, NewProfanityFilter(blockedWords)}}
+}
+
+// Check возвращает текст после всех фильтров и причины отправки на модерацию без повторов.
+func (m *ReviewModerator) Check(text string) (string, []string) {
+	var reasons []string
+	for _, filter := range m.filters {
+		var reason string
+		text, reason = filter(text)
+		if reason != "" && !containsString(reasons, reason) {
+			reasons = append(reasons, reason)
+		}
+	}
+	return text, reasons
+}
+
+// RedactPII заменяет адреса почты, телефоны и номера карт. Такой текст публикуется без модерации.
+func RedactPII(text string) (string, string) {
+	text = emailPattern.ReplaceAllString(text, redactedPlaceholder)
+	text = digitsPattern.ReplaceAllString(text, redactedPlaceholder)
+	return text, ""
+}
+
+// NewProfanityFilter создает фильтр, который отправляет на модерацию текст с запрещенными словами.
+// Текст не меняется: решение принимает модератор.
+func NewProfanityFilter(extra []string) ReviewFilter {
+	roots := make([]string, 0, len(defaultBlockedWords)+len(extra))
+	for _, word := range append(append([]string{}, defaultBlockedWords...), extra...) {
+		if word = normalizeReviewWord(word); word != "" {
+			roots = append(roots, word)
+		}
+	}
+
+	return func(text string) (string, string) {
+		words := strings.FieldsFunc(text, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+		for _, word := range words {
+			word = normalizeReviewWord(word)
+			for _, root := range roots {
+				if strings.HasPrefix(word, root) {
+					return text, reviewFlagProfanity
+				}
+			}
+		}
+		return text, ""
+	}
+}
+
+// normalizeReviewWord приводит слово к нижнему регистру и заменяет ё на е.
+func normalizeReviewWord(word string) string {
+	return strings.ReplaceAll(strings.ToLower(strings.TrimSpace(word)), "ё", "е")
+}
@@ -0,0 +1,519 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"delivery-system/internal/actor"
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/config"
+	"delivery-system/internal/database"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+	"delivery-system/internal/pagination"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// defaultReviewEditWindow — сколько клиент может править отзыв, если окно не задано в конфигурации.
+const defaultReviewEditWindow = 48 * time.Hour
+
+// ReviewService ведет отзывы по заказам: создание и правку клиентом, ответы курьеров и модерацию.
+// Комментарии проходят через фильтры: персональные данные скрываются, отзыв с запрещенными словами
+// ждет модератора. Рейтинг курьера пересчитывает триггер по опубликованным отзывам.
+type ReviewService struct {
+	db         *database.DB
+	log        *logger.Logger
+	moderator  *ReviewModerator
+	editWindow time.Duration
+	now        func() time.Time
+}
+
+// NewReviewService создает сервис отзывов
+func NewReviewService(db *database.DB, log *logger.Logger, cfg *config.ReviewConfig) *ReviewService {
+	editWindow := defaultReviewEditWindow
+	var blockedWords []string
+	if cfg != nil {
+		if cfg.EditWindowHours > 0 {
+			editWindow = time.Duration(cfg.EditWindowHours) * time.Hour
+		}
+		blockedWords = cfg.BlockedWords
+	}
+	return &ReviewService{
+		db:         db,
+		log:        log,
+		moderator:  NewReviewModerator(blockedWords),
+		editWindow: editWindow,
+		now:        time.Now,
+	}
+}
+
+// reviewColumns — колонки отзыва в порядке, который ожидает scanReview.
+const reviewColumns = `id, order_id, courier_id, rating, comment, status, moderation_reason, reply, replied_at, created_at, edited_at, merchant_rating, tags, author`
+
+// scanReview читает отзыв из строки, выбранной с reviewColumns.
+func scanReview(row rowScanner) (*models.Review, error) {
+	r := &models.Review{}
+	var tags pq.StringArray
+	if err := row.Scan(&r.ID, &r.OrderID, &r.CourierID, &r.Rating, &r.Comment, &r.Status, &r.ModerationReason,
+		&r.Reply, &r.RepliedAt, &r.CreatedAt, &r.EditedAt, &r.MerchantRating, &tags, &r.Author); err != nil {
+		return nil, err
+	}
+	for _, tag := range tags {
+		r.Tags = append(r.Tags, models.ReviewTag(tag))
+	}
+	return r, nil
+}
+
+// validateReviewScores проверяет оценки отзыва и возвращает теги без повторов.
+func validateReviewScores(rating int, merchantRating *int, tags []models.ReviewTag) ([]models.ReviewTag, error) {
+	if rating < 1 || rating > 5 {
+		return nil, apperror.Validation("rating must be between 1 and 5", nil)
+	}
+	if merchantRating != nil && (*merchantRating < 1 || *merchantRating > 5) {
+		return nil, apperror.Validation("merchant_rating must be between 1 and 5", nil)
+	}
+
+	unique := make([]models.ReviewTag, 0, len(tags))
+	for _, tag := range tags {
+		if !tag.IsValid() {
+			return nil, apperror.Validation(fmt.Sprintf("unknown review tag %q", tag), nil)
+		}
+		if !containsReviewTag(unique, tag) {
+			unique = append(unique, tag)
+		}
+	}
+	return unique, nil
+}
+
+func containsReviewTag(tags []models.ReviewTag, tag models.ReviewTag) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// reviewTagsArray готовит теги к записи в TEXT[]; пустой список пишется как '{}', а не NULL.
+func reviewTagsArray(tags []models.ReviewTag) pq.StringArray {
+	values := make(pq.StringArray, 0, len(tags))
+	for _, tag := range tags {
+		values = append(values, string(tag))
+	}
+	return values
+}
+
+// publishedRating возвращает оценку и комментарий, которые копируются в orders.rating и
+// orders.review_comment: оценка и текст скрытого или ждущего модератора отзыва в заказ не попадают.
+func publishedRating(review *models.Review) (*int, *string) {
+	if review.Status != models.ReviewStatusPublished {
+		return nil, nil
+	}
+	return &review.Rating, review.Comment
+}
+
+// moderateComment проверяет длину комментария и прогоняет его через фильтры. Возвращает текст
+// для сохранения, состояние отзыва и причину отправки на модерацию.
+func (s *ReviewService) moderateComment(comment *string) (*string, models.ReviewStatus, *string, error) {
+	if comment == nil || strings.TrimSpace(*comment) == "" {
+		return nil, models.ReviewStatusPublished, nil, nil
+	}
+	text := strings.TrimSpace(*comment)
+	if len([]rune(text)) > models.MaxReviewCommentLength {
+		return nil, "", nil, apperror.Validation(fmt.Sprintf("comment must be at most %d characters", models.MaxReviewCommentLength), nil)
+	}
+
+	cleaned, reasons := s.moderator.Check(text)
+	if len(reasons) == 0 {
+		return &cleaned, models.ReviewStatusPublished, nil, nil
+	}
+	reason := strings.Join(reasons, ",")
+	return &cleaned, models.ReviewStatusPending, &reason, nil
+}
+
+// CreateReview создает отзыв по доставленному заказу от имени исполнителя из контекста. Отзыв
+// с запрещенными словами сохраняется в состоянии pending; рейтинг курьера обновляет триггер.
+func (s *ReviewService) CreateReview(ctx context.Context, orderID uuid.UUID, req *models.CreateReviewRequest) (*models.Review, error) {
+	tags, err := validateReviewScores(req.Rating, req.MerchantRating, req.Tags)
+	if err != nil {
+		return nil, err
+	}
+	a := actor.FromContext(ctx)
+	if a.Type == actor.TypeCourier {
+		return nil, apperror.Forbidden("couriers cannot leave reviews", nil)
+	}
+	comment, status, reason, err := s.moderateComment(req.Comment)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	// Получаем заказ и привязанного курьера, проверяем статус и отсутствие отзыва. Отзыв ищется
+	// в reviews: у скрытого отзыва оценки в заказе нет, но второй отзыв по заказу все равно не нужен
+	var courierID uuid.UUID
+	var orderStatus models.OrderStatus
+	var reviewed bool
+	var merchantName sql.NullString
+
+	query := `
+		SELECT courier_id, status, EXISTS (SELECT 1 FROM reviews WHERE order_id = orders.id), merchant_name
+		FROM orders
+		WHERE id = $1
+		FOR UPDATE
+	`
+
+	if err := tx.QueryRowContext(ctx, query, orderID).Scan(&courierID, &orderStatus, &reviewed, &merchantName); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperror.NotFound("order not found", err)
+		}
+		return nil, fmt.Errorf("failed to fetch order for review: %w", err)
+	}
+
+	if courierID == uuid.Nil {
+		return nil, apperror.Conflict("order has no assigned courier", nil)
+	}
+
+	if orderStatus != models.OrderStatusDelivered {
+		return nil, apperror.Conflict("order is not delivered yet", nil)
+	}
+
+	if reviewed {
+		return nil, apperror.Conflict("review already exists for this order", nil)
+	}
+
+	if req.MerchantRating != nil && !merchantName.Valid {
+		return nil, apperror.Validation("order has no merchant to rate", nil)
+	}
+
+	author := a.String()
+	review := &models.Review{
+		ID:               uuid.New(),
+		OrderID:          orderID,
+		CourierID:        courierID,
+		Rating:           req.Rating,
+		MerchantRating:   req.MerchantRating,
+		Tags:             tags,
+		Comment:          comment,
+		Status:           status,
+		ModerationReason: reason,
+		CreatedAt:        s.now(),
+		Author:           &author,
+	}
+
+	insertReviewQuery := `
+		INSERT INTO reviews (id, order_id, courier_id, rating, comment, status, moderation_reason, created_at, merchant_rating, tags, author)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+	if _, err := tx.ExecContext(ctx, insertReviewQuery, review.ID, review.OrderID, review.CourierID, review.Rating, review.Comment,
+		review.Status, review.ModerationReason, review.CreatedAt, review.MerchantRating, reviewTagsArray(review.Tags), author); err != nil {
+		return nil, fmt.Errorf("failed to insert review: %w", err)
+	}
+
+	if err := s.syncOrderReviewWithTx(ctx, tx, review); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit review transaction: %w", err)
+	}
+
+	s.log.WithFields(map[string]interface{}{
+		"order_id":   orderID,
+		"courier_id": courierID,
+		"rating":     review.Rating,
+		"status":     review.Status,
+	}).Info("Review created and courier rating update triggered")
+
+	return review, nil
+}
+
+// UpdateReview заменяет оценки, теги и комментарий отзыва по заказу. Править может только автор отзыва
+// в течение окна после создания; скрытый модератором отзыв не правится. Новый текст снова проходит фильтры.
+func (s *ReviewService) UpdateReview(ctx context.Context, orderID uuid.UUID, req *models.UpdateReviewRequest) (*models.Review, error) {
+	tags, err := validateReviewScores(req.Rating, req.MerchantRating, req.Tags)
+	if err != nil {
+		return nil, err
+	}
+	a := actor.FromContext(ctx)
+	if a.Type == actor.TypeCourier {
+		return nil, apperror.Forbidden("couriers cannot edit reviews", nil)
+	}
+	comment, status, reason, err := s.moderateComment(req.Comment)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	review, err := scanReview(tx.QueryRowContext(ctx, `SELECT `+reviewColumns+` FROM reviews WHERE order_id = $1 FOR UPDATE`, orderID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperror.NotFound("review not found", err)
+		}
+		return nil, fmt.Errorf("failed to lock review: %w", err)
+	}
+	// Отзыв анонимного клиента и старый отзыв без автора не правятся: подтвердить авторство нечем
+	if review.Author == nil || a == actor.Anonymous || *review.Author != a.String() {
+		return nil, apperror.Forbidden("only the author can edit the review", nil)
+	}
+	if review.Status == models.ReviewStatusHidden {
+		return nil, apperror.Conflict("hidden review cannot be edited", nil)
+	}
+	now := s.now()
+	if now.Sub(review.CreatedAt) > s.editWindow {
+		return nil, apperror.Conflict(fmt.Sprintf("review can only be edited within %d hours", int(s.editWindow.Hours())), nil)
+	}
+	if req.MerchantRating != nil {
+		var merchantName sql.NullString
+		if err := tx.QueryRowContext(ctx, "SELECT merchant_name FROM orders WHERE id = $1", orderID).Scan(&merchantName); err != nil {
+			return nil, fmt.Errorf("failed to fetch order merchant: %w", err)
+		}
+		if !merchantName.Valid {
+			return nil, apperror.Validation("order has no merchant to rate", nil)
+		}
+	}
+
+	review.Rating = req.Rating
+	review.MerchantRating = req.MerchantRating
+	review.Tags = tags
+	review.Comment = comment
+	review.Status = status
+	review.ModerationReason = reason
+	review.EditedAt = &now
+
+	updateQuery := `
+		UPDATE reviews
+		SET rating = $1, comment = $2, status = $3, moderation_reason = $4, edited_at = $5, merchant_rating = $6, tags = $7
+		WHERE id = $8
+	`
+	if _, err := tx.ExecContext(ctx, updateQuery, review.Rating, review.Comment, review.Status, review.ModerationReason, now,
+		review.MerchantRating, reviewTagsArray(review.Tags), review.ID); err != nil {
+		return nil, fmt.Errorf("failed to update review: %w", err)
+	}
+
+	if err := s.syncOrderReviewWithTx(ctx, tx, review); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit review update: %w", err)
+	}
+
+	s.log.WithFields(map[string]interface{}{
+		"review_id": review.ID,
+		"order_id":  orderID,
+		"rating":    review.Rating,
+		"status":    review.Status,
+	}).Info("Review edited")
+
+	return review, nil
+}
+
+// ReplyToReview сохраняет ответ курьера на отзыв о нем; повторный ответ заменяет прежний.
+// Персональные данные в ответе скрываются, ответ с запрещенными словами отклоняется.
+func (s *ReviewService) ReplyToReview(ctx context.Context, reviewID uuid.UUID, req *models.ReviewReplyRequest) (*models.Review, error) {
+	text := strings.TrimSpace(req.Text)
+	if text == "" {
+		return nil, apperror.Validation("reply text is required", nil)
+	}
+	if len([]rune(text)) > models.MaxReviewReplyLength {
+		return nil, apperror.Validation(fmt.Sprintf("reply must be at most %d characters", models.MaxReviewReplyLength), nil)
+	}
+	a := actor.FromContext(ctx)
+	if a.Type != actor.TypeCourier {
+		return nil, apperror.Forbidden("only the reviewed courier can reply", nil)
+	}
+	text, reasons := s.moderator.Check(text)
+	if len(reasons) > 0 {
+		return nil, apperror.Validation("reply contains prohibited language", nil)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	review, err := s.lockReviewWithTx(ctx, tx, reviewID)
+	if err != nil {
+		return nil, err
+	}
+	if review.CourierID.String() != a.ID {
+		return nil, apperror.Forbidden("only the reviewed courier can reply", nil)
+	}
+	if review.Status == models.ReviewStatusHidden {
+		return nil, apperror.Conflict("cannot reply to a hidden review", nil)
+	}
+
+	now := s.now()
+	if _, err := tx.ExecContext(ctx, "UPDATE reviews SET reply = $1, replied_at = $2 WHERE id = $3", text, now, reviewID); err != nil {
+		return nil, fmt.Errorf("failed to save review reply: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit review reply: %w", err)
+	}
+
+	review.Reply = &text
+	review.RepliedAt = &now
+
+	s.log.WithFields(map[string]interface{}{
+		"review_id":  reviewID,
+		"courier_id": review.CourierID,
+	}).Info("Courier replied to review")
+
+	return review, nil
+}
+
+// ModerateReview публикует или скрывает отзыв. Скрытый отзыв не учитывается в рейтинге курьера,
+// а его оценка и комментарий убираются из заказа. Для скрытия нужна причина.
+func (s *ReviewService) ModerateReview(ctx context.Context, reviewID uuid.UUID, req *models.ModerateReviewRequest) (*models.Review, error) {
+	if req.Status != models.ReviewStatusPublished && req.Status != models.ReviewStatusHidden {
+		return nil, apperror.Validation("status must be published or hidden", nil)
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if req.Status == models.ReviewStatusHidden && reason == "" {
+		return nil, apperror.Validation("reason is required to hide a review", nil)
+	}
+	if len([]rune(reason)) > models.MaxModerationReasonLength {
+		return nil, apperror.Validation(fmt.Sprintf("reason must be at most %d characters", models.MaxModerationReasonLength), nil)
+	}
+	if actor.FromContext(ctx).Type == actor.TypeCourier {
+		return nil, apperror.Forbidden("couriers cannot moderate reviews", nil)
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	review, err := s.lockReviewWithTx(ctx, tx, reviewID)
+	if err != nil {
+		return nil, err
+	}
+	if review.Status == req.Status {
+		return nil, apperror.Conflict(fmt.Sprintf("review is already %s", req.Status), nil)
+	}
+
+	review.Status = req.Status
+	review.ModerationReason = nil
+	if reason != "" {
+		review.ModerationReason = &reason
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE reviews SET status = $1, moderation_reason = $2 WHERE id = $3",
+		review.Status, review.ModerationReason, reviewID); err != nil {
+		return nil, fmt.Errorf("failed to update review status: %w", err)
+	}
+	if err := s.syncOrderReviewWithTx(ctx, tx, review); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit review moderation: %w", err)
+	}
+
+	s.log.WithFields(map[string]interface{}{
+		"review_id":  reviewID,
+		"courier_id": review.CourierID,
+		"status":     review.Status,
+		"reason":     reason,
+	}).Info("Review moderated")
+
+	return review, nil
+}
+
+// lockReviewWithTx блокирует отзыв до конца транзакции.
+func (s *ReviewService) lockReviewWithTx(ctx context.Context, tx *sql.Tx, reviewID uuid.UUID) (*models.Review, error) {
+	review, err := scanReview(tx.QueryRowContext(ctx, `SELECT `+reviewColumns+` FROM reviews WHERE id = $1 FOR UPDATE`, reviewID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, apperror.NotFound("review not found", err)
+		}
+		return nil, fmt.Errorf("failed to lock review: %w", err)
+	}
+	return review, nil
+}
+
+// syncOrderReviewWithTx копирует оценку и комментарий опубликованного отзыва в заказ; для
+// неопубликованного отзыва они в заказе очищаются.
+func (s *ReviewService) syncOrderReviewWithTx(ctx context.Context, tx *sql.Tx, review *models.Review) error {
+	updateOrderQuery := `
+		UPDATE orders
+		SET rating = $1, review_comment = $2, updated_at = $3
+		WHERE id = $4
+	`
+	rating, comment := publishedRating(review)
+	if _, err := tx.ExecContext(ctx, updateOrderQuery, rating, comment, s.now(), review.OrderID); err != nil {
+		return fmt.Errorf("failed to update order with review: %w", err)
+	}
+	return nil
+}
+
+// GetCourierReviews возвращает страницу опубликованных отзывов по курьеру, от новых к старым
+func (s *ReviewService) GetCourierReviews(ctx context.Context, courierID uuid.UUID, page pagination.Request) (*pagination.Page[*models.Review], error) {
+	return s.listReviews(ctx, &courierID, models.ReviewStatusPublished, page)
+}
+
+// ListReviews возвращает страницу отзывов в указанном состоянии, от новых к старым:
+// например, очередь модерации (pending).
+func (s *ReviewService) ListReviews(ctx context.Context, status models.ReviewStatus, page pagination.Request) (*pagination.Page[*models.Review], error) {
+	if !status.IsValid() {
+		return nil, apperror.Validation("invalid review status", nil)
+	}
+	return s.listReviews(ctx, nil, status, page)
+}
+
+func (s *ReviewService) listReviews(ctx context.Context, courierID *uuid.UUID, status models.ReviewStatus, page pagination.Request) (*pagination.Page[*models.Review], error) {
+	if err := page.CheckSort(sortCreatedAt); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + reviewColumns + ` FROM reviews WHERE status = $1`
+	args := []interface{}{status}
+	if courierID != nil {
+		args = append(args, *courierID)
+		query += fmt.Sprintf(" AND courier_id = $%d", len(args))
+	}
+	if page.After != nil {
+		query += " AND " + keysetCondition([]string{"created_at", "id"}, &args, page.After.CreatedAt, page.After.ID)
+	}
+	query += " ORDER BY created_at DESC, id DESC" + pageLimitClause(page, &args)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get reviews: %w", err)
+	}
+	defer rows.Close()
+
+	var reviews []*models.Review
+	for rows.Next() {
+		review, err := scanReview(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan review: %w", err)
+		}
+		reviews = append(reviews, review)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate reviews: %w", err)
+	}
+
+	return pagination.NewPage(reviews, page.Limit, func(r *models.Review) pagination.Cursor {
+		return pagination.Cursor{Sort: sortCreatedAt, CreatedAt: r.CreatedAt, ID: r.ID.String()}
+	}), nil
+}
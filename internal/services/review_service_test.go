@@ -0,0 +1,548 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"delivery-system/internal/actor"
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/config"
+	"delivery-system/internal/models"
+	"delivery-system/internal/pagination"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+// reviewAuthor — исполнитель, от имени которого в тестах создаются и правятся отзывы.
+const reviewAuthor = "user:customer-1"
+
+var reviewRowColumns = []string{"id", "order_id", "courier_id", "rating", "comment", "status", "moderation_reason", "reply", "replied_at", "created_at", "edited_at", "merchant_rating", "tags", "author"}
+
+func reviewerContext() context.Context {
+	return actor.WithContext(context.Background(), actor.Actor{Type: actor.TypeUser, ID: "customer-1"})
+}
+
+func newTestReviewService(t *testing.T) (*ReviewService, sqlmock.Sqlmock, func()) {
+	db, mock := newMockDB(t)
+	service := NewReviewService(db, newTestLogger(), &config.ReviewConfig{EditWindowHours: 24, BlockedWords: []string{"жулик"}})
+	return service, mock, func() { _ = db.Close() }
+}
+
+func TestReviewService_CreateReview_Success(t *testing.T) {
+	service, mock, done := newTestReviewService(t)
+	defer done()
+
+	orderID := uuid.New()
+	courierID := uuid.New()
+	comment := "great delivery"
+	req := &models.CreateReviewRequest{Rating: 5, Comment: &comment}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT courier_id, status, EXISTS .* merchant_name FROM orders").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"courier_id", "status", "reviewed", "merchant_name"}).
+			AddRow(courierID, models.OrderStatusDelivered, false, nil))
+
+	mock.ExpectExec("INSERT INTO reviews").
+		WithArgs(sqlmock.AnyArg(), orderID, courierID, req.Rating, comment, models.ReviewStatusPublished, nil, sqlmock.AnyArg(), nil, "{}", reviewAuthor).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	mock.ExpectExec("UPDATE orders").
+		WithArgs(req.Rating, comment, sqlmock.AnyArg(), orderID).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	mock.ExpectCommit()
+
+	review, err := service.CreateReview(reviewerContext(), orderID, req)
+	if err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+
+	if review.Rating != req.Rating || review.OrderID != orderID || review.CourierID != courierID || review.Status != models.ReviewStatusPublished {
+		t.Fatalf("unexpected review result: %+v", review)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestReviewService_CreateReview_FiltersComment(t *testing.T) {
+	service, mock, done := newTestReviewService(t)
+	defer done()
+
+	orderID := uuid.New()
+	courierID := uuid.New()
+	// Запрещенное слово из конфигурации отправляет отзыв на модерацию, телефон скрывается
+	comment := "Курьер ЖУЛИК, звоните +7 (999) 123-45-67"
+	cleaned := "Курьер ЖУЛИК, звоните " + redactedPlaceholder
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT courier_id, status, EXISTS .* merchant_name FROM orders").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"courier_id", "status", "reviewed", "merchant_name"}).
+			AddRow(courierID, models.OrderStatusDelivered, false, nil))
+	mock.ExpectExec("INSERT INTO reviews").
+		WithArgs(sqlmock.AnyArg(), orderID, courierID, 1, cleaned, models.ReviewStatusPending, reviewFlagProfanity, sqlmock.AnyArg(), nil, "{}", reviewAuthor).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	// Оценка и комментарий неопубликованного отзыва в заказ не копируются
+	mock.ExpectExec("UPDATE orders").
+		WithArgs(nil, nil, sqlmock.AnyArg(), orderID).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	review, err := service.CreateReview(reviewerContext(), orderID, &models.CreateReviewRequest{Rating: 1, Comment: &comment})
+	if err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+	if review.Status != models.ReviewStatusPending || *review.Comment != cleaned {
+		t.Fatalf("unexpected review: %+v", review)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestReviewService_CreateReview_OrderNotFound(t *testing.T) {
+	service, mock, done := newTestReviewService(t)
+	defer done()
+
+	orderID := uuid.New()
+	req := &models.CreateReviewRequest{Rating: 4}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT courier_id, status, EXISTS .* merchant_name FROM orders").
+		WithArgs(orderID).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectRollback()
+
+	if _, err := service.CreateReview(reviewerContext(), orderID, req); !apperror.Is(err, apperror.KindNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestReviewService_CreateReview_NotDelivered(t *testing.T) {
+	service, mock, done := newTestReviewService(t)
+	defer done()
+
+	orderID := uuid.New()
+	courierID := uuid.New()
+	req := &models.CreateReviewRequest{Rating: 3}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT courier_id, status, EXISTS .* merchant_name FROM orders").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"courier_id", "status", "reviewed", "merchant_name"}).
+			AddRow(courierID, models.OrderStatusCreated, false, nil))
+	mock.ExpectRollback()
+
+	if _, err := service.CreateReview(reviewerContext(), orderID, req); err == nil {
+		t.Fatalf("expected error, got nil")
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestReviewService_CreateReview_AlreadyExists(t *testing.T) {
+	service, mock, done := newTestReviewService(t)
+	defer done()
+
+	orderID := uuid.New()
+	courierID := uuid.New()
+	req := &models.CreateReviewRequest{Rating: 4}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT courier_id, status, EXISTS .* merchant_name FROM orders").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"courier_id", "status", "reviewed", "merchant_name"}).
+			AddRow(courierID, models.OrderStatusDelivered, true, nil))
+	mock.ExpectRollback()
+
+	if _, err := service.CreateReview(reviewerContext(), orderID, req); !apperror.Is(err, apperror.KindConflict) {
+		t.Fatalf("expected conflict, got %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestReviewService_CreateReview_InvalidRating(t *testing.T) {
+	service, _, done := newTestReviewService(t)
+	defer done()
+
+	if _, err := service.CreateReview(reviewerContext(), uuid.New(), &models.CreateReviewRequest{Rating: 6}); !apperror.Is(err, apperror.KindValidation) {
+		t.Fatalf("expected validation error for invalid rating, got %v", err)
+	}
+	courier := actor.WithContext(context.Background(), actor.Actor{Type: actor.TypeCourier, ID: uuid.New().String()})
+	if _, err := service.CreateReview(courier, uuid.New(), &models.CreateReviewRequest{Rating: 5}); !apperror.Is(err, apperror.KindForbidden) {
+		t.Fatalf("expected forbidden for courier, got %v", err)
+	}
+}
+
+func TestReviewService_CreateReview_MerchantRatingAndTags(t *testing.T) {
+	service, mock, done := newTestReviewService(t)
+	defer done()
+
+	orderID := uuid.New()
+	courierID := uuid.New()
+	merchantRating := 2
+	req := &models.CreateReviewRequest{
+		Rating:         5,
+		MerchantRating: &merchantRating,
+		Tags:           []models.ReviewTag{models.ReviewTagColdFood, models.ReviewTagGreatService, models.ReviewTagColdFood},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT courier_id, status, EXISTS .* merchant_name FROM orders").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"courier_id", "status", "reviewed", "merchant_name"}).
+			AddRow(courierID, models.OrderStatusDelivered, false, "Pizzeria"))
+	mock.ExpectExec("INSERT INTO reviews").
+		WithArgs(sqlmock.AnyArg(), orderID, courierID, 5, nil, models.ReviewStatusPublished, nil, sqlmock.AnyArg(), merchantRating, "{\"cold_food\",\"great_service\"}", reviewAuthor).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("UPDATE orders").
+		WithArgs(5, nil, sqlmock.AnyArg(), orderID).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	review, err := service.CreateReview(reviewerContext(), orderID, req)
+	if err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+	if len(review.Tags) != 2 || review.MerchantRating == nil || *review.MerchantRating != merchantRating {
+		t.Fatalf("unexpected review: %+v", review)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestReviewService_CreateReview_MerchantRatingWithoutMerchant(t *testing.T) {
+	service, mock, done := newTestReviewService(t)
+	defer done()
+
+	orderID := uuid.New()
+	merchantRating := 4
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT courier_id, status, EXISTS .* merchant_name FROM orders").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"courier_id", "status", "reviewed", "merchant_name"}).
+			AddRow(uuid.New(), models.OrderStatusDelivered, false, nil))
+	mock.ExpectRollback()
+
+	_, err := service.CreateReview(reviewerContext(), orderID, &models.CreateReviewRequest{Rating: 4, MerchantRating: &merchantRating})
+	if !apperror.Is(err, apperror.KindValidation) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestValidateReviewScores(t *testing.T) {
+	zero, six := 0, 6
+	cases := []struct {
+		name           string
+		merchantRating *int
+		tags           []models.ReviewTag
+	}{
+		{"merchant rating too low", &zero, nil},
+		{"merchant rating too high", &six, nil},
+		{"unknown tag", nil, []models.ReviewTag{models.ReviewTagLate, "slow"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := validateReviewScores(4, tc.merchantRating, tc.tags); !apperror.Is(err, apperror.KindValidation) {
+				t.Fatalf("expected validation error, got %v", err)
+			}
+		})
+	}
+}
+
+func TestReviewService_UpdateReview(t *testing.T) {
+	service, mock, done := newTestReviewService(t)
+	defer done()
+
+	now := time.Now()
+	service.now = func() time.Time { return now }
+	reviewID, orderID, courierID := uuid.New(), uuid.New(), uuid.New()
+	comment := "Привез вовремя"
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, order_id, courier_id, rating, comment, status, .* FROM reviews WHERE order_id = \\$1 FOR UPDATE").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows(reviewRowColumns).
+			AddRow(reviewID, orderID, courierID, 2, "опоздал", models.ReviewStatusPublished, nil, nil, nil, now.Add(-2*time.Hour), nil, nil, "{}", reviewAuthor))
+	mock.ExpectExec("UPDATE reviews").
+		WithArgs(4, comment, models.ReviewStatusPublished, nil, now, nil, "{}", reviewID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE orders").
+		WithArgs(4, comment, now, orderID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	review, err := service.UpdateReview(reviewerContext(), orderID, &models.UpdateReviewRequest{Rating: 4, Comment: &comment})
+	if err != nil {
+		t.Fatalf("UpdateReview returned error: %v", err)
+	}
+	if review.Rating != 4 || review.EditedAt == nil || !review.EditedAt.Equal(now) {
+		t.Fatalf("unexpected review: %+v", review)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestReviewService_UpdateReview_Rejected(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		name      string
+		status    models.ReviewStatus
+		createdAt time.Time
+		kind      apperror.Kind
+	}{
+		{"edit window expired", models.ReviewStatusPublished, now.Add(-25 * time.Hour), apperror.KindConflict},
+		{"hidden review", models.ReviewStatusHidden, now.Add(-time.Hour), apperror.KindConflict},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			service, mock, done := newTestReviewService(t)
+			defer done()
+			service.now = func() time.Time { return now }
+			orderID := uuid.New()
+
+			mock.ExpectBegin()
+			mock.ExpectQuery("SELECT id, order_id, courier_id, rating, comment, status, .* FROM reviews WHERE order_id = \\$1 FOR UPDATE").
+				WithArgs(orderID).
+				WillReturnRows(sqlmock.NewRows(reviewRowColumns).
+					AddRow(uuid.New(), orderID, uuid.New(), 2, nil, tc.status, nil, nil, nil, tc.createdAt, nil, nil, "{}", reviewAuthor))
+			mock.ExpectRollback()
+
+			if _, err := service.UpdateReview(reviewerContext(), orderID, &models.UpdateReviewRequest{Rating: 5}); !apperror.Is(err, tc.kind) {
+				t.Fatalf("expected %s, got %v", tc.kind, err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unmet expectations: %v", err)
+			}
+		})
+	}
+
+	service, _, done := newTestReviewService(t)
+	defer done()
+	ctx := actor.WithContext(context.Background(), actor.Actor{Type: actor.TypeCourier, ID: uuid.New().String()})
+	if _, err := service.UpdateReview(ctx, uuid.New(), &models.UpdateReviewRequest{Rating: 5}); !apperror.Is(err, apperror.KindForbidden) {
+		t.Fatalf("expected forbidden for courier, got %v", err)
+	}
+}
+
+func TestReviewService_UpdateReview_NotAuthor(t *testing.T) {
+	other := actor.WithContext(context.Background(), actor.Actor{Type: actor.TypeUser, ID: "customer-2"})
+	cases := []struct {
+		name   string
+		ctx    context.Context
+		author interface{}
+	}{
+		{"another customer", other, reviewAuthor},
+		{"anonymous caller", actor.WithContext(context.Background(), actor.Anonymous), actor.Anonymous.String()},
+		{"review without author", reviewerContext(), nil},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			service, mock, done := newTestReviewService(t)
+			defer done()
+			orderID := uuid.New()
+
+			mock.ExpectBegin()
+			mock.ExpectQuery("SELECT id, order_id, courier_id, rating, comment, status, .* FROM reviews WHERE order_id = \\$1 FOR UPDATE").
+				WithArgs(orderID).
+				WillReturnRows(sqlmock.NewRows(reviewRowColumns).
+					AddRow(uuid.New(), orderID, uuid.New(), 2, nil, models.ReviewStatusPublished, nil, nil, nil, time.Now(), nil, nil, "{}", tc.author))
+			mock.ExpectRollback()
+
+			if _, err := service.UpdateReview(tc.ctx, orderID, &models.UpdateReviewRequest{Rating: 5}); !apperror.Is(err, apperror.KindForbidden) {
+				t.Fatalf("expected forbidden, got %v", err)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Fatalf("unmet expectations: %v", err)
+			}
+		})
+	}
+}
+
+func TestReviewService_ReplyToReview(t *testing.T) {
+	service, mock, done := newTestReviewService(t)
+	defer done()
+
+	reviewID, orderID, courierID := uuid.New(), uuid.New(), uuid.New()
+	ctx := actor.WithContext(context.Background(), actor.Actor{Type: actor.TypeCourier, ID: courierID.String()})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, order_id, courier_id, .* FROM reviews WHERE id = \\$1 FOR UPDATE").
+		WithArgs(reviewID).
+		WillReturnRows(sqlmock.NewRows(reviewRowColumns).
+			AddRow(reviewID, orderID, courierID, 3, "долго", models.ReviewStatusPublished, nil, nil, nil, time.Now(), nil, nil, "{}", reviewAuthor))
+	mock.ExpectExec("UPDATE reviews SET reply").
+		WithArgs("Извините, была пробка. Пишите на "+redactedPlaceholder, sqlmock.AnyArg(), reviewID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	review, err := service.ReplyToReview(ctx, reviewID, &models.ReviewReplyRequest{Text: "Извините, была пробка. Пишите на courier@example.com"})
+	if err != nil {
+		t.Fatalf("ReplyToReview returned error: %v", err)
+	}
+	if review.Reply == nil || review.RepliedAt == nil {
+		t.Fatalf("expected reply to be set: %+v", review)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+
+	// Ответ с запрещенными словами отклоняется без обращения к базе
+	if _, err := service.ReplyToReview(ctx, reviewID, &models.ReviewReplyRequest{Text: "сам ты жулик"}); !apperror.Is(err, apperror.KindValidation) {
+		t.Fatalf("expected validation error, got %v", err)
+	}
+	if _, err := service.ReplyToReview(context.Background(), reviewID, &models.ReviewReplyRequest{Text: "Спасибо"}); !apperror.Is(err, apperror.KindForbidden) {
+		t.Fatalf("expected forbidden for non-courier, got %v", err)
+	}
+}
+
+func TestReviewService_ReplyToReview_OtherCourier(t *testing.T) {
+	service, mock, done := newTestReviewService(t)
+	defer done()
+
+	reviewID := uuid.New()
+	ctx := actor.WithContext(context.Background(), actor.Actor{Type: actor.TypeCourier, ID: uuid.New().String()})
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, order_id, courier_id, .* FROM reviews WHERE id = \\$1 FOR UPDATE").
+		WithArgs(reviewID).
+		WillReturnRows(sqlmock.NewRows(reviewRowColumns).
+			AddRow(reviewID, uuid.New(), uuid.New(), 3, nil, models.ReviewStatusPublished, nil, nil, nil, time.Now(), nil, nil, "{}", reviewAuthor))
+	mock.ExpectRollback()
+
+	if _, err := service.ReplyToReview(ctx, reviewID, &models.ReviewReplyRequest{Text: "Спасибо"}); !apperror.Is(err, apperror.KindForbidden) {
+		t.Fatalf("expected forbidden, got %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestReviewService_ModerateReview_Hide(t *testing.T) {
+	service, mock, done := newTestReviewService(t)
+	defer done()
+
+	reviewID, orderID, courierID := uuid.New(), uuid.New(), uuid.New()
+	reason := "оскорбления"
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT id, order_id, courier_id, .* FROM reviews WHERE id = \\$1 FOR UPDATE").
+		WithArgs(reviewID).
+		WillReturnRows(sqlmock.NewRows(reviewRowColumns).
+			AddRow(reviewID, orderID, courierID, 1, "текст", models.ReviewStatusPending, reviewFlagProfanity, nil, nil, time.Now(), nil, nil, "{}", reviewAuthor))
+	mock.ExpectExec("UPDATE reviews SET status").
+		WithArgs(models.ReviewStatusHidden, reason, reviewID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE orders").
+		WithArgs(nil, nil, sqlmock.AnyArg(), orderID).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	review, err := service.ModerateReview(context.Background(), reviewID, &models.ModerateReviewRequest{Status: models.ReviewStatusHidden, Reason: reason})
+	if err != nil {
+		t.Fatalf("ModerateReview returned error: %v", err)
+	}
+	if review.Status != models.ReviewStatusHidden || review.CourierID != courierID {
+		t.Fatalf("unexpected review: %+v", review)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestReviewService_ModerateReview_Validation(t *testing.T) {
+	service, _, done := newTestReviewService(t)
+	defer done()
+
+	cases := []struct {
+		name string
+		ctx  context.Context
+		req  *models.ModerateReviewRequest
+		kind apperror.Kind
+	}{
+		{"pending is not a decision", context.Background(), &models.ModerateReviewRequest{Status: models.ReviewStatusPending}, apperror.KindValidation},
+		{"hide without reason", context.Background(), &models.ModerateReviewRequest{Status: models.ReviewStatusHidden}, apperror.KindValidation},
+		{"courier", actor.WithContext(context.Background(), actor.Actor{Type: actor.TypeCourier, ID: uuid.New().String()}),
+			&models.ModerateReviewRequest{Status: models.ReviewStatusPublished}, apperror.KindForbidden},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := service.ModerateReview(tc.ctx, uuid.New(), tc.req); !apperror.Is(err, tc.kind) {
+				t.Fatalf("expected %s, got %v", tc.kind, err)
+			}
+		})
+	}
+}
+
+func TestReviewService_GetCourierReviews(t *testing.T) {
+	service, mock, done := newTestReviewService(t)
+	defer done()
+
+	courierID := uuid.New()
+	limit, offset := 10, 20
+
+	rows := sqlmock.NewRows(reviewRowColumns).
+		AddRow(uuid.New(), uuid.New(), courierID, 5, "great", models.ReviewStatusPublished, nil, nil, nil, time.Now(), nil, nil, "{}", reviewAuthor).
+		AddRow(uuid.New(), uuid.New(), courierID, 4, "ok", models.ReviewStatusPublished, nil, "Спасибо", time.Now(), time.Now(), nil, nil, "{}", reviewAuthor)
+
+	mock.ExpectQuery("SELECT id, order_id, courier_id, rating, comment, .* FROM reviews WHERE status = \\$1 AND courier_id = \\$2").
+		WithArgs(models.ReviewStatusPublished, courierID, limit+1, offset).
+		WillReturnRows(rows)
+
+	page, err := service.GetCourierReviews(context.Background(), courierID, pagination.Request{Limit: limit, Offset: offset})
+	if err != nil {
+		t.Fatalf("expected success, got error: %v", err)
+	}
+
+	if len(page.Items) != 2 || page.Items[1].Reply == nil {
+		t.Fatalf("unexpected reviews: %+v", page.Items)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestReviewModerator_Check(t *testing.T) {
+	moderator := NewReviewModerator(nil)
+
+	cases := []struct {
+		text    string
+		want    string
+		flagged bool
+	}{
+		{"Все отлично, спасибо!", "Все отлично, спасибо!", false},
+		{"Мой номер 8 999 123 45 67, почта anna@mail.ru", "Мой номер " + redactedPlaceholder + ", почта " + redactedPlaceholder, false},
+		{"Карта 4111-1111-1111-1111", "Карта " + redactedPlaceholder, false},
+		{"Курьер — мудак", "Курьер — мудак", true},
+		{"Ёбаный сервис", "Ёбаный сервис", true},
+		{"Заказ 12345 пришел", "Заказ 12345 пришел", false},
+	}
+	for _, tc := range cases {
+		got, reasons := moderator.Check(tc.text)
+		if got != tc.want || (len(reasons) > 0) != tc.flagged {
+			t.Fatalf("Check(%q) = %q %v, want %q flagged=%v", tc.text, got, reasons, tc.want, tc.flagged)
+		}
+	}
+}
@@ -0,0 +1,223 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"delivery-system/internal/apperror"
+	"delivery-system/internal/database"
+	"delivery-system/internal/logger"
+	"delivery-system/internal/models"
+	"delivery-system/internal/money"
+	"delivery-system/internal/pagination"
+
+	"github.com/google/uuid"
+)
+
+// WalletService ведет бонусные счета клиентов. Клиент определяется номером телефона без форматирования,
+// остаток хранится отдельно по каждой валюте.
+type WalletService struct {
+	db  *database.DB
+	log *logger.Logger
+}
+
+// NewWalletService создает сервис бонусных счетов.
+func NewWalletService(db *database.DB, log *logger.Logger) *WalletService {
+	return &WalletService{
+		db:  db,
+		log: log,
+	}
+}
+
+// GetWallet возвращает остатки клиента и страницу его операций, новые первыми.
+func (s *WalletService) GetWallet(ctx context.Context, customerPhone string, page pagination.Request) (*models.Wallet, error) {
+	phone := normalizePhone(customerPhone)
+	if phone == "" {
+		return nil, apperror.Validation("customer phone is required", nil)
+	}
+	if err := page.CheckSort(sortCreatedAt); err != nil {
+		return nil, err
+	}
+	if page.Limit <= 0 {
+		page.Limit = 50
+	}
+
+	wallet := &models.Wallet{
+		CustomerPhone: phone,
+		Balances:      []models.WalletBalance{},
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+		SELECT currency, balance
+		FROM wallets
+		WHERE customer_phone = $1
+		ORDER BY currency
+	`, phone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wallet balances: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var b models.WalletBalance
+		if err := rows.Scan(&b.Currency, &b.Balance); err != nil {
+			return nil, fmt.Errorf("failed to scan wallet balance: %w", err)
+		}
+		b.Balance = b.Balance.In(b.Currency)
+		wallet.Balances = append(wallet.Balances, b)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate wallet balances: %w", err)
+	}
+
+	query := `
+		SELECT id, customer_phone, kind, amount, currency, order_id, referral_id, created_at
+		FROM wallet_transactions
+		WHERE customer_phone = $1`
+	args := []interface{}{phone}
+	if page.After != nil {
+		query += " AND " + keysetCondition([]string{"created_at", "id"}, &args, page.After.CreatedAt, page.After.ID)
+	}
+	query += " ORDER BY created_at DESC, id DESC" + pageLimitClause(page, &args)
+
+	txRows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get wallet transactions: %w", err)
+	}
+	defer txRows.Close()
+
+	var transactions []*models.WalletTransaction
+	for txRows.Next() {
+		t := &models.WalletTransaction{}
+		if err := txRows.Scan(&t.ID, &t.CustomerPhone, &t.Kind, &t.Amount, &t.Currency, &t.OrderID, &t.ReferralID, &t.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan wallet transaction: %w", err)
+		}
+		t.Amount = t.Amount.In(t.Currency)
+		transactions = append(transactions, t)
+	}
+	if err := txRows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate wallet transactions: %w", err)
+	}
+
+	wallet.Transactions = pagination.NewPage(transactions, page.Limit, func(t *models.WalletTransaction) pagination.Cursor {
+		return pagination.Cursor{Sort: sortCreatedAt, CreatedAt: t.CreatedAt, ID: t.ID.String()}
+	})
+	return wallet, nil
+}
+
+// CreditWithTx начисляет бонусы на счет клиента в рамках переданной транзакции.
+func (s *WalletService) CreditWithTx(ctx context.Context, tx *sql.Tx, entry *models.WalletTransaction) error {
+	if !entry.Amount.IsPositive() {
+		return nil
+	}
+
+	now := time.Now()
+	upsertQuery := `
+		INSERT INTO wallets (customer_phone, currency, balance, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (customer_phone, currency)
+		DO UPDATE SET balance = wallets.balance + EXCLUDED.balance, updated_at = EXCLUDED.updated_at
+	`
+	if _, err := tx.ExecContext(ctx, upsertQuery, entry.CustomerPhone, entry.Currency, entry.Amount, now); err != nil {
+		return fmt.Errorf("failed to credit wallet: %w", err)
+	}
+
+	entry.ID = uuid.New()
+	entry.CreatedAt = now
+	return insertWalletTransaction(ctx, tx, entry)
+}
+
+// SpendWithTx списывает бонусы клиента в счет заказа, но не больше limit. Строка счета блокируется,
+// чтобы параллельные заказы не потратили один остаток дважды. Возвращает списанную сумму.
+func (s *WalletService) SpendWithTx(ctx context.Context, tx *sql.Tx, customerPhone string, orderID uuid.UUID, limit money.Money) (money.Money, error) {
+	spent := money.Zero(limit.Currency)
+	phone := normalizePhone(customerPhone)
+	if phone == "" || !limit.IsPositive() {
+		return spent, nil
+	}
+
+	balance := money.Zero(limit.Currency)
+	selectQuery := `
+		SELECT balance
+		FROM wallets
+		WHERE customer_phone = $1 AND currency = $2
+		FOR UPDATE
+	`
+	if err := tx.QueryRowContext(ctx, selectQuery, phone, limit.Currency).Scan(&balance); err != nil {
+		if err == sql.ErrNoRows {
+			return spent, nil
+		}
+		return spent, fmt.Errorf("failed to lock wallet: %w", err)
+	}
+
+	spent = money.Min(balance.In(limit.Currency), limit)
+	if !spent.IsPositive() {
+		return money.Zero(limit.Currency), nil
+	}
+
+	now := time.Now()
+	updateQuery := `
+		UPDATE wallets
+		SET balance = balance - $1, updated_at = $2
+		WHERE customer_phone = $3 AND currency = $4
+	`
+	if _, err := tx.ExecContext(ctx, updateQuery, spent, now, phone, limit.Currency); err != nil {
+		return spent, fmt.Errorf("failed to debit wallet: %w", err)
+	}
+
+	entry := &models.WalletTransaction{
+		ID:            uuid.New(),
+		CustomerPhone: phone,
+		Kind:          models.WalletKindOrderPayment,
+		Amount:        spent.Neg(),
+		Currency:      limit.Currency,
+		OrderID:       &orderID,
+		CreatedAt:     now,
+	}
+	if err := insertWalletTransaction(ctx, tx, entry); err != nil {
+		return spent, err
+	}
+
+	return spent, nil
+}
+
+// RefundOrderWithTx возвращает на счет бонусы, списанные в счет отмененного заказа.
+func (s *WalletService) RefundOrderWithTx(ctx context.Context, tx *sql.Tx, orderID uuid.UUID) error {
+	var (
+		phone    string
+		currency string
+		amount   money.Money
+	)
+	query := `
+		SELECT customer_phone, currency, amount
+		FROM wallet_transactions
+		WHERE order_id = $1 AND kind = 'order_payment'
+	`
+	if err := tx.QueryRowContext(ctx, query, orderID).Scan(&phone, &currency, &amount); err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return fmt.Errorf("failed to get wallet payment: %w", err)
+	}
+
+	return s.CreditWithTx(ctx, tx, &models.WalletTransaction{
+		CustomerPhone: phone,
+		Kind:          models.WalletKindOrderRefund,
+		Amount:        amount.In(currency).Neg(),
+		Currency:      currency,
+		OrderID:       &orderID,
+	})
+}
+
+func insertWalletTransaction(ctx context.Context, tx *sql.Tx, t *models.WalletTransaction) error {
+	query := `
+		INSERT INTO wallet_transactions (id, customer_phone, kind, amount, currency, order_id, referral_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	if _, err := tx.ExecContext(ctx, query, t.ID, t.CustomerPhone, t.Kind, t.Amount, t.Currency, t.OrderID, t.ReferralID, t.CreatedAt); err != nil {
+		return fmt.Errorf("failed to record wallet transaction: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"delivery-system/internal/models"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/google/uuid"
+)
+
+func TestWalletService_SpendWithTx_CapsAtLimit(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewWalletService(db, newTestLogger())
+	orderID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT balance FROM wallets").
+		WithArgs("79990000002", "RUB").
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}).AddRow("500.00"))
+	mock.ExpectExec("UPDATE wallets").
+		WithArgs(rub(320), sqlmock.AnyArg(), "79990000002", "RUB").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO wallet_transactions").
+		WithArgs(sqlmock.AnyArg(), "79990000002", models.WalletKindOrderPayment, rub(-320), "RUB", &orderID, nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("failed to begin tx: %v", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	spent, err := service.SpendWithTx(context.Background(), tx, "+7 999 000-00-02", orderID, rub(320))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if spent != rub(320) {
+		t.Fatalf("expected 320.00 spent, got %s", spent)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestWalletService_SpendWithTx_NoWallet(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewWalletService(db, newTestLogger())
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT balance FROM wallets").
+		WithArgs("79990000002", "RUB").
+		WillReturnRows(sqlmock.NewRows([]string{"balance"}))
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("failed to begin tx: %v", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	spent, err := service.SpendWithTx(context.Background(), tx, "79990000002", uuid.New(), rub(100))
+	if err != nil || !spent.IsZero() {
+		t.Fatalf("expected nothing spent, got %s err=%v", spent, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
+
+func TestWalletService_RefundOrderWithTx(t *testing.T) {
+	db, mock := newMockDB(t)
+	defer db.Close()
+
+	service := NewWalletService(db, newTestLogger())
+	orderID := uuid.New()
+
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT customer_phone, currency, amount FROM wallet_transactions").
+		WithArgs(orderID).
+		WillReturnRows(sqlmock.NewRows([]string{"customer_phone", "currency", "amount"}).AddRow("79990000002", "RUB", "-150.00"))
+	mock.ExpectExec("INSERT INTO wallets").
+		WithArgs("79990000002", "RUB", rub(150), sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO wallet_transactions").
+		WithArgs(sqlmock.AnyArg(), "79990000002", models.WalletKindOrderRefund, rub(150), "RUB", &orderID, nil, sqlmock.AnyArg()).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("failed to begin tx: %v", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if err := service.RefundOrderWithTx(context.Background(), tx, orderID); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatalf("unmet expectations: %v", err)
+	}
+}
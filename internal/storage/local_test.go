@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"delivery-system/internal/config"
+)
+
+func TestLocalStorage_PutOpenDelete(t *testing.T) {
+	s, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+
+	ctx := context.Background()
+	n, err := s.Put(ctx, "proofs/order-1/photo", strings.NewReader("image-bytes"))
+	if err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	if n != int64(len("image-bytes")) {
+		t.Fatalf("expected %d bytes written, got %d", len("image-bytes"), n)
+	}
+
+	rc, err := s.Open(ctx, "proofs/order-1/photo")
+	if err != nil {
+		t.Fatalf("open failed: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	_ = rc.Close()
+	if string(data) != "image-bytes" {
+		t.Fatalf("unexpected content: %q", data)
+	}
+
+	if err := s.Delete(ctx, "proofs/order-1/photo"); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if _, err := s.Open(ctx, "proofs/order-1/photo"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound after delete, got %v", err)
+	}
+
+	// Повторное удаление не должно быть ошибкой
+	if err := s.Delete(ctx, "proofs/order-1/photo"); err != nil {
+		t.Fatalf("expected idempotent delete, got %v", err)
+	}
+}
+
+func TestLocalStorage_RejectsPathTraversal(t *testing.T) {
+	s, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+
+	for _, key := range []string{"", "/etc/passwd", "../outside", "a/../../outside", ".."} {
+		if _, err := s.Put(context.Background(), key, strings.NewReader("x")); err == nil {
+			t.Fatalf("expected error for key %q", key)
+		}
+	}
+}
+
+func TestLocalStorage_PutCancelledContext(t *testing.T) {
+	s, err := NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatalf("failed to create storage: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := s.Put(ctx, "blob", strings.NewReader("data")); err == nil {
+		t.Fatalf("expected error for cancelled context")
+	}
+	if _, err := s.Open(context.Background(), "blob"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected no blob after failed put, got %v", err)
+	}
+}
+
+func TestNew_Providers(t *testing.T) {
+	if _, err := New(&config.StorageConfig{Provider: "local", LocalDir: t.TempDir()}); err != nil {
+		t.Fatalf("expected local provider, got %v", err)
+	}
+	if _, err := New(&config.StorageConfig{Provider: "s3"}); err == nil {
+		t.Fatalf("expected error for unsupported provider")
+	}
+}
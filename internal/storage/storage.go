@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"delivery-system/internal/config"
+)
+
+// ErrNotFound возвращается, если объект с указанным ключом отсутствует.
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStorage описывает хранилище бинарных объектов (фото, подписи, документы).
+// Реализации должны быть безопасны для конкурентного использования.
+type BlobStorage interface {
+	// Put сохраняет содержимое reader под ключом key и возвращает количество записанных байт.
+	Put(ctx context.Context, key string, r io.Reader) (int64, error)
+	// Open открывает объект на чтение. Вызывающая сторона обязана закрыть reader.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete удаляет объект. Отсутствие объекта ошибкой не считается.
+	Delete(ctx context.Context, key string) error
+}
+
+// New создает хранилище согласно конфигурации.
+func New(cfg *config.StorageConfig) (BlobStorage, error) {
+	switch strings.ToLower(cfg.Provider) {
+	case "", "local":
+		return NewLocalStorage(cfg.LocalDir)
+	default:
+		return nil, fmt.Errorf("unsupported storage provider: %s", cfg.Provider)
+	}
+}
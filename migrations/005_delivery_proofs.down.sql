@@ -0,0 +1,7 @@
+-- Откат подтверждений доставки
+
+DROP INDEX IF EXISTS idx_delivery_proofs_order_id;
+DROP TABLE IF EXISTS delivery_proofs;
+
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS handoff_pin;
@@ -0,0 +1,16 @@
+-- Подтверждение доставки: PIN-код передачи и вложения (фото/подпись)
+
+ALTER TABLE orders
+    ADD COLUMN handoff_pin VARCHAR(4);
+
+CREATE TABLE delivery_proofs (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    proof_type VARCHAR(20) NOT NULL CHECK (proof_type IN ('pin', 'photo', 'signature')),
+    storage_key TEXT, -- ключ в хранилище (для фото и подписи)
+    content_type VARCHAR(100),
+    size_bytes BIGINT NOT NULL DEFAULT 0,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_delivery_proofs_order_id ON delivery_proofs(order_id);
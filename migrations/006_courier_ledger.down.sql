@@ -0,0 +1,12 @@
+-- Откат журнала начислений курьерам
+
+DROP TRIGGER IF EXISTS trg_check_ledger_transaction_balanced ON ledger_entries;
+DROP FUNCTION IF EXISTS check_ledger_transaction_balanced();
+
+DROP INDEX IF EXISTS idx_ledger_entries_account_courier;
+DROP INDEX IF EXISTS idx_ledger_entries_transaction_id;
+DROP TABLE IF EXISTS ledger_entries;
+
+DROP INDEX IF EXISTS idx_ledger_transactions_delivery_order;
+DROP INDEX IF EXISTS idx_ledger_transactions_courier_created;
+DROP TABLE IF EXISTS ledger_transactions;
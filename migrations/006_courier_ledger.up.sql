@@ -0,0 +1,53 @@
+-- Двойная запись начислений курьерам: проводки и их строки
+
+CREATE TABLE ledger_transactions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    courier_id UUID NOT NULL REFERENCES couriers(id) ON DELETE RESTRICT,
+    order_id UUID REFERENCES orders(id) ON DELETE SET NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('delivery', 'tip', 'bonus', 'adjustment')),
+    amount DECIMAL(12, 2) NOT NULL, -- сумма для курьера (может быть отрицательной для корректировок)
+    description TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_ledger_transactions_courier_created ON ledger_transactions(courier_id, created_at);
+-- Начисление за доставку создается не более одного раза на заказ
+CREATE UNIQUE INDEX idx_ledger_transactions_delivery_order ON ledger_transactions(order_id) WHERE kind = 'delivery';
+
+CREATE TABLE ledger_entries (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    transaction_id UUID NOT NULL REFERENCES ledger_transactions(id) ON DELETE CASCADE,
+    account VARCHAR(50) NOT NULL,
+    courier_id UUID REFERENCES couriers(id) ON DELETE RESTRICT, -- заполняется для счета courier_payable
+    direction VARCHAR(6) NOT NULL CHECK (direction IN ('debit', 'credit')),
+    amount DECIMAL(12, 2) NOT NULL CHECK (amount > 0),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_ledger_entries_transaction_id ON ledger_entries(transaction_id);
+CREATE INDEX idx_ledger_entries_account_courier ON ledger_entries(account, courier_id);
+
+-- Проверка баланса проводки: сумма дебетов равна сумме кредитов (на момент коммита)
+CREATE OR REPLACE FUNCTION check_ledger_transaction_balanced()
+RETURNS TRIGGER AS $$
+DECLARE
+    diff DECIMAL(12, 2);
+BEGIN
+    SELECT COALESCE(SUM(CASE WHEN direction = 'debit' THEN amount ELSE -amount END), 0)
+    INTO diff
+    FROM ledger_entries
+    WHERE transaction_id = NEW.transaction_id;
+
+    IF diff <> 0 THEN
+        RAISE EXCEPTION 'ledger transaction % is not balanced', NEW.transaction_id;
+    END IF;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER trg_check_ledger_transaction_balanced
+AFTER INSERT OR UPDATE ON ledger_entries
+DEFERRABLE INITIALLY DEFERRED
+FOR EACH ROW
+EXECUTE FUNCTION check_ledger_transaction_balanced();
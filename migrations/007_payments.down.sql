@@ -0,0 +1,7 @@
+-- Откат платежей
+
+DROP TABLE IF EXISTS payment_webhook_events;
+
+DROP TRIGGER IF EXISTS update_payments_updated_at ON payments;
+DROP INDEX IF EXISTS idx_payments_provider_ref;
+DROP TABLE IF EXISTS payments;
@@ -0,0 +1,31 @@
+-- Платежи по заказам: авторизация, списание, отмена и возвраты
+
+CREATE TABLE payments (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    order_id UUID NOT NULL UNIQUE REFERENCES orders(id) ON DELETE CASCADE,
+    provider VARCHAR(50) NOT NULL,
+    provider_ref VARCHAR(255), -- идентификатор платежа у провайдера
+    status VARCHAR(20) NOT NULL CHECK (status IN ('authorized', 'captured', 'partially_refunded', 'refunded', 'voided', 'failed')),
+    amount DECIMAL(10, 2) NOT NULL,
+    captured_amount DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    refunded_amount DECIMAL(10, 2) NOT NULL DEFAULT 0,
+    failure_reason TEXT,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    CHECK (refunded_amount <= captured_amount)
+);
+
+CREATE UNIQUE INDEX idx_payments_provider_ref ON payments(provider, provider_ref);
+
+CREATE TRIGGER update_payments_updated_at
+    BEFORE UPDATE ON payments
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+-- Обработанные вебхуки провайдера (идемпотентность повторных доставок)
+CREATE TABLE payment_webhook_events (
+    id VARCHAR(255) PRIMARY KEY,
+    payment_id UUID REFERENCES payments(id) ON DELETE CASCADE,
+    event_type VARCHAR(50) NOT NULL,
+    received_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
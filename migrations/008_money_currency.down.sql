@@ -0,0 +1,20 @@
+-- Откат валюты и точности денежных колонок
+
+ALTER TABLE payments
+    DROP COLUMN IF EXISTS currency,
+    ALTER COLUMN amount TYPE DECIMAL(10, 2),
+    ALTER COLUMN captured_amount TYPE DECIMAL(10, 2),
+    ALTER COLUMN refunded_amount TYPE DECIMAL(10, 2);
+
+ALTER TABLE promo_codes
+    DROP COLUMN IF EXISTS currency,
+    ALTER COLUMN amount TYPE DECIMAL(10, 2);
+
+ALTER TABLE order_items
+    ALTER COLUMN price TYPE DECIMAL(10, 2);
+
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS currency,
+    ALTER COLUMN total_amount TYPE DECIMAL(10, 2),
+    ALTER COLUMN delivery_cost TYPE DECIMAL(10, 2),
+    ALTER COLUMN discount_amount TYPE DECIMAL(10, 2);
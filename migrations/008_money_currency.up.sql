@@ -0,0 +1,21 @@
+-- Денежные суммы хранятся в DECIMAL и читаются без потерь в минимальных единицах;
+-- добавляем валюту к заказам, промокодам и платежам
+
+ALTER TABLE orders
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'RUB' CHECK (currency ~ '^[A-Z]{3}$'),
+    ALTER COLUMN total_amount TYPE DECIMAL(12, 2),
+    ALTER COLUMN delivery_cost TYPE DECIMAL(12, 2),
+    ALTER COLUMN discount_amount TYPE DECIMAL(12, 2);
+
+ALTER TABLE order_items
+    ALTER COLUMN price TYPE DECIMAL(12, 2);
+
+ALTER TABLE promo_codes
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'RUB' CHECK (currency ~ '^[A-Z]{3}$'),
+    ALTER COLUMN amount TYPE DECIMAL(12, 2);
+
+ALTER TABLE payments
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'RUB' CHECK (currency ~ '^[A-Z]{3}$'),
+    ALTER COLUMN amount TYPE DECIMAL(12, 2),
+    ALTER COLUMN captured_amount TYPE DECIMAL(12, 2),
+    ALTER COLUMN refunded_amount TYPE DECIMAL(12, 2);
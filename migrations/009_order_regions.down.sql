@@ -0,0 +1,7 @@
+-- Откат региона заказа
+
+DROP INDEX IF EXISTS idx_orders_currency_delivered;
+DROP INDEX IF EXISTS idx_orders_region_delivered;
+
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS region_code;
@@ -0,0 +1,8 @@
+-- Регион заказа определяется по точке забора и задает валюту, тариф и часовой пояс;
+-- существующие заказы относятся к региону по умолчанию
+
+ALTER TABLE orders
+    ADD COLUMN region_code VARCHAR(32) NOT NULL DEFAULT 'default';
+
+CREATE INDEX idx_orders_region_delivered ON orders(region_code, delivered_at);
+CREATE INDEX idx_orders_currency_delivered ON orders(currency, delivered_at);
@@ -0,0 +1,7 @@
+-- Откат чеков и налоговых категорий
+
+DROP TABLE IF EXISTS receipts;
+DROP SEQUENCE IF EXISTS receipt_number_seq;
+
+ALTER TABLE order_items
+    DROP COLUMN IF EXISTS tax_category;
@@ -0,0 +1,23 @@
+-- Налоговые категории товаров и чеки по доставленным заказам
+
+ALTER TABLE order_items
+    ADD COLUMN tax_category VARCHAR(16) NOT NULL DEFAULT 'standard'
+        CHECK (tax_category IN ('standard', 'reduced', 'zero', 'exempt'));
+
+-- Сквозная нумерация чеков без пропусков в рамках успешных транзакций
+CREATE SEQUENCE receipt_number_seq;
+
+CREATE TABLE receipts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    order_id UUID NOT NULL UNIQUE REFERENCES orders(id) ON DELETE CASCADE,
+    number VARCHAR(32) NOT NULL UNIQUE,
+    currency CHAR(3) NOT NULL CHECK (currency ~ '^[A-Z]{3}$'),
+    total DECIMAL(12, 2) NOT NULL,
+    tax_total DECIMAL(12, 2) NOT NULL,
+    payload JSONB NOT NULL, -- чек в JSON, из него же отдается формат json
+    html TEXT NOT NULL,
+    pdf BYTEA NOT NULL,
+    issued_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_receipts_issued_at ON receipts(issued_at);
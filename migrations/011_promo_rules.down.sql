@@ -0,0 +1,6 @@
+-- Откат правил промокодов
+
+DROP INDEX IF EXISTS idx_orders_customer_phone_digits;
+
+ALTER TABLE promo_codes
+    DROP COLUMN IF EXISTS rules;
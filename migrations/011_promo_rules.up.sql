@@ -0,0 +1,8 @@
+-- Правила применения промокодов: минимальная сумма, первый заказ, лимит на клиента,
+-- регионы, дни недели и часы, потолок процентной скидки
+
+ALTER TABLE promo_codes
+    ADD COLUMN rules JSONB NOT NULL DEFAULT '{}'::jsonb;
+
+-- Поиск заказов клиента по номеру телефона без форматирования
+CREATE INDEX idx_orders_customer_phone_digits ON orders ((regexp_replace(customer_phone, '\D', '', 'g')));
@@ -0,0 +1,10 @@
+-- Откат кампаний промокодов
+
+DROP INDEX IF EXISTS idx_orders_promo_code;
+DROP INDEX IF EXISTS idx_promo_codes_campaign;
+
+ALTER TABLE promo_codes
+    DROP COLUMN IF EXISTS campaign_id;
+
+DROP TRIGGER IF EXISTS update_campaigns_updated_at ON campaigns;
+DROP TABLE IF EXISTS campaigns;
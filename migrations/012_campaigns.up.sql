@@ -0,0 +1,26 @@
+-- Кампании промокодов: шаблон скидки и массово выпущенные коды
+
+CREATE TABLE campaigns (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    name VARCHAR(255) NOT NULL,
+    discount_type VARCHAR(20) NOT NULL CHECK (discount_type IN ('fixed', 'percent', 'free_delivery')),
+    amount DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    currency CHAR(3) NOT NULL DEFAULT 'RUB' CHECK (currency ~ '^[A-Z]{3}$'),
+    max_uses INTEGER NOT NULL DEFAULT 0, -- лимит каждого кода, 0 = безлимит
+    expires_at TIMESTAMP WITH TIME ZONE,
+    active BOOLEAN NOT NULL DEFAULT TRUE,
+    rules JSONB NOT NULL DEFAULT '{}'::jsonb,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE TRIGGER update_campaigns_updated_at
+    BEFORE UPDATE ON campaigns
+    FOR EACH ROW
+    EXECUTE FUNCTION update_updated_at_column();
+
+ALTER TABLE promo_codes
+    ADD COLUMN campaign_id UUID REFERENCES campaigns(id) ON DELETE CASCADE;
+
+CREATE INDEX idx_promo_codes_campaign ON promo_codes(campaign_id) WHERE campaign_id IS NOT NULL;
+CREATE INDEX idx_orders_promo_code ON orders(promo_code) WHERE promo_code IS NOT NULL;
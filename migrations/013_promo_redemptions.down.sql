@@ -0,0 +1,11 @@
+-- Откат истории применений промокодов
+
+DROP INDEX IF EXISTS idx_promo_redemptions_customer;
+DROP INDEX IF EXISTS idx_promo_redemptions_code;
+DROP TABLE IF EXISTS promo_redemptions;
+
+ALTER TABLE campaigns
+    DROP COLUMN IF EXISTS stacking;
+
+ALTER TABLE promo_codes
+    DROP COLUMN IF EXISTS stacking;
@@ -0,0 +1,35 @@
+-- История применений промокодов и правила их сочетания в одном заказе
+
+ALTER TABLE promo_codes
+    ADD COLUMN stacking VARCHAR(20) NOT NULL DEFAULT 'exclusive' CHECK (stacking IN ('exclusive', 'combinable'));
+
+ALTER TABLE campaigns
+    ADD COLUMN stacking VARCHAR(20) NOT NULL DEFAULT 'exclusive' CHECK (stacking IN ('exclusive', 'combinable'));
+
+-- Код хранится без внешнего ключа, чтобы история сохранялась после удаления промокода.
+-- Ключ на заказ отложенный: применение записывается до вставки заказа в той же транзакции.
+CREATE TABLE promo_redemptions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    code VARCHAR(64) NOT NULL,
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED,
+    customer_phone VARCHAR(32) NOT NULL DEFAULT '',
+    discount_type VARCHAR(20) NOT NULL,
+    discount_amount DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    currency CHAR(3) NOT NULL,
+    position INTEGER NOT NULL DEFAULT 0,
+    redeemed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    reversed_at TIMESTAMP WITH TIME ZONE,
+    UNIQUE (order_id, code)
+);
+
+CREATE INDEX idx_promo_redemptions_code ON promo_redemptions(code, redeemed_at DESC);
+CREATE INDEX idx_promo_redemptions_customer ON promo_redemptions(code, customer_phone) WHERE reversed_at IS NULL;
+
+-- Перенос уже примененных промокодов из заказов
+INSERT INTO promo_redemptions (code, order_id, customer_phone, discount_type, discount_amount, currency, position, redeemed_at, reversed_at)
+SELECT o.promo_code, o.id, regexp_replace(o.customer_phone, '\D', '', 'g'), COALESCE(p.discount_type, 'fixed'),
+       o.discount_amount, o.currency, 0, o.created_at,
+       CASE WHEN o.status = 'cancelled' THEN o.updated_at END
+FROM orders o
+LEFT JOIN promo_codes p ON p.code = o.promo_code
+WHERE o.promo_code IS NOT NULL AND o.promo_code <> '';
@@ -0,0 +1,18 @@
+-- Откат реферальной программы и бонусного счета
+
+DROP INDEX IF EXISTS idx_wallet_transactions_order_refund;
+DROP INDEX IF EXISTS idx_wallet_transactions_order_payment;
+DROP INDEX IF EXISTS idx_wallet_transactions_customer;
+DROP TABLE IF EXISTS wallet_transactions;
+DROP TABLE IF EXISTS wallets;
+
+DROP INDEX IF EXISTS idx_referrals_referee_active;
+DROP INDEX IF EXISTS idx_referrals_code;
+DROP TABLE IF EXISTS referrals;
+DROP TABLE IF EXISTS referral_codes;
+
+DROP INDEX IF EXISTS idx_orders_customer_phone_digits;
+
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS wallet_credit,
+    DROP COLUMN IF EXISTS device_id;
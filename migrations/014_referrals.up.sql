@@ -0,0 +1,58 @@
+-- Реферальная программа: персональные коды клиентов, приглашения и бонусный счет
+
+ALTER TABLE orders
+    ADD COLUMN device_id VARCHAR(128),
+    ADD COLUMN wallet_credit DECIMAL(12, 2) NOT NULL DEFAULT 0; -- часть discount_amount, оплаченная бонусами
+
+-- Проверки реферальной программы ищут заказы клиента по номеру без форматирования
+CREATE INDEX idx_orders_customer_phone_digits ON orders ((regexp_replace(customer_phone, '\D', '', 'g')));
+
+CREATE TABLE referral_codes (
+    code VARCHAR(64) PRIMARY KEY,
+    customer_phone VARCHAR(32) NOT NULL UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Ключ на заказ отложенный: приглашение записывается до вставки заказа в той же транзакции
+CREATE TABLE referrals (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    code VARCHAR(64) NOT NULL REFERENCES referral_codes(code) ON DELETE CASCADE,
+    referrer_phone VARCHAR(32) NOT NULL,
+    referee_phone VARCHAR(32) NOT NULL,
+    order_id UUID NOT NULL UNIQUE REFERENCES orders(id) ON DELETE CASCADE DEFERRABLE INITIALLY DEFERRED,
+    status VARCHAR(20) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'rewarded', 'rejected')),
+    reject_reason VARCHAR(32),
+    referrer_reward DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    referee_reward DECIMAL(12, 2) NOT NULL DEFAULT 0,
+    currency CHAR(3) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    rewarded_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX idx_referrals_code ON referrals(code, created_at DESC);
+-- Новый клиент может быть приглашен только один раз; отклоненные попытки не учитываются
+CREATE UNIQUE INDEX idx_referrals_referee_active ON referrals(referee_phone) WHERE status <> 'rejected';
+
+CREATE TABLE wallets (
+    customer_phone VARCHAR(32) NOT NULL,
+    currency CHAR(3) NOT NULL CHECK (currency ~ '^[A-Z]{3}$'),
+    balance DECIMAL(12, 2) NOT NULL DEFAULT 0 CHECK (balance >= 0),
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (customer_phone, currency)
+);
+
+CREATE TABLE wallet_transactions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    customer_phone VARCHAR(32) NOT NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('referral_reward', 'order_payment', 'order_refund')),
+    amount DECIMAL(12, 2) NOT NULL, -- начисления положительные, списания отрицательные
+    currency CHAR(3) NOT NULL,
+    order_id UUID REFERENCES orders(id) ON DELETE SET NULL DEFERRABLE INITIALLY DEFERRED,
+    referral_id UUID REFERENCES referrals(id) ON DELETE SET NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_wallet_transactions_customer ON wallet_transactions(customer_phone, created_at DESC);
+-- Списание и возврат бонусов по заказу выполняются не более одного раза
+CREATE UNIQUE INDEX idx_wallet_transactions_order_payment ON wallet_transactions(order_id) WHERE kind = 'order_payment';
+CREATE UNIQUE INDEX idx_wallet_transactions_order_refund ON wallet_transactions(order_id) WHERE kind = 'order_refund';
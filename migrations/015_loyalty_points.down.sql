@@ -0,0 +1,12 @@
+-- Откат баллов лояльности
+
+DROP INDEX IF EXISTS idx_loyalty_transactions_order_redeem;
+DROP INDEX IF EXISTS idx_loyalty_transactions_order_earn;
+DROP INDEX IF EXISTS idx_loyalty_transactions_lots;
+DROP INDEX IF EXISTS idx_loyalty_transactions_customer;
+DROP TABLE IF EXISTS loyalty_transactions;
+DROP TABLE IF EXISTS loyalty_accounts;
+
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS points_discount,
+    DROP COLUMN IF EXISTS points_redeemed;
@@ -0,0 +1,29 @@
+-- Баллы лояльности: начисление за доставленные заказы, списание при оформлении и сгорание
+
+ALTER TABLE orders
+    ADD COLUMN points_redeemed INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN points_discount DECIMAL(12, 2) NOT NULL DEFAULT 0; -- часть discount_amount, оплаченная баллами
+
+CREATE TABLE loyalty_accounts (
+    customer_phone VARCHAR(32) PRIMARY KEY,
+    balance INTEGER NOT NULL DEFAULT 0 CHECK (balance >= 0), -- сумма remaining по партиям клиента
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+-- Ключ на заказ отложенный: списание записывается до вставки заказа в той же транзакции
+CREATE TABLE loyalty_transactions (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    customer_phone VARCHAR(32) NOT NULL,
+    kind VARCHAR(20) NOT NULL CHECK (kind IN ('earn', 'redeem', 'expire', 'reversal')),
+    points INTEGER NOT NULL, -- начисления положительные, списания отрицательные
+    remaining INTEGER NOT NULL DEFAULT 0 CHECK (remaining >= 0), -- непотраченный остаток партии
+    order_id UUID REFERENCES orders(id) ON DELETE SET NULL DEFERRABLE INITIALLY DEFERRED,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_loyalty_transactions_customer ON loyalty_transactions(customer_phone, created_at DESC);
+CREATE INDEX idx_loyalty_transactions_lots ON loyalty_transactions(customer_phone, expires_at) WHERE remaining > 0;
+-- Начисление и списание по заказу выполняются не более одного раза
+CREATE UNIQUE INDEX idx_loyalty_transactions_order_earn ON loyalty_transactions(order_id) WHERE kind = 'earn';
+CREATE UNIQUE INDEX idx_loyalty_transactions_order_redeem ON loyalty_transactions(order_id) WHERE kind = 'redeem';
@@ -0,0 +1,12 @@
+-- Откат поиска заказов
+
+DROP INDEX IF EXISTS idx_orders_total_amount;
+DROP INDEX IF EXISTS idx_orders_phone_digits_trgm;
+DROP INDEX IF EXISTS idx_orders_merchant_name_trgm;
+DROP INDEX IF EXISTS idx_orders_delivery_address_trgm;
+DROP INDEX IF EXISTS idx_orders_customer_name_trgm;
+DROP INDEX IF EXISTS idx_orders_search_vector;
+
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS search_vector,
+    DROP COLUMN IF EXISTS merchant_name;
@@ -0,0 +1,22 @@
+-- Поиск заказов для поддержки: продавец, полнотекстовый индекс и триграммы для поиска по фрагментам
+
+CREATE EXTENSION IF NOT EXISTS pg_trgm;
+
+ALTER TABLE orders ADD COLUMN merchant_name VARCHAR(255);
+
+-- Словарь simple: имена и адреса не нужно приводить к основе слова
+ALTER TABLE orders ADD COLUMN search_vector tsvector GENERATED ALWAYS AS (
+    to_tsvector('simple',
+        coalesce(customer_name, '') || ' ' ||
+        coalesce(delivery_address, '') || ' ' ||
+        coalesce(pickup_address, '') || ' ' ||
+        coalesce(merchant_name, '') || ' ' ||
+        coalesce(promo_code, ''))
+) STORED;
+
+CREATE INDEX idx_orders_search_vector ON orders USING GIN (search_vector);
+CREATE INDEX idx_orders_customer_name_trgm ON orders USING GIN (customer_name gin_trgm_ops);
+CREATE INDEX idx_orders_delivery_address_trgm ON orders USING GIN (delivery_address gin_trgm_ops);
+CREATE INDEX idx_orders_merchant_name_trgm ON orders USING GIN (merchant_name gin_trgm_ops);
+CREATE INDEX idx_orders_phone_digits_trgm ON orders USING GIN ((regexp_replace(customer_phone, '\D', '', 'g')) gin_trgm_ops);
+CREATE INDEX idx_orders_total_amount ON orders(total_amount);
@@ -0,0 +1,9 @@
+-- Откат индексов keyset-пагинации
+
+CREATE INDEX IF NOT EXISTS idx_orders_created_at ON orders(created_at);
+
+DROP INDEX IF EXISTS idx_reviews_courier_created_at_id;
+DROP INDEX IF EXISTS idx_promo_codes_created_at_code;
+DROP INDEX IF EXISTS idx_couriers_rating_keyset;
+DROP INDEX IF EXISTS idx_couriers_created_at_id;
+DROP INDEX IF EXISTS idx_orders_created_at_id;
@@ -0,0 +1,10 @@
+-- Индексы для keyset-пагинации списков: порядок колонок совпадает с ORDER BY запросов
+
+CREATE INDEX idx_orders_created_at_id ON orders(created_at DESC, id DESC);
+CREATE INDEX idx_couriers_created_at_id ON couriers(created_at DESC, id DESC);
+CREATE INDEX idx_couriers_rating_keyset ON couriers(rating DESC, total_reviews DESC, created_at DESC, id DESC);
+CREATE INDEX idx_promo_codes_created_at_code ON promo_codes(created_at DESC, code DESC);
+CREATE INDEX idx_reviews_courier_created_at_id ON reviews(courier_id, created_at DESC, id DESC);
+
+-- Прежний индекс по created_at покрывается составным
+DROP INDEX IF EXISTS idx_orders_created_at;
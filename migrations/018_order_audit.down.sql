@@ -0,0 +1,17 @@
+-- Откат аудита статусов заказа
+
+CREATE INDEX IF NOT EXISTS idx_order_status_history_order_id ON order_status_history(order_id);
+DROP INDEX IF EXISTS idx_order_status_history_timeline;
+
+DROP TRIGGER IF EXISTS log_order_created_trigger ON orders;
+
+CREATE OR REPLACE FUNCTION log_order_status_change()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF OLD.status IS DISTINCT FROM NEW.status THEN
+        INSERT INTO order_status_history (order_id, old_status, new_status, courier_id, changed_by)
+        VALUES (NEW.id, OLD.status, NEW.status, NEW.courier_id, 'system');
+    END IF;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
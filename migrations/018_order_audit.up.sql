@@ -0,0 +1,34 @@
+-- Аудит статусов заказа: исполнитель передается в транзакции через app.actor, создание заказа тоже попадает в историю
+
+CREATE OR REPLACE FUNCTION log_order_status_change()
+RETURNS TRIGGER AS $$
+DECLARE
+    -- Сервис выполняет set_config('app.actor', 'courier:<id>', true) перед изменением заказа
+    actor TEXT := COALESCE(NULLIF(current_setting('app.actor', true), ''), 'system');
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO order_status_history (order_id, old_status, new_status, courier_id, changed_by)
+        VALUES (NEW.id, NULL, NEW.status, NEW.courier_id, actor);
+    ELSIF OLD.status IS DISTINCT FROM NEW.status THEN
+        INSERT INTO order_status_history (order_id, old_status, new_status, courier_id, changed_by)
+        VALUES (NEW.id, OLD.status, NEW.status, NEW.courier_id, actor);
+    END IF;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+CREATE TRIGGER log_order_created_trigger
+    AFTER INSERT ON orders
+    FOR EACH ROW
+    EXECUTE FUNCTION log_order_status_change();
+
+-- Начальная запись для уже созданных заказов, чтобы хронология начиналась с created
+INSERT INTO order_status_history (order_id, old_status, new_status, changed_at, changed_by)
+SELECT o.id, NULL, 'created', o.created_at, 'system:backfill'
+FROM orders o
+WHERE NOT EXISTS (
+    SELECT 1 FROM order_status_history h WHERE h.order_id = o.id AND h.old_status IS NULL
+);
+
+CREATE INDEX idx_order_status_history_timeline ON order_status_history(order_id, changed_at);
+DROP INDEX IF EXISTS idx_order_status_history_order_id;
@@ -0,0 +1,8 @@
+-- Откат новых статусов заказа: незавершенные заказы возвращаются в ближайший прежний статус
+
+UPDATE orders SET status = 'ready' WHERE status = 'picked_up';
+UPDATE orders SET status = 'in_delivery' WHERE status = 'delivery_failed';
+UPDATE orders SET status = 'cancelled' WHERE status = 'returned';
+
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_status_check;
+ALTER TABLE orders ADD CONSTRAINT orders_status_check CHECK (status IN ('created', 'accepted', 'preparing', 'ready', 'in_delivery', 'delivered', 'cancelled'));
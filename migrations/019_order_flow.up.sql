@@ -0,0 +1,7 @@
+-- Новые статусы заказа для настраиваемой машины состояний: picked_up, delivery_failed, returned
+
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_status_check;
+ALTER TABLE orders ADD CONSTRAINT orders_status_check CHECK (status IN (
+    'created', 'accepted', 'preparing', 'ready', 'picked_up', 'in_delivery',
+    'delivered', 'cancelled', 'delivery_failed', 'returned'
+));
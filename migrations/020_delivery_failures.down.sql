@@ -0,0 +1,16 @@
+-- Откат неудачной доставки и возврата: заказы в пути обратно считаются не врученными
+
+DROP INDEX IF EXISTS idx_order_contact_attempts_order;
+DROP TABLE IF EXISTS order_contact_attempts;
+
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS return_cost,
+    DROP COLUMN IF EXISTS delivery_attempts;
+
+UPDATE orders SET status = 'delivery_failed' WHERE status = 'returning';
+
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_status_check;
+ALTER TABLE orders ADD CONSTRAINT orders_status_check CHECK (status IN (
+    'created', 'accepted', 'preparing', 'ready', 'picked_up', 'in_delivery',
+    'delivered', 'cancelled', 'delivery_failed', 'returned'
+));
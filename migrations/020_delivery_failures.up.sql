@@ -0,0 +1,26 @@
+-- Неудачная доставка и возврат: статус returning, счетчик попыток вручения, стоимость обратного плеча
+-- и журнал попыток связаться с клиентом
+
+ALTER TABLE orders DROP CONSTRAINT IF EXISTS orders_status_check;
+ALTER TABLE orders ADD CONSTRAINT orders_status_check CHECK (status IN (
+    'created', 'accepted', 'preparing', 'ready', 'picked_up', 'in_delivery',
+    'delivered', 'cancelled', 'delivery_failed', 'returning', 'returned'
+));
+
+ALTER TABLE orders
+    ADD COLUMN delivery_attempts INTEGER NOT NULL DEFAULT 0, -- попыток, закончившихся delivery_failed
+    ADD COLUMN return_cost DECIMAL(12, 2);                   -- заполняется при переводе в returning
+
+CREATE TABLE order_contact_attempts (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    order_id UUID NOT NULL REFERENCES orders(id) ON DELETE CASCADE,
+    courier_id UUID REFERENCES couriers(id) ON DELETE SET NULL,
+    attempt INTEGER NOT NULL CHECK (attempt > 0), -- номер попытки вручения, к которой относится звонок
+    channel VARCHAR(20) NOT NULL CHECK (channel IN ('call', 'sms', 'messenger', 'doorbell')),
+    result VARCHAR(20) NOT NULL CHECK (result IN ('no_answer', 'unreachable', 'wrong_address', 'refused', 'reached')),
+    note TEXT,
+    logged_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_order_contact_attempts_order ON order_contact_attempts(order_id, attempt, created_at);
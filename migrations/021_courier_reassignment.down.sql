@@ -0,0 +1,19 @@
+-- Откат снятия и переназначения курьера: журнал снова пишется только при смене статуса
+
+CREATE OR REPLACE FUNCTION log_order_status_change()
+RETURNS TRIGGER AS $$
+DECLARE
+    actor TEXT := COALESCE(NULLIF(current_setting('app.actor', true), ''), 'system');
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO order_status_history (order_id, old_status, new_status, courier_id, changed_by)
+        VALUES (NEW.id, NULL, NEW.status, NEW.courier_id, actor);
+    ELSIF OLD.status IS DISTINCT FROM NEW.status THEN
+        INSERT INTO order_status_history (order_id, old_status, new_status, courier_id, changed_by)
+        VALUES (NEW.id, OLD.status, NEW.status, NEW.courier_id, actor);
+    END IF;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
+
+ALTER TABLE order_status_history DROP COLUMN IF EXISTS reason;
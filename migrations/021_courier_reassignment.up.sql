@@ -0,0 +1,22 @@
+-- Снятие и переназначение курьера диспетчером: причина пишется в журнал, смена курьера без смены статуса тоже попадает в историю
+
+ALTER TABLE order_status_history ADD COLUMN reason TEXT;
+
+CREATE OR REPLACE FUNCTION log_order_status_change()
+RETURNS TRIGGER AS $$
+DECLARE
+    -- Сервис выполняет set_config('app.actor', 'courier:<id>', true) перед изменением заказа
+    actor TEXT := COALESCE(NULLIF(current_setting('app.actor', true), ''), 'system');
+    -- Причина передается так же через set_config('app.reason', ..., true), например при переназначении
+    change_reason TEXT := NULLIF(current_setting('app.reason', true), '');
+BEGIN
+    IF TG_OP = 'INSERT' THEN
+        INSERT INTO order_status_history (order_id, old_status, new_status, courier_id, changed_by, reason)
+        VALUES (NEW.id, NULL, NEW.status, NEW.courier_id, actor, change_reason);
+    ELSIF OLD.status IS DISTINCT FROM NEW.status OR OLD.courier_id IS DISTINCT FROM NEW.courier_id THEN
+        INSERT INTO order_status_history (order_id, old_status, new_status, courier_id, changed_by, reason)
+        VALUES (NEW.id, OLD.status, NEW.status, NEW.courier_id, actor, change_reason);
+    END IF;
+    RETURN NEW;
+END;
+$$ language 'plpgsql';
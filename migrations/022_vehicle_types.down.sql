@@ -0,0 +1,16 @@
+-- Откат транспорта курьеров и габаритов заказов
+
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS volume_l,
+    DROP COLUMN IF EXISTS weight_kg,
+    DROP COLUMN IF EXISTS vehicle_type;
+
+ALTER TABLE order_items
+    DROP COLUMN IF EXISTS volume_l,
+    DROP COLUMN IF EXISTS weight_kg;
+
+ALTER TABLE couriers
+    DROP COLUMN IF EXISTS max_range_km,
+    DROP COLUMN IF EXISTS max_volume_l,
+    DROP COLUMN IF EXISTS max_weight_kg,
+    DROP COLUMN IF EXISTS vehicle_type;
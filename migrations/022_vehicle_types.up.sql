@@ -0,0 +1,19 @@
+-- Транспорт курьеров и габариты заказов: тип транспорта, грузоподъемность, объем багажника и дальность поездки.
+-- Пустые ограничения курьера означают отсутствие ограничения, поэтому существующие курьеры назначаются как раньше
+
+ALTER TABLE couriers
+    ADD COLUMN vehicle_type VARCHAR(20) NOT NULL DEFAULT 'bike' CHECK (vehicle_type IN ('bike', 'scooter', 'car')),
+    ADD COLUMN max_weight_kg DECIMAL(8, 2) CHECK (max_weight_kg > 0),
+    ADD COLUMN max_volume_l DECIMAL(8, 2) CHECK (max_volume_l > 0),
+    ADD COLUMN max_range_km DECIMAL(8, 2) CHECK (max_range_km > 0);
+
+-- Вес и объем одной единицы товара
+ALTER TABLE order_items
+    ADD COLUMN weight_kg DECIMAL(10, 3) NOT NULL DEFAULT 0 CHECK (weight_kg >= 0),
+    ADD COLUMN volume_l DECIMAL(10, 3) NOT NULL DEFAULT 0 CHECK (volume_l >= 0);
+
+-- Габариты заказа и транспорт, по тарифу которого посчитана доставка
+ALTER TABLE orders
+    ADD COLUMN vehicle_type VARCHAR(20) NOT NULL DEFAULT 'bike' CHECK (vehicle_type IN ('bike', 'scooter', 'car')),
+    ADD COLUMN weight_kg DECIMAL(10, 3) NOT NULL DEFAULT 0,
+    ADD COLUMN volume_l DECIMAL(10, 3) NOT NULL DEFAULT 0;
@@ -0,0 +1,12 @@
+-- Откат подключения курьеров
+
+DROP INDEX IF EXISTS idx_courier_documents_expires_at;
+DROP INDEX IF EXISTS idx_courier_documents_courier;
+DROP TABLE IF EXISTS courier_documents;
+
+DROP INDEX IF EXISTS idx_couriers_onboarding_status;
+
+ALTER TABLE couriers
+    DROP COLUMN IF EXISTS verified_at,
+    DROP COLUMN IF EXISTS onboarding_reason,
+    DROP COLUMN IF EXISTS onboarding_status;
@@ -0,0 +1,26 @@
+-- Подключение курьеров: этапы проверки и документы со сроком действия.
+-- Курьеры, работавшие до появления проверки, считаются подтвержденными
+
+ALTER TABLE couriers
+    ADD COLUMN onboarding_status VARCHAR(30) NOT NULL DEFAULT 'applied'
+        CHECK (onboarding_status IN ('applied', 'documents_submitted', 'verified', 'suspended', 'deactivated')),
+    ADD COLUMN onboarding_reason TEXT,                  -- причина отказа, приостановки или отключения
+    ADD COLUMN verified_at TIMESTAMP WITH TIME ZONE;
+
+UPDATE couriers SET onboarding_status = 'verified', verified_at = created_at;
+
+CREATE INDEX idx_couriers_onboarding_status ON couriers(onboarding_status);
+
+CREATE TABLE courier_documents (
+    id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    courier_id UUID NOT NULL REFERENCES couriers(id) ON DELETE CASCADE,
+    doc_type VARCHAR(30) NOT NULL CHECK (doc_type IN ('identity', 'driver_license', 'vehicle_registration', 'insurance')),
+    storage_key TEXT NOT NULL,
+    content_type VARCHAR(100),
+    size_bytes BIGINT NOT NULL CHECK (size_bytes >= 0),
+    expires_at DATE,                                    -- для прав и страховки
+    uploaded_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_courier_documents_courier ON courier_documents(courier_id, doc_type, uploaded_at);
+CREATE INDEX idx_courier_documents_expires_at ON courier_documents(expires_at) WHERE expires_at IS NOT NULL;
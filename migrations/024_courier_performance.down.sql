@@ -0,0 +1,5 @@
+-- Откат оценки работы курьеров
+
+DROP INDEX IF EXISTS idx_courier_performance_history_courier;
+DROP TABLE IF EXISTS courier_performance_history;
+DROP TABLE IF EXISTS courier_performance;
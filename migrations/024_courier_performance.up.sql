@@ -0,0 +1,32 @@
+-- Оценка работы курьеров: счетчики обновляются инкрементально по событиям заказов из Kafka,
+-- после каждого события в историю пишется снимок оценки. event_id в истории защищает от повторной обработки
+
+CREATE TABLE courier_performance (
+    courier_id UUID PRIMARY KEY REFERENCES couriers(id) ON DELETE CASCADE,
+    assignments_offered INTEGER NOT NULL DEFAULT 0,
+    assignments_dropped INTEGER NOT NULL DEFAULT 0,   -- курьера сняли с заказа или передали заказ другому
+    orders_delivered INTEGER NOT NULL DEFAULT 0,
+    orders_cancelled INTEGER NOT NULL DEFAULT 0,
+    timed_deliveries INTEGER NOT NULL DEFAULT 0,      -- доставки, для которых посчитано расчетное время
+    on_time_deliveries INTEGER NOT NULL DEFAULT 0,
+    delivery_minutes DOUBLE PRECISION NOT NULL DEFAULT 0,
+    eta_minutes DOUBLE PRECISION NOT NULL DEFAULT 0,
+    score DECIMAL(5, 4) NOT NULL,
+    updated_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE courier_performance_history (
+    event_id UUID PRIMARY KEY,
+    courier_id UUID NOT NULL REFERENCES couriers(id) ON DELETE CASCADE,
+    event_type VARCHAR(50) NOT NULL,
+    order_id UUID NOT NULL,
+    acceptance_rate DECIMAL(5, 4) NOT NULL,
+    cancellation_rate DECIMAL(5, 4) NOT NULL,
+    on_time_rate DECIMAL(5, 4) NOT NULL,
+    eta_ratio DECIMAL(8, 4) NOT NULL,
+    bayes_rating DECIMAL(3, 2) NOT NULL,
+    score DECIMAL(5, 4) NOT NULL,
+    recorded_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_courier_performance_history_courier ON courier_performance_history(courier_id, recorded_at DESC);
@@ -0,0 +1,39 @@
+-- Откат модерации отзывов: возвращается инкрементальный пересчет рейтинга только при вставке
+
+DROP TRIGGER IF EXISTS trg_update_courier_rating_on_review ON reviews;
+
+CREATE OR REPLACE FUNCTION update_courier_rating_on_review()
+RETURNS TRIGGER AS $$
+DECLARE
+    new_total INTEGER;
+    new_rating DECIMAL(3, 2);
+BEGIN
+    SELECT total_reviews + 1, ((rating * total_reviews) + NEW.rating)::DECIMAL(5,2) / (total_reviews + 1)
+    INTO new_total, new_rating
+    FROM couriers
+    WHERE id = NEW.courier_id
+    FOR UPDATE;
+
+    UPDATE couriers
+    SET total_reviews = new_total,
+        rating = ROUND(new_rating::NUMERIC, 2),
+        updated_at = NOW()
+    WHERE id = NEW.courier_id;
+
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER trg_update_courier_rating_on_review
+AFTER INSERT ON reviews
+FOR EACH ROW
+EXECUTE FUNCTION update_courier_rating_on_review();
+
+DROP INDEX IF EXISTS idx_reviews_status;
+
+ALTER TABLE reviews
+    DROP COLUMN IF EXISTS edited_at,
+    DROP COLUMN IF EXISTS replied_at,
+    DROP COLUMN IF EXISTS reply,
+    DROP COLUMN IF EXISTS moderation_reason,
+    DROP COLUMN IF EXISTS status;
@@ -0,0 +1,48 @@
+-- Модерация отзывов: состояния, ответы курьеров и правка отзыва клиентом.
+-- Рейтинг курьера теперь пересчитывается по всем нескрытым отзывам при вставке, правке, смене
+-- состояния и удалении отзыва, а не только инкрементально при вставке
+
+ALTER TABLE reviews
+    ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT 'published'
+        CHECK (status IN ('pending', 'published', 'hidden')),
+    ADD COLUMN moderation_reason VARCHAR(500),
+    ADD COLUMN reply TEXT,
+    ADD COLUMN replied_at TIMESTAMP WITH TIME ZONE,
+    ADD COLUMN edited_at TIMESTAMP WITH TIME ZONE;
+
+CREATE INDEX idx_reviews_status ON reviews(status, created_at);
+
+CREATE OR REPLACE FUNCTION update_courier_rating_on_review()
+RETURNS TRIGGER AS $$
+DECLARE
+    target_courier UUID;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        target_courier := OLD.courier_id;
+    ELSE
+        target_courier := NEW.courier_id;
+    END IF;
+
+    PERFORM 1 FROM couriers WHERE id = target_courier FOR UPDATE;
+
+    UPDATE couriers
+    SET total_reviews = stats.total,
+        rating = ROUND(stats.avg_rating, 2),
+        updated_at = NOW()
+    FROM (
+        SELECT COUNT(*) AS total, COALESCE(AVG(rating), 0)::NUMERIC AS avg_rating
+        FROM reviews
+        WHERE courier_id = target_courier AND status <> 'hidden'
+    ) stats
+    WHERE couriers.id = target_courier;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+DROP TRIGGER IF EXISTS trg_update_courier_rating_on_review ON reviews;
+
+CREATE TRIGGER trg_update_courier_rating_on_review
+AFTER INSERT OR DELETE OR UPDATE OF rating, status ON reviews
+FOR EACH ROW
+EXECUTE FUNCTION update_courier_rating_on_review();
@@ -0,0 +1,3 @@
+ALTER TABLE reviews
+    DROP COLUMN IF EXISTS tags,
+    DROP COLUMN IF EXISTS merchant_rating;
@@ -0,0 +1,7 @@
+-- Структурированные теги отзыва и отдельная оценка заведения (orders.merchant_name).
+-- Теги ограничены фиксированным списком, частоты считает аналитика по курьерам и заведениям
+
+ALTER TABLE reviews
+    ADD COLUMN merchant_rating SMALLINT CHECK (merchant_rating BETWEEN 1 AND 5),
+    ADD COLUMN tags TEXT[] NOT NULL DEFAULT '{}'
+        CHECK (tags <@ ARRAY['late', 'cold_food', 'rude', 'great_service']::TEXT[]);
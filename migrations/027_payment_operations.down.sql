@@ -0,0 +1,11 @@
+DROP INDEX IF EXISTS idx_payments_pending_operation;
+
+ALTER TABLE payments DROP COLUMN IF EXISTS pending_operation;
+
+-- Платежи, так и не авторизованные у провайдера, считаются неуспешными
+UPDATE payments SET status = 'failed', failure_reason = 'authorization was not performed' WHERE status = 'pending';
+
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS payments_status_check;
+ALTER TABLE payments ADD CONSTRAINT payments_status_check CHECK (status IN (
+    'authorized', 'captured', 'partially_refunded', 'refunded', 'voided', 'failed'
+));
@@ -0,0 +1,13 @@
+-- Операции у платежного провайдера выполняются после фиксации транзакции заказа: в транзакции
+-- платеж только помечается ожидающей операцией, которую выполняет сервис сразу после коммита
+-- или периодическая сверка, если процесс упал между коммитом и вызовом провайдера
+
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS payments_status_check;
+ALTER TABLE payments ADD CONSTRAINT payments_status_check CHECK (status IN (
+    'pending', 'authorized', 'captured', 'partially_refunded', 'refunded', 'voided', 'failed'
+));
+
+ALTER TABLE payments
+    ADD COLUMN pending_operation VARCHAR(20) CHECK (pending_operation IN ('authorize', 'capture', 'release'));
+
+CREATE INDEX idx_payments_pending_operation ON payments(updated_at) WHERE pending_operation IS NOT NULL;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS idx_ledger_transactions_courier_currency;
+
+ALTER TABLE ledger_transactions DROP COLUMN IF EXISTS currency;
@@ -0,0 +1,15 @@
+-- Валюта начислений курьерам: выплата за доставку начисляется в валюте заказа по правилам
+-- его региона, балансы и реестр выплат считаются отдельно по каждой валюте
+
+ALTER TABLE ledger_transactions
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'RUB' CHECK (currency ~ '^[A-Z]{3}$');
+
+-- Существующие начисления по заказам получают валюту заказа
+UPDATE ledger_transactions t
+SET currency = o.currency
+FROM orders o
+WHERE o.id = t.order_id AND o.currency <> t.currency;
+
+ALTER TABLE ledger_transactions ALTER COLUMN currency DROP DEFAULT;
+
+CREATE INDEX idx_ledger_transactions_courier_currency ON ledger_transactions(courier_id, currency, created_at);
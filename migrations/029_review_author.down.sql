@@ -0,0 +1,31 @@
+-- Откат: рейтинг курьера снова считается по всем нескрытым отзывам, автор отзыва не хранится
+
+CREATE OR REPLACE FUNCTION update_courier_rating_on_review()
+RETURNS TRIGGER AS $$
+DECLARE
+    target_courier UUID;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        target_courier := OLD.courier_id;
+    ELSE
+        target_courier := NEW.courier_id;
+    END IF;
+
+    PERFORM 1 FROM couriers WHERE id = target_courier FOR UPDATE;
+
+    UPDATE couriers
+    SET total_reviews = stats.total,
+        rating = ROUND(stats.avg_rating, 2),
+        updated_at = NOW()
+    FROM (
+        SELECT COUNT(*) AS total, COALESCE(AVG(rating), 0)::NUMERIC AS avg_rating
+        FROM reviews
+        WHERE courier_id = target_courier AND status <> 'hidden'
+    ) stats
+    WHERE couriers.id = target_courier;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+ALTER TABLE reviews DROP COLUMN IF EXISTS author;
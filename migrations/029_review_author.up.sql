@@ -0,0 +1,53 @@
+-- Рейтинг курьера учитывает только опубликованные отзывы: отзыв, ждущий модератора, в рейтинг
+-- не попадает до публикации. У отзыва сохраняется автор, чтобы править его мог только он сам
+
+ALTER TABLE reviews ADD COLUMN author VARCHAR(255);
+
+CREATE OR REPLACE FUNCTION update_courier_rating_on_review()
+RETURNS TRIGGER AS $$
+DECLARE
+    target_courier UUID;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        target_courier := OLD.courier_id;
+    ELSE
+        target_courier := NEW.courier_id;
+    END IF;
+
+    PERFORM 1 FROM couriers WHERE id = target_courier FOR UPDATE;
+
+    UPDATE couriers
+    SET total_reviews = stats.total,
+        rating = ROUND(stats.avg_rating, 2),
+        updated_at = NOW()
+    FROM (
+        SELECT COUNT(*) AS total, COALESCE(AVG(rating), 0)::NUMERIC AS avg_rating
+        FROM reviews
+        WHERE courier_id = target_courier AND status = 'published'
+    ) stats
+    WHERE couriers.id = target_courier;
+
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Пересчитываем рейтинг курьеров, у которых есть отзывы на модерации
+UPDATE couriers c
+SET total_reviews = stats.total,
+    rating = ROUND(stats.avg_rating, 2),
+    updated_at = NOW()
+FROM (
+    SELECT courier_id,
+           COUNT(*) FILTER (WHERE status = 'published') AS total,
+           COALESCE(AVG(rating) FILTER (WHERE status = 'published'), 0)::NUMERIC AS avg_rating
+    FROM reviews
+    GROUP BY courier_id
+    HAVING COUNT(*) FILTER (WHERE status = 'pending') > 0
+) stats
+WHERE c.id = stats.courier_id;
+
+-- Оценка скрытого или ждущего модератора отзыва не копируется в заказ
+UPDATE orders o
+SET rating = NULL, review_comment = NULL
+FROM reviews r
+WHERE r.order_id = o.id AND r.status <> 'published' AND o.rating IS NOT NULL;
@@ -0,0 +1,8 @@
+-- Невыполненные возвраты снимаются: до этой миграции возврат выполнялся сразу и не откладывался
+UPDATE payments SET pending_operation = NULL WHERE pending_operation = 'refund';
+
+ALTER TABLE payments DROP COLUMN IF EXISTS pending_amount;
+
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS payments_pending_operation_check;
+ALTER TABLE payments ADD CONSTRAINT payments_pending_operation_check
+    CHECK (pending_operation IN ('authorize', 'capture', 'release'));
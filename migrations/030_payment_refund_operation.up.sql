@@ -0,0 +1,8 @@
+-- Возврат по запросу выполняется так же, как списание и отмена: в транзакции платеж помечается
+-- операцией refund с суммой, а провайдер вызывается после коммита или при сверке
+
+ALTER TABLE payments DROP CONSTRAINT IF EXISTS payments_pending_operation_check;
+ALTER TABLE payments ADD CONSTRAINT payments_pending_operation_check
+    CHECK (pending_operation IN ('authorize', 'capture', 'release', 'refund'));
+
+ALTER TABLE payments ADD COLUMN pending_amount DECIMAL(12, 2) CHECK (pending_amount > 0);
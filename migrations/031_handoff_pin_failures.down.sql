@@ -0,0 +1,3 @@
+-- Откат: неверные PIN вручения не считаются
+
+ALTER TABLE orders DROP COLUMN IF EXISTS handoff_pin_failures;
@@ -0,0 +1,4 @@
+-- Неверные PIN вручения считаются по заказу: после нескольких ошибок PIN не принимается,
+-- чтобы 4-значный код нельзя было подобрать перебором
+
+ALTER TABLE orders ADD COLUMN handoff_pin_failures INT NOT NULL DEFAULT 0 CHECK (handoff_pin_failures >= 0);